@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	camerascfg "github.com/owlcms/replays/internal/config/cameras"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// maxClipWindow bounds the footage served by one clip request.
+const maxClipWindow = 15 * time.Minute
+
+// bufferSegment is one file of the local circular recording and the wall
+// clock interval it covers.
+type bufferSegment struct {
+	path  string
+	start time.Time
+	end   time.Time
+}
+
+// listBufferSegments returns the segments of a stream, oldest first. A
+// segment ends when its file was last written and starts when the previous
+// one ended; the oldest segment is assumed to be a full segment long.
+func listBufferSegments(local camerascfg.LocalRecording, port int) ([]bufferSegment, error) {
+	paths, err := filepath.Glob(local.SegmentGlob(port))
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]bufferSegment, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		segments = append(segments, bufferSegment{path: path, end: info.ModTime()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].end.Before(segments[j].end) })
+
+	length := time.Duration(local.SegmentSeconds) * time.Second
+	for i := range segments {
+		segments[i].start = segments[i].end.Add(-length)
+		if i > 0 && segments[i].end.Sub(segments[i-1].end) <= 2*length {
+			segments[i].start = segments[i-1].end
+		}
+	}
+	return segments, nil
+}
+
+// selectClipSegments returns the consecutive segments overlapping [from, to].
+func selectClipSegments(segments []bufferSegment, from, to time.Time) []bufferSegment {
+	var selected []bufferSegment
+	for _, segment := range segments {
+		if segment.end.Before(from) || segment.start.After(to) {
+			continue
+		}
+		selected = append(selected, segment)
+	}
+	return selected
+}
+
+// startClipServer serves the local circular recording to replays machines
+// that missed or lost a replay.
+func startClipServer(local camerascfg.LocalRecording) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/clip", func(w http.ResponseWriter, r *http.Request) {
+		serveClip(local, w, r)
+	})
+	addr := fmt.Sprintf(":%d", local.HTTPPort)
+	go func() {
+		logging.InfoLogger.Printf("Serving local recordings from %s on %s", local.Dir, addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logging.ErrorLogger.Printf("Local recording server stopped: %v", err)
+		}
+	}()
+}
+
+// serveClip handles GET /clip?port=P&from=MS&to=MS, where from and to are
+// Unix times in milliseconds. The body is the MPEG-TS of the segments
+// covering the window; the X-Clip-Start header gives the wall clock time of
+// its first frame, so that the caller can cut the exact window.
+func serveClip(local camerascfg.LocalRecording, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	port, portErr := strconv.Atoi(query.Get("port"))
+	fromMs, fromErr := strconv.ParseInt(query.Get("from"), 10, 64)
+	toMs, toErr := strconv.ParseInt(query.Get("to"), 10, 64)
+	if portErr != nil || fromErr != nil || toErr != nil || port <= 0 || toMs <= fromMs {
+		http.Error(w, "expected port, from and to (Unix milliseconds, from < to)", http.StatusBadRequest)
+		return
+	}
+	from := time.UnixMilli(fromMs)
+	to := time.UnixMilli(toMs)
+	if to.Sub(from) > maxClipWindow {
+		http.Error(w, fmt.Sprintf("window longer than %s", maxClipWindow), http.StatusBadRequest)
+		return
+	}
+
+	segments, err := listBufferSegments(local, port)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	selected := selectClipSegments(segments, from, to)
+	if len(selected) == 0 {
+		logging.WarningLogger.Printf("Clip request for port %d %s-%s: no local recording covers it", port, from.Format("15:04:05"), to.Format("15:04:05"))
+		http.Error(w, "no local recording covers this window", http.StatusNotFound)
+		return
+	}
+
+	logging.InfoLogger.Printf("Serving clip for port %d %s-%s from %d segment(s)", port, from.Format("15:04:05"), to.Format("15:04:05"), len(selected))
+	w.Header().Set("Content-Type", "video/mp2t")
+	w.Header().Set("X-Clip-Start", strconv.FormatInt(selected[0].start.UnixMilli(), 10))
+	for _, segment := range selected {
+		file, err := os.Open(segment.path)
+		if err != nil {
+			logging.ErrorLogger.Printf("Clip for port %d: %v", port, err)
+			return
+		}
+		_, err = io.Copy(w, file)
+		file.Close()
+		if err != nil {
+			logging.WarningLogger.Printf("Clip for port %d interrupted: %v", port, err)
+			return
+		}
+	}
+}
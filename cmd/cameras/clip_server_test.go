@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	camerascfg "github.com/owlcms/replays/internal/config/cameras"
+)
+
+func TestServeClipConcatenatesCoveringSegments(t *testing.T) {
+	local := camerascfg.LocalRecording{Enabled: true, Minutes: 3, SegmentSeconds: 60, Dir: t.TempDir()}
+	base := time.Now().Truncate(time.Second).Add(-10 * time.Minute)
+	writeSegment := func(name, content string, end time.Time) {
+		path := filepath.Join(local.Dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, end, end); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Wrapped buffer: file 000 is the newest.
+	writeSegment("port9001_001.ts", "A", base.Add(1*time.Minute))
+	writeSegment("port9001_002.ts", "B", base.Add(2*time.Minute))
+	writeSegment("port9001_000.ts", "C", base.Add(3*time.Minute))
+	writeSegment("port9002_000.ts", "other", base.Add(3*time.Minute))
+
+	from := base.Add(90 * time.Second)
+	to := base.Add(150 * time.Second)
+	request := httptest.NewRequest(http.MethodGet, "/clip?port=9001&from="+strconv.FormatInt(from.UnixMilli(), 10)+"&to="+strconv.FormatInt(to.UnixMilli(), 10), nil)
+	recorder := httptest.NewRecorder()
+	serveClip(local, recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", recorder.Code, recorder.Body.String())
+	}
+	if got := recorder.Body.String(); got != "BC" {
+		t.Fatalf("body = %q, want segments B then C", got)
+	}
+	if got, want := recorder.Header().Get("X-Clip-Start"), strconv.FormatInt(base.Add(1*time.Minute).UnixMilli(), 10); got != want {
+		t.Fatalf("X-Clip-Start = %s, want %s (end of segment A)", got, want)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/clip?port=9001&from=1000&to=2000", nil)
+	recorder = httptest.NewRecorder()
+	serveClip(local, recorder, request)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("status for an uncovered window = %d, want 404", recorder.Code)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+	ffmpegcfg "github.com/owlcms/replays/internal/config/ffmpeg"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// logTailBytes bounds how much of cameras.log is read for a log request.
+const logTailBytes = 512 * 1024
+
+// bitrateOverrides holds the target bitrates set from replays, by output
+// port. They last until the cameras program exits.
+var (
+	bitrateMu        sync.Mutex
+	bitrateOverrides = make(map[int]string)
+)
+
+func bitrateOverride(port int) string {
+	bitrateMu.Lock()
+	defer bitrateMu.Unlock()
+	return bitrateOverrides[port]
+}
+
+func setBitrateOverride(port int, bitrate string) {
+	bitrateMu.Lock()
+	defer bitrateMu.Unlock()
+	if bitrate == "" {
+		delete(bitrateOverrides, port)
+		return
+	}
+	bitrateOverrides[port] = bitrate
+}
+
+// bitrateArgs forces a target bitrate; ffmpeg keeps the last value of an
+// option, so they replace those of the encoder settings.
+func bitrateArgs(bitrate string) []string {
+	if bitrate == "" {
+		return nil
+	}
+	return []string{"-b:v", bitrate, "-maxrate", bitrate, "-bufsize", bitrate}
+}
+
+// isPassthroughPixFmt reports whether a source is streamed without encoding.
+func isPassthroughPixFmt(pixFmt string) bool {
+	switch strings.ToLower(pixFmt) {
+	case "h264", "hevc", "h265":
+		return true
+	}
+	return false
+}
+
+// nodeControl answers the control requests of replays with the monitoring
+// actions of the user interface, which runUI provides.
+type nodeControl struct {
+	sources func() []sourceSpec
+	stream  func(key string) *cameraStream
+	toggle  func(spec sourceSpec, on bool) error
+	restart func(spec sourceSpec) error
+	// reload detects the encoders again and restarts the streams after the
+	// encoder settings changed.
+	reload func()
+}
+
+func (n *nodeControl) Streams() []control.StreamStatus {
+	var list []control.StreamStatus
+	for _, spec := range n.sources() {
+		status := control.StreamStatus{
+			Port:    spec.OutputPort,
+			Name:    spec.Name,
+			ShortID: spec.ShortID,
+			Bitrate: bitrateOverride(spec.OutputPort),
+		}
+		if stream := n.stream(spec.Key); stream != nil {
+			stream.mu.RLock()
+			status.Running = stream.running && !stream.stopping
+			status.Status = stream.status
+			stream.mu.RUnlock()
+		}
+		list = append(list, status)
+	}
+	return list
+}
+
+func (n *nodeControl) find(port int) (sourceSpec, error) {
+	for _, spec := range n.sources() {
+		if spec.OutputPort == port {
+			return spec, nil
+		}
+	}
+	return sourceSpec{}, fmt.Errorf("no source streams on port %d", port)
+}
+
+func (n *nodeControl) Start(port int) error {
+	spec, err := n.find(port)
+	if err != nil {
+		return err
+	}
+	return n.toggle(spec, true)
+}
+
+func (n *nodeControl) Stop(port int) error {
+	spec, err := n.find(port)
+	if err != nil {
+		return err
+	}
+	return n.toggle(spec, false)
+}
+
+func (n *nodeControl) Restart(port int) error {
+	spec, err := n.find(port)
+	if err != nil {
+		return err
+	}
+	return n.restart(spec)
+}
+
+func (n *nodeControl) SetBitrate(port int, bitrate string) error {
+	spec, err := n.find(port)
+	if err != nil {
+		return err
+	}
+	if isPassthroughPixFmt(spec.Camera.PixFmt) {
+		return fmt.Errorf("%s sends %s as is; its bitrate is set on the camera", spec.Name, spec.Camera.PixFmt)
+	}
+	setBitrateOverride(port, bitrate)
+	logging.InfoLogger.Printf("Bitrate of %s set to %q from replays", spec.Name, bitrate)
+	return n.restart(spec)
+}
+
+func (n *nodeControl) Log(lines int) (string, error) {
+	file, err := os.Open(filepath.Join(config.GetLogDir(), "cameras.log"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() > logTailBytes {
+		if _, err := file.Seek(-logTailBytes, io.SeekEnd); err != nil {
+			return "", err
+		}
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return control.TailLines(string(data), lines), nil
+}
+
+func (n *nodeControl) ConfigHash() string {
+	content, err := ffmpegcfg.SharedConfigContent()
+	if err != nil {
+		return ""
+	}
+	return control.ConfigHash(content)
+}
+
+func (n *nodeControl) ApplyConfig(content []byte) error {
+	if control.ConfigHash(content) == n.ConfigHash() {
+		return nil
+	}
+	cfg, err := ffmpegcfg.WriteSharedConfig(content)
+	if err != nil {
+		return err
+	}
+	logging.InfoLogger.Printf("Encoder settings replaced by those of replays in %s", ffmpegcfg.ResolveConfigPath())
+	ffmpegConfig = cfg
+	n.reload()
+	return nil
+}
+
+// startControlClient connects this node to the replays machine named in the
+// [control] section, if any.
+func startControlClient(n *nodeControl) {
+	settings := camerasConfig.Control
+	if strings.TrimSpace(settings.Replays) == "" {
+		return
+	}
+	name := strings.TrimSpace(settings.NodeName)
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	if name == "" {
+		name = "cameras"
+	}
+	logging.InfoLogger.Printf("Remote control by replays at %s enabled as node %s", settings.Replays, name)
+	go control.RunNode(settings.Replays, settings.Token, name, config.GetProgramVersion(), n)
+}
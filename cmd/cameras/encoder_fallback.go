@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/recording"
+)
+
+var (
+	softwareMu sync.Mutex
+	// softwareSources are the devices streamed with the software encoder
+	// since their hardware encoder failed, until cameras is restarted.
+	softwareSources = map[string]bool{}
+)
+
+// streamEncodes reports whether a stream is encoded rather than copied.
+func streamEncodes(stream *cameraStream) bool {
+	switch strings.ToLower(strings.TrimSpace(stream.camera.PixFmt)) {
+	case "h264", "hevc", "h265":
+		return false
+	}
+	return true
+}
+
+// applySoftwareFallback streams a source with the software encoder when its
+// hardware encoder failed before.
+func applySoftwareFallback(stream *cameraStream) {
+	softwareMu.Lock()
+	defer softwareMu.Unlock()
+	if softwareSources[stream.camera.Device] {
+		stream.encoder = nil
+	}
+}
+
+// noteEncoderFailure makes the later starts of a source whose hardware
+// encoder failed with message use the software encoder of ffmpeg.toml. It
+// returns false when the stream is copied, already encoded in software, or
+// failed for another reason.
+func noteEncoderFailure(stream *cameraStream, message string) bool {
+	if stream.encoder == nil || !streamEncodes(stream) || !recording.IsEncoderFailure(message, stream.encoder.Name) {
+		return false
+	}
+	softwareMu.Lock()
+	softwareSources[stream.camera.Device] = true
+	softwareMu.Unlock()
+	logging.ErrorLogger.Printf("%s [%s]: hardware encoder %s failed (%s), streaming with the software encoder until cameras is restarted", stream.camera.Name, stream.shortID, stream.encoder.Name, message)
+	fmt.Printf("  WARNING: %s failed, using the software encoder\n", stream.encoder.Name)
+	return true
+}
+
+// fallBackToSoftware switches a stream that failed to start with message to
+// the software encoder when its hardware encoder is the cause.
+func fallBackToSoftware(stream *cameraStream, message string) bool {
+	if !noteEncoderFailure(stream, message) {
+		return false
+	}
+	stream.encoder = nil
+	return true
+}
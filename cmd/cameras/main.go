@@ -35,6 +35,7 @@ import (
 	"github.com/owlcms/replays/internal/config"
 	camerascfg "github.com/owlcms/replays/internal/config/cameras"
 	ffmpegcfg "github.com/owlcms/replays/internal/config/ffmpeg"
+	"github.com/owlcms/replays/internal/depcheck"
 	"github.com/owlcms/replays/internal/jobutil"
 	"github.com/owlcms/replays/internal/logging"
 	"github.com/owlcms/replays/internal/recording"
@@ -296,6 +297,7 @@ type cameraStream struct {
 	summary     string
 	sourceType  string
 	transport   string
+	screen      camerascfg.ScreenSource
 	commandLine string
 
 	mu                 sync.RWMutex
@@ -377,6 +379,7 @@ func main() {
 	flag.IntVar(&startPort, "startport", 0, "Starting port for multicast allocation (overrides cameras.toml)")
 	flag.BoolVar(&extractConfig, "extractConfig", false, "extract default editable config files to configDir/install dir and exit")
 	flag.StringVar(&config.ConfigDir, "configDir", "", "directory containing editable camera config files")
+	flag.BoolVar(&config.Portable, "portable", false, "keep config and logs in the executable's folder (e.g. on a removable drive)")
 	flag.Parse()
 
 	if config.ConfigDir != "" {
@@ -403,10 +406,11 @@ func main() {
 	if err := jobutil.Init(); err != nil {
 		fmt.Printf("Warning: Failed to create job object: %v\n", err)
 	}
+	jobutil.SetInventoryDir(config.GetProcessInventoryDir())
 
-	// Initialize logging to the instance/version logs folder (next to executable)
-	logDir := config.GetRuntimeDir()
-	logDir = filepath.Join(logDir, "logs")
+	// Initialize logging to the logs folder next to the executable (user data
+	// folder for packaged installs)
+	logDir := config.GetLogDir()
 	if err := logging.InitWithFile(logDir, "cameras.log"); err != nil {
 		fmt.Printf("Warning: Failed to initialize logging: %v\n", err)
 	} else {
@@ -452,6 +456,9 @@ func main() {
 	if startPort > 0 {
 		camerasConfig.Multicast.StartPort = startPort
 	}
+	if camerasConfig.LocalRecording.Enabled {
+		startClipServer(camerasConfig.LocalRecording)
+	}
 
 	// Initialize ffmpeg path
 	if err := recording.InitializeFFmpeg(); err != nil {
@@ -522,6 +529,7 @@ func startAllStreams(sources []sourceSpec, encoder *recording.HwEncoder, callbac
 			summary:     source.Summary,
 			sourceType:  source.SourceType,
 			transport:   source.Transport,
+			screen:      source.Screen,
 		}
 
 		fmt.Printf("\n[%s] %s (%s, %s @ %d fps)\n", cam.PixFmt, cam.Name, cam.Size, cam.PixFmt, cam.Fps)
@@ -534,7 +542,7 @@ func startAllStreams(sources []sourceSpec, encoder *recording.HwEncoder, callbac
 		}
 
 		callbacks.report(recording.ProgressMsg(recording.ProgStreamPrep, cam.Name))
-		cmd, err := startStream(stream, callbacks)
+		cmd, err := startStreamWithFallback(stream, callbacks)
 		if err != nil {
 			fmt.Printf("  ERROR: Failed to start stream: %v\n", err)
 			stream.setStopped(fmt.Sprintf("failed: %v", err))
@@ -768,6 +776,23 @@ func isIntegratedCamera(cam recording.DetectedCamera) bool {
 	}
 }
 
+// pickStreamEncoder returns the hardware encoder of the streams this node
+// encodes, for the codec of its config.toml. HEVC falls back to H.264 on a
+// machine without a working HEVC encoder.
+func pickStreamEncoder(encoders []recording.HwEncoder) *recording.HwEncoder {
+	codec := ffmpegcfg.Codecs[0]
+	if camerasConfig != nil && camerasConfig.Cameras.Codec != "" {
+		codec = camerasConfig.Cameras.Codec
+	}
+	if enc := recording.PickEncoderForCodec(encoders, codec); enc != nil {
+		return enc
+	}
+	if codec != ffmpegcfg.Codecs[0] {
+		logging.WarningLogger.Printf("No %s hardware encoder works on this machine, streams are encoded to H.264", codec)
+	}
+	return recording.PickBestEncoder(encoders)
+}
+
 func describeEncodingPlan(cam recording.DetectedCamera, encoder *recording.HwEncoder, fc *ffmpegcfg.Config) string {
 	pixFmt := strings.ToLower(strings.TrimSpace(cam.PixFmt))
 	switch pixFmt {
@@ -777,7 +802,7 @@ func describeEncodingPlan(cam recording.DetectedCamera, encoder *recording.HwEnc
 		return "copy input hevc"
 	case "mjpeg":
 		if encoder != nil {
-			return fmt.Sprintf("encode mjpeg -> h264 via %s (%s)", encoder.Name, encoder.Description)
+			return fmt.Sprintf("encode mjpeg -> %s via %s (%s)", encoder.Codec(), encoder.Name, encoder.Description)
 		}
 		if fc != nil && strings.TrimSpace(fc.Software.OutputParameters) != "" {
 			return fmt.Sprintf("encode mjpeg -> h264 via software (%s)", strings.TrimSpace(fc.Software.OutputParameters))
@@ -785,7 +810,7 @@ func describeEncodingPlan(cam recording.DetectedCamera, encoder *recording.HwEnc
 		return "encode mjpeg -> h264 via software"
 	default:
 		if encoder != nil {
-			return fmt.Sprintf("encode raw %s -> h264 via %s (%s)", cam.PixFmt, encoder.Name, encoder.Description)
+			return fmt.Sprintf("encode raw %s -> %s via %s (%s)", cam.PixFmt, encoder.Codec(), encoder.Name, encoder.Description)
 		}
 		if fc != nil && strings.TrimSpace(fc.Software.OutputParameters) != "" {
 			return fmt.Sprintf("encode raw %s -> h264 via software (%s)", cam.PixFmt, strings.TrimSpace(fc.Software.OutputParameters))
@@ -883,6 +908,9 @@ func buildStreamCommandSpec(stream *cameraStream, mode streamOutputMode) (stream
 	}
 
 	var args []string
+	// inputFilter is applied before the encoder's own filter, e.g. to crop a
+	// screen capture.
+	var inputFilter string
 
 	args = append(args, "-use_wallclock_as_timestamps", "1")
 
@@ -935,6 +963,17 @@ func buildStreamCommandSpec(stream *cameraStream, mode streamOutputMode) (stream
 			args = append(args, "-rtsp_transport", sourceTransport)
 		}
 		args = append(args, "-i", cam.Device)
+
+	case "libndi_newtek":
+		args = append(args, "-f", "libndi_newtek", "-i", cam.Device)
+
+	case "screen":
+		screenArgs, filter, err := screenInputArgs(stream.screen, runtime.GOOS)
+		if err != nil {
+			return streamCommandSpec{}, err
+		}
+		args = append(args, screenArgs...)
+		inputFilter = filter
 	}
 
 	gopFPS := cam.Fps
@@ -963,17 +1002,23 @@ func buildStreamCommandSpec(stream *cameraStream, mode streamOutputMode) (stream
 		} else {
 			args = append(args, strings.Fields(fc.Software.OutputParameters)...)
 		}
+		args = append(args, bitrateArgs(bitrateOverride(port))...)
 		args = append(args, "-g", fmt.Sprintf("%d", gopSize))
 		args = append(args, "-keyint_min", fmt.Sprintf("%d", gopSize))
 	default:
+		encoderFilter := ""
+		if encoder != nil {
+			encoderFilter = encoder.VideoFilter
+		}
+		if filter := joinVideoFilters(inputFilter, encoderFilter); filter != "" {
+			args = append(args, "-vf", filter)
+		}
 		if encoder != nil {
-			if strings.TrimSpace(encoder.VideoFilter) != "" {
-				args = append(args, "-vf", strings.TrimSpace(encoder.VideoFilter))
-			}
 			args = append(args, strings.Fields(encoder.OutputParameters)...)
 		} else {
 			args = append(args, strings.Fields(fc.Software.OutputParameters)...)
 		}
+		args = append(args, bitrateArgs(bitrateOverride(port))...)
 		args = append(args, "-g", fmt.Sprintf("%d", gopSize))
 		args = append(args, "-keyint_min", fmt.Sprintf("%d", gopSize))
 	}
@@ -986,10 +1031,22 @@ func buildStreamCommandSpec(stream *cameraStream, mode streamOutputMode) (stream
 		}
 		args = append(args, "-frames:v", "1", "-nostats", "-f", "null", "-")
 	case streamOutputLive:
-		if unicastMode {
-			if strings.TrimSpace(udpDest) == "" {
+		localRecording := camCfg.LocalRecording.Enabled
+		srt := camCfg.SRT.Enabled
+		if unicastMode || localRecording || srt {
+			if unicastMode && strings.TrimSpace(udpDest) == "" {
 				return streamCommandSpec{}, fmt.Errorf("no enabled unicast destinations")
 			}
+			teeOutput := udpDest
+			if !unicastMode {
+				teeOutput = "[f=mpegts]" + udpDest
+			}
+			if localRecording {
+				teeOutput += "|" + camCfg.LocalRecording.TeeLeg(port)
+			}
+			if srt {
+				teeOutput += "|" + camCfg.SRT.TeeLeg(port)
+			}
 			extra := fc.Output.ExtraFlags
 			extra = strings.ReplaceAll(extra, "-f mpegts", "")
 			extra = strings.TrimSpace(extra)
@@ -998,7 +1055,7 @@ func buildStreamCommandSpec(stream *cameraStream, mode streamOutputMode) (stream
 			}
 			args = append(args, "-map", "0:v")
 			args = append(args, "-nostats", "-progress", "pipe:1")
-			args = append(args, "-f", "tee", udpDest)
+			args = append(args, "-f", "tee", teeOutput)
 		} else {
 			args = append(args, strings.Fields(fc.Output.ExtraFlags)...)
 			args = append(args, "-nostats", "-progress", "pipe:1")
@@ -1064,7 +1121,7 @@ func formatProbeDiagnostics(output string) string {
 
 func runStartupProbeCommand(ffmpegPath string, args []string, logLevel string, timeout time.Duration) (string, error) {
 	probeArgs := append([]string{"-hide_banner", "-loglevel", logLevel}, args...)
-	cmd := recording.CreateHiddenCmd(ffmpegPath, probeArgs...)
+	cmd := jobutil.Command(ffmpegPath, probeArgs...)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -1144,9 +1201,60 @@ func runStartupProbe(stream *cameraStream, callbacks *streamStartupCallbacks) er
 	return fmt.Errorf("stream validation failed: %s", reason)
 }
 
+// maxModeFallbacks bounds how many other detected modes are tried when ffmpeg
+// cannot open a camera with the selected one.
+const maxModeFallbacks = 3
+
+// startStreamWithFallback starts a stream and, when ffmpeg refuses the
+// selected camera mode (typically after a driver or OS update changed the
+// formats offered), retries with the next-best detected modes.
+func startStreamWithFallback(stream *cameraStream, callbacks *streamStartupCallbacks) (*exec.Cmd, error) {
+	cmd, err := startStream(stream, callbacks)
+	tried := make(map[string]bool)
+	for attempt := 0; err != nil && attempt < maxModeFallbacks; attempt++ {
+		// A busy device or a failing encoder or network is not fixed by
+		// another mode.
+		var busyErr *recording.DeviceBusyError
+		if errors.As(err, &busyErr) || !recording.IsModeFailure(err.Error()) {
+			break
+		}
+		failed := stream.camera.Mode()
+		tried[failed] = true
+		if !stream.camera.FallbackMode(ffmpegConfig, tried) {
+			break
+		}
+		next := stream.camera.Mode()
+		logging.WarningLogger.Printf("Camera %s [%s] failed with %s (%v); retrying with %s", stream.camera.Name, stream.shortID, failed, err, next)
+		fmt.Printf("  WARNING: %s failed, retrying with %s\n", failed, next)
+		callbacks.report(recording.ProgressMsg(recording.ProgValidateFailed, recording.ProgressDetailPayload(stream.camera.Name, fmt.Sprintf("%s failed, trying %s", failed, next))))
+		cmd, err = startStream(stream, callbacks)
+	}
+	return cmd, err
+}
+
 // startStream starts ffmpeg to stream a camera to multicast UDP
 func startStream(stream *cameraStream, callbacks *streamStartupCallbacks) (*exec.Cmd, error) {
-	if err := runStartupProbe(stream, callbacks); err != nil {
+	// Pre-flight: a device held by another program makes ffmpeg fail with
+	// an unhelpful I/O error, so name the culprit instead.
+	var busyErr *recording.DeviceBusyError
+	if err := recording.CheckCameraDevice(stream.camera.Format, stream.camera.Device); errors.As(err, &busyErr) {
+		if busyErr.Confirmed {
+			logging.ErrorLogger.Printf("%s [%s] will not be streamed: %v", stream.camera.Name, stream.shortID, busyErr)
+			return nil, busyErr
+		}
+		logging.WarningLogger.Printf("%s [%s]: %v", stream.camera.Name, stream.shortID, busyErr)
+	}
+
+	applySoftwareFallback(stream)
+	err := runStartupProbe(stream, callbacks)
+	if err != nil && fallBackToSoftware(stream, err.Error()) {
+		callbacks.report(recording.ProgressMsg(recording.ProgValidateFailed, recording.ProgressDetailPayload(stream.camera.Name, "hardware encoder failed, trying the software encoder")))
+		err = runStartupProbe(stream, callbacks)
+	}
+	if err != nil {
+		if busyErr != nil {
+			return nil, fmt.Errorf("%v, %w", err, busyErr)
+		}
 		return nil, err
 	}
 	callbacks.report(recording.ProgressMsg(recording.ProgStreamStart, stream.camera.Name))
@@ -1155,10 +1263,16 @@ func startStream(stream *cameraStream, callbacks *streamStartupCallbacks) (*exec
 	if err != nil {
 		return nil, err
 	}
+	if camerasConfig.LocalRecording.Enabled {
+		// The segment muxer does not create directories.
+		if err := os.MkdirAll(camerasConfig.LocalRecording.Dir, 0755); err != nil {
+			return nil, fmt.Errorf("cannot create local recording directory: %w", err)
+		}
+	}
 	stream.udpDest = spec.udpDest
 	stream.commandLine = formatCommandLine(spec.ffmpegPath, spec.args)
 
-	cmd := recording.CreateHiddenCmd(spec.ffmpegPath, spec.args...)
+	cmd := jobutil.Command(spec.ffmpegPath, spec.args...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -1188,17 +1302,11 @@ func startStream(stream *cameraStream, callbacks *streamStartupCallbacks) (*exec
 	)
 	logging.InfoLogger.Printf("Starting ffmpeg: %s", stream.commandLine)
 
-	if err := cmd.Start(); err != nil {
+	if err := jobutil.Start(cmd, "cameras: stream "+stream.camera.Name); err != nil {
 		logging.ErrorLogger.Printf("Failed to start ffmpeg for %s [%s]: %v | command=%s", stream.camera.Name, stream.shortID, err, stream.commandLine)
 		return nil, err
 	}
-	if cmd.Process != nil {
-		logging.InfoLogger.Printf("ffmpeg started for %s (%s) with pid=%d", stream.camera.Name, stream.udpDest, cmd.Process.Pid)
-	}
-
-	if err := jobutil.Assign(cmd); err != nil {
-		logging.ErrorLogger.Printf("Failed to assign ffmpeg to job object: %v", err)
-	}
+	logging.InfoLogger.Printf("ffmpeg started for %s (%s) with pid=%d", stream.camera.Name, stream.udpDest, cmd.Process.Pid)
 
 	go monitorFFmpegProgress(stream, stdout)
 	go monitorFFmpegErrors(stream, stderr)
@@ -1224,6 +1332,9 @@ func startStream(stream *cameraStream, callbacks *streamStartupCallbacks) (*exec
 				} else {
 					logging.ErrorLogger.Printf("ffmpeg exited for %s (%s): %v", stream.camera.Name, stream.udpDest, err)
 				}
+				// The next start of the source no longer uses a hardware
+				// encoder that failed.
+				noteEncoderFailure(stream, lastErr)
 				if stream.shouldRunActivationDiagnostic() {
 					go runActivationDiagnosticRetry(stream)
 				}
@@ -1401,7 +1512,7 @@ func runActivationDiagnosticRetry(stream *cameraStream) {
 	commandLine := formatCommandLine(spec.ffmpegPath, diagnosticArgs)
 	logging.ErrorLogger.Printf("Activation diagnostic retry for %s [%s]: %s", stream.camera.Name, stream.shortID, commandLine)
 
-	cmd := recording.CreateHiddenCmd(spec.ffmpegPath, diagnosticArgs...)
+	cmd := jobutil.Command(spec.ffmpegPath, diagnosticArgs...)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -1994,6 +2105,9 @@ func resolveFFplayPath() string {
 		if runtime.GOOS == "windows" {
 			ffplayName = "ffplay.exe"
 		}
+		if bundledPath := config.FindBundledFFmpegExecutable(ffplayName); bundledPath != "" {
+			return bundledPath
+		}
 		if sharedPath := config.FindSharedFFmpegExecutable(ffplayName); sharedPath != "" {
 			return sharedPath
 		}
@@ -2010,6 +2124,9 @@ func resolveFFplayPath() string {
 		return candidate
 	}
 
+	if bundledPath := config.FindBundledFFmpegExecutable(ffplayName); bundledPath != "" {
+		return bundledPath
+	}
 	if sharedPath := config.FindSharedFFmpegExecutable(ffplayName); sharedPath != "" {
 		return sharedPath
 	}
@@ -2090,17 +2207,13 @@ func launchPreview(stream *cameraStream, onDone func()) error {
 
 	ffplayPath := resolveFFplayPath()
 	logging.InfoLogger.Printf("Preview launch requested for %s [%s]: ffplay=%s listenURL=%s size=%s port=%d", stream.camera.Name, stream.shortID, ffplayPath, listenURL, stream.camera.Size, stream.port)
-	cmd := recording.CreateHiddenCmd(ffplayPath, args...)
-	if err := cmd.Start(); err != nil {
+	cmd := jobutil.Command(ffplayPath, args...)
+	if err := jobutil.Start(cmd, "cameras: preview "+stream.camera.Name); err != nil {
 		logging.ErrorLogger.Printf("Preview start failed for %s [%s] after %s: %v", stream.camera.Name, stream.shortID, time.Since(startTime), err)
 		return err
 	}
 	logging.InfoLogger.Printf("Preview process started for %s [%s] after %s with pid=%d", stream.camera.Name, stream.shortID, time.Since(startTime), cmd.Process.Pid)
 
-	if err := jobutil.Assign(cmd); err != nil {
-		logging.ErrorLogger.Printf("Failed to assign ffplay to job object: %v", err)
-	}
-
 	registerPreviewCmd(cmd)
 	go func() {
 		err := cmd.Wait()
@@ -2186,7 +2299,7 @@ func recordClip(stream *cameraStream) (string, error) {
 		outputPath,
 	}
 
-	cmd := recording.CreateHiddenCmd(config.GetFFmpegPath(), args...)
+	cmd := jobutil.Command(config.GetFFmpegPath(), args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
@@ -2265,6 +2378,16 @@ func (e *portTableEntry) FocusLost() {
 	}
 }
 
+// checkDependencies looks for missing capture tools, camera permissions and
+// firewall rules (for the clip server) and offers to fix them.
+func checkDependencies(window fyne.Window) {
+	opts := depcheck.Options{Cameras: true, FFmpegPath: config.GetFFmpegPath()}
+	if camerasConfig != nil && camerasConfig.LocalRecording.Enabled {
+		opts.HTTPPort = camerasConfig.LocalRecording.HTTPPort
+	}
+	depcheck.ShowIssues(window, depcheck.Run(opts))
+}
+
 func runUI() {
 	myApp := app.New()
 	myApp.Settings().SetTheme(appTheme{theme.DefaultTheme()})
@@ -2831,6 +2954,11 @@ func runUI() {
 				return &currentInventory.RTSP[i], true
 			}
 		}
+		for i := range currentInventory.Screen {
+			if currentInventory.Screen[i].Key == key {
+				return &currentInventory.Screen[i], true
+			}
+		}
 		return nil, false
 	}
 
@@ -2896,10 +3024,11 @@ func runUI() {
 			summary:     spec.Summary,
 			sourceType:  spec.SourceType,
 			transport:   spec.Transport,
+			screen:      spec.Screen,
 		}
 
 		callbacks := &streamStartupCallbacks{action: actionStatus.SetText}
-		cmd, err := startStream(stream, callbacks)
+		cmd, err := startStreamWithFallback(stream, callbacks)
 		if err != nil {
 			stream.setStopped(fmt.Sprintf("failed: %v", err))
 			return warning, err
@@ -3074,6 +3203,13 @@ func runUI() {
 					break
 				}
 			}
+		case "screen":
+			for i := range camerasConfig.ScreenSources {
+				if camerasConfig.ScreenSources[i].SourceID == spec.Key {
+					camerasConfig.ScreenSources[i].On = boolRef(on)
+					break
+				}
+			}
 		default:
 			return fmt.Errorf("unsupported source type %s", spec.SourceType)
 		}
@@ -3289,7 +3425,7 @@ func runUI() {
 	}
 
 	renderMonitoringSourceToggles = func(inv sourceInventory) {
-		sources := make([]sourceSpec, 0, len(inv.USB)+len(inv.RTSP))
+		sources := make([]sourceSpec, 0, len(inv.USB)+len(inv.RTSP)+len(inv.Screen))
 		for _, src := range inv.USB {
 			if src.Enabled {
 				sources = append(sources, src)
@@ -3300,6 +3436,11 @@ func runUI() {
 				sources = append(sources, src)
 			}
 		}
+		for _, src := range inv.Screen {
+			if src.Enabled {
+				sources = append(sources, src)
+			}
+		}
 		monitoringSources = sortedSpecsByPort(sources)
 		table.Refresh()
 	}
@@ -3498,6 +3639,11 @@ func runUI() {
 				return fmt.Errorf("duplicate output port %d for %s and %s", port, name, src.Name)
 			}
 		}
+		for _, src := range currentInventory.Screen {
+			if src.OutputPort == port {
+				return fmt.Errorf("duplicate output port %d for %s and %s", port, name, src.Name)
+			}
+		}
 		return nil
 	}
 
@@ -4355,10 +4501,15 @@ func runUI() {
 		restartStreams()
 	})
 	restartFromConfigBtn.Importance = widget.HighImportance
+	// Lists the devices the system sees, with the permission, busy device
+	// and driver problems found on this machine.
+	listCamerasBtn := widget.NewButton("List Cameras", func() {
+		go recording.ListCameras(window)
+	})
 	configurationTab := container.NewScroll(
 		container.NewPadded(container.NewVBox(
 			newVerticalGap(4),
-			container.NewHBox(rescanBtn, restartFromConfigBtn),
+			container.NewHBox(rescanBtn, restartFromConfigBtn, listCamerasBtn),
 			newVerticalGap(8),
 			topConfigSections,
 			newVerticalGap(12),
@@ -4378,5 +4529,17 @@ func runUI() {
 	window.SetContent(content)
 	window.Show()
 	startInitialDetection()
+	go checkDependencies(window)
+	startControlClient(&nodeControl{
+		sources: func() []sourceSpec { return monitoringSources },
+		stream:  findStreamForSource,
+		toggle:  toggleSingleSource,
+		restart: restartSource,
+		reload: func() {
+			currentEncoder = pickStreamEncoder(recording.DetectEncodersWithConfigAndProgress(ffmpegConfig, nil))
+			updateEncoderStatus()
+			restartWithInventory(buildCachedSourceInventory(currentInventory, currentEncoder), "Encoder settings updated from replays")
+		},
+	})
 	myApp.Run()
 }
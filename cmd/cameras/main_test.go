@@ -870,7 +870,7 @@ func TestBuildUSBSourcesWithProgressSkipsDisabledAssignmentsAndKeepsConfigRows(t
 		t.Fatalf("enabled source state = enabled:%t detected:%t, want both true", enabled.Enabled, enabled.Detected)
 	}
 
-	inv := assembleSourceInventory(sources, nil, nil)
+	inv := assembleSourceInventory(sources, nil, nil, nil)
 	if len(inv.Active) != 1 || inv.Active[0].Key != "usb-enabled" {
 		t.Fatalf("active inventory = %+v, want only enabled detected source", inv.Active)
 	}
@@ -1003,6 +1003,16 @@ func TestBuildStreamCommandSpecUsesNVENCFallbackOnlyWhenEncoding(t *testing.T) {
 	if !strings.Contains(strings.Join(encodedSpec.args, " "), "-vf format=yuv420p") {
 		t.Fatalf("encoded stream args = %q, want encoder video filter from ffmpeg.toml", strings.Join(encodedSpec.args, " "))
 	}
+
+	setBitrateOverride(9006, "2500k")
+	defer setBitrateOverride(9006, "")
+	encodedSpec, err = buildStreamCommandSpec(encodedStream, streamOutputLive)
+	if err != nil {
+		t.Fatalf("buildStreamCommandSpec(bitrate) error = %v", err)
+	}
+	if !strings.Contains(strings.Join(encodedSpec.args, " "), "-c:v h264_nvenc -b:v 2500k -maxrate 2500k -bufsize 2500k") {
+		t.Fatalf("encoded stream args = %q, want the bitrate set from replays after the encoder settings", strings.Join(encodedSpec.args, " "))
+	}
 }
 
 func TestStreamNeedsStartupProbeIncludesCopyStreams(t *testing.T) {
@@ -1081,6 +1091,90 @@ func TestBuildStreamCommandSpecProbeNullCopiesToNullOutput(t *testing.T) {
 	}
 }
 
+func TestBuildStreamCommandSpecAddsLocalRecordingLeg(t *testing.T) {
+	previousCamerasConfig := camerasConfig
+	previousFFmpegConfig := ffmpegConfig
+	previousFFmpegPath := config.GetFFmpegPath()
+	defer func() {
+		camerasConfig = previousCamerasConfig
+		ffmpegConfig = previousFFmpegConfig
+		config.SetFFmpegPath(previousFFmpegPath)
+	}()
+
+	camerasConfig = &camerascfg.Config{
+		Multicast: camerascfg.MulticastConfig{IP: "239.255.0.1", StartPort: 9001},
+		LocalRecording: camerascfg.LocalRecording{
+			Enabled:        true,
+			Minutes:        5,
+			SegmentSeconds: 60,
+			Dir:            "/var/lib/replays/buffer",
+		},
+	}
+	ffmpegConfig = &ffmpegcfg.Config{
+		Software: ffmpegcfg.SoftwareEncoder{OutputParameters: "-c:v libx264"},
+		Output:   ffmpegcfg.OutputConfig{ExtraFlags: "-f mpegts"},
+	}
+	config.SetFFmpegPath("ffmpeg7")
+
+	stream := &cameraStream{
+		camera: recording.DetectedCamera{Format: "rtsp", PixFmt: "h264", Device: "rtsp://copy"},
+		port:   9005,
+	}
+
+	spec, err := buildStreamCommandSpec(stream, streamOutputLive)
+	if err != nil {
+		t.Fatalf("buildStreamCommandSpec(live) error = %v", err)
+	}
+	if spec.udpDest != "udp://239.255.0.1:9005?pkt_size=1316" {
+		t.Fatalf("udpDest = %q, want the multicast URL alone", spec.udpDest)
+	}
+	output := spec.args[len(spec.args)-1]
+	want := "[f=mpegts]udp://239.255.0.1:9005?pkt_size=1316|[f=segment:segment_format=mpegts:segment_time=60:segment_wrap=5:onfail=ignore]/var/lib/replays/buffer/port9005_%03d.ts"
+	if output != want {
+		t.Fatalf("tee output = %q, want %q", output, want)
+	}
+	if joined := strings.Join(spec.args, " "); !strings.Contains(joined, "-map 0:v") || !strings.Contains(joined, "-f tee") {
+		t.Fatalf("live args = %q, want mapped tee output", joined)
+	}
+}
+
+func TestBuildStreamCommandSpecServesStreamOverSRT(t *testing.T) {
+	previousCamerasConfig := camerasConfig
+	previousFFmpegConfig := ffmpegConfig
+	previousFFmpegPath := config.GetFFmpegPath()
+	defer func() {
+		camerasConfig = previousCamerasConfig
+		ffmpegConfig = previousFFmpegConfig
+		config.SetFFmpegPath(previousFFmpegPath)
+	}()
+
+	camerasConfig = &camerascfg.Config{
+		Multicast: camerascfg.MulticastConfig{IP: "239.255.0.1", StartPort: 9001},
+		SRT:       camerascfg.SRTConfig{Enabled: true, LatencyMs: 300, Passphrase: "platformA2025"},
+	}
+	ffmpegConfig = &ffmpegcfg.Config{
+		Software: ffmpegcfg.SoftwareEncoder{OutputParameters: "-c:v libx264"},
+		Output:   ffmpegcfg.OutputConfig{ExtraFlags: "-f mpegts"},
+	}
+	config.SetFFmpegPath("ffmpeg7")
+
+	stream := &cameraStream{
+		camera: recording.DetectedCamera{Format: "rtsp", PixFmt: "h264", Device: "rtsp://copy"},
+		port:   9005,
+	}
+	spec, err := buildStreamCommandSpec(stream, streamOutputLive)
+	if err != nil {
+		t.Fatalf("buildStreamCommandSpec(live) error = %v", err)
+	}
+	output := spec.args[len(spec.args)-1]
+	want := "[f=mpegts]udp://239.255.0.1:9005?pkt_size=1316|" +
+		`[f=mpegts:onfail=ignore:use_fifo=1:fifo_options=attempt_recovery=1\:recover_any_error=1\:recovery_wait_time=1\:drop_pkts_on_overflow=1]` +
+		"srt://0.0.0.0:10005?mode=listener&latency=300000&pkt_size=1316&passphrase=platformA2025"
+	if output != want {
+		t.Fatalf("tee output = %q, want %q", output, want)
+	}
+}
+
 func TestRunStartupProbeRetriesFailedGrabWithDebugLogging(t *testing.T) {
 	previousCamerasConfig := camerasConfig
 	previousFFmpegConfig := ffmpegConfig
@@ -1134,3 +1228,26 @@ func TestRunStartupProbeRetriesFailedGrabWithDebugLogging(t *testing.T) {
 		t.Fatalf("probe args = %q, want null output", argLists[0])
 	}
 }
+
+func TestFallBackToSoftwareOnlyForEncoderFailures(t *testing.T) {
+	defer func() { softwareSources = map[string]bool{} }()
+	nvenc := &recording.HwEncoder{Name: "h264_nvenc", OutputParameters: "-c:v h264_nvenc"}
+
+	copied := &cameraStream{camera: recording.DetectedCamera{PixFmt: "h264", Device: "/dev/video0"}, encoder: nvenc}
+	if fallBackToSoftware(copied, "[h264_nvenc @ 0x5581] Cannot load libnvidia-encode.so.1") {
+		t.Error("fell back for a copied stream")
+	}
+	encoded := &cameraStream{camera: recording.DetectedCamera{PixFmt: "mjpeg", Device: "/dev/video2"}, encoder: nvenc}
+	if fallBackToSoftware(encoded, "/dev/video2: Device or resource busy") {
+		t.Error("fell back for a camera failure")
+	}
+	if !fallBackToSoftware(encoded, "[h264_nvenc @ 0x5581] Cannot load libnvidia-encode.so.1") || encoded.encoder != nil {
+		t.Fatalf("the stream kept its failed hardware encoder: %+v", encoded.encoder)
+	}
+
+	restarted := &cameraStream{camera: recording.DetectedCamera{PixFmt: "mjpeg", Device: "/dev/video2"}, encoder: nvenc}
+	applySoftwareFallback(restarted)
+	if restarted.encoder != nil {
+		t.Error("a restarted stream went back to the failed hardware encoder")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	camerascfg "github.com/owlcms/replays/internal/config/cameras"
+	"github.com/owlcms/replays/internal/recording"
+)
+
+// screenPixFmt is what the screen grabbers deliver; it is always encoded.
+const screenPixFmt = "bgra"
+
+// buildScreenSources turns the configured [[screen]] entries into sources.
+// Screens are not detected: a configured screen is always available.
+func buildScreenSources(startPort int, usedPorts map[int]struct{}, usedShortIDs map[string]struct{}) []sourceSpec {
+	for _, src := range camerasConfig.ScreenSources {
+		if src.OutputPort > 0 {
+			usedPorts[src.OutputPort] = struct{}{}
+		}
+		if strings.TrimSpace(src.ShortID) != "" {
+			usedShortIDs[strings.ToUpper(strings.TrimSpace(src.ShortID))] = struct{}{}
+		}
+	}
+
+	sources := make([]sourceSpec, 0, len(camerasConfig.ScreenSources))
+	for i, src := range camerasConfig.ScreenSources {
+		shortID := strings.TrimSpace(src.ShortID)
+		if shortID == "" {
+			shortID = nextShortID("S", usedShortIDs)
+		}
+		port := src.OutputPort
+		if port <= 0 {
+			port = nextAvailablePort(startPort, usedPorts)
+		}
+		usedPorts[port] = struct{}{}
+
+		camera := screenSourceCamera(src)
+		if camera.Name == "" {
+			camera.Name = fmt.Sprintf("Screen %d", i+1)
+		}
+		sources = append(sources, sourceSpec{
+			Key:          src.SourceID,
+			SourceType:   "screen",
+			Name:         camera.Name,
+			ShortID:      shortID,
+			Summary:      camera.Identity,
+			Enabled:      src.Enabled,
+			Detected:     true,
+			MonitoringOn: src.On == nil || *src.On,
+			OutputPort:   port,
+			Camera:       camera,
+			Screen:       src,
+		})
+	}
+	return sources
+}
+
+func screenSourceCamera(src camerascfg.ScreenSource) recording.DetectedCamera {
+	size := "-"
+	if width, height, _, _, ok, err := src.ScreenRegion(); ok && err == nil {
+		size = fmt.Sprintf("%dx%d", width, height)
+	}
+	identity := "screen " + screenDisplay(src.Display, runtime.GOOS)
+	if region := strings.TrimSpace(src.Region); region != "" {
+		identity += " " + region
+	}
+	return recording.DetectedCamera{
+		Name:     strings.TrimSpace(src.Name),
+		Device:   strings.TrimSpace(src.Display),
+		Format:   "screen",
+		PixFmt:   screenPixFmt,
+		Size:     size,
+		Fps:      src.Fps,
+		MatchKey: src.SourceID,
+		Identity: strings.TrimSpace(identity),
+	}
+}
+
+// screenDisplay returns the display to grab, with the platform default when
+// none is configured.
+func screenDisplay(display, goos string) string {
+	display = strings.TrimSpace(display)
+	if display != "" {
+		return display
+	}
+	switch goos {
+	case "windows":
+		return "desktop"
+	case "darwin":
+		return "Capture screen 0"
+	case "linux":
+		return ":0.0"
+	}
+	return "main"
+}
+
+// screenInputArgs builds the ffmpeg input arguments for a screen source, and
+// the crop filter needed where the grabber cannot capture a region itself.
+func screenInputArgs(src camerascfg.ScreenSource, goos string) (args []string, filter string, err error) {
+	width, height, x, y, hasRegion, err := src.ScreenRegion()
+	if err != nil {
+		return nil, "", err
+	}
+	fps := src.Fps
+	if fps <= 0 {
+		fps = 10
+	}
+	display := screenDisplay(src.Display, goos)
+	framerate := fmt.Sprintf("%d", fps)
+
+	switch goos {
+	case "windows":
+		args = []string{"-f", "gdigrab", "-framerate", framerate, "-draw_mouse", "0"}
+		if hasRegion {
+			args = append(args,
+				"-offset_x", fmt.Sprintf("%d", x),
+				"-offset_y", fmt.Sprintf("%d", y),
+				"-video_size", fmt.Sprintf("%dx%d", width, height))
+		}
+		args = append(args, "-i", display)
+	case "linux":
+		args = []string{"-f", "x11grab", "-framerate", framerate, "-draw_mouse", "0"}
+		if hasRegion {
+			args = append(args, "-video_size", fmt.Sprintf("%dx%d", width, height))
+			display = fmt.Sprintf("%s+%d,%d", display, x, y)
+		}
+		args = append(args, "-i", display)
+	case "darwin":
+		args = []string{"-f", "avfoundation", "-framerate", framerate, "-capture_cursor", "0", "-i", display + ":none"}
+		if hasRegion {
+			filter = fmt.Sprintf("crop=%d:%d:%d:%d", width, height, x, y)
+		}
+	default:
+		return nil, "", fmt.Errorf("screen capture is not supported on %s", goos)
+	}
+	return args, filter, nil
+}
+
+// joinVideoFilters chains two -vf filter graphs, either of which may be empty.
+func joinVideoFilters(first, second string) string {
+	first = strings.TrimSpace(first)
+	second = strings.TrimSpace(second)
+	switch {
+	case first == "":
+		return second
+	case second == "":
+		return first
+	}
+	return first + "," + second
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/owlcms/replays/internal/config"
 	camerascfg "github.com/owlcms/replays/internal/config/cameras"
+	"github.com/owlcms/replays/internal/jobutil"
 	"github.com/owlcms/replays/internal/logging"
 	"github.com/owlcms/replays/internal/recording"
 )
@@ -35,11 +36,13 @@ type sourceSpec struct {
 	PreferredFormat  string
 	Camera           recording.DetectedCamera
 	RTSP             camerascfg.RTSPSource
+	Screen           camerascfg.ScreenSource
 }
 
 type sourceInventory struct {
 	USB                 []sourceSpec
 	RTSP                []sourceSpec
+	Screen              []sourceSpec
 	Active              []sourceSpec
 	Status              string
 	Errors              []string
@@ -71,6 +74,7 @@ func buildSourceInventoryWithProgress(progress func(string)) sourceInventory {
 	}
 	rtspSpecs := buildRTSPSourcesWithProgress(startPort, usedPorts, usedShortIDs, progress)
 	logging.InfoLogger.Printf("Source inventory RTSP phase completed in %s with %d source(s)", time.Since(start), len(rtspSpecs))
+	screenSpecs := buildScreenSources(startPort, usedPorts, usedShortIDs)
 	usbStart := time.Now()
 	if progress != nil {
 		progress(recording.ProgressMsg(recording.ProgCheckingLocal, ""))
@@ -83,7 +87,7 @@ func buildSourceInventoryWithProgress(progress func(string)) sourceInventory {
 	}
 	encoders := recording.DetectEncodersWithConfigAndProgress(ffmpegConfig, progress)
 	logging.InfoLogger.Printf("Source inventory encoder phase completed in %s with %d candidate(s)", time.Since(encoderStart), len(encoders))
-	inv := assembleSourceInventory(usbSpecs, rtspSpecs, recording.PickBestEncoder(encoders))
+	inv := assembleSourceInventory(usbSpecs, rtspSpecs, screenSpecs, pickStreamEncoder(encoders))
 	logging.InfoLogger.Printf("Source inventory build completed in %s: usb=%d rtsp=%d active=%d errors=%d pending=%d",
 		time.Since(start), len(inv.USB), len(inv.RTSP), len(inv.Active), len(inv.Errors), len(inv.PendingVerification))
 	if progress != nil {
@@ -98,13 +102,14 @@ func buildCachedSourceInventory(previous sourceInventory, encoder *recording.HwE
 	usedShortIDs := make(map[string]struct{})
 
 	rtspSpecs := buildRTSPSources(startPort, usedPorts, usedShortIDs)
+	screenSpecs := buildScreenSources(startPort, usedPorts, usedShortIDs)
 	usbSpecs := buildUSBSourcesFromDetected(cachedDetectedCameras(previous.USB), startPort, usedPorts, usedShortIDs, nil)
-	return assembleSourceInventory(usbSpecs, rtspSpecs, encoder)
+	return assembleSourceInventory(usbSpecs, rtspSpecs, screenSpecs, encoder)
 }
 
-func assembleSourceInventory(usbSpecs, rtspSpecs []sourceSpec, encoder *recording.HwEncoder) sourceInventory {
+func assembleSourceInventory(usbSpecs, rtspSpecs, screenSpecs []sourceSpec, encoder *recording.HwEncoder) sourceInventory {
 
-	active := make([]sourceSpec, 0, len(rtspSpecs)+len(usbSpecs))
+	active := make([]sourceSpec, 0, len(rtspSpecs)+len(usbSpecs)+len(screenSpecs))
 	enabledCount := 0
 	for _, src := range usbSpecs {
 		if src.Enabled && src.Detected {
@@ -123,6 +128,15 @@ func assembleSourceInventory(usbSpecs, rtspSpecs []sourceSpec, encoder *recordin
 		}
 	}
 
+	for _, src := range screenSpecs {
+		if src.Enabled {
+			enabledCount++
+		}
+		if src.Enabled && src.MonitoringOn {
+			active = append(active, src)
+		}
+	}
+
 	sort.Slice(active, func(i, j int) bool {
 		if active[i].OutputPort != active[j].OutputPort {
 			return active[i].OutputPort < active[j].OutputPort
@@ -133,6 +147,7 @@ func assembleSourceInventory(usbSpecs, rtspSpecs []sourceSpec, encoder *recordin
 	inv := sourceInventory{
 		USB:                 usbSpecs,
 		RTSP:                rtspSpecs,
+		Screen:              screenSpecs,
 		Active:              active,
 		PendingVerification: collectPendingVerification(append(append([]sourceSpec{}, usbSpecs...), rtspSpecs...)),
 		Encoder:             encoder,
@@ -753,7 +768,7 @@ func probeRTSPSource(src camerascfg.RTSPSource) recording.DetectedCamera {
 		normalizedURL,
 	)
 
-	cmd := recording.CreateHiddenCmd(ffprobePath, args...)
+	cmd := jobutil.Command(ffprobePath, args...)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -819,6 +834,9 @@ func resolveFFprobePathForRTSP() string {
 	if runtime.GOOS == "windows" {
 		name = "ffprobe.exe"
 	}
+	if bundled := config.FindBundledFFmpegExecutable(name); bundled != "" {
+		return bundled
+	}
 	if shared := config.FindSharedFFmpegExecutable(name); shared != "" {
 		return shared
 	}
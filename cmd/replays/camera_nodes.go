@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// logFetchLines is how much of a node's log is shown.
+const logFetchLines = 300
+
+func formatStreamStatus(stream control.StreamStatus) string {
+	state := "stopped"
+	if stream.Running {
+		state = "running"
+	}
+	if stream.Status != "" && !strings.EqualFold(stream.Status, state) {
+		state = stream.Status
+	}
+	text := fmt.Sprintf("%s  port %d  %s", stream.Name, stream.Port, state)
+	if stream.ShortID != "" {
+		text = fmt.Sprintf("[%s] %s", stream.ShortID, text)
+	}
+	if stream.Bitrate != "" {
+		text += "  bitrate " + stream.Bitrate
+	}
+	return text
+}
+
+// showCameraNodes lists the camera nodes connected through the control
+// channel and lets the operator manage their streams.
+func showCameraNodes(window fyne.Window) {
+	body := container.NewVBox()
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	// run sends a request in the background and reports its outcome.
+	run := func(node string, req control.Message, done string) {
+		status.SetText(fmt.Sprintf("%s: %s...", node, req.Command))
+		go func() {
+			if _, err := control.Call(node, req); err != nil {
+				logging.ErrorLogger.Printf("Control request %s to %s failed: %v", req.Command, node, err)
+				status.SetText("Error: " + err.Error())
+				return
+			}
+			status.SetText(fmt.Sprintf("%s: %s", node, done))
+		}()
+	}
+
+	showLog := func(node string) {
+		status.SetText(fmt.Sprintf("%s: fetching log...", node))
+		go func() {
+			resp, err := control.Call(node, control.Message{Command: control.CommandLog, Lines: logFetchLines})
+			if err != nil {
+				status.SetText("Error: " + err.Error())
+				return
+			}
+			status.SetText("")
+			text := widget.NewMultiLineEntry()
+			text.TextStyle = fyne.TextStyle{Monospace: true}
+			text.SetText(resp.Log)
+			text.SetMinRowsVisible(20)
+			d := dialog.NewCustom(fmt.Sprintf("Log of %s", node), "Close", text, window)
+			d.Resize(fyne.NewSize(900, 560))
+			d.Show()
+		}()
+	}
+
+	pushSettings := func(node string) {
+		status.SetText(fmt.Sprintf("%s: sending encoder settings...", node))
+		go func() {
+			if err := control.PushConfig(node); err != nil {
+				logging.ErrorLogger.Printf("Sending the encoder settings to %s failed: %v", node, err)
+				status.SetText("Error: " + err.Error())
+				return
+			}
+			status.SetText(fmt.Sprintf("%s: encoder settings applied, streams restarted", node))
+		}()
+	}
+
+	rebuild := func() {
+		body.Objects = nil
+		nodes := control.Nodes()
+		localHash := control.LocalConfigHash()
+		if len(nodes) == 0 {
+			body.Add(widget.NewLabel("No camera node is connected. Set [control] replays in the cameras config.toml to this machine's address and web port."))
+		}
+		for _, n := range nodes {
+			node := n.Node
+			settings := "same encoder settings"
+			if n.ConfigHash != localHash {
+				settings = "different encoder settings"
+			}
+			title := widget.NewLabel(fmt.Sprintf("%s (%s, version %s, connected %s, %s)", node, n.Address, n.Version, n.ConnectedAt.Format("15:04:05"), settings))
+			title.TextStyle = fyne.TextStyle{Bold: true}
+			body.Add(container.NewHBox(title,
+				widget.NewButton("Refresh", func() { run(node, control.Message{Command: control.CommandStatus}, "status updated") }),
+				widget.NewButton("Show Log", func() { showLog(node) }),
+				widget.NewButton("Send Encoder Settings", func() { pushSettings(node) }),
+			))
+			if len(n.Streams) == 0 {
+				body.Add(widget.NewLabel("    no streams"))
+			}
+			for _, s := range n.Streams {
+				port := s.Port
+				bitrate := widget.NewEntry()
+				bitrate.SetPlaceHolder("e.g. 4M")
+				bitrate.SetText(s.Bitrate)
+				body.Add(container.NewHBox(
+					widget.NewLabel("    "+formatStreamStatus(s)),
+					widget.NewButton("Start", func() {
+						run(node, control.Message{Command: control.CommandStart, Port: port}, fmt.Sprintf("port %d started", port))
+					}),
+					widget.NewButton("Stop", func() {
+						run(node, control.Message{Command: control.CommandStop, Port: port}, fmt.Sprintf("port %d stopped", port))
+					}),
+					widget.NewButton("Restart", func() {
+						run(node, control.Message{Command: control.CommandRestart, Port: port}, fmt.Sprintf("port %d restarted", port))
+					}),
+					container.NewGridWrap(fyne.NewSize(100, bitrate.MinSize().Height), bitrate),
+					widget.NewButton("Set Bitrate", func() {
+						if err := control.ValidateBitrate(bitrate.Text); err != nil {
+							status.SetText("Error: " + err.Error())
+							return
+						}
+						run(node, control.Message{Command: control.CommandBitrate, Port: port, Bitrate: bitrate.Text}, fmt.Sprintf("port %d restarted with the new bitrate", port))
+					}),
+				))
+			}
+			body.Add(widget.NewSeparator())
+		}
+		body.Refresh()
+	}
+
+	d := dialog.NewCustom("Camera Nodes", "Close", container.NewBorder(nil, status, nil, nil, container.NewVScroll(body)), window)
+	control.SetNodesChangedHandler(rebuild)
+	d.SetOnClosed(func() { control.SetNodesChangedHandler(nil) })
+	rebuild()
+	d.Resize(fyne.NewSize(980, 520))
+	d.Show()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/recording"
+)
+
+// cameraConfigIdlePoll is how often a pending camera change checks whether
+// the attempt in progress is finished.
+const cameraConfigIdlePoll = time.Second
+
+var (
+	pendingCameraMu       sync.Mutex
+	pendingCameraSettings *config.MulticastSettings
+)
+
+// watchCameraConfig applies edits to the [mpeg-ts] section of config.toml
+// without a restart. Changes are validated immediately but only take effect
+// between attempts, never while recording or trimming.
+func watchCameraConfig(cfg *replays.Config) {
+	configFile := filepath.Join(config.GetInstallDir(), "config.toml")
+	_, err := replays.WatchConfigFile(configFile, func() {
+		settings, err := replays.ReadCameraSources(configFile)
+		if err != nil {
+			logging.ErrorLogger.Printf("Camera configuration change ignored: %v", err)
+			httpServer.SendStatus(httpServer.Error, fmt.Sprintf("Error: config.toml camera change not applied (%v)", err))
+			return
+		}
+		queueCameraSettings(cfg, settings)
+	})
+	if err != nil {
+		logging.WarningLogger.Printf("Changes to %s will need a restart: %v", configFile, err)
+	}
+}
+
+func queueCameraSettings(cfg *replays.Config, settings config.MulticastSettings) {
+	pendingCameraMu.Lock()
+	defer pendingCameraMu.Unlock()
+	if reflect.DeepEqual(settings, cfg.Multicast) {
+		pendingCameraSettings = nil
+		return
+	}
+	waiting := pendingCameraSettings != nil
+	pendingCameraSettings = &settings
+	if waiting {
+		return
+	}
+	go func() {
+		for recording.IsBusy() {
+			time.Sleep(cameraConfigIdlePoll)
+		}
+		pendingCameraMu.Lock()
+		next := pendingCameraSettings
+		pendingCameraSettings = nil
+		pendingCameraMu.Unlock()
+		if next != nil {
+			applyCameraSettings(cfg, *next)
+		}
+	}()
+}
+
+func applyCameraSettings(cfg *replays.Config, settings config.MulticastSettings) {
+	unlockCameraStreams(cfg.Multicast)
+	cfg.Multicast = settings
+	cfg.Cameras = settings.BuildCameraConfigs()
+	config.SetCameraConfigs(cfg.Cameras)
+	config.ClipSource = settings.ClipSource
+	config.ContextCamera = settings.ContextCamera
+	config.Scoreboard = settings.BuildScoreboardConfig()
+	logging.InfoLogger.Printf("Camera configuration reloaded from config.toml: %d stream(s)", len(cfg.Cameras))
+
+	if conflict := lockCameraStreams(settings); conflict != "" {
+		httpServer.SendStatus(httpServer.Error, conflict)
+		return
+	}
+	if err := recording.RestartPreBuffers(); err != nil {
+		logging.ErrorLogger.Printf("Failed to restart pre-buffering: %v", err)
+	}
+	message := fmt.Sprintf("New camera configuration active: %d stream(s)", len(cfg.Cameras))
+	httpServer.SendStatus(httpServer.Ready, message)
+	fyne.CurrentApp().SendNotification(fyne.NewNotification("Replays", message))
+}
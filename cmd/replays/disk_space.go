@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/recording"
+	"github.com/owlcms/replays/internal/state"
+)
+
+// watchDiskSpace keeps the disk space banner of the main window in line with
+// the free space of the video directory, then starts monitoring it.
+func watchDiskSpace(banner *widget.Label) {
+	state.OnDiskSpaceChanged = func() {
+		_, alert := state.DiskSpace()
+		setStatusLabelText(banner, alert, true)
+	}
+	recording.StartDiskMonitor()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/recording"
+)
+
+const dryRunUsage = `Usage: replays dryrun [options]
+
+Prints the ffmpeg command lines replays would run to record and trim an
+attempt on every camera of config.toml, with the file names, overlays and
+encoders resolved. Nothing is recorded. Paste the output into a support
+request.
+
+Options:
+`
+
+// maybeRunDryRunAndExit prints the dry-run report of "replays dryrun".
+func maybeRunDryRunAndExit() bool {
+	if len(os.Args) < 2 || os.Args[1] != "dryrun" {
+		return false
+	}
+	os.Exit(runDryRun(os.Args[2:], os.Stdout, os.Stderr))
+	return true
+}
+
+func runDryRun(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dryrun", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, dryRunUsage)
+		flags.PrintDefaults()
+	}
+	keep := flags.Float64("keep", 10, "seconds of the attempt kept by the trim")
+	detect := flags.Bool("detectEncoders", false, "probe the hardware encoders as at startup (takes a few seconds)")
+	flags.StringVar(&config.ConfigDir, "configDir", "", "directory containing config.toml")
+	flags.StringVar(&config.InstallDir, "dir", "replays", "name of an alternate installation directory")
+	flags.BoolVar(&config.Portable, "portable", false, "use the config.toml of the executable's folder")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *keep <= 0 {
+		fmt.Fprintln(stderr, "Error: -keep must be positive")
+		return 2
+	}
+
+	// The report is the output; the log would only get in the way.
+	logging.InfoLogger.SetOutput(io.Discard)
+	logging.WarningLogger.SetOutput(io.Discard)
+	if config.ConfigDir != "" {
+		if absConfigDir, err := filepath.Abs(config.ConfigDir); err == nil {
+			config.ConfigDir = absConfigDir
+		}
+	}
+	if err := config.ResolveAndEnsureConfigDir(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	cfg, err := replays.LoadConfig(filepath.Join(config.GetInstallDir(), "config.toml"))
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	config.SetCameraConfigs(cfg.Cameras)
+	if err := recording.InitializeFFmpeg(); err != nil {
+		fmt.Fprintf(stderr, "Warning: %v\n", err)
+	}
+	recording.SetVideoDir(cfg.VideoDir)
+	recording.SetVideoConfig(cfg.Width, cfg.Height, cfg.Fps)
+	if *detect {
+		recording.DetectTrimEncoder()
+	}
+
+	recording.DryRunReport(stdout, int64(*keep*1000), time.Now())
+	return 0
+}
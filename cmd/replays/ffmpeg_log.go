@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+const (
+	ffmpegLogNextLabel    = "Detailed ffmpeg Logs for Next Attempt"
+	ffmpegLogAttemptLabel = "Detailed ffmpeg Logs (this attempt is logged)"
+)
+
+// newFfmpegLogMenuItem returns the menu item that asks for detailed ffmpeg
+// logs for the next attempt only. Its check mark follows the requests made
+// from the video list, and goes away once the attempt is saved.
+func newFfmpegLogMenuItem(window fyne.Window) *fyne.MenuItem {
+	item := fyne.NewMenuItem(ffmpegLogNextLabel, nil)
+	if config.LogFfmpeg {
+		item.Label = "Detailed ffmpeg Logs (logFfmpeg logs every attempt)"
+		item.Disabled = true
+		return item
+	}
+	item.Action = func() {
+		on := config.FfmpegLogState() != config.FfmpegLogNext
+		config.LogNextAttempt(on)
+		if on {
+			logging.InfoLogger.Println("Detailed ffmpeg logs for the next attempt asked from the menu")
+		} else {
+			logging.InfoLogger.Println("Detailed ffmpeg logs for the next attempt cancelled from the menu")
+		}
+	}
+	config.OnFfmpegLogChanged = func() {
+		switch config.FfmpegLogState() {
+		case config.FfmpegLogNext:
+			item.Label, item.Checked = ffmpegLogNextLabel, true
+		case config.FfmpegLogAttempt:
+			item.Label, item.Checked = ffmpegLogAttemptLabel, false
+		default:
+			item.Label, item.Checked = ffmpegLogNextLabel, false
+		}
+		if menu := window.MainMenu(); menu != nil {
+			menu.Refresh()
+		}
+		httpServer.RefreshStatus()
+	}
+	return item
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/instances"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// registerInstance announces this replays instance to the others running on
+// the machine, moving to a free HTTP port if another instance already uses the
+// configured one. It returns an error text when a camera stream is already
+// received by another instance.
+func registerInstance(cfg *replays.Config) string {
+	instances.SetDir(filepath.Join(config.GetSharedConfigDir(), "instances"))
+
+	if port := instances.SuggestPort(cfg.Port); port != cfg.Port {
+		logging.WarningLogger.Printf("Port %d is used by another replays instance, using port %d", cfg.Port, port)
+		cfg.Port = port
+	}
+	if err := instances.Register(config.GetInstallDir(), cfg.Port, cfg.Platform); err != nil {
+		logging.WarningLogger.Printf("Failed to register replays instance: %v", err)
+	}
+
+	if config.Viewer {
+		return ""
+	}
+	return lockCameraStreams(cfg.Multicast)
+}
+
+// lockCameraStreams takes the stream locks for settings. Several listeners can
+// join the same multicast group, but a unicast UDP port delivers packets to a
+// single receiver, so unicast ports are locked. It returns an error text when
+// a port is already received by another instance.
+func lockCameraStreams(settings config.MulticastSettings) string {
+	if !isUnicastIP(settings.IP) {
+		return ""
+	}
+	var conflicts []string
+	for i, port := range cameraStreamPorts(settings) {
+		if port <= 0 {
+			continue
+		}
+		if err := instances.AcquireLock(fmt.Sprintf("udp port %d", port)); err != nil {
+			logging.ErrorLogger.Printf("%s: %v", cameraStreamName(i), err)
+			conflicts = append(conflicts, fmt.Sprintf("%s %v", cameraStreamName(i), err))
+		}
+	}
+	if len(conflicts) == 0 {
+		return ""
+	}
+	return "Error: " + strings.Join(conflicts, "; ")
+}
+
+// unlockCameraStreams releases the locks taken by lockCameraStreams.
+func unlockCameraStreams(settings config.MulticastSettings) {
+	if !isUnicastIP(settings.IP) {
+		return
+	}
+	for _, port := range cameraStreamPorts(settings) {
+		if port > 0 {
+			instances.ReleaseLock(fmt.Sprintf("udp port %d", port))
+		}
+	}
+}
+
+// cameraStreamPorts lists the ports of the four cameras followed by the
+// attempt board port.
+func cameraStreamPorts(settings config.MulticastSettings) []int {
+	return []int{settings.Camera1Port, settings.Camera2Port, settings.Camera3Port, settings.Camera4Port, settings.ScoreboardPort}
+}
+
+// cameraStreamName names the i-th entry of cameraStreamPorts.
+func cameraStreamName(i int) string {
+	if i == 4 {
+		return "Attempt board"
+	}
+	return fmt.Sprintf("Camera %d", i+1)
+}
+
+func formatOtherInstances(others []instances.Instance) string {
+	if len(others) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(others))
+	for _, other := range others {
+		platform := other.Platform
+		if platform == "" {
+			platform = "no platform"
+		}
+		status := other.Status
+		if status == "" {
+			status = "Ready"
+		}
+		lines = append(lines, fmt.Sprintf("Other instance %s (platform %s, port %d): %s",
+			filepath.Base(other.Dir), platform, other.Port, status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// watchOtherInstances keeps label showing the status of the other instances.
+func watchOtherInstances(label *widget.Label) {
+	for {
+		text := formatOtherInstances(instances.Others())
+		label.SetText(text)
+		if text == "" {
+			label.Hide()
+		} else {
+			label.Show()
+		}
+		time.Sleep(instances.HeartbeatInterval)
+	}
+}
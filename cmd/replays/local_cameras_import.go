@@ -179,10 +179,7 @@ func discoverLocalCamerasVersions() ([]localCamerasVersionOption, error) {
 // devLocalCamerasOption returns the local dev cameras config option
 // (./video_config/cameras/config.toml) if it exists, or nil otherwise.
 func devLocalCamerasOption() *localCamerasVersionOption {
-	devDir, err := filepath.Abs(filepath.Join(".", config.LocalVideoConfigDir, "cameras"))
-	if err != nil {
-		return nil
-	}
+	devDir := config.LocalAppConfigDir("cameras")
 	configPath := filepath.Join(devDir, "config.toml")
 	if info, statErr := os.Stat(configPath); statErr == nil && !info.IsDir() {
 		return &localCamerasVersionOption{
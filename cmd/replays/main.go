@@ -24,7 +24,11 @@ import (
 	"github.com/owlcms/replays/internal/config/cameras"
 	ffmpegcfg "github.com/owlcms/replays/internal/config/ffmpeg"
 	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/housekeeping"
 	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/instances"
+	"github.com/owlcms/replays/internal/jobutil"
 	"github.com/owlcms/replays/internal/logging"
 	"github.com/owlcms/replays/internal/monitor"
 	"github.com/owlcms/replays/internal/recording"
@@ -32,6 +36,9 @@ import (
 
 var titleLabel *widget.Label
 
+// profiling is the listener of the --pprof server, closed on shutdown.
+var profiling net.Listener
+
 func setAppIcon(myApp fyne.App) {
 	if assets.IconResource != nil && len(assets.IconResource.Content()) > 0 {
 		myApp.SetIcon(assets.IconResource)
@@ -107,6 +114,10 @@ func maybeExtractConfigAndExit() bool {
 
 	for i := 1; i < len(os.Args); i++ {
 		arg := os.Args[i]
+		if arg == "--portable" {
+			config.Portable = true
+			continue
+		}
 		if arg == "--configDir" && i+1 < len(os.Args) {
 			config.ConfigDir = os.Args[i+1]
 			i++
@@ -152,10 +163,15 @@ func shutdown() {
 
 	// Stop HTTP server
 	httpServer.StopServer()
+	if profiling != nil {
+		profiling.Close()
+	}
 
 	// Disconnect MQTT
 	monitor.DisconnectMQTT()
 
+	instances.Unregister()
+
 	logging.InfoLogger.Println("Application shutdown complete")
 }
 
@@ -174,7 +190,11 @@ func setupShutdownHook() {
 
 // openApplicationDirectory opens the application directory in the file explorer
 func openApplicationDirectory() {
-	dir := config.GetInstallDir()
+	openDirectory(config.GetInstallDir())
+}
+
+// openDirectory opens a directory in the file explorer
+func openDirectory(dir string) {
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
 	case "windows":
@@ -188,7 +208,7 @@ func openApplicationDirectory() {
 		return
 	}
 	if err := cmd.Start(); err != nil {
-		logging.ErrorLogger.Printf("Failed to open application directory: %v", err)
+		logging.ErrorLogger.Printf("Failed to open directory %s: %v", dir, err)
 	}
 }
 
@@ -228,6 +248,17 @@ func showEnabledCameras(cfg *replays.Config, window fyne.Window) {
 	dialog.Show()
 }
 
+// saveHTTPPort records the port the HTTP server actually bound to, so that the
+// replay list URL stays the same on the next start.
+func saveHTTPPort(cfg *replays.Config, port int) {
+	cfg.Port = port
+	instances.UpdatePort(port)
+	configFilePath := filepath.Join(config.GetInstallDir(), "config.toml")
+	if err := replays.UpdatePort(configFilePath, port); err != nil {
+		logging.ErrorLogger.Printf("Failed to save HTTP port %d: %v", port, err)
+	}
+}
+
 // showOwlCMSServerAddress shows a dialog with the OwlCMS server address
 func showOwlCMSServerAddress(cfg *replays.Config, window fyne.Window) {
 	var message string
@@ -240,22 +271,29 @@ func showOwlCMSServerAddress(cfg *replays.Config, window fyne.Window) {
 	entry := widget.NewEntry()
 	entry.SetPlaceHolder("Enter new server address")
 
+	messageLabel := widget.NewLabel(message)
+
 	updateFunc := func() {
-		newAddress := entry.Text
+		newAddress := strings.TrimSpace(entry.Text)
 		if newAddress != "" {
-			cfg.OwlCMS = newAddress
 			configFilePath := filepath.Join(config.GetInstallDir(), "config.toml")
 			if err := replays.UpdateConfigFile(configFilePath, newAddress); err != nil {
 				fmt.Printf("Error updating config file: %v\n", err)
 				dialog.ShowError(err, window)
 				return
 			}
-			successDialog := dialog.NewInformation("Success", "OwlCMS server address updated. The application will now exit. Please restart it.", window)
-			successDialog.SetOnClosed(func() {
-				window.Close()
-				os.Exit(0)
-			})
-			successDialog.Show()
+			messageLabel.SetText(fmt.Sprintf("Connecting to owlcms server at %s...", newAddress))
+			go func() {
+				if err := monitor.Reconnect(cfg, newAddress); err != nil {
+					logging.ErrorLogger.Printf("Failed to connect to new owlcms server: %v", err)
+					messageLabel.SetText(fmt.Sprintf("Current owlcms Server Address:\n%s (not connected)", newAddress))
+					httpServer.SendStatus(httpServer.Error, fmt.Sprintf("Error: %v", err))
+					return
+				}
+				messageLabel.SetText(fmt.Sprintf("Current owlcms Server Address:\n%s", newAddress))
+				updateTitle()
+				httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Connected to owlcms server at %s", newAddress))
+			}()
 		}
 	}
 
@@ -271,7 +309,7 @@ func showOwlCMSServerAddress(cfg *replays.Config, window fyne.Window) {
 	)
 
 	content := container.NewVBox(
-		widget.NewLabel(message),
+		messageLabel,
 		form,
 	)
 	dialog := dialog.NewCustom("OwlCMS Server Address", "Close", content, window)
@@ -327,18 +365,13 @@ func showPlatformSelection(cfg *replays.Config, window fyne.Window) {
 	dialog := dialog.NewCustomConfirm("Platform Selection", "Update", "Cancel", content,
 		func(update bool) {
 			if update && combo.Selected != "" {
-				cfg.Platform = combo.Selected
 				configFilePath := filepath.Join(config.GetInstallDir(), "config.toml")
 				if err := replays.UpdatePlatform(configFilePath, combo.Selected); err != nil {
 					dialog.ShowError(err, window)
 					return
 				}
-				successDialog := dialog.NewInformation("Success", "Platform updated. The application will now exit. Please restart it.", window)
-				successDialog.SetOnClosed(func() {
-					window.Close()
-					os.Exit(0)
-				})
-				successDialog.Show()
+				monitor.SwitchPlatform(cfg, combo.Selected)
+				updateTitle()
 			}
 		}, window)
 	dialog.Resize(fyne.NewSize(300, 0))
@@ -352,6 +385,8 @@ func confirmAndQuit(window fyne.Window) {
 		logging.InfoLogger.Println("No MQTT connection - shutting down immediately")
 		shutdown()
 		window.Close()
+		// With a tray icon the app outlives its last window.
+		fyne.CurrentApp().Quit()
 		return
 	}
 
@@ -363,6 +398,7 @@ func confirmAndQuit(window fyne.Window) {
 				logging.InfoLogger.Println("User requested application shutdown")
 				shutdown()
 				window.Close()
+				fyne.CurrentApp().Quit()
 			}
 		},
 		window,
@@ -378,7 +414,12 @@ func updateTitle() {
 	if platform == "" {
 		platform = "No Platform Selected"
 	}
+	if config.Viewer {
+		titleLabel.SetText("OWLCMS Jury Replays - Viewer")
+		return
+	}
 	titleLabel.SetText(fmt.Sprintf("OWLCMS Jury Replays - Platform %s", platform))
+	instances.UpdatePlatform(cfg.Platform)
 }
 
 // showConfigError displays configuration errors in a dialog and allows user to fix them
@@ -479,43 +520,79 @@ func showMulticastConfig(cfg *replays.Config, window fyne.Window) {
 		}
 		return strconv.Itoa(port)
 	}
-	parseOptionalPort := func(name, text string) (int, error) {
-		trimmed := strings.TrimSpace(text)
-		if trimmed == "" {
-			return 0, nil
-		}
-		port, err := strconv.Atoi(trimmed)
-		if err != nil || port < 1 || port > 65535 {
-			return 0, fmt.Errorf("%s must be empty or a number between 1 and 65535", name)
-		}
-		return port, nil
-	}
+
+	enabledCheck := widget.NewCheck("Record from Cameras Module streams", nil)
+	enabledCheck.SetChecked(m.Enabled)
 
 	ipEntry := widget.NewEntry()
 	ipEntry.SetText(m.IP)
 
-	port1Entry := widget.NewEntry()
-	port1Entry.SetText(portToText(m.Camera1Port))
-	port2Entry := widget.NewEntry()
-	port2Entry.SetText(portToText(m.Camera2Port))
-	port3Entry := widget.NewEntry()
-	port3Entry.SetText(portToText(m.Camera3Port))
-	port4Entry := widget.NewEntry()
-	port4Entry.SetText(portToText(m.Camera4Port))
+	portEntries := make([]*widget.Entry, multicastCameraCount)
+	portStatus := make([]*widget.Label, multicastCameraCount)
+	for i, port := range []int{m.Camera1Port, m.Camera2Port, m.Camera3Port, m.Camera4Port} {
+		portEntries[i] = widget.NewEntry()
+		portEntries[i].SetText(portToText(port))
+		portStatus[i] = widget.NewLabel("")
+	}
 
-	form := widget.NewForm(
+	portTexts := func() [multicastCameraCount]string {
+		var texts [multicastCameraCount]string
+		for i, entry := range portEntries {
+			texts[i] = entry.Text
+		}
+		return texts
+	}
+
+	// checkStreams validates the form and listens on every configured port in
+	// parallel, reporting per camera whether packets are arriving.
+	checkStreams := func() {
+		settings, err := parseMulticastForm(enabledCheck.Checked, ipEntry.Text, portTexts())
+		if err != nil {
+			for _, status := range portStatus {
+				status.SetText("")
+			}
+			portStatus[0].SetText(err.Error())
+			return
+		}
+		ip := net.ParseIP(settings.IP)
+		for i, port := range []int{settings.Camera1Port, settings.Camera2Port, settings.Camera3Port, settings.Camera4Port} {
+			if port <= 0 {
+				portStatus[i].SetText("disabled")
+				continue
+			}
+			portStatus[i].SetText("checking...")
+			target := cameraStreamProbeTarget{
+				index:     i,
+				label:     fmt.Sprintf("camera %d (port %d)", i+1, port),
+				ip:        ip,
+				port:      port,
+				multicast: ip.IsMulticast(),
+			}
+			go func(status *widget.Label, target cameraStreamProbeTarget) {
+				text := "no data received"
+				if probeCameraStreamTarget(target, multicastDialogProbeTimeout) {
+					text = "receiving data"
+				}
+				status.SetText(text)
+			}(portStatus[i], target)
+		}
+	}
+
+	formItems := []*widget.FormItem{
+		widget.NewFormItem("", enabledCheck),
 		widget.NewFormItem("Stream IP", ipEntry),
-		widget.NewFormItem("Camera 1 port", port1Entry),
-		widget.NewFormItem("Camera 2 port", port2Entry),
-		widget.NewFormItem("Camera 3 port", port3Entry),
-		widget.NewFormItem("Camera 4 port", port4Entry),
-	)
+	}
+	for i := range portEntries {
+		formItems = append(formItems, widget.NewFormItem(fmt.Sprintf("Camera %d port", i+1),
+			container.NewGridWithColumns(2, portEntries[i], portStatus[i])))
+	}
+	form := widget.NewForm(formItems...)
 
 	hint := widget.NewLabel("Use a multicast address (e.g. 239.255.0.1) for multicast mode, " +
 		"or 0.0.0.0 for unicast mode (passive UDP listener).\n" +
 		"If a port is empty, the corresponding camera is disabled.")
 	hint.Wrapping = fyne.TextWrapWord
-	content := container.NewVBox(form, hint)
+	content := container.NewVBox(form, widget.NewButton("Check Streams", checkStreams), hint)
 
 	dlg := dialog.NewCustomConfirm("Cameras Module Stream Configuration", "Save", "Cancel", content,
 		func(save bool) {
@@ -523,58 +600,86 @@ func showMulticastConfig(cfg *replays.Config, window fyne.Window) {
 				return
 			}
 
-			ip := strings.TrimSpace(ipEntry.Text)
-			parsedIP := net.ParseIP(ip)
-			if parsedIP == nil {
-				dialog.ShowError(fmt.Errorf("stream IP must be a valid IP address (multicast or unicast)"), window)
-				return
-			}
-			p1, err := parseOptionalPort("Camera 1 port", port1Entry.Text)
+			settings, err := parseMulticastForm(enabledCheck.Checked, ipEntry.Text, portTexts())
 			if err != nil {
 				dialog.ShowError(err, window)
 				return
 			}
-			p2, err := parseOptionalPort("Camera 2 port", port2Entry.Text)
-			if err != nil {
-				dialog.ShowError(err, window)
-				return
-			}
-			p3, err := parseOptionalPort("Camera 3 port", port3Entry.Text)
-			if err != nil {
-				dialog.ShowError(err, window)
-				return
-			}
-			p4, err := parseOptionalPort("Camera 4 port", port4Entry.Text)
-			if err != nil {
-				dialog.ShowError(err, window)
-				return
-			}
-
-			cfg.Multicast.IP = ip
-			cfg.Multicast.Camera1Port = p1
-			cfg.Multicast.Camera2Port = p2
-			cfg.Multicast.Camera3Port = p3
-			cfg.Multicast.Camera4Port = p4
-
+			// Settings that are only edited in config.toml are kept.
+			settings.ClipSource = cfg.Multicast.ClipSource
+			settings.ContextCamera = cfg.Multicast.ContextCamera
+			settings.ScoreboardPort = cfg.Multicast.ScoreboardPort
+			settings.SRTHost = cfg.Multicast.SRTHost
+			settings.SRTLatency = cfg.Multicast.SRTLatency
+			settings.SRTPassphrase = cfg.Multicast.SRTPassphrase
+			settings.CameraOffsetsMs = cfg.Multicast.CameraOffsetsMs
+			settings.FrameAccurateCameras = cfg.Multicast.FrameAccurateCameras
+			settings.Audio = cfg.Multicast.Audio
+			settings.Trim = cfg.Multicast.Trim
 			configFilePath := filepath.Join(config.GetInstallDir(), "config.toml")
-			if err := replays.UpdateMpegTSConfig(configFilePath, cfg.Multicast); err != nil {
+			if err := replays.UpdateMpegTSConfig(configFilePath, settings); err != nil {
 				dialog.ShowError(fmt.Errorf("failed to save Cameras Module Stream config: %w", err), window)
 				return
 			}
 
+			// Replays always receives streams, as LoadConfig does on startup.
+			settings.Enabled = true
+			queueCameraSettings(cfg, settings)
+
 			mode := "Multicast"
-			if !parsedIP.IsMulticast() {
+			if isUnicastIP(settings.IP) {
 				mode = "Unicast"
 			}
-			successDialog := dialog.NewInformation("Success", fmt.Sprintf("%s stream configuration saved. The application will now exit. Please restart it.", mode), window)
-			successDialog.SetOnClosed(func() {
-				window.Close()
-				os.Exit(0)
-			})
-			successDialog.Show()
+			message := fmt.Sprintf("%s stream configuration saved.", mode)
+			if recording.IsBusy() {
+				message += " It will be used once the current attempt is finished."
+			}
+			dialog.ShowInformation("Success", message, window)
 		}, window)
-	dlg.Resize(fyne.NewSize(400, 0))
+	dlg.Resize(fyne.NewSize(520, 0))
 	dlg.Show()
+	checkStreams()
+}
+
+const multicastCameraCount = 4
+const multicastDialogProbeTimeout = 1500 * time.Millisecond
+
+// parseMulticastForm validates the stream configuration dialog fields.
+// Empty ports disable the corresponding camera.
+func parseMulticastForm(enabled bool, ipText string, portTexts [multicastCameraCount]string) (config.MulticastSettings, error) {
+	settings := config.MulticastSettings{Enabled: enabled}
+
+	settings.IP = strings.TrimSpace(ipText)
+	if net.ParseIP(settings.IP) == nil {
+		return settings, fmt.Errorf("stream IP must be a valid IP address (multicast or unicast)")
+	}
+
+	var ports [multicastCameraCount]int
+	seen := make(map[int]int)
+	for i, text := range portTexts {
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" {
+			continue
+		}
+		port, err := strconv.Atoi(trimmed)
+		if err != nil || port < 1 || port > 65535 {
+			return settings, fmt.Errorf("Camera %d port must be empty or a number between 1 and 65535", i+1)
+		}
+		if other, dup := seen[port]; dup {
+			return settings, fmt.Errorf("Camera %d and Camera %d both use port %d", other, i+1, port)
+		}
+		seen[port] = i + 1
+		ports[i] = port
+	}
+	if enabled && len(seen) == 0 {
+		return settings, fmt.Errorf("at least one camera port is required when streams are enabled")
+	}
+
+	settings.Camera1Port = ports[0]
+	settings.Camera2Port = ports[1]
+	settings.Camera3Port = ports[2]
+	settings.Camera4Port = ports[3]
+	return settings, nil
 }
 
 // isUnicastIP reports whether ip is a unicast listen address (0.0.0.0 or a
@@ -617,7 +722,7 @@ func localMulticastMismatchNote(cfg *replays.Config) string {
 
 func loadStartupCamerasConfigForComparison() (*cameras.Config, string, error) {
 	if config.IsLocalDevRuntime() {
-		devDir := filepath.Join(".", config.LocalVideoConfigDir, "cameras")
+		devDir := config.LocalAppConfigDir("cameras")
 		cfg, err := cameras.LoadConfigFromDir(devDir)
 		if err == nil {
 			configPath := filepath.Join(devDir, "config.toml")
@@ -652,6 +757,15 @@ func main() {
 	if maybeExtractConfigAndExit() {
 		return
 	}
+	if maybeRunProcessToolAndExit() {
+		return
+	}
+	if maybeRunQueryAndExit() {
+		return
+	}
+	if maybeRunDryRunAndExit() {
+		return
+	}
 
 	// Disable Fyne telemetry
 	os.Setenv("FYNE_TELEMETRY", "0")
@@ -681,6 +795,9 @@ func main() {
 		return
 	}
 
+	jobutil.SetInventoryDir(config.GetProcessInventoryDir())
+	instanceConflict := registerInstance(cfg)
+
 	titleLabel = widget.NewLabel("")
 	titleLabel.TextStyle = fyne.TextStyle{Bold: true}
 	updateTitle()
@@ -697,27 +814,71 @@ func main() {
 		logging.WarningLogger.Printf("Warning: %v", err)
 		// Continue execution even if FFmpeg initialization fails
 	}
-	if err := recording.EnsureCompatibleFFmpegForRecording(cfg.Cameras); err != nil {
-		logging.WarningLogger.Printf("Warning: failed to switch to compatible ffmpeg for recording: %v", err)
+	if !config.Viewer {
+		if err := recording.EnsureCompatibleFFmpegForRecording(cfg.Cameras); err != nil {
+			logging.WarningLogger.Printf("Warning: failed to switch to compatible ffmpeg for recording: %v", err)
+		}
 	}
 
 	// Set recording package configuration
 	recording.SetNoVideo(config.NoVideo)
 	recording.SetVideoDir(cfg.VideoDir)
 	recording.SetVideoConfig(cfg.Width, cfg.Height, cfg.Fps)
+	if err := recording.StartPreBuffers(); err != nil {
+		logging.ErrorLogger.Printf("Failed to start pre-buffering: %v", err)
+	}
+	if !config.Viewer && !config.NoVideo {
+		go recording.DetectTrimEncoder()
+		recording.ResumePendingRecodes()
+	}
 
 	// Initialize with an empty status
 	var initialStatus string
 	if err := cfg.ValidateCamera(); err != nil {
 		initialStatus = "Error: " + err.Error()
 	} else {
-		initialStatus = ""
+		initialStatus = instanceConflict
 	}
 
-	// Start HTTP server
-	go func() {
-		httpServer.StartServer(cfg.Port, config.Verbose)
-	}()
+	// Start HTTP server, moving to the next free port if the configured one is taken
+	listener, port, err := httpServer.Listen(cfg.Port, cfg.PortRange)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to start HTTP server: %v", err)
+		initialStatus = "Error: " + err.Error()
+	} else {
+		if port != cfg.Port {
+			saveHTTPPort(cfg, port)
+		}
+		httpServer.ProbeVideo = recording.ProbeVideoDuration
+		httpServer.RecorderState = func() interface{} { return recording.DebugState() }
+		httpServer.SubscribedTopics = monitor.SubscribedTopics
+		control.SetToken(cfg.ControlToken)
+		control.SetConfigSource(ffmpegcfg.SharedConfigContent, cfg.SyncEncoders)
+		if !config.Viewer {
+			httpServer.StartLongRecording = recording.StartLongRecording
+			httpServer.StopLongRecording = recording.StopLongRecording
+			httpServer.SimulateDecision = monitor.SimulateDecision
+			httpServer.CalibrateCameras = func() (map[int]int, error) {
+				offsets, err := recording.CalibrateCameras()
+				if err != nil {
+					return nil, err
+				}
+				// The config watcher applies the saved offsets.
+				return offsets, replays.SaveCameraOffsets(filepath.Join(config.GetInstallDir(), "config.toml"), offsets)
+			}
+		}
+		go httpServer.StartServer(listener, logging.Verbose)
+		if config.ProfilePort > 0 {
+			if listener, err := httpServer.StartProfiling(config.ProfilePort); err != nil {
+				logging.WarningLogger.Printf("Profiling disabled: %v", err)
+			} else {
+				profiling = listener
+			}
+		}
+		if err := httpServer.WatchVideoDir(cfg.VideoDir); err != nil {
+			logging.WarningLogger.Printf("Videos added to %s will only be listed after a page reload: %v", cfg.VideoDir, err)
+		}
+	}
 
 	label := widget.NewLabel("OWLCMS Jury Replays")
 	label.TextStyle = fyne.TextStyle{Bold: true}
@@ -736,6 +897,15 @@ func main() {
 	startupMessages := widget.NewLabel("")
 	startupMessages.Wrapping = fyne.TextWrapWord
 	startupMessages.Hide()
+	otherInstances := widget.NewLabel("")
+	otherInstances.Wrapping = fyne.TextWrapWord
+	otherInstances.Hide()
+	maintenanceBanner := widget.NewLabel("")
+	maintenanceBanner.Wrapping = fyne.TextWrapWord
+	maintenanceBanner.Hide()
+	diskBanner := widget.NewLabel("")
+	diskBanner.Wrapping = fyne.TextWrapWord
+	diskBanner.Hide()
 
 	host := getReplayListHost()
 	urlStr := fmt.Sprintf("http://%s:%d", host, cfg.Port)
@@ -746,11 +916,16 @@ func main() {
 	upperContent := container.NewVBox(
 		topContainer,
 		container.NewHBox(replaysListLabel, hyperlink),
+		otherInstances,
 		widget.NewSeparator(),
+		maintenanceBanner,
+		diskBanner,
 		startupMessages,
 		statusLabel,
 	)
-	content := container.NewPadded(upperContent)
+	history := &statusHistory{}
+	history.add(time.Now(), httpServer.Ready, initialStatus)
+	content := container.NewPadded(container.NewBorder(upperContent, nil, nil, nil, history.panel()))
 
 	window.SetContent(content)
 	window.Resize(defaultWindowSize())
@@ -760,43 +935,79 @@ func main() {
 	multicastConfigItem := fyne.NewMenuItem("Cameras Module Stream Configuration", func() {
 		showMulticastConfig(cfg, window)
 	})
+	rebuildIndexItem := fyne.NewMenuItem("Rebuild Video Index", func() {
+		showVideoIndexRebuild(window)
+	})
 
-	mainMenu := fyne.NewMainMenu(
-		fyne.NewMenu("File",
-			fyne.NewMenuItem("Platform Selection", func() {
-				showPlatformSelection(cfg, window)
-				updateTitle() // Update title after platform selection
-			}),
-			fyne.NewMenuItem("owlcms Server Address", func() {
-				showOwlCMSServerAddress(cfg, window)
-			}),
-			fyne.NewMenuItemSeparator(),
-			fyne.NewMenuItem("Open Application Directory", func() {
-				openApplicationDirectory()
-			}),
-			fyne.NewMenuItemSeparator(),
-			fyne.NewMenuItem("Quit", func() {
-				confirmAndQuit(window)
-			}),
-		),
-		fyne.NewMenu("Cameras",
-			fyne.NewMenuItem("Use Streams from Local Cameras Module", func() {
-				showLocalCamerasImportDialog(cfg, window)
-			}),
-			multicastConfigItem,
-			fyne.NewMenuItemSeparator(),
-			// Camera tooling
-			fyne.NewMenuItem("List Enabled Cameras", func() {
-				showEnabledCameras(cfg, window)
-			}),
-		),
-		fyne.NewMenu("Help",
-			fyne.NewMenuItem("About", func() {
-				dialog.ShowInformation("About", fmt.Sprintf("OWLCMS Jury Replays\nVersion %s", config.GetProgramVersion()), window)
-			}),
-		),
+	fileMenu := fyne.NewMenu("File",
+		fyne.NewMenuItem("Platform Selection", func() {
+			showPlatformSelection(cfg, window)
+			updateTitle() // Update title after platform selection
+		}),
+		fyne.NewMenuItem("owlcms Server Address", func() {
+			showOwlCMSServerAddress(cfg, window)
+		}),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Open Application Directory", func() {
+			openApplicationDirectory()
+		}),
+		fyne.NewMenuItem("ffmpeg Processes", func() {
+			showProcessInventory(window)
+		}),
+		fyne.NewMenuItem("Export Session for Results Package", func() {
+			showSessionExport(window)
+		}),
+		rebuildIndexItem,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Quit", func() {
+			confirmAndQuit(window)
+		}),
 	)
-	window.SetMainMenu(mainMenu)
+	camerasMenu := fyne.NewMenu("Cameras",
+		fyne.NewMenuItem("Use Streams from Local Cameras Module", func() {
+			showLocalCamerasImportDialog(cfg, window)
+		}),
+		multicastConfigItem,
+		fyne.NewMenuItem("Camera Nodes", func() {
+			showCameraNodes(window)
+		}),
+		fyne.NewMenuItem("Maintenance Mode", func() {
+			showMaintenanceDialog(window)
+		}),
+		newFfmpegLogMenuItem(window),
+		fyne.NewMenuItem("Pre-roll Duration", func() {
+			showPreRollDialog(cfg, window)
+		}),
+		fyne.NewMenuItem("Simulate Referees Decision (Practice)", func() {
+			if err := monitor.SimulateDecision(); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}),
+		fyne.NewMenuItemSeparator(),
+		// Camera tooling
+		fyne.NewMenuItem("List Enabled Cameras", func() {
+			showEnabledCameras(cfg, window)
+		}),
+	)
+	helpMenu := fyne.NewMenu("Help",
+		fyne.NewMenuItem("About", func() {
+			dialog.ShowInformation("About", fmt.Sprintf("OWLCMS Jury Replays\nVersion %s", config.GetProgramVersion()), window)
+		}),
+	)
+	if config.Viewer {
+		// A viewer has no platform, owlcms connection or cameras to set up,
+		// and leaves the files of the shared folder where they are.
+		var items []*fyne.MenuItem
+		for _, item := range fileMenu.Items[3:] {
+			if item != rebuildIndexItem {
+				items = append(items, item)
+			}
+		}
+		fileMenu.Items = items
+		window.SetMainMenu(fyne.NewMainMenu(fileMenu, helpMenu))
+	} else {
+		window.SetMainMenu(fyne.NewMainMenu(fileMenu, camerasMenu, helpMenu))
+	}
 
 	// Register platform dialog function for monitor package
 	monitor.ShowPlatformDialogFunc = func() {
@@ -814,10 +1025,15 @@ func main() {
 			// Skip showing "Reloading..." in the Fyne window
 			if msg.Text == "Reloading..." {
 				msg.Text = "Ready"
+				history.add(time.Now(), msg.Code, "Videos ready")
+			} else {
+				history.add(time.Now(), msg.Code, msg.Text)
 			}
 
 			// Update status text and style
 			setStatusLabelText(statusLabel, msg.Text, strings.HasPrefix(msg.Text, "Error:"))
+			instances.UpdateStatus(msg.Text)
+			setTrayState(msg.Text)
 
 			if msg.Code == httpServer.Ready {
 				hideTimer = time.AfterFunc(10*time.Second, func() {
@@ -830,12 +1046,40 @@ func main() {
 	// Show the window before running the application
 	window.Show()
 	startStartupScans(cfg, statusLabel, startupMessages)
+	go checkDependencies(window, cfg)
+	go watchOtherInstances(otherInstances)
+	if !config.Viewer {
+		// A viewer shows the videos of another machine; their retention is
+		// that machine's.
+		housekeeping.Start()
+		watchCameraConfig(cfg)
+		watchMaintenance(maintenanceBanner)
+		watchDiskSpace(diskBanner)
+	}
+
+	if config.ReplayMQTT != "" {
+		go func() {
+			setStatusLabelText(statusLabel, fmt.Sprintf("Replaying MQTT archive %s (no video)", filepath.Base(config.ReplayMQTT)), false)
+			if err := monitor.ReplayArchive(config.ReplayMQTT); err != nil {
+				logging.ErrorLogger.Printf("MQTT replay failed: %v", err)
+				setStatusLabelText(statusLabel, "Error: "+err.Error(), true)
+				return
+			}
+			setStatusLabelText(statusLabel, "MQTT replay complete", false)
+		}()
+	}
 
 	// Set up shutdown hook early in main
 	setupShutdownHook()
 
-	// Remove the duplicate signal handling code and replace with window close handler
+	// With a tray icon, closing the window only hides it; replays keeps
+	// running until Quit is chosen from the tray or the File menu.
+	hasTray := setupTray(window, parsedURL, cfg.VideoDir)
 	window.SetCloseIntercept(func() {
+		if hasTray {
+			hideToTray(window)
+			return
+		}
 		confirmAndQuit(window)
 	})
 
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/state"
+)
+
+// maintenanceChoices are the durations offered, in minutes.
+var maintenanceChoices = []string{"5", "10", "15", "30", "60"}
+
+func maintenanceBannerText() string {
+	until, active := state.MaintenanceUntil()
+	if !active {
+		return ""
+	}
+	return fmt.Sprintf("MAINTENANCE MODE until %s: attempts are not recorded", until.Format("15:04"))
+}
+
+// watchMaintenance keeps the banner of the main window and the status shown
+// everywhere (web pages, tray, other instances) in line with maintenance mode.
+func watchMaintenance(banner *widget.Label) {
+	state.OnMaintenanceChanged = func() {
+		text := maintenanceBannerText()
+		setStatusLabelText(banner, text, true)
+		if text != "" {
+			httpServer.SendStatus(httpServer.Ready, text)
+		} else {
+			logging.InfoLogger.Println("Maintenance mode ended, attempts are recorded again")
+			httpServer.SendStatus(httpServer.Ready, "Maintenance mode ended: attempts are recorded again")
+		}
+	}
+}
+
+// showMaintenanceDialog starts, extends or ends maintenance mode.
+func showMaintenanceDialog(window fyne.Window) {
+	minutes := widget.NewSelect(maintenanceChoices, nil)
+	minutes.SetSelected(strconv.Itoa(int(state.DefaultMaintenanceDuration / time.Minute)))
+	explanation := widget.NewLabel("While in maintenance mode, start messages from owlcms are ignored so that\n" +
+		"camera work does not produce bad replays. It ends by itself after the chosen time.")
+	current := widget.NewLabel("Attempts are being recorded.")
+	if text := maintenanceBannerText(); text != "" {
+		current.SetText(text)
+	}
+
+	var d dialog.Dialog
+	start := widget.NewButton("Start Maintenance", func() {
+		value, _ := strconv.Atoi(minutes.Selected)
+		until := state.StartMaintenance(time.Duration(value) * time.Minute)
+		logging.InfoLogger.Printf("Maintenance mode started until %s", until.Format("15:04:05"))
+		d.Hide()
+	})
+	end := widget.NewButton("End Maintenance", func() {
+		if state.StopMaintenance() {
+			logging.InfoLogger.Println("Maintenance mode ended by the operator")
+		}
+		d.Hide()
+	})
+	if _, active := state.MaintenanceUntil(); active {
+		start.SetText("Extend Maintenance")
+	} else {
+		end.Disable()
+	}
+
+	content := container.NewVBox(
+		explanation,
+		current,
+		container.NewHBox(widget.NewLabel("Duration (minutes):"), minutes),
+		container.NewHBox(start, end),
+	)
+	d = dialog.NewCustom("Maintenance Mode", "Close", content, window)
+	d.Show()
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestParseMulticastForm(t *testing.T) {
+	settings, err := parseMulticastForm(true, " 239.255.0.1 ", [multicastCameraCount]string{"9001", "", " 9003", ""})
+	if err != nil {
+		t.Fatalf("parseMulticastForm: %v", err)
+	}
+	if !settings.Enabled || settings.IP != "239.255.0.1" || settings.Camera1Port != 9001 || settings.Camera2Port != 0 || settings.Camera3Port != 9003 {
+		t.Fatalf("unexpected settings: %+v", settings)
+	}
+
+	invalid := []struct {
+		name    string
+		enabled bool
+		ip      string
+		ports   [multicastCameraCount]string
+	}{
+		{name: "bad ip", enabled: true, ip: "239.255.0", ports: [multicastCameraCount]string{"9001"}},
+		{name: "port out of range", enabled: true, ip: "0.0.0.0", ports: [multicastCameraCount]string{"70000"}},
+		{name: "duplicate port", enabled: true, ip: "0.0.0.0", ports: [multicastCameraCount]string{"9001", "9001"}},
+		{name: "enabled without ports", enabled: true, ip: "0.0.0.0"},
+	}
+	for _, tt := range invalid {
+		if _, err := parseMulticastForm(tt.enabled, tt.ip, tt.ports); err == nil {
+			t.Fatalf("%s: expected an error", tt.name)
+		}
+	}
+
+	if _, err := parseMulticastForm(false, "0.0.0.0", [multicastCameraCount]string{}); err != nil {
+		t.Fatalf("disabled streams without ports should be accepted: %v", err)
+	}
+}
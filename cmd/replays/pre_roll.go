@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// preRollChoices are the pre-rolls offered, in seconds.
+var preRollChoices = []string{"3", "5", "8", "10", "12", "15", "20"}
+
+// showPreRollDialog changes how much approach footage the replays of the
+// current platform keep before the clock was stopped.
+func showPreRollDialog(cfg *replays.Config, window fyne.Window) {
+	platform := cfg.Platform
+	current := config.GetPreRoll(platform)
+	seconds := widget.NewSelectEntry(preRollChoices)
+	seconds.SetText(strconv.FormatFloat(current.Seconds(), 'f', -1, 64))
+	explanation := widget.NewLabel("Replays start this long before the clock was stopped, to show the approach\n" +
+		"to the bar. The change applies to the next attempt and is saved in config.toml.")
+	name := "this platform"
+	if platform != "" {
+		name = "platform " + platform
+	}
+
+	var d dialog.Dialog
+	apply := widget.NewButton("Apply", func() {
+		value, err := strconv.ParseFloat(seconds.Text, 64)
+		if err != nil || value < 0 || value > 60 {
+			dialog.ShowError(fmt.Errorf("the pre-roll is a number of seconds between 0 and 60"), window)
+			return
+		}
+		preRoll := time.Duration(value * float64(time.Second)).Round(time.Millisecond)
+		config.SetPreRoll(platform, preRoll)
+		logging.InfoLogger.Printf("Pre-roll of %s set to %v", name, preRoll)
+		configFilePath := filepath.Join(config.GetInstallDir(), "config.toml")
+		if err := replays.UpdatePreRoll(configFilePath, platform, int(preRoll.Milliseconds())); err != nil {
+			logging.ErrorLogger.Printf("Failed to save the pre-roll: %v", err)
+			dialog.ShowError(fmt.Errorf("the pre-roll is used until replays is restarted, but it could not be saved: %w", err), window)
+			return
+		}
+		d.Hide()
+	})
+
+	content := container.NewVBox(
+		explanation,
+		container.NewHBox(widget.NewLabel(fmt.Sprintf("Pre-roll of %s (seconds):", name)), seconds),
+		apply,
+	)
+	d = dialog.NewCustom("Pre-roll Duration", "Close", content, window)
+	d.Show()
+}
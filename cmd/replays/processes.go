@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+const orphanStopTimeout = 2 * time.Second
+
+func formatProcessInventory(processes []jobutil.TrackedProcess) string {
+	if len(processes) == 0 {
+		return "No ffmpeg or ffplay processes started by replays or cameras are running."
+	}
+
+	var builder strings.Builder
+	for _, p := range processes {
+		status := "running"
+		if p.Orphan {
+			status = "ORPHAN"
+		}
+		builder.WriteString(fmt.Sprintf("%-7s %s (%d) started %s by pid %d: %s\n",
+			status, p.Command, p.PID, p.Started.Format("15:04:05"), p.Owner, p.Label))
+	}
+	return builder.String()
+}
+
+func countOrphans(processes []jobutil.TrackedProcess) int {
+	count := 0
+	for _, p := range processes {
+		if p.Orphan {
+			count++
+		}
+	}
+	return count
+}
+
+// showProcessInventory lists the ffmpeg/ffplay processes started by this
+// install and offers to stop the ones whose parent program is gone.
+func showProcessInventory(window fyne.Window) {
+	textArea := widget.NewMultiLineEntry()
+	textArea.SetMinRowsVisible(12)
+	textArea.TextStyle = fyne.TextStyle{Monospace: true}
+	summary := widget.NewLabel("")
+
+	var stopButton *widget.Button
+	refresh := func() {
+		processes, err := jobutil.Inventory()
+		if err != nil {
+			textArea.SetText(fmt.Sprintf("Failed to list processes: %v", err))
+			stopButton.Disable()
+			return
+		}
+		textArea.SetText(formatProcessInventory(processes))
+		orphans := countOrphans(processes)
+		summary.SetText(fmt.Sprintf("%d running, %d orphaned", len(processes), orphans))
+		if orphans > 0 {
+			stopButton.Enable()
+		} else {
+			stopButton.Disable()
+		}
+	}
+
+	stopButton = widget.NewButton("Stop Orphans", func() {
+		stopped, err := jobutil.StopOrphans(orphanStopTimeout)
+		logging.InfoLogger.Printf("Stopped %d orphaned ffmpeg/ffplay processes", stopped)
+		if err != nil {
+			logging.ErrorLogger.Printf("Failed to stop orphaned processes: %v", err)
+			dialog.ShowError(err, window)
+		}
+		refresh()
+	})
+
+	d := dialog.NewCustom("ffmpeg Processes", "Close", container.NewVBox(
+		widget.NewLabel("Processes started by replays and cameras (orphans were left behind by a program that is no longer running):"),
+		textArea,
+		container.NewHBox(summary, widget.NewButton("Refresh", refresh), stopButton),
+	), window)
+	refresh()
+	d.Resize(fyne.NewSize(760, 420))
+	d.Show()
+}
+
+// maybeRunProcessToolAndExit handles --processes (list) and --stopOrphans
+// before the UI starts.
+func maybeRunProcessToolAndExit() bool {
+	var list, stop bool
+	for _, arg := range os.Args[1:] {
+		switch arg {
+		case "--processes":
+			list = true
+		case "--stopOrphans":
+			stop = true
+		case "--portable":
+			config.Portable = true
+		}
+	}
+	if !list && !stop {
+		return false
+	}
+
+	jobutil.SetInventoryDir(config.GetProcessInventoryDir())
+	if stop {
+		stopped, err := jobutil.StopOrphans(orphanStopTimeout)
+		fmt.Printf("Stopped %d orphaned processes\n", stopped)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	processes, err := jobutil.Inventory()
+	if err != nil {
+		fmt.Printf("Failed to list processes: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(strings.TrimRight(formatProcessInventory(processes), "\n"))
+	return true
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+const queryUsage = `Usage: replays query <sessions|attempts|files|export> [options]
+
+  sessions   list the sessions of the video directory
+  attempts   list the recorded attempts (tab-separated: session, time,
+             athlete, lift, attempt, cameras)
+  files      print the path of every replay file
+  export     write the results package export (CSV and JSON, with SHA-256
+             hashes) of -session in its folder and print the file paths
+
+Options:
+`
+
+// queryAttempt is a lift with its session, as printed by replays query.
+type queryAttempt struct {
+	Session string `json:"session"`
+	httpServer.ReplayLift
+}
+
+// maybeRunQueryAndExit answers "replays query ..." from the replay index of
+// the video directory, for scripts and spreadsheets. Nothing is started.
+func maybeRunQueryAndExit() bool {
+	if len(os.Args) < 2 || os.Args[1] != "query" {
+		return false
+	}
+	os.Exit(runQuery(os.Args[2:], os.Stdout, os.Stderr))
+	return true
+}
+
+func runQuery(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, queryUsage)
+		flags.PrintDefaults()
+	}
+	session := flags.String("session", "", "only this session (default: all sessions)")
+	athlete := flags.String("athlete", "", "only this athlete, as shown in the replay list (case-insensitive)")
+	camera := flags.Int("camera", -1, "files: only this camera (0 is the attempt board)")
+	sortMode := flags.String("sort", "time", "attempts: time (latest first) or athlete")
+	asJSON := flags.Bool("json", false, "print JSON instead of text")
+	flags.StringVar(&config.ConfigDir, "configDir", "", "directory containing config.toml")
+	flags.StringVar(&config.InstallDir, "dir", "replays", "name of an alternate installation directory")
+	flags.BoolVar(&config.Portable, "portable", false, "use the config.toml of the executable's folder")
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		flags.Usage()
+		return 2
+	}
+	what := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	videoDir, err := queryVideoDir()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	config.SetVideoDir(videoDir)
+
+	switch what {
+	case "sessions":
+		err = querySessions(stdout, *asJSON)
+	case "attempts":
+		err = queryAttempts(stdout, *session, *athlete, *sortMode, *asJSON)
+	case "files":
+		err = queryFiles(stdout, videoDir, *session, *athlete, *camera, *asJSON)
+	case "export":
+		err = queryExport(stdout, *session)
+	default:
+		fmt.Fprintf(stderr, "Unknown query %q\n\n", what)
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// queryVideoDir reads the video directory from config.toml. The program is
+// loaded as a viewer so that nothing is created, and its log is silenced to
+// keep the output clean for scripts.
+func queryVideoDir() (string, error) {
+	logging.InfoLogger.SetOutput(io.Discard)
+	logging.WarningLogger.SetOutput(io.Discard)
+	config.Viewer = true
+	if config.ConfigDir != "" {
+		if absConfigDir, err := filepath.Abs(config.ConfigDir); err == nil {
+			config.ConfigDir = absConfigDir
+		}
+	}
+	if err := config.ResolveAndEnsureConfigDir(); err != nil {
+		return "", err
+	}
+	cfg, err := replays.LoadConfig(filepath.Join(config.GetInstallDir(), "config.toml"))
+	if err != nil {
+		return "", err
+	}
+	return cfg.VideoDir, nil
+}
+
+func querySessions(out io.Writer, asJSON bool) error {
+	sessions, err := httpServer.ListReplaySessions()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return writeQueryJSON(out, sessions)
+	}
+	for _, s := range sessions {
+		fmt.Fprintf(out, "%s\t%d\n", s.ID, s.LiftCount)
+	}
+	return nil
+}
+
+// queryLifts returns the lifts of one session, or of all sessions in the
+// order of ListReplaySessions.
+func queryLifts(session, athlete, sortMode string) ([]queryAttempt, error) {
+	sessions := []string{session}
+	if session == "" {
+		summaries, err := httpServer.ListReplaySessions()
+		if err != nil {
+			return nil, err
+		}
+		sessions = sessions[:0]
+		for _, s := range summaries {
+			sessions = append(sessions, s.ID)
+		}
+	}
+	var attempts []queryAttempt
+	for _, name := range sessions {
+		lifts, _, err := httpServer.ReplaySessionLifts(name, athlete, sortMode)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("no session %q in the video directory", name)
+			}
+			return nil, err
+		}
+		for _, lift := range lifts {
+			attempts = append(attempts, queryAttempt{Session: name, ReplayLift: lift})
+		}
+	}
+	return attempts, nil
+}
+
+func queryAttempts(out io.Writer, session, athlete, sortMode string, asJSON bool) error {
+	attempts, err := queryLifts(session, athlete, sortMode)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		if attempts == nil {
+			attempts = []queryAttempt{}
+		}
+		return writeQueryJSON(out, attempts)
+	}
+	for _, a := range attempts {
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", a.Session, a.Timestamp, a.Athlete, a.LiftType, a.Attempt, a.ReplayCount, a.Time)
+	}
+	return nil
+}
+
+func queryFiles(out io.Writer, videoDir, session, athlete string, camera int, asJSON bool) error {
+	attempts, err := queryLifts(session, athlete, "time")
+	if err != nil {
+		return err
+	}
+	paths := []string{}
+	for _, a := range attempts {
+		for _, replay := range a.Replays {
+			if camera >= 0 && replay.Camera != camera {
+				continue
+			}
+			paths = append(paths, filepath.Join(videoDir, a.Session, replay.Filename))
+		}
+	}
+	if asJSON {
+		return writeQueryJSON(out, paths)
+	}
+	for _, path := range paths {
+		fmt.Fprintln(out, path)
+	}
+	return nil
+}
+
+func queryExport(out io.Writer, session string) error {
+	if session == "" {
+		return fmt.Errorf("export needs -session")
+	}
+	paths, err := httpServer.ExportSession(session)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fmt.Fprintln(out, path)
+	}
+	return nil
+}
+
+func writeQueryJSON(out io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
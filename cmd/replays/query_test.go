@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestQueryFilesFiltersByAthleteAndCamera(t *testing.T) {
+	videoDir := t.TempDir()
+	sessionDir := filepath.Join(videoDir, "A")
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"2025-03-01_10h00m00s_DOE_Jane_SNATCH_attempt1_Camera1.mp4",
+		"2025-03-01_10h00m00s_DOE_Jane_SNATCH_attempt1_Camera2.mp4",
+		"2025-03-01_10h02m00s_ROE_Rick_SNATCH_attempt1_Camera1.mp4",
+	} {
+		if err := os.WriteFile(filepath.Join(sessionDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	var out bytes.Buffer
+	if err := queryFiles(&out, videoDir, "", "doe jane", 2, false); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(sessionDir, "2025-03-01_10h00m00s_DOE_Jane_SNATCH_attempt1_Camera2.mp4")
+	if strings.TrimSpace(out.String()) != want {
+		t.Fatalf("files = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := queryAttempts(&out, "A", "", "time", false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "A\t2025-03-01_10h02m00s\tROE Rick\tSNATCH\t1\t1\t") || len(strings.Split(lines[0], "\t")) != 7 {
+		t.Fatalf("attempts = %q", out.String())
+	}
+
+	if err := queryAttempts(&out, "B", "", "time", false); err == nil {
+		t.Fatal("an unknown session must be reported")
+	}
+}
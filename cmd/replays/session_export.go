@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// showSessionExport writes the results package export of a chosen session in
+// its folder.
+func showSessionExport(window fyne.Window) {
+	sessions, err := httpServer.ListReplaySessions()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	if len(sessions) == 0 {
+		dialog.ShowInformation("Export Session", "No session has been recorded yet.", window)
+		return
+	}
+	var names []string
+	for _, s := range sessions {
+		names = append(names, s.ID)
+	}
+	choice := widget.NewSelect(names, nil)
+	choice.SetSelected(names[0])
+
+	content := container.NewVBox(
+		widget.NewLabel("Writes a CSV and a JSON file listing every attempt of the session with its\nvideo files and their SHA-256 hashes, for the official results package."),
+		container.NewHBox(widget.NewLabel("Session:"), choice),
+	)
+	dialog.ShowCustomConfirm("Export Session for Results Package", "Export", "Cancel", content, func(ok bool) {
+		if !ok || choice.Selected == "" {
+			return
+		}
+		session := choice.Selected
+		go func() {
+			paths, err := httpServer.ExportSession(session)
+			if err != nil {
+				logging.ErrorLogger.Printf("Export of session %s failed: %v", session, err)
+				dialog.ShowError(err, window)
+				return
+			}
+			dialog.ShowInformation("Session Exported", fmt.Sprintf("Written:\n%s", strings.Join(paths, "\n")), window)
+		}()
+	}, window)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/owlcms/replays/internal/config"
 	camerascfg "github.com/owlcms/replays/internal/config/cameras"
 	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/depcheck"
 	"github.com/owlcms/replays/internal/logging"
 	"github.com/owlcms/replays/internal/monitor"
 )
@@ -101,6 +102,16 @@ func setMessageLabelText(label *widget.Label, text string) {
 	label.Refresh()
 }
 
+// checkDependencies looks for machine settings, such as firewall rules, that
+// would keep browsers or camera streams from reaching replays.
+func checkDependencies(window fyne.Window, cfg *replays.Config) {
+	opts := depcheck.Options{HTTPPort: cfg.Port}
+	if cfg.Multicast.Enabled && !config.Viewer {
+		opts.UDPPorts = cameraStreamPorts(cfg.Multicast)
+	}
+	depcheck.ShowIssues(window, depcheck.Run(opts))
+}
+
 func startStartupScans(cfg *replays.Config, statusLabel, startupLabel *widget.Label) {
 	if cfg == nil || statusLabel == nil || startupLabel == nil {
 		return
@@ -120,7 +131,10 @@ func startStartupScans(cfg *replays.Config, statusLabel, startupLabel *widget.La
 		results := make(chan startupScanResult, 3)
 		var wg sync.WaitGroup
 
-		if config.NoMQTT {
+		if config.Viewer {
+			setStatusLabelText(statusLabel, "Viewer mode: listing the videos of "+cfg.VideoDir, false)
+			results <- startupScanResult{order: 1, text: ""}
+		} else if config.NoMQTT {
 			logging.InfoLogger.Println("MQTT autodiscovery disabled via -noMQTT flag")
 			setStatusLabelText(statusLabel, "MQTT disabled", false)
 			results <- startupScanResult{order: 1, text: ""}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/httpServer"
+)
+
+// statusHistorySize is how many status messages the history panel keeps.
+const statusHistorySize = 50
+
+type statusSeverity int
+
+const (
+	severityInfo statusSeverity = iota
+	severityRecording
+	severityReady
+	severityWarning
+	severityError
+)
+
+type statusEvent struct {
+	at       time.Time
+	text     string
+	severity statusSeverity
+}
+
+// statusHistory keeps the recent status messages, newest first, so that
+// operators can see what happened while they were looking away.
+type statusHistory struct {
+	mu     sync.Mutex
+	events []statusEvent
+	list   *widget.List
+}
+
+func statusSeverityOf(code httpServer.StatusCode, text string) statusSeverity {
+	switch {
+	case code == httpServer.Error || strings.HasPrefix(text, "Error:"):
+		return severityError
+	case strings.Contains(text, "Warning:"):
+		return severityWarning
+	case code == httpServer.Recording || code == httpServer.Trimming:
+		return severityRecording
+	case code == httpServer.Ready:
+		return severityReady
+	}
+	return severityInfo
+}
+
+func (s statusSeverity) colorName() fyne.ThemeColorName {
+	switch s {
+	case severityError:
+		return theme.ColorNameError
+	case severityWarning:
+		return theme.ColorNameWarning
+	case severityRecording:
+		return theme.ColorNamePrimary
+	case severityReady:
+		return theme.ColorNameSuccess
+	}
+	return theme.ColorNameForeground
+}
+
+// add records a status message. Repeats of the latest message only refresh
+// its time.
+func (h *statusHistory) add(at time.Time, code httpServer.StatusCode, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	event := statusEvent{at: at, text: text, severity: statusSeverityOf(code, text)}
+	h.mu.Lock()
+	if len(h.events) > 0 && h.events[0].text == text {
+		h.events[0].at = at
+	} else {
+		h.events = append([]statusEvent{event}, h.events...)
+		if len(h.events) > statusHistorySize {
+			h.events = h.events[:statusHistorySize]
+		}
+	}
+	h.mu.Unlock()
+	if h.list != nil {
+		h.list.Refresh()
+	}
+}
+
+func (h *statusHistory) event(i int) (statusEvent, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < 0 || i >= len(h.events) {
+		return statusEvent{}, false
+	}
+	return h.events[i], true
+}
+
+func (h *statusHistory) length() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.events)
+}
+
+// panel builds the scrollable "Recent events" panel.
+func (h *statusHistory) panel() fyne.CanvasObject {
+	h.list = widget.NewList(
+		h.length,
+		func() fyne.CanvasObject {
+			row := widget.NewRichText(
+				&widget.TextSegment{Style: widget.RichTextStyle{Inline: true, TextStyle: fyne.TextStyle{Monospace: true}}},
+				&widget.TextSegment{Style: widget.RichTextStyle{Inline: true}},
+			)
+			row.Truncation = fyne.TextTruncateEllipsis
+			return row
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			event, ok := h.event(id)
+			if !ok {
+				return
+			}
+			row := item.(*widget.RichText)
+			timeSegment := row.Segments[0].(*widget.TextSegment)
+			textSegment := row.Segments[1].(*widget.TextSegment)
+			timeSegment.Text = event.at.Format("15:04:05") + "  "
+			textSegment.Text = event.text
+			textSegment.Style.ColorName = event.severity.colorName()
+			textSegment.Style.TextStyle = fyne.TextStyle{Bold: event.severity == severityError}
+			row.Refresh()
+		},
+	)
+	title := widget.NewLabel("Recent events")
+	title.TextStyle = fyne.TextStyle{Bold: true}
+	return container.NewBorder(title, nil, nil, nil, h.list)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/httpServer"
+)
+
+func TestStatusHistoryKeepsNewestFirstAndCaps(t *testing.T) {
+	history := &statusHistory{}
+	start := time.Date(2026, 10, 15, 9, 0, 0, 0, time.Local)
+	for i := 0; i < statusHistorySize+5; i++ {
+		history.add(start.Add(time.Duration(i)*time.Second), httpServer.Ready, fmt.Sprintf("event %d", i))
+	}
+	if got := history.length(); got != statusHistorySize {
+		t.Fatalf("length = %d, want %d", got, statusHistorySize)
+	}
+	newest, _ := history.event(0)
+	if newest.text != fmt.Sprintf("event %d", statusHistorySize+4) {
+		t.Fatalf("newest = %q", newest.text)
+	}
+
+	history.add(start.Add(time.Hour), httpServer.Ready, newest.text)
+	if got := history.length(); got != statusHistorySize {
+		t.Fatalf("a repeated message was added again, length = %d", got)
+	}
+	if repeated, _ := history.event(0); !repeated.at.Equal(start.Add(time.Hour)) {
+		t.Fatalf("repeated message time = %v, want refreshed", repeated.at)
+	}
+	history.add(start, httpServer.Ready, "  ")
+	if got := history.length(); got != statusHistorySize {
+		t.Fatal("a blank message was recorded")
+	}
+}
+
+func TestStatusSeverityOf(t *testing.T) {
+	tests := []struct {
+		code httpServer.StatusCode
+		text string
+		want statusSeverity
+	}{
+		{httpServer.Error, "No camera could be started", severityError},
+		{httpServer.Ready, "Error: Failed to trim video for Camera 1", severityError},
+		{httpServer.Ready, "Warning: Camera 2 replay was re-encoded", severityWarning},
+		{httpServer.Recording, "Recording: DOE John - SNATCH attempt 1 (Warning: Camera 2 failed to start)", severityWarning},
+		{httpServer.Trimming, "Trimming videos", severityRecording},
+		{httpServer.Ready, "Videos ready", severityReady},
+	}
+	for _, tt := range tests {
+		if got := statusSeverityOf(tt.code, tt.text); got != tt.want {
+			t.Errorf("statusSeverityOf(%d, %q) = %d, want %d", tt.code, tt.text, got, tt.want)
+		}
+	}
+}
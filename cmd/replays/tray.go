@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"github.com/owlcms/replays/internal/assets"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// trayStateMaxLen keeps the state line of the tray menu readable.
+const trayStateMaxLen = 60
+
+var (
+	trayMu        sync.Mutex
+	trayMenu      *fyne.Menu
+	trayStateItem *fyne.MenuItem
+	trayHintShown bool
+)
+
+// setupTray adds a system tray icon showing the replays state, with quick
+// actions. It returns false when the platform has no system tray.
+func setupTray(window fyne.Window, listURL *url.URL, videoDir string) bool {
+	desk, ok := fyne.CurrentApp().(desktop.App)
+	if !ok {
+		return false
+	}
+
+	trayStateItem = fyne.NewMenuItem("Ready", nil)
+	trayStateItem.Disabled = true
+	// The tray adds its own Quit item, which would exit without stopping
+	// recordings, unless the last item is marked as the quit item; this one
+	// goes through the usual confirmation.
+	exitItem := fyne.NewMenuItem("Stop Replays and Exit", func() {
+		window.Show()
+		confirmAndQuit(window)
+	})
+	exitItem.IsQuit = true
+	trayMenu = fyne.NewMenu("Replays",
+		trayStateItem,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Show Window", func() {
+			window.Show()
+			window.RequestFocus()
+		}),
+		fyne.NewMenuItem("Open Replay List in Browser", func() {
+			if listURL == nil {
+				return
+			}
+			if err := fyne.CurrentApp().OpenURL(listURL); err != nil {
+				logging.ErrorLogger.Printf("Failed to open %s: %v", listURL, err)
+			}
+		}),
+		fyne.NewMenuItem("Open Video Folder", func() {
+			openDirectory(videoDir)
+		}),
+		fyne.NewMenuItemSeparator(),
+		exitItem,
+	)
+	desk.SetSystemTrayMenu(trayMenu)
+	if assets.IconResource != nil && len(assets.IconResource.Content()) > 0 {
+		desk.SetSystemTrayIcon(assets.IconResource)
+	}
+	return true
+}
+
+// setTrayState shows the latest status in the tray menu.
+func setTrayState(text string) {
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	if trayStateItem == nil {
+		return
+	}
+	trayStateItem.Label = trayStateLabel(text)
+	trayMenu.Refresh()
+}
+
+func trayStateLabel(text string) string {
+	if text == "" {
+		return "Ready"
+	}
+	runes := []rune(text)
+	if len(runes) > trayStateMaxLen {
+		return string(runes[:trayStateMaxLen-1]) + "…"
+	}
+	return text
+}
+
+// hideToTray hides the main window; the first time, the operator is told
+// that replays is still running.
+func hideToTray(window fyne.Window) {
+	window.Hide()
+	trayMu.Lock()
+	first := !trayHintShown
+	trayHintShown = true
+	trayMu.Unlock()
+	if first {
+		fyne.CurrentApp().SendNotification(fyne.NewNotification("Replays", "Replays is still running. Use the tray icon to show the window or to exit."))
+	}
+}
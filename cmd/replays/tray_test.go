@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTrayStateLabel(t *testing.T) {
+	if got := trayStateLabel(""); got != "Ready" {
+		t.Fatalf("trayStateLabel(\"\") = %q, want Ready", got)
+	}
+	if got := trayStateLabel("Recording: DOE John"); got != "Recording: DOE John" {
+		t.Fatalf("short state changed to %q", got)
+	}
+	long := "Recording: " + strings.Repeat("é", 80)
+	got := trayStateLabel(long)
+	if utf8.RuneCountInString(got) != trayStateMaxLen || !strings.HasSuffix(got, "…") {
+		t.Fatalf("trayStateLabel(long) = %q, want %d runes ending with an ellipsis", got, trayStateMaxLen)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// showVideoIndexRebuild rescans the video directory after files were moved
+// by hand, and shows what was found.
+func showVideoIndexRebuild(window fyne.Window) {
+	content := widget.NewLabel("Checks every video with ffprobe and moves the voice notes, annotations and\n" +
+		"slow-motion copies left behind by replays moved by hand next to them.")
+	dialog.ShowCustomConfirm("Rebuild Video Index", "Rebuild", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		progress := dialog.NewCustomWithoutButtons("Rebuild Video Index", widget.NewLabel("Scanning the video directory..."), window)
+		progress.Show()
+		go func() {
+			report, err := httpServer.RebuildVideoIndex()
+			progress.Hide()
+			if err != nil {
+				logging.ErrorLogger.Printf("Rebuilding the video index failed: %v", err)
+				dialog.ShowError(err, window)
+				return
+			}
+			summary := widget.NewMultiLineEntry()
+			summary.SetText(report.Summary())
+			summary.SetMinRowsVisible(12)
+			d := dialog.NewCustom("Video Index Rebuilt", "Close", container.NewVBox(summary), window)
+			d.Resize(fyne.NewSize(700, 0))
+			d.Show()
+		}()
+	}, window)
+}
@@ -0,0 +1,127 @@
+#!/usr/bin/env bash
+
+set -euo pipefail
+
+# Downloads the ffmpeg build bundled with the installers into <dest>/ffmpeg/bin
+# and verifies it before it is packaged.
+#
+# Usage: dist/packaging/fetch-ffmpeg.sh <windows|linux-amd64|linux-arm64|macos> <dest>
+#
+# Every download is pinned: the URLs name a fixed release, never a rolling
+# "latest" build, and the archive must match the checksum pinned for its
+# platform in FFMPEG_SHA256_<PLATFORM> (FFMPEG_SHA256_WINDOWS,
+# FFMPEG_SHA256_LINUX_AMD64, FFMPEG_SHA256_LINUX_ARM64, FFMPEG_SHA256_MACOS).
+# The Linux builds come from a dated BtbN autobuild release named by
+# FFMPEG_LINUX_RELEASE, e.g. "autobuild-2025-01-31-12-58/ffmpeg-n7.1-153-gaeb8631048"
+# (the release tag and the file name without its -linux64-gpl-7.1.tar.xz ending).
+# The release workflow takes these from the repository variables of the same
+# name. The script stops when a pin is missing; the checksum of the downloaded
+# archive is printed so that it can be pinned.
+#
+# The extracted ffmpeg must also run, report the expected version and provide
+# the libx264 encoder used when recoding replays.
+
+FFMPEG_VERSION="7.1"
+
+if [[ $# -ne 2 ]]; then
+  echo "Usage: $0 <windows|linux-amd64|linux-arm64|macos> <dest>" >&2
+  exit 1
+fi
+PLATFORM="$1"
+DEST="$2"
+
+linux_url() {
+  if [[ -z "${FFMPEG_LINUX_RELEASE:-}" ]]; then
+    echo "ERROR: FFMPEG_LINUX_RELEASE is not set, the Linux ffmpeg build is not pinned" >&2
+    exit 1
+  fi
+  local tag="${FFMPEG_LINUX_RELEASE%%/*}" name="${FFMPEG_LINUX_RELEASE#*/}"
+  echo "https://github.com/BtbN/FFmpeg-Builds/releases/download/${tag}/${name}-$1-gpl-${FFMPEG_VERSION}.tar.xz"
+}
+
+case "$PLATFORM" in
+  windows)
+    # Same build as the one downloaded by replays itself (recording.FfmpegBuild).
+    URL="https://github.com/GyanD/codexffmpeg/releases/download/${FFMPEG_VERSION}/ffmpeg-${FFMPEG_VERSION}-full_build.zip"
+    EXPECTED_SHA256="${FFMPEG_SHA256_WINDOWS:-}"
+    EXE=".exe"
+    ;;
+  linux-amd64)
+    URL="$(linux_url linux64)"
+    EXPECTED_SHA256="${FFMPEG_SHA256_LINUX_AMD64:-}"
+    EXE=""
+    ;;
+  linux-arm64)
+    URL="$(linux_url linuxarm64)"
+    EXPECTED_SHA256="${FFMPEG_SHA256_LINUX_ARM64:-}"
+    EXE=""
+    ;;
+  macos)
+    URL="https://evermeet.cx/ffmpeg/ffmpeg-${FFMPEG_VERSION}.zip"
+    EXPECTED_SHA256="${FFMPEG_SHA256_MACOS:-}"
+    EXE=""
+    ;;
+  *)
+    echo "Unknown platform: $PLATFORM" >&2
+    exit 1
+    ;;
+esac
+
+WORK="$(mktemp -d)"
+trap 'rm -rf "$WORK"' EXIT
+
+ARCHIVE="$WORK/$(basename "$URL")"
+echo "Downloading $URL"
+curl -fsSL --retry 3 -o "$ARCHIVE" "$URL"
+
+if command -v sha256sum >/dev/null 2>&1; then
+  SUM="$(sha256sum "$ARCHIVE" | cut -d' ' -f1)"
+else
+  SUM="$(shasum -a 256 "$ARCHIVE" | cut -d' ' -f1)"
+fi
+echo "sha256 $SUM  $(basename "$URL")"
+if [[ -z "$EXPECTED_SHA256" ]]; then
+  echo "ERROR: no checksum is pinned for $PLATFORM" >&2
+  exit 1
+fi
+if [[ "$SUM" != "$EXPECTED_SHA256" ]]; then
+  echo "ERROR: checksum mismatch, expected $EXPECTED_SHA256" >&2
+  exit 1
+fi
+
+mkdir -p "$WORK/x"
+case "$ARCHIVE" in
+  *.zip) unzip -q "$ARCHIVE" -d "$WORK/x" ;;
+  *.tar.xz) tar -xJf "$ARCHIVE" -C "$WORK/x" ;;
+esac
+
+mkdir -p "$DEST/ffmpeg/bin"
+for tool in ffmpeg ffprobe ffplay; do
+  found="$(find "$WORK/x" -type f -name "${tool}${EXE}" | head -n 1)"
+  if [[ -z "$found" ]]; then
+    if [[ "$tool" == "ffmpeg" ]]; then
+      echo "ERROR: ${tool}${EXE} not found in the archive" >&2
+      exit 1
+    fi
+    echo "Note: ${tool}${EXE} is not part of this build"
+    continue
+  fi
+  cp "$found" "$DEST/ffmpeg/bin/"
+  chmod +x "$DEST/ffmpeg/bin/${tool}${EXE}"
+done
+
+# Set SKIP_RUN_CHECK=true when the build cannot run on this machine (e.g. the
+# arm64 build on an amd64 runner).
+if [[ "${SKIP_RUN_CHECK:-false}" != "true" ]]; then
+  VERSION_LINE="$("$DEST/ffmpeg/bin/ffmpeg${EXE}" -hide_banner -version | head -n 1)"
+  echo "$VERSION_LINE"
+  if [[ "$VERSION_LINE" != *"${FFMPEG_VERSION}"* ]]; then
+    echo "ERROR: expected ffmpeg ${FFMPEG_VERSION}" >&2
+    exit 1
+  fi
+  if ! "$DEST/ffmpeg/bin/ffmpeg${EXE}" -hide_banner -encoders 2>/dev/null | grep -q libx264; then
+    echo "ERROR: this ffmpeg has no libx264 encoder" >&2
+    exit 1
+  fi
+fi
+echo "ffmpeg ready in $DEST/ffmpeg/bin"
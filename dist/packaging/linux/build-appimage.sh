@@ -0,0 +1,44 @@
+#!/usr/bin/env bash
+
+set -euo pipefail
+
+# Builds owlcms-replays-<version>-<arch>.AppImage. The AppImage starts replays;
+# "owlcms-replays.AppImage cameras" starts cameras. The bundled ffmpeg is used.
+#
+# Usage: dist/packaging/linux/build-appimage.sh <amd64|arm64> <version> <binaries dir> <out dir>
+# Needs appimagetool on the PATH.
+
+if [[ $# -ne 4 ]]; then
+  echo "Usage: $0 <amd64|arm64> <version> <binaries dir> <out dir>" >&2
+  exit 1
+fi
+ARCH="$1"
+VERSION="$2"
+BIN_DIR="$3"
+OUT_DIR="$4"
+
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+REPO_ROOT="$(cd "$SCRIPT_DIR/../../.." && pwd)"
+
+APPDIR="$(mktemp -d)/owlcms-replays.AppDir"
+trap 'rm -rf "$(dirname "$APPDIR")"' EXIT
+mkdir -p "$APPDIR"
+
+install -m 0755 "$BIN_DIR/replays_linux_${ARCH}" "$APPDIR/replays"
+install -m 0755 "$BIN_DIR/cameras_linux_${ARCH}" "$APPDIR/cameras"
+install -m 0755 "$SCRIPT_DIR/AppRun" "$APPDIR/AppRun"
+# The AppImage is mounted read-only: config and logs go to
+# ~/.local/share/owlcms-replays.
+touch "$APPDIR/owlcms-package"
+sed 's|^Exec=.*|Exec=replays|' "$SCRIPT_DIR/owlcms-replays.desktop" > "$APPDIR/owlcms-replays.desktop"
+cp "$REPO_ROOT/Icon.png" "$APPDIR/owlcms-replays.png"
+
+SKIP_RUN_CHECK="${SKIP_RUN_CHECK:-false}" "$SCRIPT_DIR/../fetch-ffmpeg.sh" "linux-${ARCH}" "$APPDIR"
+
+case "$ARCH" in
+  amd64) APPIMAGE_ARCH="x86_64" ;;
+  arm64) APPIMAGE_ARCH="aarch64" ;;
+esac
+
+mkdir -p "$OUT_DIR"
+ARCH="$APPIMAGE_ARCH" appimagetool "$APPDIR" "$OUT_DIR/owlcms-replays-${VERSION#v}-${ARCH}.AppImage"
@@ -0,0 +1,56 @@
+#!/usr/bin/env bash
+
+set -euo pipefail
+
+# Builds owlcms-replays_<version>_<arch>.deb with replays, cameras and the
+# bundled ffmpeg under /opt/owlcms-replays, and menu entries for both.
+#
+# Usage: dist/packaging/linux/build-deb.sh <amd64|arm64> <version> <binaries dir> <out dir>
+# The binaries dir holds replays_linux_<arch> and cameras_linux_<arch>, as
+# produced by the release workflow.
+
+if [[ $# -ne 4 ]]; then
+  echo "Usage: $0 <amd64|arm64> <version> <binaries dir> <out dir>" >&2
+  exit 1
+fi
+ARCH="$1"
+VERSION="$2"
+BIN_DIR="$3"
+OUT_DIR="$4"
+
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+REPO_ROOT="$(cd "$SCRIPT_DIR/../../.." && pwd)"
+
+ROOT="$(mktemp -d)"
+trap 'rm -rf "$ROOT"' EXIT
+APP="$ROOT/opt/owlcms-replays"
+
+mkdir -p "$APP" "$ROOT/usr/bin" "$ROOT/usr/share/applications" \
+  "$ROOT/usr/share/icons/hicolor/256x256/apps" "$ROOT/DEBIAN"
+install -m 0755 "$BIN_DIR/replays_linux_${ARCH}" "$APP/replays"
+install -m 0755 "$BIN_DIR/cameras_linux_${ARCH}" "$APP/cameras"
+# Marks a packaged install: config and logs go to ~/.local/share/owlcms-replays.
+touch "$APP/owlcms-package"
+
+SKIP_RUN_CHECK="${SKIP_RUN_CHECK:-false}" "$SCRIPT_DIR/../fetch-ffmpeg.sh" "linux-${ARCH}" "$APP"
+
+ln -s /opt/owlcms-replays/replays "$ROOT/usr/bin/owlcms-replays"
+ln -s /opt/owlcms-replays/cameras "$ROOT/usr/bin/owlcms-cameras"
+install -m 0644 "$SCRIPT_DIR/owlcms-replays.desktop" "$SCRIPT_DIR/owlcms-cameras.desktop" "$ROOT/usr/share/applications/"
+install -m 0644 "$REPO_ROOT/Icon.png" "$ROOT/usr/share/icons/hicolor/256x256/apps/owlcms-replays.png"
+
+cat > "$ROOT/DEBIAN/control" <<CONTROL
+Package: owlcms-replays
+Version: ${VERSION#v}
+Architecture: ${ARCH}
+Maintainer: owlcms <owlcms@users.noreply.github.com>
+Depends: v4l-utils
+Section: video
+Priority: optional
+Description: Jury replays for owlcms
+ Records the camera streams during each attempt and publishes the replays
+ to the jury. Includes the cameras streaming program and ffmpeg.
+CONTROL
+
+mkdir -p "$OUT_DIR"
+dpkg-deb --build --root-owner-group "$ROOT" "$OUT_DIR/owlcms-replays_${VERSION#v}_${ARCH}.deb"
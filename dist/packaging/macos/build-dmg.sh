@@ -0,0 +1,47 @@
+#!/usr/bin/env bash
+
+set -euo pipefail
+
+# Builds owlcms-replays-<version>.dmg with "owlcms Replays.app" and
+# "owlcms Cameras.app", each with the bundled ffmpeg in Contents/Resources.
+# Runs on macOS; needs Go, the fyne command (go install
+# fyne.io/fyne/v2/cmd/fyne@v2.5.4) and the Xcode command line tools.
+#
+# Usage: dist/packaging/macos/build-dmg.sh <version> <out dir>
+
+if [[ $# -ne 2 ]]; then
+  echo "Usage: $0 <version> <out dir>" >&2
+  exit 1
+fi
+VERSION="${1#v}"
+OUT_DIR="$(mkdir -p "$2" && cd "$2" && pwd)"
+
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+REPO_ROOT="$(cd "$SCRIPT_DIR/../../.." && pwd)"
+APP_VERSION="${VERSION%%-*}"
+
+WORK="$(mktemp -d)"
+trap 'rm -rf "$WORK"' EXIT
+mkdir -p "$WORK/dmg"
+
+"$SCRIPT_DIR/../fetch-ffmpeg.sh" macos "$WORK"
+
+package_app() {
+  local program="$1" name="$2" app_id="$3"
+  (cd "$REPO_ROOT/cmd/$program" && fyne package -os darwin -name "$name" \
+    -icon "$REPO_ROOT/Icon.png" -appID "$app_id" -appVersion "$APP_VERSION" -release)
+  local app="$REPO_ROOT/cmd/$program/$name.app"
+  mkdir -p "$app/Contents/Resources"
+  cp -R "$WORK/ffmpeg" "$app/Contents/Resources/"
+  # Marks a packaged install: config and logs go to
+  # ~/Library/Application Support/owlcms-replays.
+  touch "$app/Contents/MacOS/owlcms-package"
+  mv "$app" "$WORK/dmg/"
+}
+
+package_app replays "owlcms Replays" app.owlcms.replays
+package_app cameras "owlcms Cameras" app.owlcms.cameras
+ln -s /Applications "$WORK/dmg/Applications"
+
+hdiutil create -volname "owlcms Jury Replays" -srcfolder "$WORK/dmg" -ov -format UDZO \
+  "$OUT_DIR/owlcms-replays-${VERSION}.dmg"
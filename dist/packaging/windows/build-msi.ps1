@@ -0,0 +1,52 @@
+# Builds owlcms-replays-<version>.msi with replays, cameras, the bundled ffmpeg
+# and Start menu shortcuts.
+#
+# Usage: dist\packaging\windows\build-msi.ps1 -Version 2.4.0 -BinDir dist -OutDir installers
+# BinDir holds replays_windows.exe and cameras_windows.exe, as produced by the
+# release workflow. Needs the WiX Toolset v5 (dotnet tool install --global wix)
+# and bash with curl and unzip (Git for Windows) for fetch-ffmpeg.sh.
+
+param(
+    [Parameter(Mandatory = $true)][string]$Version,
+    [Parameter(Mandatory = $true)][string]$BinDir,
+    [Parameter(Mandatory = $true)][string]$OutDir
+)
+
+$ErrorActionPreference = "Stop"
+$scriptDir = Split-Path -Parent $MyInvocation.MyCommand.Path
+$repoRoot = Resolve-Path (Join-Path $scriptDir "..\..\..")
+$msiVersion = ($Version -replace '^v', '') -replace '-.*$', ''
+
+$stage = Join-Path ([System.IO.Path]::GetTempPath()) ("owlcms-replays-msi-" + [guid]::NewGuid())
+New-Item -ItemType Directory -Path $stage | Out-Null
+try {
+    Copy-Item (Join-Path $BinDir "replays_windows.exe") (Join-Path $stage "replays.exe")
+    Copy-Item (Join-Path $BinDir "cameras_windows.exe") (Join-Path $stage "cameras.exe")
+    New-Item -ItemType File -Path (Join-Path $stage "owlcms-package") | Out-Null
+
+    # The .ico is made from Icon.png by embedding the PNG (Vista+ icon format).
+    $png = [System.IO.File]::ReadAllBytes((Join-Path $repoRoot "Icon.png"))
+    $ico = New-Object System.IO.MemoryStream
+    $writer = New-Object System.IO.BinaryWriter($ico)
+    $writer.Write([UInt16]0); $writer.Write([UInt16]1); $writer.Write([UInt16]1)
+    # Width and height come from the PNG header; 0 means 256 pixels.
+    $width = [Byte]([Math]::Min(($png[18] * 256 + $png[19]), 256) % 256)
+    $height = [Byte]([Math]::Min(($png[22] * 256 + $png[23]), 256) % 256)
+    $writer.Write($width); $writer.Write($height); $writer.Write([Byte]0); $writer.Write([Byte]0)
+    $writer.Write([UInt16]1); $writer.Write([UInt16]32)
+    $writer.Write([UInt32]$png.Length); $writer.Write([UInt32]22)
+    $writer.Write($png)
+    [System.IO.File]::WriteAllBytes((Join-Path $stage "Icon.ico"), $ico.ToArray())
+
+    & bash (Join-Path $scriptDir "../fetch-ffmpeg.sh") windows ($stage -replace '\\', '/')
+    if ($LASTEXITCODE -ne 0) { throw "fetching ffmpeg failed" }
+
+    New-Item -ItemType Directory -Force -Path $OutDir | Out-Null
+    $msi = Join-Path $OutDir ("owlcms-replays-" + ($Version -replace '^v', '') + ".msi")
+    wix build (Join-Path $scriptDir "owlcms-replays.wxs") -arch x64 -d "Version=$msiVersion" -bindpath "stage=$stage" -o $msi
+    if ($LASTEXITCODE -ne 0) { throw "wix build failed" }
+    Write-Host "Built $msi"
+}
+finally {
+    Remove-Item -Recurse -Force $stage
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// ConfigBackupsKept is how many backups are kept per config file.
+const ConfigBackupsKept = 20
+
+const configBackupDir = "backups"
+const diffContextLines = 3
+
+// WriteConfigFile replaces a config file, first saving the previous content
+// to a timestamped backup next to it (in a backups subdirectory) and logging
+// a unified diff of the change. Older backups beyond ConfigBackupsKept are
+// removed.
+func WriteConfigFile(path string, content []byte, perm os.FileMode) error {
+	previous, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if string(previous) == string(content) {
+			return nil
+		}
+		backupPath, backupErr := backupConfigFile(path, previous)
+		if backupErr != nil {
+			logging.WarningLogger.Printf("Failed to back up %s before writing: %v", path, backupErr)
+		} else {
+			logging.InfoLogger.Printf("Backed up %s to %s", path, backupPath)
+		}
+		if diff := unifiedDiff(filepath.Base(path)+" (before)", filepath.Base(path)+" (after)",
+			string(previous), string(content)); diff != "" {
+			logging.InfoLogger.Printf("Changes written to %s:\n%s", path, diff)
+		}
+	case os.IsNotExist(err):
+		logging.InfoLogger.Printf("Creating %s", path)
+	default:
+		return err
+	}
+	return os.WriteFile(path, content, perm)
+}
+
+func backupConfigFile(path string, content []byte) (string, error) {
+	dir := filepath.Join(filepath.Dir(path), configBackupDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Base(path)
+	stamp := time.Now().Format("20060102_150405.000")
+	backupPath := filepath.Join(dir, fmt.Sprintf("%s.%s.bak", base, stamp))
+	for n := 1; fileExists(backupPath); n++ {
+		backupPath = filepath.Join(dir, fmt.Sprintf("%s.%s_%d.bak", base, stamp, n))
+	}
+	if err := os.WriteFile(backupPath, content, 0644); err != nil {
+		return "", err
+	}
+	pruneConfigBackups(dir, base, ConfigBackupsKept)
+	return backupPath, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// pruneConfigBackups keeps the newest keep backups of base. Backup names sort
+// chronologically because of their timestamp format.
+func pruneConfigBackups(dir, base string, keep int) {
+	matches, err := filepath.Glob(filepath.Join(dir, base+".*.bak"))
+	if err != nil || len(matches) <= keep {
+		return
+	}
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-keep] {
+		if err := os.Remove(old); err != nil {
+			logging.WarningLogger.Printf("Failed to remove old config backup %s: %v", old, err)
+		}
+	}
+}
+
+// unifiedDiff returns a unified diff of two texts, or "" if they are equal.
+// Config files are small, so a plain LCS table is good enough.
+func unifiedDiff(fromName, toName, from, to string) string {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte
+		text string
+		ai   int // index in a of this line (or of the next a line for inserts)
+		bi   int
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// Grow the hunk until there are more than 2*context unchanged lines.
+		hunkStart := start - diffContextLines
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		end := start
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].op == ' ' {
+				run++
+			}
+			if run == len(lines) || run-end > 2*diffContextLines {
+				if run-end > diffContextLines {
+					run = end + diffContextLines
+				}
+				end = run
+				break
+			}
+			end = run
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		aCount, bCount := 0, 0
+		for _, l := range lines[hunkStart:end] {
+			if l.op != '+' {
+				aCount++
+			}
+			if l.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lines[hunkStart].ai+1, aCount, lines[hunkStart].bi+1, bCount)
+		for _, l := range lines[hunkStart:end] {
+			fmt.Fprintf(&out, "%c%s\n", l.op, l.text)
+		}
+		start = end
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteConfigFileKeepsRotatedBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("port = 8091\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < ConfigBackupsKept+3; i++ {
+		content := []byte(strings.Repeat("#\n", i) + "port = 8092\n")
+		if err := WriteConfigFile(path, content, 0644); err != nil {
+			t.Fatalf("WriteConfigFile: %v", err)
+		}
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "backups", "config.toml.*.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != ConfigBackupsKept {
+		t.Fatalf("expected %d backups, got %d", ConfigBackupsKept, len(backups))
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	to := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\n"
+	want := "--- old\n+++ new\n@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h"
+	if got := unifiedDiff("old", "new", from, to); got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := unifiedDiff("old", "new", from, from); got != "" {
+		t.Fatalf("expected empty diff, got %q", got)
+	}
+}
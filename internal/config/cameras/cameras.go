@@ -30,7 +30,11 @@ type Config struct {
 	Multicast         MulticastConfig    `toml:"multicast"`
 	Unicast           UnicastConfig      `toml:"unicast"`
 	Cameras           CamerasSettings    `toml:"cameras"`
+	LocalRecording    LocalRecording     `toml:"localRecording"`
+	SRT               SRTConfig          `toml:"srt"`
+	Control           ControlSettings    `toml:"control"`
 	RTSPSources       []RTSPSource       `toml:"rtsp"`
+	ScreenSources     []ScreenSource     `toml:"screen"`
 	DeviceAssignments []DeviceAssignment `toml:"deviceAssignment"`
 }
 
@@ -65,6 +69,44 @@ type UnicastConfig struct {
 // CamerasSettings holds per-instance camera behaviour flags.
 type CamerasSettings struct {
 	IncludeAll bool `toml:"includeAll"`
+	// Codec of the streams this node encodes (MJPEG and raw cameras, screens):
+	// "h264" (default) or "hevc", which needs an HEVC encoder in ffmpeg.toml
+	// that works on this machine. Cameras that send H.264 are copied.
+	Codec string `toml:"codec"`
+}
+
+// LocalRecording keeps the last minutes of every stream on the camera node's
+// disk, as a second source of footage when the replays machine missed it.
+// Files are written in wrapping segments so the disk use stays bounded, and
+// are served to replays over HTTP on HTTPPort.
+type LocalRecording struct {
+	Enabled        bool   `toml:"enabled"`
+	Minutes        int    `toml:"minutes"`
+	SegmentSeconds int    `toml:"segmentSeconds"`
+	Dir            string `toml:"dir"`
+	HTTPPort       int    `toml:"httpPort"`
+}
+
+// SRTConfig serves every stream over SRT as well, for receivers on lossy
+// networks (Wi-Fi) where UDP drops packets. SRT sends lost packets again
+// within LatencyMs. Each stream is served on its port + config.SRTPortOffset
+// to one replays machine at a time, which connects to it.
+type SRTConfig struct {
+	Enabled    bool   `toml:"enabled"`
+	LatencyMs  int    `toml:"latencyMs"`
+	Passphrase string `toml:"passphrase"`
+}
+
+// ControlSettings connect the node to a replays machine, which can then
+// start, stop and restart its streams, change their bitrate and read its log.
+type ControlSettings struct {
+	// Replays is the host:port of the replays web server. Empty disables
+	// remote control.
+	Replays string `toml:"replays"`
+	// Token must match controlToken in the replays config.toml when set.
+	Token string `toml:"token"`
+	// NodeName identifies this node in replays; empty uses the host name.
+	NodeName string `toml:"nodeName"`
 }
 
 // RTSPSource defines one configured RTSP input that should be republished.
@@ -84,6 +126,36 @@ type RTSPSource struct {
 	DirtyReasons []string `toml:"dirtyReasons,omitempty"`
 }
 
+// ScreenSource captures a screen, such as the one showing the owlcms
+// scoreboard or attempt board, and streams it like a camera.
+type ScreenSource struct {
+	SourceID string `toml:"sourceId"`
+	Name     string `toml:"name"`
+	ShortID  string `toml:"shortId"`
+	Enabled  bool   `toml:"enabled"`
+	On       *bool  `toml:"on,omitempty"`
+	// Display is "desktop" (or "title=<window title>") on Windows, the X
+	// display (":0.0") on Linux and the screen device ("Capture screen 0") on
+	// macOS. Empty selects the main screen.
+	Display string `toml:"display"`
+	// Region optionally limits the capture to WIDTHxHEIGHT+X+Y.
+	Region     string `toml:"region"`
+	Fps        int    `toml:"fps"`
+	OutputPort int    `toml:"outputPort"`
+}
+
+// ScreenRegion parses Region. ok is false when no region is set.
+func (s ScreenSource) ScreenRegion() (width, height, x, y int, ok bool, err error) {
+	region := strings.TrimSpace(s.Region)
+	if region == "" {
+		return 0, 0, 0, 0, false, nil
+	}
+	if _, scanErr := fmt.Sscanf(region, "%dx%d+%d+%d", &width, &height, &x, &y); scanErr != nil || width <= 0 || height <= 0 || x < 0 || y < 0 {
+		return 0, 0, 0, 0, false, fmt.Errorf("invalid screen region %q, expected WIDTHxHEIGHT+X+Y", s.Region)
+	}
+	return width, height, x, y, true, nil
+}
+
 // DeviceAssignment persists operator-facing metadata for one autodetected USB source.
 type DeviceAssignment struct {
 	AttachmentPath       string   `toml:"attachmentPath,omitempty"`
@@ -139,6 +211,10 @@ func loadConfigFile(path string, rememberSource bool) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
 	}
 
+	if err := config.CheckSRTPassphrase(cfg.SRT.Passphrase); err != nil {
+		return nil, fmt.Errorf("invalid [srt] passphrase in %s: %w", path, err)
+	}
+
 	logging.InfoLogger.Printf("Loading cameras instance config from %s", path)
 	cfg.applyDefaults()
 	if rememberSource {
@@ -237,7 +313,7 @@ func SaveConfig(cfg *Config) error {
 	cfg.applyDefaults()
 	cfg.ensureSourceIDs()
 	content := cfg.serialize()
-	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+	if err := config.WriteConfigFile(configPath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write cameras config: %w", err)
 	}
 	configSourcePath = configPath
@@ -328,6 +404,36 @@ func (c *Config) applyDefaults() {
 	if c.Unicast.StartPort == 0 {
 		c.Unicast.StartPort = 9001
 	}
+	if c.LocalRecording.Minutes <= 0 {
+		c.LocalRecording.Minutes = 10
+	}
+	if c.LocalRecording.SegmentSeconds <= 0 {
+		c.LocalRecording.SegmentSeconds = 60
+	}
+	if strings.TrimSpace(c.LocalRecording.Dir) == "" {
+		c.LocalRecording.Dir = filepath.Join(config.GetInstallDir(), "localRecording")
+	}
+	if c.LocalRecording.HTTPPort == 0 {
+		c.LocalRecording.HTTPPort = 9180
+	}
+	if c.SRT.LatencyMs <= 0 {
+		c.SRT.LatencyMs = config.DefaultSRTLatency
+	}
+	c.Cameras.Codec = strings.ToLower(strings.TrimSpace(c.Cameras.Codec))
+	if !ffmpeg.ValidCodec(c.Cameras.Codec) {
+		if c.Cameras.Codec != "" {
+			logging.WarningLogger.Printf("cameras codec %q is not one of %v, using %s", c.Cameras.Codec, ffmpeg.Codecs, ffmpeg.Codecs[0])
+		}
+		c.Cameras.Codec = ffmpeg.Codecs[0]
+	}
+	for i := range c.ScreenSources {
+		if c.ScreenSources[i].On == nil {
+			c.ScreenSources[i].On = boolPtr(true)
+		}
+		if c.ScreenSources[i].Fps <= 0 {
+			c.ScreenSources[i].Fps = 10
+		}
+	}
 	for i := range c.RTSPSources {
 		if c.RTSPSources[i].On == nil {
 			c.RTSPSources[i].On = boolPtr(true)
@@ -448,6 +554,20 @@ func (c *Config) ensureSourceIDs() {
 			src.Transport = "tcp"
 		}
 	}
+	for i := range c.ScreenSources {
+		src := &c.ScreenSources[i]
+		id := strings.TrimSpace(src.SourceID)
+		if _, exists := used[strings.ToLower(id)]; id == "" || exists {
+			for n := i + 1; ; n++ {
+				id = fmt.Sprintf("screen-%d", n)
+				if _, taken := used[id]; !taken {
+					break
+				}
+			}
+			src.SourceID = id
+		}
+		used[strings.ToLower(id)] = struct{}{}
+	}
 }
 
 func generateRTSPSourceID(rtspURL string, index int, used map[string]struct{}) string {
@@ -512,6 +632,24 @@ func (c *Config) serialize() string {
 
 	buf.WriteString("[cameras]\n")
 	buf.WriteString(fmt.Sprintf("    includeAll = %t\n", c.Cameras.IncludeAll))
+	buf.WriteString(fmt.Sprintf("    codec = %s\n", strconv.Quote(c.Cameras.Codec)))
+
+	buf.WriteString("\n[localRecording]\n")
+	buf.WriteString(fmt.Sprintf("    enabled = %t\n", c.LocalRecording.Enabled))
+	buf.WriteString(fmt.Sprintf("    minutes = %d\n", c.LocalRecording.Minutes))
+	buf.WriteString(fmt.Sprintf("    segmentSeconds = %d\n", c.LocalRecording.SegmentSeconds))
+	buf.WriteString(fmt.Sprintf("    dir = %s\n", strconv.Quote(c.LocalRecording.Dir)))
+	buf.WriteString(fmt.Sprintf("    httpPort = %d\n", c.LocalRecording.HTTPPort))
+
+	buf.WriteString("\n[srt]\n")
+	buf.WriteString(fmt.Sprintf("    enabled = %t\n", c.SRT.Enabled))
+	buf.WriteString(fmt.Sprintf("    latencyMs = %d\n", c.SRT.LatencyMs))
+	buf.WriteString(fmt.Sprintf("    passphrase = %s\n", strconv.Quote(c.SRT.Passphrase)))
+
+	buf.WriteString("\n[control]\n")
+	buf.WriteString(fmt.Sprintf("    replays = %s\n", strconv.Quote(c.Control.Replays)))
+	buf.WriteString(fmt.Sprintf("    token = %s\n", strconv.Quote(c.Control.Token)))
+	buf.WriteString(fmt.Sprintf("    nodeName = %s\n", strconv.Quote(c.Control.NodeName)))
 
 	for _, assignment := range c.DeviceAssignments {
 		if strings.TrimSpace(assignment.MatchKey) == "" && strings.TrimSpace(assignment.AttachmentPath) == "" {
@@ -586,6 +724,25 @@ func (c *Config) serialize() string {
 		}
 	}
 
+	for _, source := range c.ScreenSources {
+		buf.WriteString("\n[[screen]]\n")
+		buf.WriteString(fmt.Sprintf("    sourceId = %s\n", strconv.Quote(source.SourceID)))
+		buf.WriteString(fmt.Sprintf("    name = %s\n", strconv.Quote(source.Name)))
+		if strings.TrimSpace(source.ShortID) != "" {
+			buf.WriteString(fmt.Sprintf("    shortId = %s\n", strconv.Quote(source.ShortID)))
+		}
+		buf.WriteString(fmt.Sprintf("    enabled = %t\n", source.Enabled))
+		buf.WriteString(fmt.Sprintf("    on = %t\n", monitoringOn(source.On)))
+		buf.WriteString(fmt.Sprintf("    display = %s\n", strconv.Quote(source.Display)))
+		if strings.TrimSpace(source.Region) != "" {
+			buf.WriteString(fmt.Sprintf("    region = %s\n", strconv.Quote(source.Region)))
+		}
+		buf.WriteString(fmt.Sprintf("    fps = %d\n", source.Fps))
+		if source.OutputPort > 0 {
+			buf.WriteString(fmt.Sprintf("    outputPort = %d\n", source.OutputPort))
+		}
+	}
+
 	return buf.String() + "\n"
 }
 
@@ -629,6 +786,45 @@ func (c *UnicastConfig) TeeOutput(port int) string {
 	return strings.Join(legs, "|")
 }
 
+// Segments returns how many segment files are kept per stream to cover the
+// configured minutes.
+func (l *LocalRecording) Segments() int {
+	segments := (l.Minutes*60 + l.SegmentSeconds - 1) / l.SegmentSeconds
+	if segments < 2 {
+		segments = 2
+	}
+	return segments
+}
+
+// SegmentGlob matches the segment files of the stream on the given port.
+func (l *LocalRecording) SegmentGlob(port int) string {
+	return filepath.Join(l.Dir, fmt.Sprintf("port%d_*.ts", port))
+}
+
+// TeeLeg builds the ffmpeg tee leg that records the stream on the given port
+// into wrapping segments. Timestamps run on across segments so that
+// consecutive files can be served as one stream. Forward slashes are used
+// because backslashes are escape characters in tee leg syntax; ffmpeg
+// accepts them on Windows too.
+func (l *LocalRecording) TeeLeg(port int) string {
+	pattern := filepath.ToSlash(filepath.Join(l.Dir, fmt.Sprintf("port%d_%%03d.ts", port)))
+	return fmt.Sprintf("[f=segment:segment_format=mpegts:segment_time=%d:segment_wrap=%d:onfail=ignore]%s",
+		l.SegmentSeconds, l.Segments(), pattern)
+}
+
+// TeeLeg builds the ffmpeg tee leg that serves the stream on the given port
+// over SRT. The leg goes through a fifo so that waiting for replays to
+// connect does not hold up the other legs, and it starts listening again
+// after replays disconnects.
+func (s *SRTConfig) TeeLeg(port int) string {
+	return fmt.Sprintf(`[f=mpegts:onfail=ignore:use_fifo=1:fifo_options=attempt_recovery=1\:recover_any_error=1\:recovery_wait_time=1\:drop_pkts_on_overflow=1]%s`,
+		teeEscaper.Replace(config.SRTURL("0.0.0.0", port+config.SRTPortOffset, "listener", s.LatencyMs, s.Passphrase)))
+}
+
+// teeEscaper escapes the characters that the tee muxer splits its outputs on
+// or unquotes.
+var teeEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `|`, `\|`)
+
 // NormalizeUnicastDestinationAddress canonicalizes loopback destinations so
 // preview can always listen on a stable local IPv4 address.
 func NormalizeUnicastDestinationAddress(address string) string {
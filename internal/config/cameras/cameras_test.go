@@ -181,3 +181,27 @@ func TestClearRestartDirtyReasonsRemovesRestartOnly(t *testing.T) {
 		t.Fatalf("expected second clear to report no changes")
 	}
 }
+
+func TestApplyDefaultsValidatesTheStreamCodec(t *testing.T) {
+	for codec, want := range map[string]string{"": "h264", " HEVC ": "hevc", "av1": "h264"} {
+		cfg := &Config{Cameras: CamerasSettings{Codec: codec}}
+		cfg.applyDefaults()
+		if cfg.Cameras.Codec != want {
+			t.Fatalf("codec %q became %q, want %q", codec, cfg.Cameras.Codec, want)
+		}
+		if !strings.Contains(cfg.serialize(), "    codec = \""+want+"\"") {
+			t.Fatalf("serialized config does not keep codec %q", want)
+		}
+	}
+}
+
+func TestSRTTeeLegEscapesThePassphrase(t *testing.T) {
+	srt := SRTConfig{LatencyMs: 200, Passphrase: `it's|a\secret`}
+	leg := srt.TeeLeg(9001)
+	if !strings.HasSuffix(leg, `]srt://0.0.0.0:10001?mode=listener&latency=200000&pkt_size=1316&passphrase=it%27s%7Ca%5Csecret`) {
+		t.Fatalf("TeeLeg() = %s", leg)
+	}
+	if got := teeEscaper.Replace(`a|b'c\d`); got != `a\|b\'c\\d` {
+		t.Fatalf("teeEscaper = %s", got)
+	}
+}
@@ -2,11 +2,16 @@ package config
 
 import (
 	"fmt"
+	"math"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/owlcms/replays/internal/logging"
 )
@@ -23,6 +28,285 @@ type CameraConfiguration struct {
 	Size             string `toml:"size"`
 	Fps              int    `toml:"fps"`
 	Recode           bool   `toml:"recode"`
+	// Audio records sound with the camera; nil records video only.
+	Audio *AudioConfiguration `toml:"audio,omitempty"`
+	// TrimOffsetMs is how much later than the fastest camera this camera
+	// shows the platform, measured by calibration; trimmed replays start
+	// that much closer to the end of the recording.
+	TrimOffsetMs int `toml:"trimOffsetMs"`
+	// FrameAccurate starts replays on the exact frame instead of the nearest
+	// keyframe, re-encoding the frames before the first keyframe.
+	FrameAccurate bool `toml:"frameAccurate"`
+	// Trim overrides how the replays of the camera are trimmed; nil uses
+	// the camera's recode setting and the [x264] settings.
+	Trim *TrimProfile `toml:"trim,omitempty"`
+	// Overlay is the text burned into the replay being trimmed, set for
+	// each attempt when Overlay is on.
+	Overlay string `toml:"-"`
+	// ScoreFilter draws the clock and decision lights of the attempt onto
+	// the replay being trimmed, set for each attempt when ScoreOverlay is on.
+	ScoreFilter string `toml:"-"`
+	// Timecode is the wall-clock time of the first frame of the replay being
+	// trimmed, burned in when Timecode is on; zero for none.
+	Timecode time.Time `toml:"-"`
+	// Proxy trims the replay as a stream copy whatever would re-encode it,
+	// the re-encode being deferred until no attempt is running.
+	Proxy bool `toml:"-"`
+}
+
+// TrimProfile sets how the replays of one camera are trimmed. Crf, Preset,
+// Crop and Scale re-encode the replay with libx264, so that a 4K camera can be
+// downscaled, or a wide venue shot reframed on the platform, while the other
+// cameras are copied; Container is the file type of the replays, and Codec
+// re-encodes them for an editing workflow that needs another codec.
+type TrimProfile struct {
+	Camera    int    `toml:"camera"`
+	Crf       int    `toml:"crf"`
+	Preset    string `toml:"preset"`
+	Crop      string `toml:"crop"`  // region kept, "x,y,w,h" in pixels of the camera picture
+	Scale     string `toml:"scale"` // size given to the ffmpeg scale filter, such as "1920:-2"
+	Container string `toml:"container"`
+	Codec     string `toml:"codec"`
+}
+
+// TrimContainers are the file types replays can be trimmed into; the first is
+// the default.
+var TrimContainers = []string{"mp4", "mov", "mkv"}
+
+// TrimCodecs are the video codecs replays can be re-encoded with; the first is
+// the default, and the only one that keeps copied replays copied.
+var TrimCodecs = []string{"h264", "hevc", "prores"}
+
+// Recodes reports whether the profile re-encodes the replays.
+func (p *TrimProfile) Recodes() bool {
+	return p != nil && (p.Crf > 0 || p.Preset != "" || p.Crop != "" || p.Scale != "" || p.VideoCodec() != TrimCodecs[0])
+}
+
+// VideoCodec returns the codec of the re-encoded replays, "h264" by default.
+func (p *TrimProfile) VideoCodec() string {
+	if p == nil || p.Codec == "" {
+		return TrimCodecs[0]
+	}
+	return p.Codec
+}
+
+// ParseCrop parses a crop region such as "640,120,1280,720": the left and top
+// of the region, then its width and height.
+func ParseCrop(crop string) (x, y, width, height int, err error) {
+	fields := strings.Split(crop, ",")
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		if values[i], err = strconv.Atoi(strings.TrimSpace(field)); err != nil {
+			break
+		}
+	}
+	if err != nil || len(values) != 4 || values[0] < 0 || values[1] < 0 || values[2] <= 0 || values[3] <= 0 {
+		return 0, 0, 0, 0, fmt.Errorf("%q is not a crop region such as \"640,120,1280,720\" (x,y,width,height)", crop)
+	}
+	return values[0], values[1], values[2], values[3], nil
+}
+
+// CropRegion returns the crop region of the trim section of the camera, kept
+// inside the picture when its size is known and rounded down to even numbers,
+// as the yuv420p encoders need. ok is false when there is no region left.
+func (c CameraConfiguration) CropRegion() (x, y, width, height int, ok bool) {
+	if c.Trim == nil || c.Trim.Crop == "" {
+		return 0, 0, 0, 0, false
+	}
+	x, y, width, height, err := ParseCrop(c.Trim.Crop)
+	if err != nil {
+		return 0, 0, 0, 0, false
+	}
+	if frameWidth, frameHeight, err := ParseFrameSize(c.Size); err == nil {
+		if x+width > frameWidth {
+			width = frameWidth - x
+		}
+		if y+height > frameHeight {
+			height = frameHeight - y
+		}
+	}
+	x, y, width, height = x&^1, y&^1, width&^1, height&^1
+	if width <= 0 || height <= 0 {
+		return 0, 0, 0, 0, false
+	}
+	return x, y, width, height, true
+}
+
+// Extension returns the file extension of the replays, ".mp4" by default.
+func (p *TrimProfile) Extension() string {
+	if p == nil || p.Container == "" {
+		return "." + TrimContainers[0]
+	}
+	return "." + p.Container
+}
+
+// Normalizes reports whether the replays of the camera are scaled and padded
+// to ReplaySize, because the camera records another size (or an unknown one).
+// The recorder fills in the size it probed when the camera does not set it.
+func (c CameraConfiguration) Normalizes() bool {
+	return ReplaySize != "" && c.Size != ReplaySize
+}
+
+// TrimRecodes reports whether the replays of the camera are re-encoded with
+// libx264 whatever its recode setting: by its trim section, to normalize
+// their size or to burn in the overlays.
+func (c CameraConfiguration) TrimRecodes() bool {
+	if c.Proxy {
+		return false
+	}
+	return c.Trim.Recodes() || c.Normalizes() || c.Overlay != "" || c.ScoreFilter != "" || !c.Timecode.IsZero()
+}
+
+// TrimFilter returns the ffmpeg video filter of the re-encoded replays of the
+// camera: its crop region, then the replay size or else the scale of its trim
+// section. "" when the picture is kept as recorded.
+func (c CameraConfiguration) TrimFilter() string {
+	var filters []string
+	if x, y, width, height, ok := c.CropRegion(); ok {
+		filters = append(filters, fmt.Sprintf("crop=%d:%d:%d:%d", width, height, x, y))
+	}
+	if c.Normalizes() {
+		// The replay size wins over the scale of the trim section, so that
+		// the replays of all the cameras line up.
+		filters = append(filters, NormalizeFilter(ReplaySize))
+	} else if c.Trim != nil && c.Trim.Scale != "" {
+		filters = append(filters, "scale="+c.Trim.Scale)
+	}
+	if c.Overlay != "" {
+		filters = append(filters, OverlayFilter(c.Overlay))
+	}
+	if c.ScoreFilter != "" {
+		filters = append(filters, c.ScoreFilter)
+	}
+	if !c.Timecode.IsZero() {
+		filters = append(filters, TimecodeFilter(c.Timecode))
+	}
+	return strings.Join(filters, ",")
+}
+
+// TimecodeFilter returns the ffmpeg filter that writes the local wall-clock
+// time of each frame, to the millisecond, in the top left corner of a video
+// whose first frame was taken at start.
+func TimecodeFilter(start time.Time) string {
+	midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	text := fmt.Sprintf("%%{pts:hms:%.3f}", start.Sub(midnight).Seconds())
+	return "drawtext=" + overlayFontOption() + "expansion=normal:text=" + escapeFilterValue(text) +
+		":fontcolor=white:fontsize=h/22:box=1:boxcolor=black@0.6:boxborderw=12:x=24:y=36"
+}
+
+// OverlayFilter returns the ffmpeg filter that writes text in a band at the
+// bottom of the picture, sized on the height of the picture.
+func OverlayFilter(text string) string {
+	return "drawtext=" + overlayFontOption() + "expansion=none:text=" + escapeFilterValue(text) +
+		":fontcolor=white:fontsize=h/22:box=1:boxcolor=black@0.6:boxborderw=12:x=24:y=h-th-36"
+}
+
+// overlayFontOption is the fontfile option of drawtext, "" for the default
+// font of ffmpeg.
+func overlayFontOption() string {
+	if OverlayFont == "" {
+		return ""
+	}
+	return "fontfile=" + escapeFilterValue(filepath.ToSlash(OverlayFont)) + ":"
+}
+
+// ClockSegment is a stretch of a replay during which the attempt clock runs,
+// or stays still.
+type ClockSegment struct {
+	From, To  float64 // seconds into the replay
+	Remaining float64 // seconds left on the clock at From
+	Running   bool
+}
+
+// ScoreboardFilter returns the ffmpeg filter that draws the attempt clock in
+// the top right corner of the picture, and below it the decision lights
+// ("good" or "bad") from decisionAt seconds into the replay, like the
+// graphics of a broadcast. "" when there is nothing to draw.
+func ScoreboardFilter(clock []ClockSegment, decisionAt float64, lights []string) string {
+	var filters []string
+	for _, segment := range clock {
+		text := escapeFilterValue(fmt.Sprintf("%d:%02d", int(math.Ceil(segment.Remaining))/60, int(math.Ceil(segment.Remaining))%60))
+		expansion := "none"
+		if segment.Running {
+			left := fmt.Sprintf("ceil(max(0,%.3f-(t-%.3f)))", segment.Remaining, segment.From)
+			text = escapeFilterValue(fmt.Sprintf("%%{eif:trunc(%s/60):d}:%%{eif:mod(%s,60):d:2}", left, left))
+			expansion = "normal"
+		}
+		filters = append(filters, "drawtext="+overlayFontOption()+"expansion="+expansion+":text="+text+
+			":fontcolor=white:fontsize=h/14:box=1:boxcolor=black@0.6:boxborderw=12:x=w-tw-36:y=36"+
+			":enable="+escapeFilterValue(fmt.Sprintf("between(t,%.3f,%.3f)", segment.From, segment.To)))
+	}
+	for i, light := range lights {
+		color := "white"
+		if light != "good" {
+			color = "red"
+		}
+		// Squares of a sixteenth of the height, a 64th apart, right aligned
+		// under the clock.
+		filters = append(filters, fmt.Sprintf("drawbox=x=iw-36-ih*%d/64:y=36+ih/8:w=ih/16:h=ih/16:color=%s:t=fill:enable=%s",
+			(len(lights)-i)*5-1, color, escapeFilterValue(fmt.Sprintf("gte(t,%.3f)", decisionAt))))
+	}
+	return strings.Join(filters, ",")
+}
+
+// escapeFilterValue escapes a filter option value twice: for the option
+// parser of the filter, then for the parser of the filter graph.
+func escapeFilterValue(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(value)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(value)
+}
+
+// ParseFrameSize parses a frame size such as "1920x1080".
+func ParseFrameSize(size string) (width, height int, err error) {
+	if _, err := fmt.Sscanf(size, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 || fmt.Sprintf("%dx%d", width, height) != size {
+		return 0, 0, fmt.Errorf("%q is not a frame size such as 1920x1080", size)
+	}
+	return width, height, nil
+}
+
+// NormalizeFilter returns the ffmpeg filter that fits a video into size
+// ("1920x1080") without distorting it, padding the rest with black bars.
+func NormalizeFilter(size string) string {
+	width, height, err := ParseFrameSize(size)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1",
+		width, height, width, height)
+}
+
+// AudioConfiguration records sound with a camera so the jury can hear the
+// platform. Device is a capture device of this machine (a DirectShow name on
+// Windows, an ALSA device such as "hw:1" on Linux, an AVFoundation index on
+// macOS); empty keeps the audio carried by the camera stream itself. Codec
+// must fit in an MP4 file (aac, mp3, opus) or be "copy".
+type AudioConfiguration struct {
+	Camera  int    `toml:"camera"`
+	Device  string `toml:"device"`
+	Codec   string `toml:"codec"`
+	Bitrate string `toml:"bitrate"`
+}
+
+// Audio settings used when a camera's audio section leaves them out.
+const (
+	DefaultAudioCodec   = "aac"
+	DefaultAudioBitrate = "128k"
+)
+
+// StorageSettings choose where the finished videos are kept: the [storage]
+// table of the configuration.
+type StorageSettings struct {
+	Type string `toml:"type"` // "local" (the default), "share" or "s3"
+	// Path is the folder where an SMB or NFS share is mounted, for "share".
+	Path string `toml:"path"`
+	// The bucket of an S3-compatible object storage, for "s3". Empty keys
+	// are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+	Endpoint  string `toml:"endpoint"`
+	Region    string `toml:"region"`
+	Bucket    string `toml:"bucket"`
+	Prefix    string `toml:"prefix"`
+	AccessKey string `toml:"accessKey"`
+	SecretKey string `toml:"secretKey"`
 }
 
 // MulticastSettings holds the multicast camera configuration.
@@ -33,22 +317,183 @@ type MulticastSettings struct {
 	Camera2Port int    `toml:"camera2Port"`
 	Camera3Port int    `toml:"camera3Port"`
 	Camera4Port int    `toml:"camera4Port"`
+	// ClipSource is the host:port of the camera node whose local recording
+	// can replace a missing or corrupted replay. Empty disables it.
+	ClipSource string `toml:"clipSource"`
+	// ContextCamera is the camera (1-4) carrying a screen capture, such as
+	// the scoreboard; its replay is inset into copies of the other replays.
+	// 0 disables it.
+	ContextCamera int `toml:"contextCamera"`
+	// ScoreboardPort receives a capture of the owlcms attempt board, recorded
+	// with every attempt and stored as camera 0. 0 disables it.
+	ScoreboardPort int `toml:"scoreboardPort"`
+	// SRTHost is the address of a cameras machine serving its streams over
+	// SRT ([srt] in its config.toml). When set, the camera ports are received
+	// from it over SRT instead of UDP. Empty uses UDP.
+	SRTHost       string `toml:"srtHost"`
+	SRTLatency    int    `toml:"srtLatencyMs"`
+	SRTPassphrase string `toml:"srtPassphrase"`
+	// CameraOffsetsMs holds the calibrated delay of cameras 1 to 4 (see
+	// CameraConfiguration.TrimOffsetMs).
+	CameraOffsetsMs []int `toml:"cameraOffsetsMs"`
+	// FrameAccurateCameras lists the cameras (1-4) trimmed on the exact frame.
+	FrameAccurateCameras []int `toml:"frameAccurateCameras"`
+	// Audio lists the cameras (1-4) recorded with sound.
+	Audio []AudioConfiguration `toml:"audio"`
+	// Trim lists the cameras (1-4) with their own trim settings.
+	Trim []TrimProfile `toml:"trim"`
+}
+
+// SRTPortOffset is added to the port of a camera stream to get the port on
+// which the cameras program serves it over SRT, so that both can be received
+// on the same machine.
+const SRTPortOffset = 1000
+
+// DefaultSRTLatency is the SRT latency used when none is configured.
+const DefaultSRTLatency = 200
+
+// SRTURL builds the srt:// URL of a stream. mode is "listener" or "caller";
+// latencyMs is how long SRT may wait for lost packets to be sent again, and
+// a non-empty passphrase (10 to 79 characters) encrypts the stream.
+func SRTURL(host string, port int, mode string, latencyMs int, passphrase string) string {
+	if latencyMs <= 0 {
+		latencyMs = DefaultSRTLatency
+	}
+	// ffmpeg takes the SRT latency in microseconds.
+	address := fmt.Sprintf("srt://%s:%d?mode=%s&latency=%d&pkt_size=1316", host, port, mode, latencyMs*1000)
+	if passphrase != "" {
+		// ffmpeg decodes the options of the URL.
+		address += "&passphrase=" + url.QueryEscape(passphrase)
+	}
+	return address
+}
+
+// CheckSRTPassphrase returns why SRT would refuse passphrase, nil when it is
+// empty (no encryption) or 10 to 79 characters long.
+func CheckSRTPassphrase(passphrase string) error {
+	if n := len(passphrase); n > 0 && (n < 10 || n > 79) {
+		return fmt.Errorf("the SRT passphrase must be 10 to 79 characters long, not %d", n)
+	}
+	return nil
+}
+
+// X264Settings tunes the libx264 encoding done after an attempt: trims that
+// re-encode, context insets and slow motion. Empty values are chosen for the
+// processor of this machine.
+type X264Settings struct {
+	Preset  string `toml:"preset"`
+	Tune    string `toml:"tune"`
+	Threads int    `toml:"threads"`
+}
+
+// X264Presets and X264Tunes are the values libx264 accepts.
+var (
+	X264Presets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow"}
+	X264Tunes   = []string{"film", "animation", "grain", "stillimage", "fastdecode", "zerolatency"}
+)
+
+// X264PresetForCores is the preset used when none is configured: the
+// fastest on small machines, where a slower one would hold up the replays.
+func X264PresetForCores(cores int) string {
+	switch {
+	case cores <= 2:
+		return "ultrafast"
+	case cores <= 4:
+		return "superfast"
+	case cores <= 8:
+		return "veryfast"
+	default:
+		return "faster"
+	}
+}
+
+// X264Args returns the -preset, -tune and -threads options of a libx264
+// encode. parallel is the number of such encodes running at the same time,
+// which share the processor cores when threads is not configured.
+func X264Args(parallel int) []string {
+	cores := runtime.NumCPU()
+	preset := X264.Preset
+	if preset == "" {
+		preset = X264PresetForCores(cores)
+	}
+	threads := X264.Threads
+	if threads <= 0 {
+		if parallel < 1 {
+			parallel = 1
+		}
+		threads = cores / parallel
+		if threads < 1 {
+			threads = 1
+		}
+	}
+	args := []string{"-preset", preset}
+	if X264.Tune != "" {
+		args = append(args, "-tune", X264.Tune)
+	}
+	return append(args, "-threads", strconv.Itoa(threads))
 }
 
+// ScoreboardCameraNumber is the camera number of the attempt board replays.
+const ScoreboardCameraNumber = 0
+
 var (
 	Verbose       bool
 	NoVideo       bool
 	NoMQTT        bool
+	Viewer        bool // serve the replay list only: no MQTT, cameras or recording (set by --viewer)
 	AutoTomlDir   string
+	ReplayMQTT    string // archived MQTT traffic file to replay (set by --replayMQTT)
+	ProfilePort   int    // localhost port of the pprof profiles and runtime metrics, 0 for none (set by --pprof)
 	ConfigDir     string // per-instance config dir (set by --configDir)
+	Portable      bool   // keep config, logs and videos next to the executable (set by --portable)
 	InstallDir    string
 	AppName       string // "cameras" or "replays" — set by each binary before config resolution
 	videoDir      string
+	recordingDir  string
 	Width         int
 	Height        int
 	Fps           int
 	Recode        bool
 	LogFfmpeg     bool
+	TrimWait      = 15 * time.Second
+	TrimWorkers   int // cameras re-encoded at the same time; 0 chooses from the processor cores
+	MaxRecording  = 5 * time.Minute
+	StallTimeout  = 10 * time.Second
+	StallRestarts = 2
+	DecisionWait  = 2 * time.Second
+	JuryWaits     map[int]time.Duration // DecisionWait of some jury sizes
+	PreRoll       = 5 * time.Second
+	PreRolls      map[string]time.Duration // PreRoll of some platforms
+	LogRetention  = 14 * 24 * time.Hour
+	NightlyAt     = "03:00"     // local time of the nightly maintenance, "" for none
+	VideoMaxAge   time.Duration // sessions older than this are deleted at night; 0 keeps them
+	MaxVideoBytes int64         // the oldest sessions are deleted at night above this; 0 for no limit
+	LowDiskBytes  int64         // the operator is warned below this much free space; 0 for no warning
+	FullDiskBytes int64         // attempts are not recorded below this much free space; 0 for no limit
+	preRollMutex  sync.Mutex
+	StartLatency  = 1500 * time.Millisecond
+	FirstFrame    = 5 * time.Second
+	PreBuffer     time.Duration
+	SlowMotion    []int
+	ProxyVariants []ProxyVariant // lower-bitrate copies of every replay, for the jury tablets
+	Interpolate   bool
+	X264          X264Settings
+	TrimEncoder   = "auto" // "auto", "software" or an ffmpeg.toml encoder name, for trims that re-encode
+	ReplaySize    string   // "1920x1080" letterboxes every replay to that size; empty keeps the camera's
+	JuryDevices   []string // JuryDeviceTypes the replays must play on; empty for DefaultJuryDevices
+	Overlay       bool     // burn the athlete, lift, attempt and weight into the replays
+	OverlayFont   string   // font file of the overlay; empty uses the default font of ffmpeg
+	ScoreOverlay  bool     // draw the clock and decision lights onto the replays
+	Timecode      bool     // burn the wall-clock time of every frame into the replays
+	DeferRecodes  bool     // publish stream copies first, re-encode between attempts
+	PlatformDirs  bool     // store the sessions under videos/{platform}/{session}
+	MultiAngle    string   // "hstack" or "vstack" combines the cameras of each attempt; empty for none
+	KeepOriginals bool     // archive the untrimmed recordings instead of deleting them
+	ArchiveAV1    string   // "auto", "software" or an av1_ encoder transcodes the finished sessions to AV1; empty for none
+	WebhookURL    string   // receives a JSON POST on every status change; empty disables it
+	ClipSource    string
+	ContextCamera int
+	Scoreboard    *CameraConfiguration
 	Mjpeg720pOnly = IsLinuxARM()
 	CameraConfigs []CameraConfiguration
 	ffmpegPath    string
@@ -66,9 +511,9 @@ const LocalVideoConfigDir = "video_config"
 func ResolveAndEnsureConfigDir() error {
 	if strings.TrimSpace(ConfigDir) == "" {
 		if AppName == "" {
-			ConfigDir = filepath.Join(".", LocalVideoConfigDir)
+			ConfigDir = localConfigRoot()
 		} else {
-			ConfigDir = filepath.Join(".", LocalVideoConfigDir, AppName)
+			ConfigDir = filepath.Join(localConfigRoot(), AppName)
 		}
 	}
 
@@ -85,19 +530,35 @@ func ResolveAndEnsureConfigDir() error {
 	return nil
 }
 
+// localConfigRoot returns the ./video_config folder used when no explicit
+// directory is given. In portable mode it is anchored next to the executable
+// instead of the current directory, so the whole setup moves with the drive;
+// packaged installs keep it in the user's data folder.
+func localConfigRoot() string {
+	if Portable {
+		if exePath, err := os.Executable(); err == nil {
+			return filepath.Join(filepath.Dir(exePath), LocalVideoConfigDir)
+		}
+	}
+	if IsPackaged() {
+		return filepath.Join(GetUserDataDir(), LocalVideoConfigDir)
+	}
+	return filepath.Join(".", LocalVideoConfigDir)
+}
+
 // IsLocalDevRuntime reports whether the runtime root is the default local
 // ./video_config/<AppName> folder (i.e. not overridden by --configDir and
 // not launched by the control panel with VIDEO_CONFIGDIR).
 func IsLocalDevRuntime() bool {
-	if strings.TrimSpace(os.Getenv(SharedConfigDirEnv)) != "" {
+	if !Portable && strings.TrimSpace(os.Getenv(SharedConfigDirEnv)) != "" {
 		return false
 	}
 
 	var devDir string
 	if AppName == "" {
-		devDir = filepath.Join(".", LocalVideoConfigDir)
+		devDir = localConfigRoot()
 	} else {
-		devDir = filepath.Join(".", LocalVideoConfigDir, AppName)
+		devDir = filepath.Join(localConfigRoot(), AppName)
 	}
 	absDevDir, err := filepath.Abs(devDir)
 	if err != nil {
@@ -117,9 +578,9 @@ func GetInstallDir() string {
 
 	var fallback string
 	if AppName == "" {
-		fallback = filepath.Join(".", LocalVideoConfigDir)
+		fallback = localConfigRoot()
 	} else {
-		fallback = filepath.Join(".", LocalVideoConfigDir, AppName)
+		fallback = filepath.Join(localConfigRoot(), AppName)
 	}
 	if abs, err := filepath.Abs(fallback); err == nil {
 		return abs
@@ -130,17 +591,24 @@ func GetInstallDir() string {
 // GetSharedConfigDir returns the shared configuration directory.
 // This is where ffmpeg.toml lives. In control-panel mode it comes from
 // VIDEO_CONFIGDIR; in dev mode it falls back to ./video_config/ffmpeg.
+// Portable mode ignores VIDEO_CONFIGDIR and stays next to the executable.
 func GetSharedConfigDir() string {
-	if envDir := strings.TrimSpace(os.Getenv(SharedConfigDirEnv)); envDir != "" {
+	if envDir := strings.TrimSpace(os.Getenv(SharedConfigDirEnv)); envDir != "" && !Portable {
 		if abs, err := filepath.Abs(envDir); err == nil {
 			return abs
 		}
 		return envDir
 	}
-	if abs, err := filepath.Abs(filepath.Join(".", LocalVideoConfigDir, "ffmpeg")); err == nil {
+	if abs, err := filepath.Abs(filepath.Join(localConfigRoot(), "ffmpeg")); err == nil {
 		return abs
 	}
-	return filepath.Join(".", LocalVideoConfigDir, "ffmpeg")
+	return filepath.Join(localConfigRoot(), "ffmpeg")
+}
+
+// GetProcessInventoryDir returns where the ffmpeg/ffplay processes started by
+// replays and cameras are recorded, so orphans can be found after a crash.
+func GetProcessInventoryDir() string {
+	return filepath.Join(GetSharedConfigDir(), "processes")
 }
 
 // GetRuntimeDir returns the directory of the running executable.
@@ -190,10 +658,10 @@ func GetFFmpegBootstrapDir() string {
 		return filepath.Join(envDir, "ffmpeg")
 	}
 
-	if absLocalVideo, err := filepath.Abs(filepath.Join(".", LocalVideoConfigDir, "ffmpeg")); err == nil {
+	if absLocalVideo, err := filepath.Abs(filepath.Join(localConfigRoot(), "ffmpeg")); err == nil {
 		return absLocalVideo
 	}
-	return filepath.Join(".", LocalVideoConfigDir, "ffmpeg")
+	return filepath.Join(localConfigRoot(), "ffmpeg")
 }
 
 // GetSharedFFmpegRootDirs returns candidate shared FFmpeg root directories
@@ -285,6 +753,27 @@ func GetVideoDir() string {
 	return videoDir
 }
 
+// SetRecordingDir sets where recordings are written while they run; empty
+// writes them in the video directory.
+func SetRecordingDir(dir string) {
+	recordingDir = dir
+}
+
+// GetRecordingDir returns where recordings are written while they run, such
+// as a RAM disk, or the video directory when none is configured.
+func GetRecordingDir() string {
+	if recordingDir != "" {
+		return recordingDir
+	}
+	return videoDir
+}
+
+// HasRecordingDir reports whether recordings are written outside the video
+// directory.
+func HasRecordingDir() bool {
+	return recordingDir != ""
+}
+
 func SetVideoConfig(width, height, fps int) {
 	Width = width
 	Height = height
@@ -308,8 +797,165 @@ func GetFFmpegPath() string {
 	return ffmpegPath
 }
 
+// GetLogFfmpeg reports whether ffmpeg commands write detailed logs: always
+// with logFfmpeg, or for the attempt the operator asked them for.
 func GetLogFfmpeg() bool {
-	return LogFfmpeg
+	if LogFfmpeg {
+		return true
+	}
+	ffmpegLogMu.Lock()
+	defer ffmpegLogMu.Unlock()
+	return logThisAttempt
+}
+
+// GetTrimWait returns how long trimming waits for a finished recording to
+// become readable before giving up.
+func GetTrimWait() time.Duration {
+	return TrimWait
+}
+
+// GetDecisionWait returns how long recording goes on after the referees
+// decision, so that the replays show the decision lights, for a jury of
+// jurySize members (0 when owlcms has not told).
+func GetDecisionWait(jurySize int) time.Duration {
+	if wait, ok := JuryWaits[jurySize]; ok {
+		return wait
+	}
+	return DecisionWait
+}
+
+// GetPreRoll returns how much footage a replay keeps before the clock was
+// stopped, on platform.
+func GetPreRoll(platform string) time.Duration {
+	preRollMutex.Lock()
+	defer preRollMutex.Unlock()
+	if preRoll, ok := PreRolls[platform]; ok {
+		return preRoll
+	}
+	return PreRoll
+}
+
+// SetPreRoll changes the pre-roll of platform while running.
+func SetPreRoll(platform string, preRoll time.Duration) {
+	preRollMutex.Lock()
+	defer preRollMutex.Unlock()
+	if platform == "" {
+		PreRoll = preRoll
+		return
+	}
+	if PreRolls == nil {
+		PreRolls = map[string]time.Duration{}
+	}
+	PreRolls[platform] = preRoll
+}
+
+// GetMaxRecording returns how long an attempt is recorded without a decision
+// before it is saved anyway; 0 never stops it.
+func GetMaxRecording() time.Duration {
+	return MaxRecording
+}
+
+// GetStallTimeout returns how long a camera may record nothing before the
+// operator is warned; 0 disables the check.
+func GetStallTimeout() time.Duration {
+	return StallTimeout
+}
+
+// GetStallRestarts returns how many times a stalled camera is restarted during
+// one attempt; 0 only warns the operator.
+func GetStallRestarts() int {
+	return StallRestarts
+}
+
+// GetStartLatencyWarning returns how long a camera may take from the start
+// message to its first frame before the operator is warned; 0 disables the
+// warning.
+func GetStartLatencyWarning() time.Duration {
+	return StartLatency
+}
+
+// GetFirstFrameTimeout returns how long a camera may take to record its first
+// frame before it is dropped from the attempt; 0 does not wait for the first
+// frames.
+func GetFirstFrameTimeout() time.Duration {
+	return FirstFrame
+}
+
+// ProxyVariant is a lower-bitrate copy made of every replay: at most Height
+// lines, at Kbps kilobits per second.
+type ProxyVariant struct {
+	Height int
+	Kbps   int
+}
+
+// Name is the quality of the copy, "720p", as in its file name and in the
+// quality parameter of the video URLs.
+func (v ProxyVariant) Name() string {
+	return fmt.Sprintf("%dp", v.Height)
+}
+
+// ParseProxyVariant parses a proxy variant such as "720p/2M" or "480p/800k".
+func ParseProxyVariant(spec string) (ProxyVariant, error) {
+	var variant ProxyVariant
+	height, rate, ok := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), "p/")
+	unit := 1
+	if strings.HasSuffix(rate, "m") {
+		unit, rate = 1000, strings.TrimSuffix(rate, "m")
+	} else {
+		rate = strings.TrimSuffix(rate, "k")
+	}
+	var err error
+	if variant.Height, err = strconv.Atoi(height); !ok || err != nil || variant.Height < 144 || variant.Height%2 != 0 {
+		return ProxyVariant{}, fmt.Errorf("%q is not a variant such as \"720p/2M\" (an even height, then a bitrate)", spec)
+	}
+	kbps, err := strconv.ParseFloat(rate, 64)
+	if variant.Kbps = int(kbps * float64(unit)); err != nil || variant.Kbps < 100 {
+		return ProxyVariant{}, fmt.Errorf("%q is not a variant such as \"720p/2M\" (at least 100k)", spec)
+	}
+	return variant, nil
+}
+
+// GetSlowMotion returns the speeds (percent of normal) of the slow-motion
+// copies made of each replay, and whether frames are interpolated; no speeds
+// makes none.
+func GetSlowMotion() ([]int, bool) {
+	return SlowMotion, Interpolate
+}
+
+// GetPreBuffer returns how much footage the rolling buffer of each camera
+// keeps; 0 records every attempt with its own ffmpeg instead.
+func GetPreBuffer() time.Duration {
+	return PreBuffer
+}
+
+// GetClipSource returns the host:port of the camera node serving its local
+// recording, or "" when replays cannot fall back on it.
+func GetClipSource() string {
+	return ClipSource
+}
+
+// GetContextCamera returns the camera whose replay is inset into the others,
+// or 0.
+func GetContextCamera() int {
+	return ContextCamera
+}
+
+// GetScoreboardConfig returns the attempt board stream recorded as camera 0,
+// or nil when none is configured.
+func GetScoreboardConfig() *CameraConfiguration {
+	return Scoreboard
+}
+
+// GetCameraConfig returns the configuration of a camera number, including the
+// attempt board (camera 0), or nil when there is no such camera.
+func GetCameraConfig(cameraNumber int) *CameraConfiguration {
+	if cameraNumber == ScoreboardCameraNumber {
+		return Scoreboard
+	}
+	if cameraNumber < 1 || cameraNumber > len(CameraConfigs) {
+		return nil
+	}
+	return &CameraConfigs[cameraNumber-1]
 }
 
 func GetMjpeg720pOnly() bool {
@@ -325,22 +971,80 @@ func (m *MulticastSettings) ApplyDefaults() {
 	if m.IP == "" {
 		m.IP = "0.0.0.0"
 	}
+	if m.SRTLatency <= 0 {
+		m.SRTLatency = DefaultSRTLatency
+	}
 }
 
 // BuildCameraConfigs creates CameraConfiguration entries for each non-zero port.
 func (m *MulticastSettings) BuildCameraConfigs() []CameraConfiguration {
 	ports := []int{m.Camera1Port, m.Camera2Port, m.Camera3Port, m.Camera4Port}
 	var cameras []CameraConfiguration
-	for _, port := range ports {
+	for i, port := range ports {
 		if port > 0 {
-			cameras = append(cameras, CameraConfiguration{
-				FfmpegCamera:     fmt.Sprintf("udp://%s:%d", m.IP, port),
-				Format:           "mpegts",
-				InputParameters:  "",
-				OutputParameters: "-c:v copy -an",
-				Recode:           false,
-			})
+			camera := m.streamConfig(port)
+			camera.Audio = m.audioConfig(i + 1)
+			camera.Trim = m.trimProfile(i + 1)
+			if i < len(m.CameraOffsetsMs) {
+				camera.TrimOffsetMs = m.CameraOffsetsMs[i]
+			}
+			for _, cameraNumber := range m.FrameAccurateCameras {
+				camera.FrameAccurate = camera.FrameAccurate || cameraNumber == i+1
+			}
+			cameras = append(cameras, camera)
 		}
 	}
 	return cameras
 }
+
+// audioConfig returns the audio section of a camera, nil when it is recorded
+// without sound.
+func (m *MulticastSettings) audioConfig(cameraNumber int) *AudioConfiguration {
+	for _, audio := range m.Audio {
+		if audio.Camera != cameraNumber {
+			continue
+		}
+		if audio.Codec == "" {
+			audio.Codec = DefaultAudioCodec
+		}
+		if audio.Bitrate == "" {
+			audio.Bitrate = DefaultAudioBitrate
+		}
+		return &audio
+	}
+	return nil
+}
+
+// trimProfile returns the trim section of a camera, nil when it has none.
+func (m *MulticastSettings) trimProfile(cameraNumber int) *TrimProfile {
+	for _, profile := range m.Trim {
+		if profile.Camera == cameraNumber {
+			return &profile
+		}
+	}
+	return nil
+}
+
+// BuildScoreboardConfig creates the CameraConfiguration of the attempt board
+// stream, or returns nil when ScoreboardPort is not set.
+func (m *MulticastSettings) BuildScoreboardConfig() *CameraConfiguration {
+	if m.ScoreboardPort <= 0 {
+		return nil
+	}
+	camera := m.streamConfig(m.ScoreboardPort)
+	return &camera
+}
+
+func (m *MulticastSettings) streamConfig(port int) CameraConfiguration {
+	source := fmt.Sprintf("udp://%s:%d", m.IP, port)
+	if host := strings.TrimSpace(m.SRTHost); host != "" {
+		source = SRTURL(host, port+SRTPortOffset, "caller", m.SRTLatency, m.SRTPassphrase)
+	}
+	return CameraConfiguration{
+		FfmpegCamera:     source,
+		Format:           "mpegts",
+		InputParameters:  "",
+		OutputParameters: "-c:v copy -an",
+		Recode:           false,
+	}
+}
@@ -0,0 +1,297 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPortableModeAnchorsDirsNextToExecutable(t *testing.T) {
+	oldPortable, oldConfigDir, oldAppName := Portable, ConfigDir, AppName
+	t.Cleanup(func() { Portable, ConfigDir, AppName = oldPortable, oldConfigDir, oldAppName })
+	t.Setenv(SharedConfigDirEnv, t.TempDir())
+
+	exePath, err := os.Executable()
+	if err != nil {
+		t.Skip("executable path unavailable")
+	}
+	root := filepath.Join(filepath.Dir(exePath), LocalVideoConfigDir)
+
+	Portable, ConfigDir, AppName = true, "", "replays"
+	if got := GetInstallDir(); got != filepath.Join(root, "replays") {
+		t.Fatalf("GetInstallDir() = %s, want under %s", got, root)
+	}
+	if got := GetSharedConfigDir(); got != filepath.Join(root, "ffmpeg") {
+		t.Fatalf("GetSharedConfigDir() = %s, want %s", got, filepath.Join(root, "ffmpeg"))
+	}
+}
+
+func TestPackagedInstallUsesUserDataDir(t *testing.T) {
+	oldPortable, oldConfigDir, oldAppName := Portable, ConfigDir, AppName
+	t.Cleanup(func() { Portable, ConfigDir, AppName = oldPortable, oldConfigDir, oldAppName })
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	t.Setenv(SharedConfigDirEnv, "")
+
+	exePath, err := os.Executable()
+	if err != nil {
+		t.Skip("executable path unavailable")
+	}
+	marker := filepath.Join(filepath.Dir(exePath), PackageMarkerFile)
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Skipf("cannot write next to the test binary: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(marker) })
+
+	Portable, ConfigDir, AppName = false, "", "replays"
+	root := filepath.Join(GetUserDataDir(), LocalVideoConfigDir)
+	if got := GetInstallDir(); got != filepath.Join(root, "replays") {
+		t.Fatalf("GetInstallDir() = %s, want under %s", got, root)
+	}
+	if got := GetLogDir(); got != filepath.Join(GetUserDataDir(), "logs") {
+		t.Fatalf("GetLogDir() = %s, want in the user data folder", got)
+	}
+
+	Portable = true
+	if IsPackaged() {
+		t.Fatal("portable mode must win over the package marker")
+	}
+}
+
+func TestMulticastCamerasAreReceivedOverSRTFromSRTHost(t *testing.T) {
+	settings := MulticastSettings{IP: "239.255.0.1", Camera1Port: 9001, SRTHost: "192.168.1.10", SRTPassphrase: "platformA2025"}
+	settings.ApplyDefaults()
+	cameras := settings.BuildCameraConfigs()
+	want := "srt://192.168.1.10:10001?mode=caller&latency=200000&pkt_size=1316&passphrase=platformA2025"
+	if len(cameras) != 1 || cameras[0].FfmpegCamera != want || cameras[0].Format != "mpegts" {
+		t.Fatalf("BuildCameraConfigs() = %+v, want %s", cameras, want)
+	}
+
+	if got := SRTURL("h", 10001, "caller", 200, "a&b=c d#e%f"); !strings.HasSuffix(got, "&passphrase=a%26b%3Dc+d%23e%25f") {
+		t.Fatalf("passphrase not escaped in %s", got)
+	}
+	if CheckSRTPassphrase("") != nil || CheckSRTPassphrase("platformA2025") != nil {
+		t.Fatalf("valid passphrases refused")
+	}
+	if CheckSRTPassphrase("short") == nil || CheckSRTPassphrase(strings.Repeat("x", 80)) == nil {
+		t.Fatalf("passphrases SRT refuses were accepted")
+	}
+}
+
+func TestX264ArgsShareTheCoresBetweenParallelEncodes(t *testing.T) {
+	saved := X264
+	t.Cleanup(func() { X264 = saved })
+	cores := runtime.NumCPU()
+
+	X264 = X264Settings{}
+	args := strings.Join(X264Args(cores*2), " ")
+	if want := "-preset " + X264PresetForCores(cores) + " -threads 1"; args != want {
+		t.Fatalf("X264Args() = %q, want %q", args, want)
+	}
+	if X264PresetForCores(2) != "ultrafast" || X264PresetForCores(16) != "faster" {
+		t.Fatalf("small machines must get the fastest preset, large ones a better one")
+	}
+
+	X264 = X264Settings{Preset: "medium", Tune: "film", Threads: 3}
+	if args := strings.Join(X264Args(4), " "); args != "-preset medium -tune film -threads 3" {
+		t.Fatalf("configured settings not used: %q", args)
+	}
+}
+
+func TestParseFrameSize(t *testing.T) {
+	if width, height, err := ParseFrameSize("1280x720"); err != nil || width != 1280 || height != 720 {
+		t.Fatalf("ParseFrameSize(1280x720) = %d, %d, %v", width, height, err)
+	}
+	for _, size := range []string{"", "1920", "1920x", "0x1080", "1920x1080,drawbox", "1920:1080"} {
+		if _, _, err := ParseFrameSize(size); err == nil {
+			t.Errorf("ParseFrameSize(%q) accepted", size)
+		}
+	}
+}
+
+func TestParseProxyVariant(t *testing.T) {
+	for spec, want := range map[string]ProxyVariant{"720p/2M": {720, 2000}, " 480P/800k ": {480, 800}, "360p/1.5m": {360, 1500}} {
+		if variant, err := ParseProxyVariant(spec); err != nil || variant != want {
+			t.Errorf("ParseProxyVariant(%q) = %+v, %v", spec, variant, err)
+		}
+	}
+	for _, spec := range []string{"", "720p", "720/2M", "721p/2M", "720p/fast", "720p/50k"} {
+		if _, err := ParseProxyVariant(spec); err == nil {
+			t.Errorf("ParseProxyVariant(%q) accepted", spec)
+		}
+	}
+	if name := (ProxyVariant{Height: 720}).Name(); name != "720p" {
+		t.Fatalf("Name() = %q", name)
+	}
+}
+
+func TestTrimFilterCropsBeforeScaling(t *testing.T) {
+	camera := CameraConfiguration{Trim: &TrimProfile{Crop: "640,120,1280,720", Scale: "1920:-2"}}
+	if filter := camera.TrimFilter(); filter != "crop=1280:720:640:120,scale=1920:-2" {
+		t.Fatalf("TrimFilter() = %q", filter)
+	}
+	camera = CameraConfiguration{Size: "1920x1080", Trim: &TrimProfile{Crop: "641,121,1281,1001"}}
+	if filter := camera.TrimFilter(); filter != "crop=1278:958:640:120" {
+		t.Fatalf("the crop region must be even and inside the picture, TrimFilter() = %q", filter)
+	}
+	camera = CameraConfiguration{Size: "1280x720", Trim: &TrimProfile{Crop: "1400,0,200,200"}}
+	if filter := camera.TrimFilter(); filter != "" {
+		t.Fatalf("a crop region outside the picture must be dropped, TrimFilter() = %q", filter)
+	}
+	for _, crop := range []string{"", "640,120,1280", "640,120,0,720", "-1,0,10,10", "a,b,c,d"} {
+		if _, _, _, _, err := ParseCrop(crop); err == nil {
+			t.Errorf("ParseCrop(%q) accepted", crop)
+		}
+	}
+}
+
+func TestDecisionWaitFollowsTheJurySize(t *testing.T) {
+	defer func(wait time.Duration, byJury map[int]time.Duration) {
+		DecisionWait, JuryWaits = wait, byJury
+	}(DecisionWait, JuryWaits)
+
+	DecisionWait = 2 * time.Second
+	JuryWaits = map[int]time.Duration{5: 3500 * time.Millisecond}
+	if wait := GetDecisionWait(5); wait != 3500*time.Millisecond {
+		t.Fatalf("wait for a jury of 5 = %s, want 3.5s", wait)
+	}
+	for _, size := range []int{0, 3} {
+		if wait := GetDecisionWait(size); wait != 2*time.Second {
+			t.Fatalf("wait for a jury of %d = %s, want the default 2s", size, wait)
+		}
+	}
+}
+
+func TestPreRollFollowsThePlatform(t *testing.T) {
+	defer func(preRoll time.Duration, byPlatform map[string]time.Duration) {
+		PreRoll, PreRolls = preRoll, byPlatform
+	}(PreRoll, PreRolls)
+
+	PreRoll, PreRolls = 5*time.Second, nil
+	SetPreRoll("A", 12*time.Second)
+	if preRoll := GetPreRoll("A"); preRoll != 12*time.Second {
+		t.Fatalf("pre-roll of platform A = %s, want 12s", preRoll)
+	}
+	if preRoll := GetPreRoll("B"); preRoll != 5*time.Second {
+		t.Fatalf("pre-roll of platform B = %s, want the default 5s", preRoll)
+	}
+}
+
+func TestOverlayFilterEscapesTheText(t *testing.T) {
+	filter := OverlayFilter("O'Neil, Mary: Snatch attempt 1")
+	want := `drawtext=expansion=none:text=O\\\'Neil\, Mary\\: Snatch attempt 1:`
+	if !strings.HasPrefix(filter, want) {
+		t.Fatalf("OverlayFilter() = %s, want it to start with %s", filter, want)
+	}
+	if camera := (CameraConfiguration{Overlay: "x"}); !camera.TrimRecodes() {
+		t.Fatalf("a replay with an overlay must be re-encoded")
+	}
+}
+
+func TestScoreboardFilterDrawsTheClockAndLights(t *testing.T) {
+	filter := ScoreboardFilter([]ClockSegment{
+		{From: 0, To: 2, Remaining: 60},
+		{From: 2, To: 9.5, Remaining: 60, Running: true},
+		{From: 9.5, To: 12, Remaining: 52.5},
+	}, 10.25, []string{"good", "bad", "good"})
+	for _, want := range []string{
+		`expansion=none:text=1\\:00:`,
+		`enable=between(t\,0.000\,2.000)`,
+		`expansion=normal:text=%{eif\\:trunc(ceil(max(0\,60.000-(t-2.000)))/60)\\:d}`,
+		`expansion=none:text=0\\:53:`,
+		`drawbox=x=iw-36-ih*14/64:y=36+ih/8:w=ih/16:h=ih/16:color=white:t=fill:enable=gte(t\,10.250)`,
+		`drawbox=x=iw-36-ih*9/64:y=36+ih/8:w=ih/16:h=ih/16:color=red:`,
+	} {
+		if !strings.Contains(filter, want) {
+			t.Errorf("ScoreboardFilter() = %s, want it to contain %s", filter, want)
+		}
+	}
+	if filter := ScoreboardFilter(nil, 0, nil); filter != "" {
+		t.Errorf("ScoreboardFilter() without clock nor decision = %q, want none", filter)
+	}
+	if camera := (CameraConfiguration{ScoreFilter: "drawbox"}); !camera.TrimRecodes() {
+		t.Fatalf("a replay with the scoreboard must be re-encoded")
+	}
+}
+
+func TestTimecodeFilterCountsFromMidnight(t *testing.T) {
+	start := time.Date(2026, 5, 2, 14, 3, 7, 250*int(time.Millisecond), time.Local)
+	want := `drawtext=expansion=normal:text=%{pts\\:hms\\:50587.250}:`
+	if filter := TimecodeFilter(start); !strings.HasPrefix(filter, want) {
+		t.Fatalf("TimecodeFilter() = %s, want it to start with %s", filter, want)
+	}
+	camera := CameraConfiguration{Timecode: start}
+	if !camera.TrimRecodes() || !strings.Contains(camera.TrimFilter(), "pts") {
+		t.Fatalf("a replay with the timecode must be re-encoded with it, filter %q", camera.TrimFilter())
+	}
+}
+
+func TestJuryDevicesPlayUpToTheirH264Level(t *testing.T) {
+	defer func(saved []string) { JuryDevices = saved }(JuryDevices)
+
+	if level := H264Level(1920, 1080, 30); level != 40 {
+		t.Fatalf("1080p30 is level %s, want 4.0", FormatLevel(level))
+	}
+	if level := H264Level(1920, 1080, 60); level != 42 {
+		t.Fatalf("1080p60 is level %s, want 4.2", FormatLevel(level))
+	}
+	if level := H264Level(3840, 2160, 120); level != 0 {
+		t.Fatalf("2160p120 is level %s, want above 5.2", FormatLevel(level))
+	}
+
+	JuryDevices = nil
+	if problems := JuryPlaybackProblems("h264", "High", 42, "mp4"); len(problems) != 0 {
+		t.Fatalf("the default devices cannot play 1080p60: %v", problems)
+	}
+	if problems := JuryPlaybackProblems("hevc", "", 0, "mp4"); len(problems) != 2 {
+		t.Fatalf("HEVC problems = %v, want chrome and firefox", problems)
+	}
+	JuryDevices = []string{"safari", "old-ipad"}
+	if MaxJuryLevel() != 41 {
+		t.Fatalf("MaxJuryLevel() = %d, want 41", MaxJuryLevel())
+	}
+	problems := JuryPlaybackProblems("h264", "High", 42, "mkv")
+	if len(problems) != 2 || !strings.Contains(problems[1], "old-ipad: it plays H.264 up to level 4.1") {
+		t.Fatalf("problems = %v", problems)
+	}
+	if problem := JuryDeviceTypes["chrome"].PlaybackProblem("h264", "High 4:2:2", 40, "mp4"); problem == "" {
+		t.Fatalf("chrome plays H.264 4:2:2")
+	}
+}
+
+func TestFfmpegLogsForTheNextAttemptOnly(t *testing.T) {
+	savedLog, savedHook := LogFfmpeg, OnFfmpegLogChanged
+	defer func() {
+		LogFfmpeg, OnFfmpegLogChanged = savedLog, savedHook
+		LogNextAttempt(false)
+		EndAttemptLog()
+	}()
+	LogFfmpeg = false
+	changes := 0
+	OnFfmpegLogChanged = func() { changes++ }
+
+	LogNextAttempt(true)
+	if GetLogFfmpeg() || FfmpegLogState() != FfmpegLogNext {
+		t.Fatalf("armed logs are on before the attempt: %v %q", GetLogFfmpeg(), FfmpegLogState())
+	}
+	if !BeginAttemptLog() || !GetLogFfmpeg() || FfmpegLogState() != FfmpegLogAttempt {
+		t.Fatalf("the attempt is not logged: %q", FfmpegLogState())
+	}
+	if !EndAttemptLog() || GetLogFfmpeg() || FfmpegLogState() != FfmpegLogOff {
+		t.Fatalf("the logs did not stop with the attempt: %q", FfmpegLogState())
+	}
+	if BeginAttemptLog() || GetLogFfmpeg() {
+		t.Fatalf("the attempt after is logged too")
+	}
+	if changes != 3 {
+		t.Fatalf("%d changes notified, want 3 (armed, started, ended)", changes)
+	}
+
+	LogFfmpeg = true
+	if !GetLogFfmpeg() {
+		t.Fatalf("logFfmpeg no longer logs every attempt")
+	}
+}
@@ -50,6 +50,34 @@ type EncoderConfig struct {
 	GpuVendors       []string `toml:"gpuVendors"` // optional: nvidia, amd, intel
 }
 
+// Codecs are the video codecs the camera streams can be encoded to; the first
+// is the default. The av1_ encoder blocks only serve the archival transcodes.
+var Codecs = []string{"h264", "hevc"}
+
+// ValidCodec reports whether codec is one of Codecs.
+func ValidCodec(codec string) bool {
+	for _, known := range Codecs {
+		if codec == known {
+			return true
+		}
+	}
+	return false
+}
+
+// EncoderCodec returns the codec produced by an encoder, from its ffmpeg name:
+// "hevc" for hevc_nvenc and the like, "av1" for av1_nvenc and av1_qsv, "h264"
+// otherwise.
+func EncoderCodec(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case strings.HasPrefix(name, "hevc_"):
+		return "hevc"
+	case strings.HasPrefix(name, "av1_"):
+		return "av1"
+	}
+	return Codecs[0]
+}
+
 // OutputConfig holds common output flags.
 type OutputConfig struct {
 	GopMultiplier int    `toml:"gopMultiplier"`
@@ -172,6 +200,44 @@ func ExtractDefaultConfig() string {
 	return sharedPath
 }
 
+// SharedConfigContent returns the ffmpeg.toml of the shared config directory,
+// or the embedded default when there is none.
+func SharedConfigContent() ([]byte, error) {
+	data, err := os.ReadFile(ResolveConfigPath())
+	if os.IsNotExist(err) {
+		return defaultConfig, nil
+	}
+	return data, err
+}
+
+// ParseConfig parses the content of an ffmpeg.toml as LoadConfig does.
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if _, err := toml.Decode(string(data), &cfg); err != nil {
+		return nil, err
+	}
+	cfg.applyDefaults()
+	cfg.filterEncodersForPlatform()
+	return &cfg, nil
+}
+
+// WriteSharedConfig replaces the ffmpeg.toml of the shared config directory,
+// after checking that it parses.
+func WriteSharedConfig(data []byte) (*Config, error) {
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ffmpeg.toml: %w", err)
+	}
+	path := ResolveConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := config.WriteConfigFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // filterEncodersForPlatform removes encoder entries that don't match the current OS.
 // Platform values can be OS names ("linux", "windows") or capture API names
 // ("v4l2" for Linux, "dshow" for Windows).
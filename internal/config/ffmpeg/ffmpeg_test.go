@@ -34,3 +34,30 @@ func TestApplyDefaultsUsesEmbeddedEncoderDefaults(t *testing.T) {
 		t.Fatalf("software OutputParameters = %q, want yuv420p output", cfg.Software.OutputParameters)
 	}
 }
+
+func TestEmbeddedHevcEncodersComeAfterH264(t *testing.T) {
+	cfg, err := parseEmbeddedDefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstHevc, lastH264 := -1, -1
+	for i, enc := range cfg.Encoders {
+		switch EncoderCodec(enc.Name) {
+		case "hevc":
+			if firstHevc < 0 {
+				firstHevc = i
+			}
+			if !strings.Contains(enc.OutputParameters, "-c:v "+enc.Name) || !strings.Contains(enc.OutputParameters, "-bf 0") {
+				t.Fatalf("%s OutputParameters = %q", enc.Name, enc.OutputParameters)
+			}
+		case "h264":
+			lastH264 = i
+		}
+	}
+	if firstHevc < 0 || firstHevc < lastH264 {
+		t.Fatalf("hevc encoders at %d, last h264 encoder at %d: H.264 must stay the default", firstHevc, lastH264)
+	}
+	if EncoderCodec("h264_nvenc") != "h264" || EncoderCodec("HEVC_QSV") != "hevc" || EncoderCodec("av1_nvenc") != "av1" {
+		t.Fatal("EncoderCodec() does not tell H.264 and HEVC encoders apart")
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import "sync"
+
+// The operator can ask for detailed ffmpeg logs for the next attempt only, to
+// capture a problem without logging every attempt of the day (logFfmpeg).
+var (
+	ffmpegLogMu    sync.Mutex
+	logNextAttempt bool // armed for the next attempt
+	logThisAttempt bool // the attempt being recorded and trimmed is logged
+
+	// OnFfmpegLogChanged is called after the logs of the next attempt are
+	// armed or disarmed, and when the attempt they were armed for starts or
+	// ends, outside of any lock.
+	OnFfmpegLogChanged func()
+)
+
+// Values of FfmpegLogState.
+const (
+	FfmpegLogOff     = ""
+	FfmpegLogNext    = "next"    // the next attempt will be logged
+	FfmpegLogAttempt = "attempt" // the current attempt is logged
+)
+
+// LogNextAttempt arms (or disarms) the ffmpeg logs of the next attempt.
+func LogNextAttempt(on bool) {
+	ffmpegLogMu.Lock()
+	changed := logNextAttempt != on
+	logNextAttempt = on
+	ffmpegLogMu.Unlock()
+	if changed {
+		notifyFfmpegLogChanged()
+	}
+}
+
+// FfmpegLogState returns FfmpegLogNext when the logs of the next attempt are
+// armed, else FfmpegLogAttempt while the attempt they were armed for is
+// recorded and trimmed, else FfmpegLogOff.
+func FfmpegLogState() string {
+	ffmpegLogMu.Lock()
+	defer ffmpegLogMu.Unlock()
+	switch {
+	case logNextAttempt:
+		return FfmpegLogNext
+	case logThisAttempt:
+		return FfmpegLogAttempt
+	}
+	return FfmpegLogOff
+}
+
+// BeginAttemptLog is called when an attempt starts recording: the logs armed
+// for it are turned on, those of the previous attempt off. It reports whether
+// the attempt is logged.
+func BeginAttemptLog() bool {
+	ffmpegLogMu.Lock()
+	changed := logNextAttempt || logThisAttempt
+	logThisAttempt = logNextAttempt
+	logNextAttempt = false
+	logged := logThisAttempt
+	ffmpegLogMu.Unlock()
+	if changed {
+		notifyFfmpegLogChanged()
+	}
+	return logged
+}
+
+// EndAttemptLog is called when the attempt is saved, or could not be
+// recorded. It reports whether the attempt was logged.
+func EndAttemptLog() bool {
+	ffmpegLogMu.Lock()
+	logged := logThisAttempt
+	logThisAttempt = false
+	ffmpegLogMu.Unlock()
+	if logged {
+		notifyFfmpegLogChanged()
+	}
+	return logged
+}
+
+func notifyFfmpegLogChanged() {
+	if OnFfmpegLogChanged != nil {
+		OnFfmpegLogChanged()
+	}
+}
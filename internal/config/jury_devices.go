@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The jury watches the replays in the browser of a laptop or a tablet. All
+// the browsers play H.264 in mp4 files, but each device decodes it up to a
+// level (the size and frame rate it can keep up with), and only some of them
+// play HEVC or Matroska files. ProRes plays in none of them.
+
+// JuryDevice is what the browser of a jury device plays.
+type JuryDevice struct {
+	MaxLevel   int  // highest H.264 level, times ten (41 for 4.1)
+	HEVC       bool // plays HEVC
+	Containers []string
+}
+
+// JuryDeviceTypes are the devices juryDevices can name.
+var JuryDeviceTypes = map[string]JuryDevice{
+	"chrome":   {MaxLevel: 52, Containers: []string{"mp4", "mov", "mkv"}},
+	"edge":     {MaxLevel: 52, Containers: []string{"mp4", "mov", "mkv"}},
+	"firefox":  {MaxLevel: 52, Containers: []string{"mp4", "mov"}},
+	"safari":   {MaxLevel: 52, HEVC: true, Containers: []string{"mp4", "mov"}},
+	"ipad":     {MaxLevel: 52, HEVC: true, Containers: []string{"mp4", "mov"}},
+	"old-ipad": {MaxLevel: 41, Containers: []string{"mp4", "mov"}}, // iPad 2 to 4: High profile up to 4.1
+	"android":  {MaxLevel: 51, Containers: []string{"mp4", "mkv"}},
+}
+
+// DefaultJuryDevices are checked when juryDevices is empty.
+var DefaultJuryDevices = []string{"chrome", "firefox", "safari"}
+
+// h264Levels are the limits of the H.264 levels from 3.0 up: the macroblocks
+// of a frame and the macroblocks decoded per second.
+var h264Levels = []struct{ level, maxFS, maxMBPS int }{
+	{30, 1620, 40500},
+	{31, 3600, 108000},
+	{32, 5120, 216000},
+	{40, 8192, 245760},
+	{41, 8192, 245760},
+	{42, 8704, 522240},
+	{50, 22080, 589824},
+	{51, 36864, 983040},
+	{52, 36864, 2073600},
+}
+
+// H264Level returns the lowest H.264 level, times ten, that holds a video of
+// that size and frame rate: 40 for 1080p30, 42 for 1080p60. Smaller videos
+// are level 3.0, which every device plays; 0 is above level 5.2.
+func H264Level(width, height int, fps float64) int {
+	frame := ((width + 15) / 16) * ((height + 15) / 16)
+	perSecond := float64(frame) * fps
+	for _, limits := range h264Levels {
+		if frame <= limits.maxFS && perSecond <= float64(limits.maxMBPS) {
+			return limits.level
+		}
+	}
+	return 0
+}
+
+// FormatLevel writes a level returned by H264Level as "4.2".
+func FormatLevel(level int) string {
+	if level <= 0 {
+		return "above 5.2"
+	}
+	return fmt.Sprintf("%d.%d", level/10, level%10)
+}
+
+// PlaybackProblem tells why the device cannot play replays of that codec
+// ("h264", "hevc" or "prores"), H.264 profile and level, and container, or
+// returns "" when it can. An empty profile or a level of 0 is not checked.
+func (d JuryDevice) PlaybackProblem(codec, profile string, level int, container string) string {
+	switch {
+	case codec == "prores":
+		return "it does not play ProRes"
+	case codec == "hevc" && !d.HEVC:
+		return "it does not play HEVC"
+	case codec == "h264" && (strings.Contains(profile, "4:2:2") || strings.Contains(profile, "4:4:4") || strings.Contains(profile, "10")):
+		return fmt.Sprintf("it does not play H.264 %s", profile)
+	case codec == "h264" && level > d.MaxLevel:
+		return fmt.Sprintf("it plays H.264 up to level %s, the replays are %s", FormatLevel(d.MaxLevel), FormatLevel(level))
+	}
+	for _, playable := range d.Containers {
+		if playable == container {
+			return ""
+		}
+	}
+	return fmt.Sprintf("it does not play %s files", container)
+}
+
+// CheckedJuryDevices returns the names of the devices the replays must play
+// on: JuryDevices, or DefaultJuryDevices when it is empty.
+func CheckedJuryDevices() []string {
+	if len(JuryDevices) == 0 {
+		return DefaultJuryDevices
+	}
+	return JuryDevices
+}
+
+// JuryPlaybackProblems returns, for each checked jury device that cannot
+// play them, "device: why" about replays of that codec, profile, level and
+// container.
+func JuryPlaybackProblems(codec, profile string, level int, container string) []string {
+	var problems []string
+	for _, name := range CheckedJuryDevices() {
+		if problem := JuryDeviceTypes[name].PlaybackProblem(codec, profile, level, container); problem != "" {
+			problems = append(problems, name+": "+problem)
+		}
+	}
+	return problems
+}
+
+// MaxJuryLevel returns the highest H.264 level all the checked jury devices
+// play.
+func MaxJuryLevel() int {
+	level := math.MaxInt32
+	for _, name := range CheckedJuryDevices() {
+		if device := JuryDeviceTypes[name]; device.MaxLevel < level {
+			level = device.MaxLevel
+		}
+	}
+	return level
+}
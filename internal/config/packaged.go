@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// PackageMarkerFile is placed next to the executable by the installers (MSI,
+// deb, AppImage, dmg). The program folder of an installed package is
+// read-only, so configuration, logs and videos then go to the user's data
+// folder instead of ./video_config.
+const PackageMarkerFile = "owlcms-package"
+
+// IsPackaged reports whether the running executable was installed by one of
+// the installers. Portable mode always wins.
+func IsPackaged() bool {
+	if Portable {
+		return false
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+	_, err = os.Stat(filepath.Join(filepath.Dir(exePath), PackageMarkerFile))
+	return err == nil
+}
+
+// GetUserDataDir returns the per-user folder used by packaged installs,
+// next to the control panel's own folder.
+func GetUserDataDir() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(os.Getenv("APPDATA"), "owlcms-replays")
+	case "darwin":
+		return filepath.Join(os.Getenv("HOME"), "Library", "Application Support", "owlcms-replays")
+	default:
+		return filepath.Join(os.Getenv("HOME"), ".local", "share", "owlcms-replays")
+	}
+}
+
+// GetLogDir returns where the logs are written: next to the executable, or in
+// the user's data folder for packaged installs.
+func GetLogDir() string {
+	if IsPackaged() {
+		return filepath.Join(GetUserDataDir(), "logs")
+	}
+	return filepath.Join(GetRuntimeDir(), "logs")
+}
+
+// LocalAppConfigDir returns the default configuration folder of app
+// ("replays" or "cameras") when no --configDir is given.
+func LocalAppConfigDir(app string) string {
+	dir := filepath.Join(localConfigRoot(), app)
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// FindBundledFFmpegExecutable returns the ffmpeg shipped with a packaged
+// install: ffmpeg/bin next to the executable, or in the Resources folder of
+// the macOS application bundle. Returns empty string when not found.
+func FindBundledFFmpegExecutable(executableName string) string {
+	exePath, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+	exeDir := filepath.Dir(exePath)
+	candidates := []string{
+		filepath.Join(exeDir, "ffmpeg", "bin", executableName),
+		filepath.Join(exeDir, "ffmpeg", executableName),
+		filepath.Join(exeDir, "..", "Resources", "ffmpeg", "bin", executableName),
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return filepath.Clean(candidate)
+		}
+	}
+	return ""
+}
@@ -5,26 +5,74 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/owlcms/replays/internal/config"
 	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
 )
 
 // Config represents the replays configuration file structure.
 type Config struct {
-	Port      int                          `toml:"port"`
-	VideoDir  string                       `toml:"videoDir"`
-	Width     int                          `toml:"width"`
-	Height    int                          `toml:"height"`
-	Fps       int                          `toml:"fps"`
-	OwlCMS    string                       `toml:"owlcms"`
-	Platform  string                       `toml:"platform"`
-	LogFfmpeg bool                         `toml:"logFfmpeg"`
-	Multicast config.MulticastSettings     `toml:"mpeg-ts"`
-	Cameras   []config.CameraConfiguration `toml:"-"`
+	Port         int                          `toml:"port"`
+	PortRange    int                          `toml:"portRange"`
+	VideoDir     string                       `toml:"videoDir"`
+	RecordingDir string                       `toml:"recordingDir"`
+	Width        int                          `toml:"width"`
+	Height       int                          `toml:"height"`
+	Fps          int                          `toml:"fps"`
+	OwlCMS       string                       `toml:"owlcms"`
+	Platform     string                       `toml:"platform"`
+	LogFfmpeg    bool                         `toml:"logFfmpeg"`
+	TrimWait     int                          `toml:"trimWaitTimeout"`
+	TrimWorkers  int                          `toml:"trimWorkers"`
+	MaxRecording int                          `toml:"maxRecordingSeconds"`
+	StallWarning int                          `toml:"stallSeconds"`
+	Restarts     int                          `toml:"stallRestarts"`
+	StartWarning int                          `toml:"startLatencyWarningMs"`
+	FirstFrame   int                          `toml:"firstFrameSeconds"`
+	DecisionWait int                          `toml:"decisionWaitMs"`
+	PostRoll     int                          `toml:"postRollSeconds"`
+	JuryWait     map[string]int               `toml:"decisionWaitByJuryMs"`
+	PreRoll      int                          `toml:"preRollMs"`
+	PlatformRoll map[string]int               `toml:"preRollByPlatformMs"`
+	NightlyAt    *string                      `toml:"maintenanceTime"`
+	LogDays      int                          `toml:"logRetentionDays"`
+	VideoDays    int                          `toml:"videoRetentionDays"`
+	MaxVideoGB   float64                      `toml:"maxVideoGB"`
+	LowDiskGB    float64                      `toml:"lowDiskGB"`
+	FullDiskGB   float64                      `toml:"criticalDiskGB"`
+	PreBuffer    int                          `toml:"preBufferSeconds"`
+	SlowMotion   []int                        `toml:"slowMotionPercent"`
+	Interpolate  bool                         `toml:"slowMotionInterpolate"`
+	Proxies      []string                     `toml:"proxyVariants"`
+	ArchiveMQTT  bool                         `toml:"archiveMqtt"`
+	Viewer       bool                         `toml:"viewer"`
+	ControlToken string                       `toml:"controlToken"`
+	WebhookURL   string                       `toml:"webhookUrl"`
+	SyncEncoders bool                         `toml:"syncEncoderSettings"`
+	Multicast    config.MulticastSettings     `toml:"mpeg-ts"`
+	X264         config.X264Settings          `toml:"x264"`
+	Storage      config.StorageSettings       `toml:"storage"`
+	TrimEncoder  string                       `toml:"trimEncoder"`
+	ReplaySize   string                       `toml:"replaySize"`
+	JuryDevices  []string                     `toml:"juryDevices"`
+	AdjustJury   bool                         `toml:"adjustForJuryDevices"`
+	Overlay      bool                         `toml:"burnInOverlay"`
+	OverlayFont  string                       `toml:"overlayFont"`
+	ScoreOverlay bool                         `toml:"scoreboardOverlay"`
+	Timecode     bool                         `toml:"burnInTimecode"`
+	DeferRecodes bool                         `toml:"deferRecodes"`
+	PlatformDirs bool                         `toml:"platformFolders"`
+	MultiAngle   string                       `toml:"multiAngle"`
+	KeepOriginal bool                         `toml:"archiveOriginals"`
+	ArchiveAV1   string                       `toml:"archiveAV1"`
+	Cameras      []config.CameraConfiguration `toml:"-"`
 }
 
 var currentConfig *Config
@@ -47,22 +95,89 @@ func LoadConfig(configFile string) (*Config, error) {
 	if cfg.VideoDir == "" {
 		cfg.VideoDir = "videos"
 	}
+	if cfg.PortRange <= 0 {
+		cfg.PortRange = 10
+	}
+	if cfg.TrimWait <= 0 {
+		cfg.TrimWait = 15
+	}
+	if cfg.MaxRecording == 0 {
+		cfg.MaxRecording = 300
+	}
+	if cfg.StallWarning == 0 {
+		cfg.StallWarning = 10
+	}
+	if cfg.Restarts == 0 {
+		cfg.Restarts = 2
+	}
+	if cfg.StartWarning == 0 {
+		cfg.StartWarning = 1500
+	}
+	if cfg.FirstFrame == 0 {
+		cfg.FirstFrame = 5
+	}
+	// postRollSeconds is the same wait in seconds, and wins over
+	// decisionWaitMs when it is set.
+	switch {
+	case cfg.PostRoll > 0:
+		cfg.DecisionWait = cfg.PostRoll * 1000
+	case cfg.PostRoll < 0:
+		cfg.DecisionWait = -1
+	case cfg.DecisionWait == 0:
+		cfg.DecisionWait = 2000
+	}
+	if cfg.PreRoll == 0 {
+		cfg.PreRoll = 5000
+	}
+	if cfg.LogDays == 0 {
+		cfg.LogDays = 14
+	}
 	if !filepath.IsAbs(cfg.VideoDir) {
 		cfg.VideoDir = filepath.Join(config.GetInstallDir(), cfg.VideoDir)
+	} else if config.Portable {
+		logging.WarningLogger.Printf("Portable mode: videoDir %s is an absolute path and will not move with the executable folder", cfg.VideoDir)
 	}
-	if err := os.MkdirAll(cfg.VideoDir, os.ModePerm); err != nil {
+	if cfg.Viewer {
+		config.Viewer = true
+	}
+	if config.Viewer {
+		// The share may be offline at startup; the replay list reports it.
+		if _, err := os.Stat(cfg.VideoDir); err != nil {
+			logging.WarningLogger.Printf("Video directory %s is not reachable: %v", cfg.VideoDir, err)
+		}
+	} else if err := os.MkdirAll(cfg.VideoDir, os.ModePerm); err != nil {
 		return nil, fmt.Errorf("failed to create video directory '%s': %w", cfg.VideoDir, err)
 	}
 	logging.InfoLogger.Printf("Videos will be stored in: %s", cfg.VideoDir)
+	backend, err := storage.New(cfg.Storage)
+	if err != nil {
+		// Recording goes on in the video directory.
+		logging.ErrorLogger.Printf("Ignoring [storage]: %v", err)
+		backend = nil
+	}
+	storage.Use(backend)
+	if backend != nil {
+		logging.InfoLogger.Printf("Finished videos are stored on %v, recorded and trimmed in %s", backend, cfg.VideoDir)
+	}
 
 	// Replays is a pure MPEG-TS receiver: cameras are always provided as UDP
 	// streams from the Cameras module (local or remote). There is no local
 	// capture / autodetection path.
 	cfg.Multicast.Enabled = true
 	cfg.Multicast.ApplyDefaults()
+	if err := config.CheckSRTPassphrase(cfg.Multicast.SRTPassphrase); err != nil {
+		return nil, fmt.Errorf("invalid srtPassphrase in [mpeg-ts]: %w", err)
+	}
+	for i := range cfg.Multicast.Trim {
+		checkTrimProfile(&cfg.Multicast.Trim[i])
+	}
 
 	cameras := cfg.Multicast.BuildCameraConfigs()
-	if len(cameras) == 0 {
+	if config.Viewer {
+		// A viewer only lists the videos recorded by another instance.
+		cameras = nil
+		logging.InfoLogger.Printf("Viewer mode: serving %s without MQTT or recording", cfg.VideoDir)
+	} else if len(cameras) == 0 {
 		logging.WarningLogger.Printf("No camera stream ports configured in the [mpeg-ts] section of %s. Replays will start with no camera sources.", configFile)
 	} else {
 		logging.InfoLogger.Printf("MPEG-TS receiver: loaded %d camera stream(s) from %s", len(cameras), configFile)
@@ -71,6 +186,7 @@ func LoadConfig(configFile string) (*Config, error) {
 	cfg.Cameras = cameras
 
 	config.SetVideoDir(cfg.VideoDir)
+	config.SetRecordingDir(resolveRecordingDir(cfg.RecordingDir))
 	config.SetVideoConfig(cfg.Width, cfg.Height, cfg.Fps)
 
 	logging.InfoLogger.Printf("Configuration loaded from %s:\n"+
@@ -93,6 +209,184 @@ func LoadConfig(configFile string) (*Config, error) {
 
 	currentConfig = &cfg
 	config.LogFfmpeg = cfg.LogFfmpeg
+	config.TrimWait = time.Duration(cfg.TrimWait) * time.Second
+	config.TrimWorkers = cfg.TrimWorkers
+	config.MaxRecording = positiveSeconds(cfg.MaxRecording)
+	config.StallTimeout = positiveSeconds(cfg.StallWarning)
+	config.StallRestarts = cfg.Restarts
+	if config.StallRestarts < 0 {
+		config.StallRestarts = 0
+	}
+	config.StartLatency = 0
+	if cfg.StartWarning > 0 {
+		config.StartLatency = time.Duration(cfg.StartWarning) * time.Millisecond
+	}
+	config.FirstFrame = positiveSeconds(cfg.FirstFrame)
+	config.DecisionWait = positiveMillis(cfg.DecisionWait)
+	config.JuryWaits = nil
+	for size, wait := range cfg.JuryWait {
+		jurySize, err := strconv.Atoi(size)
+		if err != nil || jurySize <= 0 {
+			logging.WarningLogger.Printf("Ignoring decisionWaitByJuryMs %q, the keys are jury sizes such as \"3\" or \"5\"", size)
+			continue
+		}
+		if config.JuryWaits == nil {
+			config.JuryWaits = map[int]time.Duration{}
+		}
+		config.JuryWaits[jurySize] = positiveMillis(wait)
+	}
+	config.PreRoll = positiveMillis(cfg.PreRoll)
+	config.PreRolls = nil
+	for platform, preRoll := range cfg.PlatformRoll {
+		config.SetPreRoll(platform, positiveMillis(preRoll))
+	}
+	config.NightlyAt = "03:00"
+	if cfg.NightlyAt != nil {
+		config.NightlyAt = strings.TrimSpace(*cfg.NightlyAt)
+	}
+	if config.NightlyAt != "" {
+		if _, err := time.Parse("15:04", config.NightlyAt); err != nil {
+			logging.WarningLogger.Printf("Ignoring maintenanceTime %q, expected a time such as \"03:00\"", config.NightlyAt)
+			config.NightlyAt = "03:00"
+		}
+	}
+	config.LogRetention = 0
+	if cfg.LogDays > 0 {
+		config.LogRetention = time.Duration(cfg.LogDays) * 24 * time.Hour
+	}
+	config.VideoMaxAge = 0
+	if cfg.VideoDays > 0 {
+		config.VideoMaxAge = time.Duration(cfg.VideoDays) * 24 * time.Hour
+		logging.InfoLogger.Printf("Sessions older than %d days are deleted by the nightly maintenance", cfg.VideoDays)
+	}
+	config.MaxVideoBytes = 0
+	if cfg.MaxVideoGB > 0 {
+		config.MaxVideoBytes = int64(cfg.MaxVideoGB * (1 << 30))
+		logging.InfoLogger.Printf("The oldest sessions are deleted by the nightly maintenance above %.1f GB of videos", cfg.MaxVideoGB)
+	}
+	if cfg.LowDiskGB == 0 {
+		cfg.LowDiskGB = 10
+	}
+	if cfg.FullDiskGB == 0 {
+		cfg.FullDiskGB = 2
+	}
+	config.LowDiskBytes = positiveGB(cfg.LowDiskGB)
+	config.FullDiskBytes = positiveGB(cfg.FullDiskGB)
+	if config.FullDiskBytes > 0 && config.LowDiskBytes > 0 && config.LowDiskBytes < config.FullDiskBytes {
+		logging.WarningLogger.Printf("lowDiskGB (%.1f) is below criticalDiskGB (%.1f), there will be no warning before attempts stop being recorded", cfg.LowDiskGB, cfg.FullDiskGB)
+	}
+	config.PreBuffer = positiveSeconds(cfg.PreBuffer)
+	config.SlowMotion = nil
+	for _, percent := range cfg.SlowMotion {
+		if percent <= 0 || percent >= 100 {
+			logging.WarningLogger.Printf("Ignoring slowMotionPercent %d, speeds are between 1 and 99 percent", percent)
+			continue
+		}
+		config.SlowMotion = append(config.SlowMotion, percent)
+	}
+	config.Interpolate = cfg.Interpolate
+	config.ProxyVariants = nil
+	for _, spec := range cfg.Proxies {
+		variant, err := config.ParseProxyVariant(spec)
+		if err != nil {
+			logging.WarningLogger.Printf("Ignoring proxyVariants entry: %v", err)
+			continue
+		}
+		config.ProxyVariants = append(config.ProxyVariants, variant)
+		logging.InfoLogger.Printf("Every replay is also copied at %s, %d kbps", variant.Name(), variant.Kbps)
+	}
+	config.X264 = cfg.X264
+	if config.X264.Preset != "" && !contains(config.X264Presets, config.X264.Preset) {
+		logging.WarningLogger.Printf("Ignoring unknown [x264] preset %q, expected one of %s", config.X264.Preset, strings.Join(config.X264Presets, ", "))
+		config.X264.Preset = ""
+	}
+	if config.X264.Tune != "" && !contains(config.X264Tunes, config.X264.Tune) {
+		logging.WarningLogger.Printf("Ignoring unknown [x264] tune %q, expected one of %s", config.X264.Tune, strings.Join(config.X264Tunes, ", "))
+		config.X264.Tune = ""
+	}
+	logging.InfoLogger.Printf("Software encoding after attempts: %s", strings.Join(config.X264Args(1), " "))
+	config.WebhookURL = strings.TrimSpace(cfg.WebhookURL)
+	if config.WebhookURL != "" {
+		logging.InfoLogger.Printf("Status changes are posted to %s", config.WebhookURL)
+	}
+	config.TrimEncoder = strings.ToLower(strings.TrimSpace(cfg.TrimEncoder))
+	if config.TrimEncoder == "" {
+		config.TrimEncoder = "auto"
+	}
+	config.ReplaySize = ""
+	if size := strings.TrimSpace(cfg.ReplaySize); size != "" {
+		if _, _, err := config.ParseFrameSize(size); err != nil {
+			logging.WarningLogger.Printf("Ignoring replaySize: %v", err)
+		} else {
+			config.ReplaySize = size
+			logging.InfoLogger.Printf("Replays are normalized to %s", size)
+		}
+	}
+	config.Overlay = cfg.Overlay
+	config.OverlayFont = strings.TrimSpace(cfg.OverlayFont)
+	if config.OverlayFont != "" {
+		if _, err := os.Stat(config.OverlayFont); err != nil {
+			logging.WarningLogger.Printf("Ignoring overlayFont: %v", err)
+			config.OverlayFont = ""
+		}
+	}
+	if config.Overlay {
+		logging.InfoLogger.Println("The athlete, lift, attempt and weight are burned into the replays")
+	}
+	config.ScoreOverlay = cfg.ScoreOverlay
+	if config.ScoreOverlay {
+		logging.InfoLogger.Println("The clock and decision lights are drawn onto the replays")
+	}
+	config.Timecode = cfg.Timecode
+	if config.Timecode {
+		logging.InfoLogger.Println("The wall-clock time is burned into the replays")
+	}
+	config.DeferRecodes = cfg.DeferRecodes
+	if config.DeferRecodes {
+		logging.InfoLogger.Println("Replays are re-encoded and slowed down between attempts")
+	}
+	config.PlatformDirs = cfg.PlatformDirs
+	if config.PlatformDirs {
+		logging.InfoLogger.Println("Sessions are stored in a folder per platform")
+	}
+	config.KeepOriginals = cfg.KeepOriginal
+	if config.KeepOriginals {
+		logging.InfoLogger.Println("The untrimmed recordings are archived")
+	}
+	config.ArchiveAV1 = ""
+	switch encoder := strings.ToLower(strings.TrimSpace(cfg.ArchiveAV1)); {
+	case encoder == "" || encoder == "off":
+	case encoder == "libsvtav1":
+		config.ArchiveAV1 = "software"
+	case encoder == "auto" || encoder == "software" || strings.HasPrefix(encoder, "av1_"):
+		config.ArchiveAV1 = encoder
+	default:
+		logging.WarningLogger.Printf("Ignoring archiveAV1 = %q: use \"auto\", \"software\" or an AV1 encoder of ffmpeg.toml", cfg.ArchiveAV1)
+	}
+	if config.ArchiveAV1 != "" {
+		logging.InfoLogger.Printf("Finished sessions are transcoded to AV1 (archiveAV1 = %q)", config.ArchiveAV1)
+	}
+	config.MultiAngle = ""
+	switch layout := strings.ToLower(strings.TrimSpace(cfg.MultiAngle)); layout {
+	case "", "off":
+	case "hstack", "vstack":
+		config.MultiAngle = layout
+		logging.InfoLogger.Printf("The cameras of each attempt are combined with %s", layout)
+	default:
+		logging.WarningLogger.Printf("Ignoring multiAngle = %q: use \"hstack\" or \"vstack\"", cfg.MultiAngle)
+	}
+	config.ClipSource = cfg.Multicast.ClipSource
+	config.ContextCamera = cfg.Multicast.ContextCamera
+	config.Scoreboard = nil
+	if !config.Viewer {
+		config.Scoreboard = cfg.Multicast.BuildScoreboardConfig()
+	}
+	if config.Scoreboard != nil {
+		logging.InfoLogger.Printf("Attempt board stream (camera 0): %s", config.Scoreboard.FfmpegCamera)
+	}
+	setJuryDevices(cfg.JuryDevices)
+	logging.InfoLogger.Printf("Replays are checked to play on %s", strings.Join(config.CheckedJuryDevices(), ", "))
+	checkJuryDevices(cfg.Cameras, cfg.Width, cfg.Height, cfg.Fps, cfg.AdjustJury)
 	return &cfg, nil
 }
 
@@ -103,6 +397,9 @@ func GetCurrentConfig() *Config {
 
 // ValidateCamera checks if camera configuration is correct for the platform.
 func (c *Config) ValidateCamera() error {
+	if config.Viewer {
+		return nil
+	}
 	if len(c.Cameras) == 0 || c.Cameras[0].FfmpegCamera == "" {
 		return fmt.Errorf("camera not configured")
 	}
@@ -127,8 +424,22 @@ An absolute path can be provded if needed.`, config.GetInstallDir()))
 	flag.BoolVar(&config.NoMQTT, "noMQTT", false, "disable MQTT autodiscovery and monitoring")
 	flag.StringVar(&config.AutoTomlDir, "autoTomlDir", "",
 		"directory for auto.toml output (default: install dir)")
+	flag.BoolVar(&config.Portable, "portable", false,
+		"keep config, logs and videos in the executable's folder (e.g. on a removable drive)")
+	flag.BoolVar(&config.Viewer, "viewer", false,
+		"only serve the replay list of the video directory, without MQTT or recording (extra display machine)")
+	flag.StringVar(&config.ReplayMQTT, "replayMQTT", "",
+		"replay an archived MQTT session file at original timing (implies -noVideo and -noMQTT)")
+	flag.IntVar(&config.ProfilePort, "pprof", 0,
+		"serve pprof profiles and runtime metrics on this port of 127.0.0.1 (0: off)")
 	flag.Parse()
 
+	if config.ReplayMQTT != "" {
+		// Replaying archived traffic must never touch cameras or a live broker.
+		config.NoVideo = true
+		config.NoMQTT = true
+	}
+
 	if err := config.ResolveAndEnsureConfigDir(); err != nil {
 		return nil, err
 	}
@@ -138,7 +449,7 @@ An absolute path can be provded if needed.`, config.GetInstallDir()))
 	}
 
 	logging.SetVerbose(*verbose || *verboseAlt)
-	logDir := filepath.Join(config.GetRuntimeDir(), "logs")
+	logDir := config.GetLogDir()
 	if err := logging.Init(logDir); err != nil {
 		return nil, fmt.Errorf("failed to initialize logging: %w", err)
 	}
@@ -148,6 +459,10 @@ An absolute path can be provded if needed.`, config.GetInstallDir()))
 		return nil, fmt.Errorf("error loading configuration: %w", err)
 	}
 
+	if config.Viewer {
+		config.NoMQTT = true
+	}
+
 	config.SetCameraConfigs(cfg.Cameras)
 	return cfg, nil
 }
@@ -192,7 +507,30 @@ func UpdateConfigFile(configFile, owlcmsAddress string) error {
 		lines = append(lines[:portLineIndex+1], append([]string{newLine}, lines[portLineIndex+1:]...)...)
 	}
 
-	return os.WriteFile(configFile, []byte(strings.Join(lines, "\n")), 0644)
+	return config.WriteConfigFile(configFile, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// UpdatePort updates the HTTP port value in the config file.
+func UpdatePort(configFile string, port int) error {
+	input, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	lines := strings.Split(string(input), "\n")
+	for i, line := range lines {
+		key := strings.TrimSpace(strings.SplitN(line, "=", 2)[0])
+		if key == "port" {
+			leadingSpace := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			lines[i] = fmt.Sprintf("%sport = %d", leadingSpace, port)
+			output := strings.Join(lines, "\n")
+			if err := config.WriteConfigFile(configFile, []byte(output), 0644); err != nil {
+				return fmt.Errorf("failed to write config file: %v", err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no port setting found in %s", configFile)
 }
 
 // UpdatePlatform updates the platform value in the config file.
@@ -227,12 +565,79 @@ func UpdatePlatform(configFile, platform string) error {
 	}
 
 	output := strings.Join(lines, "\n")
-	if err := os.WriteFile(configFile, []byte(output), 0644); err != nil {
+	if err := config.WriteConfigFile(configFile, []byte(output), 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 	return nil
 }
 
+// UpdatePreRoll saves the pre-roll of platform, in milliseconds, in the config
+// file; without a platform the default preRollMs is changed.
+func UpdatePreRoll(configFile, platform string, ms int) error {
+	input, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	key, value := "preRollMs", strconv.Itoa(ms)
+	if platform != "" {
+		preRolls := map[string]int{}
+		if currentConfig != nil {
+			for p, preRoll := range currentConfig.PlatformRoll {
+				preRolls[p] = preRoll
+			}
+		}
+		preRolls[platform] = ms
+		if currentConfig != nil {
+			currentConfig.PlatformRoll = preRolls
+		}
+		key, value = "preRollByPlatformMs", inlineTable(preRolls)
+	} else if currentConfig != nil {
+		currentConfig.PreRoll = ms
+	}
+
+	lines := strings.Split(string(input), "\n")
+	line := fmt.Sprintf("%s = %s", key, value)
+	found := false
+	for i, l := range lines {
+		if fields := strings.Fields(l); len(fields) > 0 && strings.TrimSuffix(fields[0], "=") == key {
+			lines[i] = line
+			found = true
+			break
+		}
+	}
+	if !found {
+		// Keys after the first section header would belong to it.
+		at := len(lines)
+		for i, l := range lines {
+			if strings.HasPrefix(strings.TrimSpace(l), "[") {
+				at = i
+				break
+			}
+		}
+		lines = append(lines[:at], append([]string{line}, lines[at:]...)...)
+	}
+
+	if err := config.WriteConfigFile(configFile, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+// inlineTable writes values as a TOML inline table, keys sorted.
+func inlineTable(values map[string]int) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := make([]string, len(keys))
+	for i, key := range keys {
+		entries[i] = fmt.Sprintf("%q = %d", key, values[key])
+	}
+	return "{ " + strings.Join(entries, ", ") + " }"
+}
+
 // UpdateMpegTSConfig writes the [mpeg-ts] section to the config file.
 func UpdateMpegTSConfig(configFile string, settings config.MulticastSettings) error {
 	content, err := os.ReadFile(configFile)
@@ -266,6 +671,64 @@ func UpdateMpegTSConfig(configFile string, settings config.MulticastSettings) er
 		fmt.Sprintf("    camera3Port = %d", settings.Camera3Port),
 		fmt.Sprintf("    camera4Port = %d", settings.Camera4Port),
 	}
+	if settings.ClipSource != "" {
+		newSection = append(newSection, fmt.Sprintf("    clipSource = %q", settings.ClipSource))
+	}
+	if settings.ContextCamera > 0 {
+		newSection = append(newSection, fmt.Sprintf("    contextCamera = %d", settings.ContextCamera))
+	}
+	if settings.ScoreboardPort > 0 {
+		newSection = append(newSection, fmt.Sprintf("    scoreboardPort = %d", settings.ScoreboardPort))
+	}
+	if settings.SRTHost != "" {
+		newSection = append(newSection,
+			fmt.Sprintf("    srtHost = %q", settings.SRTHost),
+			fmt.Sprintf("    srtLatencyMs = %d", settings.SRTLatency),
+			fmt.Sprintf("    srtPassphrase = %q", settings.SRTPassphrase),
+		)
+	}
+	for _, offset := range settings.CameraOffsetsMs {
+		if offset != 0 {
+			newSection = append(newSection, fmt.Sprintf("    cameraOffsetsMs = %s", tomlInts(settings.CameraOffsetsMs)))
+			break
+		}
+	}
+	if len(settings.FrameAccurateCameras) > 0 {
+		newSection = append(newSection, fmt.Sprintf("    frameAccurateCameras = %s", tomlInts(settings.FrameAccurateCameras)))
+	}
+	for _, audio := range settings.Audio {
+		newSection = append(newSection,
+			"",
+			"[[mpeg-ts.audio]]",
+			fmt.Sprintf("    camera = %d", audio.Camera),
+			fmt.Sprintf("    device = %q", audio.Device),
+			fmt.Sprintf("    codec = %q", audio.Codec),
+			fmt.Sprintf("    bitrate = %q", audio.Bitrate),
+		)
+	}
+
+	for _, profile := range settings.Trim {
+		newSection = append(newSection,
+			"",
+			"[[mpeg-ts.trim]]",
+			fmt.Sprintf("    camera = %d", profile.Camera),
+		)
+		if profile.Crf > 0 {
+			newSection = append(newSection, fmt.Sprintf("    crf = %d", profile.Crf))
+		}
+		if profile.Preset != "" {
+			newSection = append(newSection, fmt.Sprintf("    preset = %q", profile.Preset))
+		}
+		if profile.Crop != "" {
+			newSection = append(newSection, fmt.Sprintf("    crop = %q", profile.Crop))
+		}
+		if profile.Scale != "" {
+			newSection = append(newSection, fmt.Sprintf("    scale = %q", profile.Scale))
+		}
+		if profile.Container != "" {
+			newSection = append(newSection, fmt.Sprintf("    container = %q", profile.Container))
+		}
+	}
 
 	var newLines []string
 	if sectionStart >= 0 {
@@ -288,5 +751,136 @@ func UpdateMpegTSConfig(configFile string, settings config.MulticastSettings) er
 		newLines = append(newLines, lines[insertAt:]...)
 	}
 
-	return os.WriteFile(configFile, []byte(strings.Join(newLines, "\n")), 0644)
+	return config.WriteConfigFile(configFile, []byte(strings.Join(newLines, "\n")), 0644)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTrimProfile clears the settings of a [[mpeg-ts.trim]] section that
+// ffmpeg would reject, so that the replays are still trimmed.
+func checkTrimProfile(profile *config.TrimProfile) {
+	profile.Preset = strings.TrimSpace(profile.Preset)
+	profile.Crop = strings.TrimSpace(profile.Crop)
+	profile.Scale = strings.TrimSpace(profile.Scale)
+	profile.Container = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(profile.Container), "."))
+	profile.Codec = strings.ToLower(strings.TrimSpace(profile.Codec))
+	if profile.Crf < 0 || profile.Crf > 51 {
+		logging.WarningLogger.Printf("Ignoring crf %d of the trim section of camera %d, expected 0 to 51", profile.Crf, profile.Camera)
+		profile.Crf = 0
+	}
+	if profile.Preset != "" && !contains(config.X264Presets, profile.Preset) {
+		logging.WarningLogger.Printf("Ignoring unknown preset %q of the trim section of camera %d, expected one of %s", profile.Preset, profile.Camera, strings.Join(config.X264Presets, ", "))
+		profile.Preset = ""
+	}
+	if profile.Crop != "" {
+		if _, _, _, _, err := config.ParseCrop(profile.Crop); err != nil {
+			logging.WarningLogger.Printf("Ignoring crop of the trim section of camera %d: %v", profile.Camera, err)
+			profile.Crop = ""
+		}
+	}
+	if strings.ContainsAny(profile.Scale, ",;[]' ") {
+		logging.WarningLogger.Printf("Ignoring scale %q of the trim section of camera %d, expected a size such as \"1920:-2\"", profile.Scale, profile.Camera)
+		profile.Scale = ""
+	}
+	if profile.Container != "" && !contains(config.TrimContainers, profile.Container) {
+		logging.WarningLogger.Printf("Ignoring unknown container %q of the trim section of camera %d, expected one of %s", profile.Container, profile.Camera, strings.Join(config.TrimContainers, ", "))
+		profile.Container = ""
+	}
+	if profile.Codec != "" && !contains(config.TrimCodecs, profile.Codec) {
+		logging.WarningLogger.Printf("Ignoring unknown codec %q of the trim section of camera %d, expected one of %s", profile.Codec, profile.Camera, strings.Join(config.TrimCodecs, ", "))
+		profile.Codec = ""
+	}
+	if profile.Codec == "prores" && (profile.Container == "" || profile.Container == "mp4") {
+		logging.WarningLogger.Printf("ProRes replays of camera %d cannot be mp4 files, using mov", profile.Camera)
+		profile.Container = "mov"
+	}
+}
+
+// ramRecordingDir is the RAM-backed directory used for recordingDir = "ram".
+// Each instance records in its own folder inside it, named after its
+// installation directory, so that one instance never removes the recordings
+// of another when it cleans up.
+const ramRecordingDir = "/dev/shm/replays"
+
+// resolveRecordingDir returns the directory where recordings are written
+// while they run, or "" for the video directory.
+func resolveRecordingDir(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if strings.EqualFold(value, "ram") {
+		if runtime.GOOS != "linux" {
+			logging.WarningLogger.Printf("recordingDir = \"ram\" is only available on Linux; give the folder of a RAM disk instead. Recording in the video directory")
+			return ""
+		}
+		value = filepath.Join(ramRecordingDir, filepath.Base(config.GetInstallDir()))
+	} else if !filepath.IsAbs(value) {
+		value = filepath.Join(config.GetInstallDir(), value)
+	}
+	if err := os.MkdirAll(value, os.ModePerm); err != nil {
+		logging.WarningLogger.Printf("Cannot use recordingDir %s, recording in the video directory: %v", value, err)
+		return ""
+	}
+	logging.InfoLogger.Printf("Recordings are written in %s and moved to the video directory when kept", value)
+	return value
+}
+
+// tomlInts formats a TOML array of integers.
+func tomlInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.Itoa(value)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// SaveCameraOffsets stores the calibrated delay of each camera (by camera
+// number) in the [mpeg-ts] section of the configuration file.
+func SaveCameraOffsets(configFile string, offsets map[int]int) error {
+	settings, err := ReadCameraSources(configFile)
+	if err != nil {
+		return err
+	}
+	settings.CameraOffsetsMs = make([]int, 4)
+	for camera, offset := range offsets {
+		if camera >= 1 && camera <= 4 {
+			settings.CameraOffsetsMs[camera-1] = offset
+		}
+	}
+	return UpdateMpegTSConfig(configFile, settings)
+}
+
+// positiveMillis converts a number of milliseconds from config.toml; a
+// negative value means none.
+func positiveMillis(ms int) time.Duration {
+	if ms < 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// positiveSeconds converts a number of seconds from config.toml; a negative
+// value disables the corresponding check.
+func positiveSeconds(seconds int) time.Duration {
+	if seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// positiveGB converts a number of gigabytes from config.toml to bytes; a
+// negative value means no limit.
+func positiveGB(gb float64) int64 {
+	if gb < 0 {
+		return 0
+	}
+	return int64(gb * (1 << 30))
 }
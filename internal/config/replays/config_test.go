@@ -0,0 +1,79 @@
+package replays
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestUpdatePreRoll(t *testing.T) {
+	defer func(saved *Config) { currentConfig = saved }(currentConfig)
+	currentConfig = &Config{PlatformRoll: map[string]int{"B": 8000}}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("port = 8091\npreRollMs = 5000\n\n[mpeg-ts]\ncamera1Port = 9001\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePreRoll(path, "A", 12000); err != nil {
+		t.Fatalf("UpdatePreRoll: %v", err)
+	}
+	if err := UpdatePreRoll(path, "", 7000); err != nil {
+		t.Fatalf("UpdatePreRoll: %v", err)
+	}
+
+	var cfg Config
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		content, _ := os.ReadFile(path)
+		t.Fatalf("the updated file does not parse: %v\n%s", err, content)
+	}
+	if cfg.PreRoll != 7000 || cfg.PlatformRoll["A"] != 12000 || cfg.PlatformRoll["B"] != 8000 {
+		t.Fatalf("preRollMs = %d, preRollByPlatformMs = %v", cfg.PreRoll, cfg.PlatformRoll)
+	}
+	if content, _ := os.ReadFile(path); strings.Count(string(content), "preRollMs") != 1 {
+		t.Fatalf("preRollMs written more than once:\n%s", content)
+	}
+}
+
+func TestJuryDevicesAdjustTheReplays(t *testing.T) {
+	defer func(devices []string, encoder, size string) {
+		config.JuryDevices, config.TrimEncoder, config.ReplaySize = devices, encoder, size
+	}(config.JuryDevices, config.TrimEncoder, config.ReplaySize)
+	config.JuryDevices, config.TrimEncoder, config.ReplaySize = []string{"chrome", "old-ipad"}, "auto", ""
+
+	cameras := []config.CameraConfiguration{
+		{Format: "mpegts"},
+		{Format: "mpegts", Trim: &config.TrimProfile{Camera: 2, Codec: "hevc", Container: "mkv"}},
+		{Format: "mpegts", Trim: &config.TrimProfile{Camera: 3, Crop: "0,0,1280,720"}},
+	}
+	if format := replayFormatOf(cameras[0], 1920, 1080, 60); format.codec != "" || format.level != 42 {
+		t.Fatalf("stream copy of 1080p60 = %+v, want an unknown codec", format)
+	}
+	if format := replayFormatOf(config.CameraConfiguration{Format: "v4l2"}, 1920, 1080, 60); format.codec != "h264" {
+		t.Fatalf("recording of a local camera = %+v", format)
+	}
+	if format := replayFormatOf(cameras[2], 1920, 1080, 60); format.width != 1280 || format.level != 32 {
+		t.Fatalf("1280x720 crop of 1080p60 = %+v", format)
+	}
+
+	checkJuryDevices(cameras, 1920, 1080, 60, true)
+	if cameras[0].Trim == nil || cameras[0].Trim.Scale != "-2:720" || !cameras[0].TrimRecodes() {
+		t.Fatalf("1080p60 stream copies not scaled down for level 4.1: %+v", cameras[0].Trim)
+	}
+	if trim := cameras[1].Trim; trim.VideoCodec() != "h264" || trim.Extension() != ".mp4" || trim.Scale != "-2:720" {
+		t.Fatalf("HEVC mkv replays adjusted to %+v", trim)
+	}
+	if trim := cameras[2].Trim; trim.Scale != "" {
+		t.Fatalf("720p replays scaled to %q", trim.Scale)
+	}
+
+	config.ReplaySize = "1920x1080"
+	normalized := []config.CameraConfiguration{{Format: "mpegts"}}
+	checkJuryDevices(normalized, 0, 0, 50, true)
+	if config.ReplaySize != "1280x720" {
+		t.Fatalf("replaySize adjusted to %q", config.ReplaySize)
+	}
+}
@@ -0,0 +1,209 @@
+package replays
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// juryHeights are the heights replays are scaled down to, largest first, when
+// they are too big for the H.264 level of the jury devices.
+var juryHeights = []int{1080, 720, 540, 480, 360}
+
+// setJuryDevices sets config.JuryDevices from juryDevices, dropping the
+// names that are not in config.JuryDeviceTypes.
+func setJuryDevices(names []string) {
+	config.JuryDevices = nil
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := config.JuryDeviceTypes[name]; !ok {
+			known := make([]string, 0, len(config.JuryDeviceTypes))
+			for device := range config.JuryDeviceTypes {
+				known = append(known, device)
+			}
+			sort.Strings(known)
+			logging.WarningLogger.Printf("Ignoring unknown jury device %q, expected one of %s", name, strings.Join(known, ", "))
+			continue
+		}
+		config.JuryDevices = append(config.JuryDevices, name)
+	}
+}
+
+// replayFormat is what the replays of a camera are made of, as far as the
+// configuration tells: the size and level are 0 when they are not known. The
+// level is the H.264 level of that size, whatever the codec.
+type replayFormat struct {
+	codec     string
+	container string
+	width     int
+	height    int
+	level     int
+}
+
+// replayFormatOf works out the replays of a camera whose stream is
+// width x height at fps (0 when not configured). Stream copies keep the codec
+// of the stream, H.264 or HEVC as the camera node was set up: it is "" until
+// a recording is probed.
+func replayFormatOf(camera config.CameraConfiguration, width, height, fps int) replayFormat {
+	camera = withStreamSize(camera, width, height)
+	format := replayFormat{container: strings.TrimPrefix(camera.Trim.Extension(), ".")}
+	if w, h, err := config.ParseFrameSize(camera.Size); err == nil {
+		width, height = w, h
+	}
+	if camera.Format != "mpegts" || camera.Recode {
+		// Recorded or re-encoded here, in H.264.
+		format.codec = "h264"
+	}
+	if camera.TrimRecodes() {
+		format.codec = camera.Trim.VideoCodec()
+		if format.codec == "h264" && strings.HasPrefix(config.TrimEncoder, "hevc") {
+			format.codec = "hevc"
+		}
+		if _, _, w, h, ok := camera.CropRegion(); ok {
+			width, height = w, h
+		}
+		if camera.Normalizes() {
+			width, height, _ = config.ParseFrameSize(config.ReplaySize)
+		} else if camera.Trim != nil && camera.Trim.Scale != "" {
+			width, height = scaledSize(camera.Trim.Scale, width, height)
+		}
+	}
+	format.width, format.height = width, height
+	if width > 0 && height > 0 && fps > 0 {
+		format.level = config.H264Level(width, height, float64(fps))
+	}
+	return format
+}
+
+// withStreamSize gives the camera the size of the stream when it does not set
+// its own, as the recorder does from the recordings, so that only the cameras
+// of another size than replaySize count as letterboxed.
+func withStreamSize(camera config.CameraConfiguration, width, height int) config.CameraConfiguration {
+	if camera.Size == "" && width > 0 && height > 0 {
+		camera.Size = fmt.Sprintf("%dx%d", width, height)
+	}
+	return camera
+}
+
+// scaledSize returns the size the ffmpeg scale filter gives a picture of
+// width x height, for a scale such as "1280:720" or "-2:720". 0, 0 when it
+// cannot be told.
+func scaledSize(scale string, width, height int) (int, int) {
+	w, h, ok := strings.Cut(scale, ":")
+	if !ok {
+		return 0, 0
+	}
+	sw, errW := strconv.Atoi(w)
+	sh, errH := strconv.Atoi(h)
+	switch {
+	case errW != nil || errH != nil:
+		return 0, 0
+	case sw > 0 && sh > 0:
+		return sw, sh
+	case width <= 0 || height <= 0:
+		return 0, 0
+	case sw > 0:
+		return sw, evenRound(float64(height) * float64(sw) / float64(width))
+	case sh > 0:
+		return evenRound(float64(width) * float64(sh) / float64(height)), sh
+	}
+	return 0, 0
+}
+
+func evenRound(value float64) int {
+	return 2 * int(value/2+0.5)
+}
+
+// checkJuryDevices warns about the cameras whose replays a jury device cannot
+// play. With adjust, it first changes their trim settings so that they can:
+// H.264 in mp4 files, scaled down to the level of the devices.
+func checkJuryDevices(cameras []config.CameraConfiguration, width, height, fps int, adjust bool) {
+	for i := range cameras {
+		camera := &cameras[i]
+		cameraNumber := i + 1
+		format := replayFormatOf(*camera, width, height, fps)
+		if format.codec == "" {
+			warnUnknownCodec(cameraNumber, format)
+			format.codec = "h264"
+		}
+		problems := config.JuryPlaybackProblems(format.codec, "", format.level, format.container)
+		if len(problems) > 0 && adjust {
+			normalizes := withStreamSize(*camera, width, height).Normalizes()
+			if changes := adjustForJury(camera, cameraNumber, format, fps, normalizes); len(changes) > 0 {
+				logging.InfoLogger.Printf("Camera %d: %s for the jury devices", cameraNumber, strings.Join(changes, ", "))
+				format = replayFormatOf(*camera, width, height, fps)
+				if format.codec == "" {
+					format.codec = "h264"
+				}
+				problems = config.JuryPlaybackProblems(format.codec, "", format.level, format.container)
+			}
+		}
+		for _, problem := range problems {
+			logging.WarningLogger.Printf("Camera %d: the jury cannot play the replays on %s", cameraNumber, problem)
+		}
+		if len(problems) > 0 && !adjust {
+			logging.WarningLogger.Printf("Camera %d: set adjustForJuryDevices = true to make replays the jury devices play", cameraNumber)
+		}
+	}
+}
+
+// warnUnknownCodec warns, when the jury devices do not all play HEVC, that
+// the stream copies of a camera are checked as H.264 until the codec of its
+// stream is probed on the first replay.
+func warnUnknownCodec(cameraNumber int, format replayFormat) {
+	if len(config.JuryPlaybackProblems("hevc", "", 0, format.container)) == 0 {
+		return
+	}
+	logging.WarningLogger.Printf("Camera %d: the codec of the stream is not known before it is recorded; its replays are checked as H.264 for the jury devices, and again once the first one is probed", cameraNumber)
+}
+
+// adjustForJury changes the trim settings of a camera so that the jury
+// devices play its replays, and says what it changed. The trim section of a
+// camera is its own copy, the other cameras keep theirs. normalizes tells
+// whether the replays of the camera are letterboxed to replaySize.
+func adjustForJury(camera *config.CameraConfiguration, cameraNumber int, format replayFormat, fps int, normalizes bool) []string {
+	if camera.Trim == nil {
+		camera.Trim = &config.TrimProfile{Camera: cameraNumber}
+	}
+	var changes []string
+	if format.codec != "h264" {
+		if camera.Trim.VideoCodec() != "h264" {
+			camera.Trim.Codec = ""
+		}
+		if strings.HasPrefix(config.TrimEncoder, "hevc") {
+			// Shared by the cameras: the others are H.264 from now on too.
+			config.TrimEncoder = "auto"
+		}
+		changes = append(changes, "H.264 instead of "+format.codec)
+	}
+	if len(config.JuryPlaybackProblems("h264", "", 0, format.container)) > 0 {
+		camera.Trim.Container = ""
+		changes = append(changes, "mp4 instead of "+format.container)
+	}
+	known := format.width > 0 && format.height > 0 && fps > 0
+	if known && (format.level == 0 || format.level > config.MaxJuryLevel()) {
+		for _, height := range juryHeights {
+			if height >= format.height {
+				continue
+			}
+			width := evenRound(float64(format.width) * float64(height) / float64(format.height))
+			if level := config.H264Level(width, height, float64(fps)); level == 0 || level > config.MaxJuryLevel() {
+				continue
+			}
+			if normalizes {
+				// The replay size wins over the scale of the trim section.
+				config.ReplaySize = fmt.Sprintf("%dx%d", width, height)
+				changes = append(changes, "replaySize "+config.ReplaySize)
+			} else {
+				camera.Trim.Scale = fmt.Sprintf("-2:%d", height)
+				changes = append(changes, fmt.Sprintf("scaled to %dp", height))
+			}
+			break
+		}
+	}
+	return changes
+}
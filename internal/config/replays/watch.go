@@ -0,0 +1,95 @@
+package replays
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fsnotify/fsnotify"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// configChangeDelay lets an editor finish writing before the file is read.
+const configChangeDelay = 500 * time.Millisecond
+
+// WatchConfigFile calls onChange whenever configFile is written or replaced.
+// Bursts of events are coalesced. The directory is watched rather than the
+// file because many editors save by renaming a new file over the old one.
+// The returned function stops watching.
+func WatchConfigFile(configFile string, onChange func()) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	target := filepath.Clean(configFile)
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(configChangeDelay, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logging.WarningLogger.Printf("Watching %s: %v", configFile, err)
+			}
+		}
+	}()
+	return func() { watcher.Close() }, nil
+}
+
+// ReadCameraSources reads and validates the [mpeg-ts] camera stream section
+// of configFile without touching the rest of the running configuration.
+func ReadCameraSources(configFile string) (config.MulticastSettings, error) {
+	var cfg Config
+	if _, err := toml.DecodeFile(configFile, &cfg); err != nil {
+		return config.MulticastSettings{}, fmt.Errorf("failed to parse %s: %w", filepath.Base(configFile), err)
+	}
+	settings := cfg.Multicast
+	settings.Enabled = true
+	settings.ApplyDefaults()
+
+	if net.ParseIP(settings.IP) == nil {
+		return settings, fmt.Errorf("[mpeg-ts] ip %q is not a valid IP address", settings.IP)
+	}
+	seen := make(map[int]int)
+	for i, port := range []int{settings.Camera1Port, settings.Camera2Port, settings.Camera3Port, settings.Camera4Port} {
+		if port == 0 {
+			continue
+		}
+		if port < 0 || port > 65535 {
+			return settings, fmt.Errorf("[mpeg-ts] camera%dPort %d is not between 1 and 65535", i+1, port)
+		}
+		if other, dup := seen[port]; dup {
+			return settings, fmt.Errorf("[mpeg-ts] camera%dPort and camera%dPort both use port %d", other, i+1, port)
+		}
+		seen[port] = i + 1
+	}
+	if port := settings.ScoreboardPort; port != 0 {
+		if port < 0 || port > 65535 {
+			return settings, fmt.Errorf("[mpeg-ts] scoreboardPort %d is not between 1 and 65535", port)
+		}
+		if other, dup := seen[port]; dup {
+			return settings, fmt.Errorf("[mpeg-ts] scoreboardPort and camera%dPort both use port %d", other, port)
+		}
+	}
+	return settings, nil
+}
@@ -0,0 +1,76 @@
+package replays
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadCameraSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("port = 8091\n[mpeg-ts]\nip = \"239.255.0.1\"\ncamera1Port = 9001\ncamera2Port = 9002\n")
+	settings, err := ReadCameraSources(path)
+	if err != nil {
+		t.Fatalf("ReadCameraSources: %v", err)
+	}
+	if !settings.Enabled || settings.Camera1Port != 9001 || settings.Camera2Port != 9002 {
+		t.Fatalf("unexpected settings: %+v", settings)
+	}
+
+	write("[mpeg-ts]\ncamera1Port = 9001\ncamera3Port = 9001\n")
+	if _, err := ReadCameraSources(path); err == nil {
+		t.Fatalf("expected an error for a duplicate port")
+	}
+
+	write("[mpeg-ts]\ncamera1Port = 9001\nscoreboardPort = 9001\n")
+	if _, err := ReadCameraSources(path); err == nil {
+		t.Fatalf("expected an error for a scoreboard port used by a camera")
+	}
+
+	write("[mpeg-ts]\nip = \"not-an-ip\"\n")
+	if _, err := ReadCameraSources(path); err == nil {
+		t.Fatalf("expected an error for an invalid IP")
+	}
+}
+
+func TestWatchConfigFileCoalescesWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("port = 8091\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 10)
+	stop, err := WatchConfigFile(path, func() { changes <- struct{}{} })
+	if err != nil {
+		t.Fatalf("WatchConfigFile: %v", err)
+	}
+	defer stop()
+
+	if err := os.WriteFile(filepath.Join(dir, "other.toml"), []byte("x = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte("port = 8092\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no change reported")
+	}
+	select {
+	case <-changes:
+		t.Fatalf("writes in a burst should be reported once")
+	case <-time.After(2 * configChangeDelay):
+	}
+}
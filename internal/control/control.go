@@ -0,0 +1,161 @@
+// Package control is the channel through which a replays machine manages its
+// camera nodes. Each cameras program connects to replays over a WebSocket
+// (/control on the replays web port), announces itself, and then answers the
+// requests replays sends it: list, start, stop or restart streams, change a
+// stream's bitrate, fetch the node's log and replace its encoder settings
+// (ffmpeg.toml) with those of replays.
+package control
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Path is the WebSocket endpoint served by replays.
+const Path = "/control"
+
+// TokenHeader carries the shared token when one is configured on both sides.
+const TokenHeader = "X-Control-Token"
+
+// Message types.
+const (
+	TypeHello    = "hello"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+)
+
+// Commands sent by replays to a camera node.
+const (
+	CommandStatus  = "status"
+	CommandStart   = "start"
+	CommandStop    = "stop"
+	CommandRestart = "restart"
+	CommandBitrate = "bitrate"
+	CommandLog     = "log"
+	CommandConfig  = "config"
+)
+
+// maxLogLines bounds the log returned by one request.
+const maxLogLines = 1000
+
+// Message is the single JSON message exchanged in both directions.
+type Message struct {
+	Type string `json:"type"`
+	ID   int64  `json:"id,omitempty"`
+
+	// hello
+	Node    string `json:"node,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// request
+	Command string `json:"command,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	Bitrate string `json:"bitrate,omitempty"`
+	Lines   int    `json:"lines,omitempty"`
+	Config  string `json:"config,omitempty"`
+
+	// response (and hello, which carries the initial state)
+	Error      string         `json:"error,omitempty"`
+	Streams    []StreamStatus `json:"streams,omitempty"`
+	Log        string         `json:"log,omitempty"`
+	ConfigHash string         `json:"configHash,omitempty"`
+}
+
+// StreamStatus describes one source of a camera node.
+type StreamStatus struct {
+	Port    int    `json:"port"`
+	Name    string `json:"name"`
+	ShortID string `json:"shortId,omitempty"`
+	Running bool   `json:"running"`
+	Status  string `json:"status,omitempty"`
+	// Bitrate is the target set through the control channel, empty when the
+	// encoder settings apply.
+	Bitrate string `json:"bitrate,omitempty"`
+}
+
+// Handler is implemented by the camera node.
+type Handler interface {
+	Streams() []StreamStatus
+	Start(port int) error
+	Stop(port int) error
+	Restart(port int) error
+	// SetBitrate restarts the stream with the new target bitrate; an empty
+	// bitrate goes back to the encoder settings.
+	SetBitrate(port int, bitrate string) error
+	Log(lines int) (string, error)
+	// ConfigHash identifies the node's ffmpeg.toml (see ConfigHash).
+	ConfigHash() string
+	// ApplyConfig replaces the node's ffmpeg.toml and restarts its streams
+	// with it.
+	ApplyConfig(content []byte) error
+}
+
+// ConfigHash identifies the content of an ffmpeg.toml, so that replays can
+// tell which nodes run different encoder settings.
+func ConfigHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:6])
+}
+
+var bitratePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[kKmM]?$`)
+
+// ValidateBitrate accepts ffmpeg bitrates such as 2500k or 4M.
+func ValidateBitrate(bitrate string) error {
+	bitrate = strings.TrimSpace(bitrate)
+	if bitrate == "" || bitratePattern.MatchString(bitrate) {
+		return nil
+	}
+	return fmt.Errorf("invalid bitrate %q, expected a value such as 2500k or 4M", bitrate)
+}
+
+// Dispatch runs a request on the node and builds its response.
+func Dispatch(h Handler, req Message) Message {
+	resp := Message{Type: TypeResponse, ID: req.ID}
+	var err error
+	switch req.Command {
+	case CommandStatus:
+	case CommandStart:
+		err = h.Start(req.Port)
+	case CommandStop:
+		err = h.Stop(req.Port)
+	case CommandRestart:
+		err = h.Restart(req.Port)
+	case CommandBitrate:
+		if err = ValidateBitrate(req.Bitrate); err == nil {
+			err = h.SetBitrate(req.Port, strings.TrimSpace(req.Bitrate))
+		}
+	case CommandLog:
+		lines := req.Lines
+		if lines <= 0 || lines > maxLogLines {
+			lines = maxLogLines
+		}
+		resp.Log, err = h.Log(lines)
+	case CommandConfig:
+		if req.Config == "" {
+			err = fmt.Errorf("no configuration sent")
+		} else {
+			err = h.ApplyConfig([]byte(req.Config))
+		}
+	default:
+		err = fmt.Errorf("unknown command %q", req.Command)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	resp.Streams = h.Streams()
+	resp.ConfigHash = h.ConfigHash()
+	return resp
+}
+
+// TailLines returns the last n lines of text.
+func TailLines(text string, n int) string {
+	text = strings.TrimRight(text, "\n")
+	lines := strings.Split(text, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,127 @@
+package control
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type fakeNode struct {
+	restarted []int
+	bitrate   string
+	config    []byte
+}
+
+func (f *fakeNode) Streams() []StreamStatus {
+	return []StreamStatus{{Port: 9001, Name: "Platform", Running: true, Bitrate: f.bitrate}}
+}
+func (f *fakeNode) Start(port int) error { return nil }
+func (f *fakeNode) Stop(port int) error  { return fmt.Errorf("no stream on port %d", port) }
+func (f *fakeNode) Restart(port int) error {
+	f.restarted = append(f.restarted, port)
+	return nil
+}
+func (f *fakeNode) SetBitrate(port int, bitrate string) error {
+	f.bitrate = bitrate
+	return nil
+}
+func (f *fakeNode) Log(lines int) (string, error) { return TailLines("a\nb\nc\n", lines), nil }
+func (f *fakeNode) ConfigHash() string            { return ConfigHash(f.config) }
+func (f *fakeNode) ApplyConfig(content []byte) error {
+	f.config = content
+	return nil
+}
+
+func waitForNode(t *testing.T, name string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, n := range Nodes() {
+			if n.Node == name {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("node %s did not connect", name)
+}
+
+func TestCallReachesConnectedNode(t *testing.T) {
+	SetToken("secret")
+	t.Cleanup(func() { SetToken("") })
+	server := httptest.NewServer(http.HandlerFunc(HandleNode))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	if _, resp, err := websocket.DefaultDialer.Dial(ControlURL(address), nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("a node without the token must be refused")
+	}
+
+	header := http.Header{}
+	header.Set(TokenHeader, "secret")
+	conn, _, err := websocket.DefaultDialer.Dial(ControlURL(address), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fake := &fakeNode{}
+	go serveNode(conn, "gym-left", "test", fake)
+	waitForNode(t, "gym-left")
+
+	if _, err := Call("gym-left", Message{Command: CommandRestart, Port: 9001}); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if len(fake.restarted) != 1 || fake.restarted[0] != 9001 {
+		t.Fatalf("restart not delivered: %v", fake.restarted)
+	}
+	if _, err := Call("gym-left", Message{Command: CommandStop, Port: 9002}); err == nil {
+		t.Fatal("the node's error must be returned")
+	}
+	if _, err := Call("gym-left", Message{Command: CommandBitrate, Port: 9001, Bitrate: "fast"}); err == nil {
+		t.Fatal("an invalid bitrate must be refused")
+	}
+	resp, err := Call("gym-left", Message{Command: CommandBitrate, Port: 9001, Bitrate: "2500k"})
+	if err != nil || len(resp.Streams) != 1 || resp.Streams[0].Bitrate != "2500k" {
+		t.Fatalf("bitrate: %+v %v", resp, err)
+	}
+	resp, err = Call("gym-left", Message{Command: CommandLog, Lines: 2})
+	if err != nil || resp.Log != "b\nc" {
+		t.Fatalf("log: %q %v", resp.Log, err)
+	}
+	if _, err := Call("missing", Message{Command: CommandStatus}); err == nil {
+		t.Fatal("expected an error for an unknown node")
+	}
+}
+
+func TestConnectingNodeReceivesDifferentEncoderSettings(t *testing.T) {
+	shared := []byte("[output]\ngopMultiplier = 2\n")
+	SetConfigSource(func() ([]byte, error) { return shared, nil }, true)
+	t.Cleanup(func() { SetConfigSource(nil, false) })
+	server := httptest.NewServer(http.HandlerFunc(HandleNode))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(ControlURL(strings.TrimPrefix(server.URL, "http://")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fake := &fakeNode{config: []byte("[output]\ngopMultiplier = 1\n")}
+	go serveNode(conn, "gym-right", "test", fake)
+	waitForNode(t, "gym-right")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, n := range Nodes() {
+			if n.Node == "gym-right" && n.ConfigHash == ConfigHash(shared) {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("encoder settings not synchronized: %+v", Nodes())
+}
@@ -0,0 +1,289 @@
+package control
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// requestTimeout bounds a request; a restart probes the camera first, which
+// can take a while.
+const requestTimeout = 30 * time.Second
+
+// helloTimeout is how long a new connection has to announce itself.
+const helloTimeout = 10 * time.Second
+
+// NodeInfo is a connected camera node as last reported.
+type NodeInfo struct {
+	Node        string
+	Version     string
+	Address     string
+	ConnectedAt time.Time
+	Streams     []StreamStatus
+	ConfigHash  string
+}
+
+type node struct {
+	info    NodeInfo
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	pending map[int64]chan Message
+}
+
+var (
+	hubMu  sync.Mutex
+	nodes  = make(map[string]*node)
+	nextID int64
+	token  string
+
+	onNodesChanged func()
+	configSource   func() ([]byte, error)
+	autoSync       bool
+
+	upgrader = websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+)
+
+// SetToken sets the token camera nodes must present. Empty accepts any node.
+func SetToken(value string) {
+	hubMu.Lock()
+	token = value
+	hubMu.Unlock()
+}
+
+// HandleNode serves the WebSocket connection of a camera node.
+func HandleNode(w http.ResponseWriter, r *http.Request) {
+	if !TokenAccepted(r.Header.Get(TokenHeader)) {
+		logging.WarningLogger.Printf("Camera node %s rejected: wrong control token", r.RemoteAddr)
+		http.Error(w, "wrong control token", http.StatusUnauthorized)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logging.ErrorLogger.Printf("Camera node WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var hello Message
+	conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	if err := conn.ReadJSON(&hello); err != nil || hello.Type != TypeHello || hello.Node == "" {
+		logging.WarningLogger.Printf("Camera node %s did not announce itself", r.RemoteAddr)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	n := &node{
+		info: NodeInfo{
+			Node:        hello.Node,
+			Version:     hello.Version,
+			Address:     r.RemoteAddr,
+			ConnectedAt: time.Now(),
+			Streams:     hello.Streams,
+			ConfigHash:  hello.ConfigHash,
+		},
+		conn:    conn,
+		pending: make(map[int64]chan Message),
+	}
+	hubMu.Lock()
+	if previous, ok := nodes[hello.Node]; ok {
+		previous.conn.Close()
+	}
+	nodes[hello.Node] = n
+	syncConfig := autoSync
+	hubMu.Unlock()
+	logging.InfoLogger.Printf("Camera node %s connected from %s with %d stream(s)", hello.Node, r.RemoteAddr, len(hello.Streams))
+	notifyNodesChanged()
+	if syncConfig {
+		go syncNodeConfig(hello.Node, hello.ConfigHash)
+	}
+
+	for {
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			break
+		}
+		if msg.Type != TypeResponse {
+			continue
+		}
+		hubMu.Lock()
+		if msg.Streams != nil {
+			n.info.Streams = msg.Streams
+		}
+		if msg.ConfigHash != "" {
+			n.info.ConfigHash = msg.ConfigHash
+		}
+		reply, ok := n.pending[msg.ID]
+		delete(n.pending, msg.ID)
+		hubMu.Unlock()
+		if ok {
+			reply <- msg
+		}
+	}
+
+	hubMu.Lock()
+	if nodes[hello.Node] == n {
+		delete(nodes, hello.Node)
+	}
+	for id, reply := range n.pending {
+		close(reply)
+		delete(n.pending, id)
+	}
+	hubMu.Unlock()
+	logging.InfoLogger.Printf("Camera node %s disconnected", hello.Node)
+	notifyNodesChanged()
+}
+
+// Nodes returns the connected camera nodes, by name.
+func Nodes() []NodeInfo {
+	hubMu.Lock()
+	list := make([]NodeInfo, 0, len(nodes))
+	for _, n := range nodes {
+		info := n.info
+		info.Streams = append([]StreamStatus(nil), n.info.Streams...)
+		list = append(list, info)
+	}
+	hubMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Node < list[j].Node })
+	return list
+}
+
+// Call sends a request to a camera node and waits for its response. An error
+// reported by the node is returned as an error.
+func Call(nodeName string, req Message) (Message, error) {
+	hubMu.Lock()
+	n, ok := nodes[nodeName]
+	if !ok {
+		hubMu.Unlock()
+		return Message{}, fmt.Errorf("camera node %s is not connected", nodeName)
+	}
+	nextID++
+	req.ID = nextID
+	req.Type = TypeRequest
+	reply := make(chan Message, 1)
+	n.pending[req.ID] = reply
+	hubMu.Unlock()
+
+	n.writeMu.Lock()
+	err := n.conn.WriteJSON(req)
+	n.writeMu.Unlock()
+	if err != nil {
+		hubMu.Lock()
+		delete(n.pending, req.ID)
+		hubMu.Unlock()
+		return Message{}, fmt.Errorf("camera node %s: %w", nodeName, err)
+	}
+
+	select {
+	case resp, ok := <-reply:
+		if !ok {
+			return Message{}, fmt.Errorf("camera node %s disconnected", nodeName)
+		}
+		notifyNodesChanged()
+		if resp.Error != "" {
+			return resp, fmt.Errorf("camera node %s: %s", nodeName, resp.Error)
+		}
+		return resp, nil
+	case <-time.After(requestTimeout):
+		hubMu.Lock()
+		delete(n.pending, req.ID)
+		hubMu.Unlock()
+		return Message{}, fmt.Errorf("camera node %s did not answer %s within %s", nodeName, req.Command, requestTimeout)
+	}
+}
+
+// SetConfigSource sets where the encoder settings pushed to nodes are read
+// from. With automatic, every node that connects with different settings
+// receives them.
+func SetConfigSource(source func() ([]byte, error), automatic bool) {
+	hubMu.Lock()
+	configSource = source
+	autoSync = automatic
+	hubMu.Unlock()
+}
+
+// LocalConfigHash returns the hash of the settings pushed to nodes, or "".
+func LocalConfigHash() string {
+	content, err := readConfigSource()
+	if err != nil {
+		return ""
+	}
+	return ConfigHash(content)
+}
+
+// PushConfig sends the encoder settings to a node, which restarts its
+// streams with them.
+func PushConfig(nodeName string) error {
+	content, err := readConfigSource()
+	if err != nil {
+		return err
+	}
+	_, err = Call(nodeName, Message{Command: CommandConfig, Config: string(content)})
+	return err
+}
+
+func readConfigSource() ([]byte, error) {
+	hubMu.Lock()
+	source := configSource
+	hubMu.Unlock()
+	if source == nil {
+		return nil, fmt.Errorf("no encoder settings to send")
+	}
+	return source()
+}
+
+// syncNodeConfig pushes the encoder settings to a node that does not run them.
+func syncNodeConfig(nodeName, nodeHash string) {
+	local := LocalConfigHash()
+	if local == "" || local == nodeHash {
+		return
+	}
+	logging.InfoLogger.Printf("Camera node %s runs different encoder settings, sending ffmpeg.toml", nodeName)
+	if err := PushConfig(nodeName); err != nil {
+		logging.ErrorLogger.Printf("Failed to synchronize the encoder settings of %s: %v", nodeName, err)
+		return
+	}
+	logging.InfoLogger.Printf("Camera node %s now runs the encoder settings of replays", nodeName)
+}
+
+// SetNodesChangedHandler sets the function called when a node connects,
+// disconnects or reports its streams. nil removes it.
+func SetNodesChangedHandler(fn func()) {
+	hubMu.Lock()
+	onNodesChanged = fn
+	hubMu.Unlock()
+}
+
+func notifyNodesChanged() {
+	hubMu.Lock()
+	fn := onNodesChanged
+	hubMu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// TokenAccepted reports whether value may be accepted: any value when no
+// token is configured, the configured token otherwise.
+func TokenAccepted(value string) bool {
+	hubMu.Lock()
+	expected := token
+	hubMu.Unlock()
+	return expected == "" || subtle.ConstantTimeCompare([]byte(value), []byte(expected)) == 1
+}
+
+// TokenMatches reports whether value is the configured token. It is false
+// when no token is configured, so that it can protect other endpoints.
+func TokenMatches(value string) bool {
+	hubMu.Lock()
+	expected := token
+	hubMu.Unlock()
+	return expected != "" && subtle.ConstantTimeCompare([]byte(value), []byte(expected)) == 1
+}
@@ -0,0 +1,80 @@
+package control
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// reconnectDelay is the wait between attempts to reach replays.
+const reconnectDelay = 5 * time.Second
+
+// ControlURL returns the WebSocket URL of the control endpoint of the replays
+// machine at address (host:port, or a full ws:// URL).
+func ControlURL(address string) string {
+	address = strings.TrimSpace(address)
+	if strings.HasPrefix(address, "ws://") || strings.HasPrefix(address, "wss://") {
+		return address
+	}
+	u := url.URL{Scheme: "ws", Host: address, Path: Path}
+	return u.String()
+}
+
+// RunNode keeps this camera node connected to the replays machine at
+// address, answering its requests with h. It never returns.
+func RunNode(address, nodeToken, nodeName, version string, h Handler) {
+	controlURL := ControlURL(address)
+	header := http.Header{}
+	if nodeToken != "" {
+		header.Set(TokenHeader, nodeToken)
+	}
+	connected := false
+	for {
+		conn, resp, err := websocket.DefaultDialer.Dial(controlURL, header)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+				logging.ErrorLogger.Printf("Replays at %s refused this node: the control token does not match", address)
+			} else if connected {
+				logging.WarningLogger.Printf("Lost the control connection to replays at %s: %v", address, err)
+			}
+			connected = false
+			time.Sleep(reconnectDelay)
+			continue
+		}
+		connected = true
+		logging.InfoLogger.Printf("Control connection to replays at %s established", address)
+		serveNode(conn, nodeName, version, h)
+		conn.Close()
+		time.Sleep(reconnectDelay)
+	}
+}
+
+// serveNode announces the node and answers requests until the connection
+// closes. Requests are handled one at a time, in order.
+func serveNode(conn *websocket.Conn, nodeName, version string, h Handler) {
+	hello := Message{Type: TypeHello, Node: nodeName, Version: version, Streams: h.Streams(), ConfigHash: h.ConfigHash()}
+	if err := conn.WriteJSON(hello); err != nil {
+		return
+	}
+	for {
+		var req Message
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		if req.Type != TypeRequest {
+			continue
+		}
+		logging.InfoLogger.Printf("Control request from replays: %s port %d", req.Command, req.Port)
+		resp := Dispatch(h, req)
+		if resp.Error != "" {
+			logging.WarningLogger.Printf("Control request %s port %d failed: %s", req.Command, req.Port, resp.Error)
+		}
+		if err := conn.WriteJSON(resp); err != nil {
+			return
+		}
+	}
+}
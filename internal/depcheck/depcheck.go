@@ -0,0 +1,139 @@
+// Package depcheck verifies at startup what replays and cameras need from the
+// machine they run on (capture tools, camera permissions, firewall rules) and
+// offers a fix, or instructions, for what is missing. These environmental
+// problems are the most frequent support requests.
+package depcheck
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// Issue is a problem found by a startup check.
+type Issue struct {
+	Title  string
+	Detail string
+	// FixLabel and Fix are set when the problem can be fixed in one click;
+	// otherwise Detail tells the operator what to do.
+	FixLabel string
+	Fix      func() error
+}
+
+// Options selects the checks that apply to the calling program.
+type Options struct {
+	// Cameras checks what capturing from local cameras needs.
+	Cameras    bool
+	FFmpegPath string
+	// HTTPPort (TCP) and UDPPorts must accept incoming connections.
+	HTTPPort int
+	UDPPorts []int
+}
+
+// Run performs the checks for the current platform and logs every issue.
+func Run(opts Options) []Issue {
+	issues := platformChecks(opts)
+	for _, issue := range issues {
+		logging.WarningLogger.Printf("Startup check: %s", issue.Title)
+	}
+	return issues
+}
+
+// combinedOutputWithin runs cmd and kills it when it takes longer than
+// timeout, so that a check never holds up startup.
+func combinedOutputWithin(cmd *exec.Cmd, timeout time.Duration) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	timer := time.AfterFunc(timeout, func() {
+		_ = cmd.Process.Kill()
+	})
+	err := cmd.Wait()
+	timer.Stop()
+	return output.Bytes(), err
+}
+
+// firewallRulePrefix names the inbound rules created for replays and cameras.
+const firewallRulePrefix = "owlcms replays"
+
+type firewallPort struct {
+	protocol string
+	port     int
+}
+
+func (p firewallPort) ruleName() string {
+	return fmt.Sprintf("%s %s %d", firewallRulePrefix, p.protocol, p.port)
+}
+
+// addRuleArgs returns the netsh arguments that allow incoming traffic on p.
+func (p firewallPort) addRuleArgs() []string {
+	return []string{
+		"advfirewall", "firewall", "add", "rule",
+		"name=" + p.ruleName(),
+		"dir=in", "action=allow",
+		"protocol=" + p.protocol,
+		"localport=" + strconv.Itoa(p.port),
+		"profile=any",
+	}
+}
+
+func firewallPorts(opts Options) []firewallPort {
+	var ports []firewallPort
+	if opts.HTTPPort > 0 {
+		ports = append(ports, firewallPort{protocol: "TCP", port: opts.HTTPPort})
+	}
+	seen := map[int]bool{}
+	for _, port := range opts.UDPPorts {
+		if port <= 0 || seen[port] {
+			continue
+		}
+		seen[port] = true
+		ports = append(ports, firewallPort{protocol: "UDP", port: port})
+	}
+	return ports
+}
+
+// firewallAllows reports whether the listing of inbound rules (netsh ... show
+// rule name=all dir=in verbose) covers p, either with the rule replays
+// creates or with a rule for the program itself, such as the one Windows adds
+// when "Allow access" is chosen on its prompt. Field labels are localized, so
+// only the rule name and the program path are looked for.
+func firewallAllows(rules string, p firewallPort, program string) bool {
+	lower := strings.ToLower(rules)
+	if strings.Contains(lower, strings.ToLower(p.ruleName())) {
+		return true
+	}
+	return program != "" && strings.Contains(lower, strings.ToLower(program))
+}
+
+func describePorts(ports []firewallPort) string {
+	names := make([]string, 0, len(ports))
+	for _, p := range ports {
+		names = append(names, fmt.Sprintf("%s port %d", p.protocol, p.port))
+	}
+	return strings.Join(names, ", ")
+}
+
+// firewallCommands lists the commands an administrator can run to open ports.
+func firewallCommands(ports []firewallPort) string {
+	lines := make([]string, 0, len(ports))
+	for _, p := range ports {
+		args := p.addRuleArgs()
+		for i, arg := range args {
+			if strings.Contains(arg, " ") {
+				key, value, _ := strings.Cut(arg, "=")
+				args[i] = fmt.Sprintf("%s=%q", key, value)
+			}
+		}
+		lines = append(lines, "netsh "+strings.Join(args, " "))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,48 @@
+//go:build darwin
+
+package depcheck
+
+import (
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/jobutil"
+)
+
+// cameraPrivacyPane opens Privacy & Security > Camera in System Settings.
+const cameraPrivacyPane = "x-apple.systempreferences:com.apple.preference.security?Privacy_Camera"
+
+func platformChecks(opts Options) []Issue {
+	var issues []Issue
+	if opts.Cameras && opts.FFmpegPath != "" {
+		if issue, ok := checkCameraPermission(opts.FFmpegPath); ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// checkCameraPermission grabs one frame from the first camera. macOS asks for
+// permission the first time; afterwards a refusal shows in ffmpeg's output.
+func checkCameraPermission(ffmpegPath string) (Issue, bool) {
+	cmd := jobutil.Command(ffmpegPath, "-hide_banner", "-f", "avfoundation", "-i", "0", "-frames:v", "1", "-f", "null", "-")
+	output, _ := combinedOutputWithin(cmd, 15*time.Second)
+	if !cameraAccessDenied(string(output)) {
+		return Issue{}, false
+	}
+	return Issue{
+		Title:    "Camera access is not allowed",
+		Detail:   "macOS blocks the cameras. In System Settings > Privacy & Security > Camera, allow the cameras program (or the terminal it is started from), then restart it.",
+		FixLabel: "Open Camera Privacy Settings",
+		Fix: func() error {
+			return jobutil.Command("open", cameraPrivacyPane).Run()
+		},
+	}, true
+}
+
+func cameraAccessDenied(output string) bool {
+	lower := strings.ToLower(output)
+	return strings.Contains(lower, "not authorized") ||
+		strings.Contains(lower, "not granted") ||
+		strings.Contains(lower, "access denied")
+}
@@ -0,0 +1,48 @@
+//go:build linux
+
+package depcheck
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/owlcms/replays/internal/jobutil"
+)
+
+func platformChecks(opts Options) []Issue {
+	var issues []Issue
+	if opts.Cameras {
+		if issue, ok := checkV4l2Ctl(); ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// checkV4l2Ctl verifies that v4l2-ctl, used to list USB cameras and their
+// formats, is installed.
+func checkV4l2Ctl() (Issue, bool) {
+	if _, err := exec.LookPath("v4l2-ctl"); err == nil {
+		return Issue{}, false
+	}
+	issue := Issue{
+		Title:  "v4l2-ctl is not installed",
+		Detail: "USB cameras cannot be detected without it. Install the v4l-utils package, for example with\nsudo apt install v4l-utils",
+	}
+	if _, err := exec.LookPath("pkexec"); err != nil {
+		return issue, true
+	}
+	if _, err := exec.LookPath("apt-get"); err != nil {
+		return issue, true
+	}
+	issue.FixLabel = "Install v4l-utils"
+	issue.Fix = func() error {
+		output, err := jobutil.Command("pkexec", "apt-get", "install", "-y", "v4l-utils").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("installing v4l-utils failed: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return issue, true
+}
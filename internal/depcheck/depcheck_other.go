@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !windows
+
+package depcheck
+
+func platformChecks(opts Options) []Issue {
+	return nil
+}
@@ -0,0 +1,46 @@
+package depcheck
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFirewallPortsSkipsUnsetAndDuplicatePorts(t *testing.T) {
+	ports := firewallPorts(Options{HTTPPort: 8091, UDPPorts: []int{9001, 0, 9001, 9002}})
+	want := []string{"TCP 8091", "UDP 9001", "UDP 9002"}
+	if len(ports) != len(want) {
+		t.Fatalf("got %d ports, want %d", len(ports), len(want))
+	}
+	for i, p := range ports {
+		if got := p.ruleName(); got != firewallRulePrefix+" "+want[i] {
+			t.Errorf("port %d: rule name %q", i, got)
+		}
+	}
+}
+
+func TestFirewallAllows(t *testing.T) {
+	rules := `Rule Name:                            owlcms replays TCP 8091
+Enabled:                              Yes
+Direction:                            In
+
+Rule Name:                            cameras.exe
+Program:                              C:\owlcms\cameras.exe
+`
+	if !firewallAllows(rules, firewallPort{protocol: "TCP", port: 8091}, `C:\other.exe`) {
+		t.Error("rule created by replays not recognized")
+	}
+	if !firewallAllows(rules, firewallPort{protocol: "UDP", port: 9001}, `c:\OWLCMS\cameras.exe`) {
+		t.Error("program rule not recognized")
+	}
+	if firewallAllows(rules, firewallPort{protocol: "UDP", port: 9001}, `C:\owlcms\replays.exe`) {
+		t.Error("port reported as allowed without a rule")
+	}
+}
+
+func TestFirewallCommandsQuoteRuleNames(t *testing.T) {
+	got := firewallCommands([]firewallPort{{protocol: "UDP", port: 9001}})
+	want := `netsh advfirewall firewall add rule name="owlcms replays UDP 9001" dir=in action=allow protocol=UDP localport=9001 profile=any`
+	if strings.TrimSpace(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build windows
+
+package depcheck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+func platformChecks(opts Options) []Issue {
+	var issues []Issue
+	if issue, ok := checkFirewall(firewallPorts(opts)); ok {
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// checkFirewall verifies that inbound rules let browsers reach the HTTP port
+// and camera streams reach the UDP ports.
+func checkFirewall(ports []firewallPort) (Issue, bool) {
+	if len(ports) == 0 {
+		return Issue{}, false
+	}
+	output, err := combinedOutputWithin(jobutil.Command("netsh", "advfirewall", "firewall", "show", "rule", "name=all", "dir=in", "verbose"), 30*time.Second)
+	if err != nil {
+		// Without the listing nothing can be said; do not warn needlessly.
+		return Issue{}, false
+	}
+	program, _ := os.Executable()
+	var missing []firewallPort
+	for _, p := range ports {
+		if !firewallAllows(string(output), p, program) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return Issue{}, false
+	}
+	return Issue{
+		Title: "Windows Firewall may block incoming connections",
+		Detail: fmt.Sprintf("No firewall rule allows %s. Other machines, such as the jury tablets, may not reach this one. "+
+			"Creating the rules needs administrator approval; alternatively, run these commands in an administrator command prompt:\n%s",
+			describePorts(missing), firewallCommands(missing)),
+		FixLabel: "Create Firewall Rules",
+		Fix: func() error {
+			return createFirewallRules(missing)
+		},
+	}, true
+}
+
+// createFirewallRules runs the netsh commands from a script started with
+// administrator rights, so that Windows asks for approval once for all ports.
+func createFirewallRules(ports []firewallPort) error {
+	script := filepath.Join(os.TempDir(), fmt.Sprintf("owlcms-firewall-%d.cmd", os.Getpid()))
+	content := "@echo off\r\n" + strings.ReplaceAll(firewallCommands(ports), "\n", "\r\n") + "\r\n"
+	if err := os.WriteFile(script, []byte(content), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(script)
+
+	// Start-Process -Verb RunAs shows the UAC prompt; declining it makes
+	// PowerShell fail, which is reported to the operator.
+	elevate := fmt.Sprintf("Start-Process -FilePath cmd.exe -ArgumentList '/c \"%s\"' -Verb RunAs -Wait -WindowStyle Hidden", strings.ReplaceAll(script, "'", "''"))
+	output, err := jobutil.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", elevate).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("creating firewall rules was cancelled or failed: %s", strings.TrimSpace(string(output)))
+	}
+
+	listing, err := combinedOutputWithin(jobutil.Command("netsh", "advfirewall", "firewall", "show", "rule", "name=all", "dir=in"), 30*time.Second)
+	if err != nil {
+		return nil
+	}
+	var still []firewallPort
+	for _, p := range ports {
+		if !firewallAllows(string(listing), p, "") {
+			still = append(still, p)
+		}
+	}
+	if len(still) > 0 {
+		return fmt.Errorf("firewall rules were not created for %s", describePorts(still))
+	}
+	logging.InfoLogger.Printf("Created firewall rules for %s", describePorts(ports))
+	return nil
+}
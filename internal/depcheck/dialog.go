@@ -0,0 +1,51 @@
+package depcheck
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// ShowIssues lists the problems found at startup, each with its fix button
+// or instructions.
+func ShowIssues(window fyne.Window, issues []Issue) {
+	if len(issues) == 0 {
+		return
+	}
+	rows := container.NewVBox()
+	for _, issue := range issues {
+		rows.Add(issueRow(window, issue))
+	}
+	d := dialog.NewCustom("Startup Checks", "Ignore", container.NewVScroll(rows), window)
+	d.Resize(fyne.NewSize(640, 420))
+	d.Show()
+}
+
+func issueRow(window fyne.Window, issue Issue) fyne.CanvasObject {
+	title := widget.NewLabel(issue.Title)
+	title.TextStyle = fyne.TextStyle{Bold: true}
+	detail := widget.NewLabel(issue.Detail)
+	detail.Wrapping = fyne.TextWrapWord
+	row := container.NewVBox(title, detail)
+	if issue.Fix == nil {
+		return row
+	}
+
+	var button *widget.Button
+	button = widget.NewButton(issue.FixLabel, func() {
+		button.Disable()
+		go func() {
+			if err := issue.Fix(); err != nil {
+				logging.ErrorLogger.Printf("Startup check fix %q failed: %v", issue.FixLabel, err)
+				dialog.ShowError(err, window)
+				button.Enable()
+				return
+			}
+			logging.InfoLogger.Printf("Startup check fix %q done", issue.FixLabel)
+		}()
+	})
+	row.Add(container.NewHBox(button))
+	return row
+}
@@ -0,0 +1,142 @@
+// Package housekeeping runs the nightly maintenance that keeps a machine left
+// on for a multi-day meet healthy without the operator: the log is rotated,
+// the old logs, ffmpeg logs and failure reports are deleted, and so are the
+// old sessions when the videos have a retention limit.
+package housekeeping
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/recording"
+)
+
+const (
+	// busyRetry is how often maintenance checks whether the attempt it
+	// waits for is saved.
+	busyRetry = time.Minute
+	// busyGiveUp skips the night when recording never stops, as during a
+	// long recording.
+	busyGiveUp = time.Hour
+)
+
+// isBusy reports whether an attempt is being recorded or saved.
+var isBusy = recording.IsBusy
+
+// Start runs the maintenance every night at config.NightlyAt.
+func Start() {
+	if config.NightlyAt == "" {
+		logging.InfoLogger.Println("Nightly maintenance is disabled")
+		return
+	}
+	logging.InfoLogger.Printf("Nightly maintenance runs at %s", config.NightlyAt)
+	go func() {
+		for {
+			next := nextRun(time.Now(), config.NightlyAt)
+			time.Sleep(time.Until(next))
+			if waitUntilIdle() {
+				Run()
+			}
+		}
+	}()
+}
+
+// nextRun returns the first time after now that is at ("15:04") local time.
+func nextRun(now time.Time, at string) time.Time {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		clock = time.Date(0, 1, 1, 3, 0, 0, 0, time.UTC)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// waitUntilIdle waits for the attempt in progress; false when it lasted so
+// long that maintenance is skipped until the next night.
+func waitUntilIdle() bool {
+	waited := time.Duration(0)
+	for isBusy() {
+		if waited >= busyGiveUp {
+			logging.WarningLogger.Printf("Nightly maintenance skipped: still recording after %v", busyGiveUp)
+			return false
+		}
+		time.Sleep(busyRetry)
+		waited += busyRetry
+	}
+	return true
+}
+
+// Run rotates the log, deletes the logs older than config.LogRetention and
+// cleans up the videos.
+func Run() {
+	now := time.Now()
+	logging.InfoLogger.Println("Nightly maintenance started")
+	if rotated, err := logging.Rotate(now.Format("20060102_150405")); err != nil {
+		logging.ErrorLogger.Printf("Failed to rotate the log: %v", err)
+	} else if rotated != "" {
+		logging.InfoLogger.Printf("Previous log moved to %s", rotated)
+	}
+
+	if config.LogRetention > 0 {
+		removed := 0
+		for _, dir := range logDirs() {
+			removed += pruneLogs(dir, now.Add(-config.LogRetention))
+		}
+		logging.InfoLogger.Printf("Deleted %d log files older than %v", removed, config.LogRetention)
+	}
+
+	if !config.Viewer && (config.VideoMaxAge > 0 || config.MaxVideoBytes > 0) {
+		if report, err := httpServer.CleanupVideos(now); err != nil {
+			logging.ErrorLogger.Printf("Failed to clean up the videos: %v", err)
+		} else {
+			logging.InfoLogger.Printf("Video cleanup: %s", report)
+		}
+	}
+	logging.InfoLogger.Println("Nightly maintenance done")
+}
+
+// logDirs are the directories of the log of the program and of the logs
+// written next to it (ffmpeg output, attempt logs, failure reports).
+func logDirs() []string {
+	dirs := []string{filepath.Join(config.GetInstallDir(), "logs")}
+	if dir := logging.Dir(); dir != "" && filepath.Clean(dir) != filepath.Clean(dirs[0]) {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// pruneLogs deletes the log files under dir last written before cutoff and
+// returns how many were deleted.
+func pruneLogs(dir string, cutoff time.Time) int {
+	removed := 0
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".log", ".jsonl", ".txt":
+		default:
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			logging.WarningLogger.Printf("Failed to delete old log %s: %v", path, err)
+			return nil
+		}
+		removed++
+		return nil
+	})
+	return removed
+}
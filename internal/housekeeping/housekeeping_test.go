@@ -0,0 +1,51 @@
+package housekeeping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2025, 3, 1, 2, 30, 0, 0, time.Local)
+	if next := nextRun(now, "03:00"); !next.Equal(time.Date(2025, 3, 1, 3, 0, 0, 0, time.Local)) {
+		t.Errorf("next run before 03:00 = %v, want the same night", next)
+	}
+	now = time.Date(2025, 3, 1, 3, 0, 0, 0, time.Local)
+	if next := nextRun(now, "03:00"); !next.Equal(time.Date(2025, 3, 2, 3, 0, 0, 0, time.Local)) {
+		t.Errorf("next run at 03:00 = %v, want the next night", next)
+	}
+}
+
+func TestPruneLogsKeepsRecentLogsAndOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	files := map[string]bool{
+		"replays_20250101_030000.log": true,
+		"attempts/old_attempt1.jsonl": true,
+		"trim_failure_Camera1.txt":    true,
+		"replays.log":                 false,
+		"config.toml.20250101.bak":    false,
+		"attempts/new_attempt2.jsonl": false,
+	}
+	for name, isOld := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if isOld || name == "config.toml.20250101.bak" {
+			os.Chtimes(path, old, old)
+		}
+	}
+
+	if removed := pruneLogs(dir, time.Now().Add(-14*24*time.Hour)); removed != 3 {
+		t.Fatalf("removed %d files, want 3", removed)
+	}
+	for name, isOld := range files {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) == isOld {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, !isOld)
+		}
+	}
+}
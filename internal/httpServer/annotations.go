@@ -0,0 +1,200 @@
+package httpServer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// An annotation is what the jury chair drew on a paused frame of a replay
+// (lines, angles), kept next to the video as <video>_annotation.json and as
+// the rendered frame <video>_annotation.png for the record.
+const (
+	annotationSuffix   = "_annotation"
+	maxAnnotationBytes = 20 << 20
+	pngDataURLPrefix   = "data:image/png;base64,"
+)
+
+// AnnotationShape is a line (two points) or an angle (three points, the
+// vertex in the middle). Coordinates are fractions of the frame size.
+type AnnotationShape struct {
+	Type   string       `json:"type"`
+	Points [][2]float64 `json:"points"`
+}
+
+// Annotation is the drawing saved for a replay.
+type Annotation struct {
+	Time   float64           `json:"time"` // position of the frame in the video, in seconds
+	Shapes []AnnotationShape `json:"shapes"`
+}
+
+// annotationRequest is the body posted by the annotation page.
+type annotationRequest struct {
+	Annotation
+	PNG string `json:"png"` // the frame with the drawing, as a data URL
+}
+
+// AnnotateTemplateData is shown by annotate.html.
+type AnnotateTemplateData struct {
+	Session     string
+	File        string
+	DisplayName string
+	VideoURL    string
+	Annotation  string // JSON of the saved annotation, "" if none
+}
+
+// annotationBase returns the name of the annotation files of a video.
+func annotationBase(videoFile string) string {
+	return strings.TrimSuffix(videoFile, filepath.Ext(videoFile)) + annotationSuffix
+}
+
+// sessionAnnotations returns the videos of a session folder that have a
+// rendered annotation, with the name of its image.
+func sessionAnnotations(entries []os.DirEntry) map[string]string {
+	images := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), annotationSuffix+".png") {
+			images[entry.Name()] = true
+		}
+	}
+	annotations := make(map[string]string)
+	for _, entry := range entries {
+		if image := annotationBase(entry.Name()) + ".png"; !entry.IsDir() && images[image] {
+			annotations[entry.Name()] = image
+		}
+	}
+	return annotations
+}
+
+// annotatedVideoPath checks that a video of a session exists and returns its
+// storage name.
+func annotatedVideoPath(session, file string) (string, error) {
+	session, err := sanitizeReplaySessionID(session)
+	if err != nil || file == "" || filepath.Base(file) != file || isNoteFile(file) {
+		return "", os.ErrInvalid
+	}
+	name := storage.Join(session, file)
+	info, err := storage.Current().Stat(name)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", os.ErrInvalid
+	}
+	return name, nil
+}
+
+// saveAnnotation writes the JSON and PNG of an annotation next to its video.
+func saveAnnotation(videoPath string, request annotationRequest) error {
+	for _, shape := range request.Shapes {
+		if (shape.Type != "line" || len(shape.Points) != 2) && (shape.Type != "angle" || len(shape.Points) != 3) {
+			return fmt.Errorf("invalid %s shape with %d points", shape.Type, len(shape.Points))
+		}
+	}
+	if !strings.HasPrefix(request.PNG, pngDataURLPrefix) {
+		return fmt.Errorf("missing rendered image")
+	}
+	image, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(request.PNG, pngDataURLPrefix))
+	if err != nil {
+		return fmt.Errorf("invalid rendered image: %w", err)
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(image)); err != nil {
+		return fmt.Errorf("invalid rendered image: %w", err)
+	}
+	if request.Shapes == nil {
+		request.Shapes = []AnnotationShape{}
+	}
+	data, err := json.MarshalIndent(request.Annotation, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	store := storage.Current()
+	base := path.Join(path.Dir(videoPath), annotationBase(path.Base(videoPath)))
+	if err := store.WriteFile(base+".json", data); err != nil {
+		return err
+	}
+	return store.WriteFile(base+".png", image)
+}
+
+// handleAnnotate shows the annotation page of a replay:
+// GET /annotate/{session}/{file}.
+func handleAnnotate(w http.ResponseWriter, r *http.Request) {
+	session, file := mux.Vars(r)["session"], mux.Vars(r)["file"]
+	videoPath, err := annotatedVideoPath(session, file)
+	if err != nil {
+		http.Error(w, "Replay not found", http.StatusNotFound)
+		return
+	}
+	data := AnnotateTemplateData{
+		Session:     session,
+		File:        file,
+		DisplayName: file,
+		VideoURL:    "/videos/" + session + "/" + file,
+	}
+	if parsed, ok := parseReplayFilename(session, file); ok {
+		data.DisplayName = fmt.Sprintf("%s - %s attempt %d - Camera %d", parsed.Athlete, parsed.LiftType, parsed.AttemptNumber, parsed.Camera)
+	}
+	base := path.Join(path.Dir(videoPath), annotationBase(file))
+	if saved, err := storage.ReadFile(base + ".json"); err == nil {
+		data.Annotation = string(saved)
+	}
+	if err := templates.ExecuteTemplate(w, "annotate.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleSaveAnnotation stores the annotation of a replay:
+// POST /api/sessions/{session}/annotations/{file}.
+func handleSaveAnnotation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if config.Viewer {
+		http.Error(w, "A viewer does not save annotations", http.StatusServiceUnavailable)
+		return
+	}
+
+	session, file := mux.Vars(r)["session"], mux.Vars(r)["file"]
+	videoPath, err := annotatedVideoPath(session, file)
+	if err != nil {
+		http.Error(w, "Replay not found", http.StatusNotFound)
+		return
+	}
+	var request annotationRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAnnotationBytes)).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid annotation: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := saveAnnotation(videoPath, request); err != nil {
+		logging.ErrorLogger.Printf("Failed to save the annotation of %s: %v", videoPath, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logging.InfoLogger.Printf("Saved annotation of %s (%d shapes)", videoPath, len(request.Shapes))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"image": "/videos/" + session + "/" + annotationBase(file) + ".png",
+	})
+}
@@ -0,0 +1,150 @@
+package httpServer
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// An attempt link, /attempt/{session}/{athlete}/{lift}/{attempt}, names an
+// attempt the way the jury does rather than by file: it still resolves after
+// the replays are trimmed again, re-encoded or moved under a platform folder,
+// so it can be pasted in the jury chat. The athlete is written as in the file
+// names, "DOE_John"; case and underscores do not matter.
+
+// attemptRoute is the route of the attempt links.
+const attemptRoute = "/attempt/" + sessionRoute + "/{athlete}/{lift:(?i:snatch|cleanjerk)}/{attempt:[0-9]+}"
+
+// AttemptTemplateData is the data of attempt.html.
+type AttemptTemplateData struct {
+	Session     string
+	DisplayName string
+	Link        string
+	Lift        ReplayLift
+	Recorded    string // local date and time of the attempt
+	Quality     string // of the videos played, "" for the full-quality replays
+}
+
+// attemptLink returns the link of an attempt of a session.
+func attemptLink(session, athlete, lift string, attempt int) string {
+	segments := strings.Split(session, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	athlete = strings.ReplaceAll(strings.TrimSpace(athlete), " ", "_")
+	return "/attempt/" + strings.Join(segments, "/") + "/" + url.PathEscape(athlete) + "/" + lift + "/" + strconv.Itoa(attempt)
+}
+
+// sameAthlete compares an athlete of a link with one of a file name.
+func sameAthlete(a, b string) bool {
+	normalize := func(name string) string {
+		return strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(name, "_", " "))), " ")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// findAttempt returns the replays of an attempt of a session: the latest
+// recording of it when it was recorded more than once.
+func findAttempt(session, athlete, lift string, attempt int) (ReplayLift, bool, error) {
+	lifts, err := buildGroupedReplayLifts(session)
+	if err != nil {
+		return ReplayLift{}, false, err
+	}
+	sortReplayLifts(lifts, "time")
+	for _, candidate := range lifts {
+		if candidate.Attempt == attempt && strings.EqualFold(candidate.LiftType, lift) && sameAthlete(candidate.Athlete, athlete) {
+			return candidate, true, nil
+		}
+	}
+	return ReplayLift{}, false, nil
+}
+
+// resolveLinkSession finds the folder of the session of a link, which may
+// have been made before the sessions were stored by platform, or the other
+// way round.
+func resolveLinkSession(session string) (string, error) {
+	store := storage.Current()
+	if info, err := store.Stat(session); err == nil && info.IsDir() {
+		return session, nil
+	}
+	folders, err := sessionFolders(store)
+	if err != nil {
+		return "", err
+	}
+	found := findSession(folders, sessionName(session), sessionPlatform(session))
+	for _, folder := range folders {
+		if folder == found {
+			return folder, nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// handleAttempt serves GET /attempt/{session}/{athlete}/{lift}/{attempt}: a
+// page with the replays of the attempt, or the video of one camera with
+// ?camera=N (and its lower-bitrate copy with &quality=720p).
+func handleAttempt(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	session, err := sanitizeReplaySessionID(vars["session"])
+	if err != nil {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+	attempt, err := strconv.Atoi(vars["attempt"])
+	if err != nil {
+		http.Error(w, "Invalid attempt", http.StatusBadRequest)
+		return
+	}
+	session, err = resolveLinkSession(session)
+	if err != nil {
+		http.Error(w, "Replay session not found", http.StatusNotFound)
+		return
+	}
+	lift, ok, err := findAttempt(session, vars["athlete"], vars["lift"], attempt)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to list the replays of session %s: %v", session, err)
+		http.Error(w, "Failed to list session replays", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "No replay of this attempt", http.StatusNotFound)
+		return
+	}
+
+	if camera := r.URL.Query().Get("camera"); camera != "" {
+		for _, replay := range lift.Replays {
+			if strconv.Itoa(replay.Camera) == camera {
+				target := replay.URL
+				if quality := r.URL.Query().Get("quality"); qualityPattern.MatchString(quality) {
+					target += "?quality=" + quality
+				}
+				http.Redirect(w, r, target, http.StatusFound)
+				return
+			}
+		}
+		http.Error(w, "No replay of this attempt for camera "+camera, http.StatusNotFound)
+		return
+	}
+
+	data := AttemptTemplateData{
+		Session:     session,
+		DisplayName: lift.Athlete + " - " + lift.LiftType + " attempt " + strconv.Itoa(lift.Attempt),
+		Link:        lift.Link,
+		Lift:        lift,
+		Recorded:    localReplayTime(lift.Timestamp, time.Local),
+	}
+	if quality := r.URL.Query().Get("quality"); qualityPattern.MatchString(quality) {
+		data.Quality = quality
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if err := templates.ExecuteTemplate(w, "attempt.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,66 @@
+package httpServer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestAttemptLinkResolvesTheLatestRecording(t *testing.T) {
+	videoDir := t.TempDir()
+	sessionDir := filepath.Join(videoDir, "A", "M1")
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"2025-03-01_14h00m00s_DOE_John_SNATCH_attempt1_Camera1.mp4",
+		"2025-03-01_14h05m00s_DOE_John_SNATCH_attempt1_Camera1.mp4",
+		"2025-03-01_14h05m00s_DOE_John_SNATCH_attempt1_Camera2.mp4",
+		"2025-03-01_14h06m00s_ROE_Jane_SNATCH_attempt1_Camera1.mp4",
+	} {
+		if err := os.WriteFile(filepath.Join(sessionDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	router := mux.NewRouter()
+	router.HandleFunc(attemptRoute, handleAttempt)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	link := attemptLink("A/M1", "DOE John", "SNATCH", 1)
+	if link != "/attempt/A/M1/DOE_John/SNATCH/1" {
+		t.Fatalf("attemptLink() = %q", link)
+	}
+	rec := get(link)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: %d %s", link, rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "2025-03-01_14h05m00s_DOE_John_SNATCH_attempt1_Camera2.mp4") || strings.Contains(body, "14h00m00s") {
+		t.Fatalf("the page does not show the latest recording:\n%s", body)
+	}
+
+	// Without the platform, in another case, for one camera.
+	rec = get("/attempt/M1/doe_john/snatch/1?camera=2")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/videos/A/M1/2025-03-01_14h05m00s_DOE_John_SNATCH_attempt1_Camera2.mp4" {
+		t.Fatalf("camera 2: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	for _, missing := range []string{"/attempt/A/M1/DOE_John/SNATCH/2", "/attempt/A/M2/DOE_John/SNATCH/1", "/attempt/A/M1/DOE_John/SNATCH/1?camera=3"} {
+		if rec := get(missing); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: %d", missing, rec.Code)
+		}
+	}
+}
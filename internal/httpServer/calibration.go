@@ -0,0 +1,91 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// Calibration is recorded for CalibrationSeconds while the calibration page
+// flashes white for CalibrationFlashMs at the start of every second.
+const (
+	CalibrationSeconds = 8
+	CalibrationFlashMs = 200
+)
+
+// CalibrateCameras is set by the application; it records the calibration
+// page with all cameras, saves the correction of each camera and returns it
+// in milliseconds by camera number.
+var CalibrateCameras func() (map[int]int, error)
+
+// CalibrationOffset is the measured correction of one camera.
+type CalibrationOffset struct {
+	Camera   int `json:"camera"`
+	OffsetMs int `json:"offsetMs"`
+}
+
+// CalibrationResponse is returned by POST /api/calibration/start.
+type CalibrationResponse struct {
+	Offsets []CalibrationOffset `json:"offsets,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// CalibrationTemplateData fills calibration.html.
+type CalibrationTemplateData struct {
+	Seconds int
+	FlashMs int
+}
+
+// handleCalibrationPage serves the flashing millisecond clock filmed by the
+// cameras during calibration.
+func handleCalibrationPage(w http.ResponseWriter, _ *http.Request) {
+	data := CalibrationTemplateData{Seconds: CalibrationSeconds, FlashMs: CalibrationFlashMs}
+	if err := templates.ExecuteTemplate(w, "calibration.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleCalibrate serves POST /api/calibration/start; it answers once the
+// calibration is measured. It takes the control token: the cameras are tied
+// up meanwhile and their offsets are saved.
+func handleCalibrate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+control.TokenHeader)
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !controlAllowed(w, r) {
+		return
+	}
+	if CalibrateCameras == nil {
+		http.Error(w, "Calibration is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var response CalibrationResponse
+	status := http.StatusOK
+	offsets, err := CalibrateCameras()
+	if err != nil {
+		status = http.StatusConflict
+		response.Error = err.Error()
+	}
+	for camera, offset := range offsets {
+		response.Offsets = append(response.Offsets, CalibrationOffset{Camera: camera, OffsetMs: offset})
+	}
+	sort.Slice(response.Offsets, func(i, j int) bool { return response.Offsets[i].Camera < response.Offsets[j].Camera })
+
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.ErrorLogger.Printf("Failed to encode calibration response: %v", err)
+	}
+}
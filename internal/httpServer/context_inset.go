@@ -0,0 +1,44 @@
+package httpServer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// insetSuffix ends the name of the copy of a replay with the context
+// camera (e.g. the scoreboard) inset in its corner: <replay>_inset.mp4.
+const insetSuffix = "_inset.mp4"
+
+// InsetName returns the name of the copy of a replay with the context inset.
+func InsetName(replay string) string {
+	return strings.TrimSuffix(replay, filepath.Ext(replay)) + insetSuffix
+}
+
+// insetOwner returns the replay name without its extension of an inset
+// copy, "" for other files.
+func insetOwner(name string) string {
+	if !strings.HasSuffix(name, insetSuffix) {
+		return ""
+	}
+	return strings.TrimSuffix(name, insetSuffix)
+}
+
+func isInsetFile(name string) bool {
+	return insetOwner(name) != ""
+}
+
+// sessionInsets returns the inset copies of a session folder by replay name
+// without its extension, with urlPrefix before their names.
+func sessionInsets(entries []os.DirEntry, urlPrefix string) map[string]string {
+	copies := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if replay := insetOwner(entry.Name()); replay != "" {
+			copies[replay] = urlPrefix + entry.Name()
+		}
+	}
+	return copies
+}
@@ -0,0 +1,20 @@
+package httpServer
+
+import (
+	"net/http"
+
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// controlAllowed tells if a request may change what the recorder does: when
+// controlToken is set, the request must carry it in the X-Control-Token
+// header, as camera nodes do. It answers 401 otherwise.
+func controlAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if control.TokenAccepted(r.Header.Get(control.TokenHeader)) {
+		return true
+	}
+	logging.WarningLogger.Printf("%s %s from %s rejected: wrong control token", r.Method, r.URL.Path, r.RemoteAddr)
+	http.Error(w, "wrong control token", http.StatusUnauthorized)
+	return false
+}
@@ -0,0 +1,112 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/state"
+)
+
+// RecorderState and SubscribedTopics are set by the application for
+// /debug/state; the recorder and the MQTT monitor depend on this package.
+var (
+	RecorderState    func() interface{}
+	SubscribedTopics func() []string
+)
+
+// debugVerbose opens /debug/state without the control token, when replays
+// runs with --verbose.
+var debugVerbose bool
+
+// DebugState is the in-memory state of replays, returned by /debug/state.
+type DebugState struct {
+	Time      string      `json:"time"`
+	Version   string      `json:"version"`
+	Platform  string      `json:"platform,omitempty"`
+	Platforms []string    `json:"platforms,omitempty"`
+	Session   string      `json:"session,omitempty"`
+	Athlete   string      `json:"athlete,omitempty"`
+	LiftType  string      `json:"liftType,omitempty"`
+	Attempt   int         `json:"attempt,omitempty"`
+	Weight    int         `json:"weight,omitempty"`
+	Timers    DebugTimers `json:"timers"`
+	Status    string      `json:"status,omitempty"`
+	Recorder  interface{} `json:"recorder,omitempty"`
+	Topics    []string    `json:"topics"`
+}
+
+// DebugTimers are the last times owlcms started and stopped the clock and
+// gave a decision, empty when not received.
+type DebugTimers struct {
+	Start    string `json:"start,omitempty"`
+	Stop     string `json:"stop,omitempty"`
+	Decision string `json:"decision,omitempty"`
+}
+
+// debugTime formats a time in milliseconds, "" for 0.
+func debugTime(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).Format(time.RFC3339Nano)
+}
+
+// debugAllowed tells if a request may see the state: always with --verbose,
+// otherwise with the control token in the X-Control-Token header. It is not
+// taken from the URL, which ends up in logs and browser histories.
+func debugAllowed(r *http.Request) bool {
+	if debugVerbose {
+		return true
+	}
+	return control.TokenMatches(r.Header.Get(control.TokenHeader))
+}
+
+// handleDebugState serves GET /debug/state. It is hidden when not allowed.
+func handleDebugState(w http.ResponseWriter, r *http.Request) {
+	if !debugAllowed(r) {
+		http.NotFound(w, r)
+		return
+	}
+	mu.Lock()
+	status := statusMsg
+	mu.Unlock()
+
+	snapshot := DebugState{
+		Time:      time.Now().Format(time.RFC3339Nano),
+		Version:   config.GetProgramVersion(),
+		Platform:  state.CurrentPlatform,
+		Platforms: state.AvailablePlatforms,
+		Session:   state.CurrentSession,
+		Athlete:   state.CurrentAthlete,
+		LiftType:  state.CurrentLiftType,
+		Attempt:   state.CurrentAttempt,
+		Weight:    state.CurrentWeight,
+		Timers: DebugTimers{
+			Start:    debugTime(state.LastStartTime),
+			Stop:     debugTime(state.LastTimerStopTime),
+			Decision: debugTime(state.LastDecisionTime),
+		},
+		Status: status,
+		Topics: make([]string, 0),
+	}
+	if RecorderState != nil {
+		snapshot.Recorder = RecorderState()
+	}
+	if SubscribedTopics != nil {
+		if topics := SubscribedTopics(); topics != nil {
+			snapshot.Topics = topics
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(snapshot); err != nil {
+		logging.ErrorLogger.Printf("Failed to encode the debug state: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/state"
+)
+
+func TestDebugStateNeedsVerboseOrTheToken(t *testing.T) {
+	oldVerbose, oldAthlete := debugVerbose, state.CurrentAthlete
+	t.Cleanup(func() {
+		debugVerbose, state.CurrentAthlete = oldVerbose, oldAthlete
+		control.SetToken("")
+		RecorderState, SubscribedTopics = nil, nil
+	})
+	state.CurrentAthlete = "John Smith"
+	RecorderState = func() interface{} { return map[string]bool{"recording": true} }
+	SubscribedTopics = func() []string { return []string{"owlcms/fop/config"} }
+
+	get := func(target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			req.Header.Set(control.TokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		handleDebugState(rec, req)
+		return rec
+	}
+
+	debugVerbose = false
+	if rec := get("/debug/state", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("open without a token: %d", rec.Code)
+	}
+	control.SetToken("secret")
+	if rec := get("/debug/state", "wrong"); rec.Code != http.StatusNotFound {
+		t.Fatalf("open with a wrong token: %d", rec.Code)
+	}
+	if rec := get("/debug/state?token=secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("open with the token as a parameter: %d", rec.Code)
+	}
+
+	rec := get("/debug/state", "secret")
+	var snapshot struct {
+		Athlete  string          `json:"athlete"`
+		Recorder map[string]bool `json:"recorder"`
+		Topics   []string        `json:"topics"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Athlete != "John Smith" || !snapshot.Recorder["recording"] || len(snapshot.Topics) != 1 {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+
+	control.SetToken("")
+	debugVerbose = true
+	if rec := get("/debug/state", ""); rec.Code != http.StatusOK {
+		t.Fatalf("closed with --verbose: %d", rec.Code)
+	}
+}
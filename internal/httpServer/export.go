@@ -0,0 +1,213 @@
+package httpServer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// exportSuffix names the export files written in a session folder.
+const exportSuffix = "_replays"
+
+// SessionExport maps each attempt of a session to its video files, for the
+// results package sent to the federation. Lifts use the owlcms names.
+type SessionExport struct {
+	Session   string          `json:"session"`
+	Generated string          `json:"generated"`
+	Version   string          `json:"replaysVersion"`
+	Notes     string          `json:"notes,omitempty"` // operator notes of the session
+	Attempts  []ExportAttempt `json:"attempts"`
+}
+
+// ExportAttempt is one lift and its replays.
+type ExportAttempt struct {
+	Time    string       `json:"time"` // local time with its offset from UTC (RFC 3339)
+	Athlete string       `json:"athlete"`
+	Lift    string       `json:"lift"`
+	Attempt int          `json:"attempt"`
+	Files   []ExportFile `json:"files"`
+	Note    string       `json:"note,omitempty"` // voice note of the jury
+}
+
+// ExportFile is one replay with the hash that lets the federation check it.
+type ExportFile struct {
+	Camera     int    `json:"camera"`
+	File       string `json:"file"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	Annotation string `json:"annotation,omitempty"` // image of the marked frame
+}
+
+// LiftName returns the owlcms name of a lift type of the file names.
+func LiftName(liftType string) string {
+	switch liftType {
+	case "SNATCH":
+		return "Snatch"
+	case "CLEANJERK":
+		return "Clean&Jerk"
+	}
+	return liftType
+}
+
+func hashFile(name string) (int64, string, error) {
+	file, err := storage.Current().Open(name)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// BuildSessionExport lists the attempts of a session in the order they were
+// lifted and hashes their files.
+func BuildSessionExport(name string) (*SessionExport, error) {
+	session, err := sanitizeReplaySessionID(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session %q", name)
+	}
+	lifts, err := buildGroupedReplayLifts(session)
+	if err != nil {
+		return nil, err
+	}
+	sortReplayLifts(lifts, "time")
+
+	export := &SessionExport{
+		Session:   session,
+		Generated: time.Now().Format(time.RFC3339),
+		Version:   config.GetProgramVersion(),
+		Attempts:  make([]ExportAttempt, 0, len(lifts)),
+	}
+	if manifest, err := readSessionManifest(session); err == nil {
+		export.Notes = manifest.Notes
+	}
+	// Oldest first, as in the protocol of the session.
+	for i := len(lifts) - 1; i >= 0; i-- {
+		lift := lifts[i]
+		attempt := ExportAttempt{
+			Time:    lift.Time,
+			Athlete: lift.Athlete,
+			Lift:    LiftName(lift.LiftType),
+			Attempt: lift.Attempt,
+		}
+		if lift.Note != "" {
+			attempt.Note = path.Base(lift.Note)
+		}
+		for _, replay := range lift.Replays {
+			size, sum, err := hashFile(storage.Join(session, replay.Filename))
+			if err != nil {
+				return nil, err
+			}
+			file := ExportFile{Camera: replay.Camera, File: replay.Filename, Size: size, SHA256: sum}
+			if replay.Annotation != "" {
+				file.Annotation = path.Base(replay.Annotation)
+			}
+			attempt.Files = append(attempt.Files, file)
+		}
+		export.Attempts = append(export.Attempts, attempt)
+	}
+	return export, nil
+}
+
+// WriteCSV writes one row per file.
+func (e *SessionExport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"Session", "Time", "Athlete", "Lift", "Attempt", "Camera", "File", "Size", "SHA256"}); err != nil {
+		return err
+	}
+	for _, a := range e.Attempts {
+		for _, f := range a.Files {
+			row := []string{e.Session, a.Time, a.Athlete, a.Lift, strconv.Itoa(a.Attempt), strconv.Itoa(f.Camera), f.File, strconv.FormatInt(f.Size, 10), f.SHA256}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteJSON writes the export as indented JSON.
+func (e *SessionExport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e)
+}
+
+// ExportSession writes <session>_replays.csv and .json in the session folder
+// and returns their paths (their storage names when they have no local file).
+func ExportSession(session string) ([]string, error) {
+	export, err := BuildSessionExport(session)
+	if err != nil {
+		return nil, err
+	}
+	store := storage.Current()
+	base := storage.Join(export.Session, export.Session+exportSuffix)
+	var paths []string
+	for _, format := range []struct {
+		ext   string
+		write func(io.Writer) error
+	}{{".csv", export.WriteCSV}, {".json", export.WriteJSON}} {
+		name := base + format.ext
+		var content bytes.Buffer
+		if err := format.write(&content); err != nil {
+			return paths, err
+		}
+		if err := store.WriteFile(name, content.Bytes()); err != nil {
+			return paths, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if path, ok := store.LocalPath(name); ok {
+			name = path
+		}
+		paths = append(paths, name)
+	}
+	logging.InfoLogger.Printf("Exported %d attempt(s) of session %s to %v", len(export.Attempts), export.Session, paths)
+	return paths, nil
+}
+
+// handleSessionExport serves GET /api/sessions/{session}/export.{csv|json}.
+func handleSessionExport(w http.ResponseWriter, r *http.Request) {
+	setReplayAPIHeaders(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	export, err := BuildSessionExport(mux.Vars(r)["session"])
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "Replay session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := export.Session + exportSuffix + "." + mux.Vars(r)["format"]
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if mux.Vars(r)["format"] == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = export.WriteCSV(w)
+	} else {
+		err = export.WriteJSON(w)
+	}
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to send the export of session %s: %v", export.Session, err)
+	}
+}
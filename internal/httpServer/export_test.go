@@ -0,0 +1,73 @@
+package httpServer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestSessionExportListsAttemptsOldestFirstWithHashes(t *testing.T) {
+	videoDir := t.TempDir()
+	sessionDir := filepath.Join(videoDir, "M1")
+	if err := os.Mkdir(sessionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"2025-03-01_14h05m10s_Jane_Doe_CLEANJERK_attempt1_Camera1.mp4",
+		"2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4",
+		"2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera2.mp4",
+	} {
+		if err := os.WriteFile(filepath.Join(sessionDir, name), []byte("abc"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	export, err := BuildSessionExport("M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Attempts) != 2 || export.Attempts[0].Lift != "Snatch" || export.Attempts[1].Lift != "Clean&Jerk" {
+		t.Fatalf("attempts = %+v, want the snatch first", export.Attempts)
+	}
+	// Names without Z are from older versions, in local time.
+	if want := time.Date(2025, 3, 1, 14, 0, 0, 0, time.Local).Format(time.RFC3339); export.Attempts[0].Time != want || len(export.Attempts[0].Files) != 2 {
+		t.Fatalf("first attempt = %+v", export.Attempts[0])
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d CSV rows, want a header and 3 files", len(rows))
+	}
+	// sha256 of "abc"
+	if want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; rows[1][8] != want || rows[1][7] != "3" {
+		t.Fatalf("row = %v, want size 3 and hash %s", rows[1], want)
+	}
+
+	paths, err := ExportSession("M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("export file not written: %v", err)
+		}
+	}
+	if _, err := BuildSessionExport("M1"); err != nil {
+		t.Fatalf("export files must not be taken as replays: %v", err)
+	}
+}
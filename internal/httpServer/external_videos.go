@@ -0,0 +1,314 @@
+package httpServer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// ProbeVideo returns the duration of a video file, or an error if the file
+// is not a readable video. Set by the application (it needs ffprobe); when
+// nil, or when it returns ErrProbeUnavailable, files are accepted on their
+// extension alone.
+var ProbeVideo func(path string) (time.Duration, error)
+
+// ErrProbeUnavailable is returned by ProbeVideo when ffprobe cannot be run at
+// all, which says nothing about the file.
+var ErrProbeUnavailable = errors.New("ffprobe not found")
+
+// externalSettleDelay is how long a dropped file must stay unchanged before
+// it is indexed, so that copies in progress are not probed.
+const externalSettleDelay = 2 * time.Second
+
+var externalVideoExtensions = map[string]bool{
+	".mp4":  true,
+	".m4v":  true,
+	".mov":  true,
+	".webm": true,
+	".mkv":  true,
+}
+
+var (
+	attemptFilePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})_(\d{2}h\d{2}m\d{2}sZ?)_(.+)_(CLEANJERK|SNATCH)_attempt(\d+)_Camera(\d+)\.(?:mp4|mov|mkv)$`)
+
+	looseDatePattern    = regexp.MustCompile(`(\d{4})[-_.]?(\d{2})[-_.]?(\d{2})`)
+	looseTimePattern    = regexp.MustCompile(`(?:^|[^\d])(\d{2})[h:_.-]?(\d{2})[m:_.-]?(\d{2})s?(?:[^\d]|$)`)
+	looseCameraPattern  = regexp.MustCompile(`(?i)cam(?:era)?[ _-]?(\d+)`)
+	looseAttemptPattern = regexp.MustCompile(`(?i)(?:attempt|try)[ _-]?(\d)`)
+	looseLiftPattern    = regexp.MustCompile(`(?i)(clean[ _-]?(?:and|&)?[ _-]?jerk|cleanjerk|\bcj\b|snatch)`)
+)
+
+// externalVideo is the cached index entry of a file that was not produced by
+// replays. Only the readable videos are kept: a file that could not be probed
+// may still be copying.
+type externalVideo struct {
+	size        int64
+	modTime     time.Time
+	displayName string
+	sortKey     string
+}
+
+var (
+	externalIndex   = make(map[string]externalVideo)
+	externalProbing = make(map[string]bool)
+	externalIndexMu sync.Mutex
+)
+
+// IsVideoFile reports whether a file of a session folder is a video, from its
+// extension.
+func IsVideoFile(name string) bool {
+	return externalVideoExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// isExternalCandidate reports whether a file in a session folder may be an
+// operator-added video, as opposed to a replay named by replays itself.
+func isExternalCandidate(name string) bool {
+	if attemptFilePattern.MatchString(strings.ReplaceAll(name, "Clean_and_Jerk", "CJ")) || isNoteFile(name) || isSlowMotionFile(name) || isInsetFile(name) || IsProxyFile(name) || isMultiAngleFile(name) {
+		return false
+	}
+	return IsVideoFile(name)
+}
+
+// cachedExternalVideo returns the list entry of an operator-added file of a
+// session when it is indexed. A file not indexed yet, or changed since, is
+// probed in the background, and the browsers reload the list once it is
+// indexed, so that listing a session never waits for ffprobe.
+func cachedExternalVideo(session, path string, info os.FileInfo) (externalVideo, bool) {
+	externalIndexMu.Lock()
+	defer externalIndexMu.Unlock()
+	cached, found := externalIndex[path]
+	if found && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached, true
+	}
+	if !externalProbing[path] {
+		externalProbing[path] = true
+		go func() {
+			_, ok := indexExternalVideo(path, info)
+			externalIndexMu.Lock()
+			delete(externalProbing, path)
+			externalIndexMu.Unlock()
+			if ok {
+				broadcastListChanged(session)
+			}
+		}()
+	}
+	return externalVideo{}, false
+}
+
+// indexExternalVideo returns the list entry for an operator-added file,
+// probing it the first time it is seen (and again if it changes).
+func indexExternalVideo(path string, info os.FileInfo) (externalVideo, bool) {
+	externalIndexMu.Lock()
+	cached, found := externalIndex[path]
+	externalIndexMu.Unlock()
+	if found && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached, true
+	}
+
+	if ProbeVideo != nil {
+		if _, err := ProbeVideo(path); err != nil && !errors.Is(err, ErrProbeUnavailable) {
+			logging.WarningLogger.Printf("Not listing %s: not a readable video (%v)", path, err)
+			externalIndexMu.Lock()
+			delete(externalIndex, path)
+			externalIndexMu.Unlock()
+			return externalVideo{}, false
+		}
+	}
+	entry := externalVideo{size: info.Size(), modTime: info.ModTime()}
+	entry.displayName, entry.sortKey = describeExternalVideo(filepath.Base(path), info.ModTime())
+	logging.InfoLogger.Printf("Indexed added video %s as %q", path, entry.displayName)
+
+	externalIndexMu.Lock()
+	externalIndex[path] = entry
+	externalIndexMu.Unlock()
+	return entry, true
+}
+
+// describeExternalVideo builds a display name in the same "date time - name -
+// lift - attempt - camera" shape as replays' own files, using whatever the
+// file name gives and the modification time for the rest. The sort key
+// orders it among replays' files, whose keys start with the date and time in
+// UTC.
+func describeExternalVideo(name string, modTime time.Time) (displayName, sortKey string) {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	rest := base
+
+	when := modTime
+	if m := looseDatePattern.FindStringSubmatchIndex(rest); m != nil {
+		date := rest[m[2]:m[3]] + "-" + rest[m[4]:m[5]] + "-" + rest[m[6]:m[7]]
+		clock := "00:00:00"
+		after := rest[m[1]:]
+		if t := looseTimePattern.FindStringSubmatchIndex(after); t != nil {
+			clock = after[t[2]:t[3]] + ":" + after[t[4]:t[5]] + ":" + after[t[6]:t[7]]
+			rest = rest[:m[0]] + " " + after[:t[0]] + " " + after[t[1]:]
+		} else {
+			rest = rest[:m[0]] + " " + after
+		}
+		if parsed, err := time.ParseInLocation("2006-01-02 15:04:05", date+" "+clock, time.Local); err == nil {
+			when = parsed
+		}
+	}
+
+	var parts []string
+	lift := ""
+	if m := looseLiftPattern.FindString(rest); m != "" {
+		lift = "CJ"
+		if strings.EqualFold(m, "snatch") {
+			lift = "SNATCH"
+		}
+		rest = strings.Replace(rest, m, " ", 1)
+	}
+	attempt := ""
+	if m := looseAttemptPattern.FindStringSubmatch(rest); m != nil {
+		attempt = "attempt " + m[1]
+		rest = strings.Replace(rest, m[0], " ", 1)
+	}
+	camera := ""
+	if m := looseCameraPattern.FindStringSubmatch(rest); m != nil {
+		camera = "Camera " + m[1]
+		rest = strings.Replace(rest, m[0], " ", 1)
+	}
+
+	title := strings.Join(strings.Fields(strings.NewReplacer("_", " ", "-", " ", ".", " ").Replace(rest)), " ")
+	if title == "" {
+		title = base
+	}
+	parts = append(parts, when.Format("2006-01-02 15:04:05"), title)
+	for _, part := range []string{lift, attempt, camera} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " - "), ReplayTimestamp(when) + "_" + name
+}
+
+// WatchVideoDir notices videos dropped into session folders, those of the
+// platform folders included, and tells the browsers to refresh their list once
+// each file has finished copying.
+func WatchVideoDir(videoDir string) error {
+	_, err := watchVideoDir(videoDir, externalSettleDelay)
+	return err
+}
+
+// watchVideoDir watches videoDir, indexing the added files once they have
+// not changed for settle. Closing the watcher stops it.
+func watchVideoDir(videoDir string, settle time.Duration) (*fsnotify.Watcher, error) {
+	videoDir = filepath.Clean(videoDir)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(videoDir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	// Session folders, and platform folders with their session folders.
+	watchFolders(watcher, videoDir, 2)
+
+	go func() {
+		timers := make(map[string]*time.Timer)
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !event.Has(fsnotify.Create | fsnotify.Write | fsnotify.Rename) {
+					continue
+				}
+				rel, err := filepath.Rel(videoDir, event.Name)
+				if err != nil || strings.HasPrefix(filepath.Base(event.Name), ".") {
+					continue
+				}
+				levels := len(strings.Split(filepath.ToSlash(rel), "/"))
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// New session or platform folder, or new session of a
+					// platform.
+					if levels <= 2 {
+						_ = watcher.Add(event.Name)
+						watchFolders(watcher, event.Name, 2-levels)
+					}
+					continue
+				}
+				session, ok := watchedSession(videoDir, event.Name)
+				if !ok || !isExternalCandidate(filepath.Base(event.Name)) {
+					continue
+				}
+				path := event.Name
+				if timer, found := timers[path]; found {
+					timer.Stop()
+				}
+				timers[path] = time.AfterFunc(settle, func() {
+					info, err := os.Stat(path)
+					if err != nil || info.IsDir() {
+						return
+					}
+					if _, ok := indexExternalVideo(path, info); ok {
+						broadcastListChanged(session)
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logging.WarningLogger.Printf("Watching %s: %v", videoDir, err)
+			}
+		}
+	}()
+	return watcher, nil
+}
+
+// watchFolders watches the folders under dir, down to levels deep. Hidden
+// work folders are left out.
+func watchFolders(watcher *fsnotify.Watcher, dir string, levels int) {
+	if levels <= 0 {
+		return
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		folder := filepath.Join(dir, entry.Name())
+		_ = watcher.Add(folder)
+		watchFolders(watcher, folder, levels-1)
+	}
+}
+
+// watchedSession returns the session identifier of a file of the video
+// directory: its folder ("Session1"), or its platform and folder
+// ("A/Session1"). Files at the top level are recordings in progress and
+// belong to no session.
+func watchedSession(videoDir, path string) (string, bool) {
+	rel, err := filepath.Rel(videoDir, filepath.Dir(path))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	session := filepath.ToSlash(rel)
+	if strings.Count(session, "/") > 1 {
+		return "", false
+	}
+	return session, true
+}
+
+// listChangedMessage tells browsers showing a session to reload the list. It
+// is not a status, so it does not replace the status shown on the page.
+type listChangedMessage struct {
+	Event   string `json:"event"`
+	Session string `json:"session"`
+}
+
+func broadcastListChanged(session string) {
+	msg := listChangedMessage{Event: "listChanged", Session: session}
+	mu.Lock()
+	defer mu.Unlock()
+	broadcastToClients(msg)
+	logging.InfoLogger.Printf("Video list of session %s changed", session)
+}
@@ -0,0 +1,70 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// FfmpegLogResponse is returned by the ffmpeg log endpoints. Status is
+// "next", "attempt" or "off".
+type FfmpegLogResponse struct {
+	Status string `json:"status"`
+}
+
+// handleFfmpegLog serves POST /api/ffmpeg-log/{action}, where action is next
+// (detailed ffmpeg logs for the next attempt only) or cancel. Both take the
+// control token.
+func handleFfmpegLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+control.TokenHeader)
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if config.Viewer {
+		http.Error(w, "A viewer does not record attempts", http.StatusServiceUnavailable)
+		return
+	}
+	if !controlAllowed(w, r) {
+		return
+	}
+
+	switch mux.Vars(r)["action"] {
+	case "next":
+		config.LogNextAttempt(true)
+		logging.InfoLogger.Printf("Detailed ffmpeg logs for the next attempt asked from %s", r.RemoteAddr)
+	case "cancel":
+		config.LogNextAttempt(false)
+		logging.InfoLogger.Printf("Detailed ffmpeg logs for the next attempt cancelled from %s", r.RemoteAddr)
+	default:
+		http.Error(w, "Unknown action, expected next or cancel", http.StatusNotFound)
+		return
+	}
+
+	response := FfmpegLogResponse{Status: ffmpegLogText()}
+	if response.Status == config.FfmpegLogOff {
+		response.Status = "off"
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.ErrorLogger.Printf("Failed to encode ffmpeg log response: %v", err)
+	}
+}
+
+func ffmpegLogText() string {
+	if config.LogFfmpeg {
+		// Every attempt is logged anyway.
+		return config.FfmpegLogOff
+	}
+	return config.FfmpegLogState()
+}
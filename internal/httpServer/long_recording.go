@@ -0,0 +1,81 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// StartLongRecording and StopLongRecording are set by the application; the
+// recorder depends on this package and cannot be called from it directly.
+var (
+	StartLongRecording func(label string) error
+	StopLongRecording  func() ([]string, error)
+)
+
+// LongRecordingResponse is returned by the long recording endpoints.
+type LongRecordingResponse struct {
+	Status string   `json:"status"`
+	Files  []string `json:"files,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// handleLongRecording serves POST /api/long-recording/{action}, where action
+// is start (with an optional label query parameter) or stop. Both take the
+// control token.
+func handleLongRecording(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+control.TokenHeader)
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !controlAllowed(w, r) {
+		return
+	}
+	if StartLongRecording == nil || StopLongRecording == nil {
+		http.Error(w, "Long recordings are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var response LongRecordingResponse
+	status := http.StatusOK
+	switch mux.Vars(r)["action"] {
+	case "start":
+		if err := StartLongRecording(r.URL.Query().Get("label")); err != nil {
+			status = http.StatusConflict
+			response = LongRecordingResponse{Status: "error", Error: err.Error()}
+		} else {
+			response = LongRecordingResponse{Status: "recording"}
+		}
+	case "stop":
+		files, err := StopLongRecording()
+		for i, file := range files {
+			files[i] = filepath.Base(file)
+		}
+		if err != nil {
+			status = http.StatusConflict
+			response = LongRecordingResponse{Status: "error", Files: files, Error: err.Error()}
+		} else {
+			response = LongRecordingResponse{Status: "saved", Files: files}
+		}
+	default:
+		http.Error(w, "Unknown action, expected start or stop", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.ErrorLogger.Printf("Failed to encode long recording response: %v", err)
+	}
+}
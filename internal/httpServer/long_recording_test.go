@@ -0,0 +1,38 @@
+package httpServer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/control"
+)
+
+func TestLongRecordingTakesTheControlToken(t *testing.T) {
+	started := false
+	StartLongRecording = func(string) error { started = true; return nil }
+	StopLongRecording = func() ([]string, error) { return nil, nil }
+	control.SetToken("secret")
+	t.Cleanup(func() {
+		StartLongRecording, StopLongRecording = nil, nil
+		control.SetToken("")
+	})
+
+	send := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/long-recording/start", nil)
+		req = mux.SetURLVars(req, map[string]string{"action": "start"})
+		if token != "" {
+			req.Header.Set(control.TokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		handleLongRecording(rec, req)
+		return rec.Code
+	}
+	if code := send("wrong"); code != http.StatusUnauthorized || started {
+		t.Fatalf("long recording started with a wrong token: %d", code)
+	}
+	if code := send("secret"); code != http.StatusOK || !started {
+		t.Fatalf("long recording not started with the token: %d", code)
+	}
+}
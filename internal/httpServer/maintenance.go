@@ -0,0 +1,78 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/state"
+)
+
+// MaintenanceResponse is returned by the maintenance endpoints.
+type MaintenanceResponse struct {
+	Status string `json:"status"`
+	Until  string `json:"until,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleMaintenance serves POST /api/maintenance/{action}, where action is
+// start (with an optional minutes query parameter) or stop. Both take the
+// control token.
+func handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+control.TokenHeader)
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if config.Viewer {
+		http.Error(w, "A viewer does not record attempts", http.StatusServiceUnavailable)
+		return
+	}
+	if !controlAllowed(w, r) {
+		return
+	}
+
+	var response MaintenanceResponse
+	status := http.StatusOK
+	switch mux.Vars(r)["action"] {
+	case "start":
+		duration := state.DefaultMaintenanceDuration
+		if value := r.URL.Query().Get("minutes"); value != "" {
+			minutes, err := strconv.Atoi(value)
+			if err != nil || minutes <= 0 {
+				status = http.StatusBadRequest
+				response = MaintenanceResponse{Status: "error", Error: "minutes must be a positive number"}
+				break
+			}
+			duration = time.Duration(minutes) * time.Minute
+		}
+		until := state.StartMaintenance(duration)
+		logging.InfoLogger.Printf("Maintenance mode started from %s until %s", r.RemoteAddr, until.Format("15:04:05"))
+		response = MaintenanceResponse{Status: "maintenance", Until: until.Format(time.RFC3339)}
+	case "stop":
+		if state.StopMaintenance() {
+			logging.InfoLogger.Printf("Maintenance mode ended from %s", r.RemoteAddr)
+		}
+		response = MaintenanceResponse{Status: "recording"}
+	default:
+		http.Error(w, "Unknown action, expected start or stop", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.ErrorLogger.Printf("Failed to encode maintenance response: %v", err)
+	}
+}
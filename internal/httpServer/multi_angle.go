@@ -0,0 +1,49 @@
+package httpServer
+
+import (
+	"os"
+	"strings"
+)
+
+// The cameras of an attempt combined into one video are kept in the session
+// folder next to the replays, as <attempt>_cameras.mp4 where <attempt> is the
+// replay file name without its camera part.
+const multiAngleSuffix = "_cameras.mp4"
+
+// MultiAngleName returns the name of the combined video of the attempt of a
+// replay, "" if the file is not a replay.
+func MultiAngleName(replay string) string {
+	key := attemptKey(replay)
+	if key == "" {
+		return ""
+	}
+	return key + multiAngleSuffix
+}
+
+// multiAngleKey returns the attempt of a combined video, "" for other files.
+func multiAngleKey(name string) string {
+	if !strings.HasSuffix(name, multiAngleSuffix) {
+		return ""
+	}
+	key := strings.TrimSuffix(name, multiAngleSuffix)
+	if !attemptKeyPattern.MatchString(key) {
+		return ""
+	}
+	return key
+}
+
+func isMultiAngleFile(name string) bool {
+	return multiAngleKey(name) != ""
+}
+
+// sessionMultiAngle returns the combined videos of a session folder by
+// attempt key.
+func sessionMultiAngle(entries []os.DirEntry) map[string]string {
+	videos := make(map[string]string)
+	for _, entry := range entries {
+		if key := multiAngleKey(entry.Name()); key != "" && !entry.IsDir() {
+			videos[key] = entry.Name()
+		}
+	}
+	return videos
+}
@@ -0,0 +1,165 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// A voice note records the reasoning of the jury for an attempt. It is kept in
+// the session folder next to the replays, as <attempt>_note.<ext> where
+// <attempt> is the replay file name without its camera part.
+const (
+	noteSuffix   = "_note"
+	maxNoteBytes = 10 << 20
+)
+
+// noteExtensions maps the types produced by browser MediaRecorders to the
+// extension of the stored note.
+var noteExtensions = map[string]string{
+	"audio/webm": ".webm",
+	"audio/ogg":  ".ogg",
+	"audio/mp4":  ".m4a",
+}
+
+var attemptKeyPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}_\d{2}h\d{2}m\d{2}sZ?_.+_(CLEANJERK|SNATCH)_attempt\d+$`)
+
+// NoteResponse is the JSON reply to a voice note upload.
+type NoteResponse struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	Note    string `json:"note,omitempty"`
+}
+
+// attemptKey returns the part of a replay file name shared by all the cameras
+// of the attempt, or "" if the file is not a replay.
+func attemptKey(filename string) string {
+	i := strings.LastIndex(filename, "_Camera")
+	if i < 0 || !attemptKeyPattern.MatchString(filename[:i]) {
+		return ""
+	}
+	return filename[:i]
+}
+
+// isNoteFile reports whether a file of a session folder is a voice note.
+func isNoteFile(name string) bool {
+	ext := filepath.Ext(name)
+	for _, noteExt := range noteExtensions {
+		if ext == noteExt {
+			base := strings.TrimSuffix(name, ext)
+			return strings.HasSuffix(base, noteSuffix) && attemptKeyPattern.MatchString(strings.TrimSuffix(base, noteSuffix))
+		}
+	}
+	return false
+}
+
+// sessionNotes returns the voice notes of a session folder by attempt key.
+func sessionNotes(entries []os.DirEntry) map[string]string {
+	notes := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isNoteFile(name) {
+			continue
+		}
+		key := strings.TrimSuffix(strings.TrimSuffix(name, filepath.Ext(name)), noteSuffix)
+		notes[key] = name
+	}
+	return notes
+}
+
+// saveNote stores the note of an attempt, replacing a previous one.
+func saveNote(session, key, contentType string, body io.Reader) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("missing audio type")
+	}
+	ext, ok := noteExtensions[mediaType]
+	if !ok {
+		return "", fmt.Errorf("unsupported audio type %s", mediaType)
+	}
+	store := storage.Current()
+	if _, err := store.Stat(session); err != nil {
+		return "", err
+	}
+
+	name := key + noteSuffix + ext
+	audio, err := io.ReadAll(io.LimitReader(body, maxNoteBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(audio) == 0 {
+		return "", fmt.Errorf("the note is empty")
+	}
+	if len(audio) > maxNoteBytes {
+		return "", fmt.Errorf("the note is longer than %d MB", maxNoteBytes>>20)
+	}
+	for _, other := range noteExtensions {
+		if other != ext {
+			store.Remove(storage.Join(session, key+noteSuffix+other))
+		}
+	}
+	if err := store.WriteFile(storage.Join(session, name), audio); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// handleReplayNote stores a voice note recorded in the browser for an
+// attempt: POST /api/sessions/{session}/notes/{attempt} with the audio as body.
+func handleReplayNote(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if config.Viewer {
+		http.Error(w, "A viewer does not record voice notes", http.StatusServiceUnavailable)
+		return
+	}
+
+	response := NoteResponse{}
+	status := http.StatusOK
+	session, err := sanitizeReplaySessionID(mux.Vars(r)["session"])
+	key := mux.Vars(r)["attempt"]
+	switch {
+	case err != nil:
+		status, response.Message = http.StatusBadRequest, "Invalid session"
+	case !attemptKeyPattern.MatchString(key) || strings.ContainsAny(key, `/\`):
+		status, response.Message = http.StatusBadRequest, "Invalid attempt"
+	default:
+		name, err := saveNote(session, key, r.Header.Get("Content-Type"), r.Body)
+		if err != nil {
+			logging.ErrorLogger.Printf("Failed to save the voice note of %s: %v", key, err)
+			status, response.Message = http.StatusBadRequest, err.Error()
+			if os.IsNotExist(err) {
+				status, response.Message = http.StatusNotFound, "Replay session not found"
+			}
+			break
+		}
+		logging.InfoLogger.Printf("Saved voice note %s in session %s", name, session)
+		response.OK = true
+		response.Message = "Voice note saved"
+		response.Note = "/videos/" + session + "/" + name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,175 @@
+package httpServer
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/state"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// With platformFolders, the sessions are stored under a folder per platform
+// and their identifier is "platform/session"; sessions stored before keep a
+// plain name.
+
+// sessionRoute matches a session identifier in the routes.
+const sessionRoute = "{session:[^/]+(?:/[^/]+)?}"
+
+// folderName turns an owlcms session or platform name into a folder name.
+func folderName(name string) string {
+	return strings.NewReplacer(" ", "_", "/", "_", `\`, "_").Replace(strings.TrimSpace(name))
+}
+
+// platformSession puts a session under the folder of the current platform
+// when sessions are stored by platform.
+func platformSession(session string) string {
+	if !config.PlatformDirs || session == "" {
+		return session
+	}
+	platform := folderName(state.CurrentPlatform)
+	if platform == "" {
+		return session
+	}
+	return platform + "/" + session
+}
+
+// SessionFolder returns the folder where the attempts of an owlcms session
+// are stored: "unsorted" when there is no session, under the folder of the
+// current platform with platformFolders.
+func SessionFolder(session string) string {
+	folder := folderName(session)
+	if folder == "" {
+		folder = "unsorted"
+	}
+	return platformSession(folder)
+}
+
+// sessionPlatform returns the platform of a session identifier, "" for a
+// session stored without one.
+func sessionPlatform(session string) string {
+	if i := strings.Index(session, "/"); i >= 0 {
+		return session[:i]
+	}
+	return ""
+}
+
+// sessionName returns the session of an identifier without its platform.
+func sessionName(session string) string {
+	return path.Base(session)
+}
+
+// isUnsorted tells if a session identifier is where attempts without a
+// session go.
+func isUnsorted(session string) bool {
+	return sessionName(session) == "unsorted"
+}
+
+// isPlatformFolder tells if a top-level folder holds the sessions of a
+// platform rather than videos: it has session folders and no files. Hidden
+// work folders do not count.
+func isPlatformFolder(store storage.Backend, name string) bool {
+	entries, err := store.ReadDir(name)
+	if err != nil {
+		return false
+	}
+	folders := 0
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !entry.IsDir() {
+			return false
+		}
+		folders++
+	}
+	return folders > 0
+}
+
+// sessionFolders returns the identifiers of every session folder, those of
+// the platform folders included.
+func sessionFolders(store storage.Backend) ([]string, error) {
+	entries, err := store.ReadDir("")
+	if err != nil {
+		return nil, err
+	}
+	var sessions []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !isPlatformFolder(store, entry.Name()) {
+			sessions = append(sessions, entry.Name())
+			continue
+		}
+		children, err := store.ReadDir(entry.Name())
+		if err != nil {
+			continue
+		}
+		for _, child := range children {
+			if child.IsDir() && !strings.HasPrefix(child.Name(), ".") {
+				sessions = append(sessions, entry.Name()+"/"+child.Name())
+			}
+		}
+	}
+	return sessions, nil
+}
+
+// sessionPlatforms returns the platforms that have sessions, sorted.
+func sessionPlatforms(sessions []string) []string {
+	seen := make(map[string]bool)
+	var platforms []string
+	for _, session := range sessions {
+		if platform := sessionPlatform(session); platform != "" && !seen[platform] {
+			seen[platform] = true
+			platforms = append(platforms, platform)
+		}
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// filterPlatform keeps the sessions of a platform; "" keeps them all.
+func filterPlatform(sessions []string, platform string) []string {
+	if platform == "" {
+		return sessions
+	}
+	var kept []string
+	for _, session := range sessions {
+		if sessionPlatform(session) == platform {
+			kept = append(kept, session)
+		}
+	}
+	return kept
+}
+
+// findSession returns the identifier of sessions named session: itself, or
+// the session of that name on platform, or on any platform when there is a
+// single one. owlcms announces sessions without their platform.
+func findSession(sessions []string, session, platform string) string {
+	candidates := []string{session}
+	if platform != "" {
+		candidates = append(candidates, platform+"/"+session)
+	}
+	for _, candidate := range candidates {
+		for _, s := range sessions {
+			if s == candidate {
+				return s
+			}
+		}
+	}
+	found := ""
+	for _, s := range sessions {
+		if sessionPlatform(s) != "" && sessionName(s) == session {
+			if found != "" {
+				return session
+			}
+			found = s
+		}
+	}
+	if found == "" {
+		return session
+	}
+	return found
+}
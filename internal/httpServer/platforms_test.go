@@ -0,0 +1,84 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/state"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+func TestSessionFolderIsUnderThePlatformFolder(t *testing.T) {
+	oldDirs, oldPlatform := config.PlatformDirs, state.CurrentPlatform
+	t.Cleanup(func() { config.PlatformDirs, state.CurrentPlatform = oldDirs, oldPlatform })
+
+	state.CurrentPlatform = "Platform A"
+	config.PlatformDirs = false
+	if got := SessionFolder("M 1"); got != "M_1" {
+		t.Fatalf("SessionFolder() = %q without platform folders", got)
+	}
+	config.PlatformDirs = true
+	for session, want := range map[string]string{"M 1": "Platform_A/M_1", "": "Platform_A/unsorted"} {
+		if got := SessionFolder(session); got != want {
+			t.Fatalf("SessionFolder(%q) = %q, want %q", session, got, want)
+		}
+	}
+}
+
+func TestSessionsAreListedByPlatform(t *testing.T) {
+	videoDir := t.TempDir()
+	for _, dir := range []string{"M0", "A/M1", "A/unsorted", "A/.recode", "B/M1", "Empty"} {
+		if err := os.MkdirAll(filepath.Join(videoDir, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(videoDir, "M0", "video.mp4"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	folders, err := sessionFolders(storage.Local{Root: videoDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"A/M1", "A/unsorted", "B/M1", "Empty", "M0"}; !reflect.DeepEqual(folders, want) {
+		t.Fatalf("sessionFolders() = %v, want %v", folders, want)
+	}
+	if got := sessionPlatforms(folders); !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Fatalf("sessionPlatforms() = %v", got)
+	}
+	if got := findSession(folders, "M1", "B"); got != "B/M1" {
+		t.Fatalf("M1 on platform B found as %q", got)
+	}
+	if got := findSession(folders, "M1", ""); got != "M1" {
+		t.Fatalf("M1 of two platforms found as %q", got)
+	}
+
+	rec := httptest.NewRecorder()
+	handleReplaySessions(rec, httptest.NewRequest("GET", "/api/sessions?platform=A", nil))
+	var response ReplaySessionsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Sessions) != 1 || response.Sessions[0].ID != "A/M1" || response.Sessions[0].Name != "M1" || response.Sessions[0].Platform != "A" {
+		t.Fatalf("sessions of platform A = %+v", response.Sessions)
+	}
+	if !reflect.DeepEqual(response.Platforms, []string{"A", "B"}) {
+		t.Fatalf("platforms = %v", response.Platforms)
+	}
+}
+
+func TestSessionIDMayHaveAPlatform(t *testing.T) {
+	for id, valid := range map[string]bool{"M1": true, "A/M1": true, "A/M1/x": false, "../M1": false, "A/": false, `A\M1`: false} {
+		if _, err := sanitizeReplaySessionID(id); (err == nil) != valid {
+			t.Fatalf("sanitizeReplaySessionID(%q) = %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// SimulateDecision is set by the application (see monitor.SimulateDecision).
+var SimulateDecision func() error
+
+// SimulateDecisionResponse is returned by the simulate-decision endpoint.
+type SimulateDecisionResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleSimulateDecision serves POST /api/simulate-decision, which ends the
+// attempt being recorded as if the referees had given a decision. It takes
+// the control token.
+func handleSimulateDecision(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+control.TokenHeader)
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !controlAllowed(w, r) {
+		return
+	}
+	if SimulateDecision == nil {
+		http.Error(w, "Simulated decisions are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	response := SimulateDecisionResponse{Status: "trimming"}
+	status := http.StatusOK
+	if err := SimulateDecision(); err != nil {
+		status = http.StatusConflict
+		response = SimulateDecisionResponse{Status: "error", Error: err.Error()}
+	}
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.ErrorLogger.Printf("Failed to encode simulate decision response: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// processStart is when replays started, for the uptime of /debug/metrics.
+var processStart = time.Now()
+
+// RuntimeMetrics are the Go runtime figures returned by /debug/metrics, to
+// follow goroutine leaks and memory growth during a competition.
+type RuntimeMetrics struct {
+	Time             string  `json:"time"`
+	UptimeSeconds    int64   `json:"uptimeSeconds"`
+	GoVersion        string  `json:"goVersion"`
+	CPUs             int     `json:"cpus"`
+	Goroutines       int     `json:"goroutines"`
+	WebSocketClients int     `json:"webSocketClients"`
+	HeapAllocBytes   uint64  `json:"heapAllocBytes"`
+	HeapObjects      uint64  `json:"heapObjects"`
+	SysBytes         uint64  `json:"sysBytes"`
+	NumGC            uint32  `json:"numGC"`
+	GCPauseTotalMs   float64 `json:"gcPauseTotalMs"`
+	LastGC           string  `json:"lastGC,omitempty"`
+}
+
+// StartProfiling serves net/http/pprof under /debug/pprof/ and the runtime
+// metrics under /debug/metrics on port, for --pprof. It listens on the
+// loopback interface only: profiles are read on the replays machine, or
+// through an SSH tunnel. It returns the listener, whose Addr is the address
+// listened on; closing it stops the profiling server.
+func StartProfiling(port int) (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("cannot serve profiles on port %d: %w", port, err)
+	}
+	addr := listener.Addr().String()
+	go func() {
+		if err := http.Serve(listener, profilingHandler()); err != nil && !errors.Is(err, net.ErrClosed) {
+			logging.ErrorLogger.Printf("Profiling server on %s stopped: %v", addr, err)
+		}
+	}()
+	logging.InfoLogger.Printf("Serving pprof profiles on http://%s/debug/pprof/ and runtime metrics on http://%s/debug/metrics", addr, addr)
+	return listener, nil
+}
+
+// profilingHandler routes the profiling server. It is separate from the main
+// router so that the profiles are never reachable from the network.
+func profilingHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/metrics", handleRuntimeMetrics)
+	return mux
+}
+
+// handleRuntimeMetrics serves GET /debug/metrics.
+func handleRuntimeMetrics(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	mu.Lock()
+	webSocketClients := len(clients)
+	mu.Unlock()
+
+	metrics := RuntimeMetrics{
+		Time:             time.Now().Format(time.RFC3339Nano),
+		UptimeSeconds:    int64(time.Since(processStart).Seconds()),
+		GoVersion:        runtime.Version(),
+		CPUs:             runtime.NumCPU(),
+		Goroutines:       runtime.NumGoroutine(),
+		WebSocketClients: webSocketClients,
+		HeapAllocBytes:   mem.HeapAlloc,
+		HeapObjects:      mem.HeapObjects,
+		SysBytes:         mem.Sys,
+		NumGC:            mem.NumGC,
+		GCPauseTotalMs:   float64(mem.PauseTotalNs) / float64(time.Millisecond),
+	}
+	if mem.LastGC > 0 {
+		metrics.LastGC = time.Unix(0, int64(mem.LastGC)).Format(time.RFC3339Nano)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(metrics); err != nil {
+		logging.ErrorLogger.Printf("Failed to encode the runtime metrics: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProfilingIsServedOnLoopbackOnly(t *testing.T) {
+	listener, err := StartProfiling(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	addr := listener.Addr().String()
+	if !strings.HasPrefix(addr, "127.0.0.1:") {
+		t.Fatalf("profiles served on %s", addr)
+	}
+
+	resp, err := http.Get("http://" + addr + "/debug/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var metrics RuntimeMetrics
+	if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Goroutines == 0 || metrics.HeapAllocBytes == 0 || metrics.GoVersion == "" {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+
+	index, err := http.Get("http://" + addr + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Body.Close()
+	if index.StatusCode != http.StatusOK {
+		t.Fatalf("pprof index: %d", index.StatusCode)
+	}
+
+	listener.Close()
+	client := http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	if resp, err := client.Get("http://" + addr + "/debug/metrics"); err == nil {
+		resp.Body.Close()
+		t.Fatal("the profiling server still answers once its listener is closed")
+	}
+}
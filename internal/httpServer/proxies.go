@@ -0,0 +1,86 @@
+package httpServer
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// proxyPattern matches the lower-bitrate copies of a replay, named after it
+// with their quality: <replay>_720p.mp4.
+var proxyPattern = regexp.MustCompile(`^(.+)_(\d+p)\.mp4$`)
+
+// qualityPattern matches the quality parameter of the video URLs.
+var qualityPattern = regexp.MustCompile(`^\d+p$`)
+
+// ProxyLink is a lower-bitrate copy of a replay. URL is relative to the
+// session folder in the video list, absolute in the API.
+type ProxyLink struct {
+	Quality string `json:"quality"`
+	URL     string `json:"url"`
+}
+
+// IsProxyFile reports whether a file of a session folder is the lower-bitrate
+// copy of a replay.
+func IsProxyFile(name string) bool {
+	return proxyPattern.MatchString(name)
+}
+
+// sessionProxies returns the lower-bitrate copies of a session folder by
+// replay name without its extension, best first, with urlPrefix before their
+// names.
+func sessionProxies(entries []os.DirEntry, urlPrefix string) map[string][]ProxyLink {
+	copies := make(map[string][]ProxyLink)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := proxyPattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		replay := matches[1]
+		copies[replay] = append(copies[replay], ProxyLink{Quality: matches[2], URL: urlPrefix + entry.Name()})
+	}
+	lines := func(quality string) int {
+		height, _ := strconv.Atoi(strings.TrimSuffix(quality, "p"))
+		return height
+	}
+	for _, links := range copies {
+		sort.Slice(links, func(i, j int) bool { return lines(links[i].Quality) > lines(links[j].Quality) })
+	}
+	return copies
+}
+
+// proxyOf returns the stored name of the copy of a stored video in quality,
+// or "" when there is none (yet) and the video itself is to be served.
+func proxyOf(video, quality string) string {
+	if !qualityPattern.MatchString(quality) {
+		return ""
+	}
+	name := strings.TrimSuffix(video, path.Ext(video)) + "_" + quality + ".mp4"
+	if info, err := storage.Current().Stat(name); err != nil || info.IsDir() {
+		return ""
+	}
+	return name
+}
+
+// withQuality serves the copy of a video in the quality asked for by
+// ?quality=720p, when there is one, instead of the video. next serves the
+// stored files, with the /videos/ prefix stripped.
+func withQuality(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if quality := r.URL.Query().Get("quality"); quality != "" {
+			if proxy := proxyOf(strings.TrimPrefix(r.URL.Path, "/"), quality); proxy != "" {
+				r.URL.Path, r.URL.RawPath = proxy, ""
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
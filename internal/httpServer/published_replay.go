@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -12,6 +11,7 @@ import (
 
 	"github.com/owlcms/replays/internal/config"
 	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
 )
 
 var (
@@ -51,7 +51,7 @@ func sanitizeReplayFilename(filename string) (string, error) {
 
 // ClearPublishedReplayState removes the per-camera replay pointer before a new recording/trim starts.
 func ClearPublishedReplayState(camera int) error {
-	if camera < 1 {
+	if camera < config.ScoreboardCameraNumber {
 		return fmt.Errorf("invalid camera number %d", camera)
 	}
 
@@ -71,7 +71,7 @@ func ClearPublishedReplayState(camera int) error {
 
 // PublishReplayState atomically points /replay/{camera} and /api/replay-state at a completed replay file.
 func PublishReplayState(camera int, session string, filename string, durationMs int64) error {
-	if camera < 1 {
+	if camera < config.ScoreboardCameraNumber {
 		return fmt.Errorf("invalid camera number %d", camera)
 	}
 
@@ -93,8 +93,8 @@ func PublishReplayState(camera int, session string, filename string, durationMs
 		return fmt.Errorf("replay filename camera %d does not match state camera %d", parsedReplay.Camera, camera)
 	}
 
-	videoPath := filepath.Join(config.GetVideoDir(), parsedReplay.Session, parsedReplay.Filename)
-	info, err := os.Stat(videoPath)
+	videoPath := storage.Join(parsedReplay.Session, parsedReplay.Filename)
+	info, err := storage.Current().Stat(videoPath)
 	if err != nil {
 		return fmt.Errorf("completed replay file is not available: %w", err)
 	}
@@ -153,7 +153,7 @@ func SnapshotPublishedReplays() []ReplayCameraState {
 }
 
 func findPublishedReplayForCamera(camera int) (*ReplayCameraState, error) {
-	if camera < 1 {
+	if camera < config.ScoreboardCameraNumber {
 		return nil, fmt.Errorf("invalid camera number %d", camera)
 	}
 
@@ -164,8 +164,8 @@ func findPublishedReplayForCamera(camera int) (*ReplayCameraState, error) {
 		return nil, os.ErrNotExist
 	}
 
-	videoPath := filepath.Join(config.GetVideoDir(), replayState.Session, replayState.Filename)
-	info, err := os.Stat(videoPath)
+	videoPath := storage.Join(replayState.Session, replayState.Filename)
+	info, err := storage.Current().Stat(videoPath)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +103,23 @@ func TestPublishedReplayStatePublishesAndClears(t *testing.T) {
 	}
 }
 
+func TestPublishedReplayStateAcceptsAttemptBoard(t *testing.T) {
+	videoDir := withReplayTestVideoDir(t)
+	filename := "2026-05-08_11h09m59s_LARRIVEE_Mariane_CLEANJERK_attempt1_Camera0.mp4"
+	writeReplayTestFile(t, videoDir, "3", filename)
+
+	if err := PublishReplayState(0, "3", filename, 12345); err != nil {
+		t.Fatalf("failed to publish the attempt board replay: %v", err)
+	}
+	t.Cleanup(func() { ClearPublishedReplayState(0) })
+	if _, err := findPublishedReplayForCamera(0); err != nil {
+		t.Fatalf("failed to read the attempt board replay: %v", err)
+	}
+	if err := PublishReplayState(-1, "3", filename, 12345); err == nil {
+		t.Fatal("expected an error for a negative camera number")
+	}
+}
+
 func TestHandleReplayRequiresPublishedState(t *testing.T) {
 	videoDir := withReplayTestVideoDir(t)
 	filename := "2026-05-08_11h09m59s_LARRIVEE_Mariane_CLEANJERK_attempt1_Camera1.mp4"
@@ -0,0 +1,57 @@
+package httpServer
+
+import (
+	"strings"
+	"time"
+)
+
+// replayTimeLayout is the date and time at the start of the replay file
+// names. Replays name their files in UTC, marked by a Z after the seconds, so
+// that their order does not depend on the time zone of the laptop; names
+// without the Z are from older versions, in the local time of the machine.
+const replayTimeLayout = "2006-01-02_15h04m05s"
+
+// ReplayTimestamp returns the date and time at the start of the names of the
+// replays of an attempt saved at t.
+func ReplayTimestamp(t time.Time) string {
+	return t.UTC().Format(replayTimeLayout) + "Z"
+}
+
+// parseReplayTime parses the date and time of a replay file name, the older
+// names being in loc.
+func parseReplayTime(timestamp string, loc *time.Location) (time.Time, bool) {
+	if utc := strings.TrimSuffix(timestamp, "Z"); utc != timestamp {
+		t, err := time.Parse(replayTimeLayout, utc)
+		return t, err == nil
+	}
+	t, err := time.ParseInLocation(replayTimeLayout, timestamp, loc)
+	return t, err == nil
+}
+
+// replaySortKey returns the date and time of a replay file name in UTC, to
+// order the replays named in UTC with those named in the local time loc.
+func replaySortKey(timestamp string, loc *time.Location) string {
+	if t, ok := parseReplayTime(timestamp, loc); ok {
+		return ReplayTimestamp(t)
+	}
+	return timestamp
+}
+
+// localReplayTime returns the date and time of a replay file name in loc,
+// the local time of the machine, as shown on the pages.
+func localReplayTime(timestamp string, loc *time.Location) string {
+	if t, ok := parseReplayTime(timestamp, loc); ok {
+		return t.In(loc).Format("2006-01-02 15:04:05")
+	}
+	return timestamp
+}
+
+// replayTimeWithOffset returns the date and time of a replay file name as
+// RFC 3339 time in loc with its offset from UTC, for the metadata written
+// next to the replays.
+func replayTimeWithOffset(timestamp string, loc *time.Location) string {
+	if t, ok := parseReplayTime(timestamp, loc); ok {
+		return t.In(loc).Format(time.RFC3339)
+	}
+	return timestamp
+}
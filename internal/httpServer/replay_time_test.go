@@ -0,0 +1,38 @@
+package httpServer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplaysNamedInUTCAreOrderedWithOlderLocalNames(t *testing.T) {
+	local := time.FixedZone("UTC-5", -5*3600)
+
+	if got := ReplayTimestamp(time.Date(2025, 3, 1, 13, 30, 0, 0, local)); got != "2025-03-01_18h30m00sZ" {
+		t.Fatalf("ReplayTimestamp() = %s, want the UTC time marked with Z", got)
+	}
+
+	// The older name is in local time: 14:00 is 19:00 UTC, after 18:30 UTC.
+	older := "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt1_Camera1.mp4"
+	newer := "2025-03-01_18h30m00sZ_Jane_Doe_SNATCH_attempt1_Camera1.mp4"
+	var timestamps []string
+	for _, name := range []string{newer, older} {
+		parsed, ok := parseReplayFilename("A", name)
+		if !ok {
+			t.Fatalf("%s is not a replay", name)
+		}
+		if attemptKey(name) == "" {
+			t.Fatalf("%s has no attempt key", name)
+		}
+		timestamps = append(timestamps, parsed.Timestamp)
+	}
+	if replaySortKey(timestamps[1], local) <= replaySortKey(timestamps[0], local) {
+		t.Fatalf("the lift of 19:00 UTC is sorted before the lift of 18:30 UTC")
+	}
+	if offset := replayTimeWithOffset(timestamps[0], local); offset != "2025-03-01T13:30:00-05:00" {
+		t.Fatalf("time = %s, want the local time with its offset", offset)
+	}
+	if shown := localReplayTime("2025-03-01_18h30m00sZ", local); shown != "2025-03-01 13:30:00" {
+		t.Fatalf("shown as %s, want the local time", shown)
+	}
+}
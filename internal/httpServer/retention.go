@@ -0,0 +1,217 @@
+package httpServer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/state"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// The video cleanup keeps the video directory within videoRetentionDays and
+// maxVideoGB by deleting whole sessions, oldest first. The current session
+// and the sessions marked to be kept in their manifest are never deleted.
+
+// CleanupReport tells what a video cleanup deleted.
+type CleanupReport struct {
+	Time      time.Time
+	Deleted   []string // sessions deleted, oldest first
+	Reclaimed int64    // bytes freed
+	Used      int64    // bytes used by the sessions left
+}
+
+// String summarizes the report for the log and the video list.
+func (r CleanupReport) String() string {
+	if len(r.Deleted) == 0 {
+		return fmt.Sprintf("no session deleted, the videos use %s", formatGB(r.Used))
+	}
+	return fmt.Sprintf("%d sessions deleted, %s reclaimed, the videos use %s", len(r.Deleted), formatGB(r.Reclaimed), formatGB(r.Used))
+}
+
+// sessionUsage is the space taken by a session folder.
+type sessionUsage struct {
+	session  string
+	modified time.Time // last change of a file of the session
+	bytes    int64
+	keep     bool
+}
+
+var (
+	cleanupMu   sync.Mutex
+	lastCleanup *CleanupReport
+)
+
+// CleanupVideos deletes the sessions last changed more than
+// config.VideoMaxAge before now, then the oldest ones until the sessions use
+// at most config.MaxVideoBytes.
+func CleanupVideos(now time.Time) (CleanupReport, error) {
+	report := CleanupReport{Time: now}
+	if config.VideoMaxAge <= 0 && config.MaxVideoBytes <= 0 {
+		return report, nil
+	}
+	store := storage.Current()
+	usages, err := sessionUsages(store)
+	if err != nil {
+		return report, err
+	}
+	current := ""
+	if state.CurrentSession != "" {
+		current = SessionFolder(state.CurrentSession)
+	}
+	for i := range usages {
+		usages[i].keep = usages[i].keep || usages[i].session == current
+		report.Used += usages[i].bytes
+	}
+
+	for _, usage := range expiredSessions(usages, now, config.VideoMaxAge, config.MaxVideoBytes) {
+		if err := removeTree(store, usage.session); err != nil {
+			logging.WarningLogger.Printf("Failed to delete session %s: %v", usage.session, err)
+			continue
+		}
+		removeEmptyPlatform(store, usage.session)
+		logging.InfoLogger.Printf("Deleted session %s (%s, last changed %s)", usage.session, formatGB(usage.bytes), usage.modified.Format("2006-01-02 15:04"))
+		report.Deleted = append(report.Deleted, usage.session)
+		report.Reclaimed += usage.bytes
+		report.Used -= usage.bytes
+		broadcastListChanged(usage.session)
+	}
+	if config.MaxVideoBytes > 0 && report.Used > config.MaxVideoBytes {
+		logging.WarningLogger.Printf("The videos use %s, more than maxVideoGB (%s): what is left is kept or current", formatGB(report.Used), formatGB(config.MaxVideoBytes))
+	}
+
+	cleanupMu.Lock()
+	lastCleanup = &report
+	cleanupMu.Unlock()
+	return report, nil
+}
+
+// expiredSessions returns the sessions to delete, oldest first: those last
+// changed before now-maxAge, then more until the total is at most maxBytes.
+// Kept sessions count in the total but are never returned. 0 disables a limit.
+func expiredSessions(usages []sessionUsage, now time.Time, maxAge time.Duration, maxBytes int64) []sessionUsage {
+	sorted := append([]sessionUsage(nil), usages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].modified.Before(sorted[j].modified)
+	})
+	var total int64
+	for _, usage := range sorted {
+		total += usage.bytes
+	}
+
+	var expired []sessionUsage
+	for _, usage := range sorted {
+		if usage.keep {
+			continue
+		}
+		tooOld := maxAge > 0 && usage.modified.Before(now.Add(-maxAge))
+		tooBig := maxBytes > 0 && total > maxBytes
+		if !tooOld && !tooBig {
+			continue
+		}
+		expired = append(expired, usage)
+		total -= usage.bytes
+	}
+	return expired
+}
+
+// sessionUsages measures every session folder.
+func sessionUsages(store storage.Backend) ([]sessionUsage, error) {
+	sessions, err := sessionFolders(store)
+	if err != nil {
+		return nil, err
+	}
+	usages := make([]sessionUsage, 0, len(sessions))
+	for _, session := range sessions {
+		usage := sessionUsage{session: session}
+		measureTree(store, session, &usage)
+		if info, err := store.Stat(session); err == nil && usage.modified.IsZero() {
+			usage.modified = info.ModTime()
+		}
+		if manifest, err := readSessionManifest(session); err == nil {
+			usage.keep = manifest.Keep
+		} else {
+			// Kept rather than deleted on a guess.
+			logging.WarningLogger.Printf("Session %s is not cleaned up: %v", session, err)
+			usage.keep = true
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// measureTree adds the sizes of the files under name to usage, and moves its
+// modification time to the latest of theirs.
+func measureTree(store storage.Backend, name string, usage *sessionUsage) {
+	entries, err := store.ReadDir(name)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		child := storage.Join(name, entry.Name())
+		if entry.IsDir() {
+			measureTree(store, child, usage)
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		usage.bytes += info.Size()
+		if info.ModTime().After(usage.modified) {
+			usage.modified = info.ModTime()
+		}
+	}
+}
+
+// removeTree deletes a stored folder and everything in it.
+func removeTree(store storage.Backend, name string) error {
+	entries, err := store.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		child := storage.Join(name, entry.Name())
+		if entry.IsDir() {
+			err = removeTree(store, child)
+		} else {
+			err = store.Remove(child)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return store.Remove(name)
+}
+
+// removeEmptyPlatform deletes the platform folder of a deleted session when
+// it has no session left; it would be listed as an empty session otherwise.
+func removeEmptyPlatform(store storage.Backend, session string) {
+	platform := sessionPlatform(session)
+	if platform == "" {
+		return
+	}
+	if entries, err := store.ReadDir(platform); err == nil && len(entries) == 0 {
+		store.Remove(platform)
+	}
+}
+
+// lastCleanupText describes the last video cleanup for the video list, ""
+// before the first.
+func lastCleanupText() string {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	if lastCleanup == nil {
+		return ""
+	}
+	return lastCleanup.Time.Format("2006-01-02 15:04") + ": " + lastCleanup.String()
+}
+
+// formatGB formats a size in gigabytes.
+func formatGB(bytes int64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(bytes)/(1<<30)), ".0") + " GB"
+}
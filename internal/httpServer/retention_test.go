@@ -0,0 +1,193 @@
+package httpServer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/state"
+)
+
+func TestExpiredSessionsAreTheOldestNotKept(t *testing.T) {
+	now := time.Date(2025, 3, 10, 3, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	usages := []sessionUsage{
+		{session: "M3", modified: now.Add(-1 * day), bytes: 30},
+		{session: "M1", modified: now.Add(-9 * day), bytes: 10},
+		{session: "K", modified: now.Add(-20 * day), bytes: 50, keep: true},
+		{session: "M2", modified: now.Add(-5 * day), bytes: 20},
+	}
+	names := func(expired []sessionUsage) []string {
+		var names []string
+		for _, usage := range expired {
+			names = append(names, usage.session)
+		}
+		return names
+	}
+
+	if got := names(expiredSessions(usages, now, 7*day, 0)); !reflect.DeepEqual(got, []string{"M1"}) {
+		t.Fatalf("older than 7 days: %v", got)
+	}
+	// 110 bytes in all: M1 and M2 go to get to 80, the kept one stays.
+	if got := names(expiredSessions(usages, now, 0, 80)); !reflect.DeepEqual(got, []string{"M1", "M2"}) {
+		t.Fatalf("above 80 bytes: %v", got)
+	}
+	if got := names(expiredSessions(usages, now, 0, 10)); !reflect.DeepEqual(got, []string{"M1", "M2", "M3"}) {
+		t.Fatalf("above 10 bytes: %v", got)
+	}
+	if got := expiredSessions(usages, now, 0, 0); len(got) != 0 {
+		t.Fatalf("without limits: %v", names(got))
+	}
+}
+
+func TestCleanupVideosSparesKeptAndCurrentSessions(t *testing.T) {
+	videoDir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-30 * 24 * time.Hour)
+	write := func(name string, size int, modified time.Time) {
+		path := filepath.Join(videoDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("A/Old/video.mp4", 1000, old)
+	write("B/Old/video.mp4", 1000, old)
+	write("Kept/video.mp4", 1000, old)
+	if err := os.WriteFile(filepath.Join(videoDir, "Kept", "session.json"), []byte(`{"notes":"","keep":true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	write("Current/video.mp4", 1000, old)
+	write("Recent/video.mp4", 1000, now)
+
+	oldVideoDir, oldAge, oldMax := config.GetVideoDir(), config.VideoMaxAge, config.MaxVideoBytes
+	oldSession, oldDirs := state.CurrentSession, config.PlatformDirs
+	t.Cleanup(func() {
+		config.SetVideoDir(oldVideoDir)
+		config.VideoMaxAge, config.MaxVideoBytes = oldAge, oldMax
+		state.CurrentSession, config.PlatformDirs = oldSession, oldDirs
+	})
+	config.SetVideoDir(videoDir)
+	config.VideoMaxAge, config.MaxVideoBytes = 7*24*time.Hour, 0
+	state.CurrentSession, config.PlatformDirs = "Current", false
+
+	report, err := CleanupVideos(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"A/Old", "B/Old"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Fatalf("deleted %v, want %v", report.Deleted, want)
+	}
+	if report.Reclaimed != 2000 {
+		t.Fatalf("reclaimed %d bytes, want 2000", report.Reclaimed)
+	}
+	for _, gone := range []string{"A", "B"} {
+		if _, err := os.Stat(filepath.Join(videoDir, gone)); !os.IsNotExist(err) {
+			t.Fatalf("%s is still there: %v", gone, err)
+		}
+	}
+	for _, kept := range []string{"Kept/video.mp4", "Current/video.mp4", "Recent/video.mp4"} {
+		if _, err := os.Stat(filepath.Join(videoDir, filepath.FromSlash(kept))); err != nil {
+			t.Fatalf("%s was deleted: %v", kept, err)
+		}
+	}
+	if text := lastCleanupText(); !strings.Contains(text, "2 sessions deleted") {
+		t.Fatalf("last cleanup shown as %q", text)
+	}
+}
+
+func TestSessionKeepIsSavedInTheManifest(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(videoDir, "M1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	if _, err := saveSessionNotes("M1", "Camera 2 moved"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saveSessionKeep("M1", true); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := readSessionManifest("M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manifest.Keep || manifest.Notes != "Camera 2 moved" {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+}
+
+func TestSessionKeepTakesTheControlTokenAndIsRefusedByViewers(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(videoDir, "M1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	control.SetToken("secret")
+	t.Cleanup(func() {
+		config.SetVideoDir(oldVideoDir)
+		control.SetToken("")
+		config.Viewer = false
+	})
+
+	send := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/sessions/M1/keep", strings.NewReader(`{"keep":true}`))
+		req = mux.SetURLVars(req, map[string]string{"session": "M1"})
+		if token != "" {
+			req.Header.Set(control.TokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		handleSessionKeep(rec, req)
+		return rec.Code
+	}
+	if code := send(""); code != http.StatusUnauthorized {
+		t.Fatalf("keep without the control token: %d", code)
+	}
+	if code := send("secret"); code != http.StatusOK {
+		t.Fatalf("keep with the control token: %d", code)
+	}
+	config.Viewer = true
+	if code := send("secret"); code != http.StatusServiceUnavailable {
+		t.Fatalf("keep on a viewer: %d", code)
+	}
+}
+
+func TestViewersRefuseNotesAndAnnotations(t *testing.T) {
+	config.Viewer = true
+	t.Cleanup(func() { config.Viewer = false })
+
+	for _, tc := range []struct {
+		name    string
+		vars    map[string]string
+		handler http.HandlerFunc
+	}{
+		{"voice note", map[string]string{"session": "M1", "attempt": "John_Smith_SNATCH_attempt1"}, handleReplayNote},
+		{"operator notes", map[string]string{"session": "M1"}, handleSessionNotes},
+		{"annotation", map[string]string{"session": "M1", "file": "replay.mp4"}, handleSaveAnnotation},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+		req = mux.SetURLVars(req, tc.vars)
+		rec := httptest.NewRecorder()
+		tc.handler(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s on a viewer: %d", tc.name, rec.Code)
+		}
+	}
+}
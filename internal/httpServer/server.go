@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -19,8 +20,10 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/owlcms/replays/internal/config"
 	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/control"
 	"github.com/owlcms/replays/internal/logging"
 	"github.com/owlcms/replays/internal/state"
+	"github.com/owlcms/replays/internal/storage"
 )
 
 var (
@@ -29,7 +32,7 @@ var (
 	upgrader  = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool { return true },
 	}
-	clients   = make(map[*websocket.Conn]bool)
+	clients   = make(map[*wsClient]bool)
 	broadcast = make(chan StatusMessage)
 	mu        sync.Mutex
 )
@@ -37,6 +40,16 @@ var (
 type VideoInfo struct {
 	Filename    string
 	DisplayName string
+	NoteKey     string // attempt of a replay, to attach a voice note
+	NoteURL     string
+	MultiAngle  string // cameras of the attempt combined into one video, if any
+	Annotation  string // rendered annotation of the replay, if any
+	SlowMotion  []SlowMotionLink
+	Proxies     []ProxyLink
+	Inset       string // copy with the context camera inset, if any
+	AttemptLink string // page of the attempt, whatever its files are called
+	Broken      string // why the replay cannot be played, "" when it can
+	sortKey     string
 }
 
 type TemplateData struct {
@@ -44,14 +57,25 @@ type TemplateData struct {
 	StatusMsg            string
 	StatusCode           StatusCode // Change type to StatusCode instead of int
 	Sessions             []string
-	SelectedSession      string // Currently selected directory
-	ActiveSession        string // Current competition session from state
+	SelectedSession      string   // Currently selected directory
+	Platforms            []string // platforms with a session folder
+	SelectedPlatform     string   // platform whose sessions are listed, "" for all
+	SessionNotes         string   // operator notes of the selected session
+	ActiveSession        string   // Current competition session from state
 	NoSessions           bool
 	Platform             string // Add Platform field
 	HasMultiplePlatforms bool
 	SortByAthlete        bool // Add field for athlete sorting option
+	SortByJury           bool // Latest attempt's cameras grouped together
 	ShowAll              bool // Add field for showing all videos
 	TotalCount           int  // Add field for total video count
+	MaintenanceUntil     string
+	DiskAlert            string // the disk of the videos is (almost) full
+	FfmpegLogToggle      bool   // detailed ffmpeg logs can be asked for one attempt (not a viewer, logFfmpeg off)
+	FfmpegLog            string // config.FfmpegLogState, "" when logFfmpeg logs every attempt
+	Cleanup              bool   // the nightly maintenance deletes old sessions
+	SessionKept          bool   // the selected session is never deleted
+	LastCleanup          string // what the last video cleanup deleted
 }
 
 type VideoCountMessage struct {
@@ -83,36 +107,47 @@ type ReplayStateResponse struct {
 }
 
 type ReplayFileEntry struct {
-	Camera   int    `json:"camera"`
-	Filename string `json:"filename"`
-	URL      string `json:"url"`
+	Camera     int              `json:"camera"`
+	Filename   string           `json:"filename"`
+	URL        string           `json:"url"`
+	Annotation string           `json:"annotation,omitempty"`
+	SlowMotion []SlowMotionLink `json:"slowMotion,omitempty"`
+	Proxies    []ProxyLink      `json:"proxies,omitempty"`
+	Inset      string           `json:"inset,omitempty"`
 }
 
 type ReplayLift struct {
-	Timestamp   string            `json:"timestamp"`
+	Timestamp   string            `json:"timestamp"` // as in the file names, in UTC when it ends with Z
+	Time        string            `json:"time"`      // local time with its offset from UTC (RFC 3339)
 	Athlete     string            `json:"athlete"`
 	LiftType    string            `json:"liftType"`
 	Attempt     int               `json:"attempt"`
 	ReplayCount int               `json:"replayCount"`
 	Replays     []ReplayFileEntry `json:"replays"`
+	Note        string            `json:"note,omitempty"`
+	MultiAngle  string            `json:"multiAngle,omitempty"`
+	Link        string            `json:"link"` // attempt link, stable across re-encodes
 }
 
 type ReplaySessionSummary struct {
 	ID        string `json:"id"`
 	Name      string `json:"name"`
+	Platform  string `json:"platform,omitempty"`
 	Active    bool   `json:"active"`
 	LiftCount int    `json:"liftCount"`
 }
 
 type ReplaySessionsResponse struct {
 	ActiveSession string                 `json:"activeSession,omitempty"`
+	Platforms     []string               `json:"platforms,omitempty"`
 	Sessions      []ReplaySessionSummary `json:"sessions"`
 }
 
 type ReplaySessionInfo struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Active bool   `json:"active"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Platform string `json:"platform,omitempty"`
+	Active   bool   `json:"active"`
 }
 
 type ReplaySessionLiftsResponse struct {
@@ -132,6 +167,10 @@ type ParsedReplayFile struct {
 	AttemptNumber int
 	Camera        int
 	URL           string
+	Annotation    string
+	SlowMotion    []SlowMotionLink
+	Proxies       []ProxyLink
+	Inset         string
 }
 
 type replayResponseRecorder struct {
@@ -162,7 +201,7 @@ func (r *replayResponseRecorder) Write(data []byte) (int, error) {
 	return written, err
 }
 
-var replayFilenamePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})_(\d{2}h\d{2}m\d{2}s)_(.+)_(CLEANJERK|SNATCH)_attempt(\d+)_Camera(\d+)\.mp4$`)
+var replayFilenamePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})_(\d{2}h\d{2}m\d{2}sZ?)_(.+)_(CLEANJERK|SNATCH)_attempt(\d+)_Camera(\d+)\.(?:mp4|mov|mkv)$`)
 
 func init() {
 	// Load templates from embedded filesystem
@@ -173,8 +212,31 @@ func init() {
 	}
 }
 
-// StartServer starts the HTTP server on the specified port
-func StartServer(port int, _ bool) {
+// Listen opens the HTTP listener on port. When that port is taken, the
+// following ports are tried, tries ports in total. It returns the port in use.
+func Listen(port, tries int) (net.Listener, int, error) {
+	if tries < 1 {
+		tries = 1
+	}
+	var lastErr error
+	for candidate := port; candidate < port+tries && candidate <= 65535; candidate++ {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", candidate))
+		if err == nil {
+			if candidate != port {
+				logging.WarningLogger.Printf("HTTP port %d is in use, using port %d instead", port, candidate)
+			}
+			return listener, candidate, nil
+		}
+		logging.InfoLogger.Printf("HTTP port %d unavailable: %v", candidate, err)
+		lastErr = err
+	}
+	return nil, 0, fmt.Errorf("no free HTTP port between %d and %d: %w", port, port+tries-1, lastErr)
+}
+
+// StartServer serves HTTP requests on a listener obtained from Listen.
+// verbose opens /debug/state without the control token.
+func StartServer(listener net.Listener, verbose bool) {
+	debugVerbose = verbose
 	router := mux.NewRouter()
 
 	// Serve static files from embedded filesystem
@@ -182,20 +244,37 @@ func StartServer(port int, _ bool) {
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fileServer))
 
 	// Serve video files
-	x := config.GetVideoDir()
-	logging.InfoLogger.Printf("Serving video files from %s\n", x)
-	router.PathPrefix("/videos/").Handler(http.StripPrefix("/videos/", http.FileServer(http.Dir(x))))
+	logging.InfoLogger.Printf("Serving video files from %v\n", storage.Current())
+	router.PathPrefix("/videos/").Handler(http.StripPrefix("/videos/", withQuality(http.FileServer(storage.FileSystem))))
 
 	router.HandleFunc("/", listFilesHandler)
 	router.HandleFunc("/api/sessions", handleReplaySessions)
-	router.HandleFunc("/api/sessions/{session}/lifts", handleReplaySessionLifts)
+	router.HandleFunc("/api/sessions/"+sessionRoute+"/lifts", handleReplaySessionLifts)
+	router.HandleFunc("/api/sessions/"+sessionRoute+"/export.{format:csv|json}", handleSessionExport)
+	router.HandleFunc("/api/sessions/"+sessionRoute+"/notes/{attempt}", handleReplayNote)
+	router.HandleFunc("/api/sessions/"+sessionRoute+"/operator-notes", handleSessionNotes)
+	router.HandleFunc("/api/sessions/"+sessionRoute+"/keep", handleSessionKeep)
+	router.HandleFunc("/api/sessions/"+sessionRoute+"/annotations/{file}", handleSaveAnnotation)
+	router.HandleFunc("/annotate/"+sessionRoute+"/{file}", handleAnnotate)
+	router.HandleFunc(attemptRoute, handleAttempt)
+	router.HandleFunc("/status", handleStatus)
 	router.HandleFunc("/api/replay-state", handleReplayState)
+	router.HandleFunc("/api/long-recording/{action}", handleLongRecording)
+	router.HandleFunc("/calibration", handleCalibrationPage)
+	router.HandleFunc("/api/calibration/start", handleCalibrate)
+	router.HandleFunc("/api/maintenance/{action}", handleMaintenance)
+	router.HandleFunc("/api/ffmpeg-log/{action}", handleFfmpegLog)
+	router.HandleFunc("/api/simulate-decision", handleSimulateDecision)
 	router.HandleFunc("/ws", handleWebSocket)
-	// Accept /replay/{camera:[0-9]+} and /replay/{camera:[0-9]+}.mp4
+	router.HandleFunc("/debug/state", handleDebugState)
+	if !config.Viewer {
+		router.HandleFunc(control.Path, control.HandleNode)
+	}
+	// Accept /replay/{camera:[0-9]+} and /replay/{camera:[0-9]+}.mp4 (or .mov, .mkv)
 	router.HandleFunc("/replay/{camera:[0-9]+}", handleReplay)
-	router.HandleFunc("/replay/{camera:[0-9]+}.mp4", handleReplay).Name("replay-mp4")
+	router.HandleFunc("/replay/{camera:[0-9]+}.{ext:mp4|mov|mkv}", handleReplay).Name("replay-file")
 
-	addr := fmt.Sprintf(":%d", port)
+	addr := listener.Addr().String()
 	Server = &http.Server{
 		Addr:    addr,
 		Handler: router,
@@ -205,27 +284,38 @@ func StartServer(port int, _ bool) {
 	go handleMessages()
 
 	logging.InfoLogger.Printf("Starting HTTP server on %s\n", addr)
-	if err := Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := Server.Serve(listener); err != nil && err != http.ErrServerClosed {
 		logging.ErrorLogger.Printf("Failed to start server: %v", err)
 	}
 }
 
 // listFilesHandler lists all files in the videos directory as clickable hyperlinks
 func listFilesHandler(w http.ResponseWriter, r *http.Request) {
-	files, err := os.ReadDir(config.GetVideoDir())
+	store := storage.Current()
+	folders, err := sessionFolders(store)
 	if err != nil {
 		http.Error(w, "Failed to read videos directory", http.StatusInternalServerError)
 		return
 	}
 
 	// Get selected session from query parameter or active session
+	selectedPlatform := folderName(r.URL.Query().Get("platform"))
 	selectedSession := r.URL.Query().Get("session")
 	if selectedSession == "" {
-		selectedSession = strings.ReplaceAll(state.CurrentSession, " ", "_")
+		selectedSession = currentReplaySessionName()
+	} else {
+		selectedSession = findSession(folders, selectedSession, selectedPlatform)
+	}
+	if _, err := sanitizeReplaySessionID(selectedSession); err != nil {
+		selectedSession = ""
+	}
+	if !r.URL.Query().Has("platform") {
+		selectedPlatform = sessionPlatform(selectedSession)
 	}
 
 	// Get sorting preference from query parameter
 	sortByAthlete := r.URL.Query().Get("sortBy") == "athlete"
+	sortByJury := r.URL.Query().Get("sortBy") == "jury"
 
 	// Get time ordering preference (asc/desc) for athlete sorting
 	timeOrder := r.URL.Query().Get("timeOrder")
@@ -236,11 +326,17 @@ func listFilesHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get list of sessions (subdirectories)
 	var sessions []string
-	for _, f := range files {
-		if f.IsDir() && f.Name() != "unsorted" {
-			sessions = append(sessions, f.Name())
+	for _, folder := range folders {
+		if !isUnsorted(folder) {
+			sessions = append(sessions, folder)
 		}
 	}
+	platforms := sessionPlatforms(sessions)
+	if selectedSession != "" && sessionPlatform(selectedSession) != selectedPlatform && selectedPlatform != "" {
+		// The platform was switched: show its latest session.
+		selectedSession = ""
+	}
+	sessions = filterPlatform(sessions, selectedPlatform)
 
 	// If no sessions exist, show a message instead
 	if len(sessions) == 0 {
@@ -251,65 +347,120 @@ func listFilesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A viewer follows no competition session: show the latest one.
+	if selectedSession == "" && (config.Viewer || selectedPlatform != "") {
+		selectedSession = latestSessionDir(store, sessions)
+	}
+
 	// Create directory if it doesn't exist yet
-	sessionDir := filepath.Join(config.GetVideoDir(), selectedSession)
-	if selectedSession != "" && selectedSession != "unsorted" {
-		if err := os.MkdirAll(sessionDir, os.ModePerm); err != nil {
+	if selectedSession != "" && !isUnsorted(selectedSession) && !config.Viewer {
+		if err := store.MkdirAll(selectedSession); err != nil {
 			logging.ErrorLogger.Printf("Failed to create session directory: %v", err)
 		}
 	}
 
 	// Read files from the session directory
-	sessionDir = filepath.Join(config.GetVideoDir(), selectedSession)
-	files, err = os.ReadDir(sessionDir)
+	files, err := store.ReadDir(selectedSession)
 	if err != nil && !os.IsNotExist(err) {
 		http.Error(w, "Failed to read session directory", http.StatusInternalServerError)
 		return
 	}
 
-	// Sort files in reverse order (most recent first)
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name() > files[j].Name()
-	})
-
-	// Count only valid video files (those starting with a date)
-	datePattern := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
-	validFiles := make([]os.DirEntry, 0)
-	for _, file := range files {
-		if !file.IsDir() && datePattern.MatchString(file.Name()) {
-			validFiles = append(validFiles, file)
+	var operatorNotes string
+	sessionKept := false
+	if selectedSession != "" {
+		manifest, err := readSessionManifest(selectedSession)
+		if err != nil {
+			logging.WarningLogger.Printf("Failed to read the manifest of session %s: %v", selectedSession, err)
 		}
+		operatorNotes = manifest.Notes
+		sessionKept = manifest.Keep
 	}
 
-	// Regex to extract date, hour, name, lift type, attempt, and camera
-	re := regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})_(\d{2}h\d{2}m\d{2}s)_(.+)_(CLEANJERK|SNATCH)_attempt(\d+)_Camera(\d+)\.mp4$`)
-
+	notes := sessionNotes(files)
+	multiAngle := sessionMultiAngle(files)
+	annotations := sessionAnnotations(files)
+	slowMotion := sessionSlowMotion(files, selectedSession+"/")
+	proxies := sessionProxies(files, selectedSession+"/")
+	insets := sessionInsets(files, selectedSession+"/")
 	videos := make([]VideoInfo, 0)
-	for _, file := range validFiles {
-		if !file.IsDir() {
-			fileName := file.Name()
-			// Replace Clean_and_Jerk with CJ
-			fileName2 := strings.ReplaceAll(fileName, "Clean_and_Jerk", "CJ")
-			matches := re.FindStringSubmatch(fileName2)
-			if len(matches) == 7 {
-				date := matches[1]
-				hourMinuteSeconds := strings.NewReplacer("h", ":", "m", ":", "s", "").Replace(matches[2])
-				name := strings.ReplaceAll(matches[3], "_", " ")
-				lift := matches[4]
-				attempt := matches[5]
-				camera := matches[6]
-				displayName := fmt.Sprintf("%s %s - %s - %s - attempt %s - Camera %s",
-					date, hourMinuteSeconds, name, lift, attempt, camera)
-				// Use forward slashes for URL path
-				urlPath := strings.Join([]string{selectedSession, fileName}, "/")
-				videos = append(videos, VideoInfo{
-					Filename:    urlPath,
-					DisplayName: displayName,
-				})
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		fileName := file.Name()
+		// Use forward slashes for URL path
+		urlPath := strings.Join([]string{selectedSession, fileName}, "/")
+
+		// Replace Clean_and_Jerk with CJ
+		fileName2 := strings.ReplaceAll(fileName, "Clean_and_Jerk", "CJ")
+		matches := attemptFilePattern.FindStringSubmatch(fileName2)
+		if len(matches) == 7 {
+			timestamp := matches[1] + "_" + matches[2]
+			name := strings.ReplaceAll(matches[3], "_", " ")
+			lift := matches[4]
+			attempt := matches[5]
+			camera := matches[6]
+			displayName := fmt.Sprintf("%s - %s - %s - attempt %s - Camera %s",
+				localReplayTime(timestamp, time.Local), name, lift, attempt, camera)
+			video := VideoInfo{
+				Filename:    urlPath,
+				DisplayName: displayName,
+				NoteKey:     attemptKey(fileName),
+				SlowMotion:  slowMotion[strings.TrimSuffix(fileName, filepath.Ext(fileName))],
+				Proxies:     proxies[strings.TrimSuffix(fileName, filepath.Ext(fileName))],
+				Inset:       insets[strings.TrimSuffix(fileName, filepath.Ext(fileName))],
+				sortKey:     replaySortKey(timestamp, time.Local) + strings.TrimPrefix(fileName, timestamp),
+			}
+			if number, err := strconv.Atoi(attempt); err == nil {
+				video.AttemptLink = attemptLink(selectedSession, name, lift, number)
+			}
+			if note, ok := notes[video.NoteKey]; ok && video.NoteKey != "" {
+				video.NoteURL = selectedSession + "/" + note
 			}
+			if combined, ok := multiAngle[video.NoteKey]; ok && video.NoteKey != "" {
+				video.MultiAngle = selectedSession + "/" + combined
+			}
+			if image, ok := annotations[fileName]; ok {
+				video.Annotation = selectedSession + "/" + image
+			}
+			if info, err := file.Info(); err == nil && info.Size() == 0 {
+				video.Broken = "empty file"
+			}
+			videos = append(videos, video)
+			continue
+		}
+
+		// Files added by the operator: listed once probed as readable videos.
+		if !isExternalCandidate(fileName) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		localPath, ok := store.LocalPath(storage.Join(selectedSession, fileName))
+		if !ok {
+			continue
+		}
+		if external, ok := cachedExternalVideo(selectedSession, localPath, info); ok {
+			video := VideoInfo{
+				Filename:    urlPath,
+				DisplayName: external.displayName,
+				sortKey:     external.sortKey,
+			}
+			if image, ok := annotations[fileName]; ok {
+				video.Annotation = selectedSession + "/" + image
+			}
+			videos = append(videos, video)
 		}
 	}
 
+	// Most recent first; the sort keys start with the date and time in UTC
+	sort.SliceStable(videos, func(i, j int) bool {
+		return videos[i].sortKey > videos[j].sortKey
+	})
+
 	// Sort videos based on parameter
 	if sortByAthlete {
 		// Sort by athlete name, then by date and time
@@ -323,15 +474,15 @@ func listFilesHandler(w http.ResponseWriter, r *http.Request) {
 				athleteNameI := partsI[1]
 				athleteNameJ := partsJ[1]
 
-				// If athlete names are the same, sort by date and time (which is the first part)
+				// If athlete names are the same, sort by date and time
 				if athleteNameI == athleteNameJ {
-					// The date and time are the first part, so we can compare the original strings
+					// The sort keys start with the date and time in UTC
 					if ascendingTime {
 						// Ascending order (older first)
-						return videos[i].Filename < videos[j].Filename
+						return videos[i].sortKey < videos[j].sortKey
 					} else {
 						// Descending order (most recent first)
-						return videos[i].Filename > videos[j].Filename
+						return videos[i].sortKey > videos[j].sortKey
 					}
 				}
 
@@ -342,6 +493,8 @@ func listFilesHandler(w http.ResponseWriter, r *http.Request) {
 			// Fallback to filename comparison if parsing fails
 			return videos[i].Filename > videos[j].Filename
 		})
+	} else if sortByJury {
+		sortForJury(videos)
 	} else {
 		// Already sorted in reverse order (most recent first) by the code above
 		// This is the default sort
@@ -359,12 +512,23 @@ func listFilesHandler(w http.ResponseWriter, r *http.Request) {
 		StatusCode:           statusCode,
 		Sessions:             sessions,
 		SelectedSession:      selectedSession,
+		Platforms:            platforms,
+		SelectedPlatform:     selectedPlatform,
+		SessionNotes:         operatorNotes,
 		ActiveSession:        state.CurrentSession, // Current competition session
 		Platform:             replays.GetCurrentConfig().Platform,
 		HasMultiplePlatforms: len(state.AvailablePlatforms) > 1,
 		SortByAthlete:        sortByAthlete,
+		SortByJury:           sortByJury,
 		ShowAll:              showAll,
 		TotalCount:           len(videos),
+		MaintenanceUntil:     maintenanceUntilText(),
+		DiskAlert:            diskAlertText(),
+		FfmpegLogToggle:      !config.Viewer && !config.LogFfmpeg,
+		FfmpegLog:            ffmpegLogText(),
+		Cleanup:              !config.Viewer && (config.VideoMaxAge > 0 || config.MaxVideoBytes > 0),
+		SessionKept:          sessionKept,
+		LastCleanup:          lastCleanupText(),
 	}
 
 	// Remove the SendStatus call here as it's not needed
@@ -375,6 +539,49 @@ func listFilesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// latestSessionDir returns the session whose folder was modified last.
+func latestSessionDir(store storage.Backend, sessions []string) string {
+	latest := ""
+	var latestTime time.Time
+	for _, session := range sessions {
+		info, err := store.Stat(session)
+		if err != nil {
+			continue
+		}
+		if latest == "" || info.ModTime().After(latestTime) {
+			latest, latestTime = session, info.ModTime()
+		}
+	}
+	return latest
+}
+
+var cameraSuffixPattern = regexp.MustCompile(`_Camera(\d+)\.(?:mp4|mov|mkv)$`)
+
+// sortForJury orders videos attempt by attempt, most recent attempt first,
+// with the cameras of each attempt together in camera order, so the jury
+// finds Camera 1..N of the lift they are reviewing side by side.
+func sortForJury(videos []VideoInfo) {
+	attemptKey := func(v VideoInfo) string {
+		return cameraSuffixPattern.ReplaceAllString(v.sortKey, "")
+	}
+	cameraNumber := func(v VideoInfo) int {
+		matches := cameraSuffixPattern.FindStringSubmatch(v.Filename)
+		if len(matches) != 2 {
+			return 0
+		}
+		n, _ := strconv.Atoi(matches[1])
+		return n
+	}
+	sort.SliceStable(videos, func(i, j int) bool {
+		keyI, keyJ := attemptKey(videos[i]), attemptKey(videos[j])
+		if keyI != keyJ {
+			// Keys start with the attempt date and time in UTC.
+			return keyI > keyJ
+		}
+		return cameraNumber(videos[i]) < cameraNumber(videos[j])
+	})
+}
+
 // handleWebSocket upgrades HTTP connection to WebSocket
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -382,16 +589,20 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		logging.ErrorLogger.Printf("WebSocket upgrade failed: %v", err)
 		return
 	}
-	defer conn.Close()
+	client := newWSClient(conn)
+	defer client.close()
+	go client.writeLoop()
 
 	mu.Lock()
-	clients[conn] = true
+	clients[client] = true
 	// Send current status immediately after connection
 	if statusMsg != "" {
 		msg := lastStatusMessage
 		if msg.Text == "" {
 			msg = buildStatusMessage(statusCode, statusMsg)
 		}
+		msg.MaintenanceUntil = maintenanceUntilText()
+		msg.ServerTime = nowMillis()
 
 		// prevent infinite loop if we are reloading after saving videos
 		if VideoReadyReloading {
@@ -400,9 +611,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			msg.Code = statusCode
 			msg.Text = statusMsg
 			lastStatusMessage = msg
-			if err := conn.WriteJSON(msg); err != nil {
-				logging.ErrorLogger.Printf("Failed to send initial status: %v", err)
-			}
+			client.enqueue(msg)
 			VideoReadyReloading = false
 		} else {
 			if strings.Contains(statusMsg, "Recording") {
@@ -411,23 +620,17 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			}
 			msg.Text = statusMsg
 			lastStatusMessage = msg
-			if err := conn.WriteJSON(msg); err != nil {
-				logging.ErrorLogger.Printf("Failed to send initial status: %v", err)
-			}
+			client.enqueue(msg)
 		}
 	}
 	VideoReadyReloading = false
 	mu.Unlock()
 
-	// Keep the connection alive until it closes
-	for {
-		if _, _, err := conn.ReadMessage(); err != nil {
-			break
-		}
-	}
+	// Keep the connection alive until it closes or stops answering pings
+	client.readLoop()
 
 	mu.Lock()
-	delete(clients, conn)
+	delete(clients, client)
 	mu.Unlock()
 }
 
@@ -441,15 +644,7 @@ func handleMessages() {
 		logging.InfoLogger.Printf("Broadcasting status: %s (code: %d)", msg.Text, msg.Code)
 
 		// Broadcast to all connected clients
-		for client := range clients {
-			err := client.WriteJSON(msg)
-			if err != nil {
-				logging.ErrorLogger.Printf("WebSocket error: %v", err)
-				client.Close()
-				delete(clients, client)
-				continue
-			}
-		}
+		broadcastToClients(msg)
 		mu.Unlock()
 	}
 }
@@ -471,7 +666,7 @@ func StopServer() {
 }
 
 func currentReplaySessionName() string {
-	return strings.ReplaceAll(strings.TrimSpace(state.CurrentSession), " ", "_")
+	return platformSession(strings.ReplaceAll(strings.TrimSpace(state.CurrentSession), " ", "_"))
 }
 
 func setReplayAPIHeaders(w http.ResponseWriter) {
@@ -496,7 +691,7 @@ func parseReplayFilename(session string, filename string) (*ParsedReplayFile, bo
 	}
 
 	camera, err := strconv.Atoi(matches[6])
-	if err != nil || camera < 1 {
+	if err != nil || camera < config.ScoreboardCameraNumber {
 		return nil, false
 	}
 
@@ -512,20 +707,29 @@ func parseReplayFilename(session string, filename string) (*ParsedReplayFile, bo
 	}, true
 }
 
+// sanitizeReplaySessionID checks a session identifier: a folder name, or
+// "platform/session" for a session stored by platform.
 func sanitizeReplaySessionID(session string) (string, error) {
 	trimmed := strings.TrimSpace(session)
-	if trimmed == "" || trimmed == "." || trimmed == ".." || strings.ContainsAny(trimmed, `/\\`) {
+	parts := strings.Split(trimmed, "/")
+	if len(parts) > 2 {
 		return "", os.ErrInvalid
 	}
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." || strings.Contains(part, `\`) {
+			return "", os.ErrInvalid
+		}
+	}
 
 	return trimmed, nil
 }
 
-func scanReplayFilesForSession(session string) ([]ParsedReplayFile, error) {
-	sessionDir := filepath.Join(config.GetVideoDir(), session)
-	entries, err := os.ReadDir(sessionDir)
+// scanReplayFilesForSession returns the replays of a session, and its voice
+// notes and combined videos by attempt key.
+func scanReplayFilesForSession(session string) ([]ParsedReplayFile, map[string]string, map[string]string, error) {
+	entries, err := storage.Current().ReadDir(session)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	parsed := make([]ParsedReplayFile, 0, len(entries))
@@ -542,11 +746,23 @@ func scanReplayFilesForSession(session string) ([]ParsedReplayFile, error) {
 		parsed = append(parsed, *replayFile)
 	}
 
-	return parsed, nil
+	annotations := sessionAnnotations(entries)
+	slowMotion := sessionSlowMotion(entries, "/videos/"+session+"/")
+	proxies := sessionProxies(entries, "/videos/"+session+"/")
+	insets := sessionInsets(entries, "/videos/"+session+"/")
+	for i := range parsed {
+		if image, ok := annotations[parsed[i].Filename]; ok {
+			parsed[i].Annotation = "/videos/" + session + "/" + image
+		}
+		parsed[i].SlowMotion = slowMotion[strings.TrimSuffix(parsed[i].Filename, filepath.Ext(parsed[i].Filename))]
+		parsed[i].Proxies = proxies[strings.TrimSuffix(parsed[i].Filename, filepath.Ext(parsed[i].Filename))]
+		parsed[i].Inset = insets[strings.TrimSuffix(parsed[i].Filename, filepath.Ext(parsed[i].Filename))]
+	}
+	return parsed, sessionNotes(entries), sessionMultiAngle(entries), nil
 }
 
 func buildGroupedReplayLifts(session string) ([]ReplayLift, error) {
-	replayFiles, err := scanReplayFilesForSession(session)
+	replayFiles, notes, multiAngle, err := scanReplayFilesForSession(session)
 	if err != nil {
 		return nil, err
 	}
@@ -566,19 +782,31 @@ func buildGroupedReplayLifts(session string) ([]ReplayLift, error) {
 		if lift == nil {
 			lift = &ReplayLift{
 				Timestamp: replayFile.Timestamp,
+				Time:      replayTimeWithOffset(replayFile.Timestamp, time.Local),
 				Athlete:   replayFile.Athlete,
 				LiftType:  replayFile.LiftType,
 				Attempt:   replayFile.AttemptNumber,
 				Replays:   make([]ReplayFileEntry, 0, 4),
+				Link:      attemptLink(session, replayFile.Athlete, replayFile.LiftType, replayFile.AttemptNumber),
+			}
+			if note, ok := notes[attemptKey(replayFile.Filename)]; ok {
+				lift.Note = "/videos/" + session + "/" + note
+			}
+			if video, ok := multiAngle[attemptKey(replayFile.Filename)]; ok {
+				lift.MultiAngle = "/videos/" + session + "/" + video
 			}
 			grouped[groupKey] = lift
 			order = append(order, groupKey)
 		}
 
 		lift.Replays = append(lift.Replays, ReplayFileEntry{
-			Camera:   replayFile.Camera,
-			Filename: replayFile.Filename,
-			URL:      replayFile.URL,
+			Camera:     replayFile.Camera,
+			Filename:   replayFile.Filename,
+			URL:        replayFile.URL,
+			Annotation: replayFile.Annotation,
+			SlowMotion: replayFile.SlowMotion,
+			Proxies:    replayFile.Proxies,
+			Inset:      replayFile.Inset,
 		})
 	}
 
@@ -608,6 +836,8 @@ func sortReplayLifts(lifts []ReplayLift, sortMode string) string {
 	sort.Slice(lifts, func(i, j int) bool {
 		left := lifts[i]
 		right := lifts[j]
+		leftTime := replaySortKey(left.Timestamp, time.Local)
+		rightTime := replaySortKey(right.Timestamp, time.Local)
 
 		if effectiveSort == "athlete" {
 			leftAthlete := normalizeReplayAthleteSortKey(left.Athlete)
@@ -615,12 +845,12 @@ func sortReplayLifts(lifts []ReplayLift, sortMode string) string {
 			if leftAthlete != rightAthlete {
 				return leftAthlete < rightAthlete
 			}
-			if left.Timestamp != right.Timestamp {
-				return left.Timestamp > right.Timestamp
+			if leftTime != rightTime {
+				return leftTime > rightTime
 			}
 		} else {
-			if left.Timestamp != right.Timestamp {
-				return left.Timestamp > right.Timestamp
+			if leftTime != rightTime {
+				return leftTime > rightTime
 			}
 			leftAthlete := normalizeReplayAthleteSortKey(left.Athlete)
 			rightAthlete := normalizeReplayAthleteSortKey(right.Athlete)
@@ -639,14 +869,16 @@ func sortReplayLifts(lifts []ReplayLift, sortMode string) string {
 	return effectiveSort
 }
 
-func listReplaySessions() ([]ReplaySessionSummary, error) {
-	entries, err := os.ReadDir(config.GetVideoDir())
+func listReplaySessions(platform string) ([]ReplaySessionSummary, error) {
+	store := storage.Current()
+	folders, err := sessionFolders(store)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return make([]ReplaySessionSummary, 0), nil
 		}
 		return nil, err
 	}
+	folders = filterPlatform(folders, platform)
 
 	activeSession := currentReplaySessionName()
 	type replaySessionItem struct {
@@ -654,31 +886,27 @@ func listReplaySessions() ([]ReplaySessionSummary, error) {
 		modTime time.Time
 	}
 
-	items := make([]replaySessionItem, 0, len(entries))
-	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == "unsorted" {
+	items := make([]replaySessionItem, 0, len(folders))
+	for _, folder := range folders {
+		if isUnsorted(folder) {
 			continue
 		}
 
-		groupedLifts, err := buildGroupedReplayLifts(entry.Name())
+		groupedLifts, err := buildGroupedReplayLifts(folder)
 		if err != nil && !os.IsNotExist(err) {
 			return nil, err
 		}
 
-		info, err := entry.Info()
-		if err != nil {
-			info = nil
-		}
-
 		item := replaySessionItem{
 			summary: ReplaySessionSummary{
-				ID:        entry.Name(),
-				Name:      entry.Name(),
-				Active:    entry.Name() == activeSession,
+				ID:        folder,
+				Name:      sessionName(folder),
+				Platform:  sessionPlatform(folder),
+				Active:    folder == activeSession,
 				LiftCount: len(groupedLifts),
 			},
 		}
-		if info != nil {
+		if info, err := store.Stat(folder); err == nil {
 			item.modTime = info.ModTime()
 		}
 
@@ -703,6 +931,32 @@ func listReplaySessions() ([]ReplaySessionSummary, error) {
 	return sessions, nil
 }
 
+// ListReplaySessions returns the sessions of the video directory, the active
+// one first, then the most recently modified.
+func ListReplaySessions() ([]ReplaySessionSummary, error) {
+	return listReplaySessions("")
+}
+
+// ReplaySessionLifts returns the lifts recorded in a session, optionally only
+// those of one athlete (case-insensitive), sorted by time or athlete. The
+// sort actually applied is returned.
+func ReplaySessionLifts(session, athlete, sortMode string) ([]ReplayLift, string, error) {
+	lifts, err := buildGroupedReplayLifts(session)
+	if err != nil {
+		return nil, "", err
+	}
+	if athlete = strings.TrimSpace(athlete); athlete != "" {
+		filtered := make([]ReplayLift, 0, len(lifts))
+		for _, lift := range lifts {
+			if strings.EqualFold(strings.TrimSpace(lift.Athlete), athlete) {
+				filtered = append(filtered, lift)
+			}
+		}
+		lifts = filtered
+	}
+	return lifts, sortReplayLifts(lifts, sortMode), nil
+}
+
 func handleReplaySessions(w http.ResponseWriter, r *http.Request) {
 	setReplayAPIHeaders(w)
 	if r.Method == http.MethodOptions {
@@ -710,14 +964,20 @@ func handleReplaySessions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessions, err := listReplaySessions()
+	sessions, err := listReplaySessions(folderName(r.URL.Query().Get("platform")))
 	if err != nil {
 		http.Error(w, "Failed to enumerate replay sessions", http.StatusInternalServerError)
 		return
 	}
+	folders, err := sessionFolders(storage.Current())
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, "Failed to enumerate replay sessions", http.StatusInternalServerError)
+		return
+	}
 
 	response := ReplaySessionsResponse{
 		ActiveSession: currentReplaySessionName(),
+		Platforms:     sessionPlatforms(folders),
 		Sessions:      sessions,
 	}
 
@@ -739,8 +999,7 @@ func handleReplaySessionLifts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionDir := filepath.Join(config.GetVideoDir(), session)
-	info, err := os.Stat(sessionDir)
+	info, err := storage.Current().Stat(session)
 	if err != nil {
 		if os.IsNotExist(err) {
 			http.Error(w, "Replay session not found", http.StatusNotFound)
@@ -754,32 +1013,24 @@ func handleReplaySessionLifts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lifts, err := buildGroupedReplayLifts(session)
+	athleteFilterValue := strings.TrimSpace(r.URL.Query().Get("athlete"))
+	lifts, effectiveSort, err := ReplaySessionLifts(session, athleteFilterValue, r.URL.Query().Get("sort"))
 	if err != nil {
 		http.Error(w, "Failed to list session replays", http.StatusInternalServerError)
 		return
 	}
-
-	athleteFilterValue := strings.TrimSpace(r.URL.Query().Get("athlete"))
 	var athleteFilter *string
 	if athleteFilterValue != "" {
-		filteredLifts := make([]ReplayLift, 0, len(lifts))
-		for _, lift := range lifts {
-			if strings.EqualFold(strings.TrimSpace(lift.Athlete), athleteFilterValue) {
-				filteredLifts = append(filteredLifts, lift)
-			}
-		}
-		lifts = filteredLifts
 		athleteFilter = &athleteFilterValue
 	}
 
-	effectiveSort := sortReplayLifts(lifts, r.URL.Query().Get("sort"))
 	activeSession := currentReplaySessionName()
 	response := ReplaySessionLiftsResponse{
 		Session: ReplaySessionInfo{
-			ID:     session,
-			Name:   session,
-			Active: session == activeSession,
+			ID:       session,
+			Name:     sessionName(session),
+			Platform: sessionPlatform(session),
+			Active:   session == activeSession,
 		},
 		Sort:          effectiveSort,
 		AthleteFilter: athleteFilter,
@@ -798,34 +1049,24 @@ func resolveReplaySession() (string, error) {
 		return session, nil
 	}
 
-	entries, err := os.ReadDir(config.GetVideoDir())
+	store := storage.Current()
+	folders, err := sessionFolders(store)
 	if err != nil {
 		return "", err
 	}
 
-	var latestDir os.DirEntry
-	var latestModTime time.Time
-	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == "unsorted" {
-			continue
-		}
-
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-
-		if latestDir == nil || info.ModTime().After(latestModTime) {
-			latestDir = entry
-			latestModTime = info.ModTime()
+	var sessions []string
+	for _, folder := range folders {
+		if !isUnsorted(folder) {
+			sessions = append(sessions, folder)
 		}
 	}
-
-	if latestDir == nil {
+	latest := latestSessionDir(store, sessions)
+	if latest == "" {
 		return "", os.ErrNotExist
 	}
 
-	return latestDir.Name(), nil
+	return latest, nil
 }
 
 func handleReplayState(w http.ResponseWriter, _ *http.Request) {
@@ -844,10 +1085,15 @@ func handleReplayState(w http.ResponseWriter, _ *http.Request) {
 		CurrentAttempt:      state.CurrentAttempt,
 		CurrentCamera:       state.CurrentCameraNumber,
 		HasMultiplePlatform: len(state.AvailablePlatforms) > 1,
-		Cameras:             make([]ReplayCameraState, 0, 4),
+		Cameras:             make([]ReplayCameraState, 0, 5),
 	}
 
-	for camera := 1; camera <= 4; camera++ {
+	// Camera 0 is the attempt board, listed only when one is recorded.
+	firstCamera := 1
+	if config.GetScoreboardConfig() != nil {
+		firstCamera = config.ScoreboardCameraNumber
+	}
+	for camera := firstCamera; camera <= 4; camera++ {
 		replayState := ReplayCameraState{Camera: camera}
 		latestReplay, replayErr := findPublishedReplayForCamera(camera)
 		if replayErr == nil {
@@ -863,6 +1109,17 @@ func handleReplayState(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// replayContentType is the type of a replay file, given by its extension.
+func replayContentType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".mov":
+		return "video/quicktime"
+	case ".mkv":
+		return "video/x-matroska"
+	}
+	return "video/mp4"
+}
+
 // handleReplay serves the published replay for the given camera number.
 // Example filename: 2025-03-29_03h34m34s_DARSIGNY_Shad_CLEANJERK_attempt3_Camera1.mp4
 func handleReplay(w http.ResponseWriter, r *http.Request) {
@@ -872,10 +1129,12 @@ func handleReplay(w http.ResponseWriter, r *http.Request) {
 	requestTimestamp := replayLogTimestamp()
 	logging.InfoLogger.Printf("=== REPLAY REQUEST RECEIVED timestamp=%s method=%s uri=%q remote=%q rawCamera=%q userAgent=%q range=%q ifModifiedSince=%q ifNoneMatch=%q cacheControl=%q ===", requestTimestamp, r.Method, r.URL.RequestURI(), r.RemoteAddr, cameraNum, r.UserAgent(), r.Header.Get("Range"), r.Header.Get("If-Modified-Since"), r.Header.Get("If-None-Match"), r.Header.Get("Cache-Control"))
 
-	// Accept and strip a .mp4 extension if present in the URL
-	cameraNum = strings.TrimSuffix(cameraNum, ".mp4")
+	// Accept and strip a replay extension if present in the URL
+	for _, ext := range []string{".mp4", ".mov", ".mkv"} {
+		cameraNum = strings.TrimSuffix(cameraNum, ext)
+	}
 	camera, err := strconv.Atoi(cameraNum)
-	if err != nil || camera < 1 {
+	if err != nil || camera < config.ScoreboardCameraNumber {
 		logging.WarningLogger.Printf("=== REPLAY REQUEST REJECTED timestamp=%s rawCamera=%q reason=%q ===", replayLogTimestamp(), cameraNum, "invalid camera number")
 		http.Error(w, "Invalid camera number", http.StatusBadRequest)
 		return
@@ -904,8 +1163,21 @@ func handleReplay(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Serve the file with correct MIME type for .mp4 and no caching headers
-	videoPath := filepath.Join(config.GetVideoDir(), latestReplay.Session, latestReplay.Filename)
-	videoInfo, err := os.Stat(videoPath)
+	videoPath := storage.Join(latestReplay.Session, latestReplay.Filename)
+	quality := r.URL.Query().Get("quality")
+	if proxy := proxyOf(videoPath, quality); proxy != "" {
+		// A tablet on a slow network asked for the lower-bitrate copy.
+		videoPath = proxy
+		w.Header().Set("X-Replay-Quality", quality)
+	}
+	videoFile, err := storage.Current().Open(videoPath)
+	if err != nil {
+		logging.ErrorLogger.Printf("=== REPLAY REQUEST FILE OPEN FAILED timestamp=%s camera=%d video=%q error=%v ===", replayLogTimestamp(), camera, videoPath, err)
+		http.Error(w, "Replay file not available for camera "+cameraNum, http.StatusInternalServerError)
+		return
+	}
+	defer videoFile.Close()
+	videoInfo, err := videoFile.Stat()
 	if err != nil {
 		logging.ErrorLogger.Printf("=== REPLAY REQUEST FILE STAT FAILED timestamp=%s camera=%d video=%q error=%v ===", replayLogTimestamp(), camera, videoPath, err)
 		http.Error(w, "Replay file not available for camera "+cameraNum, http.StatusInternalServerError)
@@ -922,7 +1194,7 @@ func handleReplay(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Replay-Session", latestReplay.Session)
 	w.Header().Set("X-Replay-Filename", latestReplay.Filename)
 	w.Header().Set("X-Replay-One-Shot", "true")
-	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Type", replayContentType(videoPath))
 	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, proxy-revalidate, max-age=0")
 	w.Header().Set("Pragma", "no-cache")
 	w.Header().Set("Expires", "0")
@@ -940,7 +1212,7 @@ func handleReplay(w http.ResponseWriter, r *http.Request) {
 	r.Header.Del("If-Modified-Since")
 	r.Header.Del("If-None-Match")
 	responseRecorder := &replayResponseRecorder{ResponseWriter: w, camera: camera, generation: generation}
-	http.ServeFile(responseRecorder, r, videoPath)
+	http.ServeContent(responseRecorder, r, latestReplay.Filename, videoInfo.ModTime(), videoFile)
 	if responseRecorder.statusCode == 0 {
 		responseRecorder.statusCode = http.StatusOK
 	}
@@ -0,0 +1,530 @@
+package httpServer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+func TestListenMovesToNextFreePort(t *testing.T) {
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	listener, got, err := Listen(port, 5)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	if got <= port || got >= port+5 {
+		t.Fatalf("Listen picked port %d, want one of the next ports after %d", got, port)
+	}
+
+	if _, _, err := Listen(port, 1); err == nil {
+		t.Fatalf("expected an error when the only allowed port is taken")
+	}
+}
+
+func TestSortForJuryGroupsCamerasOfEachAttempt(t *testing.T) {
+	videos := []VideoInfo{
+		{Filename: "S1/2024-05-01_10h01m00s_B_SNATCH_attempt1_Camera2.mp4"},
+		{Filename: "S1/2024-05-01_10h01m00s_B_SNATCH_attempt1_Camera10.mkv"},
+		{Filename: "S1/2024-05-01_10h01m00s_B_SNATCH_attempt1_Camera1.mp4"},
+		{Filename: "S1/2024-05-01_10h00m00s_A_SNATCH_attempt1_Camera2.mp4"},
+		{Filename: "S1/2024-05-01_10h00m00s_A_SNATCH_attempt1_Camera1.mp4"},
+	}
+	for i := range videos {
+		name := strings.TrimPrefix(videos[i].Filename, "S1/")
+		videos[i].sortKey = replaySortKey(name[:19], time.UTC) + name[19:]
+	}
+	sortForJury(videos)
+
+	want := []string{
+		"S1/2024-05-01_10h01m00s_B_SNATCH_attempt1_Camera1.mp4",
+		"S1/2024-05-01_10h01m00s_B_SNATCH_attempt1_Camera2.mp4",
+		"S1/2024-05-01_10h01m00s_B_SNATCH_attempt1_Camera10.mkv",
+		"S1/2024-05-01_10h00m00s_A_SNATCH_attempt1_Camera1.mp4",
+		"S1/2024-05-01_10h00m00s_A_SNATCH_attempt1_Camera2.mp4",
+	}
+	for i, v := range videos {
+		if v.Filename != want[i] {
+			t.Fatalf("position %d: got %s, want %s", i, v.Filename, want[i])
+		}
+	}
+}
+
+func TestLatestSessionDirPicksLastModified(t *testing.T) {
+	dir := t.TempDir()
+	for i, session := range []string{"A", "B", "C"} {
+		if err := os.Mkdir(filepath.Join(dir, session), 0755); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(time.Duration(i-5) * time.Hour)
+		if session == "B" {
+			modTime = time.Now()
+		}
+		if err := os.Chtimes(filepath.Join(dir, session), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := latestSessionDir(storage.Local{Root: dir}, []string{"A", "B", "C", "missing"}); got != "B" {
+		t.Fatalf("latestSessionDir() = %q, want B", got)
+	}
+}
+
+func TestDescribeExternalVideo(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+	tests := []struct {
+		name        string
+		displayName string
+		sortPrefix  string
+	}{
+		{"2024-05-02 14.05.10 Smith snatch try 2 cam3.mov", "2024-05-02 14:05:10 - Smith - SNATCH - attempt 2 - Camera 3", ReplayTimestamp(time.Date(2024, 5, 2, 14, 5, 10, 0, time.Local))},
+		{"jones_clean_and_jerk.mp4", "2024-05-01 09:30:00 - jones - CJ", ReplayTimestamp(modTime)},
+		{"GOPR0042.MP4", "2024-05-01 09:30:00 - GOPR0042", ReplayTimestamp(modTime)},
+	}
+	for _, tt := range tests {
+		displayName, sortKey := describeExternalVideo(tt.name, modTime)
+		if displayName != tt.displayName {
+			t.Errorf("%s: display name %q, want %q", tt.name, displayName, tt.displayName)
+		}
+		if !strings.HasPrefix(sortKey, tt.sortPrefix) {
+			t.Errorf("%s: sort key %q, want prefix %q", tt.name, sortKey, tt.sortPrefix)
+		}
+	}
+
+	if isExternalCandidate("2024-05-01_10h01m00s_B_SNATCH_attempt1_Camera2.mp4") {
+		t.Errorf("replays' own files must not be treated as added videos")
+	}
+	if isExternalCandidate("notes.txt") || !isExternalCandidate("clip.MOV") {
+		t.Errorf("unexpected candidate filtering by extension")
+	}
+}
+
+func TestWatchVideoDirFollowsTheSessionsOfNewPlatforms(t *testing.T) {
+	videoDir := t.TempDir()
+	path := filepath.Join(videoDir, "A", "Session1", "warmup cam2.mp4")
+	t.Cleanup(func() {
+		externalIndexMu.Lock()
+		delete(externalIndex, path)
+		externalIndexMu.Unlock()
+	})
+	watcher, err := watchVideoDir(videoDir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The session folder is watched a moment after it is created.
+	waitFor := func(what string, done func() bool) {
+		for deadline := time.Now().Add(5 * time.Second); !done(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal(what)
+			}
+		}
+	}
+	waitFor("the session folder of a new platform folder is not watched", func() bool {
+		for _, watched := range watcher.WatchList() {
+			if watched == filepath.Dir(path) {
+				return true
+			}
+		}
+		return false
+	})
+	if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("a video added to the session of a new platform folder was not indexed", func() bool {
+		externalIndexMu.Lock()
+		defer externalIndexMu.Unlock()
+		_, indexed := externalIndex[path]
+		return indexed
+	})
+
+	for file, want := range map[string]string{
+		filepath.Join(videoDir, "Session1", "clip.mp4"):      "Session1",
+		filepath.Join(videoDir, "A", "Session1", "clip.mp4"): "A/Session1",
+	} {
+		if session, ok := watchedSession(videoDir, file); !ok || session != want {
+			t.Errorf("watchedSession(%s) = %q, %v, want %q", file, session, ok, want)
+		}
+	}
+	if _, ok := watchedSession(videoDir, filepath.Join(videoDir, "recording.mkv")); ok {
+		t.Error("a recording in progress was given a session")
+	}
+}
+
+func TestAddedVideosAreProbedOutsideTheListRequest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "warmup cam2.mp4")
+	if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldProbe := ProbeVideo
+	t.Cleanup(func() {
+		ProbeVideo = oldProbe
+		externalIndexMu.Lock()
+		delete(externalIndex, path)
+		externalIndexMu.Unlock()
+	})
+
+	var probeMu sync.Mutex
+	probeErr, probes := error(errors.New("moov atom not found")), 0
+	ProbeVideo = func(string) (time.Duration, error) {
+		probeMu.Lock()
+		defer probeMu.Unlock()
+		probes++
+		return 0, probeErr
+	}
+	// listed lists the video, then waits for the probe it started.
+	listed := func() bool {
+		_, ok := cachedExternalVideo("A", path, info)
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			externalIndexMu.Lock()
+			probing := externalProbing[path]
+			externalIndexMu.Unlock()
+			if !probing {
+				break
+			}
+		}
+		return ok
+	}
+
+	if listed() || listed() {
+		t.Fatalf("an unreadable video was listed")
+	}
+	if probes != 2 {
+		t.Fatalf("the failed probe was kept: %d probes", probes)
+	}
+	probeMu.Lock()
+	probeErr = ErrProbeUnavailable
+	probeMu.Unlock()
+	if listed() || !listed() {
+		t.Fatalf("without ffprobe, an added video must be listed on its extension once probed")
+	}
+}
+
+func TestSimulateDecisionReportsConflicts(t *testing.T) {
+	old := SimulateDecision
+	control.SetToken("secret")
+	t.Cleanup(func() {
+		SimulateDecision = old
+		control.SetToken("")
+	})
+
+	calls := 0
+	SimulateDecision = func() error {
+		calls++
+		if calls == 1 {
+			return nil
+		}
+		return fmt.Errorf("no attempt is being recorded")
+	}
+	recorder := httptest.NewRecorder()
+	handleSimulateDecision(recorder, httptest.NewRequest(http.MethodPost, "/api/simulate-decision", nil))
+	if recorder.Code != http.StatusUnauthorized || calls != 0 {
+		t.Fatalf("decision simulated without the control token: %d", recorder.Code)
+	}
+	for _, want := range []int{http.StatusOK, http.StatusConflict} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/api/simulate-decision", nil)
+		request.Header.Set(control.TokenHeader, "secret")
+		handleSimulateDecision(recorder, request)
+		if recorder.Code != want {
+			t.Fatalf("status %d, want %d: %s", recorder.Code, want, recorder.Body.String())
+		}
+	}
+	recorder = httptest.NewRecorder()
+	handleSimulateDecision(recorder, httptest.NewRequest(http.MethodGet, "/api/simulate-decision", nil))
+	if recorder.Code != http.StatusMethodNotAllowed || calls != 2 {
+		t.Fatalf("GET must not simulate a decision")
+	}
+}
+
+func TestVoiceNoteIsAttachedToItsAttempt(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(videoDir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	replay := "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4"
+	if err := os.WriteFile(filepath.Join(videoDir, "A", replay), []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	post := func(attempt, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sessions/A/notes/"+attempt, strings.NewReader("audio"))
+		req.Header.Set("Content-Type", contentType)
+		req = mux.SetURLVars(req, map[string]string{"session": "A", "attempt": attempt})
+		rec := httptest.NewRecorder()
+		handleReplayNote(rec, req)
+		return rec
+	}
+	if rec := post("../../etc", "audio/webm"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid attempt accepted: %d", rec.Code)
+	}
+	if rec := post(attemptKey(replay), "video/mp4"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("non-audio note accepted: %d", rec.Code)
+	}
+	if rec := post(attemptKey(replay), "audio/webm;codecs=opus"); rec.Code != http.StatusOK {
+		t.Fatalf("note rejected: %d %s", rec.Code, rec.Body.String())
+	}
+
+	lifts, err := buildGroupedReplayLifts("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/videos/A/2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_note.webm"
+	if len(lifts) != 1 || lifts[0].Note != want {
+		t.Fatalf("lifts = %+v, want the note %s", lifts, want)
+	}
+	if isExternalCandidate(filepath.Base(want)) {
+		t.Fatalf("a voice note must not be listed as a video")
+	}
+}
+
+func TestOperatorNotesAreKeptInTheSessionManifest(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(videoDir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	send := func(method, session, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/sessions/"+session+"/operator-notes", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"session": session})
+		rec := httptest.NewRecorder()
+		handleSessionNotes(rec, req)
+		return rec
+	}
+	if rec := send(http.MethodGet, "A", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"notes":""`) {
+		t.Fatalf("session without notes: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodPost, "B", `{"notes":"x"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("notes of a missing session accepted: %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "A", `{"notes":"Camera 2 moved after the snatch break\r\n"}`); rec.Code != http.StatusOK {
+		t.Fatalf("notes rejected: %d %s", rec.Code, rec.Body.String())
+	}
+
+	manifest, err := readSessionManifest("A")
+	if err != nil || manifest.Notes != "Camera 2 moved after the snatch break" || manifest.Updated == "" {
+		t.Fatalf("manifest = %+v, %v", manifest, err)
+	}
+	if _, err := os.Stat(filepath.Join(videoDir, "A", sessionManifestName)); err != nil {
+		t.Fatalf("manifest not in the session folder: %v", err)
+	}
+	export, err := BuildSessionExport("A")
+	if err != nil || export.Notes != manifest.Notes {
+		t.Fatalf("export notes = %q, %v", export.Notes, err)
+	}
+}
+
+func TestAnnotationIsSavedNextToItsReplay(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(videoDir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	replay := "2025-03-01_14h00m00s_John_Smith_CLEANJERK_attempt1_Camera2.mp4"
+	if err := os.WriteFile(filepath.Join(videoDir, "A", replay), []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	// A 1x1 PNG.
+	pixel := "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+	post := func(file, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sessions/A/annotations/"+file, strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"session": "A", "file": file})
+		rec := httptest.NewRecorder()
+		handleSaveAnnotation(rec, req)
+		return rec
+	}
+	if rec := post("missing.mp4", `{}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("annotation of a missing video accepted: %d", rec.Code)
+	}
+	if rec := post(replay, `{"shapes":[{"type":"angle","points":[[0,0],[1,1]]}],"png":"`+pixel+`"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("angle with two points accepted: %d", rec.Code)
+	}
+	body := `{"time":1.5,"shapes":[{"type":"angle","points":[[0.1,0.1],[0.5,0.5],[0.9,0.1]]}],"png":"` + pixel + `"}`
+	if rec := post(replay, body); rec.Code != http.StatusOK {
+		t.Fatalf("annotation rejected: %d %s", rec.Code, rec.Body.String())
+	}
+
+	saved, err := os.ReadFile(filepath.Join(videoDir, "A", annotationBase(replay)+".json"))
+	if err != nil || !strings.Contains(string(saved), `"time": 1.5`) {
+		t.Fatalf("annotation JSON = %s, %v", saved, err)
+	}
+	lifts, err := buildGroupedReplayLifts("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/videos/A/" + annotationBase(replay) + ".png"; len(lifts) != 1 || lifts[0].Replays[0].Annotation != want {
+		t.Fatalf("lifts = %+v, want the annotation %s", lifts, want)
+	}
+}
+
+func TestSlowMotionCopiesAreListedWithTheirReplay(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(videoDir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	replay := "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4"
+	for _, name := range []string{replay, strings.TrimSuffix(replay, ".mp4") + "_slow25.mp4", strings.TrimSuffix(replay, ".mp4") + "_slow50.mp4"} {
+		if err := os.WriteFile(filepath.Join(videoDir, "A", name), []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	lifts, err := buildGroupedReplayLifts("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lifts) != 1 || len(lifts[0].Replays) != 1 {
+		t.Fatalf("slow-motion copies listed as replays: %+v", lifts)
+	}
+	slow := lifts[0].Replays[0].SlowMotion
+	if len(slow) != 2 || slow[0].Percent != 50 || slow[1].URL != "/videos/A/"+strings.TrimSuffix(replay, ".mp4")+"_slow25.mp4" {
+		t.Fatalf("SlowMotion = %+v, want 50%% then 25%%", slow)
+	}
+	if isExternalCandidate(strings.TrimSuffix(replay, ".mp4") + "_slow50.mp4") {
+		t.Fatalf("a slow-motion copy would be listed as an operator video")
+	}
+}
+
+func TestInsetCopyIsListedWithItsReplay(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(videoDir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	replay := "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4"
+	for _, name := range []string{replay, InsetName(replay)} {
+		if err := os.WriteFile(filepath.Join(videoDir, "A", name), []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	lifts, err := buildGroupedReplayLifts("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lifts) != 1 || len(lifts[0].Replays) != 1 {
+		t.Fatalf("inset copy listed as a replay: %+v", lifts)
+	}
+	if want := "/videos/A/" + strings.TrimSuffix(replay, ".mp4") + "_inset.mp4"; lifts[0].Replays[0].Inset != want {
+		t.Fatalf("Inset = %q, want %q", lifts[0].Replays[0].Inset, want)
+	}
+	if isExternalCandidate(InsetName(replay)) {
+		t.Fatalf("an inset copy would be listed as an operator video")
+	}
+}
+
+func TestProxiesAreListedAndServedForTheirQuality(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(videoDir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	replay := "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4"
+	base := strings.TrimSuffix(replay, ".mp4")
+	for name, content := range map[string]string{replay: "full", base + "_480p.mp4": "480", base + "_720p.mp4": "720"} {
+		if err := os.WriteFile(filepath.Join(videoDir, "A", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	lifts, err := buildGroupedReplayLifts("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lifts) != 1 || len(lifts[0].Replays) != 1 {
+		t.Fatalf("proxies listed as replays: %+v", lifts)
+	}
+	proxies := lifts[0].Replays[0].Proxies
+	if len(proxies) != 2 || proxies[0].Quality != "720p" || proxies[1].URL != "/videos/A/"+base+"_480p.mp4" {
+		t.Fatalf("Proxies = %+v, want 720p then 480p", proxies)
+	}
+	if isExternalCandidate(base + "_720p.mp4") {
+		t.Fatalf("a proxy would be listed as an operator video")
+	}
+
+	handler := http.StripPrefix("/videos/", withQuality(http.FileServer(storage.FileSystem)))
+	for query, want := range map[string]string{"?quality=720p": "720", "?quality=360p": "full", "?quality=../x": "full", "": "full"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/videos/A/"+replay+query, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Fatalf("%s served %d %q, want %q", query, rec.Code, rec.Body.String(), want)
+		}
+	}
+}
+
+func TestMultiAngleVideoIsListedWithItsAttempt(t *testing.T) {
+	videoDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(videoDir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	replay := "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4"
+	combined := MultiAngleName(replay)
+	if combined != "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_cameras.mp4" {
+		t.Fatalf("MultiAngleName() = %q", combined)
+	}
+	for _, name := range []string{replay, combined} {
+		if err := os.WriteFile(filepath.Join(videoDir, "A", name), []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	lifts, err := buildGroupedReplayLifts("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lifts) != 1 || lifts[0].MultiAngle != "/videos/A/"+combined || len(lifts[0].Replays) != 1 {
+		t.Fatalf("lifts = %+v, want the combined video with the attempt", lifts)
+	}
+	if isExternalCandidate(combined) || sidecarOwner(combined) != attemptKey(replay) {
+		t.Fatalf("the combined video must follow its attempt, not be listed on its own")
+	}
+	if MultiAngleName("clip.mp4") != "" {
+		t.Fatalf("a file that is not a replay has no combined video")
+	}
+}
@@ -0,0 +1,208 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/control"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// The manifest of a session is kept in its folder as session.json. It holds
+// what the operator wrote about the session (venue conditions, camera
+// changes, incidents) to explain anomalies found later in the footage.
+const (
+	sessionManifestName = "session.json"
+	maxSessionNotes     = 10000 // characters
+)
+
+// SessionManifest is the content of session.json.
+type SessionManifest struct {
+	Notes   string `json:"notes"`
+	Keep    bool   `json:"keep,omitempty"` // never deleted by the video cleanup
+	Updated string `json:"updated,omitempty"`
+}
+
+// readSessionManifest returns the manifest of a session, empty if the session
+// has none yet.
+func readSessionManifest(session string) (SessionManifest, error) {
+	var manifest SessionManifest
+	data, err := storage.ReadFile(storage.Join(session, sessionManifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid %s: %w", sessionManifestName, err)
+	}
+	return manifest, nil
+}
+
+// saveSessionNotes replaces the notes of a session in its manifest.
+func saveSessionNotes(session, notes string) (SessionManifest, error) {
+	notes = strings.TrimSpace(strings.ReplaceAll(notes, "\r\n", "\n"))
+	if utf8.RuneCountInString(notes) > maxSessionNotes {
+		return SessionManifest{}, fmt.Errorf("the notes are longer than %d characters", maxSessionNotes)
+	}
+	store := storage.Current()
+	if _, err := store.Stat(session); err != nil {
+		return SessionManifest{}, err
+	}
+	manifest, err := readSessionManifest(session)
+	if err != nil {
+		logging.WarningLogger.Printf("Replacing unreadable manifest of session %s: %v", session, err)
+	}
+	manifest.Notes = notes
+	return manifest, writeSessionManifest(store, session, &manifest)
+}
+
+// saveSessionKeep marks a session to be kept by the video cleanup, or not.
+func saveSessionKeep(session string, keep bool) (SessionManifest, error) {
+	store := storage.Current()
+	if _, err := store.Stat(session); err != nil {
+		return SessionManifest{}, err
+	}
+	manifest, err := readSessionManifest(session)
+	if err != nil {
+		logging.WarningLogger.Printf("Replacing unreadable manifest of session %s: %v", session, err)
+	}
+	manifest.Keep = keep
+	return manifest, writeSessionManifest(store, session, &manifest)
+}
+
+// writeSessionManifest stamps and stores the manifest of a session.
+func writeSessionManifest(store storage.Backend, session string, manifest *SessionManifest) error {
+	manifest.Updated = time.Now().Format(time.RFC3339)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(storage.Join(session, sessionManifestName), data)
+}
+
+// handleSessionNotes reads or replaces the operator notes of a session:
+// GET or POST /api/sessions/{session}/operator-notes, with {"notes": "..."}.
+func handleSessionNotes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	session, err := sanitizeReplaySessionID(mux.Vars(r)["session"])
+	if err != nil {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+
+	var manifest SessionManifest
+	switch r.Method {
+	case http.MethodGet:
+		if _, err = storage.Current().Stat(session); err == nil {
+			manifest, err = readSessionManifest(session)
+		}
+	case http.MethodPost:
+		if config.Viewer {
+			http.Error(w, "A viewer does not change the operator notes", http.StatusServiceUnavailable)
+			return
+		}
+		var request SessionManifest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 8*maxSessionNotes)).Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid notes: %v", err), http.StatusBadRequest)
+			return
+		}
+		manifest, err = saveSessionNotes(session, request.Notes)
+		if err == nil {
+			logging.InfoLogger.Printf("Saved operator notes of session %s", session)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if os.IsNotExist(err) {
+		http.Error(w, "Replay session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to access the operator notes of session %s: %v", session, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// handleSessionKeep reads or sets the protection of a session from the video
+// cleanup: GET or POST /api/sessions/{session}/keep, with {"keep": true}.
+// Setting it takes the control token, and is refused by a viewer.
+func handleSessionKeep(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+control.TokenHeader)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	session, err := sanitizeReplaySessionID(mux.Vars(r)["session"])
+	if err != nil {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPost {
+		if config.Viewer {
+			http.Error(w, "A viewer does not clean up the videos", http.StatusServiceUnavailable)
+			return
+		}
+		if !controlAllowed(w, r) {
+			return
+		}
+	}
+
+	var manifest SessionManifest
+	switch r.Method {
+	case http.MethodGet:
+		if _, err = storage.Current().Stat(session); err == nil {
+			manifest, err = readSessionManifest(session)
+		}
+	case http.MethodPost:
+		var request struct {
+			Keep bool `json:"keep"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		manifest, err = saveSessionKeep(session, request.Keep)
+		if err == nil && request.Keep {
+			logging.InfoLogger.Printf("Session %s is kept by the video cleanup", session)
+		} else if err == nil {
+			logging.InfoLogger.Printf("Session %s may be deleted by the video cleanup", session)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if os.IsNotExist(err) {
+		http.Error(w, "Replay session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to access the manifest of session %s: %v", session, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
@@ -0,0 +1,49 @@
+package httpServer
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// slowMotionPattern matches the slow-motion copies of a replay, named after
+// it with the speed in percent: <replay>_slow50.mp4.
+var slowMotionPattern = regexp.MustCompile(`^(.+)_slow(\d+)\.mp4$`)
+
+// SlowMotionLink is a slow-motion copy of a replay. URL is relative to the
+// session folder in the video list, absolute in the API.
+type SlowMotionLink struct {
+	Percent int    `json:"percent"`
+	URL     string `json:"url"`
+}
+
+func isSlowMotionFile(name string) bool {
+	return slowMotionPattern.MatchString(name)
+}
+
+// sessionSlowMotion returns the slow-motion copies of a session folder by
+// replay name without its extension, fastest first, with urlPrefix before
+// their names.
+func sessionSlowMotion(entries []os.DirEntry, urlPrefix string) map[string][]SlowMotionLink {
+	copies := make(map[string][]SlowMotionLink)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := slowMotionPattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		percent, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+		replay := matches[1]
+		copies[replay] = append(copies[replay], SlowMotionLink{Percent: percent, URL: urlPrefix + entry.Name()})
+	}
+	for _, links := range copies {
+		sort.Slice(links, func(i, j int) bool { return links[i].Percent > links[j].Percent })
+	}
+	return copies
+}
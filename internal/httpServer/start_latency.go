@@ -0,0 +1,71 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+// StartLatency is the time a camera took between the MQTT start message and
+// its first encoded frame, for the last attempt it recorded.
+type StartLatency struct {
+	Camera     int   `json:"camera"`
+	LatencyMs  int64 `json:"latencyMs"`
+	Slow       bool  `json:"slow,omitempty"`
+	MeasuredAt int64 `json:"measuredAt"` // Unix ms
+}
+
+// ServerStatus is the body of GET /status.
+type ServerStatus struct {
+	Status             StatusMessage  `json:"status"`
+	StartLatencyWarnMs int64          `json:"startLatencyWarnMs"`
+	StartLatencies     []StartLatency `json:"startLatencies"`
+}
+
+var (
+	startLatencyMu sync.Mutex
+	startLatencies = make(map[int]StartLatency)
+)
+
+// SetStartLatency records the start latency of a camera for /status.
+func SetStartLatency(latency StartLatency) {
+	startLatencyMu.Lock()
+	startLatencies[latency.Camera] = latency
+	startLatencyMu.Unlock()
+}
+
+func snapshotStartLatencies() []StartLatency {
+	startLatencyMu.Lock()
+	latencies := make([]StartLatency, 0, len(startLatencies))
+	for _, latency := range startLatencies {
+		latencies = append(latencies, latency)
+	}
+	startLatencyMu.Unlock()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i].Camera < latencies[j].Camera })
+	return latencies
+}
+
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	setReplayAPIHeaders(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	mu.Lock()
+	status := lastStatusMessage
+	mu.Unlock()
+	status.ServerTime = nowMillis()
+
+	response := ServerStatus{
+		Status:             status,
+		StartLatencyWarnMs: config.GetStartLatencyWarning().Milliseconds(),
+		StartLatencies:     snapshotStartLatencies(),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode status", http.StatusInternalServerError)
+	}
+}
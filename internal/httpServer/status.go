@@ -2,6 +2,7 @@ package httpServer
 
 import (
 	"strings"
+	"time"
 
 	"github.com/owlcms/replays/internal/logging"
 	"github.com/owlcms/replays/internal/state"
@@ -28,6 +29,53 @@ type StatusMessage struct {
 	// durationMs probed by ffprobe). Lets clients act on a freshly-published
 	// clip without a follow-up GET /api/replay-state round-trip.
 	Cameras []ReplayCameraState `json:"cameras,omitempty"`
+	// MaintenanceUntil is the end of maintenance mode (HH:MM), empty when
+	// attempts are recorded.
+	MaintenanceUntil string `json:"maintenanceUntil,omitempty"`
+	// DiskAlert warns that the disk of the videos is (almost) full, empty
+	// when there is enough space.
+	DiskAlert string `json:"diskAlert,omitempty"`
+	// FfmpegLog is "next" when detailed ffmpeg logs are asked for the next
+	// attempt, "attempt" while that attempt is logged.
+	FfmpegLog string `json:"ffmpegLog,omitempty"`
+	// RecordingStartedAt (Unix ms) and RecordingCameras describe the attempt
+	// being recorded; they are only set on Recording messages. ServerTime lets
+	// pages compute the elapsed time with their own clock.
+	RecordingStartedAt int64             `json:"recordingStartedAt,omitempty"`
+	RecordingCameras   []RecordingCamera `json:"recordingCameras,omitempty"`
+	// TrimJobs (one per camera) and TrimQueued, the number of trims waiting
+	// for a worker, describe the attempt being trimmed; they are only set on
+	// Trimming messages.
+	TrimJobs   []TrimJob `json:"trimJobs,omitempty"`
+	TrimQueued int       `json:"trimQueued,omitempty"`
+	ServerTime int64     `json:"serverTime"`
+}
+
+// Camera states of the attempt being recorded.
+const (
+	CameraRecording = "recording"
+	CameraStalled   = "stalled"
+	CameraFailed    = "failed"
+)
+
+// RecordingCamera is the state of one camera of the attempt being recorded.
+type RecordingCamera struct {
+	Camera int    `json:"camera"`
+	Status string `json:"status"`
+}
+
+// Trim job states of the attempt being trimmed.
+const (
+	TrimQueued  = "queued"
+	TrimRunning = "trimming"
+	TrimDone    = "done"
+	TrimFailed  = "failed"
+)
+
+// TrimJob is the state of the trim of one camera of the attempt.
+type TrimJob struct {
+	Camera int    `json:"camera"`
+	Status string `json:"status"`
 }
 
 type StatusAttemptDetails struct {
@@ -35,10 +83,18 @@ type StatusAttemptDetails struct {
 	AthleteName   string
 	LiftType      string
 	AttemptNumber int
+	Weight        int   // requested weight in kg, 0 if unknown
+	StartedAt     int64 // Unix ms, while recording
+	Cameras       []RecordingCamera
+	TrimJobs      []TrimJob // while trimming
+	TrimQueued    int
 }
 
+// StatusChan receives the statuses for the window. It keeps the latest ten
+// when the window falls behind.
+var StatusChan <-chan StatusMessage = statuses.subscribe(10)
+
 var (
-	StatusChan          = make(chan StatusMessage, 10)
 	statusMsg           string
 	statusCode          StatusCode
 	lastStatusMessage   StatusMessage
@@ -67,49 +123,88 @@ func buildStatusMessageWithDetails(code StatusCode, text string, details StatusA
 		attemptNumber = state.CurrentAttempt
 	}
 
-	return StatusMessage{
-		Code:          code,
-		Text:          text,
-		Session:       session,
-		AthleteName:   athleteName,
-		LiftType:      liftType,
-		AttemptNumber: attemptNumber,
+	msg := StatusMessage{
+		Code:             code,
+		Text:             text,
+		Session:          session,
+		AthleteName:      athleteName,
+		LiftType:         liftType,
+		AttemptNumber:    attemptNumber,
+		MaintenanceUntil: maintenanceUntilText(),
+		DiskAlert:        diskAlertText(),
+		FfmpegLog:        ffmpegLogText(),
+		ServerTime:       nowMillis(),
+	}
+	if code == Recording {
+		msg.RecordingStartedAt = details.StartedAt
+		msg.RecordingCameras = append([]RecordingCamera(nil), details.Cameras...)
 	}
+	if code == Trimming {
+		msg.TrimJobs = append([]TrimJob(nil), details.TrimJobs...)
+		msg.TrimQueued = details.TrimQueued
+	}
+	return msg
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func maintenanceUntilText() string {
+	if until, active := state.MaintenanceUntil(); active {
+		return until.Format("15:04")
+	}
+	return ""
+}
+
+func diskAlertText() string {
+	_, alert := state.DiskSpace()
+	return alert
+}
+
+// RefreshStatus sends the last status again to the web pages, with the
+// current maintenance and disk space alerts and ffmpeg log state, so that
+// the pages follow them in the middle of an attempt.
+func RefreshStatus() {
+	mu.Lock()
+	defer mu.Unlock()
+	lastStatusMessage.MaintenanceUntil = maintenanceUntilText()
+	lastStatusMessage.DiskAlert = diskAlertText()
+	lastStatusMessage.FfmpegLog = ffmpegLogText()
+	lastStatusMessage.ServerTime = nowMillis()
+	broadcastToClients(lastStatusMessage)
 }
 
-// SendStatus sends a status update to all clients through the broadcast channel
-// and updates the Fyne UI through StatusChan
+// SendStatus sends a status update to all clients and to the Fyne UI through
+// StatusChan, without waiting for any of them
 func SendStatus(code StatusCode, text string) {
 	SendStatusWithDetails(code, text, StatusAttemptDetails{})
 }
 
 // SendStatusWithDetails sends a status update with explicit attempt metadata.
 func SendStatusWithDetails(code StatusCode, text string, details StatusAttemptDetails) {
+	original := text
 	// Simplify the "Videos ready" message for web display
-	VideoReadyReloading = false
-	if code == Ready && strings.Contains(text, "Videos ready") {
+	reloading := code == Ready && strings.Contains(text, "Videos ready")
+	if reloading {
 		text = "Reloading..."
-		VideoReadyReloading = true
 	}
 	msg := buildStatusMessageWithDetails(code, text, details)
 	if code == Ready {
 		msg.Cameras = snapshotPublishedReplays()
 	}
 	mu.Lock()
+	VideoReadyReloading = reloading
+	notifyWebhook(msg, original)
 	statusMsg = text
 	statusCode = code
 	lastStatusMessage = msg
-	for client := range clients {
+	if len(clients) > 0 {
 		logging.InfoLogger.Printf("Sending status update: %s", text)
-		if err := client.WriteJSON(msg); err != nil {
-			logging.ErrorLogger.Printf("Failed to send status: %v", err)
-			client.Close()
-			delete(clients, client)
-			continue
-		}
 	}
+	broadcastToClients(msg)
 	mu.Unlock()
 
 	// Also send to Fyne UI
-	StatusChan <- msg
+	statuses.publish(msg)
 }
@@ -0,0 +1,55 @@
+package httpServer
+
+import (
+	"sync"
+
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// statusBus hands the status messages to their readers without ever waiting
+// for them: SendStatus is called by the recording and trimming goroutines,
+// which must not stall behind a busy window. Each reader has a bounded
+// buffer; when it is full, the oldest message is dropped, since a reader that
+// catches up only needs the latest statuses.
+type statusBus struct {
+	mu      sync.Mutex
+	readers []chan StatusMessage
+}
+
+var statuses statusBus
+
+// subscribe returns a new reader of the bus, buffering size messages.
+func (b *statusBus) subscribe(size int) chan StatusMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	reader := make(chan StatusMessage, size)
+	b.readers = append(b.readers, reader)
+	return reader
+}
+
+// publish gives msg to every reader, dropping their oldest message when
+// their buffer is full.
+func (b *statusBus) publish(msg StatusMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, reader := range b.readers {
+		sendDropOldest(reader, msg)
+	}
+}
+
+// sendDropOldest sends msg on ch, first removing the oldest message if ch is
+// full. Only one goroutine may send on ch at a time.
+func sendDropOldest(ch chan StatusMessage, msg StatusMessage) {
+	for {
+		select {
+		case ch <- msg:
+			return
+		default:
+		}
+		select {
+		case dropped := <-ch:
+			logging.Trace("Status %q dropped for a slow reader", dropped.Text)
+		default:
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package httpServer
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/control"
 	"github.com/owlcms/replays/internal/state"
 )
 
@@ -125,3 +132,188 @@ func TestVideosReadyStatusKeepsAttemptMetadata(t *testing.T) {
 		t.Fatalf("unexpected attempt %d", lastStatusMessage.AttemptNumber)
 	}
 }
+
+func TestMaintenanceIsReportedInStatusMessages(t *testing.T) {
+	resetStatusForTest(t)
+	control.SetToken("secret")
+	t.Cleanup(func() {
+		state.StopMaintenance()
+		control.SetToken("")
+	})
+
+	token := "secret"
+	post := func(action, query string) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/api/maintenance/"+action+query, nil)
+		request.Header.Set(control.TokenHeader, token)
+		handleMaintenance(recorder, mux.SetURLVars(request, map[string]string{"action": action}))
+		return recorder.Code
+	}
+
+	token = ""
+	if code := post("start", ""); code != http.StatusUnauthorized {
+		t.Fatalf("start without the control token returned status %d", code)
+	}
+	token = "secret"
+	if code := post("start", "?minutes=soon"); code != http.StatusBadRequest {
+		t.Fatalf("invalid minutes accepted with status %d", code)
+	}
+	if code := post("start", "?minutes=5"); code != http.StatusOK {
+		t.Fatalf("start returned status %d", code)
+	}
+	until, active := state.MaintenanceUntil()
+	if !active {
+		t.Fatal("maintenance mode not started")
+	}
+	SendStatus(Ready, "Ready")
+	if lastStatusMessage.MaintenanceUntil != until.Format("15:04") {
+		t.Fatalf("status reports maintenance until %q", lastStatusMessage.MaintenanceUntil)
+	}
+
+	if code := post("stop", ""); code != http.StatusOK {
+		t.Fatalf("stop returned status %d", code)
+	}
+	SendStatus(Ready, "Ready")
+	if lastStatusMessage.MaintenanceUntil != "" {
+		t.Fatalf("status still reports maintenance until %q", lastStatusMessage.MaintenanceUntil)
+	}
+}
+
+func TestRecordingDetailsOnlyAccompanyRecordingMessages(t *testing.T) {
+	resetStatusForTest(t)
+
+	details := StatusAttemptDetails{
+		AthleteName:   "SHEPPARD, Ryan",
+		LiftType:      "SNATCH",
+		AttemptNumber: 2,
+		StartedAt:     1700000000000,
+		Cameras: []RecordingCamera{
+			{Camera: 1, Status: CameraRecording},
+			{Camera: 2, Status: CameraFailed},
+		},
+	}
+	SendStatusWithDetails(Recording, "Recording", details)
+	if lastStatusMessage.RecordingStartedAt != details.StartedAt || len(lastStatusMessage.RecordingCameras) != 2 {
+		t.Fatalf("recording message lacks the recording details: %+v", lastStatusMessage)
+	}
+	if lastStatusMessage.ServerTime == 0 {
+		t.Fatal("status message lacks the server time")
+	}
+
+	SendStatusWithDetails(Trimming, "Trimming", details)
+	if lastStatusMessage.RecordingStartedAt != 0 || lastStatusMessage.RecordingCameras != nil {
+		t.Fatalf("trimming message carries recording details: %+v", lastStatusMessage)
+	}
+}
+
+func TestStatusChangesArePostedToTheWebhook(t *testing.T) {
+	resetStatusForTest(t)
+	received := make(chan WebhookPayload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+	oldURL := config.WebhookURL
+	config.WebhookURL = server.URL
+	webhookCode = Ready
+	t.Cleanup(func() { config.WebhookURL = oldURL })
+
+	state.CurrentAthlete = "John Smith"
+	state.CurrentLiftType = "SNATCH"
+	state.CurrentAttempt = 2
+	SendStatus(Recording, "Recording: John Smith")
+	SendStatus(Recording, "Recording: John Smith (2s)")
+	SendStatus(Trimming, "Trimming videos...")
+	SendStatus(Ready, "Error: Failed to trim video for Camera 2")
+	SendStatus(Ready, "Videos ready")
+
+	var events []string
+	for len(events) < 4 {
+		select {
+		case payload := <-received:
+			if payload.Text != payload.Content || payload.Status.Code != codeOfWebhookEvent(payload.Event) {
+				t.Fatalf("inconsistent payload %+v", payload)
+			}
+			events = append(events, payload.Event)
+			if payload.Event == WebhookReady && payload.Text != "Videos ready" {
+				t.Fatalf("ready posted as %q, want the message before it is shortened", payload.Text)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("webhook received %v", events)
+		}
+	}
+	want := []string{WebhookRecording, WebhookTrimming, WebhookError, WebhookReady}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("webhook events = %v, want %v", events, want)
+		}
+	}
+}
+
+// codeOfWebhookEvent is the status code that goes with a webhook event in
+// TestStatusChangesArePostedToTheWebhook.
+func codeOfWebhookEvent(event string) StatusCode {
+	switch event {
+	case WebhookRecording:
+		return Recording
+	case WebhookTrimming:
+		return Trimming
+	}
+	return Ready
+}
+
+func TestStatusBusDropsTheOldestForASlowReader(t *testing.T) {
+	var bus statusBus
+	reader := bus.subscribe(2)
+
+	done := make(chan struct{})
+	go func() {
+		for _, text := range []string{"a", "b", "c", "d"} {
+			bus.publish(StatusMessage{Text: text})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish waited for the reader")
+	}
+
+	if first, second := <-reader, <-reader; first.Text != "c" || second.Text != "d" {
+		t.Fatalf("reader got %q and %q, want the latest two", first.Text, second.Text)
+	}
+}
+
+func TestCalibrationTakesTheControlToken(t *testing.T) {
+	control.SetToken("secret")
+	calibrated := 0
+	oldCalibrate := CalibrateCameras
+	CalibrateCameras = func() (map[int]int, error) {
+		calibrated++
+		return map[int]int{1: 0, 2: 40}, nil
+	}
+	t.Cleanup(func() {
+		control.SetToken("")
+		CalibrateCameras = oldCalibrate
+	})
+
+	post := func(token string) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/api/calibration/start", nil)
+		if token != "" {
+			request.Header.Set(control.TokenHeader, token)
+		}
+		handleCalibrate(recorder, request)
+		return recorder.Code
+	}
+	if code := post(""); code != http.StatusUnauthorized || calibrated != 0 {
+		t.Fatalf("calibration without the control token: status %d, %d calibrations", code, calibrated)
+	}
+	if code := post("secret"); code != http.StatusOK || calibrated != 1 {
+		t.Fatalf("calibration with the control token: status %d, %d calibrations", code, calibrated)
+	}
+}
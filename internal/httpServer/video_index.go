@@ -0,0 +1,206 @@
+package httpServer
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// VideoIndexReport is what RebuildVideoIndex found in the video directory.
+// Files are given as session/name.
+type VideoIndexReport struct {
+	Sessions   int
+	Replays    int
+	Added      int      // readable videos added by the operator
+	Unreadable []string // videos ffprobe cannot read
+	Moved      []string // notes, annotations and slow-motion copies moved next to their replay
+	Orphans    []string // notes, annotations and slow-motion copies whose replay is nowhere
+}
+
+// Summary describes the report for the operator.
+func (r VideoIndexReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d sessions, %d replays, %d added videos.\n", r.Sessions, r.Replays, r.Added)
+	for _, part := range []struct {
+		title string
+		files []string
+	}{
+		{"Unreadable videos", r.Unreadable},
+		{"Moved next to their replay", r.Moved},
+		{"Replay not found", r.Orphans},
+	} {
+		if len(part.files) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", part.title, len(part.files))
+		for _, file := range part.files {
+			fmt.Fprintf(&b, "  %s\n", file)
+		}
+	}
+	return b.String()
+}
+
+// sidecar is a file of a session folder that belongs to a video: found under
+// the video name (annotations, slow-motion copies, proxies, insets) or the
+// attempt (notes).
+type sidecar struct {
+	session string
+	name    string
+	owner   string // video name without extension, or attempt key
+}
+
+// sidecarOwner returns the video or attempt a file belongs to, "" when the
+// file is not a sidecar.
+func sidecarOwner(name string) string {
+	if matches := slowMotionPattern.FindStringSubmatch(name); matches != nil {
+		return matches[1]
+	}
+	if matches := proxyPattern.FindStringSubmatch(name); matches != nil {
+		return matches[1]
+	}
+	if replay := insetOwner(name); replay != "" {
+		return replay
+	}
+	if isNoteFile(name) {
+		return strings.TrimSuffix(strings.TrimSuffix(name, filepath.Ext(name)), noteSuffix)
+	}
+	if key := multiAngleKey(name); key != "" {
+		return key
+	}
+	if ext := filepath.Ext(name); ext == ".json" || ext == ".png" {
+		if base := strings.TrimSuffix(name, ext); strings.HasSuffix(base, annotationSuffix) {
+			return strings.TrimSuffix(base, annotationSuffix)
+		}
+	}
+	return ""
+}
+
+// RebuildVideoIndex rescans the video directory as if replays had just
+// started: the added videos are probed again, every replay is checked with
+// ffprobe, and the notes, annotations and slow-motion copies left behind when
+// replays were moved by hand are moved next to them. The browsers reload
+// their lists.
+func RebuildVideoIndex() (VideoIndexReport, error) {
+	var report VideoIndexReport
+	store := storage.Current()
+	folders, err := sessionFolders(store)
+	if err != nil {
+		return report, err
+	}
+
+	externalIndexMu.Lock()
+	externalIndex = make(map[string]externalVideo)
+	externalIndexMu.Unlock()
+
+	owners := make(map[string]map[string]bool) // sessions of each video and attempt
+	addOwner := func(owner, session string) {
+		if owners[owner] == nil {
+			owners[owner] = make(map[string]bool)
+		}
+		owners[owner][session] = true
+	}
+	var sidecars []sidecar
+	var sessions []string
+	for _, session := range folders {
+		files, err := store.ReadDir(session)
+		if err != nil {
+			logging.WarningLogger.Printf("Rebuilding the video index: %v", err)
+			continue
+		}
+		sessions = append(sessions, session)
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() {
+				continue
+			}
+			base := strings.TrimSuffix(name, filepath.Ext(name))
+			if _, ok := parseReplayFilename(session, name); ok {
+				report.Replays++
+				addOwner(base, session)
+				if key := attemptKey(name); key != "" {
+					addOwner(key, session)
+				}
+				if localPath, ok := store.LocalPath(storage.Join(session, name)); ok && ProbeVideo != nil {
+					if _, err := ProbeVideo(localPath); err != nil && !errors.Is(err, ErrProbeUnavailable) {
+						report.Unreadable = append(report.Unreadable, storage.Join(session, name))
+					}
+				}
+				continue
+			}
+			if owner := sidecarOwner(name); owner != "" {
+				sidecars = append(sidecars, sidecar{session: session, name: name, owner: owner})
+				continue
+			}
+			if !isExternalCandidate(name) {
+				continue
+			}
+			info, err := file.Info()
+			localPath, ok := store.LocalPath(storage.Join(session, name))
+			if err != nil || !ok {
+				continue
+			}
+			if _, ok := indexExternalVideo(localPath, info); ok {
+				report.Added++
+				addOwner(base, session)
+			} else {
+				report.Unreadable = append(report.Unreadable, storage.Join(session, name))
+			}
+		}
+	}
+	report.Sessions = len(sessions)
+
+	for _, file := range sidecars {
+		found := owners[file.owner]
+		if found[file.session] {
+			continue
+		}
+		source := storage.Join(file.session, file.name)
+		if len(found) != 1 {
+			// Gone, or in several sessions: the operator decides.
+			report.Orphans = append(report.Orphans, source)
+			continue
+		}
+		for session := range found {
+			target := storage.Join(session, file.name)
+			if err := moveStoredFile(source, target); err != nil {
+				logging.WarningLogger.Printf("Failed to move %s to %s: %v", source, target, err)
+				report.Orphans = append(report.Orphans, source)
+				continue
+			}
+			report.Moved = append(report.Moved, source+" -> "+session)
+		}
+	}
+	sort.Strings(report.Unreadable)
+	sort.Strings(report.Orphans)
+
+	logging.InfoLogger.Printf("Video index rebuilt: %d sessions, %d replays, %d added videos, %d unreadable, %d moved, %d orphaned",
+		report.Sessions, report.Replays, report.Added, len(report.Unreadable), len(report.Moved), len(report.Orphans))
+	for _, session := range sessions {
+		broadcastListChanged(session)
+	}
+	return report, nil
+}
+
+// moveStoredFile moves a stored file, keeping a file already at target.
+func moveStoredFile(source, target string) error {
+	store := storage.Current()
+	if _, err := store.Stat(target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	}
+	if localPath, ok := store.LocalPath(source); ok {
+		return store.Import(localPath, target)
+	}
+	data, err := storage.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	if err := store.WriteFile(target, data); err != nil {
+		return err
+	}
+	return store.Remove(source)
+}
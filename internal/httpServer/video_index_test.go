@@ -0,0 +1,61 @@
+package httpServer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestRebuildVideoIndexMovesSidecarsNextToTheirReplay(t *testing.T) {
+	videoDir := t.TempDir()
+	const replay = "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1"
+	files := map[string]string{
+		"M2/" + replay + ".mp4":                                                    "video",
+		"M2/" + replay + "_Camera2.mp4":                                            "", // not a replay name, not a video either
+		"M1/" + replay + "_annotation.json":                                        "{}",
+		"M1/" + replay + "_slow50.mp4":                                             "slow",
+		"M1/2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_note.webm":             "note",
+		"M1/2025-03-01_15h00m00s_Gone_Away_SNATCH_attempt1_Camera1_annotation.png": "png",
+		"M1/broken.mp4": "not a video",
+	}
+	for name, content := range files {
+		path := filepath.Join(videoDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldVideoDir, oldProbe := config.GetVideoDir(), ProbeVideo
+	config.SetVideoDir(videoDir)
+	ProbeVideo = func(path string) (time.Duration, error) {
+		if data, _ := os.ReadFile(path); string(data) != "video" {
+			return 0, errors.New("invalid data found when processing input")
+		}
+		return time.Second, nil
+	}
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir); ProbeVideo = oldProbe })
+
+	report, err := RebuildVideoIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sessions != 2 || report.Replays != 1 || len(report.Moved) != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	for _, name := range []string{replay + "_annotation.json", replay + "_slow50.mp4", "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_note.webm"} {
+		if _, err := os.Stat(filepath.Join(videoDir, "M2", name)); err != nil {
+			t.Errorf("%s not moved next to its replay: %v", name, err)
+		}
+	}
+	if len(report.Orphans) != 1 || !strings.Contains(report.Orphans[0], "Gone_Away") {
+		t.Errorf("orphans = %v, want the annotation of the missing replay", report.Orphans)
+	}
+	if strings.Join(report.Unreadable, " ") != "M1/broken.mp4 M2/"+replay+"_Camera2.mp4" {
+		t.Errorf("unreadable = %v", report.Unreadable)
+	}
+}
@@ -0,0 +1,146 @@
+package httpServer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/config/replays"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// Events posted to the webhook.
+const (
+	WebhookRecording = "recording" // recording started
+	WebhookTrimming  = "trimming"  // recording stopped, replays being trimmed
+	WebhookReady     = "ready"     // replays ready, or back to waiting
+	WebhookError     = "error"
+)
+
+const (
+	webhookTimeout = 5 * time.Second
+	// webhookQueueSize bounds the events waiting for a slow webhook; later
+	// ones are dropped rather than holding up the recording.
+	webhookQueueSize = 32
+)
+
+// WebhookPayload is the JSON posted to the webhook. Text and Content carry
+// the same message, where Slack and Discord incoming webhooks expect it.
+type WebhookPayload struct {
+	Event    string        `json:"event"`
+	Text     string        `json:"text"`
+	Content  string        `json:"content"`
+	Platform string        `json:"platform,omitempty"`
+	Time     string        `json:"time"`
+	Status   StatusMessage `json:"status"`
+}
+
+var (
+	webhookOnce  sync.Once
+	webhookQueue chan WebhookPayload
+	// webhookCode is the state last posted; Error after an error, so that
+	// the next state is posted even when it has the same code as before.
+	webhookCode StatusCode
+)
+
+// webhookEvent returns the event of a status change, or "" when the change
+// is not posted (progress of the same state).
+func webhookEvent(previous StatusCode, msg StatusMessage) string {
+	if msg.Code == Error || strings.HasPrefix(msg.Text, "Error") {
+		return WebhookError
+	}
+	if msg.Code == previous {
+		return ""
+	}
+	switch msg.Code {
+	case Recording:
+		return WebhookRecording
+	case Trimming:
+		return WebhookTrimming
+	case Ready:
+		return WebhookReady
+	}
+	return ""
+}
+
+// notifyWebhook queues a status change for the webhook, if one is configured.
+// text is the message as sent by the program, before it is shortened for the
+// web pages. Called with mu held.
+func notifyWebhook(msg StatusMessage, text string) {
+	url := config.WebhookURL
+	event := webhookEvent(webhookCode, msg)
+	if url == "" || event == "" {
+		return
+	}
+	webhookCode = msg.Code
+	if event == WebhookError {
+		webhookCode = Error
+	}
+	payload := WebhookPayload{
+		Event:  event,
+		Time:   time.UnixMilli(msg.ServerTime).Format(time.RFC3339),
+		Status: msg,
+	}
+	if cfg := replays.GetCurrentConfig(); cfg != nil {
+		payload.Platform = cfg.Platform
+	}
+	payload.Text = webhookText(payload.Platform, msg, text)
+	payload.Content = payload.Text
+
+	webhookOnce.Do(func() {
+		webhookQueue = make(chan WebhookPayload, webhookQueueSize)
+		go postWebhooks()
+	})
+	select {
+	case webhookQueue <- payload:
+	default:
+		logging.WarningLogger.Printf("Webhook %s is too slow, status %q not posted", url, text)
+	}
+}
+
+// webhookText is the message shown in a chat channel.
+func webhookText(platform string, msg StatusMessage, text string) string {
+	var sb strings.Builder
+	if platform != "" {
+		fmt.Fprintf(&sb, "Platform %s: ", platform)
+	}
+	sb.WriteString(text)
+	if msg.AthleteName != "" && msg.Code != Ready {
+		fmt.Fprintf(&sb, " (%s, %s attempt %d)", msg.AthleteName, msg.LiftType, msg.AttemptNumber)
+	}
+	return sb.String()
+}
+
+// postWebhooks posts the queued events one at a time, in order.
+func postWebhooks() {
+	client := http.Client{Timeout: webhookTimeout}
+	for payload := range webhookQueue {
+		if err := postWebhook(&client, config.WebhookURL, payload); err != nil {
+			logging.WarningLogger.Printf("Failed to post %s status to the webhook: %v", payload.Event, err)
+		}
+	}
+}
+
+func postWebhook(client *http.Client, url string, payload WebhookPayload) error {
+	if url == "" {
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return nil
+}
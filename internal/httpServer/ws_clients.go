@@ -0,0 +1,115 @@
+package httpServer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+const (
+	// wsWriteWait is how long a message may take to reach a browser before
+	// the browser is dropped.
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long a browser may stay silent; it answers the pings
+	// sent every wsPingPeriod, so a tablet that went to sleep or out of Wi-Fi
+	// range is dropped instead of kept forever.
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsQueueSize is how many messages may wait for a browser. One that falls
+	// that far behind is dropped; it reconnects and gets the current status.
+	wsQueueSize = 32
+	// wsReadLimit bounds the messages browsers send, which are only pongs.
+	wsReadLimit = 4096
+)
+
+// wsClient is a browser connected to /ws. Its messages are written by its own
+// goroutine from a queue, so that a stalled tablet cannot hold the others up
+// or keep mu locked.
+type wsClient struct {
+	conn      *websocket.Conn
+	send      chan interface{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWSClient(conn *websocket.Conn) *wsClient {
+	return &wsClient{
+		conn: conn,
+		send: make(chan interface{}, wsQueueSize),
+		done: make(chan struct{}),
+	}
+}
+
+// enqueue queues a message for the browser, false when its queue is full.
+func (c *wsClient) enqueue(msg interface{}) bool {
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the connection, which also ends readLoop and writeLoop.
+func (c *wsClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// writeLoop writes the queued messages and the pings until the client is
+// closed or a write fails.
+func (c *wsClient) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.close()
+	}()
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				logging.WarningLogger.Printf("WebSocket client %s dropped: %v", c.conn.RemoteAddr(), err)
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				logging.WarningLogger.Printf("WebSocket client %s dropped: %v", c.conn.RemoteAddr(), err)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLoop reads until the browser leaves or stops answering the pings.
+func (c *wsClient) readLoop() {
+	c.conn.SetReadLimit(wsReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	}
+}
+
+// broadcastToClients queues msg for every browser, dropping the ones too far
+// behind to take it. Called with mu held; it never waits on the network.
+func broadcastToClients(msg interface{}) {
+	for client := range clients {
+		if !client.enqueue(msg) {
+			logging.WarningLogger.Printf("WebSocket client %s dropped: %d messages waiting", client.conn.RemoteAddr(), wsQueueSize)
+			client.close()
+			delete(clients, client)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package httpServer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestStalledClientDoesNotBlockTheOthers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleWebSocket))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	reader, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	stalled, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	waitForClients(t, 2)
+
+	// The reader takes every message before the next one; the other client
+	// never reads, so its socket buffers and then its queue fill up.
+	payload := strings.Repeat("x", 256<<10)
+	received := make(chan struct{})
+	go func() {
+		for {
+			var msg map[string]string
+			if err := reader.ReadJSON(&msg); err != nil {
+				close(received)
+				return
+			}
+			received <- struct{}{}
+		}
+	}()
+
+	for i := 0; i < 400; i++ {
+		start := time.Now()
+		mu.Lock()
+		broadcastToClients(map[string]string{"text": payload})
+		mu.Unlock()
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("broadcasting waited %v for the network", elapsed)
+		}
+		select {
+		case _, ok := <-received:
+			if !ok {
+				t.Fatalf("the reading client was dropped after %d messages", i)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("the reading client did not get message %d", i)
+		}
+	}
+	waitForClients(t, 1)
+}
+
+func waitForClients(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		count := len(clients)
+		mu.Unlock()
+		if count == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d WebSocket clients, want %d", count, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
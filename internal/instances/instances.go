@@ -0,0 +1,257 @@
+package instances
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// Instance is the registration of one running replays program.
+type Instance struct {
+	PID      int       `json:"pid"`
+	Dir      string    `json:"dir"`
+	Port     int       `json:"port"`
+	Platform string    `json:"platform"`
+	Status   string    `json:"status"`
+	Updated  time.Time `json:"updated"`
+}
+
+// HeartbeatInterval is how often the registration is refreshed; entries not
+// refreshed for StaleAfter are considered dead.
+const (
+	HeartbeatInterval = 5 * time.Second
+	StaleAfter        = 3 * HeartbeatInterval
+)
+
+var (
+	registryDir string
+	self        Instance
+	heldLocks   []string
+	mu          sync.Mutex
+	stopBeat    chan struct{}
+)
+
+// SetDir sets the shared directory where instances register.
+func SetDir(dir string) {
+	mu.Lock()
+	registryDir = dir
+	mu.Unlock()
+}
+
+// Register publishes this instance and starts refreshing the registration.
+func Register(dir string, port int, platform string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if registryDir == "" {
+		return fmt.Errorf("instance registry directory not set")
+	}
+	self = Instance{PID: os.Getpid(), Dir: dir, Port: port, Platform: platform}
+	if err := writeSelfLocked(); err != nil {
+		return err
+	}
+	if stopBeat == nil {
+		stopBeat = make(chan struct{})
+		go heartbeat(stopBeat)
+	}
+	return nil
+}
+
+// UpdateStatus records the current status text shown to other instances.
+func UpdateStatus(status string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if self.PID == 0 {
+		return
+	}
+	self.Status = status
+	_ = writeSelfLocked()
+}
+
+// UpdatePort records the HTTP port actually in use.
+func UpdatePort(port int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if self.PID == 0 {
+		return
+	}
+	self.Port = port
+	_ = writeSelfLocked()
+}
+
+// UpdatePlatform records the platform after it is changed in the UI.
+func UpdatePlatform(platform string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if self.PID == 0 {
+		return
+	}
+	self.Platform = platform
+	_ = writeSelfLocked()
+}
+
+// Unregister removes the registration and releases all camera locks.
+func Unregister() {
+	mu.Lock()
+	defer mu.Unlock()
+	if stopBeat != nil {
+		close(stopBeat)
+		stopBeat = nil
+	}
+	for _, path := range heldLocks {
+		_ = os.Remove(path)
+	}
+	heldLocks = nil
+	if self.PID != 0 && registryDir != "" {
+		_ = os.Remove(selfPath())
+	}
+	self = Instance{}
+}
+
+// Others returns the other live instances, sorted by directory.
+func Others() []Instance {
+	mu.Lock()
+	defer mu.Unlock()
+	return othersLocked()
+}
+
+// SuggestPort returns port if no other live instance uses it, otherwise the
+// next port that none of them uses.
+func SuggestPort(port int) int {
+	used := make(map[int]bool)
+	for _, other := range Others() {
+		used[other.Port] = true
+	}
+	for used[port] {
+		port++
+	}
+	return port
+}
+
+// AcquireLock takes an exclusive lock on a camera source (for example a
+// unicast UDP port). It fails when another live instance holds it.
+func AcquireLock(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if registryDir == "" {
+		return nil
+	}
+	dir := filepath.Join(registryDir, "locks")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, lockFileName(name))
+
+	if content, err := os.ReadFile(path); err == nil {
+		owner, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+		if owner != os.Getpid() {
+			for _, other := range othersLocked() {
+				if other.PID == owner {
+					return fmt.Errorf("%s is already used by replays instance %s (port %d, pid %d)", name, other.Dir, other.Port, other.PID)
+				}
+			}
+			logging.InfoLogger.Printf("Taking over stale lock for %s from pid %d", name, owner)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return err
+	}
+	heldLocks = append(heldLocks, path)
+	return nil
+}
+
+// ReleaseLock gives up a lock taken with AcquireLock.
+func ReleaseLock(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if registryDir == "" {
+		return
+	}
+	path := filepath.Join(registryDir, "locks", lockFileName(name))
+	for i, held := range heldLocks {
+		if held == path {
+			_ = os.Remove(path)
+			heldLocks = append(heldLocks[:i], heldLocks[i+1:]...)
+			return
+		}
+	}
+}
+
+func heartbeat(stop chan struct{}) {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			mu.Lock()
+			if err := writeSelfLocked(); err != nil {
+				logging.WarningLogger.Printf("Failed to refresh instance registration: %v", err)
+			}
+			mu.Unlock()
+		}
+	}
+}
+
+func selfPath() string {
+	return filepath.Join(registryDir, strconv.Itoa(self.PID)+".json")
+}
+
+func writeSelfLocked() error {
+	if err := os.MkdirAll(registryDir, 0755); err != nil {
+		return err
+	}
+	self.Updated = time.Now()
+	data, err := json.Marshal(self)
+	if err != nil {
+		return err
+	}
+	// Write then rename so readers never see a partial file.
+	tmp := selfPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, selfPath())
+}
+
+func othersLocked() []Instance {
+	if registryDir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(registryDir, "*.json"))
+	if err != nil {
+		return nil
+	}
+	var others []Instance
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var inst Instance
+		if err := json.Unmarshal(data, &inst); err != nil || inst.PID == os.Getpid() {
+			continue
+		}
+		if time.Since(inst.Updated) > StaleAfter {
+			_ = os.Remove(path)
+			continue
+		}
+		others = append(others, inst)
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].Dir < others[j].Dir })
+	return others
+}
+
+func lockFileName(name string) string {
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_", " ", "_")
+	return replacer.Replace(name) + ".lock"
+}
@@ -0,0 +1,73 @@
+package instances
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeOther(t *testing.T, dir string, inst Instance) {
+	t.Helper()
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuggestPortAndLocks(t *testing.T) {
+	dir := t.TempDir()
+	SetDir(dir)
+	t.Cleanup(func() {
+		Unregister()
+		SetDir("")
+	})
+
+	other := Instance{PID: os.Getpid() + 1, Dir: "replays2", Port: 8091, Updated: time.Now()}
+	writeOther(t, dir, other)
+	if err := Register("replays", 8091, "A"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if got := SuggestPort(8091); got != 8092 {
+		t.Fatalf("SuggestPort(8091) = %d, want 8092", got)
+	}
+
+	lockDir := filepath.Join(dir, "locks")
+	if err := os.MkdirAll(lockDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lockDir, lockFileName("udp port 9001")), []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lockDir, lockFileName("udp port 9002")), []byte("999999"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	other.PID = 1
+	writeOther(t, dir, other)
+
+	if err := AcquireLock("udp port 9001"); err == nil {
+		t.Fatalf("expected lock held by a live instance to be refused")
+	}
+	if err := AcquireLock("udp port 9002"); err != nil {
+		t.Fatalf("expected stale lock to be taken over: %v", err)
+	}
+}
+
+func TestStaleInstancesAreIgnored(t *testing.T) {
+	dir := t.TempDir()
+	SetDir(dir)
+	t.Cleanup(func() { SetDir("") })
+
+	writeOther(t, dir, Instance{PID: 1, Dir: "old", Port: 8091, Updated: time.Now().Add(-time.Hour)})
+	if others := Others(); len(others) != 0 {
+		t.Fatalf("expected stale instance to be ignored, got %+v", others)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected stale registration to be removed")
+	}
+}
@@ -0,0 +1,59 @@
+package jobutil
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/owlcms/replays/internal/logging"
+)
+
+var logSeq uint64
+
+// Command creates a command for a background helper program. On Windows it
+// runs without a console window; elsewhere it gets its own process group so
+// that signalling it (kill(-pgid, ...)) does not also signal the application.
+// Every subprocess of replays and cameras should be created here.
+func Command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	configureCmd(cmd)
+	return cmd
+}
+
+// Start starts a long-running command, ties it to the application's lifetime
+// (Windows job object) and records it in the process inventory under label.
+// Failing to assign or record the process is logged but not fatal.
+func Start(cmd *exec.Cmd, label string) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := Assign(cmd); err != nil {
+		logging.ErrorLogger.Printf("Failed to assign %s to job object: %v", label, err)
+	}
+	if err := Track(cmd, label); err != nil {
+		logging.WarningLogger.Printf("Failed to record pid of %s: %v", label, err)
+	}
+	return nil
+}
+
+// LogOutput redirects the command's stdout and stderr to a new timestamped
+// file <program>_<time>_<operation>_<seq>.log in logsDir and returns its path.
+func LogOutput(cmd *exec.Cmd, logsDir, program, operation string) (string, error) {
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create logs directory: %w", err)
+	}
+	timestamp := time.Now().Format("20060102_150405_000000000")
+	seq := atomic.AddUint64(&logSeq, 1)
+	logFile := filepath.Join(logsDir, fmt.Sprintf("%s_%s_%s_%d.log", program, timestamp, operation, seq))
+
+	file, err := os.Create(logFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s log file %s: %w", program, logFile, err)
+	}
+	cmd.Stdout = file
+	cmd.Stderr = file
+	return logFile, nil
+}
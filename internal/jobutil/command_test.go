@@ -0,0 +1,39 @@
+package jobutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogOutputRedirectsToNewFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	cmd := Command("ffmpeg", "-version")
+	if cmd.SysProcAttr == nil {
+		t.Fatalf("Command did not set process attributes")
+	}
+
+	first, err := LogOutput(cmd, dir, "ffmpeg", "trimming")
+	if err != nil {
+		t.Fatalf("LogOutput: %v", err)
+	}
+	defer cmd.Stdout.(*os.File).Close()
+	if cmd.Stdout != cmd.Stderr {
+		t.Fatalf("stdout and stderr should go to the same file")
+	}
+	base := filepath.Base(first)
+	if !strings.HasPrefix(base, "ffmpeg_") || !strings.Contains(base, "_trimming_") || filepath.Dir(first) != dir {
+		t.Fatalf("unexpected log file %s", first)
+	}
+
+	other := Command("ffmpeg")
+	second, err := LogOutput(other, dir, "ffmpeg", "trimming")
+	if err != nil {
+		t.Fatalf("LogOutput: %v", err)
+	}
+	defer other.Stdout.(*os.File).Close()
+	if second == first {
+		t.Fatalf("log files should be unique, got %s twice", first)
+	}
+}
@@ -0,0 +1,79 @@
+package jobutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+)
+
+// FindDeviceOwners returns the processes that currently hold a capture device
+// open, excluding ignorePIDs. On Linux and macOS the device is a path such as
+// /dev/video0 and only processes that opened that exact device are returned.
+// Windows does not expose which process owns a DirectShow device, so there
+// the result lists every process Windows reports as currently using a camera.
+func FindDeviceOwners(device string, ignorePIDs ...int) ([]PortProcess, error) {
+	device = strings.TrimSpace(device)
+	if device == "" {
+		return nil, nil
+	}
+	owners, err := findDeviceOwners(device)
+	if err != nil {
+		return nil, err
+	}
+
+	// Windows may report camera users without a PID (packaged apps); keep them
+	// as-is since dedupePortProcesses only merges real PIDs.
+	var unnamed []PortProcess
+	withPID := make([]PortProcess, 0, len(owners))
+	for _, owner := range owners {
+		if owner.PID <= 0 {
+			unnamed = append(unnamed, owner)
+			continue
+		}
+		withPID = append(withPID, owner)
+	}
+	result := filterIgnoredOwners(dedupePortProcesses(withPID), ignorePIDSet(ignorePIDs))
+	return append(result, unnamed...), nil
+}
+
+func parseLsofDeviceOwners(output []byte) []PortProcess {
+	owners := make([]PortProcess, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "COMMAND") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil || pid <= 0 {
+			continue
+		}
+		owners = append(owners, PortProcess{PID: pid, Command: strings.TrimSpace(fields[0])})
+	}
+	return owners
+}
+
+func parseWindowsTasklistPIDs(output []byte) []int {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		if pid, err := strconv.Atoi(strings.TrimSpace(record[1])); err == nil && pid > 0 {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
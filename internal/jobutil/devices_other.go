@@ -0,0 +1,59 @@
+//go:build !windows
+
+package jobutil
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func findDeviceOwners(device string) ([]PortProcess, error) {
+	target := device
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		target = resolved
+	}
+	if _, err := os.Stat("/proc/self/fd"); err == nil {
+		return scanProcDeviceOwners(target)
+	}
+	if _, err := exec.LookPath("lsof"); err == nil {
+		// lsof exits non-zero when nobody has the file open.
+		out, _ := Command("lsof", "-nP", target).Output()
+		return parseLsofDeviceOwners(out), nil
+	}
+	return nil, fmt.Errorf("unable to inspect users of %s; neither /proc nor lsof is available", device)
+}
+
+// scanProcDeviceOwners walks /proc/<pid>/fd looking for descriptors opened on
+// target. Processes of other users are skipped when their fds are unreadable.
+func scanProcDeviceOwners(target string) ([]PortProcess, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	owners := make([]PortProcess, 0)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || link != target {
+				continue
+			}
+			comm, _ := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+			owners = append(owners, PortProcess{PID: pid, Command: strings.TrimSpace(string(comm))})
+			break
+		}
+	}
+	return owners, nil
+}
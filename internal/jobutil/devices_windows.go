@@ -0,0 +1,79 @@
+//go:build windows
+
+package jobutil
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const webcamConsentStore = `Software\Microsoft\Windows\CurrentVersion\CapabilityAccessManager\ConsentStore\webcam`
+
+// findDeviceOwners lists the applications Windows currently records as using
+// a webcam (LastUsedTimeStop is 0 while the camera is open). DirectShow does
+// not tell which device they hold, so device is only used by the caller.
+func findDeviceOwners(device string) ([]PortProcess, error) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, webcamConsentStore, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil, nil
+	}
+	defer key.Close()
+
+	var owners []PortProcess
+	for _, exe := range webcamUsersInUse(key, "NonPackaged") {
+		name := filepath.Base(strings.ReplaceAll(exe, "#", `\`))
+		for _, pid := range windowsProcessPIDs(name) {
+			owners = append(owners, PortProcess{PID: pid, Command: name})
+		}
+	}
+	for _, app := range webcamUsersInUse(key, "") {
+		if app == "NonPackaged" {
+			continue
+		}
+		owners = append(owners, PortProcess{Command: app})
+	}
+	return owners, nil
+}
+
+// webcamUsersInUse returns the subkeys of the consent store (or one of its
+// subkeys) whose camera session is still open.
+func webcamUsersInUse(store registry.Key, subPath string) []string {
+	parent := store
+	if subPath != "" {
+		sub, err := registry.OpenKey(store, subPath, registry.ENUMERATE_SUB_KEYS)
+		if err != nil {
+			return nil
+		}
+		defer sub.Close()
+		parent = sub
+	}
+	names, err := parent.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil
+	}
+	var inUse []string
+	for _, name := range names {
+		app, err := registry.OpenKey(parent, name, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		start, _, startErr := app.GetIntegerValue("LastUsedTimeStart")
+		stop, _, stopErr := app.GetIntegerValue("LastUsedTimeStop")
+		app.Close()
+		if startErr == nil && stopErr == nil && start != 0 && stop == 0 {
+			inUse = append(inUse, name)
+		}
+	}
+	return inUse
+}
+
+func windowsProcessPIDs(imageName string) []int {
+	cmd := Command("tasklist", "/FI", "IMAGENAME eq "+imageName, "/FO", "CSV", "/NH")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	return parseWindowsTasklistPIDs(out)
+}
@@ -0,0 +1,175 @@
+package jobutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrackedProcess is an ffmpeg/ffplay process recorded by Track.
+type TrackedProcess struct {
+	PID     int       `json:"pid"`
+	Label   string    `json:"label"`
+	Owner   int       `json:"owner"`
+	Started time.Time `json:"started"`
+	Command string    `json:"-"` // current executable name, filled in by Inventory
+	Orphan  bool      `json:"-"` // the program that started it is no longer running
+}
+
+var (
+	inventoryDir string
+	inventoryMu  sync.Mutex
+)
+
+// SetInventoryDir sets where started processes are recorded. Each running
+// program appends to its own <pid>.jsonl file so that several programs of the
+// same install can share the directory. Tracking is disabled until it is set.
+func SetInventoryDir(dir string) {
+	inventoryMu.Lock()
+	inventoryDir = dir
+	inventoryMu.Unlock()
+}
+
+// Track records a started child process so it can be listed and cleaned up
+// later, even after this program has crashed.
+func Track(cmd *exec.Cmd, label string) error {
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	if inventoryDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(inventoryDir, 0755); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(TrackedProcess{
+		PID:     cmd.Process.Pid,
+		Label:   label,
+		Owner:   os.Getpid(),
+		Started: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(inventoryDir, strconv.Itoa(os.Getpid())+".jsonl")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Inventory returns the tracked processes that are still running. Files left
+// behind by programs that have exited and whose children are all gone are
+// removed.
+func Inventory() ([]TrackedProcess, error) {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	if inventoryDir == "" {
+		return nil, fmt.Errorf("process inventory directory not set")
+	}
+
+	files, err := filepath.Glob(filepath.Join(inventoryDir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	self := os.Getpid()
+	var running []TrackedProcess
+	for _, path := range files {
+		entries, err := readTrackedProcesses(path)
+		if err != nil {
+			return nil, err
+		}
+		alive := 0
+		for _, entry := range entries {
+			name := processName(entry.PID)
+			if !isFFmpegProcessName(name) {
+				continue
+			}
+			entry.Command = name
+			entry.Orphan = entry.Owner != self && !processStillRunning(entry.Owner)
+			running = append(running, entry)
+			alive++
+		}
+		owner, _ := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), ".jsonl"))
+		if alive == 0 && owner != self && !processStillRunning(owner) {
+			_ = os.Remove(path)
+		}
+	}
+
+	sort.Slice(running, func(i, j int) bool {
+		return running[i].Started.Before(running[j].Started)
+	})
+	return running, nil
+}
+
+// StopOrphans stops every tracked process whose parent program is gone and
+// returns how many were stopped.
+func StopOrphans(timeout time.Duration) (int, error) {
+	processes, err := Inventory()
+	if err != nil {
+		return 0, err
+	}
+	stopped := 0
+	var lastErr error
+	for _, p := range processes {
+		if !p.Orphan {
+			continue
+		}
+		if err := StopProcessTree(p.PID, timeout); err != nil {
+			lastErr = fmt.Errorf("stop %s (%d): %w", p.Command, p.PID, err)
+			continue
+		}
+		stopped++
+	}
+	return stopped, lastErr
+}
+
+func readTrackedProcesses(path string) ([]TrackedProcess, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []TrackedProcess
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry TrackedProcess
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			// A line may be cut short if the program died while writing.
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// isFFmpegProcessName guards against PID reuse: a recorded PID only counts if
+// it still belongs to ffmpeg, ffplay or ffprobe.
+func isFFmpegProcessName(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(filepath.Base(name)), ".exe")
+	switch name {
+	case "ffmpeg", "ffplay", "ffprobe":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package jobutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsFFmpegProcessName(t *testing.T) {
+	for _, name := range []string{"ffmpeg", "FFMPEG.EXE", "ffplay.exe", "/usr/bin/ffprobe"} {
+		if !isFFmpegProcessName(name) {
+			t.Fatalf("expected %q to be recognized", name)
+		}
+	}
+	for _, name := range []string{"", "obs64.exe", "ffmpeg-helper"} {
+		if isFFmpegProcessName(name) {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestInventoryRemovesStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	SetInventoryDir(dir)
+	t.Cleanup(func() { SetInventoryDir("") })
+
+	// Owner and child are long gone; the file should be cleaned up.
+	stale := filepath.Join(dir, "999999.jsonl")
+	content := "{\"pid\":999998,\"label\":\"Camera 1\",\"owner\":999999}\n{\"pid\":\n"
+	if err := os.WriteFile(stale, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	processes, err := Inventory()
+	if err != nil {
+		t.Fatalf("Inventory: %v", err)
+	}
+	if len(processes) != 0 {
+		t.Fatalf("expected no running processes, got %+v", processes)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale inventory file to be removed, stat err=%v", err)
+	}
+}
@@ -2,7 +2,11 @@
 
 package jobutil
 
-import "os/exec"
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
 
 // Init is a no-op on non-Windows platforms.
 func Init() error {
@@ -13,3 +17,18 @@ func Init() error {
 func Assign(cmd *exec.Cmd) error {
 	return nil
 }
+
+func configureCmd(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+}
+
+// Interrupt asks a command created by Command to stop as if Ctrl+C was pressed:
+// it sends SIGINT to the command alone, not to its process group.
+func Interrupt(cmd *exec.Cmd) error {
+	if cmd == nil || cmd.Process == nil {
+		return fmt.Errorf("process not started")
+	}
+	return cmd.Process.Signal(syscall.SIGINT)
+}
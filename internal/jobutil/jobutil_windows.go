@@ -5,6 +5,8 @@ package jobutil
 import (
 	"fmt"
 	"os/exec"
+	"sync"
+	"syscall"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -65,3 +67,51 @@ func Assign(cmd *exec.Cmd) error {
 	}
 	return nil
 }
+
+func configureCmd(cmd *exec.Cmd) {
+	// Its own process group lets Interrupt send it CTRL_BREAK_EVENT alone.
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: windows.CREATE_NO_WINDOW | windows.CREATE_NEW_PROCESS_GROUP,
+	}
+}
+
+var (
+	kernel32                 = windows.NewLazySystemDLL("kernel32.dll")
+	procAttachConsole        = kernel32.NewProc("AttachConsole")
+	procFreeConsole          = kernel32.NewProc("FreeConsole")
+	procSetConsoleCtrlHandle = kernel32.NewProc("SetConsoleCtrlHandler")
+	// consoleMutex serializes Interrupt: a process is attached to one
+	// console at a time.
+	consoleMutex        sync.Mutex
+	ignoreConsoleEvents sync.Once
+)
+
+// Interrupt asks a command created by Command to stop as if Ctrl+Break was
+// pressed in its console. Windows only delivers console events to processes
+// sharing the console of the sender, so the application attaches to the
+// hidden console of the command for the time of the call. It fails when the
+// application has a console of its own (started from a terminal); callers
+// then fall back to another way of stopping the command.
+func Interrupt(cmd *exec.Cmd) error {
+	if cmd == nil || cmd.Process == nil {
+		return fmt.Errorf("process not started")
+	}
+	consoleMutex.Lock()
+	defer consoleMutex.Unlock()
+
+	pid := uint32(cmd.Process.Pid)
+	if ok, _, err := procAttachConsole.Call(uintptr(pid)); ok == 0 {
+		return fmt.Errorf("AttachConsole(%d): %w", pid, err)
+	}
+	defer procFreeConsole.Call()
+	// The event also reaches the application while it is attached, maybe
+	// after it detached; the handler stays so that it never stops the
+	// application, which has no console of its own to be interrupted from.
+	ignoreConsoleEvents.Do(func() {
+		procSetConsoleCtrlHandle.Call(windows.NewCallback(func(ctrlType uint32) uintptr { return 1 }), 1)
+	})
+	if err := windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, pid); err != nil {
+		return fmt.Errorf("GenerateConsoleCtrlEvent(%d): %w", pid, err)
+	}
+	return nil
+}
@@ -141,6 +141,10 @@ func DescribePortProcesses(owners []PortProcess) string {
 			parts = append(parts, fmt.Sprintf("pid %d", owner.PID))
 			continue
 		}
+		if owner.PID <= 0 {
+			parts = append(parts, name)
+			continue
+		}
 		parts = append(parts, fmt.Sprintf("%s (%d)", name, owner.PID))
 	}
 	return strings.Join(parts, ", ")
@@ -5,20 +5,22 @@ package jobutil
 import (
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"syscall"
 )
 
 func findUDPPortOwners(port int) ([]PortProcess, error) {
 	if _, err := exec.LookPath("ss"); err == nil {
-		out, cmdErr := exec.Command("ss", "-H", "-lunp").CombinedOutput()
+		out, cmdErr := Command("ss", "-H", "-lunp").CombinedOutput()
 		if cmdErr == nil {
 			return parseSSUDPPortOwners(out, port), nil
 		}
 	}
 	if _, err := exec.LookPath("lsof"); err == nil {
-		out, cmdErr := exec.Command("lsof", "-nP", "-iUDP:"+strconv.Itoa(port)).CombinedOutput()
+		out, cmdErr := Command("lsof", "-nP", "-iUDP:"+strconv.Itoa(port)).CombinedOutput()
 		if cmdErr == nil {
 			return parseLsofUDPPortOwners(out, port), nil
 		}
@@ -56,6 +58,20 @@ func signalProcessGroup(pid int, signal syscall.Signal) error {
 	return nil
 }
 
+func processName(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+	if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		return strings.TrimSpace(string(comm))
+	}
+	out, err := Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
 func processStillRunning(pid int) bool {
 	if pid <= 0 {
 		return false
@@ -69,7 +85,7 @@ func processStillRunning(pid int) bool {
 func KillAllFFmpeg() {
 	for _, name := range []string{"ffmpeg", "ffplay"} {
 		if pkill, err := exec.LookPath("pkill"); err == nil {
-			_ = exec.Command(pkill, "-f", name).Run()
+			_ = Command(pkill, "-f", name).Run()
 		}
 	}
 }
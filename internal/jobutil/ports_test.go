@@ -40,4 +40,26 @@ func TestParseWindowsTasklistName(t *testing.T) {
 	if got := parseWindowsTasklistName(output); got != "ffplay.exe" {
 		t.Fatalf("parseWindowsTasklistName() = %q, want ffplay.exe", got)
 	}
-}
\ No newline at end of file
+}
+func TestParseLsofDeviceOwners(t *testing.T) {
+	output := []byte("COMMAND   PID USER   FD   TYPE DEVICE SIZE/OFF NODE NAME\nobs     4321 user   35u   CHR   81,0      0t0  512 /dev/video0\n")
+	owners := parseLsofDeviceOwners(output)
+	if len(owners) != 1 || owners[0].PID != 4321 || owners[0].Command != "obs" {
+		t.Fatalf("unexpected owners: %+v", owners)
+	}
+}
+
+func TestParseWindowsTasklistPIDs(t *testing.T) {
+	output := []byte("\"obs64.exe\",\"7788\",\"Console\",\"1\",\"250,000 K\"\r\n\"obs64.exe\",\"7790\",\"Console\",\"1\",\"10,000 K\"\r\n")
+	pids := parseWindowsTasklistPIDs(output)
+	if len(pids) != 2 || pids[0] != 7788 || pids[1] != 7790 {
+		t.Fatalf("unexpected pids: %v", pids)
+	}
+}
+
+func TestDescribePortProcessesWithoutPID(t *testing.T) {
+	got := DescribePortProcesses([]PortProcess{{PID: 12, Command: "ffmpeg"}, {Command: "MSTeams_8wekyb3d8bbwe"}})
+	if got != "ffmpeg (12), MSTeams_8wekyb3d8bbwe" {
+		t.Fatalf("DescribePortProcesses = %q", got)
+	}
+}
@@ -4,7 +4,6 @@ package jobutil
 
 import (
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 	"syscall"
@@ -13,8 +12,7 @@ import (
 )
 
 func findUDPPortOwners(port int) ([]PortProcess, error) {
-	cmd := exec.Command("netstat", "-ano", "-p", "udp")
-	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_NO_WINDOW}
+	cmd := Command("netstat", "-ano", "-p", "udp")
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("netstat udp owners: %w", err)
@@ -82,8 +80,7 @@ func terminateProcessNative(pid int) error {
 }
 
 func runTaskkill(args ...string) error {
-	cmd := exec.Command("taskkill", args...)
-	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_NO_WINDOW}
+	cmd := Command("taskkill", args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		message := strings.ToLower(strings.TrimSpace(string(out)))
@@ -123,8 +120,7 @@ func runTaskkill(args ...string) error {
 // orphaned or missed by per-stream stopProcess calls.
 func KillAllFFmpeg() {
 	for _, name := range []string{"ffmpeg.exe", "ffplay.exe"} {
-		cmd := exec.Command("taskkill", "/F", "/IM", name)
-		cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_NO_WINDOW}
+		cmd := Command("taskkill", "/F", "/IM", name)
 		_ = cmd.Run()
 	}
 }
@@ -133,8 +129,7 @@ func windowsProcessName(pid int) string {
 	if pid <= 0 {
 		return ""
 	}
-	cmd := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH")
-	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_NO_WINDOW}
+	cmd := Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH")
 	out, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -146,6 +141,10 @@ func windowsProcessName(pid int) string {
 	return name
 }
 
+func processName(pid int) string {
+	return windowsProcessName(pid)
+}
+
 func processStillRunning(pid int) bool {
 	return windowsProcessName(pid) != ""
 }
@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
 var (
@@ -14,6 +16,8 @@ var (
 	ErrorLogger   = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 	logFile       *os.File
 	logDir        string
+	logName       string
+	logMutex      sync.Mutex
 	Verbose       bool // Move Verbose flag here from config package
 )
 
@@ -36,7 +40,10 @@ func Init(logDirectory string) error {
 
 // InitWithFile initializes the loggers with a custom log file name
 func InitWithFile(logDirectory, logFileName string) error {
+	logMutex.Lock()
+	defer logMutex.Unlock()
 	logDir = logDirectory
+	logName = logFileName
 
 	// Create logs directory if it doesn't exist
 	if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
@@ -46,13 +53,28 @@ func InitWithFile(logDirectory, logFileName string) error {
 
 	// Open log file with O_SYNC to ensure no buffering
 	var err error
-	logFile, err = os.OpenFile(filepath.Join(logDir, logFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0666)
+	logFile, err = openLogFile()
 	if err != nil {
 		return err
 	}
 
+	// Initialize loggers with timestamps and source file info
+	flags := log.Ldate | log.Ltime | log.Lshortfile
+	infoWriter, warnWriter, errorWriter := logWriters()
+	InfoLogger = log.New(infoWriter, "INFO: ", flags)
+	WarningLogger = log.New(warnWriter, "WARN: ", flags)
+	ErrorLogger = log.New(errorWriter, "ERROR: ", flags)
+
+	return nil
+}
+
+func openLogFile() (*os.File, error) {
+	return os.OpenFile(filepath.Join(logDir, logName), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0666)
+}
+
+// logWriters sends the log to the log file, and to the console if there is one.
+func logWriters() (infoWriter, warnWriter, errorWriter io.Writer) {
 	// Initialize writers based on platform
-	var infoWriter, warnWriter, errorWriter io.Writer
 	if hasConsole() {
 		infoWriter = io.MultiWriter(os.Stdout, logFile)
 		warnWriter = io.MultiWriter(os.Stdout, logFile)
@@ -62,14 +84,50 @@ func InitWithFile(logDirectory, logFileName string) error {
 		warnWriter = logFile
 		errorWriter = logFile
 	}
+	return infoWriter, warnWriter, errorWriter
+}
 
-	// Initialize loggers with timestamps and source file info
-	flags := log.Ldate | log.Ltime | log.Lshortfile
-	InfoLogger = log.New(infoWriter, "INFO: ", flags)
-	WarningLogger = log.New(warnWriter, "WARN: ", flags)
-	ErrorLogger = log.New(errorWriter, "ERROR: ", flags)
+// Rotate renames the log file by adding suffix to its name and starts a new
+// one. It returns the path of the renamed log, "" when logging to the console
+// only.
+func Rotate(suffix string) (string, error) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logFile == nil {
+		return "", nil
+	}
 
-	return nil
+	// Windows cannot rename an open file: the lines logged meanwhile only
+	// go to the console.
+	InfoLogger.SetOutput(os.Stdout)
+	WarningLogger.SetOutput(os.Stdout)
+	ErrorLogger.SetOutput(os.Stderr)
+	logFile.Close()
+
+	ext := filepath.Ext(logName)
+	rotated := filepath.Join(logDir, strings.TrimSuffix(logName, ext)+"_"+suffix+ext)
+	renameErr := os.Rename(filepath.Join(logDir, logName), rotated)
+
+	var err error
+	if logFile, err = openLogFile(); err != nil {
+		logFile = nil
+		return "", err
+	}
+	infoWriter, warnWriter, errorWriter := logWriters()
+	InfoLogger.SetOutput(infoWriter)
+	WarningLogger.SetOutput(warnWriter)
+	ErrorLogger.SetOutput(errorWriter)
+	if renameErr != nil {
+		return "", renameErr
+	}
+	return rotated, nil
+}
+
+// Dir returns the directory of the log files, "" before Init.
+func Dir() string {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return logDir
 }
 
 func hasConsole() bool {
@@ -93,6 +151,8 @@ func fileHasConsole(f *os.File) bool {
 
 // Close closes the log file
 func Close() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
 	if logFile != nil {
 		logFile.Close()
 	}
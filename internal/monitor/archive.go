@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// ArchivedMessage is one line of an MQTT archive file (JSON lines format).
+type ArchivedMessage struct {
+	Time    int64  `json:"time"` // milliseconds since epoch when the message was received
+	Topic   string `json:"topic"`
+	Payload string `json:"payload"`
+}
+
+var (
+	archiveEnabled bool
+	archiveMu      sync.Mutex
+)
+
+// SetArchiveEnabled turns per-session MQTT archiving on or off.
+func SetArchiveEnabled(enabled bool) {
+	archiveMu.Lock()
+	archiveEnabled = enabled
+	archiveMu.Unlock()
+	if enabled {
+		logging.InfoLogger.Printf("MQTT archiving enabled, writing to %s", archiveDir())
+	}
+}
+
+// archiveDir returns the directory where MQTT archive files are written.
+func archiveDir() string {
+	return filepath.Join(config.GetInstallDir(), "mqtt")
+}
+
+// archiveFileName returns the archive file name for a session.
+func archiveFileName(session string) string {
+	session = strings.ReplaceAll(strings.TrimSpace(session), " ", "_")
+	if session == "" || session == "." || session == ".." || strings.ContainsAny(session, `/\`) {
+		session = "unsorted"
+	}
+	return session + ".jsonl"
+}
+
+// archiveMessage appends a received message to the archive file of the session.
+func archiveMessage(session string, receivedAt time.Time, topic, payload string) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	if !archiveEnabled {
+		return
+	}
+
+	dir := archiveDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logging.ErrorLogger.Printf("Failed to create MQTT archive directory %s: %v", dir, err)
+		return
+	}
+
+	line, err := json.Marshal(ArchivedMessage{
+		Time:    receivedAt.UnixNano() / int64(time.Millisecond),
+		Topic:   topic,
+		Payload: payload,
+	})
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to encode MQTT archive entry: %v", err)
+		return
+	}
+
+	path := filepath.Join(dir, archiveFileName(session))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to open MQTT archive %s: %v", path, err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		logging.ErrorLogger.Printf("Failed to write MQTT archive %s: %v", path, err)
+	}
+}
+
+// readArchive loads all messages from an archive file, in file order.
+func readArchive(path string) ([]ArchivedMessage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var messages []ArchivedMessage
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg ArchivedMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, lineNumber, err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// replayMessages dispatches archived messages, sleeping between them so the
+// original inter-message timing is preserved.
+func replayMessages(messages []ArchivedMessage, dispatch func(topic, payload string), sleep func(time.Duration)) {
+	var previous int64
+	for i, msg := range messages {
+		if i > 0 && msg.Time > previous {
+			sleep(time.Duration(msg.Time-previous) * time.Millisecond)
+		}
+		previous = msg.Time
+		logging.InfoLogger.Printf("MQTT replay %d/%d: %s", i+1, len(messages), msg.Topic)
+		dispatch(msg.Topic, msg.Payload)
+	}
+}
+
+// ReplayArchive replays an MQTT archive file against the recording pipeline.
+// It is meant to be used with --noVideo so that ffmpeg commands are only logged.
+func ReplayArchive(path string) error {
+	messages, err := readArchive(path)
+	if err != nil {
+		return fmt.Errorf("failed to read MQTT archive: %w", err)
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("MQTT archive %s contains no messages", path)
+	}
+
+	first := time.Unix(0, messages[0].Time*int64(time.Millisecond))
+	last := time.Unix(0, messages[len(messages)-1].Time*int64(time.Millisecond))
+	logging.InfoLogger.Printf("Replaying %d MQTT messages from %s (recorded %s, duration %s)",
+		len(messages), path, first.Format("2006-01-02 15:04:05"), last.Sub(first).Round(time.Second))
+
+	replayMessages(messages, dispatchMessage, time.Sleep)
+
+	logging.InfoLogger.Printf("MQTT replay of %s complete", path)
+	return nil
+}
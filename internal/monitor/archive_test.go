@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestArchiveMessageRoundTrip(t *testing.T) {
+	oldConfigDir := config.ConfigDir
+	config.ConfigDir = t.TempDir()
+	SetArchiveEnabled(true)
+	t.Cleanup(func() {
+		config.ConfigDir = oldConfigDir
+		SetArchiveEnabled(false)
+	})
+
+	base := time.Date(2025, 3, 29, 10, 0, 0, 0, time.UTC)
+	archiveMessage("Session 3", base, "owlcms/fop/start/A", `{"athleteName":"X"} 1`)
+	archiveMessage("Session 3", base.Add(1500*time.Millisecond), "owlcms/fop/stop/A", "")
+
+	messages, err := readArchive(filepath.Join(config.ConfigDir, "mqtt", "Session_3.jsonl"))
+	if err != nil {
+		t.Fatalf("readArchive: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].Topic != "owlcms/fop/start/A" || messages[0].Payload != `{"athleteName":"X"} 1` {
+		t.Fatalf("unexpected first message: %+v", messages[0])
+	}
+	if messages[1].Time-messages[0].Time != 1500 {
+		t.Fatalf("expected 1500ms between messages, got %d", messages[1].Time-messages[0].Time)
+	}
+}
+
+func TestArchiveFileNameFallsBackToUnsorted(t *testing.T) {
+	for _, session := range []string{"", "  ", "..", "a/b"} {
+		if got := archiveFileName(session); got != "unsorted.jsonl" {
+			t.Fatalf("archiveFileName(%q) = %q, want unsorted.jsonl", session, got)
+		}
+	}
+}
+
+func TestReplayMessagesPreservesTiming(t *testing.T) {
+	messages := []ArchivedMessage{
+		{Time: 1000, Topic: "owlcms/fop/start/A"},
+		{Time: 4000, Topic: "owlcms/fop/stop/A"},
+		{Time: 4250, Topic: "owlcms/fop/refereesDecision/A"},
+	}
+
+	var topics []string
+	var sleeps []time.Duration
+	replayMessages(messages, func(topic, _ string) {
+		topics = append(topics, topic)
+	}, func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	})
+
+	if len(topics) != 3 || topics[2] != "owlcms/fop/refereesDecision/A" {
+		t.Fatalf("unexpected dispatch order: %v", topics)
+	}
+	if len(sleeps) != 2 || sleeps[0] != 3*time.Second || sleeps[1] != 250*time.Millisecond {
+		t.Fatalf("unexpected sleeps: %v", sleeps)
+	}
+}
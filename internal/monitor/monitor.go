@@ -36,8 +36,20 @@ var (
 	lastConfigTimestamp    time.Time
 	// Store the list of validated platforms
 	ValidatedPlatforms []string
+	// Platform whose topics are currently subscribed
+	subscribedPlatform string
 )
 
+// platformTopics are subscribed with a "/<platform>" suffix.
+var platformTopics = []string{
+	"owlcms/fop/start",
+	"owlcms/fop/stop",
+	"owlcms/fop/refereesDecision",
+	"owlcms/fop/timer",
+	"owlcms/fop/decision",
+	"owlcms/replays/longRecording",
+}
+
 // Monitor listens to the owlcms broker for specific messages
 func Monitor(cfg *replays.Config) {
 	// First establish MQTT connection
@@ -53,6 +65,7 @@ func Monitor(cfg *replays.Config) {
 	opts.SetClientID(fmt.Sprintf("replays-monitor-%s", ip))
 	opts.SetDefaultPublishHandler(messageHandler())
 	opts.SetResumeSubs(true) // Ensure subscriptions are resumed on reconnect
+	SetArchiveEnabled(cfg.ArchiveMQTT)
 
 	mqttClient = mqtt.NewClient(opts)
 	if token := mqttClient.Connect(); token.Wait() && token.Error() != nil {
@@ -108,22 +121,117 @@ func Monitor(cfg *replays.Config) {
 		return
 	}
 
-	// Subscribe to platform-specific topics
-	platformTopics := []string{
-		"owlcms/fop/start",
-		"owlcms/fop/stop",
-		"owlcms/fop/refereesDecision",
-	}
+	subscribePlatform(cfg.Platform)
+
+	logging.InfoLogger.Printf("MQTT monitoring started on %s", mqttAddress)
+}
 
+func subscribePlatform(platform string) {
 	for _, topic := range platformTopics {
-		fullTopic := topic + "/" + cfg.Platform
+		fullTopic := topic + "/" + platform
 		logging.InfoLogger.Printf("Subscribing to topic %s", fullTopic)
 		if token := mqttClient.Subscribe(fullTopic, 0, nil); token.Wait() && token.Error() != nil {
 			logging.ErrorLogger.Printf("Failed to subscribe to topic %s: %v", fullTopic, token.Error())
 		}
 	}
+	subscribedPlatform = platform
+	state.CurrentPlatform = platform
+}
 
-	logging.InfoLogger.Printf("MQTT monitoring started on %s", mqttAddress)
+// SubscribedTopics returns the MQTT topics replays listens to, none while it
+// is not connected to owlcms.
+func SubscribedTopics() []string {
+	if mqttClient == nil || !mqttClient.IsConnected() {
+		return nil
+	}
+	topics := []string{"owlcms/fop/config"}
+	if subscribedPlatform != "" {
+		for _, topic := range platformTopics {
+			topics = append(topics, topic+"/"+subscribedPlatform)
+		}
+	}
+	return topics
+}
+
+func unsubscribePlatform(platform string) {
+	topics := make([]string, 0, len(platformTopics))
+	for _, topic := range platformTopics {
+		topics = append(topics, topic+"/"+platform)
+	}
+	logging.InfoLogger.Printf("Unsubscribing from topics %v", topics)
+	if token := mqttClient.Unsubscribe(topics...); token.Wait() && token.Error() != nil {
+		logging.ErrorLogger.Printf("Failed to unsubscribe from topics %v: %v", topics, token.Error())
+	}
+	subscribedPlatform = ""
+}
+
+// SwitchPlatform makes replays follow another platform without restarting:
+// the topics of the previous platform are dropped and those of the new one
+// subscribed. An attempt being recorded for the previous platform is
+// abandoned since its decision will no longer be received. The HTTP server
+// and the recorder keep running. The caller persists cfg.
+func SwitchPlatform(cfg *replays.Config, platform string) {
+	previous := cfg.Platform
+	cfg.Platform = platform
+	if platform == previous && subscribedPlatform == platform {
+		return
+	}
+	logging.InfoLogger.Printf("Switching platform from %q to %q", previous, platform)
+
+	abandonAttempt(fmt.Sprintf("platform %s is no longer followed", previous))
+
+	if mqttClient == nil || !mqttClient.IsConnected() {
+		// Monitoring stopped before subscribing (e.g. it was waiting for a
+		// platform to be chosen); start it again for the new platform.
+		go Monitor(cfg)
+	} else {
+		if subscribedPlatform != "" {
+			unsubscribePlatform(subscribedPlatform)
+		}
+		subscribePlatform(platform)
+	}
+	httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Platform switched to %s", platform))
+}
+
+// Reconnect moves monitoring to another owlcms server without restarting:
+// the current MQTT connection is closed, and the broker at address is
+// connected and platforms discovered again. The caller persists cfg.
+func Reconnect(cfg *replays.Config, address string) error {
+	logging.InfoLogger.Printf("Changing owlcms server from %q to %q", cfg.OwlCMS, address)
+	abandonAttempt(fmt.Sprintf("owlcms server %s is no longer followed", cfg.OwlCMS))
+	DisconnectMQTT()
+	subscribedPlatform = ""
+	ValidatedPlatforms = nil
+	state.AvailablePlatforms = nil
+	cfg.OwlCMS = address
+
+	if !IsPortOpen(fmt.Sprintf("%s:1883", address)) {
+		return fmt.Errorf("owlcms server not reachable at %s", address)
+	}
+	Monitor(cfg)
+	// Monitor also disconnects while waiting for a platform to be chosen on a
+	// server with several; that is not a failure.
+	if !IsConnected() && len(ValidatedPlatforms) == 0 {
+		return fmt.Errorf("could not connect to owlcms server at %s", address)
+	}
+	return nil
+}
+
+// abandonAttempt stops a recording whose decision can no longer arrive and
+// forgets the attempt in progress.
+func abandonAttempt(reason string) {
+	if recording.Recording {
+		logging.WarningLogger.Printf("Abandoning the recording in progress: %s", reason)
+		if _, err := recording.StopRecording(); err != nil {
+			logging.ErrorLogger.Printf("Failed to stop recording: %v", err)
+		}
+	}
+	state.CurrentSession = ""
+	state.CurrentAthlete = ""
+	state.CurrentLiftType = ""
+	state.CurrentAttempt = 0
+	state.LastStartTime = 0
+	state.LastTimerStopTime = 0
 }
 
 func validatePlatform(cfg *replays.Config, platforms []string) bool {
@@ -190,34 +298,62 @@ func PublishConfig(platform string) {
 
 func messageHandler() mqtt.MessageHandler {
 	return func(client mqtt.Client, msg mqtt.Message) {
+		receivedAt := time.Now()
 		topic := msg.Topic()
 		payload := string(msg.Payload())
 
-		// Split topic for message handling
-		topicParts := strings.Split(topic, "/")
-		if len(topicParts) < 3 {
-			return
+		sessionBefore := state.CurrentSession
+		dispatchMessage(topic, payload)
+
+		// Archive under the session the message belongs to: a start message
+		// opens the new session, a GROUP_DONE break closes the previous one.
+		session := state.CurrentSession
+		if session == "" {
+			session = sessionBefore
+		}
+		archiveMessage(session, receivedAt, topic, payload)
+	}
+}
+
+// dispatchMessage routes an MQTT message (live or replayed) to its handler.
+func dispatchMessage(topic, payload string) {
+	// Split topic for message handling
+	topicParts := strings.Split(topic, "/")
+	if len(topicParts) < 3 {
+		return
+	}
+	topic = strings.Join(topicParts[:3], "/")
+
+	switch topic {
+	case "owlcms/fop/start":
+		handleStart(payload)
+	case "owlcms/fop/stop":
+		handleStop(payload)
+	case "owlcms/fop/break":
+		handleBreak(payload)
+	case "owlcms/fop/refereesDecision":
+		handleRefereesDecision()
+	case "owlcms/fop/timer":
+		if state.UpdateStateFromTimerMessage(payload, time.Now()) {
+			logging.Trace("Clock: %s", payload)
 		}
-		topic = strings.Join(topicParts[:3], "/")
-
-		switch topic {
-		case "owlcms/fop/start":
-			handleStart(payload)
-		case "owlcms/fop/stop":
-			handleStop(payload)
-		case "owlcms/fop/break":
-			handleBreak(payload)
-		case "owlcms/fop/refereesDecision":
-			handleRefereesDecision()
-		case "owlcms/fop/config":
-			handleConfig(payload)
+	case "owlcms/fop/decision":
+		if state.UpdateStateFromDecisionMessage(payload, time.Now()) {
+			logging.Trace("Decision lights: %s", payload)
 		}
+	case "owlcms/fop/config":
+		handleConfig(payload)
+	case "owlcms/replays/longRecording":
+		handleLongRecording(payload)
 	}
 }
 
 func handleBreak(payload string) {
 	if payload == "GROUP_DONE" {
 		logging.InfoLogger.Println("Session ended")
+		if state.CurrentSession != "" {
+			recording.ArchiveSession(httpServer.SessionFolder(state.CurrentSession))
+		}
 		state.CurrentSession = ""                                    // Clear current session
 		httpServer.SendStatus(httpServer.Ready, "No active session") // Update web UI with session state
 	}
@@ -241,6 +377,7 @@ func handleConfig(payload string) {
 
 	// Store available platforms
 	state.AvailablePlatforms = configMsg.Platforms
+	state.JurySize = configMsg.JurySize
 	// Also update ValidatedPlatforms
 	ValidatedPlatforms = configMsg.Platforms
 
@@ -257,9 +394,27 @@ func handleConfig(payload string) {
 
 func handleStart(payload string) {
 	// Handle start message
+	state.StartReceivedAt = time.Now()
 	logging.InfoLogger.Printf("Handling start message: %s", payload)
 	state.UpdateStateFromStartMessage(payload)
 
+	if until, active := state.MaintenanceUntil(); active {
+		logging.InfoLogger.Printf("Maintenance mode until %s, not recording %s", until.Format("15:04:05"), state.CurrentAthlete)
+		httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Maintenance mode until %s: attempt of %s not recorded", until.Format("15:04"), strings.ReplaceAll(state.CurrentAthlete, "_", " ")))
+		return
+	}
+
+	if recording.IsLongRecording() {
+		logging.InfoLogger.Println("Long recording in progress, not recording the attempt separately")
+		return
+	}
+
+	if recording.IsCalibrating() {
+		logging.WarningLogger.Printf("Cameras are being calibrated, not recording %s", state.CurrentAthlete)
+		httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Cameras are being calibrated: attempt of %s not recorded", strings.ReplaceAll(state.CurrentAthlete, "_", " ")))
+		return
+	}
+
 	// Stop any existing recording
 	if recording.IsRecording() {
 		logging.InfoLogger.Println("Stopping running recordings")
@@ -278,9 +433,10 @@ func handleStart(payload string) {
 	}
 }
 
-// cleanUpOldMkvFiles finds and deletes .mkv files directly in the video directory
+// cleanUpOldMkvFiles finds and deletes .mkv files directly in the recording
+// directory (the video directory unless recordingDir is set)
 func cleanUpOldMkvFiles() {
-	videoDir := config.GetVideoDir()
+	videoDir := config.GetRecordingDir()
 	if videoDir == "" {
 		logging.ErrorLogger.Println("Video directory not set, cannot clean up old .mkv files")
 		return
@@ -300,6 +456,9 @@ func cleanUpOldMkvFiles() {
 			if file.IsDir() || !strings.HasSuffix(strings.ToLower(file.Name()), ".mkv") {
 				continue
 			}
+			if recording.IsLongRecordingFile(file.Name()) {
+				continue
+			}
 
 			filePath := filepath.Join(videoDir, file.Name())
 			logging.InfoLogger.Printf("Removing old temporary file: %s", filePath)
@@ -327,6 +486,9 @@ func handleRefereesDecision() {
 	// Handle refereesDecision message
 	logging.InfoLogger.Printf("Handling refereesDecision message")
 	state.LastDecisionTime = time.Now().UnixNano() / int64(time.Millisecond)
+	if recording.IsLongRecording() {
+		return
+	}
 
 	logging.InfoLogger.Println("Trimming video")
 	go func() {
@@ -336,8 +498,8 @@ func handleRefereesDecision() {
 			}
 		}()
 
-		// wait to see the decision on the replay
-		time.Sleep(2 * time.Second)
+		// wait to see the decision (and the down signal) on the replay
+		time.Sleep(config.GetDecisionWait(state.JurySize))
 		if err := recording.StopRecordingAndTrim(state.LastDecisionTime); err != nil {
 			logging.ErrorLogger.Printf("Error during trimming: %v", err)
 			return
@@ -345,6 +507,45 @@ func handleRefereesDecision() {
 	}()
 }
 
+// SimulateDecision rehearses the end of an attempt during the technical
+// meeting: the clock stop and referees decision that owlcms would send are
+// injected, so the attempt being recorded goes through the usual trimming and
+// publishing. They are not archived.
+func SimulateDecision() error {
+	if recording.IsLongRecording() {
+		return fmt.Errorf("a long recording is running, attempts are not trimmed")
+	}
+	if !recording.IsRecording() {
+		return fmt.Errorf("no attempt is being recorded; start the clock in owlcms first")
+	}
+	logging.InfoLogger.Println("Simulating clock stop and referees decision")
+	dispatchMessage("owlcms/fop/stop", "simulated")
+	dispatchMessage("owlcms/fop/refereesDecision", "simulated")
+	return nil
+}
+
+// handleLongRecording starts or stops a long recording. The payload is
+// "start", optionally followed by a label for the file names, or "stop".
+func handleLongRecording(payload string) {
+	logging.InfoLogger.Printf("Handling long recording message: %s", payload)
+	action, label, _ := strings.Cut(strings.TrimSpace(payload), " ")
+	switch strings.ToLower(action) {
+	case "start":
+		if err := recording.StartLongRecording(label); err != nil {
+			logging.ErrorLogger.Printf("Failed to start long recording: %v", err)
+			httpServer.SendStatus(httpServer.Error, fmt.Sprintf("Error: Long recording not started (%v)", err))
+		}
+	case "stop":
+		go func() {
+			if _, err := recording.StopLongRecording(); err != nil {
+				logging.ErrorLogger.Printf("Failed to stop long recording: %v", err)
+			}
+		}()
+	default:
+		logging.WarningLogger.Printf("Unknown long recording command %q, expected start or stop", payload)
+	}
+}
+
 // AutoSelectPlatform attempts to automatically select a platform when there's only one available
 func AutoSelectPlatform(cfg *replays.Config, platforms []string) bool {
 	if len(platforms) == 1 {
@@ -0,0 +1,161 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// archiveDir is the folder of the video directory where the finished sessions
+// are transcoded to AV1, in a folder per session. It is hidden so that the
+// video list does not take it for a session.
+const archiveDir = ".archive"
+
+var (
+	archiveOnce    sync.Once
+	archiveEncoder *HwEncoder // nil transcodes with libsvtav1
+)
+
+// ArchiveSession transcodes the videos of a finished session to AV1 when
+// archiveAV1 is set. The job waits for the time between attempts, like the
+// deferred re-encodes, and is stopped when an attempt starts.
+func ArchiveSession(sessionDir string) {
+	if config.ArchiveAV1 == "" || config.NoVideo || sessionDir == "" {
+		return
+	}
+	deferRecode(fmt.Sprintf("AV1 archive of %s", sessionDir), func() error {
+		return archiveSession(sessionDir, currentArchiveEncoder(), runIdle)
+	})
+}
+
+// currentArchiveEncoder probes the AV1 encoders the first time a session is
+// archived: they are not needed before.
+func currentArchiveEncoder() *HwEncoder {
+	archiveOnce.Do(func() {
+		if config.ArchiveAV1 == "software" {
+			logging.InfoLogger.Println("Sessions are archived with libsvtav1 (archiveAV1 = \"software\")")
+			return
+		}
+		archiveEncoder = pickArchiveEncoder(DetectEncoders(), config.ArchiveAV1, currentFFmpegPath())
+	})
+	return archiveEncoder
+}
+
+// pickArchiveEncoder returns the AV1 encoder of the archives among the
+// verified ones, or nil for libsvtav1.
+func pickArchiveEncoder(encoders []HwEncoder, choice, ffmpegPath string) *HwEncoder {
+	for _, enc := range encoders {
+		if enc.Codec() != "av1" || enc.FFmpegPath != "" && enc.FFmpegPath != ffmpegPath {
+			continue
+		}
+		if choice == "auto" || choice == enc.Name {
+			logging.InfoLogger.Printf("Sessions are archived with %s (%s)", enc.Name, enc.Description)
+			return &enc
+		}
+	}
+	if choice != "auto" {
+		logging.WarningLogger.Printf("archiveAV1 %s is not available on this machine, sessions are archived with libsvtav1", choice)
+	} else {
+		logging.InfoLogger.Printf("No AV1 hardware encoder found, sessions are archived with libsvtav1")
+	}
+	return nil
+}
+
+// buildArchiveArgs builds the ffmpeg arguments transcoding a video to AV1
+// with enc, or libsvtav1 when enc is nil. The sound is copied: Matroska takes
+// any codec.
+func buildArchiveArgs(enc *HwEncoder, input, output string) []string {
+	args := []string{"-y"}
+	var video []string
+	if enc != nil {
+		var hwInput []string
+		hwInput, video = hwTrimArgs(enc)
+		args = append(args, hwInput...)
+	} else {
+		// Preset 8 keeps a session within the night on a laptop; crf 32
+		// looks like the replays it is made from.
+		video = []string{"-c:v", "libsvtav1", "-preset", "8", "-crf", "32", "-pix_fmt", "yuv420p"}
+	}
+	args = append(args, "-i", input, "-map", "0:v:0", "-map", "0:a?")
+	args = append(args, video...)
+	return append(args, "-c:a", "copy", "-f", "matroska", output)
+}
+
+// archiveName returns the name of the archive of a video.
+func archiveName(video string) string {
+	return strings.TrimSuffix(video, filepath.Ext(video)) + ".mkv"
+}
+
+// archiveSession transcodes the videos of a stored session into its archive
+// folder, running ffmpeg with run. Videos archived before an interruption are
+// skipped, so the job can start over after an attempt.
+func archiveSession(sessionDir string, enc *HwEncoder, run func(*exec.Cmd) error) error {
+	entries, err := storage.Current().ReadDir(sessionDir)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(config.GetVideoDir(), archiveDir, filepath.FromSlash(sessionDir))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	workDir, err := sessionWorkDir(sessionDir)
+	if err != nil {
+		return err
+	}
+
+	archived := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !httpServer.IsVideoFile(name) || httpServer.IsProxyFile(name) {
+			// The proxies are lower-quality copies of replays archived anyway.
+			continue
+		}
+		output := filepath.Join(dir, archiveName(name))
+		if _, err := os.Stat(output); err == nil {
+			continue
+		}
+		if err := archiveVideo(sessionDir, name, workDir, output, enc, run); err != nil {
+			if errors.Is(err, errPreempted) {
+				return err
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		archived++
+	}
+	logging.InfoLogger.Printf("Session %s archived in AV1 to %s (%d videos)", sessionDir, dir, archived)
+	return nil
+}
+
+// archiveVideo transcodes one video of a session to output.
+func archiveVideo(sessionDir, name, workDir, output string, enc *HwEncoder, run func(*exec.Cmd) error) error {
+	input, cleanup, err := localReplay(sessionDir, name, workDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Not taken for archived while it is written.
+	temp := output + ".tmp"
+	defer os.Remove(temp)
+	cmd := CreateFfmpegCmd(buildArchiveArgs(enc, input, temp), "archive", "error")
+	logFile, stderr := captureTrimOutput(cmd)
+	if err := run(cmd); err != nil {
+		if errors.Is(err, errPreempted) {
+			return err
+		}
+		if stderr != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("%w, see %s", err, logFile)
+	}
+	return os.Rename(temp, output)
+}
@@ -0,0 +1,86 @@
+package recording
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestArchiveArgsTranscodeToAV1(t *testing.T) {
+	software := strings.Join(buildArchiveArgs(nil, "replay.mp4", "replay.mkv.tmp"), " ")
+	want := "-y -i replay.mp4 -map 0:v:0 -map 0:a? -c:v libsvtav1 -preset 8 -crf 32 -pix_fmt yuv420p -c:a copy -f matroska replay.mkv.tmp"
+	if software != want {
+		t.Fatalf("software archive args = %s, want %s", software, want)
+	}
+
+	qsv := HwEncoder{Name: "av1_qsv", VideoFilter: "format=nv12", OutputParameters: "-c:v av1_qsv -global_quality 32", TestInit: "-init_hw_device qsv=hw"}
+	hardware := strings.Join(buildArchiveArgs(&qsv, "replay.mp4", "replay.mkv.tmp"), " ")
+	want = "-y -init_hw_device qsv=hw -i replay.mp4 -map 0:v:0 -map 0:a? -vf format=nv12 -c:v av1_qsv -global_quality 32 -c:a copy -f matroska replay.mkv.tmp"
+	if hardware != want {
+		t.Fatalf("hardware archive args = %s, want %s", hardware, want)
+	}
+}
+
+func TestArchiveEncoderChoice(t *testing.T) {
+	encoders := []HwEncoder{
+		{Name: "h264_nvenc", FFmpegPath: "ffmpeg"},
+		{Name: "av1_nvenc", FFmpegPath: "/usr/bin/ffmpeg"},
+		{Name: "av1_qsv", FFmpegPath: "ffmpeg"},
+	}
+	if enc := pickArchiveEncoder(encoders, "auto", "ffmpeg"); enc == nil || enc.Name != "av1_qsv" {
+		t.Fatalf("auto picked %v, want av1_qsv", enc)
+	}
+	if enc := pickArchiveEncoder(encoders, "av1_nvenc", "ffmpeg"); enc != nil {
+		t.Fatalf("picked %v, only verified with another ffmpeg", enc)
+	}
+	if enc := pickArchiveEncoder(encoders[:1], "auto", "ffmpeg"); enc != nil {
+		t.Fatalf("picked %v without an AV1 encoder", enc)
+	}
+}
+
+func TestSessionIsArchivedOnce(t *testing.T) {
+	videoDir := t.TempDir()
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	session := filepath.Join(videoDir, "A", "M1")
+	if err := os.MkdirAll(session, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"attempt_Camera1.mp4", "attempt_Camera2.mov", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(session, name), []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var transcoded []string
+	run := func(cmd *exec.Cmd) error {
+		output := cmd.Args[len(cmd.Args)-1]
+		transcoded = append(transcoded, filepath.Base(output))
+		return os.WriteFile(output, []byte("av1"), 0644)
+	}
+	if err := archiveSession("A/M1", nil, run); err != nil {
+		t.Fatal(err)
+	}
+	if len(transcoded) != 2 {
+		t.Fatalf("transcoded %v, want the two videos", transcoded)
+	}
+	for _, name := range []string{"attempt_Camera1.mkv", "attempt_Camera2.mkv"} {
+		if _, err := os.Stat(filepath.Join(videoDir, archiveDir, "A", "M1", name)); err != nil {
+			t.Fatalf("%s not archived: %v", name, err)
+		}
+	}
+
+	transcoded = nil
+	if err := archiveSession("A/M1", nil, run); err != nil {
+		t.Fatal(err)
+	}
+	if len(transcoded) != 0 {
+		t.Fatalf("archived videos transcoded again: %v", transcoded)
+	}
+}
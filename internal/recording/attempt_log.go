@@ -0,0 +1,229 @@
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// maxLoggedLines bounds the lines kept from one ffmpeg process, in case one
+// repeats the same error for the whole attempt.
+const maxLoggedLines = 500
+
+// FFmpegLogEntry is one line of ffmpeg output in the log of an attempt. The
+// log of an attempt is a JSON lines file in the attempts folder of the logs.
+type FFmpegLogEntry struct {
+	Time      string `json:"time"`
+	Session   string `json:"session,omitempty"`
+	Athlete   string `json:"athlete"`
+	LiftType  string `json:"liftType"`
+	Attempt   int    `json:"attempt"`
+	Camera    int    `json:"camera"`
+	Operation string `json:"operation"` // "recording" or "trimming"
+	Level     string `json:"level"`     // "error", "warning" or "info"
+	Message   string `json:"message"`
+}
+
+// attemptLog collects the ffmpeg output of the recordings and trims of one
+// attempt.
+type attemptLog struct {
+	mu         sync.Mutex
+	path       string
+	details    httpServer.StatusAttemptDetails
+	file       *os.File
+	lastErrors map[int]string // last error of each camera
+}
+
+var (
+	attemptLogsMutex sync.Mutex
+	// attemptLogs finds the log of the attempt a recording file belongs to;
+	// trims run after the next attempt may have started.
+	attemptLogs = map[string]*attemptLog{}
+)
+
+func newAttemptLog(details httpServer.StatusAttemptDetails, started time.Time) *attemptLog {
+	name := fmt.Sprintf("%s_%s_%s_attempt%d.jsonl", started.Format("20060102_150405"),
+		strings.ReplaceAll(details.AthleteName, " ", "_"), details.LiftType, details.AttemptNumber)
+	return &attemptLog{
+		path:       filepath.Join(config.GetLogDir(), "attempts", name),
+		details:    details,
+		lastErrors: map[int]string{},
+	}
+}
+
+// registerAttemptLog makes log the log of the recording in fileName.
+func registerAttemptLog(fileName string, log *attemptLog) {
+	attemptLogsMutex.Lock()
+	defer attemptLogsMutex.Unlock()
+	attemptLogs[fileName] = log
+}
+
+// attemptLogOf returns the log of the attempt recorded in fileName, nil if
+// there is none.
+func attemptLogOf(fileName string) *attemptLog {
+	attemptLogsMutex.Lock()
+	defer attemptLogsMutex.Unlock()
+	return attemptLogs[fileName]
+}
+
+// releaseAttemptLog forgets the recording in fileName once it is trimmed,
+// closing the log when it was the last recording of its attempt.
+func releaseAttemptLog(fileName string) {
+	attemptLogsMutex.Lock()
+	defer attemptLogsMutex.Unlock()
+	log := attemptLogs[fileName]
+	delete(attemptLogs, fileName)
+	if log == nil {
+		return
+	}
+	for _, other := range attemptLogs {
+		if other == log {
+			return
+		}
+	}
+	log.close()
+}
+
+// releaseCurrentAttemptLogs forgets the recordings of the attempt, and the
+// parts of its restarted cameras, when they are stopped without being trimmed.
+func releaseCurrentAttemptLogs() {
+	recordersMutex.Lock()
+	files := append([]string(nil), currentFileNames...)
+	for _, parts := range currentParts {
+		files = append(files, parts...)
+	}
+	recordersMutex.Unlock()
+	for _, fileName := range files {
+		releaseAttemptLog(fileName)
+	}
+}
+
+// capture sends the stderr of cmd to the log, keeping the per-operation log
+// file or buffer cmd already writes to. Must be called before cmd is started.
+func (l *attemptLog) capture(cmd *exec.Cmd, cameraNumber int, operation string) {
+	if l == nil {
+		return
+	}
+	w := &ffmpegLineWriter{log: l, camera: cameraNumber, operation: operation}
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, w)
+	} else {
+		cmd.Stderr = w
+	}
+}
+
+func (l *attemptLog) write(cameraNumber int, operation, line string) {
+	entry := FFmpegLogEntry{
+		Time:      time.Now().Format("2006-01-02T15:04:05.000Z07:00"),
+		Session:   l.details.Session,
+		Athlete:   l.details.AthleteName,
+		LiftType:  l.details.LiftType,
+		Attempt:   l.details.AttemptNumber,
+		Camera:    cameraNumber,
+		Operation: operation,
+		Level:     ffmpegLineLevel(line),
+		Message:   line,
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry.Level == "error" {
+		l.lastErrors[cameraNumber] = line
+	}
+	if l.file == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+			logging.ErrorLogger.Printf("Failed to create the ffmpeg log of the attempt: %v", err)
+			return
+		}
+		if l.file, err = os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+			logging.ErrorLogger.Printf("Failed to create the ffmpeg log of the attempt: %v", err)
+			return
+		}
+	}
+	l.file.Write(append(data, '\n'))
+}
+
+// lastError returns the last error ffmpeg printed for a camera, "" if none.
+func (l *attemptLog) lastError(cameraNumber int) string {
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastErrors[cameraNumber]
+}
+
+func (l *attemptLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// ffmpegErrorDetail returns the last ffmpeg error of a camera recorded in
+// fileName, formatted to end an error message, or "".
+func ffmpegErrorDetail(fileName string, cameraNumber int) string {
+	if message := attemptLogOf(fileName).lastError(cameraNumber); message != "" {
+		return fmt.Sprintf(" (ffmpeg: %s)", message)
+	}
+	return ""
+}
+
+// ffmpegLineLevel guesses the level of a line of ffmpeg output, which has no
+// level prefix.
+func ffmpegLineLevel(line string) string {
+	lower := strings.ToLower(line)
+	for _, word := range []string{"error", "invalid", "failed", "cannot", "could not", "unable", "no such", "not found", "denied", "refused", "timed out"} {
+		if strings.Contains(lower, word) {
+			return "error"
+		}
+	}
+	if strings.Contains(lower, "warning") || strings.Contains(lower, "deprecated") {
+		return "warning"
+	}
+	return "info"
+}
+
+// ffmpegLineWriter splits the stderr of one ffmpeg process into lines for the
+// log, leaving out the progress lines.
+type ffmpegLineWriter struct {
+	log       *attemptLog
+	camera    int
+	operation string
+	pending   []byte
+	lines     int
+}
+
+func (w *ffmpegLineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		end := strings.IndexAny(string(w.pending), "\r\n")
+		if end < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(w.pending[:end]))
+		w.pending = w.pending[end+1:]
+		if line == "" || strings.HasPrefix(line, "frame=") || strings.HasPrefix(line, "size=") || w.lines >= maxLoggedLines {
+			continue
+		}
+		w.lines++
+		w.log.write(w.camera, w.operation, line)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,75 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+)
+
+func TestFFmpegStderrIsLoggedPerAttemptAndCamera(t *testing.T) {
+	details := httpServer.StatusAttemptDetails{Session: "A", AthleteName: "Jane Doe", LiftType: "SNATCH", AttemptNumber: 2}
+	log := &attemptLog{path: filepath.Join(t.TempDir(), "attempt.jsonl"), details: details, lastErrors: map[int]string{}}
+	registerAttemptLog("cam2.mkv", log)
+
+	w := &ffmpegLineWriter{log: log, camera: 2, operation: "trimming"}
+	w.Write([]byte("Input #0, matroska,webm, from 'cam2.mkv':\nframe=  10 fps=0.0 q=-1.0 size=       0kB\r"))
+	w.Write([]byte("[matroska,webm @ 0x55] Invalid data found when processing input\n[h264 @ 0x56] partial"))
+	w.Write([]byte(" line\n"))
+
+	if got := ffmpegErrorDetail("cam2.mkv", 2); got != " (ffmpeg: [matroska,webm @ 0x55] Invalid data found when processing input)" {
+		t.Fatalf("error detail = %q", got)
+	}
+	if got := ffmpegErrorDetail("cam2.mkv", 1); got != "" {
+		t.Fatalf("Camera 1 has an error detail: %q", got)
+	}
+	releaseAttemptLog("cam2.mkv")
+	if attemptLogOf("cam2.mkv") != nil || log.file != nil {
+		t.Fatalf("log still open after the last recording of the attempt was trimmed")
+	}
+
+	file, err := os.Open(log.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []FFmpegLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry FFmpegLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid line %s: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3 without the progress line: %+v", len(entries), entries)
+	}
+	if e := entries[1]; e.Athlete != "Jane Doe" || e.Attempt != 2 || e.Camera != 2 || e.Operation != "trimming" || e.Level != "error" {
+		t.Fatalf("entry = %+v", e)
+	}
+	if entries[2].Message != "[h264 @ 0x56] partial line" || entries[2].Level != "info" {
+		t.Fatalf("line split across writes = %+v", entries[2])
+	}
+}
+
+func TestStoppedRecordingsReleaseTheirLog(t *testing.T) {
+	log := newAttemptLog(httpServer.StatusAttemptDetails{AthleteName: "Jane Doe", LiftType: "SNATCH", AttemptNumber: 1}, time.Now())
+	if filepath.Dir(log.path) != filepath.Join(config.GetLogDir(), "attempts") {
+		t.Fatalf("attempt log written to %s", log.path)
+	}
+	registerAttemptLog("cam1.mkv", log)
+	registerAttemptLog("cam1_part1.mkv", log)
+	currentFileNames, currentParts = []string{"cam1.mkv"}, [][]string{{"cam1_part1.mkv"}}
+	defer func() { currentFileNames, currentParts = nil, nil }()
+
+	StopRecording()
+	if attemptLogOf("cam1.mkv") != nil || attemptLogOf("cam1_part1.mkv") != nil {
+		t.Fatalf("log kept after the attempt was stopped without a trim")
+	}
+}
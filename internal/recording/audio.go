@@ -0,0 +1,58 @@
+package recording
+
+import (
+	"runtime"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+// audioInputArgs returns the ffmpeg input reading a microphone of this machine.
+func audioInputArgs(device string) []string {
+	switch runtime.GOOS {
+	case "windows":
+		return []string{"-f", "dshow", "-thread_queue_size", "1024", "-i", "audio=" + device}
+	case "darwin":
+		return []string{"-f", "avfoundation", "-thread_queue_size", "1024", "-i", ":" + device}
+	default:
+		return []string{"-f", "alsa", "-thread_queue_size", "1024", "-i", device}
+	}
+}
+
+// audioMapArgs selects the video of the camera and the sound of the
+// microphone (second input), or that of the camera stream when there is no
+// microphone; a stream without sound is then recorded silent.
+func audioMapArgs(audio *config.AudioConfiguration) []string {
+	if audio.Device != "" {
+		return []string{"-map", "0:v:0", "-map", "1:a:0"}
+	}
+	return []string{"-map", "0:v:0", "-map", "0:a:0?"}
+}
+
+// audioCodecArgs encodes the sound with the configured codec and bitrate.
+func audioCodecArgs(audio *config.AudioConfiguration) []string {
+	if audio.Codec == "copy" {
+		return []string{"-c:a", "copy"}
+	}
+	return []string{"-c:a", audio.Codec, "-b:a", audio.Bitrate}
+}
+
+// audioTrimArgs encodes the sound of a recording that is re-encoded during
+// the trim; sound kept as is while recording is encoded with the default codec.
+func audioTrimArgs(audio *config.AudioConfiguration) []string {
+	if audio.Codec == "copy" {
+		return []string{"-c:a", config.DefaultAudioCodec, "-b:a", audio.Bitrate}
+	}
+	return audioCodecArgs(audio)
+}
+
+// withoutNoAudio removes -an from output parameters of a camera recorded with
+// sound.
+func withoutNoAudio(params []string) []string {
+	kept := params[:0]
+	for _, param := range params {
+		if param != "-an" {
+			kept = append(kept, param)
+		}
+	}
+	return kept
+}
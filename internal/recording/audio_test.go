@@ -0,0 +1,43 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestCameraWithAudioSectionIsRecordedAndTrimmedWithSound(t *testing.T) {
+	settings := config.MulticastSettings{
+		IP:          "239.255.0.1",
+		Camera1Port: 9001,
+		Camera2Port: 9002,
+		Audio:       []config.AudioConfiguration{{Camera: 2, Codec: "copy"}},
+	}
+	cameras := settings.BuildCameraConfigs()
+	if cameras[0].Audio != nil {
+		t.Fatalf("camera 1 has no audio section but was given %+v", cameras[0].Audio)
+	}
+	silent := strings.Join(buildRecordingArgs("attempt.mkv", cameras[0]), " ")
+	if !strings.Contains(silent, "-an") {
+		t.Fatalf("camera without audio recorded with sound: %s", silent)
+	}
+
+	args := strings.Join(buildRecordingArgs("attempt.mkv", cameras[1]), " ")
+	if strings.Contains(args, "-an") || !strings.Contains(args, "-map 0:v:0 -map 0:a:0? -c:v copy -c:a copy attempt.mkv") {
+		t.Fatalf("stream audio not kept: %s", args)
+	}
+
+	microphone := cameras[1]
+	microphone.Audio = &config.AudioConfiguration{Camera: 2, Device: "hw:1", Codec: "aac", Bitrate: "96k"}
+	args = strings.Join(buildRecordingArgs("attempt.mkv", microphone), " ")
+	if !strings.Contains(args, "-map 0:v:0 -map 1:a:0") || !strings.Contains(args, "-c:a aac -b:a 96k") {
+		t.Fatalf("microphone not recorded: %s", args)
+	}
+
+	microphone.Recode = true
+	trim := strings.Join(buildTrimmingArgs(5000, "attempt.mkv", "replay.mp4", microphone), " ")
+	if !strings.Contains(trim, "-c:a aac -b:a 96k") {
+		t.Fatalf("sound dropped by the re-encoding trim: %s", trim)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/owlcms/replays/internal/config"
 	"github.com/owlcms/replays/internal/config/ffmpeg"
+	"github.com/owlcms/replays/internal/jobutil"
 	"github.com/owlcms/replays/internal/logging"
 )
 
@@ -25,7 +26,7 @@ import (
 type DetectedCamera struct {
 	Name             string
 	Device           string       // device path (Linux) or device name (Windows)
-	Format           string       // v4l2, dshow, or rtsp
+	Format           string       // v4l2, dshow, rtsp or screen
 	PixFmt           string       // mjpeg, yuyv422, etc.
 	Size             string       // best resolution found
 	Fps              int          // best fps for that resolution
@@ -57,6 +58,33 @@ func (cam *DetectedCamera) RepickForFormat(preferredFormat string, cfg *ffmpeg.C
 	cam.Fps = best.fps
 }
 
+// Mode describes the selected mode of the camera, as "mjpeg 1280x720@60fps".
+func (cam *DetectedCamera) Mode() string {
+	return fmt.Sprintf("%s %s@%dfps", cam.PixFmt, cam.Size, cam.Fps)
+}
+
+// FallbackMode switches to the best detected mode not in tried, keyed by
+// Mode. The detected modes are kept, so that a later start can use the
+// failed one again. It returns false, leaving the camera unchanged, when no
+// other mode is left.
+func (cam *DetectedCamera) FallbackMode(cfg *ffmpeg.Config, tried map[string]bool) bool {
+	var remaining []cameraMode
+	for _, m := range cam.modes {
+		if tried[fmt.Sprintf("%s %dx%d@%dfps", m.pixFmt, m.width, m.height, m.fps)] {
+			continue
+		}
+		remaining = append(remaining, m)
+	}
+	if len(remaining) == 0 {
+		return false
+	}
+	best := PickBestCameraModeWithConfig(remaining, cfg)
+	cam.PixFmt = best.pixFmt
+	cam.Size = fmt.Sprintf("%dx%d", best.width, best.height)
+	cam.Fps = best.fps
+	return true
+}
+
 // uniqueFormats returns sorted unique format names from a set of camera modes.
 func uniqueFormats(modes []cameraMode) []string {
 	seen := make(map[string]struct{})
@@ -73,7 +101,7 @@ func uniqueFormats(modes []cameraMode) []string {
 
 // HwEncoder holds information about a detected hardware encoder
 type HwEncoder struct {
-	Name             string // h264_nvenc, h264_vaapi, h264_amf, h264_qsv
+	Name             string // h264_nvenc, h264_vaapi, h264_amf, h264_qsv, hevc_nvenc...
 	Description      string
 	InputParameters  string
 	OutputParameters string
@@ -82,6 +110,11 @@ type HwEncoder struct {
 	FFmpegPath       string // optional per-encoder ffmpeg path override
 }
 
+// Codec returns the codec the encoder produces, "h264", "hevc" or "av1".
+func (e HwEncoder) Codec() string {
+	return ffmpeg.EncoderCodec(e.Name)
+}
+
 type cameraMode struct {
 	pixFmt string
 	width  int
@@ -123,7 +156,13 @@ func DetectAndWriteConfig(window fyne.Window) {
 		cameras := DetectCamerasWithConfig(cameraCfg)
 		logging.InfoLogger.Printf("Detected %d cameras", len(cameras))
 
-		// Step 3: Write auto.toml (even with 0 cameras, to show detected encoders)
+		// Step 3: Measure how many cameras can be encoded at once.
+		capacity := MeasureEncodeCapacity(encoders, cameraCfg, func(streams int) {
+			progressLabel.SetText(fmt.Sprintf("Measuring encoding capacity (%d simultaneous 1080p60 encodes)...", streams))
+		})
+		logging.InfoLogger.Printf("Sustained %d simultaneous 1080p60 encodes with %s", capacity.Streams, capacity.Encoder)
+
+		// Step 4: Write auto.toml (even with 0 cameras, to show detected encoders)
 		progressLabel.SetText("Writing auto.toml...")
 		// Output to autoTomlDir if set, otherwise install dir
 		var outputPath string
@@ -132,20 +171,20 @@ func DetectAndWriteConfig(window fyne.Window) {
 		} else {
 			outputPath = filepath.Join(config.GetInstallDir(), "auto.toml")
 		}
-		err := writeAutoConfig(outputPath, cameras, encoders, cameraCfg)
+		err := writeAutoConfig(outputPath, cameras, encoders, capacity, cameraCfg)
 		if err != nil {
 			logging.ErrorLogger.Printf("Failed to write auto.toml: %v", err)
 			dialog.ShowError(fmt.Errorf("failed to write auto.toml: %v", err), window)
 			return
 		}
 
-		// Step 4: Show results
-		summary := buildSummary(cameras, encoders, outputPath)
+		// Step 5: Show results
+		summary := buildSummary(cameras, encoders, capacity, outputPath)
 		showAutoDetectResults(summary, outputPath, window)
 	}()
 }
 
-// DetectEncoders probes ffmpeg for available H.264 hardware encoders using ffmpeg.toml.
+// DetectEncoders probes ffmpeg for available hardware encoders using ffmpeg.toml.
 func DetectEncoders() []HwEncoder {
 	cfg, err := ffmpeg.LoadConfig()
 	if err != nil {
@@ -155,8 +194,8 @@ func DetectEncoders() []HwEncoder {
 	return DetectEncodersWithConfig(cfg)
 }
 
-// DetectEncodersWithConfig probes ffmpeg for available H.264 hardware encoders,
-// using the encoder definitions from cfg.
+// DetectEncodersWithConfig probes ffmpeg for available hardware encoders, H.264
+// and the HEVC and AV1 ones, using the encoder definitions from cfg.
 func DetectEncodersWithConfig(cfg *ffmpeg.Config) []HwEncoder {
 	return DetectEncodersWithConfigAndProgress(cfg, nil)
 }
@@ -220,6 +259,10 @@ func shouldRetrySystemFFmpegProbe(found []HwEncoder, cfg *ffmpeg.Config) bool {
 		if !encoderGPUVendorMatches(enc.GpuVendors, vendors) {
 			continue
 		}
+		// Older GPUs have no HEVC or AV1 encoder; that is no reason to look further.
+		if ffmpeg.EncoderCodec(enc.Name) != ffmpeg.Codecs[0] {
+			continue
+		}
 		if containsHwEncoder(found, enc.Name) {
 			continue
 		}
@@ -310,7 +353,7 @@ func detectEncodersWithPath(path string, cfg *ffmpeg.Config, progress ProbeProgr
 
 	encoderQueryArgs := []string{"-hide_banner", "-encoders"}
 	logging.InfoLogger.Printf("Querying ffmpeg encoders: %s", formatCommandLine(path, encoderQueryArgs))
-	cmd := CreateHiddenCmd(path, encoderQueryArgs...)
+	cmd := jobutil.Command(path, encoderQueryArgs...)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -320,7 +363,7 @@ func detectEncodersWithPath(path string, cfg *ffmpeg.Config, progress ProbeProgr
 		return nil
 	}
 
-	availableEncoders := parseAvailableH264Encoders(out.Bytes())
+	availableEncoders := parseAvailableHwEncoders(out.Bytes())
 	if progress != nil {
 		reportUnconfiguredEncoders(availableEncoders, cfg, progress)
 	}
@@ -391,7 +434,9 @@ func reportUnconfiguredEncoders(available map[string]bool, cfg *ffmpeg.Config, p
 	}
 }
 
-func parseAvailableH264Encoders(output []byte) map[string]bool {
+// parseAvailableHwEncoders returns the H.264, HEVC and AV1 encoders listed by
+// ffmpeg -encoders.
+func parseAvailableHwEncoders(output []byte) map[string]bool {
 	available := make(map[string]bool)
 	scanner := bufio.NewScanner(bytes.NewReader(output))
 	for scanner.Scan() {
@@ -401,7 +446,7 @@ func parseAvailableH264Encoders(output []byte) map[string]bool {
 		}
 		flags := fields[0]
 		name := fields[1]
-		if len(flags) != 6 || !strings.HasPrefix(flags, "V") || !strings.HasPrefix(name, "h264_") {
+		if len(flags) != 6 || !strings.HasPrefix(flags, "V") || !(strings.HasPrefix(name, "h264_") || strings.HasPrefix(name, "hevc_") || strings.HasPrefix(name, "av1_")) {
 			continue
 		}
 		available[name] = true
@@ -474,7 +519,7 @@ func detectGPUVendors() map[string]bool {
 
 	// Method 2: Check nvidia-smi (works even if /proc file is missing)
 	if !vendors["nvidia"] {
-		cmd := CreateHiddenCmd("nvidia-smi", "-L")
+		cmd := jobutil.Command("nvidia-smi", "-L")
 		var out bytes.Buffer
 		cmd.Stdout = &out
 		cmd.Stderr = &out
@@ -517,7 +562,7 @@ func detectGPUVendors() map[string]bool {
 	}
 
 	// Method 4: Fallback to lspci for any vendors not yet detected
-	cmd := CreateHiddenCmd("lspci", "-nn")
+	cmd := jobutil.Command("lspci", "-nn")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -564,7 +609,7 @@ func sortedVendorKeys(vendors map[string]bool) []string {
 
 // detectGPUVendorsWindows uses wmic to enumerate GPU adapters on Windows.
 func detectGPUVendorsWindows(vendors map[string]bool) map[string]bool {
-	cmd := CreateHiddenCmd("wmic", "path", "win32_VideoController", "get", "Name")
+	cmd := jobutil.Command("wmic", "path", "win32_VideoController", "get", "Name")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -628,7 +673,7 @@ func formatCommandLine(name string, args []string) string {
 var ffmpegVersionPattern = regexp.MustCompile(`(?i)^ffmpeg version `)
 
 func isSupportedSystemFFmpeg(path string) bool {
-	cmd := CreateHiddenCmd(path, "-version")
+	cmd := jobutil.Command(path, "-version")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -658,11 +703,16 @@ func isSupportedSystemFFmpeg(path string) bool {
 // using the TestInit field for any required hardware init flags.
 func testEncoderWithInit(ffmpegPath string, enc HwEncoder) bool {
 	var args []string
+	// HEVC and AV1 encoders refuse pictures as small as the H.264 ones accept.
+	source := "nullsrc=s=64x64:d=0.1"
+	if enc.Codec() != ffmpeg.Codecs[0] {
+		source = "nullsrc=s=256x256:d=0.1"
+	}
 
 	if enc.TestInit != "" {
 		args = append(args, "-hide_banner", "-loglevel", "error")
 		args = append(args, strings.Fields(enc.TestInit)...)
-		args = append(args, "-f", "lavfi", "-i", "nullsrc=s=64x64:d=0.1")
+		args = append(args, "-f", "lavfi", "-i", source)
 		// VAAPI needs hwupload filter
 		if strings.Contains(enc.Name, "vaapi") {
 			args = append(args, "-vf", "format=nv12,hwupload")
@@ -670,13 +720,13 @@ func testEncoderWithInit(ffmpegPath string, enc HwEncoder) bool {
 		args = append(args, "-c:v", enc.Name, "-f", "null", "-")
 	} else {
 		args = []string{"-hide_banner", "-loglevel", "error",
-			"-f", "lavfi", "-i", "nullsrc=s=64x64:d=0.1",
+			"-f", "lavfi", "-i", source,
 			"-c:v", enc.Name, "-f", "null", "-"}
 	}
 
 	logging.InfoLogger.Printf("Testing encoder %s with command: %s", enc.Name, formatCommandLine(ffmpegPath, args))
 
-	cmd := CreateHiddenCmd(ffmpegPath, args...)
+	cmd := jobutil.Command(ffmpegPath, args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	err := cmd.Run()
@@ -720,14 +770,14 @@ func DetectCamerasWithConfigAndProgressFiltered(cfg *ffmpeg.Config, progress Pro
 		cfg = loaded
 	}
 
+	var cameras []DetectedCamera
 	switch runtime.GOOS {
 	case "linux":
-		return detectCamerasLinux(cfg, progress, skip)
+		cameras = detectCamerasLinux(cfg, progress, skip)
 	case "windows":
-		return detectCamerasWindows(cfg, progress, skip)
-	default:
-		return nil
+		cameras = detectCamerasWindows(cfg, progress, skip)
 	}
+	return append(cameras, detectNDICameras(progress, skip)...)
 }
 
 // detectCamerasLinux uses v4l2-ctl to detect cameras and their formats
@@ -736,7 +786,7 @@ func detectCamerasLinux(cfg *ffmpeg.Config, progress ProbeProgressFunc, skip fun
 		progress(ProgressMsg(ProgListing, "V4L2 devices"))
 	}
 	// First get the device list
-	cmd := CreateHiddenCmd("v4l2-ctl", "--list-devices")
+	cmd := jobutil.Command("v4l2-ctl", "--list-devices")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -798,7 +848,7 @@ func detectCamerasLinux(cfg *ffmpeg.Config, progress ProbeProgressFunc, skip fun
 
 // probeV4L2Device probes a single v4l2 device for its best format
 func probeV4L2Device(name, device, location string, cfg *ffmpeg.Config) *DetectedCamera {
-	cmd := CreateHiddenCmd("v4l2-ctl", "-d", device, "--list-formats-ext")
+	cmd := jobutil.Command("v4l2-ctl", "-d", device, "--list-formats-ext")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -900,6 +950,7 @@ func probeV4L2Device(name, device, location string, cfg *ffmpeg.Config) *Detecte
 	for _, f := range formats {
 		modes = append(modes, cameraMode{pixFmt: f.pixFmt, width: f.width, height: f.height, fps: f.fps})
 	}
+	modes = applyUSBQuirks(v4l2USBID(device), name, modes)
 
 	best := PickBestCameraModeWithConfig(modes, cfg)
 	matchKey, attachmentPath, identity := resolveStableCameraIdentity(name, device, location)
@@ -930,7 +981,7 @@ func detectCamerasWindows(cfg *ffmpeg.Config, progress ProbeProgressFunc, skip f
 	}
 
 	// List devices
-	cmd := CreateHiddenCmd(path, "-hide_banner", "-f", "dshow", "-list_devices", "true", "-i", "dummy")
+	cmd := jobutil.Command(path, "-hide_banner", "-f", "dshow", "-list_devices", "true", "-i", "dummy")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -1002,6 +1053,9 @@ func resolveFFprobePath(ffmpegPath string) string {
 	if runtime.GOOS == "windows" {
 		name = "ffprobe.exe"
 	}
+	if bundledPath := config.FindBundledFFmpegExecutable(name); bundledPath != "" {
+		return bundledPath
+	}
 	if sharedPath := config.FindSharedFFmpegExecutable(name); sharedPath != "" {
 		return sharedPath
 	}
@@ -1013,7 +1067,7 @@ func resolveFFprobePath(ffmpegPath string) string {
 }
 
 func verifyDshowH264Delivery(ffprobePath, name string) bool {
-	cmd := CreateHiddenCmd(ffprobePath, "-hide_banner", "-f", "dshow", "-i", fmt.Sprintf("video=%s", name))
+	cmd := jobutil.Command(ffprobePath, "-hide_banner", "-f", "dshow", "-i", fmt.Sprintf("video=%s", name))
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -1041,7 +1095,7 @@ func verifyDshowH264Delivery(ffprobePath, name string) bool {
 
 // probeDshowDevice probes a single dshow device for its capabilities.
 func probeDshowDevice(ffmpegPath, name, alternativeName string, cfg *ffmpeg.Config) *DetectedCamera {
-	cmd := CreateHiddenCmd(ffmpegPath, "-hide_banner", "-f", "dshow", "-list_options", "true", "-i", fmt.Sprintf("video=%s", name))
+	cmd := jobutil.Command(ffmpegPath, "-hide_banner", "-f", "dshow", "-list_options", "true", "-i", fmt.Sprintf("video=%s", name))
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
@@ -1132,6 +1186,7 @@ func probeDshowDevice(ffmpegPath, name, alternativeName string, cfg *ffmpeg.Conf
 	for _, o := range options {
 		modes = append(modes, cameraMode{pixFmt: o.pixFmt, width: o.width, height: o.height, fps: o.fps})
 	}
+	modes = applyUSBQuirks(dshowUSBID(alternativeName), name, modes)
 
 	effectiveModes := modes
 	best := PickBestCameraModeWithConfig(modes, cfg)
@@ -1295,7 +1350,7 @@ func modePreferredOverWithConfig(candidate, current cameraMode, cfg *ffmpeg.Conf
 }
 
 // writeAutoConfig generates auto.toml from detected hardware using ffmpeg.toml settings.
-func writeAutoConfig(outputPath string, cameras []DetectedCamera, encoders []HwEncoder, cfg *ffmpeg.Config) error {
+func writeAutoConfig(outputPath string, cameras []DetectedCamera, encoders []HwEncoder, capacity EncodeCapacity, cfg *ffmpeg.Config) error {
 	if cfg == nil {
 		return fmt.Errorf("ffmpeg config is required to write auto.toml")
 	}
@@ -1414,11 +1469,14 @@ func writeAutoConfig(outputPath string, cameras []DetectedCamera, encoders []HwE
 				baseQueue = "-thread_queue_size 4096"
 			}
 
-			// Specify the pixel format for proper raw input handling
+			// Specify the pixel format for proper raw input handling; NDI
+			// sources have a single format.
 			var fmtFlag string
-			if cam.Format == "dshow" {
+			switch cam.Format {
+			case "dshow":
 				fmtFlag = fmt.Sprintf("-pixel_format %s", cam.PixFmt)
-			} else {
+			case ndiFormat:
+			default:
 				fmtFlag = fmt.Sprintf("-input_format %s", cam.PixFmt)
 			}
 
@@ -1427,7 +1485,9 @@ func writeAutoConfig(outputPath string, cameras []DetectedCamera, encoders []HwE
 			if initPart != "" {
 				parts = append(parts, initPart)
 			}
-			parts = append(parts, fmtFlag)
+			if fmtFlag != "" {
+				parts = append(parts, fmtFlag)
+			}
 			if baseBuf != "" {
 				parts = append(parts, baseBuf)
 			}
@@ -1488,6 +1548,8 @@ func writeAutoConfig(outputPath string, cameras []DetectedCamera, encoders []HwE
 		buf.WriteString(fmt.Sprintf("# %s - %s\n", enc.Name, enc.Description))
 	}
 	buf.WriteString("# libx264 - Software encoder (always available)\n")
+	buf.WriteString("\n")
+	writeEncodeCapacity(&buf, capacity)
 
 	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
 		return err
@@ -1497,16 +1559,24 @@ func writeAutoConfig(outputPath string, cameras []DetectedCamera, encoders []HwE
 	return nil
 }
 
-// PickBestEncoder selects the first verified encoder, preserving ffmpeg.toml preference order.
+// PickBestEncoder selects the first verified H.264 encoder, preserving ffmpeg.toml preference order.
 func PickBestEncoder(encoders []HwEncoder) *HwEncoder {
-	if len(encoders) > 0 {
-		return &encoders[0]
+	return PickEncoderForCodec(encoders, ffmpeg.Codecs[0])
+}
+
+// PickEncoderForCodec selects the first verified encoder producing codec,
+// "h264" or "hevc", or nil when there is none.
+func PickEncoderForCodec(encoders []HwEncoder, codec string) *HwEncoder {
+	for i := range encoders {
+		if encoders[i].Codec() == codec {
+			return &encoders[i]
+		}
 	}
 	return nil
 }
 
 // buildSummary creates a human-readable summary of detected hardware
-func buildSummary(cameras []DetectedCamera, encoders []HwEncoder, outputPath string) string {
+func buildSummary(cameras []DetectedCamera, encoders []HwEncoder, capacity EncodeCapacity, outputPath string) string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Configuration written to:\n%s\n\n", outputPath))
 
@@ -1517,7 +1587,7 @@ func buildSummary(cameras []DetectedCamera, encoders []HwEncoder, outputPath str
 		sb.WriteString(fmt.Sprintf("     Format: %s  Size: %s  FPS: %d\n", cam.PixFmt, cam.Size, cam.Fps))
 	}
 
-	sb.WriteString("\nH.264 encoders available:\n")
+	sb.WriteString("\nHardware encoders available:\n")
 	if len(encoders) == 0 {
 		sb.WriteString("  (none - software encoding only)\n")
 	}
@@ -1526,6 +1596,9 @@ func buildSummary(cameras []DetectedCamera, encoders []HwEncoder, outputPath str
 	}
 	sb.WriteString("  - libx264 (software, always available)\n")
 
+	sb.WriteString(fmt.Sprintf("\nSimultaneous 1080p60 encodes with %s: %d\n", capacity.Encoder, capacity.Streams))
+	sb.WriteString(capacity.Guidance() + "\n")
+
 	sb.WriteString("\nUse auto.toml as the baseline and add only manual overrides or extra sources to config.toml")
 	return sb.String()
 }
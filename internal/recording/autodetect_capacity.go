@@ -0,0 +1,168 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/config/ffmpeg"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+const (
+	// capacityMaxStreams is the most simultaneous encodes tried; replays
+	// supports four cameras.
+	capacityMaxStreams = 4
+	// capacitySeconds is the length of the synthetic video each encode
+	// compresses.
+	capacitySeconds = 5
+	// capacityMinSpeed is the speed every encode must reach: a little above
+	// real time, since the cameras also have to be read and the replays trimmed.
+	capacityMinSpeed = 1.1
+)
+
+// EncodeCapacity is how many 1080p60 videos the machine encodes at once in
+// real time.
+type EncodeCapacity struct {
+	Encoder string
+	Streams int         // simultaneous encodes sustained, 0 if not even one
+	Speeds  [][]float64 // speed of each encode, for 1, 2... simultaneous encodes
+}
+
+// Guidance tells the operator how many cameras to expect to record.
+func (c EncodeCapacity) Guidance() string {
+	switch {
+	case c.Streams == 0:
+		return "This machine cannot encode even one 1080p60 camera in real time: use cameras that send H.264, or a lower resolution or frame rate."
+	case c.Streams >= capacityMaxStreams:
+		return fmt.Sprintf("%d or more 1080p60 cameras can be encoded at once.", capacityMaxStreams)
+	case c.Streams == 1:
+		return "Only one 1080p60 camera can be encoded at a time: for more cameras, use cameras that send H.264, or a lower resolution or frame rate."
+	default:
+		return fmt.Sprintf("Up to %d 1080p60 cameras can be encoded at once; more need cameras that send H.264, or a lower resolution or frame rate.", c.Streams)
+	}
+}
+
+// MeasureEncodeCapacity encodes 1, 2, 3 then 4 synthetic 1080p60 videos at
+// once with the encoder raw cameras would be recorded with (the best hardware
+// encoder, libx264 if there is none), and stops at the first count where one
+// of them falls behind real time.
+func MeasureEncodeCapacity(encoders []HwEncoder, cfg *ffmpeg.Config, progress func(streams int)) EncodeCapacity {
+	path := config.GetFFmpegPath()
+	if path == "" {
+		path = "ffmpeg"
+	}
+	name := "libx264"
+	args := capacityArgs(nil, cfg.Software.OutputParameters)
+	if best := PickBestEncoder(encoders); best != nil {
+		name = best.Name
+		args = capacityArgs(best, best.OutputParameters)
+		if best.FFmpegPath != "" {
+			path = best.FFmpegPath
+		}
+	}
+
+	capacity := EncodeCapacity{Encoder: name}
+	for streams := 1; streams <= capacityMaxStreams; streams++ {
+		if progress != nil {
+			progress(streams)
+		}
+		speeds := runSimultaneousEncodes(path, args, streams)
+		capacity.Speeds = append(capacity.Speeds, speeds)
+		logging.InfoLogger.Printf("Encoding capacity with %s: %d simultaneous 1080p60 encodes at speeds %v", name, streams, speeds)
+		if !sustained(speeds) {
+			break
+		}
+		capacity.Streams = streams
+	}
+	return capacity
+}
+
+// capacityArgs returns the ffmpeg arguments encoding a synthetic 1080p60
+// video with enc (nil for libx264) and its output parameters, reporting the
+// progress on stdout.
+func capacityArgs(enc *HwEncoder, outputParameters string) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostats", "-progress", "pipe:1"}
+	if enc != nil {
+		args = append(args, strings.Fields(enc.TestInit)...)
+	}
+	args = append(args, "-f", "lavfi", "-i", fmt.Sprintf("testsrc2=s=1920x1080:r=60:d=%d", capacitySeconds))
+	if enc != nil && strings.TrimSpace(enc.VideoFilter) != "" {
+		args = append(args, "-vf", strings.TrimSpace(enc.VideoFilter))
+	}
+	args = append(args, strings.Fields(outputParameters)...)
+	return append(args, "-f", "null", "-")
+}
+
+// writeEncodeCapacity records the measured capacity in auto.toml, as comments.
+func writeEncodeCapacity(buf *bytes.Buffer, capacity EncodeCapacity) {
+	buf.WriteString("# =======================================================\n")
+	buf.WriteString("# Encoding capacity (synthetic 1080p60 sources)\n")
+	buf.WriteString("# =======================================================\n")
+	fmt.Fprintf(buf, "# Sustained simultaneous encodes with %s: %d\n", capacity.Encoder, capacity.Streams)
+	for i, speeds := range capacity.Speeds {
+		formatted := make([]string, len(speeds))
+		for j, speed := range speeds {
+			formatted[j] = fmt.Sprintf("%.2fx", speed)
+		}
+		fmt.Fprintf(buf, "#   %d at once: %s\n", i+1, strings.Join(formatted, " "))
+	}
+	fmt.Fprintf(buf, "# %s\n", capacity.Guidance())
+	buf.WriteString("# Cameras that send H.264, or MJPEG recoded when trimming, are copied and do not count.\n")
+}
+
+// runSimultaneousEncodes runs streams copies of ffmpeg at once and returns
+// the speed each one reached, 0 for those that failed.
+func runSimultaneousEncodes(path string, args []string, streams int) []float64 {
+	speeds := make([]float64, streams)
+	var wg sync.WaitGroup
+	for i := 0; i < streams; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := jobutil.Command(path, args...)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				logging.InfoLogger.Printf("Encoding capacity test failed: %v (%s)", err, summarizeEncoderProbeError(stderr.String()))
+				return
+			}
+			speeds[i] = parseProgressSpeed(stdout.String())
+		}()
+	}
+	wg.Wait()
+	return speeds
+}
+
+// parseProgressSpeed returns the last speed reported by ffmpeg -progress,
+// 0 if there is none.
+func parseProgressSpeed(output string) float64 {
+	speed := 0.0
+	for _, line := range strings.Split(output, "\n") {
+		value := strings.TrimSpace(line)
+		if !strings.HasPrefix(value, "speed=") {
+			continue
+		}
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "speed="), "x")
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			speed = parsed
+		}
+	}
+	return speed
+}
+
+// sustained tells if every encode kept up with real time.
+func sustained(speeds []float64) bool {
+	for _, speed := range speeds {
+		if speed < capacityMinSpeed {
+			return false
+		}
+	}
+	return len(speeds) > 0
+}
@@ -0,0 +1,48 @@
+package recording
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseProgressSpeed(t *testing.T) {
+	output := "frame=120\nfps=58.2\nspeed=0.97x\nprogress=continue\nframe=300\nspeed=1.52x\nprogress=end\n"
+	if speed := parseProgressSpeed(output); speed != 1.52 {
+		t.Errorf("parseProgressSpeed = %v, want 1.52", speed)
+	}
+	if speed := parseProgressSpeed("speed=N/A\n"); speed != 0 {
+		t.Errorf("parseProgressSpeed(N/A) = %v, want 0", speed)
+	}
+	if sustained([]float64{1.8, 1.05}) {
+		t.Error("sustained with one encode below real time")
+	}
+	if !sustained([]float64{1.8, 1.2}) {
+		t.Error("not sustained with every encode above real time")
+	}
+}
+
+func TestWriteEncodeCapacity(t *testing.T) {
+	capacity := EncodeCapacity{Encoder: "h264_vaapi", Streams: 2, Speeds: [][]float64{{3.1}, {1.6, 1.5}, {1.02, 0.98, 1.0}}}
+	var buf bytes.Buffer
+	writeEncodeCapacity(&buf, capacity)
+	for _, want := range []string{
+		"# Sustained simultaneous encodes with h264_vaapi: 2\n",
+		"#   3 at once: 1.02x 0.98x 1.00x\n",
+		"# Up to 2 1080p60 cameras",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			t.Errorf("line %q is not a comment", line)
+		}
+	}
+
+	args := strings.Join(capacityArgs(&HwEncoder{Name: "h264_vaapi", TestInit: "-init_hw_device vaapi=va", VideoFilter: "format=nv12,hwupload"}, "-c:v h264_vaapi -b:v 8M"), " ")
+	if !strings.Contains(args, "-init_hw_device vaapi=va -f lavfi -i testsrc2=s=1920x1080:r=60") || !strings.Contains(args, "-vf format=nv12,hwupload -c:v h264_vaapi") {
+		t.Errorf("capacityArgs = %s", args)
+	}
+}
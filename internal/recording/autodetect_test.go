@@ -12,7 +12,7 @@ func testFFmpegConfigWithEncoder(name string) *ffmpegcfg.Config {
 	}
 }
 
-func TestParseAvailableH264EncodersOnlyUsesEncoderRows(t *testing.T) {
+func TestParseAvailableHwEncodersOnlyUsesEncoderRows(t *testing.T) {
 	output := []byte(`Encoders:
  V..... h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)
  V....D libx264              H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
@@ -21,15 +21,19 @@ func TestParseAvailableH264EncodersOnlyUsesEncoderRows(t *testing.T) {
  V....D h264_amf             AMD AMF H.264 Encoder (codec h264)
  A..... h264_audio_name      not a video encoder
  V..... h264_qsv             H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (Intel Quick Sync Video acceleration)
+ V....D libx265              libx265 H.265 / HEVC (codec hevc)
+ V..... hevc_nvenc           NVIDIA NVENC hevc encoder (codec hevc)
+ V....D libsvtav1            SVT-AV1(Scalable Video Technology for AV1) encoder (codec av1)
+ V..... av1_qsv              AV1 (Intel Quick Sync Video acceleration) (codec av1)
 `)
 
-	available := parseAvailableH264Encoders(output)
-	for _, name := range []string{"h264_nvenc", "h264_amf", "h264_qsv"} {
+	available := parseAvailableHwEncoders(output)
+	for _, name := range []string{"h264_nvenc", "h264_amf", "h264_qsv", "hevc_nvenc", "av1_qsv"} {
 		if !available[name] {
 			t.Fatalf("available[%q] = false, want true", name)
 		}
 	}
-	for _, name := range []string{"libx264", "h264_audio_name", "wrapped_description"} {
+	for _, name := range []string{"libx264", "libx265", "libsvtav1", "h264_audio_name", "wrapped_description"} {
 		if available[name] {
 			t.Fatalf("available[%q] = true, want false", name)
 		}
@@ -113,3 +117,33 @@ func TestReportUnconfiguredEncodersDoesNotUseProgress(t *testing.T) {
 		t.Fatalf("reported %d messages, want 0: %v", len(messages), messages)
 	}
 }
+
+func TestFallbackModeSkipsFailedModes(t *testing.T) {
+	cam := DetectedCamera{
+		PixFmt: "mjpeg",
+		Size:   "1280x720",
+		Fps:    60,
+		modes: []cameraMode{
+			{pixFmt: "mjpeg", width: 1280, height: 720, fps: 60},
+			{pixFmt: "yuyv422", width: 1280, height: 720, fps: 10},
+		},
+	}
+
+	tried := map[string]bool{cam.Mode(): true}
+	if !cam.FallbackMode(nil, tried) {
+		t.Fatal("FallbackMode() = false, want true")
+	}
+	if cam.PixFmt != "yuyv422" || cam.Size != "1280x720" || cam.Fps != 10 {
+		t.Fatalf("fallback = %s %s@%d, want yuyv422 1280x720@10", cam.PixFmt, cam.Size, cam.Fps)
+	}
+	tried[cam.Mode()] = true
+	if cam.FallbackMode(nil, tried) {
+		t.Fatalf("second FallbackMode() = true, want false with no modes left")
+	}
+	if len(cam.modes) != 2 {
+		t.Fatalf("the failed modes were dropped: %v", cam.modes)
+	}
+	if cam.PixFmt != "yuyv422" {
+		t.Fatalf("camera changed to %s after exhausting modes", cam.PixFmt)
+	}
+}
@@ -0,0 +1,212 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// Calibration records every camera filming the calibration page, whose
+// screen flashes white at the start of each second. The trim keeps the end of
+// each recording, so what matters is how long before the end of its file a
+// camera shows the last flash: a camera that lags shows it closer to the end.
+const (
+	calibrationSuffix = "calibration"
+	// calibrationMinContrast is the least brightness difference (0-255) between
+	// a flash and the clock for the flashes to be trusted.
+	calibrationMinContrast = 20
+)
+
+// calibrating is set while the calibration records the cameras.
+var calibrating int32
+
+// measureFlashes returns the times of the flashes seen in a recording and the
+// time of its last frame, in seconds. Replaced in tests.
+var measureFlashes = runFlashProbe
+
+func runFlashProbe(path string) ([]float64, float64, error) {
+	cmd := jobutil.Command(config.GetFFmpegPath(), "-hide_banner", "-nostats", "-i", path,
+		"-vf", "signalstats,metadata=print:key=lavfi.signalstats.YAVG:file=-",
+		"-an", "-f", "null", "-")
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseFlashes(out.String())
+}
+
+// parseFlashes finds the frames where the brightness printed by the
+// signalstats filter rises past the middle of its range.
+func parseFlashes(output string) ([]float64, float64, error) {
+	var times, levels []float64
+	var pts float64
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "frame:") {
+			for _, field := range strings.Fields(line) {
+				if strings.HasPrefix(field, "pts_time:") {
+					pts, _ = strconv.ParseFloat(strings.TrimPrefix(field, "pts_time:"), 64)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "lavfi.signalstats.YAVG=") {
+			level, err := strconv.ParseFloat(strings.TrimPrefix(line, "lavfi.signalstats.YAVG="), 64)
+			if err != nil {
+				continue
+			}
+			times = append(times, pts)
+			levels = append(levels, level)
+		}
+	}
+	if len(levels) == 0 {
+		return nil, 0, fmt.Errorf("no frames")
+	}
+	darkest, brightest := levels[0], levels[0]
+	for _, level := range levels {
+		darkest = math.Min(darkest, level)
+		brightest = math.Max(brightest, level)
+	}
+	if brightest-darkest < calibrationMinContrast {
+		return nil, times[len(times)-1], fmt.Errorf("no flashes seen, is the camera pointed at the calibration page?")
+	}
+	middle := (darkest + brightest) / 2
+	var flashes []float64
+	for i := 1; i < len(levels); i++ {
+		if levels[i] >= middle && levels[i-1] < middle {
+			flashes = append(flashes, times[i])
+		}
+	}
+	return flashes, times[len(times)-1], nil
+}
+
+// calibrationOffsets turns the time between the last flash and the end of
+// each recording (seconds, by camera) into the delay of each camera behind
+// the fastest one, in milliseconds. Flashes are a second apart, so delays are
+// taken within half a second of the first camera.
+func calibrationOffsets(lastFlashToEnd map[int]float64) map[int]int {
+	cameras := make([]int, 0, len(lastFlashToEnd))
+	for camera := range lastFlashToEnd {
+		cameras = append(cameras, camera)
+	}
+	if len(cameras) == 0 {
+		return nil
+	}
+	sort.Ints(cameras)
+	reference := lastFlashToEnd[cameras[0]]
+	delays := make(map[int]int, len(cameras))
+	fastest := 0
+	for _, camera := range cameras {
+		delay := int(math.Round((reference - lastFlashToEnd[camera]) * 1000))
+		delay = ((delay+500)%1000+1000)%1000 - 500
+		delays[camera] = delay
+		if delay < fastest {
+			fastest = delay
+		}
+	}
+	for camera := range delays {
+		delays[camera] -= fastest
+	}
+	return delays
+}
+
+// CalibrateCameras records all cameras for httpServer.CalibrationSeconds
+// while they film the calibration page and returns the delay of each camera
+// behind the fastest one, in milliseconds by camera number.
+func CalibrateCameras() (map[int]int, error) {
+	if config.NoVideo {
+		return nil, fmt.Errorf("calibration needs the cameras, video is disabled")
+	}
+	if IsBusy() || !atomic.CompareAndSwapInt32(&calibrating, 0, 1) {
+		return nil, fmt.Errorf("cameras are busy, calibrate between attempts")
+	}
+	defer atomic.StoreInt32(&calibrating, 0)
+	cameras := config.GetCameraConfigs()
+	if len(cameras) < 2 {
+		return nil, fmt.Errorf("calibration needs at least two cameras")
+	}
+	if err := os.MkdirAll(config.GetRecordingDir(), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	if StopPreBuffers() {
+		defer resumePreBuffers()
+	}
+
+	started := time.Now()
+	var cmds []*exec.Cmd
+	var stdins []*os.File
+	var fileNames []string
+	var cameraNumbers []int
+	for i, camera := range cameras {
+		cameraNumber := i + 1
+		fileName := filepath.Join(config.GetRecordingDir(), fmt.Sprintf("%s_Camera%d_%d.mkv", calibrationSuffix, cameraNumber, started.Unix()))
+		cmd, stdin, err := startCameraRecording(cameraNumber, buildRecordingArgs(fileName, camera), nil)
+		if err != nil {
+			logging.ErrorLogger.Printf("Camera %d is left out of the calibration: %v", cameraNumber, err)
+			continue
+		}
+		cmds = append(cmds, cmd)
+		stdins = append(stdins, stdin)
+		fileNames = append(fileNames, fileName)
+		cameraNumbers = append(cameraNumbers, cameraNumber)
+	}
+	defer func() {
+		for _, fileName := range fileNames {
+			os.Remove(fileName)
+		}
+	}()
+	httpServer.SendStatus(httpServer.Recording, "Calibrating cameras")
+	time.Sleep(httpServer.CalibrationSeconds * time.Second)
+	stopRecorders(cmds, stdins, cameraNumbers)
+
+	lastFlashToEnd := make(map[int]float64)
+	var failures []string
+	for i, fileName := range fileNames {
+		flashes, end, err := measureFlashes(fileName)
+		if err == nil && len(flashes) < 2 {
+			err = fmt.Errorf("only %d flash(es) seen", len(flashes))
+		}
+		if err != nil {
+			logging.WarningLogger.Printf("Calibration of Camera %d failed: %v", cameraNumbers[i], err)
+			failures = append(failures, fmt.Sprintf("Camera %d: %v", cameraNumbers[i], err))
+			continue
+		}
+		lastFlashToEnd[cameraNumbers[i]] = end - flashes[len(flashes)-1]
+	}
+	if len(lastFlashToEnd) < 2 {
+		httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Calibration failed (%s)", strings.Join(failures, "; ")))
+		return nil, fmt.Errorf("fewer than two cameras saw the calibration flashes: %s", strings.Join(failures, "; "))
+	}
+
+	offsets := calibrationOffsets(lastFlashToEnd)
+	var report []string
+	for _, cameraNumber := range cameraNumbers {
+		if offset, ok := offsets[cameraNumber]; ok {
+			report = append(report, fmt.Sprintf("Camera %d +%dms", cameraNumber, offset))
+		}
+	}
+	message := "Calibration: " + strings.Join(report, ", ")
+	if len(failures) > 0 {
+		message += fmt.Sprintf(" (Warning: %s)", strings.Join(failures, "; "))
+	}
+	logging.InfoLogger.Println(message)
+	httpServer.SendStatus(httpServer.Ready, message)
+	return offsets, nil
+}
@@ -0,0 +1,46 @@
+package recording
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// signalstatsOutput builds what ffmpeg prints for a 10 fps recording of the
+// calibration page, flashing during the frames in flashFrames.
+func signalstatsOutput(frames int, flashFrames ...int) string {
+	var b strings.Builder
+	for i := 0; i < frames; i++ {
+		level := 16.0
+		for _, flash := range flashFrames {
+			if i == flash {
+				level = 200
+			}
+		}
+		fmt.Fprintf(&b, "frame:%d    pts:%d    pts_time:%.1f\nlavfi.signalstats.YAVG=%.1f\n", i, i*100, float64(i)/10, level)
+	}
+	return b.String()
+}
+
+func TestCalibrationMeasuresEachCameraDelayFromTheFlashes(t *testing.T) {
+	flashes, end, err := parseFlashes(signalstatsOutput(40, 3, 13, 23, 33))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flashes) != 4 || flashes[3] != 3.3 || end != 3.9 {
+		t.Fatalf("parseFlashes() = %v, %v", flashes, end)
+	}
+	if _, _, err := parseFlashes(signalstatsOutput(40)); err == nil {
+		t.Fatalf("a recording without flashes was accepted")
+	}
+
+	// Camera 2 shows the last flash 120ms closer to the end than camera 1:
+	// it lags by 120ms. Camera 3 leads camera 1 by 40ms, across a second.
+	offsets := calibrationOffsets(map[int]float64{1: 0.6, 2: 0.48, 3: 0.64 - 1})
+	want := map[int]int{1: 40, 2: 160, 3: 0}
+	for camera, offset := range want {
+		if offsets[camera] != offset {
+			t.Fatalf("calibrationOffsets() = %v, want %v", offsets, want)
+		}
+	}
+}
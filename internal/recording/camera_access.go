@@ -0,0 +1,142 @@
+package recording
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/owlcms/replays/internal/jobutil"
+)
+
+// CameraIssue is a problem that keeps a local camera from being recorded,
+// with the commands that fix it.
+type CameraIssue struct {
+	Device  string
+	Problem string
+	Fix     string
+}
+
+// uvcQuirkFixBandwidth makes uvcvideo ask for the bandwidth the stream needs
+// instead of what the camera claims; without it a second USB camera on the
+// same controller often fails with "No space left on device".
+const uvcQuirkFixBandwidth = 0x80
+
+// videoDevice is what is known about one /dev/video* node.
+type videoDevice struct {
+	path       string
+	group      string // group owning the node
+	accessible bool   // this process can open it for reading and writing
+	userInList bool   // the user is a member of group in /etc/group
+	owners     []jobutil.PortProcess
+}
+
+// cameraAccessFacts is what is known about the local cameras of this machine.
+type cameraAccessFacts struct {
+	user       string
+	devices    []videoDevice
+	uvcLoaded  bool
+	uvcQuirks  int
+	uvcCameras int // video nodes driven by uvcvideo
+}
+
+const udevRuleFix = `echo 'SUBSYSTEM=="video4linux", GROUP="video", MODE="0660"' | sudo tee /etc/udev/rules.d/99-replays-cameras.rules
+sudo udevadm control --reload-rules && sudo udevadm trigger`
+
+// diagnoseCameraAccess turns the facts about the cameras into the problems
+// found, none when the cameras can be recorded.
+func diagnoseCameraAccess(facts cameraAccessFacts) []CameraIssue {
+	var issues []CameraIssue
+	if len(facts.devices) == 0 {
+		fix := "Check that the camera is plugged in and seen by the system: lsusb"
+		if !facts.uvcLoaded {
+			fix = "sudo modprobe uvcvideo\n" + fix
+		}
+		return []CameraIssue{{Problem: "No /dev/video* device found", Fix: fix}}
+	}
+
+	for _, device := range facts.devices {
+		switch {
+		case device.accessible:
+		case device.group == "" || device.group == "root":
+			issues = append(issues, CameraIssue{
+				Device:  device.path,
+				Problem: fmt.Sprintf("%s is only accessible to root", device.path),
+				Fix:     udevRuleFix + fmt.Sprintf("\nsudo usermod -aG video %s\nThen log out and back in.", facts.user),
+			})
+		case device.userInList:
+			issues = append(issues, CameraIssue{
+				Device:  device.path,
+				Problem: fmt.Sprintf("%s was added to group %s, but this session started before", facts.user, device.group),
+				Fix:     "Log out and back in (or restart) so that the new group is used.",
+			})
+		default:
+			issues = append(issues, CameraIssue{
+				Device:  device.path,
+				Problem: fmt.Sprintf("%s is not a member of group %s, which owns %s", facts.user, device.group, device.path),
+				Fix:     fmt.Sprintf("sudo usermod -aG %s %s\nThen log out and back in.", device.group, facts.user),
+			})
+		}
+		if len(device.owners) > 0 {
+			var kills []string
+			for _, owner := range device.owners {
+				if owner.PID > 0 {
+					kills = append(kills, strconv.Itoa(owner.PID))
+				}
+			}
+			fix := "Close the program using the camera"
+			if len(kills) > 0 {
+				fix += ", or: kill " + strings.Join(kills, " ")
+			}
+			issues = append(issues, CameraIssue{
+				Device:  device.path,
+				Problem: fmt.Sprintf("%s is in use by %s", device.path, jobutil.DescribePortProcesses(device.owners)),
+				Fix:     fix,
+			})
+		}
+	}
+
+	if facts.uvcLoaded && facts.uvcCameras > 1 && facts.uvcQuirks&uvcQuirkFixBandwidth == 0 {
+		quirks := facts.uvcQuirks | uvcQuirkFixBandwidth
+		issues = append(issues, CameraIssue{
+			Problem: "Several USB cameras without the uvcvideo bandwidth quirk: the second one may fail with \"No space left on device\"",
+			Fix: fmt.Sprintf("sudo rmmod uvcvideo && sudo modprobe uvcvideo quirks=%d\necho 'options uvcvideo quirks=%d' | sudo tee /etc/modprobe.d/uvcvideo.conf",
+				quirks, quirks),
+		})
+	}
+	return issues
+}
+
+// FormatCameraIssues describes the problems found for the operator.
+func FormatCameraIssues(issues []CameraIssue) string {
+	if len(issues) == 0 {
+		return "No camera access problem found."
+	}
+	var b strings.Builder
+	for i, issue := range issues {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "Problem: %s\nFix:\n%s\n", issue.Problem, issue.Fix)
+	}
+	return b.String()
+}
+
+// CameraFixCommands returns the fixes of the problems found, one after the
+// other, to be pasted in a terminal.
+func CameraFixCommands(issues []CameraIssue) string {
+	var fixes []string
+	for _, issue := range issues {
+		fixes = append(fixes, issue.Fix)
+	}
+	return strings.Join(fixes, "\n")
+}
+
+// parseUvcQuirks reads the quirks parameter of uvcvideo; -1 (shown as an
+// unsigned number) means none were set.
+func parseUvcQuirks(value string) int {
+	quirks, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || quirks < 0 || quirks >= 0xFFFFFFFF {
+		return 0
+	}
+	return int(quirks)
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package recording
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// DiagnoseCameraAccess checks that the local cameras can be recorded: the
+// permissions of the /dev/video* nodes, the programs holding them, and the
+// uvcvideo settings needed for several USB cameras.
+func DiagnoseCameraAccess() []CameraIssue {
+	facts := cameraAccessFacts{user: os.Getenv("USER")}
+	current, err := user.Current()
+	var groupIDs []string
+	if err == nil {
+		facts.user = current.Username
+		groupIDs, _ = current.GroupIds()
+	}
+
+	paths, _ := filepath.Glob("/dev/video*")
+	uvcInterfaces := map[string]bool{}
+	for _, path := range paths {
+		device := videoDevice{path: path, accessible: syscall.Access(path, 0x6) == nil} // R_OK|W_OK
+		if info, err := os.Stat(path); err == nil {
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+				gid := strconv.Itoa(int(stat.Gid))
+				device.group = gid
+				if group, err := user.LookupGroupId(gid); err == nil {
+					device.group = group.Name
+				}
+				for _, id := range groupIDs {
+					device.userInList = device.userInList || id == gid
+				}
+			}
+		}
+		if owners, err := jobutil.FindDeviceOwners(path, os.Getpid()); err == nil {
+			device.owners = owners
+		}
+		facts.devices = append(facts.devices, device)
+
+		sysDevice := filepath.Join("/sys/class/video4linux", filepath.Base(path), "device")
+		if driver, err := filepath.EvalSymlinks(filepath.Join(sysDevice, "driver")); err == nil && filepath.Base(driver) == "uvcvideo" {
+			if iface, err := filepath.EvalSymlinks(sysDevice); err == nil {
+				uvcInterfaces[iface] = true
+			}
+		}
+	}
+	facts.uvcCameras = len(uvcInterfaces)
+
+	if _, err := os.Stat("/sys/module/uvcvideo"); err == nil {
+		facts.uvcLoaded = true
+		if quirks, err := os.ReadFile("/sys/module/uvcvideo/parameters/quirks"); err == nil {
+			facts.uvcQuirks = parseUvcQuirks(string(quirks))
+		}
+	}
+
+	issues := diagnoseCameraAccess(facts)
+	for _, issue := range issues {
+		logging.WarningLogger.Printf("Camera access: %s", issue.Problem)
+	}
+	return issues
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package recording
+
+// DiagnoseCameraAccess only checks Linux cameras; elsewhere the system asks
+// for camera permissions itself.
+func DiagnoseCameraAccess() []CameraIssue {
+	return nil
+}
@@ -0,0 +1,48 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/owlcms/replays/internal/jobutil"
+)
+
+func TestDiagnoseCameraAccess(t *testing.T) {
+	facts := cameraAccessFacts{
+		user: "jane",
+		devices: []videoDevice{
+			{path: "/dev/video0", group: "video", accessible: true},
+			{path: "/dev/video2", group: "video"},
+			{path: "/dev/video4", group: "video", userInList: true},
+			{path: "/dev/video6", group: "root"},
+			{path: "/dev/video8", group: "video", accessible: true, owners: []jobutil.PortProcess{{PID: 4242, Command: "cheese"}}},
+		},
+		uvcLoaded:  true,
+		uvcCameras: 2,
+	}
+	issues := diagnoseCameraAccess(facts)
+	wants := []struct{ device, fix string }{
+		{"/dev/video2", "sudo usermod -aG video jane"},
+		{"/dev/video4", "Log out and back in"},
+		{"/dev/video6", "99-replays-cameras.rules"},
+		{"/dev/video8", "kill 4242"},
+		{"", "quirks=128"},
+	}
+	if len(issues) != len(wants) {
+		t.Fatalf("got %d issues, want %d:\n%s", len(issues), len(wants), FormatCameraIssues(issues))
+	}
+	for i, want := range wants {
+		if issues[i].Device != want.device || !strings.Contains(issues[i].Fix, want.fix) {
+			t.Errorf("issue %d = %+v, want device %q and a fix with %q", i, issues[i], want.device, want.fix)
+		}
+	}
+
+	facts.devices = facts.devices[:1]
+	facts.uvcQuirks = parseUvcQuirks("128\n")
+	if issues := diagnoseCameraAccess(facts); len(issues) != 0 {
+		t.Errorf("got %v, want no issue", issues)
+	}
+	if quirks := parseUvcQuirks("4294967295"); quirks != 0 {
+		t.Errorf("parseUvcQuirks(unset) = %d, want 0", quirks)
+	}
+}
@@ -0,0 +1,148 @@
+package recording
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// clipPullTimeout bounds the download of a clip from the camera node.
+const clipPullTimeout = 2 * time.Minute
+
+// streamPort returns the UDP port a camera stream is received on, which is
+// also the port the camera node names its local recording after. SRT
+// streams are served on that port + config.SRTPortOffset.
+func streamPort(camera config.CameraConfiguration) (int, error) {
+	u, err := url.Parse(camera.FfmpegCamera)
+	if err != nil {
+		return 0, err
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return 0, fmt.Errorf("no port in %s", camera.FfmpegCamera)
+	}
+	if u.Scheme == "srt" {
+		port -= config.SRTPortOffset
+	}
+	return port, nil
+}
+
+// clipCutArgs builds the ffmpeg arguments that cut [from, to] out of a clip
+// whose first frame was captured at clipStart.
+func clipCutArgs(clipFile, finalFileName string, clipStart, from, to time.Time) []string {
+	offset := from.Sub(clipStart)
+	if offset < 0 {
+		offset = 0
+	}
+	return []string{
+		"-y",
+		"-ss", fmt.Sprintf("%.3f", offset.Seconds()),
+		"-i", clipFile,
+		"-t", fmt.Sprintf("%.3f", to.Sub(from).Seconds()),
+		"-c", "copy",
+		"-avoid_negative_ts", "make_zero",
+		"-movflags", "+faststart",
+		finalFileName,
+	}
+}
+
+// pullClip fetches the footage of [from, to] from the camera node's local
+// recording and cuts it into finalFileName. It is the fallback when the
+// replay recorded here is missing or cannot be trimmed.
+func pullClip(cameraNumber int, camera config.CameraConfiguration, from, to time.Time, finalFileName string) error {
+	source := config.GetClipSource()
+	if source == "" {
+		return fmt.Errorf("no clipSource configured")
+	}
+	port, err := streamPort(camera)
+	if err != nil {
+		return err
+	}
+
+	clipURL := fmt.Sprintf("http://%s/clip?port=%d&from=%d&to=%d", source, port, from.UnixMilli(), to.UnixMilli())
+	logging.InfoLogger.Printf("Camera %d: pulling clip from %s", cameraNumber, clipURL)
+	client := http.Client{Timeout: clipPullTimeout}
+	resp, err := client.Get(clipURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	startMs, err := strconv.ParseInt(resp.Header.Get("X-Clip-Start"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("camera node did not give the clip start time")
+	}
+
+	clipFile := filepath.Join(config.GetRecordingDir(), fmt.Sprintf("pulled_Camera%d_%d.ts", cameraNumber, from.UnixMilli()))
+	file, err := os.Create(clipFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(clipFile)
+	_, err = io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download interrupted: %w", err)
+	}
+
+	cmd := CreateFfmpegCmd(clipCutArgs(clipFile, finalFileName, time.UnixMilli(startMs), from, to), "pullclip", "error")
+	logFile, output := captureTrimOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		if output != nil {
+			return fmt.Errorf("cutting pulled clip: %w: %s", err, strings.TrimSpace(output.String()))
+		}
+		return fmt.Errorf("cutting pulled clip: %w, see %s", err, logFile)
+	}
+	return nil
+}
+
+// recoverFromCameraNode replaces a replay that is missing, broken or could
+// not be trimmed with the same moment pulled from the camera node, and
+// publishes it. The window ends when the recording was stopped (stopTimeMs)
+// and has the length the trim would have kept, or starts with the attempt
+// when the whole file was kept. Without either, the moment is unknown and
+// nothing is pulled.
+func recoverFromCameraNode(cameraNumber int, camera config.CameraConfiguration, keepFromEndMs, startTimeMs, stopTimeMs int64, sessionDir, finalFileName string) bool {
+	if config.GetClipSource() == "" || config.NoVideo {
+		return false
+	}
+	if keepFromEndMs <= 0 && startTimeMs <= 0 {
+		logging.WarningLogger.Printf("Camera %d: the attempt has no start time, its replay cannot be pulled from the camera node", cameraNumber)
+		return false
+	}
+	to := time.UnixMilli(stopTimeMs)
+	from := to.Add(-time.Duration(keepFromEndMs) * time.Millisecond)
+	if keepFromEndMs <= 0 {
+		from = time.UnixMilli(startTimeMs)
+	}
+	if err := pullClip(cameraNumber, camera, from, to, finalFileName); err != nil {
+		logging.ErrorLogger.Printf("Camera %d: could not recover the replay from the camera node: %v", cameraNumber, err)
+		return false
+	}
+
+	durationMs := to.Sub(from).Milliseconds()
+	if probe, ok := probeTrimmedVideo(finalFileName); ok && probe.durationMs > 0 {
+		durationMs = probe.durationMs
+	}
+	if err := publishReplay(cameraNumber, sessionDir, finalFileName, durationMs); err != nil {
+		logging.ErrorLogger.Printf("Failed to publish replay state for Camera %d: %v", cameraNumber, err)
+	}
+	logging.InfoLogger.Printf("Camera %d: replay recovered from the camera node into %s", cameraNumber, finalFileName)
+	httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Warning: Camera %d replay was recovered from the camera node's local recording", cameraNumber))
+	return true
+}
@@ -0,0 +1,65 @@
+package recording
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestClipCutArgsCutsWindowFromClipStart(t *testing.T) {
+	clipStart := time.UnixMilli(1_000_000)
+	from := clipStart.Add(12500 * time.Millisecond)
+	to := from.Add(20 * time.Second)
+
+	args := strings.Join(clipCutArgs("in.ts", "out.mp4", clipStart, from, to), " ")
+	if !strings.Contains(args, "-ss 12.500 -i in.ts -t 20.000") {
+		t.Fatalf("args = %q, want a 20s cut starting 12.5s into the clip", args)
+	}
+
+	args = strings.Join(clipCutArgs("in.ts", "out.mp4", from, clipStart, to), " ")
+	if !strings.Contains(args, "-ss 0.000") {
+		t.Fatalf("args = %q, want the cut to start at the beginning of a late clip", args)
+	}
+}
+
+func TestStreamPort(t *testing.T) {
+	port, err := streamPort(config.CameraConfiguration{FfmpegCamera: "udp://239.255.0.1:9003"})
+	if err != nil || port != 9003 {
+		t.Fatalf("streamPort() = %d, %v; want 9003", port, err)
+	}
+	if _, err := streamPort(config.CameraConfiguration{FfmpegCamera: "/dev/video0"}); err == nil {
+		t.Fatal("streamPort() accepted a source without a port")
+	}
+}
+
+func TestRecoverFromCameraNodeAsksForTheAttemptWindow(t *testing.T) {
+	var asked []string
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asked = append(asked, r.URL.RawQuery)
+		http.Error(w, "no recording", http.StatusNotFound)
+	}))
+	defer node.Close()
+	oldSource := config.ClipSource
+	config.ClipSource = strings.TrimPrefix(node.URL, "http://")
+	t.Cleanup(func() { config.ClipSource = oldSource })
+	camera := config.CameraConfiguration{FfmpegCamera: "udp://239.255.0.1:9003"}
+	replay := filepath.Join(t.TempDir(), "replay.mp4")
+
+	if recoverFromCameraNode(1, camera, 0, 0, 60_000, "A", replay) {
+		t.Fatal("recovered a replay whose moment is unknown")
+	}
+	if len(asked) != 0 {
+		t.Fatalf("asked the node for an unknown moment: %v", asked)
+	}
+	if recoverFromCameraNode(1, camera, 20_000, 0, 60_000, "A", replay) {
+		t.Fatal("recovered a replay the node does not have")
+	}
+	if len(asked) != 1 || asked[0] != "port=9003&from=40000&to=60000" {
+		t.Fatalf("asked the node for %v, want the last 20s before the stop", asked)
+	}
+}
@@ -0,0 +1,185 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// contextInsetWidth is the width of the inset, as a fraction of the replay.
+const contextInsetWidth = 0.3
+
+// contextInsetArgs builds the ffmpeg arguments that show the context replay
+// (a screen capture, e.g. the scoreboard) in the bottom right corner of the
+// replay. Both clips end when the recordings were stopped, so the context
+// clip is shifted by offset (replay duration minus context duration) to line
+// their ends up.
+func contextInsetArgs(replayFile, contextFile, outputFile string, offset time.Duration) []string {
+	args := []string{"-y", "-i", replayFile}
+	if offset > 0 {
+		args = append(args, "-itsoffset", fmt.Sprintf("%.3f", offset.Seconds()))
+	} else if offset < 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", (-offset).Seconds()))
+	}
+	filter := fmt.Sprintf(
+		"[1:v][0:v]scale2ref=w=trunc(main_w*%.2f/2)*2:h=trunc(ow/a/2)*2[inset][base];"+
+			"[base][inset]overlay=W-w-16:H-h-16:eof_action=pass[v]", contextInsetWidth)
+	args = append(args,
+		"-i", contextFile,
+		"-filter_complex", filter,
+		"-map", "[v]", "-map", "0:a?",
+		"-c:v", "libx264",
+	)
+	args = append(args, config.X264Args(1)...)
+	return append(args,
+		"-crf", "20", "-pix_fmt", "yuv420p",
+		"-c:a", "copy",
+		"-movflags", "+faststart",
+		"-f", replayMuxer(replayFile),
+		outputFile,
+	)
+}
+
+// replayMuxer is the ffmpeg format of a replay file, given by its extension.
+func replayMuxer(replayFile string) string {
+	switch strings.ToLower(filepath.Ext(replayFile)) {
+	case ".mov":
+		return "mov"
+	case ".mkv":
+		return "matroska"
+	}
+	return "mp4"
+}
+
+// addContextInsets makes copies of the replays of the other cameras with the
+// context camera's replay inset, in the background once the replays are
+// ready. The copies are stored next to the replays (<replay>_inset.mp4); the
+// published replays are left as they are. finalFileNames is indexed like the
+// recordings; the replay of a failed trim is not stored and is skipped.
+func addContextInsets(finalFileNames []string, sessionDir string) {
+	contextCamera := config.GetContextCamera()
+	if contextCamera <= 0 || config.NoVideo {
+		return
+	}
+	contextReplay := ""
+	for i, name := range finalFileNames {
+		if name != "" && recordingCameraNumber(i) == contextCamera {
+			contextReplay = filepath.Base(name)
+		}
+	}
+	if contextReplay == "" {
+		logging.WarningLogger.Printf("Context camera %d has no replay for this attempt; no inset added", contextCamera)
+		return
+	}
+	replays := make(map[int]string)
+	for i, name := range finalFileNames {
+		cameraNumber := recordingCameraNumber(i)
+		if name == "" || cameraNumber == contextCamera || cameraNumber == config.ScoreboardCameraNumber {
+			continue
+		}
+		replays[cameraNumber] = filepath.Base(name)
+	}
+	if len(replays) == 0 {
+		return
+	}
+	if config.DeferRecodes {
+		// Queued after the re-encodes of the replays.
+		deferRecode(fmt.Sprintf("context insets of %s", contextReplay), func() error {
+			return insetAll(sessionDir, contextReplay, replays, runIdle)
+		})
+		return
+	}
+	go func() {
+		// One background encode at a time, slow motion included.
+		slowMotionMu.Lock()
+		defer slowMotionMu.Unlock()
+		insetAll(sessionDir, contextReplay, replays, (*exec.Cmd).Run)
+	}()
+}
+
+// insetAll makes and stores the inset copies of the replays by camera,
+// running ffmpeg with run. It stops at the first errPreempted.
+func insetAll(sessionDir, contextReplay string, replays map[int]string, run func(*exec.Cmd) error) error {
+	workDir, err := sessionWorkDir(sessionDir)
+	if err != nil {
+		logging.ErrorLogger.Printf("Cannot add the context insets: %v", err)
+		return nil
+	}
+	// The replays were already handed to storage, which may keep no local
+	// copy of them.
+	contextFile, cleanup, err := localReplay(sessionDir, contextReplay, workDir)
+	if err != nil {
+		logging.WarningLogger.Printf("Context replay %s is missing: %v", contextReplay, err)
+		return nil
+	}
+	defer cleanup()
+	contextProbe, ok := probeTrimmedVideo(contextFile)
+	if !ok {
+		return nil
+	}
+
+	cameraNumbers := make([]int, 0, len(replays))
+	for cameraNumber := range replays {
+		cameraNumbers = append(cameraNumbers, cameraNumber)
+	}
+	sort.Ints(cameraNumbers)
+	for _, cameraNumber := range cameraNumbers {
+		replay := replays[cameraNumber]
+		if _, err := storage.Current().Stat(storage.Join(sessionDir, replay)); err != nil {
+			continue
+		}
+		if err := insetContext(sessionDir, workDir, replay, contextFile, contextProbe.durationMs, run); errors.Is(err, errPreempted) {
+			return err
+		} else if err != nil {
+			logging.ErrorLogger.Printf("Camera %d: could not add the context inset to %s: %v", cameraNumber, replay, err)
+			continue
+		}
+		logging.InfoLogger.Printf("Camera %d: copy of %s with the context inset ready", cameraNumber, replay)
+	}
+	return nil
+}
+
+// insetContext writes and stores the inset copy of a stored replay. ffmpeg
+// writes to a temporary name, so that a partial copy is never listed.
+func insetContext(sessionDir, workDir, replay, contextFile string, contextDurationMs int64, run func(*exec.Cmd) error) error {
+	replayFile, cleanup, err := localReplay(sessionDir, replay, workDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	replayProbe, ok := probeTrimmedVideo(replayFile)
+	if !ok {
+		return fmt.Errorf("cannot probe %s", replay)
+	}
+	offset := time.Duration(replayProbe.durationMs-contextDurationMs) * time.Millisecond
+	output := filepath.Join(workDir, httpServer.InsetName(replay))
+	// Not .mp4, so that the replay list does not show it while it is written.
+	tempFile := output + ".tmp"
+	defer os.Remove(tempFile)
+
+	cmd := CreateFfmpegCmd(contextInsetArgs(replayFile, contextFile, tempFile, offset), "inset", "error")
+	logFile, stderr := captureTrimOutput(cmd)
+	if err := run(cmd); err != nil {
+		if errors.Is(err, errPreempted) {
+			return err
+		}
+		if stderr != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("%w, see %s", err, logFile)
+	}
+	if err := os.Rename(tempFile, output); err != nil {
+		return err
+	}
+	return storeSessionFile(sessionDir, output)
+}
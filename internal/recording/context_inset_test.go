@@ -0,0 +1,25 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextInsetArgsAlignsClipEnds(t *testing.T) {
+	later := strings.Join(contextInsetArgs("replay.mp4", "context.mp4", "out.tmp", 1500*time.Millisecond), " ")
+	if !strings.Contains(later, "-itsoffset 1.500 -i context.mp4") {
+		t.Fatalf("a shorter context clip must start later: %s", later)
+	}
+	earlier := strings.Join(contextInsetArgs("replay.mp4", "context.mp4", "out.tmp", -250*time.Millisecond), " ")
+	if !strings.Contains(earlier, "-ss 0.250 -i context.mp4") {
+		t.Fatalf("a longer context clip must skip its start: %s", earlier)
+	}
+	same := strings.Join(contextInsetArgs("replay.mp4", "context.mp4", "out.tmp", 0), " ")
+	if strings.Contains(same, "-itsoffset") || strings.Contains(same, "-ss") {
+		t.Fatalf("no shift expected: %s", same)
+	}
+	if !strings.HasSuffix(same, "-f mp4 out.tmp") {
+		t.Fatalf("output must be forced to mp4: %s", same)
+	}
+}
@@ -0,0 +1,230 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// A multicast camera is copy-safe when its H.264 can always be cut with
+// -c copy: no B-frames (every GOP is closed), keyframes at a short constant
+// interval, and the same stream parameters for every attempt. Such cameras
+// are never re-encoded during the trim, even when the cut falls a keyframe
+// early, which keeps the trim near-instant.
+const (
+	copySafeProbeSeconds = 6
+	copySafeMaxGOP       = 2.0 // seconds between keyframes
+	copySafeGOPJitter    = 0.1 // seconds
+)
+
+// streamParams are the parameters of a video stream that must not change
+// between recordings of a copy-safe camera.
+type streamParams struct {
+	codec   string
+	profile string
+	level   int // times ten, as H.264 levels are written by ffprobe
+	pixFmt  string
+	width   int
+	height  int
+	bFrames int
+}
+
+// copyProbe is what ffprobe reports about the start of a recording.
+type copyProbe struct {
+	params    streamParams
+	keyframes []float64 // times of the keyframes, in seconds
+}
+
+// copySafety is the last judgement of a camera.
+type copySafety struct {
+	source string
+	params streamParams
+	safe   bool
+	reason string
+}
+
+var (
+	copySafeMu sync.Mutex
+	copySafe   = make(map[int]copySafety)
+)
+
+// probeCopySafety reads the stream parameters and keyframes of a recording.
+// Replaced in tests.
+var probeCopySafety = runCopySafetyProbe
+
+func runCopySafetyProbe(path string) (copyProbe, error) {
+	ffprobePath := resolveFFprobePath(config.GetFFmpegPath())
+	if ffprobePath == "" {
+		return copyProbe{}, fmt.Errorf("ffprobe not found")
+	}
+	cmd := jobutil.Command(ffprobePath, "-v", "error", "-select_streams", "v:0",
+		"-read_intervals", fmt.Sprintf("%%+%d", copySafeProbeSeconds),
+		"-show_entries", "stream=codec_name,profile,level,pix_fmt,width,height,has_b_frames:packet=pts_time,flags",
+		"-of", "compact", path)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return copyProbe{}, fmt.Errorf("%w: %s", err, detail)
+		}
+		return copyProbe{}, err
+	}
+	return parseCopyProbe(out.String())
+}
+
+// parseCopyProbe parses the "section|key=value|..." lines of ffprobe -of compact.
+func parseCopyProbe(output string) (copyProbe, error) {
+	var probe copyProbe
+	foundStream := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		values := make(map[string]string)
+		for _, field := range fields[1:] {
+			if key, value, ok := strings.Cut(field, "="); ok {
+				values[key] = value
+			}
+		}
+		switch fields[0] {
+		case "stream":
+			foundStream = true
+			probe.params.codec = values["codec_name"]
+			probe.params.profile = values["profile"]
+			probe.params.level, _ = strconv.Atoi(values["level"])
+			probe.params.pixFmt = values["pix_fmt"]
+			probe.params.width, _ = strconv.Atoi(values["width"])
+			probe.params.height, _ = strconv.Atoi(values["height"])
+			probe.params.bFrames, _ = strconv.Atoi(values["has_b_frames"])
+		case "packet":
+			if !strings.HasPrefix(values["flags"], "K") {
+				continue
+			}
+			if t, err := strconv.ParseFloat(values["pts_time"], 64); err == nil {
+				probe.keyframes = append(probe.keyframes, t)
+			}
+		}
+	}
+	if !foundStream {
+		return copyProbe{}, fmt.Errorf("no video stream")
+	}
+	return probe, nil
+}
+
+// judgeCopySafety returns why a probed recording cannot be cut with -c copy,
+// or "" when it can. previous holds the parameters of the earlier recordings
+// of the camera, nil for the first one.
+func judgeCopySafety(previous *streamParams, probe copyProbe) string {
+	if probe.params.codec != "h264" {
+		return fmt.Sprintf("codec is %s", probe.params.codec)
+	}
+	if probe.params.bFrames > 0 {
+		return "the stream has B-frames (GOPs may be open)"
+	}
+	if len(probe.keyframes) < 2 {
+		return fmt.Sprintf("fewer than two keyframes in %d seconds", copySafeProbeSeconds)
+	}
+	shortest, longest := probe.keyframes[1]-probe.keyframes[0], 0.0
+	for i := 1; i < len(probe.keyframes); i++ {
+		gop := probe.keyframes[i] - probe.keyframes[i-1]
+		if gop < shortest {
+			shortest = gop
+		}
+		if gop > longest {
+			longest = gop
+		}
+	}
+	if longest > copySafeMaxGOP {
+		return fmt.Sprintf("keyframes are %.1fs apart", longest)
+	}
+	if longest-shortest > copySafeGOPJitter {
+		return fmt.Sprintf("keyframes are irregular (%.2fs to %.2fs apart)", shortest, longest)
+	}
+	if previous != nil && *previous != probe.params {
+		return "the stream parameters changed"
+	}
+	return ""
+}
+
+// isMulticastSource reports whether a camera is an MPEG-TS stream received
+// over UDP or SRT.
+func isMulticastSource(camera config.CameraConfiguration) bool {
+	return camera.Format == "mpegts" && (strings.HasPrefix(camera.FfmpegCamera, "udp://") || strings.HasPrefix(camera.FfmpegCamera, "srt://"))
+}
+
+// checkCopySafe probes the recording of a multicast camera and reports
+// whether it can be trimmed without re-encoding. A camera that failed once
+// stays unsafe until its source changes. When the recording cannot be
+// probed, the previous judgement is kept.
+func checkCopySafe(cameraNumber int, camera config.CameraConfiguration, recording string) bool {
+	if !isMulticastSource(camera) || config.NoVideo {
+		return false
+	}
+	copySafeMu.Lock()
+	previous, known := copySafe[cameraNumber]
+	copySafeMu.Unlock()
+	if known && previous.source != camera.FfmpegCamera {
+		known = false
+	}
+	if known && !previous.safe {
+		return false
+	}
+
+	probe, err := probeCopySafety(recording)
+	if err != nil {
+		logging.WarningLogger.Printf("Camera %d: cannot probe %s for copy-safe trimming: %v", cameraNumber, recording, err)
+		return known && previous.safe
+	}
+	var reference *streamParams
+	if known {
+		reference = &previous.params
+	}
+	judgement := copySafety{source: camera.FfmpegCamera, params: probe.params}
+	judgement.reason = judgeCopySafety(reference, probe)
+	judgement.safe = judgement.reason == ""
+	if !known || judgement.safe != previous.safe {
+		if judgement.safe {
+			logging.InfoLogger.Printf("Camera %d: %s %dx%d with closed GOPs, trimming without re-encoding", cameraNumber, probe.params.codec, probe.params.width, probe.params.height)
+		} else {
+			logging.InfoLogger.Printf("Camera %d: not copy-safe, %s", cameraNumber, judgement.reason)
+		}
+		if (judgement.safe || !camera.Recode) && !camera.TrimRecodes() {
+			warnJuryPlayback(cameraNumber, camera, probe.params)
+		}
+	}
+	copySafeMu.Lock()
+	copySafe[cameraNumber] = judgement
+	copySafeMu.Unlock()
+	return judgement.safe
+}
+
+// withRecordedSize fills in the size of a camera that does not set one from
+// its recording, so that replaySize letterboxes only the cameras of another
+// size. The camera is kept as is when the recording cannot be probed.
+func withRecordedSize(cameraNumber int, camera config.CameraConfiguration, recording string) config.CameraConfiguration {
+	if config.ReplaySize == "" || camera.Size != "" || config.NoVideo {
+		return camera
+	}
+	probe, err := probeCopySafety(recording)
+	if err != nil || probe.params.width <= 0 || probe.params.height <= 0 {
+		logging.WarningLogger.Printf("Camera %d: cannot probe the size of %s, letterboxing to %s: %v", cameraNumber, recording, config.ReplaySize, err)
+		return camera
+	}
+	camera.Size = fmt.Sprintf("%dx%d", probe.params.width, probe.params.height)
+	return camera
+}
+
+// warnJuryPlayback warns when the jury devices cannot play the stream copies
+// of a camera, which keep the probed codec, profile and level of its stream.
+func warnJuryPlayback(cameraNumber int, camera config.CameraConfiguration, params streamParams) {
+	container := strings.TrimPrefix(camera.Trim.Extension(), ".")
+	for _, problem := range config.JuryPlaybackProblems(params.codec, params.profile, params.level, container) {
+		logging.WarningLogger.Printf("Camera %d: the jury cannot play the replays (%s %s) on %s; set scale in the trim section of the camera, or lower the resolution or frame rate of the camera",
+			cameraNumber, params.codec, params.profile, problem)
+	}
+}
@@ -0,0 +1,78 @@
+package recording
+
+import (
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+const closedGOPProbe = `stream|codec_name=h264|profile=High|level=40|width=1920|height=1080|pix_fmt=yuv420p|has_b_frames=0
+packet|pts_time=1.400000|flags=K__
+packet|pts_time=1.433333|flags=___
+packet|pts_time=2.400000|flags=K__
+packet|pts_time=3.400000|flags=K__
+`
+
+func TestMulticastCameraWithClosedGOPsIsTrimmedWithoutRecode(t *testing.T) {
+	probe, err := parseCopyProbe(closedGOPProbe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(probe.keyframes) != 3 || probe.params.width != 1920 || probe.params.level != 40 {
+		t.Fatalf("parseCopyProbe() = %+v", probe)
+	}
+	if reason := judgeCopySafety(nil, probe); reason != "" {
+		t.Fatalf("closed 1s GOPs judged unsafe: %s", reason)
+	}
+
+	withBFrames := probe
+	withBFrames.params.bFrames = 2
+	if judgeCopySafety(nil, withBFrames) == "" {
+		t.Fatalf("a stream with B-frames was judged copy-safe")
+	}
+	resized := probe
+	resized.params.width = 1280
+	if judgeCopySafety(&probe.params, resized) == "" {
+		t.Fatalf("a change of stream parameters was not noticed")
+	}
+
+	saved := probeCopySafety
+	t.Cleanup(func() {
+		probeCopySafety = saved
+		copySafe = make(map[int]copySafety)
+	})
+	probeCopySafety = func(string) (copyProbe, error) { return probe, nil }
+	camera := config.CameraConfiguration{FfmpegCamera: "udp://239.255.0.1:9001", Format: "mpegts", Recode: true}
+	if !checkCopySafe(1, camera, "recording.mkv") {
+		t.Fatalf("multicast camera not marked copy-safe")
+	}
+	probeCopySafety = func(string) (copyProbe, error) { return resized, nil }
+	if checkCopySafe(1, camera, "recording.mkv") || checkCopySafe(1, camera, "recording.mkv") {
+		t.Fatalf("camera stayed copy-safe after its stream changed")
+	}
+	if checkCopySafe(2, config.CameraConfiguration{FfmpegCamera: "/dev/video0", Format: "v4l2"}, "recording.mkv") {
+		t.Fatalf("a local camera was marked copy-safe")
+	}
+}
+
+func TestReplaySizeUsesTheProbedSizeOfTheRecording(t *testing.T) {
+	probe, err := parseCopyProbe(closedGOPProbe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := probeCopySafety
+	t.Cleanup(func() {
+		probeCopySafety = saved
+		config.ReplaySize = ""
+	})
+	probeCopySafety = func(string) (copyProbe, error) { return probe, nil }
+
+	config.ReplaySize = "1920x1080"
+	if camera := withRecordedSize(1, config.CameraConfiguration{}, "recording.mkv"); camera.Normalizes() || camera.TrimRecodes() {
+		t.Fatalf("1920x1080 recording letterboxed to the replay size: %+v", camera)
+	}
+	config.ReplaySize = "1280x720"
+	if camera := withRecordedSize(1, config.CameraConfiguration{}, "recording.mkv"); !camera.Normalizes() {
+		t.Fatalf("1920x1080 recording not letterboxed to 1280x720")
+	}
+}
@@ -0,0 +1,42 @@
+package recording
+
+import (
+	"path/filepath"
+	"sync/atomic"
+)
+
+// RecorderState is what the recorder is doing, for remote debugging.
+type RecorderState struct {
+	Recording     bool     `json:"recording"`
+	Trimming      bool     `json:"trimming"`
+	LongRecording bool     `json:"longRecording"`
+	Files         []string `json:"files,omitempty"`   // recordings of the attempt
+	Cameras       []int    `json:"cameras,omitempty"` // camera of each file
+	TrimsWaiting  int      `json:"trimsWaiting"`
+	Deferred      []string `json:"deferred,omitempty"` // re-encodes waiting for the end of the attempt
+}
+
+// DebugState returns a snapshot of the recorder. The recordings of the
+// attempt are read under the lock of the recorders; the flags are read without
+// it, so they may be a moment out of date.
+func DebugState() RecorderState {
+	snapshot := RecorderState{
+		Recording:     Recording,
+		Trimming:      Trimming,
+		LongRecording: IsLongRecording(),
+		TrimsWaiting:  int(atomic.LoadInt64(&trimWaiting)),
+	}
+	recordersMutex.Lock()
+	snapshot.Cameras = append([]int(nil), currentCameras...)
+	for _, name := range currentFileNames {
+		snapshot.Files = append(snapshot.Files, filepath.Base(name))
+	}
+	recordersMutex.Unlock()
+
+	idleMu.Lock()
+	for _, job := range idleJobs {
+		snapshot.Deferred = append(snapshot.Deferred, job.name)
+	}
+	idleMu.Unlock()
+	return snapshot
+}
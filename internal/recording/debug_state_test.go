@@ -0,0 +1,20 @@
+package recording
+
+import "testing"
+
+func TestDebugStateListsTheDeferredRecodes(t *testing.T) {
+	idleMu.Lock()
+	saved := idleJobs
+	idleJobs = []idleJob{{name: "re-encode of a.mp4"}}
+	idleMu.Unlock()
+	defer func() {
+		idleMu.Lock()
+		idleJobs = saved
+		idleMu.Unlock()
+	}()
+
+	snapshot := DebugState()
+	if len(snapshot.Deferred) != 1 || snapshot.Deferred[0] != "re-encode of a.mp4" {
+		t.Fatalf("deferred = %v", snapshot.Deferred)
+	}
+}
@@ -0,0 +1,53 @@
+package recording
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// DeviceBusyError reports a capture device that another process is using.
+// Confirmed is false when the platform can only tell that some application is
+// using a camera, not which one (DirectShow on Windows).
+type DeviceBusyError struct {
+	Device    string
+	Owners    []jobutil.PortProcess
+	Confirmed bool
+}
+
+func (e *DeviceBusyError) Error() string {
+	if e.Confirmed {
+		return fmt.Sprintf("device busy: %s used by %s", e.Device, jobutil.DescribePortProcesses(e.Owners))
+	}
+	return fmt.Sprintf("device may be busy: a camera is in use by %s", jobutil.DescribePortProcesses(e.Owners))
+}
+
+// CheckCameraDevice is the pre-flight check run before the ffmpeg of a local
+// capture device is started, with the ffmpeg input format of the device
+// ("v4l2", "dshow"...). It returns a *DeviceBusyError when the device is held
+// by another program (OBS, Teams, a stuck ffmpeg...). Network sources and
+// lookup failures are not reported.
+func CheckCameraDevice(format, device string) error {
+	var confirmed bool
+	switch strings.ToLower(format) {
+	case "v4l2":
+		confirmed = true
+	case "dshow":
+		confirmed = false
+	default:
+		return nil
+	}
+
+	owners, err := jobutil.FindDeviceOwners(device, os.Getpid())
+	if err != nil {
+		logging.WarningLogger.Printf("Could not check whether %s is in use: %v", device, err)
+		return nil
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	return &DeviceBusyError{Device: device, Owners: owners, Confirmed: confirmed}
+}
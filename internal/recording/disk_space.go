@@ -0,0 +1,107 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/state"
+)
+
+// diskCheckInterval is how often the free space is checked.
+const diskCheckInterval = 30 * time.Second
+
+// freeSpace returns the bytes available on the volume of a directory.
+// Replaced in tests.
+var freeSpace = diskFree
+
+var diskMonitorOnce sync.Once
+
+// StartDiskMonitor checks the free space of the video directory in the
+// background. The operator is warned below config.LowDiskBytes, and attempts
+// are not recorded below config.FullDiskBytes.
+func StartDiskMonitor() {
+	if config.LowDiskBytes <= 0 && config.FullDiskBytes <= 0 {
+		logging.InfoLogger.Println("Disk space is not monitored")
+		return
+	}
+	diskMonitorOnce.Do(func() {
+		go func() {
+			for {
+				checkDiskSpace()
+				time.Sleep(diskCheckInterval)
+			}
+		}()
+	})
+}
+
+// checkDiskSpace measures the free space and, when its level changed, logs
+// it and shows it to the operator. It returns the new level.
+func checkDiskSpace() state.DiskLevel {
+	level, alert := judgeDiskSpace()
+	previous, _ := state.DiskSpace()
+	state.SetDiskSpace(level, alert)
+	if level == previous {
+		return level
+	}
+	code := httpServer.Ready
+	switch level {
+	case state.DiskCritical:
+		logging.ErrorLogger.Println(alert)
+		code = httpServer.Error
+	case state.DiskLow:
+		logging.WarningLogger.Println(alert)
+	default:
+		alert = "Disk space is available again"
+		logging.InfoLogger.Println(alert)
+	}
+	if IsBusy() {
+		// The status of the attempt stays; the banners of the web pages
+		// still follow.
+		httpServer.RefreshStatus()
+	} else {
+		httpServer.SendStatus(code, alert)
+	}
+	return level
+}
+
+// judgeDiskSpace returns the level of the volume of the video directory, and
+// the alert that goes with it. The recording directory only holds the
+// attempt being recorded; a RAM disk is much smaller than the limits, which
+// are meant for the videos kept.
+func judgeDiskSpace() (state.DiskLevel, string) {
+	dir := config.GetVideoDir()
+	if dir == "" {
+		return state.DiskOK, ""
+	}
+	free, err := freeSpace(dir)
+	if err != nil {
+		// Not created yet; nothing is written there either.
+		return state.DiskOK, ""
+	}
+	gb := float64(free) / (1 << 30)
+	switch {
+	case config.FullDiskBytes > 0 && free < uint64(config.FullDiskBytes):
+		return state.DiskCritical, fmt.Sprintf("Disk full: %.1f GB free for %s, attempts are not recorded", gb, dir)
+	case config.LowDiskBytes > 0 && free < uint64(config.LowDiskBytes):
+		return state.DiskLow, fmt.Sprintf("Low disk space: %.1f GB free for %s", gb, dir)
+	}
+	return state.DiskOK, ""
+}
+
+// diskFullError returns why an attempt cannot be recorded when the disk is
+// critically full, measuring the free space again, or nil.
+func diskFullError() error {
+	if config.FullDiskBytes <= 0 || config.NoVideo {
+		return nil
+	}
+	if checkDiskSpace() != state.DiskCritical {
+		return nil
+	}
+	_, alert := state.DiskSpace()
+	return errors.New(alert)
+}
@@ -0,0 +1,64 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/state"
+)
+
+func TestDiskSpaceLevelsAndRefusedRecording(t *testing.T) {
+	savedFree, savedLow, savedFull, savedDir := freeSpace, config.LowDiskBytes, config.FullDiskBytes, config.GetVideoDir()
+	defer func() {
+		freeSpace, config.LowDiskBytes, config.FullDiskBytes = savedFree, savedLow, savedFull
+		config.SetVideoDir(savedDir)
+		config.SetRecordingDir("")
+		state.SetDiskSpace(state.DiskOK, "")
+	}()
+	videoDir, ramDir := t.TempDir(), t.TempDir()
+	config.SetVideoDir(videoDir)
+	config.SetRecordingDir(ramDir)
+	config.LowDiskBytes, config.FullDiskBytes = 10<<30, 2<<30
+	var free uint64
+	freeSpace = func(dir string) (uint64, error) {
+		if dir == ramDir {
+			// A small RAM disk is not held to the limits of the videos.
+			return 1 << 30, nil
+		}
+		return free, nil
+	}
+
+	free = 50 << 30
+	if level := checkDiskSpace(); level != state.DiskOK {
+		t.Fatalf("50 GB free: level %d", level)
+	}
+	if err := diskFullError(); err != nil {
+		t.Fatalf("50 GB free: %v", err)
+	}
+
+	free = 5 << 30
+	if level := checkDiskSpace(); level != state.DiskLow {
+		t.Fatalf("5 GB free: level %d", level)
+	}
+	if _, alert := state.DiskSpace(); !strings.Contains(alert, "Low disk space: 5.0 GB free") {
+		t.Fatalf("5 GB free: alert %q", alert)
+	}
+	if err := diskFullError(); err != nil {
+		t.Fatalf("a low disk refused the recording: %v", err)
+	}
+
+	free = 1 << 30
+	err := diskFullError()
+	if err == nil || !strings.Contains(err.Error(), "attempts are not recorded") {
+		t.Fatalf("1 GB free: %v", err)
+	}
+	if err := StartRecording("DOE_John", "SNATCH", 1); err == nil || Recording {
+		t.Fatalf("recording started on a full disk: %v", err)
+	}
+
+	config.FullDiskBytes = 0
+	if err := diskFullError(); err != nil {
+		t.Fatalf("criticalDiskGB = -1 refused the recording: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package recording
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// DryRunAttempt is the attempt the dry-run report makes up to resolve the
+// file names and the overlays.
+var DryRunAttempt = httpServer.StatusAttemptDetails{
+	AthleteName:   "DOE John",
+	LiftType:      "SNATCH",
+	AttemptNumber: 1,
+	Weight:        100,
+	Session:       "Session_A",
+}
+
+// DryRunReport writes the ffmpeg command lines replays would run for an
+// attempt with the current configuration, camera by camera: recording, then
+// trimming the last keepMs, then the copies made afterwards. Nothing is run.
+// It is meant to be pasted into a support request.
+func DryRunReport(w io.Writer, keepMs int64, now time.Time) {
+	attempt := DryRunAttempt
+	fullName := strings.ReplaceAll(attempt.AthleteName, " ", "_")
+	startTime := now.Add(-time.Duration(keepMs)*time.Millisecond - 5*time.Second).UnixMilli()
+	timestamp := httpServer.ReplayTimestamp(now)
+	sessionDir := httpServer.SessionFolder(attempt.Session)
+	fullSessionDir, ok := storage.Current().LocalPath(sessionDir)
+	if !ok {
+		fullSessionDir = filepath.Join(config.GetVideoDir(), filepath.FromSlash(sessionDir))
+	}
+
+	fmt.Fprintf(w, "Dry run of replays %s, %s\n", config.GetProgramVersion(), now.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "ffmpeg: %s\n", currentFFmpegPath())
+	fmt.Fprintf(w, "Recording directory: %s\n", config.GetRecordingDir())
+	fmt.Fprintf(w, "Video directory: %s (finished videos on %s)\n", config.GetVideoDir(), redactSecrets(fmt.Sprint(storage.Current())))
+	fmt.Fprintf(w, "trimEncoder = %q, trims that re-encode use %s\n", config.TrimEncoder, encoderName(currentTrimEncoder(), "libx264"))
+	fmt.Fprintf(w, "Attempt: %s, %s attempt %d, session %q, keeping the last %.3fs\n",
+		attempt.AthleteName, attempt.LiftType, attempt.AttemptNumber, attempt.Session, float64(keepMs)/1000)
+
+	cameraNumbers := attemptCameraNumbers()
+	if len(cameraNumbers) == 0 {
+		fmt.Fprintln(w, "\nNo camera is configured.")
+		return
+	}
+	for _, cameraNumber := range cameraNumbers {
+		camera := *config.GetCameraConfig(cameraNumber)
+		name := fmt.Sprintf("Camera %d", cameraNumber)
+		if cameraNumber == config.ScoreboardCameraNumber {
+			name = "Attempt board (camera 0)"
+		}
+		fmt.Fprintf(w, "\n== %s: %s ==\n", name, camera.FfmpegCamera)
+
+		recordingFile := filepath.Join(config.GetRecordingDir(), fmt.Sprintf("%s_%s_attempt%d_Camera%d_%d.mkv", fullName, attempt.LiftType, attempt.AttemptNumber, cameraNumber, startTime))
+		if config.PreBuffer > 0 {
+			writeDryRunCommand(w, "Pre-buffer (runs all the time, the attempt is joined from its segments)", buildPreBufferArgs(preBufferDir(cameraNumber), camera))
+		} else {
+			writeDryRunCommand(w, "Recording", buildRecordingArgs(recordingFile, camera))
+		}
+
+		trimmed := camera
+		if config.Overlay {
+			trimmed.Overlay = overlayText(attempt)
+		}
+		if config.ScoreOverlay {
+			seconds := float64(keepMs) / 1000
+			clock := []config.ClockSegment{{From: 0, To: seconds / 2, Remaining: 60, Running: true}, {From: seconds / 2, To: seconds, Remaining: 60 - seconds/2}}
+			trimmed.ScoreFilter = config.ScoreboardFilter(clock, seconds*0.75, []string{"good", "good", "bad"})
+		}
+		if config.Timecode {
+			trimmed.Timecode = time.UnixMilli(now.UnixMilli() - keepMs)
+		}
+		keep := keepMs
+		if trimmed.TrimOffsetMs > 0 && keep > int64(trimmed.TrimOffsetMs) {
+			// The camera shows the platform later than the others.
+			keep -= int64(trimmed.TrimOffsetMs)
+		}
+		replay := fmt.Sprintf("%s_%s_%s_attempt%d_Camera%d%s", timestamp, fullName, attempt.LiftType, attempt.AttemptNumber, cameraNumber, trimmed.Trim.Extension())
+		finalFile := filepath.Join(fullSessionDir, replay)
+		recodeLater := config.DeferRecodes && !trimmed.Recode && trimmed.TrimRecodes()
+		switch {
+		case recodeLater:
+			copied := trimmed
+			copied.Proxy = true
+			writeDryRunCommand(w, "Trimming (stream copy, published first)", buildTrimmingArgs(keep, recordingFile, finalFile, copied))
+			writeDryRunCommand(w, "Re-encoding between attempts (deferRecodes, stored replay on stdin)", buildTrimmingArgsWith(nil, 0, "pipe:0", finalFile, trimmed))
+		case trimmed.Recode || trimmed.TrimRecodes():
+			writeDryRunCommand(w, "Trimming (re-encoded with "+encoderName(trimEncoderFor(trimmed), trimSoftwareEncoder(trimmed))+")", buildTrimmingArgs(keep, recordingFile, finalFile, trimmed))
+		default:
+			label := "Trimming (stream copy)"
+			if trimmed.FrameAccurate {
+				label = "Trimming (stream copy; frameAccurateCameras re-encodes the frames before the first keyframe)"
+			}
+			writeDryRunCommand(w, label, buildTrimmingArgs(keep, recordingFile, finalFile, trimmed))
+			recoded := trimmed
+			recoded.Recode = true
+			writeDryRunCommand(w, "Trimming again after a bad cut (not copy-safe cameras only)", buildTrimmingArgs(keep, recordingFile, finalFile, recoded))
+		}
+
+		speeds, interpolate := config.GetSlowMotion()
+		for _, percent := range speeds {
+			writeDryRunCommand(w, fmt.Sprintf("%d%% slow motion (stored replay on stdin)", percent), buildSlowMotionArgs(percent, interpolate, filepath.Join(fullSessionDir, slowMotionName(replay, percent))))
+		}
+		for _, variant := range config.ProxyVariants {
+			writeDryRunCommand(w, fmt.Sprintf("%s proxy (stored replay on stdin)", variant.Name()), buildProxyArgs(variant, filepath.Join(fullSessionDir, proxyName(replay, variant))))
+		}
+	}
+}
+
+// trimSoftwareEncoder names the software encoder of the re-encoded replays of
+// camera.
+func trimSoftwareEncoder(camera config.CameraConfiguration) string {
+	switch camera.Trim.VideoCodec() {
+	case "hevc":
+		return "libx265"
+	case "prores":
+		return "prores_ks"
+	}
+	return "libx264"
+}
+
+// encoderName names a hardware encoder, or software when there is none.
+func encoderName(enc *HwEncoder, software string) string {
+	if enc == nil {
+		return software
+	}
+	return enc.Name
+}
+
+// writeDryRunCommand writes a titled ffmpeg command line, with the -loglevel
+// ffmpeg is given, quoting the arguments for a POSIX shell.
+func writeDryRunCommand(w io.Writer, title string, args []string) {
+	level := "quiet"
+	if config.GetLogFfmpeg() {
+		level = "info"
+	}
+	parts := []string{quoteDryRunArg(currentFFmpegPath()), "-loglevel", level}
+	for _, arg := range args {
+		parts = append(parts, quoteDryRunArg(redactSecrets(arg)))
+	}
+	fmt.Fprintf(w, "%s:\n  %s\n", title, strings.Join(parts, " "))
+}
+
+// dryRunSecrets match the SRT passphrases and the passwords of URLs, which
+// the report leaves out since it is shared when asking for help.
+var dryRunSecrets = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(passphrase=)[^&\s]*`), "${1}***"},
+	{regexp.MustCompile(`(://[^/:@\s]*):[^/@\s]*@`), "${1}:***@"},
+}
+
+func redactSecrets(text string) string {
+	for _, secret := range dryRunSecrets {
+		text = secret.pattern.ReplaceAllString(text, secret.replacement)
+	}
+	return text
+}
+
+func quoteDryRunArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$`!*?;&|<>()[]{}#~%") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
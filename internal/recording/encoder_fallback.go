@@ -0,0 +1,38 @@
+package recording
+
+import "strings"
+
+// IsEncoderFailure tells if an ffmpeg error comes from the hardware encoder
+// or its driver rather than from the camera. encoder is the name of the
+// hardware encoder in use, such as "h264_nvenc".
+func IsEncoderFailure(message, encoder string) bool {
+	lower := strings.ToLower(message)
+	if encoder != "" && strings.Contains(lower, strings.ToLower(encoder)) {
+		return true
+	}
+	for _, word := range []string{"nvenc", "cuda", "libnvidia", "vaapi", "libva", "qsv", "mfx", "amf", "hwupload", "hw device", "hardware device", "openencodesession"} {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsModeFailure tells if an ffmpeg error comes from the camera refusing the
+// requested format, size or frame rate, or from opening the device, rather
+// than from the encoder or the network: only then is another mode worth
+// trying.
+func IsModeFailure(message string) bool {
+	lower := strings.ToLower(message)
+	for _, words := range []string{
+		"not supported", "unsupported", "invalid argument", "cannot find a proper format",
+		"could not set video options", "could not run graph", "could not find video device",
+		"could not enumerate video devices", "no such file or directory", "input/output error",
+		"inappropriate ioctl", "error opening input", "i/o error",
+	} {
+		if strings.Contains(lower, words) {
+			return true
+		}
+	}
+	return false
+}
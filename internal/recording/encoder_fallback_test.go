@@ -0,0 +1,27 @@
+package recording
+
+import "testing"
+
+func TestIsEncoderFailure(t *testing.T) {
+	if !IsEncoderFailure("[h264_nvenc @ 0x5581] Cannot load libnvidia-encode.so.1", "h264_nvenc") {
+		t.Error("a missing NVENC library is an encoder failure")
+	}
+	if !IsEncoderFailure("Failed to initialise VAAPI connection: -1 (unknown libva error).", "") {
+		t.Error("a VAAPI initialisation error is an encoder failure")
+	}
+	if IsEncoderFailure("/dev/video0: No such file or directory", "h264_vaapi") {
+		t.Error("a missing camera is not an encoder failure")
+	}
+}
+
+func TestIsModeFailure(t *testing.T) {
+	if !IsModeFailure("[dshow @ 0x1] Could not set video options") {
+		t.Error("a refused DirectShow mode is a mode failure")
+	}
+	if !IsModeFailure("[avfoundation @ 0x1] Selected framerate (60.000000) is not supported by the device.") {
+		t.Error("a refused frame rate is a mode failure")
+	}
+	if IsModeFailure("[h264_nvenc @ 0x5581] OpenEncodeSessionEx failed: out of memory (10)") {
+		t.Error("an encoder error is not a mode failure")
+	}
+}
@@ -0,0 +1,141 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// maxReportLogBytes caps how much of each ffmpeg log is copied into a report.
+const maxReportLogBytes = 64 * 1024
+
+// trimAttempt records one run of the trimming ffmpeg for the failure report.
+type trimAttempt struct {
+	command string
+	logFile string // per-operation ffmpeg log, when ffmpeg logging is enabled
+	output  string // captured stderr, when it is not
+	err     error
+}
+
+// TrimFailure is returned when a trim failed permanently. Report is the
+// failure report written for it, or "" if it could not be written.
+type TrimFailure struct {
+	Attempts int
+	Err      error
+	Report   string
+}
+
+func (e *TrimFailure) Error() string {
+	return fmt.Sprintf("trim failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *TrimFailure) Unwrap() error {
+	return e.Err
+}
+
+// describeTrimSource returns ffprobe's view of the untrimmed recording.
+// Replaced in tests.
+var describeTrimSource = probeSourceInfo
+
+func probeSourceInfo(path string) string {
+	ffprobePath := resolveFFprobePath(config.GetFFmpegPath())
+	if ffprobePath == "" {
+		return "ffprobe not found"
+	}
+	cmd := jobutil.Command(ffprobePath, "-v", "error", "-show_format", "-show_streams", path)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Sprintf("%s\nffprobe failed: %v", strings.TrimSpace(out.String()), err)
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// captureTrimOutput makes sure the stderr of a trim attempt ends up somewhere
+// the failure report can read it. When ffmpeg logging is on, stderr already
+// goes to a per-operation log file; otherwise it is kept in memory.
+func captureTrimOutput(cmd *exec.Cmd) (logFile string, output *bytes.Buffer) {
+	if file, ok := cmd.Stderr.(*os.File); ok {
+		return file.Name(), nil
+	}
+	output = &bytes.Buffer{}
+	cmd.Stderr = output
+	return "", output
+}
+
+// writeTrimFailureReport gathers everything needed to diagnose a failed trim
+// (the commands, their ffmpeg output and ffprobe information on the source
+// recording) into a single file in the logs directory and returns its path.
+func writeTrimFailureReport(cameraNumber int, sourceFile, targetFile string, waitErr error, attempts []trimAttempt) (string, error) {
+	logsDir := filepath.Join(config.GetInstallDir(), "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	reportPath := filepath.Join(logsDir, fmt.Sprintf("trim_failure_%s_Camera%d.txt", now.Format("20060102_150405"), cameraNumber))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Trim failure report for Camera %d\n", cameraNumber)
+	fmt.Fprintf(&b, "Time:   %s\n", now.Format("2006-01-02 15:04:05.000 MST"))
+	fmt.Fprintf(&b, "Source: %s\n", sourceFile)
+	if info, err := os.Stat(sourceFile); err != nil {
+		fmt.Fprintf(&b, "        (%v)\n", err)
+	} else {
+		fmt.Fprintf(&b, "        %d bytes, modified %s\n", info.Size(), info.ModTime().Format("15:04:05.000"))
+	}
+	fmt.Fprintf(&b, "Target: %s\n", targetFile)
+	if waitErr != nil {
+		fmt.Fprintf(&b, "Input was not ready: %v\n", waitErr)
+	}
+
+	fmt.Fprintf(&b, "\n== Source file (ffprobe) ==\n%s\n", describeTrimSource(sourceFile))
+
+	for i, attempt := range attempts {
+		fmt.Fprintf(&b, "\n== Attempt %d ==\n", i+1)
+		fmt.Fprintf(&b, "Command: %s\n", attempt.command)
+		fmt.Fprintf(&b, "Error:   %v\n", attempt.err)
+		switch {
+		case attempt.logFile != "":
+			fmt.Fprintf(&b, "Log:     %s\n", attempt.logFile)
+			b.WriteString(readReportLog(attempt.logFile))
+		case strings.TrimSpace(attempt.output) != "":
+			b.WriteString(tailForReport(attempt.output))
+		default:
+			b.WriteString("(no ffmpeg output)\n")
+		}
+	}
+
+	if err := os.WriteFile(reportPath, []byte(b.String()), 0644); err != nil {
+		return "", err
+	}
+	logging.InfoLogger.Printf("Wrote trim failure report for Camera %d to %s", cameraNumber, reportPath)
+	return reportPath, nil
+}
+
+func readReportLog(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Sprintf("(could not read log: %v)\n", err)
+	}
+	return tailForReport(string(content))
+}
+
+// tailForReport keeps the end of long output, where ffmpeg reports the error.
+func tailForReport(text string) string {
+	if len(text) > maxReportLogBytes {
+		text = "[...]\n" + text[len(text)-maxReportLogBytes:]
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text
+}
@@ -0,0 +1,56 @@
+package recording
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestWriteTrimFailureReport(t *testing.T) {
+	dir := t.TempDir()
+	previousDir := config.ConfigDir
+	config.ConfigDir = dir
+	defer func() { config.ConfigDir = previousDir }()
+
+	previousDescribe := describeTrimSource
+	describeTrimSource = func(string) string { return "codec_name=h264" }
+	defer func() { describeTrimSource = previousDescribe }()
+
+	logFile := filepath.Join(dir, "ffmpeg_trimming.log")
+	if err := os.WriteFile(logFile, []byte("moov atom not found\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	attempts := []trimAttempt{
+		{command: "ffmpeg -i in.mkv out.mp4", output: "Invalid data found", err: errors.New("exit status 1")},
+		{command: "ffmpeg -i in.mkv out.mp4", logFile: logFile, err: errors.New("exit status 1")},
+	}
+
+	report, err := writeTrimFailureReport(2, filepath.Join(dir, "in.mkv"), filepath.Join(dir, "out.mp4"), nil, attempts)
+	if err != nil {
+		t.Fatalf("writeTrimFailureReport: %v", err)
+	}
+	if filepath.Dir(report) != filepath.Join(dir, "logs") {
+		t.Fatalf("report written to %s, want the logs directory", report)
+	}
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Camera 2", "codec_name=h264", "== Attempt 2 ==", "Invalid data found", "moov atom not found", "exit status 1"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("report does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestTailForReportKeepsEnd(t *testing.T) {
+	long := strings.Repeat("x", maxReportLogBytes) + "the error"
+	tail := tailForReport(long)
+	if !strings.HasPrefix(tail, "[...]") || !strings.HasSuffix(tail, "the error\n") {
+		t.Fatalf("unexpected tail: %q...", tail[:20])
+	}
+}
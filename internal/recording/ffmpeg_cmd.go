@@ -0,0 +1,66 @@
+package recording
+
+import (
+	"os/exec"
+	"path/filepath"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// CreateFfmpegCmd creates an exec.Cmd for ffmpeg. The -loglevel follows the
+// ffmpeg logging preference unless forced; when logging is enabled the output
+// goes to a per-operation file in the logs directory.
+func CreateFfmpegCmd(args []string, operation string, forcedLogLevel ...string) *exec.Cmd {
+	// Use the stored ffmpeg path from config
+	path := config.GetFFmpegPath()
+
+	// Handle loglevel based on logging preference or forced level
+	var targetLoglevel string
+	if len(forcedLogLevel) > 0 && forcedLogLevel[0] != "" {
+		targetLoglevel = forcedLogLevel[0]
+	} else {
+		logFfmpeg := config.GetLogFfmpeg()
+		targetLoglevel = "quiet"
+		if logFfmpeg {
+			targetLoglevel = "info"
+		}
+	}
+
+	// Check if -loglevel already exists in args and update it, or add it
+	foundLoglevel := false
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "-loglevel" {
+			args[i+1] = targetLoglevel
+			foundLoglevel = true
+			break
+		}
+	}
+
+	// If no loglevel found, add it at the beginning
+	if !foundLoglevel {
+		args = append([]string{"-loglevel", targetLoglevel}, args...)
+	}
+
+	// Log the command being executed for debugging
+	logging.InfoLogger.Printf("Creating ffmpeg command with path: %s", path)
+	logging.InfoLogger.Printf("FFmpeg args (%d total):", len(args))
+	for i, arg := range args {
+		logging.InfoLogger.Printf("  [%d]: %s", i, arg)
+	}
+
+	cmd := jobutil.Command(path, args...)
+
+	// Redirect ffmpeg output to timestamped files only if logFfmpeg is enabled
+	if config.GetLogFfmpeg() {
+		logsDir := filepath.Join(config.GetInstallDir(), "logs")
+		if logFile, err := jobutil.LogOutput(cmd, logsDir, "ffmpeg", operation); err != nil {
+			logging.ErrorLogger.Printf("%v", err)
+		} else {
+			logging.InfoLogger.Printf("FFmpeg output will be logged to: %s", logFile)
+		}
+	}
+
+	return cmd
+}
@@ -8,15 +8,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"sync/atomic"
 	"syscall"
-	"time"
 
 	"github.com/owlcms/replays/internal/config"
 	"github.com/owlcms/replays/internal/logging"
 )
 
-var ffmpegLogSeq uint64
 var activeFFmpegLibDir string
 
 // InitializeFFmpeg finds and stores the ffmpeg path in config for Linux
@@ -25,6 +22,9 @@ func InitializeFFmpeg() error {
 	if envPath := strings.TrimSpace(os.Getenv("VIDEO_FFMPEG_PATH")); envPath != "" {
 		logging.InfoLogger.Printf("Using ffmpeg from VIDEO_FFMPEG_PATH: %s", envPath)
 		path = envPath
+	} else if bundledPath := config.FindBundledFFmpegExecutable("ffmpeg"); bundledPath != "" {
+		logging.InfoLogger.Printf("Using ffmpeg bundled with the installer: %s", bundledPath)
+		path = bundledPath
 	} else if sharedPath := config.FindSharedFFmpegExecutable("ffmpeg"); sharedPath != "" {
 		logging.InfoLogger.Printf("Using shared Control Panel ffmpeg: %s", sharedPath)
 		path = sharedPath
@@ -131,75 +131,6 @@ func findFFmpeg() string {
 	return "/usr/bin/ffmpeg"
 }
 
-// CreateFfmpegCmd creates an exec.Cmd for ffmpeg on Linux
-func CreateFfmpegCmd(args []string, operation string, forcedLogLevel ...string) *exec.Cmd {
-	// Use the stored ffmpeg path from config
-	path := config.GetFFmpegPath()
-
-	// Handle loglevel based on logging preference or forced level
-	var targetLoglevel string
-	if len(forcedLogLevel) > 0 && forcedLogLevel[0] != "" {
-		targetLoglevel = forcedLogLevel[0]
-	} else {
-		logFfmpeg := config.GetLogFfmpeg()
-		targetLoglevel = "quiet"
-		if logFfmpeg {
-			targetLoglevel = "info"
-		}
-	}
-
-	// Check if -loglevel already exists in args and update it, or add it
-	foundLoglevel := false
-	for i := 0; i < len(args)-1; i++ {
-		if args[i] == "-loglevel" {
-			args[i+1] = targetLoglevel
-			foundLoglevel = true
-			break
-		}
-	}
-
-	// If no loglevel found, add it at the beginning
-	if !foundLoglevel {
-		args = append([]string{"-loglevel", targetLoglevel}, args...)
-	}
-
-	// Log the command being executed for debugging
-	logging.InfoLogger.Printf("Creating ffmpeg command with path: %s", path)
-	logging.InfoLogger.Printf("FFmpeg args (%d total):", len(args))
-	for i, arg := range args {
-		logging.InfoLogger.Printf("  [%d]: %s", i, arg)
-	}
-
-	cmd := exec.Command(path, args...)
-	// Set up process group for proper cleanup on Linux
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-	}
-
-	// Create logs directory and redirect ffmpeg output to timestamped files only if logFfmpeg is enabled
-	if config.GetLogFfmpeg() {
-		installDir := config.GetInstallDir()
-		logsDir := filepath.Join(installDir, "logs")
-		if err := os.MkdirAll(logsDir, 0755); err != nil {
-			logging.ErrorLogger.Printf("Failed to create logs directory: %v", err)
-		} else {
-			timestamp := time.Now().Format("20060102_150405_000000000")
-			seq := atomic.AddUint64(&ffmpegLogSeq, 1)
-			logFile := filepath.Join(logsDir, fmt.Sprintf("ffmpeg_%s_%s_%d.log", timestamp, operation, seq))
-
-			if file, err := os.Create(logFile); err != nil {
-				logging.ErrorLogger.Printf("Failed to create ffmpeg log file %s: %v", logFile, err)
-			} else {
-				logging.InfoLogger.Printf("FFmpeg output will be logged to: %s", logFile)
-				cmd.Stdout = file
-				cmd.Stderr = file
-			}
-		}
-	}
-
-	return cmd
-}
-
 func forceKillCmd(cmd *exec.Cmd) error {
 	if cmd.Process == nil {
 		return nil
@@ -221,13 +152,3 @@ func forceKillCmd(cmd *exec.Cmd) error {
 	}
 	return nil
 }
-
-// CreateHiddenCmd creates a command. On Linux, no special handling is needed
-// as there's no console window to hide.
-func CreateHiddenCmd(name string, args ...string) *exec.Cmd {
-	cmd := exec.Command(name, args...)
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-	}
-	return cmd
-}
@@ -1,10 +1,72 @@
 package recording
 
-import "io"
+import (
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// stopGrace is how long ffmpeg is given to finish its file once asked to stop.
+const stopGrace = 2 * time.Second
+
+// StopFFmpeg stops a running ffmpeg gracefully, so that it writes the end of
+// its file (the index of an mkv, the last segment of a pre-buffer); a killed
+// recording has to be repaired before it can be trimmed. It goes through three
+// steps, each one used when the previous did not work:
+//
+//  1. an interrupt, as with Ctrl+C in a terminal (jobutil.Interrupt): SIGINT
+//     on Unix, CTRL_BREAK_EVENT on Windows. ffmpeg handles it even when it is
+//     blocked reading a camera that sends nothing, unlike stdin.
+//  2. "q" on stdin, then stdin closed, when the interrupt cannot be sent (on
+//     Windows, when replays was started from a terminal). ffmpeg must have
+//     been started with stdin attached (no -nostdin).
+//  3. after grace, the process is killed.
+//
+// It returns whether ffmpeg had to be killed, and its exit error. done
+// receives the result of cmd.Wait when the caller already waits for the
+// process; when nil, StopFFmpeg waits for it. The command must come from
+// CreateFfmpegCmd, which puts it in its own process group so that the
+// interrupt only reaches it.
+func StopFFmpeg(cmd *exec.Cmd, stdin io.WriteCloser, done <-chan error, grace time.Duration) (killed bool, err error) {
+	if done == nil {
+		waited := make(chan error, 1)
+		go func() { waited <- cmd.Wait() }()
+		done = waited
+	}
+	RequestFFmpegStop(cmd, stdin)
+	select {
+	case err = <-done:
+		CloseFFmpegStdin(stdin)
+		return false, err
+	case <-time.After(100 * time.Millisecond):
+	}
+	// Closing stdin also stops an ffmpeg that missed the interrupt.
+	CloseFFmpegStdin(stdin)
+	select {
+	case err = <-done:
+		return false, err
+	case <-time.After(grace):
+	}
+	if killErr := forceKillCmd(cmd); killErr != nil {
+		logging.ErrorLogger.Printf("Failed to kill ffmpeg process %d: %v", cmd.Process.Pid, killErr)
+	}
+	return true, <-done
+}
+
+// RequestFFmpegStop asks ffmpeg to stop and finalize its output: it interrupts
+// the process, and writes "q" on stdin if the interrupt cannot be sent.
+func RequestFFmpegStop(cmd *exec.Cmd, stdin io.Writer) error {
+	if err := jobutil.Interrupt(cmd); err == nil {
+		return nil
+	}
+	return RequestFFmpegQuit(stdin)
+}
 
 // RequestFFmpegQuit writes "q\n" to ffmpeg's stdin, which ffmpeg interprets
-// as a request to stop recording and finalize the output file. Requires
-// ffmpeg to have been started with stdin available (i.e., without -nostdin).
+// as a request to stop recording and finalize the output file.
 func RequestFFmpegQuit(stdin io.Writer) error {
 	if stdin == nil {
 		return nil
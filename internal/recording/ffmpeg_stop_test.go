@@ -0,0 +1,34 @@
+//go:build !windows
+
+package recording
+
+import (
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/jobutil"
+)
+
+func TestStopFFmpegInterruptsThenKills(t *testing.T) {
+	cmd := jobutil.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot run sleep: %v", err)
+	}
+	if killed, err := StopFFmpeg(cmd, nil, nil, time.Second); killed || !isExpectedFFmpegStop(err) {
+		t.Fatalf("interrupted process: killed %v, err %v", killed, err)
+	}
+
+	// A process that ignores the interrupt, like a wedged ffmpeg.
+	cmd = jobutil.Command("sh", "-c", `trap "" INT; sleep 30`)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot run sh: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	started := time.Now()
+	if killed, _ := StopFFmpeg(cmd, nil, nil, 200*time.Millisecond); !killed {
+		t.Fatalf("process ignoring the interrupt was not killed")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("stopping took %s", elapsed)
+	}
+}
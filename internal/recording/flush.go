@@ -0,0 +1,59 @@
+package recording
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// keepRecording moves a recording that must outlive the attempt out of the
+// recording directory (often a RAM disk) into the video directory, in the
+// background, and returns where it will be. Recordings already in the video
+// directory stay where they are.
+func keepRecording(path string) string {
+	if !config.HasRecordingDir() || filepath.Dir(path) != filepath.Clean(config.GetRecordingDir()) {
+		return path
+	}
+	target := filepath.Join(config.GetVideoDir(), filepath.Base(path))
+	go func() {
+		if err := moveFile(path, target); err != nil {
+			logging.ErrorLogger.Printf("Failed to move %s to the video directory: %v", path, err)
+			return
+		}
+		logging.InfoLogger.Printf("Moved %s to %s", path, target)
+	}()
+	return target
+}
+
+// moveFile renames a file, copying it when the target is on another disk.
+func moveFile(source, target string) error {
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(target + ".part")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	if err := os.Rename(out.Name(), target); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(source)
+}
@@ -0,0 +1,52 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestRecordingKeptFromTheRecordingDirIsMovedToTheVideoDir(t *testing.T) {
+	videoDir, recordingDir := t.TempDir(), t.TempDir()
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	config.SetRecordingDir(recordingDir)
+	t.Cleanup(func() {
+		config.SetVideoDir(oldVideoDir)
+		config.SetRecordingDir("")
+	})
+	if config.GetRecordingDir() != recordingDir {
+		t.Fatalf("GetRecordingDir() = %s, want %s", config.GetRecordingDir(), recordingDir)
+	}
+
+	damaged := filepath.Join(recordingDir, "Camera1.mkv.damaged")
+	if err := os.WriteFile(damaged, []byte("frames"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	kept := keepRecording(damaged)
+	if kept != filepath.Join(videoDir, "Camera1.mkv.damaged") {
+		t.Fatalf("keepRecording() = %s, want it in the video directory", kept)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, err := os.ReadFile(kept); err == nil && string(data) == "frames" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not moved to %s", damaged, kept)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(damaged); !os.IsNotExist(err) {
+		t.Fatalf("the recording directory still holds %s", damaged)
+	}
+
+	config.SetRecordingDir("")
+	inVideoDir := filepath.Join(videoDir, "Camera2.mkv.damaged")
+	if keepRecording(inVideoDir) != inVideoDir {
+		t.Fatalf("a recording already in the video directory was moved")
+	}
+}
@@ -0,0 +1,192 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// A stream copy can only start on a keyframe, so the usual trim starts up to
+// a GOP early or late. The frame-accurate trim re-encodes only the frames
+// between the exact start and the next keyframe and copies the rest; both
+// parts go through MPEG-TS, which repeats the stream parameters at each
+// keyframe, so that they can be joined without re-encoding.
+const (
+	// frameAccurateWindow is how far after the cut a keyframe is searched, in seconds.
+	frameAccurateWindow = 10.0
+	// frameAccurateSlack is how close to a keyframe a cut may fall and still
+	// be copied from that keyframe, in seconds (about a frame).
+	frameAccurateSlack = 0.015
+)
+
+// smartCut is where a frame-accurate trim starts, relative to the start of
+// the recording, in seconds.
+type smartCut struct {
+	start    float64 // exact first frame of the replay
+	keyframe float64 // first keyframe at or after start
+}
+
+// reencodes reports whether frames before the keyframe must be re-encoded.
+func (c smartCut) reencodes() bool {
+	return c.keyframe-c.start > frameAccurateSlack
+}
+
+// planSmartCut places a cut keepFromEndMs before the end of a recording from
+// its probe and the absolute times of its keyframes after the cut.
+func planSmartCut(probe trimProbe, keepFromEndMs int64, keyframes []float64) (smartCut, error) {
+	start := float64(probe.durationMs-keepFromEndMs) / 1000
+	if start < 0 {
+		start = 0
+	}
+	for _, keyframe := range keyframes {
+		relative := keyframe - float64(probe.startMs)/1000
+		if relative >= start-frameAccurateSlack {
+			if relative < start {
+				start = relative
+			}
+			return smartCut{start: start, keyframe: relative}, nil
+		}
+	}
+	return smartCut{}, fmt.Errorf("no keyframe within %.0fs after %.3fs", frameAccurateWindow, start)
+}
+
+// probeKeyframes returns the codec of a recording and the absolute times of
+// its keyframes between from and from+window seconds after its start.
+// Replaced in tests.
+var probeKeyframes = runKeyframeProbe
+
+func runKeyframeProbe(path string, from, window float64) (copyProbe, error) {
+	ffprobePath := resolveFFprobePath(config.GetFFmpegPath())
+	if ffprobePath == "" {
+		return copyProbe{}, fmt.Errorf("ffprobe not found")
+	}
+	cmd := jobutil.Command(ffprobePath, "-v", "error", "-select_streams", "v:0",
+		"-read_intervals", fmt.Sprintf("%.3f%%+%.3f", from, window),
+		"-show_entries", "stream=codec_name:packet=pts_time,flags",
+		"-of", "compact", path)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return copyProbe{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseCopyProbe(out.String())
+}
+
+// trimCamera trims a recording on the exact frame when the camera asks for
+// it, or on the keyframe otherwise or when that fails. A re-encoded trim is
+// already frame-accurate.
+func trimCamera(cameraNumber int, keepFromEndMs int64, currentFileName, finalFileName string, camera config.CameraConfiguration) error {
+	if camera.FrameAccurate && !camera.Recode && !camera.TrimRecodes() && keepFromEndMs > 0 && !config.NoVideo {
+		err := runFrameAccurateTrim(cameraNumber, keepFromEndMs, currentFileName, finalFileName, camera)
+		if err == nil {
+			return nil
+		}
+		logging.WarningLogger.Printf("Camera %d: frame-accurate trim failed, cutting on the keyframe instead: %v", cameraNumber, err)
+	}
+	return runTrim(cameraNumber, keepFromEndMs, currentFileName, finalFileName, camera)
+}
+
+// runFrameAccurateTrim cuts the last keepFromEndMs of a recording on the
+// exact frame. Errors leave the caller to fall back to the usual trim.
+func runFrameAccurateTrim(cameraNumber int, keepFromEndMs int64, currentFileName, finalFileName string, camera config.CameraConfiguration) error {
+	if err := waitForTrimInput(cameraNumber, currentFileName, config.GetTrimWait()); err != nil {
+		return err
+	}
+	probe, ok := probeTrimmedVideo(currentFileName)
+	if !ok {
+		return fmt.Errorf("cannot probe %s", currentFileName)
+	}
+	from := float64(probe.startMs+probe.durationMs-keepFromEndMs) / 1000
+	probed, err := probeKeyframes(currentFileName, from-frameAccurateSlack, frameAccurateWindow)
+	if err != nil {
+		return err
+	}
+	if probed.params.codec != "h264" {
+		// The head is re-encoded with libx264; joined to another codec, the
+		// replay would not play.
+		return fmt.Errorf("the camera sends %q video, only h264 is cut on the exact frame", probed.params.codec)
+	}
+	cut, err := planSmartCut(probe, keepFromEndMs, probed.keyframes)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(finalFileName)
+	base := strings.TrimSuffix(filepath.Base(finalFileName), filepath.Ext(finalFileName))
+	head := filepath.Join(dir, base+".head.ts")
+	tail := filepath.Join(dir, base+".tail.ts")
+	list := filepath.Join(dir, base+".parts.txt")
+	defer func() {
+		os.Remove(head)
+		os.Remove(tail)
+		os.Remove(list)
+	}()
+
+	var parts []string
+	if cut.reencodes() {
+		headArgs := []string{"-y", "-ss", fmt.Sprintf("%.6f", cut.start), "-i", currentFileName,
+			"-t", fmt.Sprintf("%.6f", cut.keyframe-cut.start),
+			"-c:v", "libx264", "-crf", "18"}
+		headArgs = append(headArgs, config.X264Args(trimParallelism())...)
+		headArgs = append(headArgs, "-profile:v", "main", "-pix_fmt", "yuv420p", "-bf", "0")
+		if camera.Audio != nil {
+			headArgs = append(headArgs, audioTrimArgs(camera.Audio)...)
+		} else {
+			headArgs = append(headArgs, "-an")
+		}
+		headArgs = append(headArgs, "-f", "mpegts", head)
+		if err := runTrimStep(cameraNumber, headArgs); err != nil {
+			return fmt.Errorf("re-encoding up to the keyframe: %w", err)
+		}
+		parts = append(parts, head)
+	}
+	// Seeking a millisecond past the keyframe still lands on it.
+	tailArgs := []string{"-y", "-ss", fmt.Sprintf("%.6f", cut.keyframe+0.001), "-i", currentFileName, "-c", "copy"}
+	if camera.Audio == nil {
+		tailArgs = append(tailArgs, "-an")
+	}
+	tailArgs = append(tailArgs, "-f", "mpegts", tail)
+	if err := runTrimStep(cameraNumber, tailArgs); err != nil {
+		return fmt.Errorf("copying from the keyframe: %w", err)
+	}
+	parts = append(parts, tail)
+
+	var listing strings.Builder
+	for _, part := range parts {
+		fmt.Fprintf(&listing, "file '%s'\n", strings.ReplaceAll(filepath.ToSlash(part), "'", `'\''`))
+	}
+	if err := os.WriteFile(list, []byte(listing.String()), 0644); err != nil {
+		return err
+	}
+	if err := runTrimStep(cameraNumber, []string{"-y", "-f", "concat", "-safe", "0", "-i", list,
+		"-c", "copy", "-avoid_negative_ts", "make_zero", "-movflags", "+faststart", finalFileName}); err != nil {
+		return fmt.Errorf("joining the parts: %w", err)
+	}
+	logging.InfoLogger.Printf("Camera %d: frame-accurate trim from %.3fs (%.0fms re-encoded before the keyframe)", cameraNumber, cut.start, (cut.keyframe-cut.start)*1000)
+	return nil
+}
+
+// runTrimStep runs one ffmpeg of the frame-accurate trim.
+func runTrimStep(cameraNumber int, args []string) error {
+	logLevel := ""
+	if !config.GetLogFfmpeg() {
+		logLevel = "error"
+	}
+	cmd := CreateFfmpegCmd(args, "trimming", logLevel)
+	logFile, output := captureTrimOutput(cmd)
+	logging.InfoLogger.Printf("Executing trim step for Camera %d: %s", cameraNumber, cmd.String())
+	if err := cmd.Run(); err != nil {
+		if output != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(output.String()))
+		}
+		return fmt.Errorf("%w, see %s", err, logFile)
+	}
+	return nil
+}
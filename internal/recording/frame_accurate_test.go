@@ -0,0 +1,38 @@
+package recording
+
+import (
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestFrameAccurateTrimReencodesOnlyUpToTheNextKeyframe(t *testing.T) {
+	// A 20s recording whose timestamps start at 1.4s, keyframes every second.
+	probe := trimProbe{durationMs: 20000, startMs: 1400}
+	keyframes := []float64{13.4, 14.4, 15.4}
+
+	cut, err := planSmartCut(probe, 7700, keyframes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cut.start != 12.3 || cut.keyframe != 13.0 || !cut.reencodes() {
+		t.Fatalf("planSmartCut() = %+v, want the exact start 12.3s and the keyframe at 13s", cut)
+	}
+
+	onKeyframe, err := planSmartCut(probe, 8000, keyframes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onKeyframe.reencodes() {
+		t.Fatalf("a cut on a keyframe was re-encoded: %+v", onKeyframe)
+	}
+	if _, err := planSmartCut(probe, 7700, nil); err == nil {
+		t.Fatalf("a cut without a keyframe after it was planned")
+	}
+
+	settings := config.MulticastSettings{IP: "239.255.0.1", Camera1Port: 9001, Camera2Port: 9002, FrameAccurateCameras: []int{2}}
+	cameras := settings.BuildCameraConfigs()
+	if cameras[0].FrameAccurate || !cameras[1].FrameAccurate {
+		t.Fatalf("frameAccurateCameras = [2] gave %+v", cameras)
+	}
+}
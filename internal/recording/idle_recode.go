@@ -0,0 +1,270 @@
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// idlePoll is how often the idle worker checks that no attempt is running.
+const idlePoll = time.Second
+
+// pendingRecodesFile lists, in the video directory, the replays published as
+// a stream copy and not re-encoded yet, so that a restart finishes them.
+const pendingRecodesFile = ".pending-recodes.json"
+
+// errPreempted is returned by runIdle when an attempt started while its
+// ffmpeg was running.
+var errPreempted = errors.New("stopped for the next attempt")
+
+// idleJob is a re-encode left for the time between attempts. run is called
+// again from the start when an attempt interrupts it.
+type idleJob struct {
+	name string
+	run  func() error
+}
+
+var (
+	idleMu      sync.Mutex
+	idleOnce    sync.Once
+	idleJobs    []idleJob
+	idleCmd     *exec.Cmd // ffmpeg of the running job
+	idleStopped bool      // idleCmd was killed for an attempt
+	idleStarts  int64     // attempts started, to notice one while checking IsBusy
+)
+
+// deferRecode queues a re-encode for when no attempt is recorded or trimmed.
+// owlcms announces the attempts: the queue runs after the decision has been
+// trimmed and stops as soon as the next clock starts.
+func deferRecode(name string, run func() error) {
+	idleOnce.Do(func() { go idleWorker() })
+	idleMu.Lock()
+	defer idleMu.Unlock()
+	idleJobs = append(idleJobs, idleJob{name: name, run: run})
+	logging.InfoLogger.Printf("Deferred %s until no attempt is running (%d waiting)", name, len(idleJobs))
+}
+
+func idleWorker() {
+	for {
+		time.Sleep(idlePoll)
+		if IsBusy() {
+			continue
+		}
+		idleMu.Lock()
+		if len(idleJobs) == 0 {
+			idleMu.Unlock()
+			continue
+		}
+		job := idleJobs[0]
+		idleJobs = idleJobs[1:]
+		idleMu.Unlock()
+
+		err := job.run()
+		if errors.Is(err, errPreempted) {
+			logging.InfoLogger.Printf("Paused %s for the attempt, it will be redone after", job.name)
+			idleMu.Lock()
+			idleJobs = append([]idleJob{job}, idleJobs...)
+			idleMu.Unlock()
+			continue
+		}
+		if err != nil {
+			logging.ErrorLogger.Printf("Failed %s: %v", job.name, err)
+		}
+	}
+}
+
+// runIdle runs the ffmpeg of an idle job, killed if an attempt starts.
+func runIdle(cmd *exec.Cmd) error {
+	idleMu.Lock()
+	starts := idleStarts
+	idleMu.Unlock()
+	// IsBusy takes the locks of the recordings, never while holding idleMu.
+	if IsBusy() {
+		return errPreempted
+	}
+	idleMu.Lock()
+	if idleStarts != starts {
+		idleMu.Unlock()
+		return errPreempted
+	}
+	if err := cmd.Start(); err != nil {
+		idleMu.Unlock()
+		return err
+	}
+	idleCmd, idleStopped = cmd, false
+	idleMu.Unlock()
+
+	err := cmd.Wait()
+	idleMu.Lock()
+	defer idleMu.Unlock()
+	idleCmd = nil
+	if idleStopped {
+		return errPreempted
+	}
+	return err
+}
+
+// preemptIdleRecodes stops the deferred re-encode running, if any, so that
+// the cameras of the attempt have the whole processor. Called once the
+// recording is flagged, so that IsBusy keeps the next ones from starting.
+func preemptIdleRecodes() {
+	idleMu.Lock()
+	defer idleMu.Unlock()
+	idleStarts++
+	if idleCmd == nil || idleCmd.Process == nil {
+		return
+	}
+	idleStopped = true
+	if err := idleCmd.Process.Kill(); err != nil {
+		logging.WarningLogger.Printf("Failed to stop the deferred re-encode: %v", err)
+	}
+}
+
+// recodeStoredReplay re-encodes a replay published as a stream copy with
+// the trim settings of its camera, then replaces it.
+func recodeStoredReplay(cameraNumber int, sessionDir, replay string, camera config.CameraConfiguration) error {
+	workDir, err := sessionWorkDir(sessionDir)
+	if err != nil {
+		return err
+	}
+	output := filepath.Join(workDir, ".recode", replay)
+	if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+		return err
+	}
+	defer os.Remove(output)
+
+	input, err := storage.Current().Open(storage.Join(sessionDir, replay))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	cmd := CreateFfmpegCmd(buildTrimmingArgsWith(nil, 0, "pipe:0", output, camera), "recode", "error")
+	cmd.Stdin = input
+	if err := runIdle(cmd); err != nil {
+		return err
+	}
+	if err := storeSessionFile(sessionDir, output); err != nil {
+		return err
+	}
+	logging.InfoLogger.Printf("Camera %d: %s re-encoded", cameraNumber, replay)
+	return nil
+}
+
+// pendingRecode is a deferred re-encode of a replay, as saved in
+// pendingRecodesFile.
+type pendingRecode struct {
+	Camera   int                        `json:"camera"`
+	Session  string                     `json:"session"`
+	Replay   string                     `json:"replay"`
+	Settings config.CameraConfiguration `json:"settings"` // with the overlays of the attempt
+}
+
+// pendingMu guards pendingRecodesFile.
+var pendingMu sync.Mutex
+
+// deferReplayRecode queues the re-encode of a replay published as a stream
+// copy and saves it until it is done.
+func deferReplayRecode(cameraNumber int, sessionDir, replay string, camera config.CameraConfiguration) {
+	job := pendingRecode{Camera: cameraNumber, Session: sessionDir, Replay: replay, Settings: camera}
+	updatePendingRecodes(func(jobs []pendingRecode) []pendingRecode { return append(jobs, job) })
+	queuePendingRecode(job)
+}
+
+func queuePendingRecode(job pendingRecode) {
+	deferRecode(fmt.Sprintf("re-encode of %s", job.Replay), func() error {
+		err := recodeStoredReplay(job.Camera, job.Session, job.Replay, job.Settings)
+		if errors.Is(err, errPreempted) {
+			return err
+		}
+		updatePendingRecodes(func(jobs []pendingRecode) []pendingRecode {
+			for i := range jobs {
+				if jobs[i].Session == job.Session && jobs[i].Replay == job.Replay {
+					return append(jobs[:i], jobs[i+1:]...)
+				}
+			}
+			return jobs
+		})
+		return err
+	})
+}
+
+// ResumePendingRecodes queues again the re-encodes left pending when the
+// program was stopped.
+func ResumePendingRecodes() {
+	pendingMu.Lock()
+	jobs, err := readPendingRecodes()
+	pendingMu.Unlock()
+	if err != nil {
+		logging.ErrorLogger.Printf("Cannot read the pending re-encodes: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		queuePendingRecode(job)
+	}
+}
+
+func readPendingRecodes() ([]pendingRecode, error) {
+	data, err := os.ReadFile(filepath.Join(config.GetVideoDir(), pendingRecodesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []pendingRecode
+	return jobs, json.Unmarshal(data, &jobs)
+}
+
+// updatePendingRecodes saves the pending re-encodes as changed by update.
+func updatePendingRecodes(update func([]pendingRecode) []pendingRecode) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	jobs, err := readPendingRecodes()
+	if err != nil {
+		logging.WarningLogger.Printf("Cannot read the pending re-encodes, starting a new list: %v", err)
+	}
+	jobs = update(jobs)
+	path := filepath.Join(config.GetVideoDir(), pendingRecodesFile)
+	if len(jobs) == 0 {
+		err = os.Remove(path)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		var data []byte
+		if data, err = json.MarshalIndent(jobs, "", "  "); err == nil {
+			if err = os.WriteFile(path+".tmp", data, 0644); err == nil {
+				err = os.Rename(path+".tmp", path)
+			}
+		}
+	}
+	if err != nil {
+		logging.ErrorLogger.Printf("Cannot save the pending re-encodes: %v", err)
+	}
+}
+
+// proxyOverlays returns camera with its scoreboard and timecode drawn for a
+// proxy that starts earlierMs before the cut from..to they were made for:
+// the stream copy starts on the keyframe before it.
+func proxyOverlays(camera config.CameraConfiguration, from, to time.Time, earlierMs int64) config.CameraConfiguration {
+	if earlierMs == 0 {
+		return camera
+	}
+	from = from.Add(-time.Duration(earlierMs) * time.Millisecond)
+	if camera.ScoreFilter != "" {
+		camera.ScoreFilter = scoreboardFilter(from, to)
+	}
+	if !camera.Timecode.IsZero() {
+		camera.Timecode = from
+	}
+	return camera
+}
@@ -0,0 +1,91 @@
+package recording
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestRunIdleStopsForTheNextAttempt(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("no sleep command")
+	}
+	Recording = true
+	if err := runIdle(exec.Command("sleep", "5")); !errors.Is(err, errPreempted) {
+		t.Fatalf("runIdle during an attempt = %v, want errPreempted", err)
+	}
+	Recording = false
+
+	done := make(chan error, 1)
+	go func() { done <- runIdle(exec.Command("sleep", "5")) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		idleMu.Lock()
+		running := idleCmd != nil
+		idleMu.Unlock()
+		if running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the idle command did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	preemptIdleRecodes()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errPreempted) {
+			t.Fatalf("runIdle = %v, want errPreempted", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the idle command was not stopped")
+	}
+}
+
+func TestProxyIsAStreamCopy(t *testing.T) {
+	camera := config.CameraConfiguration{Overlay: "Mary - Snatch attempt 1", Trim: &config.TrimProfile{Scale: "1280:-2"}}
+	camera.Proxy = true
+	args := strings.Join(buildTrimmingArgsWith(nil, 8000, "in.mkv", "out.mp4", camera), " ")
+	if !strings.Contains(args, "-c copy") || strings.Contains(args, "drawtext") {
+		t.Errorf("proxy trim = %s, want a stream copy", args)
+	}
+	camera.Proxy = false
+	args = strings.Join(buildTrimmingArgsWith(nil, 0, "pipe:0", "out.mp4", camera), " ")
+	if !strings.Contains(args, "-i pipe:0") || !strings.Contains(args, "scale=1280:-2,drawtext") || strings.Contains(args, "-sseof") {
+		t.Errorf("deferred re-encode = %s, want the whole proxy re-encoded with the filters", args)
+	}
+}
+
+func TestProxyOverlaysStartWithTheProxy(t *testing.T) {
+	from := time.UnixMilli(100_000)
+	camera := config.CameraConfiguration{Timecode: from}
+	// The stream copy started on a keyframe 700ms before the cut.
+	shifted := proxyOverlays(camera, from, time.UnixMilli(110_000), 700)
+	if want := time.UnixMilli(99_300); !shifted.Timecode.Equal(want) {
+		t.Fatalf("timecode = %v, want %v", shifted.Timecode, want)
+	}
+	if untouched := proxyOverlays(config.CameraConfiguration{}, from, time.UnixMilli(110_000), 700); !untouched.Timecode.IsZero() || untouched.ScoreFilter != "" {
+		t.Fatalf("overlays were added: %+v", untouched)
+	}
+}
+
+func TestPendingRecodesAreSavedUntilDone(t *testing.T) {
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(t.TempDir())
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+
+	job := pendingRecode{Camera: 2, Session: "A", Replay: "replay.mp4", Settings: config.CameraConfiguration{Overlay: "Mary - Snatch attempt 1"}}
+	updatePendingRecodes(func(jobs []pendingRecode) []pendingRecode { return append(jobs, job) })
+	jobs, err := readPendingRecodes()
+	if err != nil || len(jobs) != 1 || jobs[0].Replay != "replay.mp4" || jobs[0].Settings.Overlay != job.Settings.Overlay {
+		t.Fatalf("saved = %+v, %v", jobs, err)
+	}
+	updatePendingRecodes(func([]pendingRecode) []pendingRecode { return nil })
+	if jobs, err := readPendingRecodes(); err != nil || len(jobs) != 0 {
+		t.Fatalf("after the re-encode = %+v, %v", jobs, err)
+	}
+}
@@ -0,0 +1,143 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// brokenSuffix is added to a trimmed replay that failed its integrity check,
+// so that it is kept for inspection but not listed as a video.
+const brokenSuffix = ".broken"
+
+// replayIntegrity checks a trimmed replay before it is published. Replaced
+// in tests.
+var replayIntegrity = checkReplayIntegrity
+
+// checkReplayIntegrity returns why a trimmed replay cannot be played, or nil:
+// an empty file, an mp4 or mov file without its moov atom (the index written
+// last by ffmpeg), no video stream or no duration.
+func checkReplayIntegrity(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("the file is empty")
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".mov":
+		if err := checkMoovAtom(path); err != nil {
+			return err
+		}
+	}
+	ffprobePath := resolveFFprobePath(config.GetFFmpegPath())
+	if ffprobePath == "" {
+		return nil
+	}
+	cmd := jobutil.Command(ffprobePath, "-v", "error",
+		"-show_entries", "stream=codec_type:format=duration", "-of", "default=noprint_wrappers=1", path)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return fmt.Errorf("ffprobe cannot read it: %s", detail)
+		}
+		return fmt.Errorf("ffprobe cannot read it: %w", err)
+	}
+	return judgeIntegrityProbe(out.String())
+}
+
+// judgeIntegrityProbe checks the "key=value" lines of ffprobe
+// -show_entries stream=codec_type:format=duration.
+func judgeIntegrityProbe(output string) error {
+	hasVideo := false
+	duration := 0.0
+	for _, line := range strings.Split(output, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "codec_type":
+			hasVideo = hasVideo || value == "video"
+		case "duration":
+			duration, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	if !hasVideo {
+		return fmt.Errorf("it has no video stream")
+	}
+	if duration <= 0 {
+		return fmt.Errorf("it has no duration")
+	}
+	return nil
+}
+
+// checkMoovAtom walks the top-level boxes of an mp4 or mov file and returns
+// an error when there is no moov box, or when a box runs past the end of the
+// file (a file cut short).
+func checkMoovAtom(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	for offset := int64(0); offset < size; {
+		var header [16]byte
+		if _, err := file.ReadAt(header[:8], offset); err != nil {
+			return fmt.Errorf("the file is cut short at byte %d", offset)
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		switch boxSize {
+		case 0:
+			// The box runs to the end of the file.
+			boxSize = size - offset
+		case 1:
+			if _, err := file.ReadAt(header[8:16], offset+8); err != nil && err != io.EOF {
+				return err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+		if boxType == "moov" {
+			if offset+boxSize > size {
+				return fmt.Errorf("the moov atom is cut short")
+			}
+			return nil
+		}
+		if boxSize < 8 || offset+boxSize > size {
+			return fmt.Errorf("the file is cut short in its %q box, no moov atom", boxType)
+		}
+		offset += boxSize
+	}
+	return fmt.Errorf("no moov atom")
+}
+
+// rejectBrokenReplay keeps a replay that failed its integrity check out of
+// the video list, keeps the recording it was trimmed from so that it can be
+// trimmed again, and reports the error to the operator.
+func rejectBrokenReplay(cameraNumber int, recording, replay string, problem error) {
+	logging.ErrorLogger.Printf("Camera %d: trimmed replay %s is broken: %v", cameraNumber, replay, problem)
+	if err := os.Rename(replay, replay+brokenSuffix); err != nil && !os.IsNotExist(err) {
+		logging.ErrorLogger.Printf("Camera %d: cannot set aside the broken replay %s: %v", cameraNumber, replay, err)
+	}
+	kept := ""
+	if recording != "" {
+		kept = ", the recording is kept as " + filepath.Base(keepRecording(recording))
+	}
+	httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Camera %d replay is broken (%v)%s", cameraNumber, problem, kept))
+}
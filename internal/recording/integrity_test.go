@@ -0,0 +1,66 @@
+package recording
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// box returns an mp4 box of a type with size bytes of payload.
+func box(boxType string, size int) []byte {
+	data := make([]byte, 8+size)
+	binary.BigEndian.PutUint32(data, uint32(8+size))
+	copy(data[4:], boxType)
+	return data
+}
+
+func TestCheckMoovAtomFindsCutShortFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, boxes ...[]byte) string {
+		var data []byte
+		for _, b := range boxes {
+			data = append(data, b...)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if err := checkMoovAtom(write("ok.mp4", box("ftyp", 16), box("mdat", 100), box("moov", 40))); err != nil {
+		t.Fatalf("complete file: %v", err)
+	}
+	if err := checkMoovAtom(write("faststart.mp4", box("ftyp", 16), box("moov", 40), box("mdat", 100))); err != nil {
+		t.Fatalf("faststart file: %v", err)
+	}
+	if err := checkMoovAtom(write("nomoov.mp4", box("ftyp", 16), box("mdat", 100))); err == nil || !strings.Contains(err.Error(), "no moov atom") {
+		t.Fatalf("file without moov: %v", err)
+	}
+	cut := box("mdat", 100)[:60]
+	if err := checkMoovAtom(write("cut.mp4", box("ftyp", 16), cut)); err == nil || !strings.Contains(err.Error(), "cut short") {
+		t.Fatalf("file cut in mdat: %v", err)
+	}
+}
+
+func TestEmptyAndStreamlessReplaysAreBroken(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "replay.mp4")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkReplayIntegrity(empty); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Fatalf("empty replay: %v", err)
+	}
+
+	if err := judgeIntegrityProbe("codec_type=video\ncodec_type=audio\nduration=5.120000\n"); err != nil {
+		t.Fatalf("good replay: %v", err)
+	}
+	if err := judgeIntegrityProbe("codec_type=audio\nduration=5.120000\n"); err == nil {
+		t.Fatalf("a replay without video was accepted")
+	}
+	if err := judgeIntegrityProbe("codec_type=video\nduration=N/A\n"); err == nil {
+		t.Fatalf("a replay without duration was accepted")
+	}
+}
@@ -0,0 +1,247 @@
+package recording
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/state"
+)
+
+// longRecordingSuffix labels the files of a long recording so that they are
+// listed apart from attempt replays.
+const longRecordingSuffix = "long_recording"
+
+// longRecording is a continuous recording of all cameras started on request
+// (records, award ceremonies). It is saved whole: the trim pipeline is not
+// used, and attempts are not recorded separately while it runs.
+type longRecording struct {
+	label     string
+	session   string
+	started   time.Time
+	cmds      []*exec.Cmd
+	stdins    []*os.File
+	fileNames []string
+	cameras   []int
+	// resumeBuffers restarts the pre-buffers, which give the cameras up to
+	// the long recording.
+	resumeBuffers bool
+}
+
+var (
+	currentLong   *longRecording
+	currentLongMu sync.Mutex
+)
+
+// IsLongRecording reports whether a long recording is running.
+func IsLongRecording() bool {
+	currentLongMu.Lock()
+	defer currentLongMu.Unlock()
+	return currentLong != nil
+}
+
+// IsLongRecordingFile reports whether a file in the video directory holds a
+// long recording, which the cleanup of leftover attempt files must keep.
+func IsLongRecordingFile(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), longRecordingSuffix+"_")
+}
+
+// longRecordingLabel turns an operator-supplied label into a file name part.
+func longRecordingLabel(label string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(label))
+	cleaned = strings.Trim(cleaned, "_")
+	for strings.Contains(cleaned, "__") {
+		cleaned = strings.ReplaceAll(cleaned, "__", "_")
+	}
+	return cleaned
+}
+
+// StartLongRecording starts recording all cameras until StopLongRecording is
+// called. An attempt being recorded is stopped first; its footage continues
+// in the long recording.
+func StartLongRecording(label string) error {
+	currentLongMu.Lock()
+	defer currentLongMu.Unlock()
+	if currentLong != nil {
+		return fmt.Errorf("a long recording is already running")
+	}
+	if Trimming {
+		return fmt.Errorf("videos are being trimmed, try again in a few seconds")
+	}
+	cameras := config.GetCameraConfigs()
+	if len(cameras) == 0 {
+		return fmt.Errorf("no camera configurations available")
+	}
+	if Recording {
+		logging.InfoLogger.Println("Stopping the attempt recording to start a long recording")
+		if _, err := StopRecording(); err != nil {
+			logging.ErrorLogger.Printf("Error stopping attempt recording: %v", err)
+		}
+	}
+	if err := os.MkdirAll(config.GetRecordingDir(), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	long := &longRecording{
+		label:         longRecordingLabel(label),
+		session:       state.CurrentSession,
+		started:       time.Now(),
+		resumeBuffers: StopPreBuffers(),
+	}
+	var failures []string
+	for i, camera := range cameras {
+		cameraNumber := i + 1
+		fileName := filepath.Join(config.GetRecordingDir(), fmt.Sprintf("%s_Camera%d_%d.mkv", longRecordingSuffix, cameraNumber, long.started.Unix()))
+		args := buildRecordingArgs(fileName, camera)
+		if config.NoVideo {
+			logging.InfoLogger.Printf("Simulating start of long recording for Camera %d: %v", cameraNumber, args)
+			long.fileNames = append(long.fileNames, fileName)
+			long.cameras = append(long.cameras, cameraNumber)
+			continue
+		}
+		cmd, stdin, err := startCameraRecording(cameraNumber, args, nil)
+		if err != nil {
+			logging.ErrorLogger.Printf("Camera %d will not be in the long recording: %v", cameraNumber, err)
+			failures = append(failures, fmt.Sprintf("Camera %d failed to start", cameraNumber))
+			continue
+		}
+		long.cmds = append(long.cmds, cmd)
+		long.stdins = append(long.stdins, stdin)
+		long.fileNames = append(long.fileNames, fileName)
+		long.cameras = append(long.cameras, cameraNumber)
+	}
+	if len(long.fileNames) == 0 {
+		if long.resumeBuffers {
+			resumePreBuffers()
+		}
+		httpServer.SendStatus(httpServer.Error, fmt.Sprintf("Error: No camera could be started for the long recording (%s)", strings.Join(failures, "; ")))
+		return fmt.Errorf("failed to start ffmpeg for all cameras")
+	}
+	currentLong = long
+	preemptIdleRecodes()
+
+	statusMessage := "Long recording in progress"
+	if long.label != "" {
+		statusMessage += ": " + strings.ReplaceAll(long.label, "_", " ")
+	}
+	if len(failures) > 0 {
+		statusMessage += fmt.Sprintf(" (Warning: %s)", strings.Join(failures, "; "))
+	}
+	httpServer.SendStatus(httpServer.Recording, statusMessage)
+	logging.InfoLogger.Printf("Started long recording: %v", long.fileNames)
+	return nil
+}
+
+// StopLongRecording stops the long recording and saves the complete videos
+// in the session folder. It returns the saved files.
+func StopLongRecording() ([]string, error) {
+	currentLongMu.Lock()
+	long := currentLong
+	currentLong = nil
+	currentLongMu.Unlock()
+	if long == nil {
+		return nil, fmt.Errorf("no long recording is running")
+	}
+
+	if !config.NoVideo {
+		stopRecorders(long.cmds, long.stdins, long.cameras)
+	}
+	if long.resumeBuffers {
+		resumePreBuffers()
+	}
+
+	sessionDir := httpServer.SessionFolder(long.session)
+	fullSessionDir, err := sessionWorkDir(sessionDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	httpServer.SendStatus(httpServer.Trimming, "Saving long recording")
+	Trimming = true
+	defer func() { Trimming = false }()
+
+	timestamp := httpServer.ReplayTimestamp(long.started)
+	name := longRecordingSuffix
+	if long.label != "" {
+		name = long.label + "_" + longRecordingSuffix
+	}
+	var saved []string
+	var failed []string
+	for i, fileName := range long.fileNames {
+		finalFileName := filepath.Join(fullSessionDir, fmt.Sprintf("%s_%s_Camera%d.mp4", timestamp, name, long.cameras[i]))
+		err = saveLongRecording(fileName, finalFileName)
+		if err == nil && !config.NoVideo {
+			err = storeSessionFile(sessionDir, finalFileName)
+		}
+		if err != nil {
+			logging.ErrorLogger.Printf("Failed to save long recording for Camera %d: %v", long.cameras[i], err)
+			failed = append(failed, fmt.Sprintf("Camera %d: %v", long.cameras[i], err))
+			if !config.NoVideo {
+				keepRecording(fileName)
+			}
+			continue
+		}
+		saved = append(saved, finalFileName)
+	}
+
+	duration := time.Since(long.started).Round(time.Second)
+	if len(failed) > 0 {
+		httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Long recording not saved for %d camera(s), the .mkv files are kept in %s", len(failed), config.GetVideoDir()))
+		return saved, fmt.Errorf("failed to save long recording: %s", strings.Join(failed, "; "))
+	}
+	httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Long recording saved (%s, %d camera(s))", duration, len(saved)))
+	logging.InfoLogger.Printf("Saved long recording: %v", saved)
+	return saved, nil
+}
+
+// saveLongRecording copies the recorded streams into an mp4 that browsers can
+// play, without re-encoding or trimming.
+func saveLongRecording(currentFileName, finalFileName string) error {
+	if config.NoVideo {
+		logging.InfoLogger.Printf("Simulating save of long recording: %s -> %s", currentFileName, finalFileName)
+		return nil
+	}
+	args := []string{"-y", "-i", currentFileName, "-c", "copy", "-movflags", "+faststart", finalFileName}
+	cmd := CreateFfmpegCmd(args, "longrecording", "error")
+	logFile, output := captureTrimOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		if output != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(output.String()))
+		}
+		return fmt.Errorf("%w, see %s", err, logFile)
+	}
+	return os.Remove(currentFileName)
+}
+
+// terminateLongRecording stops a running long recording on shutdown. The
+// .mkv files are left in the video directory, untouched by the cleanup.
+func terminateLongRecording() {
+	currentLongMu.Lock()
+	long := currentLong
+	currentLong = nil
+	currentLongMu.Unlock()
+	if long == nil || config.NoVideo {
+		return
+	}
+	logging.WarningLogger.Printf("Long recording interrupted by shutdown, unsaved files: %v", long.fileNames)
+	stopRecorders(long.cmds, long.stdins, long.cameras)
+}
+
+func resumePreBuffers() {
+	if err := StartPreBuffers(); err != nil {
+		logging.ErrorLogger.Printf("Failed to restart pre-buffering: %v", err)
+	}
+}
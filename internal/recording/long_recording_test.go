@@ -0,0 +1,26 @@
+package recording
+
+import "testing"
+
+func TestLongRecordingLabel(t *testing.T) {
+	tests := map[string]string{
+		"":                       "",
+		"World record":           "World_record",
+		"  Snatch WR / Cat. 71 ": "Snatch_WR_Cat_71",
+		"Médailles 2026-10":      "Médailles_2026-10",
+	}
+	for label, want := range tests {
+		if got := longRecordingLabel(label); got != want {
+			t.Errorf("longRecordingLabel(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestIsLongRecordingFile(t *testing.T) {
+	if !IsLongRecordingFile("/videos/long_recording_Camera1_1760000000.mkv") {
+		t.Fatal("long recording file not recognized")
+	}
+	if IsLongRecordingFile("/videos/DOE_John_SNATCH_attempt1_Camera1_1760000000.mkv") {
+		t.Fatal("attempt file taken for a long recording")
+	}
+}
@@ -0,0 +1,211 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// The combined video of an attempt fits in 1920x1080: with n cameras, each
+// one gets a cell of 1920/n x 1080/n, side by side or one above the other.
+const (
+	multiAngleWidth  = 1920
+	multiAngleHeight = 1080
+)
+
+// multiAngleInput is a replay of the attempt to combine.
+type multiAngleInput struct {
+	file string
+	// lead is how much later the replay starts than the earliest one; it is
+	// held on its first frame for that long to stay in sync.
+	lead time.Duration
+}
+
+// buildMultiAngleArgs builds the ffmpeg arguments that stack the replays of
+// an attempt with layout, "hstack" or "vstack". The sound is dropped: the
+// cameras would each play it.
+func buildMultiAngleArgs(inputs []multiAngleInput, layout, output string) []string {
+	n := len(inputs)
+	cellWidth := multiAngleWidth / n / 2 * 2
+	cellHeight := multiAngleHeight / n / 2 * 2
+	var args []string
+	var filter, stacked strings.Builder
+	for i, input := range inputs {
+		args = append(args, "-i", input.file)
+		fmt.Fprintf(&filter, "[%d:v]", i)
+		if input.lead > 0 {
+			fmt.Fprintf(&filter, "tpad=start_duration=%.3f:start_mode=clone,", input.lead.Seconds())
+		}
+		fmt.Fprintf(&filter, "scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1[v%d];",
+			cellWidth, cellHeight, cellWidth, cellHeight, i)
+		fmt.Fprintf(&stacked, "[v%d]", i)
+	}
+	fmt.Fprintf(&filter, "%s%s=inputs=%d[v]", stacked.String(), layout, n)
+
+	args = append([]string{"-y"}, args...)
+	args = append(args,
+		"-filter_complex", filter.String(),
+		"-map", "[v]",
+		"-an",
+		"-c:v", "libx264",
+	)
+	args = append(args, config.X264Args(1)...)
+	return append(args,
+		"-crf", "20",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		output,
+	)
+}
+
+// makeMultiAngle combines the replays of an attempt into one video when
+// multiAngle is set, in the background once the replays are ready.
+// finalFileNames is indexed like the recordings; failed trims are skipped.
+func makeMultiAngle(finalFileNames []string, sessionDir string) {
+	layout := config.MultiAngle
+	if layout == "" || config.NoVideo {
+		return
+	}
+	var replays []string
+	var offsetsMs []int64
+	for i, name := range finalFileNames {
+		if name == "" || recordingCameraNumber(i) == config.ScoreboardCameraNumber {
+			continue
+		}
+		if _, err := storage.Current().Stat(storage.Join(sessionDir, filepath.Base(name))); err == nil {
+			replays = append(replays, filepath.Base(name))
+			offsetsMs = append(offsetsMs, int64(config.GetCameraConfig(recordingCameraNumber(i)).TrimOffsetMs))
+		}
+	}
+	if len(replays) < 2 {
+		return
+	}
+	combined := httpServer.MultiAngleName(replays[0])
+	if combined == "" {
+		return
+	}
+	if config.DeferRecodes {
+		// Queued after the re-encodes of the replays.
+		deferRecode(fmt.Sprintf("combined video %s", combined), func() error {
+			return combineReplays(sessionDir, replays, offsetsMs, layout, combined, runIdle)
+		})
+		return
+	}
+	go func() {
+		// One background encode at a time, slow motion included.
+		slowMotionMu.Lock()
+		defer slowMotionMu.Unlock()
+		if err := combineReplays(sessionDir, replays, offsetsMs, layout, combined, (*exec.Cmd).Run); err != nil {
+			logging.ErrorLogger.Printf("Failed to make %s: %v", combined, err)
+		}
+	}()
+}
+
+// multiAngleLeads returns the lead of each replay of an attempt. The
+// recordings are stopped together, but a camera with a trim offset shows the
+// platform that much late: its replay ends offsetsMs earlier on the platform
+// and, cut on a keyframe, starts durationsMs before that. Replays whose
+// duration is unknown are not held.
+func multiAngleLeads(durationsMs, offsetsMs []int64) []time.Duration {
+	var latestStartMs int64
+	for i, durationMs := range durationsMs {
+		if durationMs > 0 && durationMs+offsetsMs[i] > latestStartMs {
+			latestStartMs = durationMs + offsetsMs[i]
+		}
+	}
+	leads := make([]time.Duration, len(durationsMs))
+	for i, durationMs := range durationsMs {
+		if durationMs > 0 {
+			leads[i] = time.Duration(latestStartMs-durationMs-offsetsMs[i]) * time.Millisecond
+		}
+	}
+	return leads
+}
+
+// combineReplays writes and stores the combined video of stored replays,
+// cut with the trim offsets offsetsMs, running ffmpeg with run.
+func combineReplays(sessionDir string, replays []string, offsetsMs []int64, layout, combined string, run func(*exec.Cmd) error) error {
+	workDir, err := sessionWorkDir(sessionDir)
+	if err != nil {
+		return err
+	}
+	inputs := make([]multiAngleInput, len(replays))
+	durations := make([]int64, len(replays))
+	for i, replay := range replays {
+		file, cleanup, err := localReplay(sessionDir, replay, workDir)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		inputs[i].file = file
+		if probe, ok := probeTrimmedVideo(file); ok {
+			durations[i] = probe.durationMs
+		}
+	}
+	for i, lead := range multiAngleLeads(durations, offsetsMs) {
+		inputs[i].lead = lead
+	}
+
+	output := filepath.Join(workDir, combined)
+	// Not listed while it is written: the list shows it once stored.
+	temp := output + ".tmp"
+	defer os.Remove(temp)
+	cmd := CreateFfmpegCmd(buildMultiAngleArgs(inputs, layout, temp), "multiangle", "error")
+	logFile, stderr := captureTrimOutput(cmd)
+	if err := run(cmd); err != nil {
+		if errors.Is(err, errPreempted) {
+			return err
+		}
+		if stderr != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("%w, see %s", err, logFile)
+	}
+	if err := os.Rename(temp, output); err != nil {
+		return err
+	}
+	if err := storeSessionFile(sessionDir, output); err != nil {
+		return err
+	}
+	logging.InfoLogger.Printf("Combined video %s of %d cameras ready", combined, len(replays))
+	return nil
+}
+
+// localReplay returns a local file with a stored replay, copied into
+// workDir when the storage keeps no local files, and what removes the copy.
+func localReplay(sessionDir, replay, workDir string) (string, func(), error) {
+	name := storage.Join(sessionDir, replay)
+	if path, ok := storage.Current().LocalPath(name); ok {
+		return path, func() {}, nil
+	}
+	input, err := storage.Current().Open(name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer input.Close()
+	copyFile, err := os.CreateTemp(workDir, ".multiangle-*"+filepath.Ext(replay))
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(copyFile.Name()) }
+	_, err = io.Copy(copyFile, input)
+	if closeErr := copyFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return copyFile.Name(), cleanup, nil
+}
@@ -0,0 +1,48 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMultiAngleArgsStackTheCamerasInSync(t *testing.T) {
+	args := strings.Join(buildMultiAngleArgs([]multiAngleInput{
+		{file: "cam1.mp4"},
+		{file: "cam2.mp4", lead: 250 * time.Millisecond},
+	}, "hstack", "out.tmp"), " ")
+	for _, want := range []string{
+		"-i cam1.mp4 -i cam2.mp4",
+		"[0:v]scale=960:540:force_original_aspect_ratio=decrease,pad=960:540",
+		"[1:v]tpad=start_duration=0.250:start_mode=clone,scale=960:540",
+		"[v0][v1]hstack=inputs=2[v]",
+		"-an",
+	} {
+		if !strings.Contains(args, want) {
+			t.Fatalf("missing %q in %s", want, args)
+		}
+	}
+	if !strings.HasSuffix(args, "-f mp4 out.tmp") {
+		t.Fatalf("output must be forced to mp4: %s", args)
+	}
+
+	three := strings.Join(buildMultiAngleArgs([]multiAngleInput{{file: "a"}, {file: "b"}, {file: "c"}}, "vstack", "out.tmp"), " ")
+	if !strings.Contains(three, "scale=640:360:") || !strings.Contains(three, "vstack=inputs=3[v]") {
+		t.Fatalf("three cameras one above the other: %s", three)
+	}
+}
+
+func TestMultiAngleLeadsFollowTheTrimOffsets(t *testing.T) {
+	// Camera 2 shows the platform 400ms late: cut on the same keyframes, its
+	// replay is 400ms shorter and starts with the others.
+	leads := multiAngleLeads([]int64{10_000, 9_600}, []int64{0, 400})
+	if leads[0] != 0 || leads[1] != 0 {
+		t.Fatalf("leads = %v, want none", leads)
+	}
+	// Camera 1 was cut 300ms later on its keyframe; the unknown replay is
+	// left as it is.
+	leads = multiAngleLeads([]int64{9_700, 9_600, 0}, []int64{0, 400, 0})
+	if leads[0] != 300*time.Millisecond || leads[1] != 0 || leads[2] != 0 {
+		t.Fatalf("leads = %v, want 300ms on the first replay", leads)
+	}
+}
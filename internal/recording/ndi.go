@@ -0,0 +1,165 @@
+package recording
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// NDI sources are read with the libndi_newtek input device, which only
+// exists in ffmpeg builds made with the NewTek NDI SDK (ffmpeg 4.3 and
+// earlier, or patched builds). Sources are found on the network and
+// recorded by name, such as "PRODUCTION (Camera 1)"; their video is raw and
+// encoded to H.264 like that of a USB camera.
+const (
+	ndiFormat = "libndi_newtek"
+	ndiPixFmt = "uyvy422"
+	// ndiDefaultFps is used when a source cannot be probed.
+	ndiDefaultFps = 30
+)
+
+// ndiSource is an NDI source announced on the network.
+type ndiSource struct {
+	name    string
+	address string
+}
+
+var (
+	ndiDevicePattern = regexp.MustCompile(`(?m)^\s*D\S*\s+libndi_newtek\s`)
+	ndiSourcePattern = regexp.MustCompile(`'([^']+)'\s+'([^']+)'`)
+
+	// ndiSupport remembers which ffmpeg builds have the NDI input.
+	ndiSupportMu sync.Mutex
+	ndiSupport   = make(map[string]bool)
+)
+
+// isNDISource reports whether a camera is read from NDI.
+func isNDISource(camera config.CameraConfiguration) bool {
+	return camera.Format == ndiFormat
+}
+
+// ffmpegHasNDI reports whether the output of ffmpeg -devices lists the NDI
+// input device.
+func ffmpegHasNDI(devices string) bool {
+	return ndiDevicePattern.MatchString(devices)
+}
+
+// parseNDISources parses the sources listed by
+// ffmpeg -f libndi_newtek -find_sources 1.
+func parseNDISources(output string) []ndiSource {
+	var sources []ndiSource
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, ndiFormat) {
+			continue
+		}
+		match := ndiSourcePattern.FindStringSubmatch(line)
+		if match == nil || seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		sources = append(sources, ndiSource{name: match[1], address: match[2]})
+	}
+	return sources
+}
+
+// findNDISources lists the NDI sources on the network, or nothing when
+// ffmpeg was built without NDI.
+func findNDISources(ffmpegPath string) []ndiSource {
+	ndiSupportMu.Lock()
+	supported, known := ndiSupport[ffmpegPath]
+	ndiSupportMu.Unlock()
+	if !known {
+		cmd := jobutil.Command(ffmpegPath, "-hide_banner", "-devices")
+		var devices bytes.Buffer
+		cmd.Stdout = &devices
+		cmd.Stderr = &devices
+		supported = cmd.Run() == nil && ffmpegHasNDI(devices.String())
+		if !supported {
+			logging.InfoLogger.Printf("NDI: %s has no libndi_newtek input, NDI sources are not searched", ffmpegPath)
+		}
+		ndiSupportMu.Lock()
+		ndiSupport[ffmpegPath] = supported
+		ndiSupportMu.Unlock()
+	}
+	if !supported {
+		return nil
+	}
+
+	// ffmpeg lists the sources and then exits with an error, since no input
+	// was opened.
+	cmd := jobutil.Command(ffmpegPath, "-hide_banner", "-f", ndiFormat, "-find_sources", "1", "-i", "dummy")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	_ = cmd.Run()
+	sources := parseNDISources(out.String())
+	logging.InfoLogger.Printf("NDI: found %d source(s)", len(sources))
+	return sources
+}
+
+// probeNDISource returns the size and frame rate of an NDI source.
+func probeNDISource(name string) (string, int) {
+	ffprobePath := resolveFFprobePath(config.GetFFmpegPath())
+	if ffprobePath == "" {
+		return "", ndiDefaultFps
+	}
+	cmd := jobutil.Command(ffprobePath, "-v", "error", "-f", ndiFormat, "-select_streams", "v:0",
+		"-show_entries", "stream=width,height,avg_frame_rate", "-of", "default=noprint_wrappers=1", "-i", name)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		logging.WarningLogger.Printf("NDI: cannot probe %q: %v", name, err)
+		return "", ndiDefaultFps
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(out.String(), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			values[key] = value
+		}
+	}
+	size := ""
+	if values["width"] != "" && values["height"] != "" {
+		size = values["width"] + "x" + values["height"]
+	}
+	fps := ndiDefaultFps
+	if numerator, denominator, ok := strings.Cut(values["avg_frame_rate"], "/"); ok && atoi(denominator) > 0 && atoi(numerator) > 0 {
+		fps = (atoi(numerator) + atoi(denominator)/2) / atoi(denominator)
+	}
+	return size, fps
+}
+
+// detectNDICameras returns the NDI sources as cameras.
+func detectNDICameras(progress ProbeProgressFunc, skip func(name, matchKey, attachmentPath string) bool) []DetectedCamera {
+	if progress != nil {
+		progress(ProgressMsg(ProgListing, "NDI sources"))
+	}
+	var cameras []DetectedCamera
+	for _, source := range findNDISources(config.GetFFmpegPath()) {
+		matchKey := "ndi:" + source.name
+		if skip != nil && skip(source.name, matchKey, "") {
+			continue
+		}
+		if progress != nil {
+			progress(ProgressMsg(ProgLocalSource, source.name))
+		}
+		size, fps := probeNDISource(source.name)
+		cameras = append(cameras, DetectedCamera{
+			Name:             source.name,
+			Device:           source.name,
+			Format:           ndiFormat,
+			PixFmt:           ndiPixFmt,
+			Size:             size,
+			Fps:              fps,
+			MatchKey:         matchKey,
+			Identity:         source.address,
+			SupportedFormats: []string{ndiPixFmt},
+		})
+	}
+	return cameras
+}
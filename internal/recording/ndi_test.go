@@ -0,0 +1,30 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestNDISourcesAreFoundAndRecordedByName(t *testing.T) {
+	devices := "Devices:\n D. = Demuxing supported\n --\n D  lavfi           Libavfilter virtual input device\n D  libndi_newtek   Network Device Interface (NDI) input using NewTek library\n"
+	if !ffmpegHasNDI(devices) || ffmpegHasNDI(" D  lavfi           Libavfilter virtual input device\n") {
+		t.Fatalf("ffmpegHasNDI() did not recognize the NDI input device")
+	}
+
+	output := "[libndi_newtek @ 0x5612] Found 2 NDI sources:\n" +
+		"[libndi_newtek @ 0x5612] \t'PRODUCTION (Camera 1)'\t'192.168.1.20:5961'\n" +
+		"[libndi_newtek @ 0x5612] \t'PRODUCTION (Camera 2)'\t'192.168.1.20:5962'\n" +
+		"dummy: Input/output error\n"
+	sources := parseNDISources(output)
+	if len(sources) != 2 || sources[1].name != "PRODUCTION (Camera 2)" || sources[1].address != "192.168.1.20:5962" {
+		t.Fatalf("parseNDISources() = %+v", sources)
+	}
+
+	camera := config.CameraConfiguration{FfmpegCamera: "PRODUCTION (Camera 1)", Format: ndiFormat, Size: "1920x1080", Fps: 50, OutputParameters: "-c:v libx264 -an"}
+	args := strings.Join(buildRecordingArgs("attempt.mkv", camera), " ")
+	if !strings.Contains(args, "-f libndi_newtek -i PRODUCTION (Camera 1)") || strings.Contains(args, "-s 1920x1080") {
+		t.Fatalf("recording args = %q", args)
+	}
+}
@@ -0,0 +1,75 @@
+package recording
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// originalsDir is the folder of the video directory where the untrimmed
+// recordings are archived, in a folder per session. It is hidden so that the
+// video list does not take it for a session.
+const originalsDir = ".originals"
+
+// archivedOriginal describes an archived recording, in a .json file next to
+// it, to trim its replay again.
+type archivedOriginal struct {
+	Original      string `json:"original"` // file name in the archive folder
+	Replay        string `json:"replay"`   // trimmed replay, relative to the video directory
+	Camera        int    `json:"camera"`
+	Athlete       string `json:"athlete,omitempty"`
+	LiftType      string `json:"liftType,omitempty"`
+	Attempt       int    `json:"attempt,omitempty"`
+	RecordingFrom string `json:"recordingFrom"` // when the recording started
+	RecordingTo   string `json:"recordingTo"`   // when it was stopped
+	KeptMs        int64  `json:"keptMs"`        // length kept from its end, the camera's trimOffsetMs applied
+	Archived      string `json:"archived"`
+}
+
+// releaseOriginal disposes of the untrimmed recording of a trimmed replay:
+// archived with archiveOriginals, deleted otherwise.
+func releaseOriginal(cameraNumber int, currentFileName, sessionDir, finalFileName string, keptMs, startTime, stopTime int64, details httpServer.StatusAttemptDetails) error {
+	if !config.KeepOriginals {
+		return os.Remove(currentFileName)
+	}
+	dir := filepath.Join(config.GetVideoDir(), originalsDir, filepath.FromSlash(sessionDir))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	replay := filepath.Base(finalFileName)
+	// Named after the replay, so that they are found together.
+	base := strings.TrimSuffix(replay, filepath.Ext(replay))
+	original := base + filepath.Ext(currentFileName)
+	if err := moveFile(currentFileName, filepath.Join(dir, original)); err != nil {
+		return err
+	}
+
+	record := archivedOriginal{
+		Original:      original,
+		Replay:        storage.Join(sessionDir, replay),
+		Camera:        cameraNumber,
+		Athlete:       details.AthleteName,
+		LiftType:      details.LiftType,
+		Attempt:       details.AttemptNumber,
+		RecordingFrom: time.UnixMilli(startTime).Format(time.RFC3339Nano),
+		RecordingTo:   time.UnixMilli(stopTime).Format(time.RFC3339Nano),
+		KeptMs:        keptMs,
+		Archived:      time.Now().Format(time.RFC3339),
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, base+".json"), data, 0644); err != nil {
+		return err
+	}
+	logging.InfoLogger.Printf("Camera %d: untrimmed recording archived as %s", cameraNumber, filepath.Join(dir, original))
+	return nil
+}
@@ -0,0 +1,59 @@
+package recording
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+)
+
+func TestOriginalIsArchivedWithItsReplay(t *testing.T) {
+	videoDir := t.TempDir()
+	oldVideoDir, oldKeep := config.GetVideoDir(), config.KeepOriginals
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir); config.KeepOriginals = oldKeep })
+
+	recording := filepath.Join(t.TempDir(), "Camera1_1700000000000.mkv")
+	if err := os.WriteFile(recording, []byte("raw"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	replay := filepath.Join(videoDir, "A", "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4")
+	details := httpServer.StatusAttemptDetails{AthleteName: "John Smith", LiftType: "SNATCH", AttemptNumber: 2}
+
+	config.KeepOriginals = true
+	if err := releaseOriginal(1, recording, "A", replay, 9000, 1700000000000, 1700000030000, details); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(recording); !os.IsNotExist(err) {
+		t.Fatalf("the recording was not moved: %v", err)
+	}
+	dir := filepath.Join(videoDir, originalsDir, "A")
+	if _, err := os.Stat(filepath.Join(dir, "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mkv")); err != nil {
+		t.Fatalf("original not archived: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record archivedOriginal
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Replay != "A/2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4" || record.KeptMs != 9000 || record.Camera != 1 || record.Attempt != 2 {
+		t.Fatalf("record = %+v", record)
+	}
+
+	config.KeepOriginals = false
+	if err := os.WriteFile(recording, []byte("raw"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := releaseOriginal(1, recording, "A", replay, 9000, 0, 0, details); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(recording); !os.IsNotExist(err) {
+		t.Fatalf("the recording must be deleted without archiveOriginals: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package recording
+
+import (
+	"fmt"
+
+	"github.com/owlcms/replays/internal/httpServer"
+)
+
+// overlayText is the line burned into the replays of an attempt, so that a
+// replay shared outside the competition tells what it shows.
+func overlayText(details httpServer.StatusAttemptDetails) string {
+	text := fmt.Sprintf("%s - %s attempt %d", details.AthleteName, httpServer.LiftName(details.LiftType), details.AttemptNumber)
+	if details.Weight > 0 {
+		text += fmt.Sprintf(" - %d kg", details.Weight)
+	}
+	return text
+}
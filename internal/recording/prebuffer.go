@@ -0,0 +1,339 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// In pre-buffer mode every camera is captured without interruption into the
+// short segment files of a rolling buffer. A start message only marks the
+// in-point of the attempt; at the decision the segments covering the attempt
+// are joined into the usual .mkv, which is then trimmed as if it had been
+// recorded for the attempt. Starting ffmpeg on every start message loses the
+// first seconds to process startup and to waiting for a keyframe.
+const (
+	preBufferSegment     = 2 * time.Second
+	preBufferRestartWait = 2 * time.Second
+	preBufferSegmentName = "segment_%06d.mkv"
+)
+
+// segmentFile is a file of a rolling buffer and the time it was last written,
+// which is when the footage it holds ends.
+type segmentFile struct {
+	path string
+	end  time.Time
+}
+
+var (
+	preBufferMu      sync.Mutex
+	preBufferRunning map[int]bool
+	preBufferStop    chan struct{}
+	preBufferWG      sync.WaitGroup
+	// preBufferHold keeps the segments that end after it, so that those of
+	// the attempt being recorded are not pruned however long it lasts.
+	preBufferHold time.Time
+)
+
+// preBufferDir is where the segments of a camera are written, in the
+// recording directory when one is configured.
+func preBufferDir(cameraNumber int) string {
+	if config.HasRecordingDir() {
+		return filepath.Join(config.GetRecordingDir(), "prebuffer", fmt.Sprintf("Camera%d", cameraNumber))
+	}
+	return filepath.Join(config.GetInstallDir(), "prebuffer", fmt.Sprintf("Camera%d", cameraNumber))
+}
+
+// buildPreBufferArgs records a camera as buildRecordingArgs does, but into
+// numbered segment files that each start on a keyframe.
+func buildPreBufferArgs(dir string, camera config.CameraConfiguration) []string {
+	args := buildRecordingArgs(filepath.Join(dir, preBufferSegmentName), camera)
+	output := args[len(args)-1]
+	args = append(args[:len(args)-1],
+		"-f", "segment",
+		"-segment_time", fmt.Sprintf("%.0f", preBufferSegment.Seconds()),
+		"-segment_format", "matroska",
+		"-reset_timestamps", "1",
+		output,
+	)
+	return args
+}
+
+// attemptCameraNumbers returns the cameras recorded for an attempt: the
+// configured cameras and the attempt board (camera 0) when there is one.
+func attemptCameraNumbers() []int {
+	var numbers []int
+	for i := range config.GetCameraConfigs() {
+		numbers = append(numbers, i+1)
+	}
+	if config.GetScoreboardConfig() != nil {
+		numbers = append(numbers, config.ScoreboardCameraNumber)
+	}
+	return numbers
+}
+
+// StartPreBuffers starts the rolling buffers of all cameras when
+// preBufferSeconds is set. Buffers whose ffmpeg exits are restarted.
+func StartPreBuffers() error {
+	if config.GetPreBuffer() <= 0 || config.NoVideo || config.Viewer {
+		return nil
+	}
+	preBufferMu.Lock()
+	defer preBufferMu.Unlock()
+	if preBufferStop != nil {
+		return nil
+	}
+	cameraNumbers := attemptCameraNumbers()
+	if len(cameraNumbers) == 0 {
+		return fmt.Errorf("no camera configurations available")
+	}
+
+	stop := make(chan struct{})
+	preBufferStop = stop
+	preBufferRunning = make(map[int]bool)
+	for _, cameraNumber := range cameraNumbers {
+		dir := preBufferDir(cameraNumber)
+		if err := os.RemoveAll(dir); err != nil {
+			logging.WarningLogger.Printf("Failed to clear the pre-buffer of Camera %d: %v", cameraNumber, err)
+		}
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create pre-buffer directory: %w", err)
+		}
+		preBufferWG.Add(1)
+		go superviseBuffer(cameraNumber, *config.GetCameraConfig(cameraNumber), dir, stop)
+	}
+	preBufferWG.Add(1)
+	go prunePreBuffers(cameraNumbers, stop)
+	logging.InfoLogger.Printf("Pre-buffering %d camera(s), keeping %s of footage", len(cameraNumbers), config.GetPreBuffer())
+	return nil
+}
+
+// StopPreBuffers stops the rolling buffers and reports whether they were
+// running.
+func StopPreBuffers() bool {
+	preBufferMu.Lock()
+	stop := preBufferStop
+	preBufferStop = nil
+	preBufferMu.Unlock()
+	if stop == nil {
+		return false
+	}
+	close(stop)
+	preBufferWG.Wait()
+	logging.InfoLogger.Println("Pre-buffering stopped")
+	return true
+}
+
+// RestartPreBuffers applies a new camera configuration to the buffers.
+func RestartPreBuffers() error {
+	StopPreBuffers()
+	return StartPreBuffers()
+}
+
+// preBuffersRunning reports whether every camera has a running buffer.
+func preBuffersRunning(cameraNumbers []int) bool {
+	preBufferMu.Lock()
+	defer preBufferMu.Unlock()
+	if preBufferStop == nil || len(cameraNumbers) == 0 {
+		return false
+	}
+	for _, cameraNumber := range cameraNumbers {
+		if !preBufferRunning[cameraNumber] {
+			return false
+		}
+	}
+	return true
+}
+
+func setPreBufferRunning(cameraNumber int, running bool) {
+	preBufferMu.Lock()
+	defer preBufferMu.Unlock()
+	if preBufferRunning != nil {
+		preBufferRunning[cameraNumber] = running
+	}
+}
+
+func holdPreBuffers(from time.Time) {
+	preBufferMu.Lock()
+	defer preBufferMu.Unlock()
+	preBufferHold = from
+}
+
+func releasePreBufferHold() {
+	holdPreBuffers(time.Time{})
+}
+
+// superviseBuffer runs the buffer ffmpeg of a camera until stop is closed,
+// restarting it when it exits on its own (stream lost, camera unplugged).
+func superviseBuffer(cameraNumber int, camera config.CameraConfiguration, dir string, stop chan struct{}) {
+	defer preBufferWG.Done()
+	for {
+		cmd, stdin, err := startCameraRecording(cameraNumber, buildPreBufferArgs(dir, camera), nil)
+		if err == nil {
+			setPreBufferRunning(cameraNumber, true)
+			done := make(chan error, 1)
+			go func() { done <- cmd.Wait() }()
+			select {
+			case <-stop:
+				stopBuffer(cameraNumber, cmd, stdin, done)
+				setPreBufferRunning(cameraNumber, false)
+				return
+			case err = <-done:
+				setPreBufferRunning(cameraNumber, false)
+			}
+		}
+		logging.WarningLogger.Printf("Pre-buffer of Camera %d stopped (%v), restarting in %s", cameraNumber, err, preBufferRestartWait)
+		httpServer.SendStatus(httpServer.Error, fmt.Sprintf("Warning: Camera %d stopped recording, restarting it", cameraNumber))
+		select {
+		case <-stop:
+			return
+		case <-time.After(preBufferRestartWait):
+		}
+	}
+}
+
+// stopBuffer asks the buffer ffmpeg to finish its segment, killing it if it
+// does not exit promptly.
+func stopBuffer(cameraNumber int, cmd *exec.Cmd, stdin *os.File, done chan error) {
+	if killed, _ := StopFFmpeg(cmd, stdin, done, stopGrace); killed {
+		logging.WarningLogger.Printf("The pre-buffer of Camera %d did not stop gracefully and was killed", cameraNumber)
+	}
+}
+
+// listSegments returns the segments of a buffer, oldest first. The last one
+// is still being written.
+func listSegments(dir string) []segmentFile {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var segments []segmentFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), "segment_") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segmentFile{path: filepath.Join(dir, entry.Name()), end: info.ModTime()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].path < segments[j].path })
+	return segments
+}
+
+// prunePreBuffers deletes the segments that are older than the buffer length
+// and not held for the attempt being recorded.
+func prunePreBuffers(cameraNumbers []int, stop chan struct{}) {
+	defer preBufferWG.Done()
+	ticker := time.NewTicker(preBufferSegment)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			cutoff := now.Add(-config.GetPreBuffer())
+			preBufferMu.Lock()
+			if !preBufferHold.IsZero() && preBufferHold.Before(cutoff) {
+				cutoff = preBufferHold
+			}
+			preBufferMu.Unlock()
+			for _, cameraNumber := range cameraNumbers {
+				segments := listSegments(preBufferDir(cameraNumber))
+				for _, segment := range expiredSegments(segments, cutoff) {
+					os.Remove(segment.path)
+				}
+			}
+		}
+	}
+}
+
+// expiredSegments returns the finished segments whose footage ends before
+// cutoff.
+func expiredSegments(segments []segmentFile, cutoff time.Time) []segmentFile {
+	var expired []segmentFile
+	for i, segment := range segments {
+		if i < len(segments)-1 && segment.end.Before(cutoff) {
+			expired = append(expired, segment)
+		}
+	}
+	return expired
+}
+
+// selectSegments returns the segments holding the footage from from to to,
+// and whether the last one reaches to.
+func selectSegments(segments []segmentFile, from, to time.Time) ([]segmentFile, bool) {
+	var selected []segmentFile
+	for _, segment := range segments {
+		if segment.end.Before(from) {
+			continue
+		}
+		selected = append(selected, segment)
+		if !segment.end.Before(to) {
+			return selected, true
+		}
+	}
+	return selected, false
+}
+
+// extractPreBuffered joins the segments of a camera covering from to to into
+// target, once the segment holding to is finished. It returns when the
+// footage of target ends.
+func extractPreBuffered(cameraNumber int, from, to time.Time, target string) (time.Time, error) {
+	dir := preBufferDir(cameraNumber)
+	deadline := to.Add(2*preBufferSegment + time.Second)
+	var selected []segmentFile
+	for {
+		segments := listSegments(dir)
+		if len(segments) > 0 {
+			// The last segment is still being written.
+			var complete bool
+			selected, complete = selectSegments(segments[:len(segments)-1], from, to)
+			if complete {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			logging.WarningLogger.Printf("Camera %d: the pre-buffer has no finished segment after the decision, using the segment being written", cameraNumber)
+			selected, _ = selectSegments(segments, from, to)
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	if len(selected) == 0 {
+		return time.Time{}, fmt.Errorf("the pre-buffer of Camera %d holds no footage of the attempt", cameraNumber)
+	}
+
+	var list strings.Builder
+	for _, segment := range selected {
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(segment.path, "'", `'\''`))
+	}
+	listFile := filepath.Join(dir, "attempt.txt")
+	if err := os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return time.Time{}, err
+	}
+	defer os.Remove(listFile)
+
+	args := []string{"-y", "-f", "concat", "-safe", "0", "-i", listFile, "-c", "copy", target}
+	cmd := CreateFfmpegCmd(args, "prebuffer", "error")
+	var stderr bytes.Buffer
+	if cmd.Stderr == nil {
+		cmd.Stderr = &stderr
+	}
+	logging.InfoLogger.Printf("Camera %d: joining %d pre-buffer segment(s) into %s", cameraNumber, len(selected), target)
+	if err := cmd.Run(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to join the pre-buffer of Camera %d: %w %s", cameraNumber, err, strings.TrimSpace(stderr.String()))
+	}
+	return selected[len(selected)-1].end, nil
+}
@@ -0,0 +1,47 @@
+package recording
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestPreBufferSegmentsCoveringAnAttempt(t *testing.T) {
+	base := time.Date(2025, 3, 1, 14, 0, 0, 0, time.Local)
+	var segments []segmentFile
+	for i := 1; i <= 6; i++ {
+		segments = append(segments, segmentFile{path: string(rune('a' + i)), end: base.Add(time.Duration(2*i) * time.Second)})
+	}
+
+	// Footage from 3s to 7s is in the segments ending at 4s, 6s and 8s.
+	selected, complete := selectSegments(segments, base.Add(3*time.Second), base.Add(7*time.Second))
+	if !complete || len(selected) != 3 || selected[0].end != base.Add(4*time.Second) {
+		t.Fatalf("selectSegments() = %v, %t", selected, complete)
+	}
+	if _, complete := selectSegments(segments, base, base.Add(20*time.Second)); complete {
+		t.Fatalf("footage after the last segment cannot be complete")
+	}
+
+	// The segment being written is never pruned.
+	if expired := expiredSegments(segments, base.Add(time.Hour)); len(expired) != len(segments)-1 {
+		t.Fatalf("expiredSegments() pruned %d segments, want %d", len(expired), len(segments)-1)
+	}
+}
+
+func TestPreBufferArgsWriteSegments(t *testing.T) {
+	args := buildPreBufferArgs("buffer", config.CameraConfiguration{Format: "mpegts", FfmpegCamera: "udp://0.0.0.0:9001"})
+	if last := args[len(args)-1]; last != filepath.Join("buffer", preBufferSegmentName) {
+		t.Fatalf("output = %s, want the segment pattern", last)
+	}
+	found := false
+	for i, arg := range args {
+		if arg == "-f" && args[i+1] == "segment" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("args %v do not use the segment muxer", args)
+	}
+}
@@ -0,0 +1,99 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// proxyName is the name of the lower-bitrate copy of a replay.
+func proxyName(replay string, variant config.ProxyVariant) string {
+	return fmt.Sprintf("%s_%s.mp4", strings.TrimSuffix(replay, filepath.Ext(replay)), variant.Name())
+}
+
+// buildProxyArgs builds the ffmpeg arguments that copy the replay read on
+// stdin at the size and bitrate of variant. Smaller replays keep their size.
+func buildProxyArgs(variant config.ProxyVariant, output string) []string {
+	rate := fmt.Sprintf("%dk", variant.Kbps)
+	args := []string{"-y", "-i", "pipe:0",
+		"-filter:v", fmt.Sprintf("scale=-2:'min(%d,ih)'", variant.Height),
+		"-c:v", "libx264",
+	}
+	args = append(args, config.X264Args(1)...)
+	return append(args,
+		"-b:v", rate, "-maxrate", rate, "-bufsize", fmt.Sprintf("%dk", 2*variant.Kbps),
+		"-profile:v", "main",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "96k",
+		"-movflags", "+faststart",
+		output,
+	)
+}
+
+// makeProxies makes the lower-bitrate copies of a saved replay of a session,
+// in the background, one encode at a time with the slow-motion copies.
+func makeProxies(cameraNumber int, sessionDir, replay string) {
+	variants := config.ProxyVariants
+	if len(variants) == 0 || config.NoVideo {
+		return
+	}
+	if _, err := storage.Current().Stat(storage.Join(sessionDir, replay)); err != nil {
+		// The trim failed.
+		return
+	}
+	if config.DeferRecodes {
+		// Queued after the re-encode of the replay, if any.
+		deferRecode(fmt.Sprintf("proxies of %s", replay), func() error {
+			return copyAllProxies(cameraNumber, sessionDir, replay, variants, runIdle)
+		})
+		return
+	}
+	go func() {
+		slowMotionMu.Lock()
+		defer slowMotionMu.Unlock()
+		copyAllProxies(cameraNumber, sessionDir, replay, variants, (*exec.Cmd).Run)
+	}()
+}
+
+// copyAllProxies makes and stores the copies of a replay in each of variants,
+// running ffmpeg with run. It stops at the first errPreempted.
+func copyAllProxies(cameraNumber int, sessionDir, replay string, variants []config.ProxyVariant, run func(*exec.Cmd) error) error {
+	workDir, err := sessionWorkDir(sessionDir)
+	if err != nil {
+		logging.ErrorLogger.Printf("Camera %d: cannot make proxies of %s: %v", cameraNumber, replay, err)
+		return nil
+	}
+	for _, variant := range variants {
+		output := filepath.Join(workDir, proxyName(replay, variant))
+		if err := copyProxy(storage.Join(sessionDir, replay), variant, output, run); errors.Is(err, errPreempted) {
+			return err
+		} else if err != nil {
+			logging.ErrorLogger.Printf("Camera %d: failed to make the %s proxy of %s: %v", cameraNumber, variant.Name(), replay, err)
+			continue
+		}
+		if err := storeSessionFile(sessionDir, output); err != nil {
+			logging.ErrorLogger.Printf("Camera %d: failed to store the %s proxy of %s: %v", cameraNumber, variant.Name(), replay, err)
+			continue
+		}
+		logging.InfoLogger.Printf("Camera %d: %s proxy of %s ready", cameraNumber, variant.Name(), replay)
+	}
+	return nil
+}
+
+// copyProxy writes the copy of a stored replay in variant.
+func copyProxy(name string, variant config.ProxyVariant, output string, run func(*exec.Cmd) error) error {
+	input, err := storage.Current().Open(name)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	cmd := CreateFfmpegCmd(buildProxyArgs(variant, output), "proxy")
+	cmd.Stdin = input
+	return run(cmd)
+}
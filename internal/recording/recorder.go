@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -21,6 +22,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/owlcms/replays/internal/config"
 	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/jobutil"
 	"github.com/owlcms/replays/internal/logging"
 	"github.com/owlcms/replays/internal/state"
 )
@@ -29,9 +31,19 @@ var (
 	currentRecordings []*exec.Cmd
 	currentStdin      []*os.File
 	currentFileNames  []string
+	currentCameras    []int // camera number of each entry in currentFileNames
 	currentAttempt    httpServer.StatusAttemptDetails
+	currentBuffered   bool // the attempt is taken from the pre-buffers
 )
 
+// recordingCameraNumber returns the camera number of the i-th running recording.
+func recordingCameraNumber(i int) int {
+	if i < len(currentCameras) {
+		return currentCameras[i]
+	}
+	return i + 1
+}
+
 // cleanParams splits a parameter string and removes outer quotes from each parameter
 func cleanParams(params string) []string {
 	fields := strings.Fields(params)
@@ -52,8 +64,8 @@ func cleanParams(params string) []string {
 func buildRecordingArgs(fileName string, camera config.CameraConfiguration) []string {
 	args := []string{"-y", "-f", camera.Format}
 
-	// Check if the source is a UDP stream
-	isUdpSource := strings.HasPrefix(camera.FfmpegCamera, "udp:")
+	// Check if the source is a UDP, SRT or NDI stream
+	isUdpSource := strings.HasPrefix(camera.FfmpegCamera, "udp:") || strings.HasPrefix(camera.FfmpegCamera, "srt:") || isNDISource(camera)
 
 	// Input parameters (before -i)
 	if camera.InputParameters != "" {
@@ -71,15 +83,24 @@ func buildRecordingArgs(fileName string, camera config.CameraConfiguration) []st
 
 	// Input source
 	args = append(args, "-i", camera.FfmpegCamera)
+	if camera.Audio != nil && camera.Audio.Device != "" {
+		args = append(args, audioInputArgs(camera.Audio.Device)...)
+	}
 
 	// Output parameters (after -i)
+	var output []string
 	if camera.OutputParameters != "" {
-		args = append(args, cleanParams(camera.OutputParameters)...)
+		output = append(output, cleanParams(camera.OutputParameters)...)
 	}
 	// Treat legacy params as additional output parameters
 	if camera.Params != "" {
-		args = append(args, cleanParams(camera.Params)...)
+		output = append(output, cleanParams(camera.Params)...)
 	}
+	if camera.Audio != nil {
+		args = append(args, audioMapArgs(camera.Audio)...)
+		output = append(withoutNoAudio(output), audioCodecArgs(camera.Audio)...)
+	}
+	args = append(args, output...)
 
 	args = append(args, fileName)
 	return args
@@ -95,8 +116,27 @@ func buildRecordingArgs(fileName string, camera config.CameraConfiguration) []st
 // IDR on the UDP stream. The end of the file, however, is always "now" — so
 // keeping the last N seconds is independent of recorder startup latency.
 func buildTrimmingArgs(keepFromEndMs int64, currentFileName, finalFileName string, camera config.CameraConfiguration) []string {
+	return buildTrimmingArgsWith(trimEncoderFor(camera), keepFromEndMs, currentFileName, finalFileName, camera)
+}
+
+// buildTrimmingArgsWith builds the trimming arguments, re-encoding with the
+// hardware encoder enc when the camera needs recoding, or libx264 when enc is nil.
+func buildTrimmingArgsWith(enc *HwEncoder, keepFromEndMs int64, currentFileName, finalFileName string, camera config.CameraConfiguration) []string {
 	args := []string{"-y"}
 	// Note: InputParameters are NOT used during trimming as they are for camera capture only
+	profile := camera.Trim
+	if camera.TrimRecodes() {
+		// The trim section of the camera, or the replay size, re-encodes
+		// with libx264, or with an HEVC encoder when it only asks for hevc.
+		camera.Recode = true
+		if enc == nil || enc.Codec() != "hevc" || !hevcOnlyTrim(camera) {
+			enc = nil
+		}
+	}
+	if camera.Recode && enc != nil {
+		input, _ := hwTrimArgs(enc)
+		args = append(args, input...)
+	}
 
 	if keepFromEndMs > 0 {
 		// -sseof takes a NEGATIVE value meaning "seek N seconds before end of file".
@@ -107,81 +147,422 @@ func buildTrimmingArgs(keepFromEndMs int64, currentFileName, finalFileName strin
 	// Input file
 	args = append(args, "-i", currentFileName)
 
-	if camera.Recode {
+	if camera.Recode && enc != nil {
+		// The hardware encoder settings of ffmpeg.toml, which are much
+		// faster than libx264 when several cameras are trimmed together.
+		logging.InfoLogger.Printf("Recode is enabled for camera: %s, using %s", camera.FfmpegCamera, enc.Name)
+		_, output := hwTrimArgs(enc)
+		args = append(args, output...)
+		args = append(args, "-avoid_negative_ts", "make_zero")
+		if camera.Audio != nil {
+			args = append(args, audioTrimArgs(camera.Audio)...)
+		}
+	} else if camera.Recode {
 		// When recoding, use software encoder to convert to H.264
 		// Do NOT use OutputParameters here as they are for recording, not transcoding
 		logging.InfoLogger.Printf("Recode is enabled for camera: %s", camera.FfmpegCamera)
-		args = append(args,
-			"-c:v", "libx264",
-			"-crf", "18",
-			"-preset", "ultrafast",
-			"-profile:v", "main",
-			"-pix_fmt", "yuv420p",
-			"-avoid_negative_ts", "make_zero",
-		)
+		if filter := camera.TrimFilter(); filter != "" {
+			args = append(args, "-vf", filter)
+		}
+		args = append(args, softwareCodecArgs(profile)...)
+		args = append(args, "-avoid_negative_ts", "make_zero")
+		if camera.Audio != nil {
+			args = append(args, audioTrimArgs(camera.Audio)...)
+		}
 	} else {
 		// When not recoding, just copy the stream (already in H.264 format)
 		args = append(args,
 			"-c", "copy",
 			"-avoid_negative_ts", "make_zero",
-			"-movflags", "+faststart",
 		)
 	}
 
+	args = append(args, containerArgs(finalFileName)...)
 	args = append(args, finalFileName)
 	return args
 }
 
-// probeVideoDurationMs runs ffprobe against a finalized video file and returns
-// its actual duration in milliseconds. Returns 0 (and logs a warning) if the
-// duration cannot be determined; callers should fall back to the requested
-// trim length in that case.
-func probeVideoDurationMs(filePath string) int64 {
+// softwareCodecArgs returns the options re-encoding a replay with the codec
+// of its trim section: libx264 by default, libx265 or ProRes.
+func softwareCodecArgs(profile *config.TrimProfile) []string {
+	// The cameras trimmed at the same time share the processor.
+	x264 := config.X264Args(trimParallelism())
+	if profile != nil && profile.Preset != "" {
+		x264[1] = profile.Preset // X264Args starts with -preset
+	}
+	crf := 0
+	if profile != nil && profile.Crf > 0 {
+		crf = profile.Crf
+	}
+	switch profile.VideoCodec() {
+	case "hevc":
+		if crf == 0 {
+			crf = 22
+		}
+		// libx265 knows the presets of libx264, not all its tunes.
+		args := []string{"-c:v", "libx265", "-crf", strconv.Itoa(crf), "-preset", x264[1], "-threads", x264[len(x264)-1]}
+		// hvc1 is the tag QuickTime and Apple editors need.
+		return append(args, "-tag:v", "hvc1", "-pix_fmt", "yuv420p")
+	case "prores":
+		// ProRes 422 HQ: constant quality, no crf nor preset.
+		return []string{"-c:v", "prores_ks", "-profile:v", "3", "-vendor", "apl0", "-pix_fmt", "yuv422p10le"}
+	}
+	if crf == 0 {
+		crf = 18
+	}
+	args := append([]string{"-c:v", "libx264", "-crf", strconv.Itoa(crf)}, x264...)
+	return append(args, "-profile:v", "main", "-pix_fmt", "yuv420p")
+}
+
+// containerArgs returns the muxer options of a replay file: mp4 and mov
+// files start with their index so that they play while being downloaded.
+func containerArgs(finalFileName string) []string {
+	switch strings.ToLower(filepath.Ext(finalFileName)) {
+	case ".mp4", ".mov":
+		return []string{"-movflags", "+faststart"}
+	}
+	return nil
+}
+
+// Tolerances used by verifyTrimmedCut. With -c copy the cut snaps to the
+// previous keyframe (1-second GOP), so a clip can legitimately run up to about
+// a second longer than requested; anything well beyond that means the seek
+// went wrong (typically a missing index in a file that was still being
+// finalized) and the whole recording was copied.
+const (
+	trimOverrunToleranceMs int64 = 2500
+	trimStartToleranceMs   int64 = 500
+)
+
+// trimProbe holds the ffprobe measurements of a trimmed clip.
+type trimProbe struct {
+	durationMs int64
+	startMs    int64
+}
+
+// probeTrimmedVideo runs ffprobe against a finalized video file and returns
+// its actual duration and first presentation timestamp in milliseconds.
+// Returns ok=false (and logs a warning) if the values cannot be determined;
+// callers should fall back to the requested trim length in that case.
+func probeTrimmedVideo(filePath string) (trimProbe, bool) {
 	ffmpegPath := config.GetFFmpegPath()
 	ffprobePath := resolveFFprobePath(ffmpegPath)
 	if ffprobePath == "" {
 		logging.WarningLogger.Printf("ffprobe path not resolved; cannot probe duration of %s", filePath)
-		return 0
+		return trimProbe{}, false
 	}
-	cmd := CreateHiddenCmd(ffprobePath,
+	cmd := jobutil.Command(ffprobePath,
 		"-v", "error",
-		"-show_entries", "format=duration",
-		"-of", "default=noprint_wrappers=1:nokey=1",
+		"-show_entries", "format=duration,start_time",
+		"-of", "default=noprint_wrappers=1",
 		filePath,
 	)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	if err := cmd.Run(); err != nil {
 		logging.WarningLogger.Printf("ffprobe failed for %s: %v", filePath, err)
-		return 0
+		return trimProbe{}, false
+	}
+	probe, err := parseFFprobeFormat(out.String())
+	if err != nil {
+		logging.WarningLogger.Printf("ffprobe output parse failed for %s (raw=%q): %v", filePath, strings.TrimSpace(out.String()), err)
+		return trimProbe{}, false
+	}
+	return probe, true
+}
+
+// parseFFprobeFormat parses "key=value" lines produced by
+// ffprobe -show_entries format=duration,start_time -of default=noprint_wrappers=1.
+func parseFFprobeFormat(output string) (trimProbe, error) {
+	var probe trimProbe
+	foundDuration := false
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || value == "N/A" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return trimProbe{}, fmt.Errorf("invalid %s value %q: %w", key, value, err)
+		}
+		switch key {
+		case "duration":
+			probe.durationMs = int64(seconds*1000.0 + 0.5)
+			foundDuration = true
+		case "start_time":
+			probe.startMs = int64(seconds*1000.0 + 0.5)
+		}
+	}
+	if !foundDuration {
+		return trimProbe{}, fmt.Errorf("no duration reported")
+	}
+	return probe, nil
+}
+
+// verifyTrimmedCut compares the probed clip against what the trim asked for
+// and returns an error describing why the cut looks wrong, or nil.
+func verifyTrimmedCut(keepFromEndMs int64, probe trimProbe) error {
+	if probe.durationMs <= 0 {
+		return fmt.Errorf("clip has zero duration")
+	}
+	if keepFromEndMs > 0 && probe.durationMs > keepFromEndMs+trimOverrunToleranceMs {
+		return fmt.Errorf("clip is %dms long, expected about %dms", probe.durationMs, keepFromEndMs)
+	}
+	if probe.startMs > trimStartToleranceMs || probe.startMs < -trimStartToleranceMs {
+		return fmt.Errorf("first frame is at %dms instead of 0", probe.startMs)
+	}
+	return nil
+}
+
+// Backoff bounds used while waiting for a finished recording to be readable.
+const (
+	trimPollInitialDelay = 100 * time.Millisecond
+	trimPollMaxDelay     = 2 * time.Second
+)
+
+// trimInputReadable reports whether ffprobe can read the container of a
+// finished recording. Replaced in tests.
+var trimInputReadable = probeContainerReadable
+
+func nextTrimBackoff(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > trimPollMaxDelay {
+		delay = trimPollMaxDelay
+	}
+	return delay
+}
+
+// probeContainerReadable returns nil when ffprobe can open the file and read
+// its container headers (i.e. the recording ffmpeg has finalized it).
+func probeContainerReadable(path string) error {
+	ffprobePath := resolveFFprobePath(config.GetFFmpegPath())
+	if ffprobePath == "" {
+		return nil
+	}
+	cmd := jobutil.Command(ffprobePath, "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return fmt.Errorf("%w: %s", err, detail)
+		}
+		return err
+	}
+	return nil
+}
+
+// ProbeVideoDuration returns the duration of a video file, or an error when
+// ffprobe cannot read it as a video (httpServer.ErrProbeUnavailable without
+// ffprobe).
+func ProbeVideoDuration(path string) (time.Duration, error) {
+	ffprobePath := resolveFFprobePath(config.GetFFmpegPath())
+	if ffprobePath == "" {
+		return 0, httpServer.ErrProbeUnavailable
+	}
+	cmd := jobutil.Command(ffprobePath, "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=codec_type:format=duration", "-of", "default=noprint_wrappers=1", path)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return 0, fmt.Errorf("%w: %s", err, detail)
+		}
+		return 0, err
+	}
+	output := out.String()
+	if !strings.Contains(output, "codec_type=video") {
+		return 0, fmt.Errorf("no video stream")
+	}
+	probe, err := parseFFprobeFormat(strings.Replace(output, "codec_type=video", "", 1))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(probe.durationMs) * time.Millisecond, nil
+}
+
+// waitForTrimInput polls the untrimmed recording with exponential backoff
+// until its size has stopped changing and its container can be read, or the
+// timeout expires.
+func waitForTrimInput(cameraNumber int, path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := trimPollInitialDelay
+	lastSize := int64(-1)
+	var reason error
+	for {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			reason = err
+			lastSize = -1
+		case info.Size() == 0:
+			reason = fmt.Errorf("file is empty")
+			lastSize = 0
+		case info.Size() != lastSize:
+			reason = fmt.Errorf("file is still growing (%d bytes)", info.Size())
+			lastSize = info.Size()
+		default:
+			if reason = trimInputReadable(path); reason == nil {
+				return nil
+			}
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("input video for Camera %d not ready after %s: %w", cameraNumber, timeout, reason)
+		}
+		logging.InfoLogger.Printf("Waiting %s for input video for Camera %d: %v", delay, cameraNumber, reason)
+		time.Sleep(delay)
+		delay = nextTrimBackoff(delay)
+	}
+}
+
+// runTrim waits for the recording to be finalized, then runs the trimming
+// ffmpeg command, retrying with backoff until the configured trim wait expires.
+func runTrim(cameraNumber int, keepFromEndMs int64, currentFileName, finalFileName string, camera config.CameraConfiguration) error {
+	if config.NoVideo {
+		args := buildTrimmingArgs(keepFromEndMs, currentFileName, finalFileName, camera)
+		logging.InfoLogger.Printf("Simulating trim command for Camera %d: %s", cameraNumber, CreateFfmpegCmd(args, "trimming").String())
+		return nil
+	}
+
+	timeout := config.GetTrimWait()
+	waitErr := waitForTrimInput(cameraNumber, currentFileName, timeout)
+	if waitErr != nil && needsRepair(currentFileName) {
+		logging.WarningLogger.Printf("%v; trying to repair it", waitErr)
+		if err := repairRecording(cameraNumber, currentFileName); err != nil {
+			return err
+		}
+		waitErr = nil
+	} else if waitErr != nil {
+		logging.WarningLogger.Printf("%v; trying to trim anyway", waitErr)
+	}
+
+	// Without ffmpeg logging the trim would run with -loglevel quiet; keep
+	// its errors so a failure report can show them.
+	logLevel := ""
+	if !config.GetLogFfmpeg() {
+		logLevel = "error"
+	}
+
+	encoder := trimEncoderFor(camera)
+	var attempts []trimAttempt
+	deadline := time.Now().Add(timeout)
+	delay := trimPollInitialDelay
+	for attempt := 1; ; attempt++ {
+		args := buildTrimmingArgsWith(encoder, keepFromEndMs, currentFileName, finalFileName, camera)
+		cmd := CreateFfmpegCmd(args, "trimming", logLevel)
+		logFile, output := captureTrimOutput(cmd)
+		attemptLogOf(currentFileName).capture(cmd, cameraNumber, "trimming")
+
+		if attempt == 1 {
+			logging.InfoLogger.Printf("Executing trim command for Camera %d: %s", cameraNumber, cmd.String())
+		}
+
+		err := cmd.Run()
+		if err == nil {
+			return nil
+		}
+		record := trimAttempt{command: cmd.String(), logFile: logFile, err: err}
+		if output != nil {
+			record.output = output.String()
+		}
+		attempts = append(attempts, record)
+
+		if encoder != nil {
+			// A busy or misbehaving GPU must not cost the replay.
+			logging.WarningLogger.Printf("Trim with %s failed for Camera %d, re-encoding with libx264: %v", encoder.Name, cameraNumber, err)
+			encoder = nil
+			continue
+		}
+		if _, _, _, _, cropped := camera.CropRegion(); cropped {
+			// Nor may a crop region that does not fit the picture.
+			logging.WarningLogger.Printf("Trim with the crop region %q failed for Camera %d, trimming the whole picture: %v", camera.Trim.Crop, cameraNumber, err)
+			uncropped := *camera.Trim
+			uncropped.Crop = ""
+			camera.Trim = &uncropped
+			continue
+		}
+		if time.Now().Add(delay).After(deadline) {
+			failure := &TrimFailure{Attempts: attempt, Err: err}
+			report, reportErr := writeTrimFailureReport(cameraNumber, currentFileName, finalFileName, waitErr, attempts)
+			if reportErr != nil {
+				logging.ErrorLogger.Printf("Failed to write trim failure report for Camera %d: %v", cameraNumber, reportErr)
+			}
+			failure.Report = report
+			return failure
+		}
+		logging.ErrorLogger.Printf("Trim attempt %d failed for Camera %d, retrying in %s: %v", attempt, cameraNumber, delay, err)
+		time.Sleep(delay)
+		delay = nextTrimBackoff(delay)
+	}
+}
+
+// retrimWithRecode re-runs a trim with re-encoding after the stream-copy cut
+// failed verification. Re-encoding decodes from the seek point, so the cut no
+// longer depends on where the keyframes fall. The operator is alerted either
+// way since the replay may be late or still wrong.
+func retrimWithRecode(cameraNumber int, keepFromEndMs int64, currentFileName, finalFileName string, camera config.CameraConfiguration, probe trimProbe, cutErr error) trimProbe {
+	logging.WarningLogger.Printf("Camera %d: trimmed clip %s failed verification: %v", cameraNumber, finalFileName, cutErr)
+	if camera.Recode || camera.TrimRecodes() {
+		httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Camera %d replay cut looks wrong (%v)", cameraNumber, cutErr))
+		return probe
+	}
+
+	logging.InfoLogger.Printf("Camera %d: retrying trim with re-encoding", cameraNumber)
+	recodeCamera := camera
+	recodeCamera.Recode = true
+	if err := runTrim(cameraNumber, keepFromEndMs, currentFileName, finalFileName, recodeCamera); err != nil {
+		logging.ErrorLogger.Printf("Camera %d: re-encoding trim failed: %v", cameraNumber, err)
+		httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Camera %d replay cut looks wrong (%v) and re-encoding failed", cameraNumber, cutErr))
+		return probe
 	}
-	trimmed := strings.TrimSpace(out.String())
-	if trimmed == "" {
-		logging.WarningLogger.Printf("ffprobe returned no duration for %s", filePath)
-		return 0
+
+	recoded, ok := probeTrimmedVideo(finalFileName)
+	if !ok {
+		httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Warning: Camera %d replay was re-encoded after a bad cut (%v)", cameraNumber, cutErr))
+		return trimProbe{}
 	}
-	seconds, err := strconv.ParseFloat(trimmed, 64)
-	if err != nil || seconds <= 0 {
-		logging.WarningLogger.Printf("ffprobe duration parse failed for %s (raw=%q): %v", filePath, trimmed, err)
-		return 0
+	if err := verifyTrimmedCut(keepFromEndMs, recoded); err != nil {
+		logging.ErrorLogger.Printf("Camera %d: re-encoded clip %s still failed verification: %v", cameraNumber, finalFileName, err)
+		httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Camera %d replay cut looks wrong even after re-encoding (%v)", cameraNumber, err))
+		return recoded
 	}
-	return int64(seconds*1000.0 + 0.5)
+	logging.InfoLogger.Printf("Camera %d: re-encoded clip passed verification (%dms)", cameraNumber, recoded.durationMs)
+	httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Warning: Camera %d replay was re-encoded after a bad cut (%v)", cameraNumber, cutErr))
+	return recoded
 }
 
 // StartRecording starts recording videos using ffmpeg for all configured cameras
 func StartRecording(fullName, liftTypeKey string, attemptNumber int) error {
+	if err := diskFullError(); err != nil {
+		httpServer.SendStatus(httpServer.Error, err.Error())
+		return err
+	}
 	Recording = true
+	preemptIdleRecodes()
+	serial := atomic.AddInt64(&recordingSerial, 1)
+	received := state.StartReceivedAt
+	if received.IsZero() {
+		received = time.Now()
+	}
 	cameras := config.GetCameraConfigs()
 	if len(cameras) == 0 {
 		return fmt.Errorf("no camera configurations available")
 	}
 
-	if err := os.MkdirAll(config.GetVideoDir(), os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create video directory: %w", err)
+	if err := os.MkdirAll(config.GetRecordingDir(), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	if config.BeginAttemptLog() {
+		logging.InfoLogger.Printf("Detailed ffmpeg logs of this attempt are written to %s", filepath.Join(config.GetInstallDir(), "logs"))
 	}
 
-	for i := range cameras {
-		cameraNumber := i + 1
+	// The attempt board, when configured, is recorded with the cameras as
+	// camera 0 so the jury has the official clock and weight for every lift.
+	allCameras := attemptCameraNumbers()
+	buffered := !config.NoVideo && preBuffersRunning(allCameras)
+
+	for _, cameraNumber := range allCameras {
 		if err := httpServer.ClearPublishedReplayState(cameraNumber); err != nil {
 			logging.ErrorLogger.Printf("Failed to clear published replay state at recording start for Camera %d: %v", cameraNumber, err)
 		}
@@ -193,71 +574,160 @@ func StartRecording(fullName, liftTypeKey string, attemptNumber int) error {
 		AthleteName:   displayName,
 		LiftType:      liftTypeKey,
 		AttemptNumber: attemptNumber,
+		Weight:        state.CurrentWeight,
+		StartedAt:     time.Now().UnixNano() / int64(time.Millisecond),
 	}
 
+	log := newAttemptLog(currentAttempt, time.Now())
 	fullName = strings.ReplaceAll(fullName, " ", "_")
 
 	var cmds []*exec.Cmd
 	var stdins []*os.File
 	var fileNames []string
-
-	for i, camera := range cameras {
-		fileName := filepath.Join(config.GetVideoDir(), fmt.Sprintf("%s_%s_attempt%d_Camera%d_%d.mkv", fullName, liftTypeKey, attemptNumber, i+1, state.LastStartTime))
+	var cameraNumbers []int
+	var cameraStates []httpServer.RecordingCamera
+	var failures []string
+
+	// Cameras are started best-effort: a camera whose ffmpeg cannot be
+	// started is reported and skipped so the others still produce replays.
+	for _, cameraNumber := range allCameras {
+		camera := *config.GetCameraConfig(cameraNumber)
+		fileName := filepath.Join(config.GetRecordingDir(), fmt.Sprintf("%s_%s_attempt%d_Camera%d_%d.mkv", fullName, liftTypeKey, attemptNumber, cameraNumber, state.LastStartTime))
 		args := buildRecordingArgs(fileName, camera)
 
 		if config.NoVideo {
 			cmd := CreateFfmpegCmd(args, "recording")
-			logging.InfoLogger.Printf("Simulating start recording video for Camera %d: %s", i+1, cmd.String())
-			logging.InfoLogger.Printf("ffmpeg command for Camera %d: %s", i+1, cmd.String())
+			logging.InfoLogger.Printf("Simulating start recording video for Camera %d: %s", cameraNumber, cmd.String())
+			logging.InfoLogger.Printf("ffmpeg command for Camera %d: %s", cameraNumber, cmd.String())
 			fileNames = append(fileNames, fileName)
+			cameraNumbers = append(cameraNumbers, cameraNumber)
+			cameraStates = append(cameraStates, httpServer.RecordingCamera{Camera: cameraNumber, Status: httpServer.CameraRecording})
 			state.LastTimerStopTime = 0
 			continue
 		}
 
-		cmd := CreateFfmpegCmd(args, "recording")
-		stdin, err := cmd.StdinPipe()
-		if err != nil {
-			return fmt.Errorf("failed to create stdin pipe for Camera %d: %w", i+1, err)
+		if buffered {
+			// The footage is joined from the pre-buffer at the decision.
+			fileNames = append(fileNames, fileName)
+			cameraNumbers = append(cameraNumbers, cameraNumber)
+			cameraStates = append(cameraStates, httpServer.RecordingCamera{Camera: cameraNumber, Status: httpServer.CameraRecording})
+			continue
 		}
 
-		logging.InfoLogger.Printf("Executing command for Camera %d: %s", i+1, cmd.String())
-		if err := cmd.Start(); err != nil {
-			stdin.Close()
-			return fmt.Errorf("failed to start ffmpeg for Camera %d: %w", i+1, err)
+		cmd, stdin, err := startCameraRecording(cameraNumber, args, log)
+		if err != nil {
+			logging.ErrorLogger.Printf("Camera %d will not be recorded: %v", cameraNumber, err)
+			failures = append(failures, fmt.Sprintf("Camera %d failed to start", cameraNumber))
+			cameraStates = append(cameraStates, httpServer.RecordingCamera{Camera: cameraNumber, Status: httpServer.CameraFailed})
+			continue
 		}
 
+		registerAttemptLog(fileName, log)
 		cmds = append(cmds, cmd)
-		stdins = append(stdins, stdin.(*os.File))
+		stdins = append(stdins, stdin)
 		fileNames = append(fileNames, fileName)
+		cameraNumbers = append(cameraNumbers, cameraNumber)
+		cameraStates = append(cameraStates, httpServer.RecordingCamera{Camera: cameraNumber, Status: httpServer.CameraRecording})
 	}
 
+	currentAttempt.Cameras = cameraStates
+
+	recordersMutex.Lock()
 	currentRecordings = cmds
 	currentStdin = stdins
 	currentFileNames = fileNames
+	currentCameras = cameraNumbers
+	currentParts = nil
+	recordersMutex.Unlock()
+	currentBuffered = buffered
 	state.LastTimerStopTime = 0
+	if buffered {
+		holdPreBuffers(time.Unix(0, currentAttempt.StartedAt*int64(time.Millisecond)))
+	}
+
+	if len(fileNames) == 0 {
+		Recording = false
+		config.EndAttemptLog()
+		httpServer.SendStatus(httpServer.Error, fmt.Sprintf("Error: No camera could be started (%s)", strings.Join(failures, "; ")))
+		return fmt.Errorf("failed to start ffmpeg for all cameras")
+	}
 
-	httpServer.SendStatusWithDetails(httpServer.Recording, fmt.Sprintf("Recording: %s - %s attempt %d",
+	statusMessage := fmt.Sprintf("Recording: %s - %s attempt %d",
 		currentAttempt.AthleteName,
 		currentAttempt.LiftType,
-		currentAttempt.AttemptNumber), currentAttempt)
+		currentAttempt.AttemptNumber)
+	if len(failures) > 0 {
+		statusMessage += fmt.Sprintf(" (Warning: %s)", strings.Join(failures, "; "))
+	}
+	httpServer.SendStatusWithDetails(httpServer.Recording, statusMessage, currentAttempt)
 
-	logging.InfoLogger.Printf("Started recording videos: %v", fileNames)
+	if buffered {
+		logging.InfoLogger.Printf("Marked the start of the attempt in the pre-buffers: %v", fileNames)
+		// No file grows until the decision; only the maximum duration is watched.
+		go watchRecording(serial, nil, nil)
+	} else {
+		logging.InfoLogger.Printf("Started recording videos: %v", fileNames)
+		if !config.NoVideo {
+			if timeout := config.GetFirstFrameTimeout(); timeout > 0 {
+				go dropSilentCameras(serial, received, timeout, failures)
+			} else {
+				go watchRecording(serial, fileNames, cameraNumbers)
+				go measureStartLatency(serial, received, fileNames, cameraNumbers)
+			}
+		}
+	}
 	return nil
 }
 
+// startCameraRecording starts the recording ffmpeg for one camera and returns
+// the running command together with its stdin, used to request a graceful stop.
+// The errors of ffmpeg go to log, unless it is nil.
+func startCameraRecording(cameraNumber int, args []string, log *attemptLog) (*exec.Cmd, *os.File, error) {
+	var cmd *exec.Cmd
+	if log != nil && !config.GetLogFfmpeg() {
+		cmd = CreateFfmpegCmd(args, "recording", "error")
+	} else {
+		cmd = CreateFfmpegCmd(args, "recording")
+	}
+	log.capture(cmd, cameraNumber, "recording")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create stdin pipe for Camera %d: %w", cameraNumber, err)
+	}
+
+	logging.InfoLogger.Printf("Executing command for Camera %d: %s", cameraNumber, cmd.String())
+	if err := jobutil.Start(cmd, fmt.Sprintf("replays: recording Camera %d", cameraNumber)); err != nil {
+		stdin.Close()
+		return nil, nil, fmt.Errorf("failed to start ffmpeg for Camera %d: %w", cameraNumber, err)
+	}
+	return cmd, stdin.(*os.File), nil
+}
+
 // trimVideo handles the trimming of a single video file.
 // keepFromEndMs is the number of milliseconds to keep counted from end-of-file
 // (see buildTrimmingArgs for rationale).
-func trimVideo(wg *sync.WaitGroup, i int, currentFileName string, keepFromEndMs int64, startTime int64, sessionDir string, fullSessionDir string, timestamp string, finalFileNames []string, attemptDetails httpServer.StatusAttemptDetails) {
+func trimVideo(wg *sync.WaitGroup, i int, cameraNumber int, currentFileName string, keepFromEndMs int64, startTime int64, stopTime int64, sessionDir string, fullSessionDir string, timestamp string, finalFileNames []string, attemptDetails httpServer.StatusAttemptDetails) {
 	defer wg.Done()
-	cameraNumber := i + 1
+	defer releaseAttemptLog(currentFileName)
 	if err := httpServer.ClearPublishedReplayState(cameraNumber); err != nil {
 		logging.ErrorLogger.Printf("Failed to clear published replay state for Camera %d: %v", cameraNumber, err)
 	}
 
 	baseFileName := strings.TrimSuffix(filepath.Base(currentFileName), filepath.Ext(currentFileName))
 	baseFileName = baseFileName[:len(baseFileName)-len(fmt.Sprintf("_%d", state.LastStartTime))]
-	finalFileName := filepath.Join(fullSessionDir, fmt.Sprintf("%s_%s.mp4", timestamp, baseFileName))
+	camera := *config.GetCameraConfig(cameraNumber)
+	if config.Overlay {
+		camera.Overlay = overlayText(attemptDetails)
+	}
+	// The overlays are drawn for the cut from overlayFrom.
+	overlayFrom := time.UnixMilli(stopTime - keepFromEndMs)
+	if config.ScoreOverlay && keepFromEndMs > 0 {
+		camera.ScoreFilter = scoreboardFilter(overlayFrom, time.UnixMilli(stopTime))
+	}
+	if config.Timecode && keepFromEndMs > 0 {
+		camera.Timecode = overlayFrom
+	}
+	finalFileName := filepath.Join(fullSessionDir, fmt.Sprintf("%s_%s%s", timestamp, baseFileName, camera.Trim.Extension()))
 	finalFileNames[i] = finalFileName
 
 	attemptInfo := fmt.Sprintf("%s - %s attempt %d",
@@ -272,42 +742,72 @@ func trimVideo(wg *sync.WaitGroup, i int, currentFileName string, keepFromEndMs
 		logging.InfoLogger.Printf("Start time is 0, not trimming the video for Camera %d", cameraNumber)
 		if config.NoVideo {
 			logging.InfoLogger.Printf("Simulating rename video for Camera %d: %s -> %s", cameraNumber, currentFileName, finalFileName)
-		} else if err = os.Rename(currentFileName, finalFileName); err != nil {
+		} else if err = moveFile(currentFileName, finalFileName); err != nil {
 			logging.ErrorLogger.Printf("Failed to rename video file for Camera %d to %s: %v", cameraNumber, finalFileName, err)
+			recoverFromCameraNode(cameraNumber, camera, keepFromEndMs, startTime, stopTime, sessionDir, finalFileName)
 			return
 		}
 		if !config.NoVideo {
-			if err := httpServer.PublishReplayState(cameraNumber, sessionDir, filepath.Base(finalFileName), 0); err != nil {
+			if err := replayIntegrity(finalFileName); err != nil {
+				if recoverFromCameraNode(cameraNumber, camera, keepFromEndMs, startTime, stopTime, sessionDir, finalFileName) {
+					return
+				}
+				rejectBrokenReplay(cameraNumber, "", finalFileName, err)
+				return
+			}
+			if err := publishReplay(cameraNumber, sessionDir, finalFileName, 0); err != nil {
 				logging.ErrorLogger.Printf("Failed to publish replay state for Camera %d: %v", cameraNumber, err)
 			}
 		}
 	} else {
-		for j := 0; j < 5; j++ {
-			args := buildTrimmingArgs(keepFromEndMs, currentFileName, finalFileName, config.GetCameraConfigs()[i])
-			cmd := CreateFfmpegCmd(args, "trimming")
-
-			if j == 0 {
-				logging.InfoLogger.Printf("Executing trim command for Camera %d: %s", cameraNumber, cmd.String())
+		if camera.TrimOffsetMs > 0 && keepFromEndMs > int64(camera.TrimOffsetMs) {
+			// The camera shows the platform later than the others.
+			keepFromEndMs -= int64(camera.TrimOffsetMs)
+		}
+		camera = withRecordedSize(cameraNumber, camera, currentFileName)
+		copySafe := checkCopySafe(cameraNumber, camera, currentFileName)
+		if copySafe {
+			camera.Recode = false
+		}
+		// A replay of a camera recording H.264 is copied now and re-encoded
+		// once the attempt is over.
+		recodeLater := config.DeferRecodes && !camera.Recode && camera.TrimRecodes()
+		trimmed := camera
+		trimmed.Proxy = recodeLater
+		if err = trimCamera(cameraNumber, keepFromEndMs, currentFileName, finalFileName, trimmed); err != nil {
+			logging.ErrorLogger.Printf("Failed to trim video for Camera %d: %v", cameraNumber, err)
+			if recoverFromCameraNode(cameraNumber, camera, keepFromEndMs, startTime, stopTime, sessionDir, finalFileName) {
+				return
 			}
-
-			if err = cmd.Run(); err != nil {
-				logging.ErrorLogger.Printf("Waiting for input video for Camera %d (attempt %d/5): %v", cameraNumber, j+1, err)
-				time.Sleep(1 * time.Second)
-			} else {
-				break
+			var failure *TrimFailure
+			var damaged *DamagedRecording
+			if !errors.As(err, &damaged) && !config.NoVideo {
+				keepRecording(currentFileName)
 			}
-			if j == 4 {
-				logging.ErrorLogger.Printf("Failed to open input video for Camera %d after 5 attempts: %v", cameraNumber, err)
-				httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Failed to trim video for Camera %d after 5 attempts", cameraNumber))
-				return
+			if errors.As(err, &damaged) {
+				httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Camera %d recording is damaged and could not be repaired, kept as %s", cameraNumber, filepath.Base(damaged.Kept)))
+			} else if errors.As(err, &failure) && failure.Report != "" {
+				httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Failed to trim video for Camera %d%s, see %s", cameraNumber, ffmpegErrorDetail(currentFileName, cameraNumber), failure.Report))
+			} else {
+				httpServer.SendStatus(httpServer.Ready, fmt.Sprintf("Error: Failed to trim video for Camera %d%s", cameraNumber, ffmpegErrorDetail(currentFileName, cameraNumber)))
 			}
+			return
 		}
 		// Probe the actual on-disk duration of the trimmed file. ffmpeg's
 		// -sseof snaps to the previous keyframe, so the resulting clip is
 		// usually shorter than keepFromEndMs. Publishing the requested
 		// keepFromEndMs caused downstream players (OBS scenes in tracker)
 		// to wait for non-existent frames and show a black tail.
-		probedDurationMs := probeVideoDurationMs(finalFileName)
+		probe, probed := probeTrimmedVideo(finalFileName)
+		if probed {
+			if cutErr := verifyTrimmedCut(keepFromEndMs, probe); cutErr != nil && copySafe {
+				logging.WarningLogger.Printf("Camera %d: trimmed clip %s is off (%v), kept without re-encoding since the camera is copy-safe", cameraNumber, finalFileName, cutErr)
+			} else if cutErr != nil {
+				probe = retrimWithRecode(cameraNumber, keepFromEndMs, currentFileName, finalFileName, trimmed, probe, cutErr)
+				recodeLater = false
+			}
+		}
+		probedDurationMs := probe.durationMs
 		publishedDurationMs := probedDurationMs
 		if publishedDurationMs <= 0 {
 			logging.WarningLogger.Printf("Falling back to requested duration %dms for Camera %d (%s); ffprobe did not return a usable value", keepFromEndMs, cameraNumber, finalFileName)
@@ -315,11 +815,26 @@ func trimVideo(wg *sync.WaitGroup, i int, currentFileName string, keepFromEndMs
 		} else if keepFromEndMs > 0 && probedDurationMs != keepFromEndMs {
 			logging.InfoLogger.Printf("Camera %d: probed duration %dms differs from requested %dms (delta=%dms) for %s", cameraNumber, probedDurationMs, keepFromEndMs, probedDurationMs-keepFromEndMs, finalFileName)
 		}
-		if err = httpServer.PublishReplayState(cameraNumber, sessionDir, filepath.Base(finalFileName), publishedDurationMs); err != nil {
+		if !config.NoVideo {
+			if err := replayIntegrity(finalFileName); err != nil {
+				if recoverFromCameraNode(cameraNumber, camera, keepFromEndMs, startTime, stopTime, sessionDir, finalFileName) {
+					return
+				}
+				rejectBrokenReplay(cameraNumber, currentFileName, finalFileName, err)
+				return
+			}
+		}
+		if err = publishReplay(cameraNumber, sessionDir, finalFileName, publishedDurationMs); err != nil {
 			logging.ErrorLogger.Printf("Failed to publish replay state for Camera %d: %v", cameraNumber, err)
 			return
 		}
-		if err = os.Remove(currentFileName); err != nil {
+		if recodeLater && !config.NoVideo {
+			if probedDurationMs > 0 && keepFromEndMs > 0 {
+				camera = proxyOverlays(camera, overlayFrom, time.UnixMilli(stopTime), probedDurationMs-keepFromEndMs)
+			}
+			deferReplayRecode(cameraNumber, sessionDir, filepath.Base(finalFileName), camera)
+		}
+		if err = releaseOriginal(cameraNumber, currentFileName, sessionDir, finalFileName, keepFromEndMs, startTime, stopTime, attemptDetails); err != nil {
 			logging.ErrorLogger.Printf("Failed to remove untrimmed video file for Camera %d: %v", cameraNumber, err)
 			return
 		}
@@ -328,12 +843,19 @@ func trimVideo(wg *sync.WaitGroup, i int, currentFileName string, keepFromEndMs
 
 // StopRecordingAndTrim stops the current recordings and trims the videos
 func StopRecordingAndTrim(decisionTime int64) error {
-	shouldReturn, err := StopRecording()
+	// The logs asked for this attempt stop with it.
+	defer config.EndAttemptLog()
+	shouldReturn, err := stopRecording()
 	if shouldReturn {
 		return err
 	}
 
+	// Cameras restarted during the attempt recorded into several files.
+	joinRestartedParts()
+
 	attemptDetails := currentAttempt
+	attemptDetails.StartedAt = 0
+	attemptDetails.Cameras = nil
 	if attemptDetails.AthleteName == "" {
 		attemptDetails.AthleteName = strings.ReplaceAll(state.CurrentAthlete, "_", " ")
 	}
@@ -348,7 +870,7 @@ func StopRecordingAndTrim(decisionTime int64) error {
 	}
 
 	// leadInMs is how much footage to keep BEFORE the moment the timer was stopped.
-	const leadInMs int64 = 5000
+	leadInMs := config.GetPreRoll(state.CurrentPlatform).Milliseconds()
 
 	startTime := state.LastStartTime
 	// Keep from EOF: everything after the timer stop (decision + a couple of
@@ -362,17 +884,13 @@ func StopRecordingAndTrim(decisionTime int64) error {
 	}
 	logging.InfoLogger.Printf("Trim: keeping last %d ms (lead-in %d ms before timer stop)", keepFromEndMs, leadInMs)
 
-	timestamp := time.Now().Format("2006-01-02_15h04m05s")
+	timestamp := httpServer.ReplayTimestamp(time.Now())
 	finalFileNames := make([]string, len(currentFileNames))
 
 	// Create session directory if it doesn't exist
-	sessionDir := attemptDetails.Session
-	if sessionDir == "" {
-		sessionDir = "unsorted"
-	}
-	sessionDir = strings.ReplaceAll(sessionDir, " ", "_")
-	fullSessionDir := filepath.Join(config.GetVideoDir(), sessionDir)
-	if err := os.MkdirAll(fullSessionDir, os.ModePerm); err != nil {
+	sessionDir := httpServer.SessionFolder(attemptDetails.Session)
+	fullSessionDir, err := sessionWorkDir(sessionDir)
+	if err != nil {
 		return fmt.Errorf("failed to create session directory: %w", err)
 	}
 	attemptDetails.Session = sessionDir
@@ -381,86 +899,143 @@ func StopRecordingAndTrim(decisionTime int64) error {
 	statusMessage := fmt.Sprintf("Trimming videos for %s -- %s attempt %d", attemptDetails.AthleteName, attemptDetails.LiftType, attemptDetails.AttemptNumber)
 	httpServer.SendStatusWithDetails(httpServer.Trimming, statusMessage, attemptDetails)
 
-	var wg sync.WaitGroup
-	for i, currentFileName := range currentFileNames {
-		wg.Add(1)
-		go trimVideo(&wg, i, currentFileName, keepFromEndMs, startTime, sessionDir, fullSessionDir, timestamp, finalFileNames, attemptDetails)
+	Trimming = true
+	defer func() { Trimming = false }()
+
+	// A pre-buffered file ends with its last segment, after the decision;
+	// the cut is moved back by that much.
+	extraMs := make([]int64, len(currentFileNames))
+	if currentBuffered {
+		defer releasePreBufferHold()
+		extractPreBufferedAttempt(nowMs, extraMs)
 	}
 
-	wg.Wait()
+	// The cameras re-encoded wait for a free trim worker rather than all
+	// being re-encoded at once; stream copies do not wait.
+	batch := newTrimBatch(statusMessage, attemptDetails, currentCameraNumbers())
+	batch.report()
+	for i, currentFileName := range currentFileNames {
+		keepMs := keepFromEndMs
+		if keepMs > 0 {
+			keepMs += extraMs[i]
+		}
+		i, currentFileName, cameraNumber := i, currentFileName, recordingCameraNumber(i)
+		batch.submit(i, &finalFileNames[i], trimReencodes(*config.GetCameraConfig(cameraNumber)), func(wg *sync.WaitGroup) {
+			trimVideo(wg, i, cameraNumber, currentFileName, keepMs, startTime, nowMs, sessionDir, fullSessionDir, timestamp, finalFileNames, attemptDetails)
+		})
+	}
+	batch.wait()
 
 	// Send single "Videos ready" message after all cameras are done
 	httpServer.SendStatusWithDetails(httpServer.Ready, "Videos ready", attemptDetails)
+	addContextInsets(finalFileNames, sessionDir)
+	for i, finalFileName := range finalFileNames {
+		if finalFileName != "" {
+			makeSlowMotion(recordingCameraNumber(i), sessionDir, filepath.Base(finalFileName))
+			makeProxies(recordingCameraNumber(i), sessionDir, filepath.Base(finalFileName))
+		}
+	}
+	makeMultiAngle(finalFileNames, sessionDir)
 
 	logging.InfoLogger.Printf("Stopped recording and saved videos: %v", finalFileNames)
 	currentRecordings = nil
 	currentStdin = nil
 	currentFileNames = nil
+	currentCameras = nil
+	currentBuffered = false
 
 	return nil
 }
 
+// extractPreBufferedAttempt joins the pre-buffered footage of the attempt of
+// every camera and records in extraMs how long each file runs past nowMs.
+func extractPreBufferedAttempt(nowMs int64, extraMs []int64) {
+	from := time.Unix(0, currentAttempt.StartedAt*int64(time.Millisecond))
+	to := time.Unix(0, nowMs*int64(time.Millisecond))
+	var wg sync.WaitGroup
+	for i, fileName := range currentFileNames {
+		wg.Add(1)
+		go func(i int, fileName string) {
+			defer wg.Done()
+			cameraNumber := recordingCameraNumber(i)
+			end, err := extractPreBuffered(cameraNumber, from, to, fileName)
+			if err != nil {
+				logging.ErrorLogger.Printf("%v", err)
+				return
+			}
+			if extra := end.Sub(to).Milliseconds(); extra > 0 {
+				extraMs[i] = extra
+			}
+		}(i, fileName)
+	}
+	wg.Wait()
+}
+
+// StopRecording stops the recordings of the attempt without trimming them,
+// closing their ffmpeg logs.
 func StopRecording() (bool, error) {
+	shouldReturn, err := stopRecording()
+	releaseCurrentAttemptLogs()
+	return shouldReturn, err
+}
+
+func stopRecording() (bool, error) {
 	Recording = false
+	if currentBuffered {
+		// The pre-buffers keep running; nothing to stop.
+		return false, nil
+	}
+	recordersMutex.Lock()
+	defer recordersMutex.Unlock()
 	if len(currentRecordings) == 0 && !config.NoVideo {
 		return true, fmt.Errorf("no ongoing recordings to stop")
 	}
 
 	if config.NoVideo {
 		for i, fileName := range currentFileNames {
-			logging.InfoLogger.Printf("Simulating stop recording video for Camera %d: %s", i+1, fileName)
+			logging.InfoLogger.Printf("Simulating stop recording video for Camera %d: %s", recordingCameraNumber(i), fileName)
 		}
 	} else {
-		logging.InfoLogger.Println("Attempting to stop ffmpeg gracefully...")
-		for i, cmd := range currentRecordings {
-			if err := RequestFFmpegStop(cmd, currentStdin[i]); err != nil {
-				logging.InfoLogger.Printf("Could not gracefully stop ffmpeg for Camera %d (this is normal if process exited): %v", i+1, err)
-			}
-		}
-		time.Sleep(100 * time.Millisecond)
-		for i, stdin := range currentStdin {
-			if err := CloseFFmpegStdin(stdin); err != nil {
-				logging.InfoLogger.Printf("Could not close stdin for Camera %d (this is normal if process exited): %v", i+1, err)
+		stopRecorders(currentRecordings, currentStdin, currentCameraNumbers())
+	}
+	return false, nil
+}
+
+// stopRecorders asks each recording ffmpeg to finish its file, killing the
+// ones that do not exit promptly. cameraNumbers labels the log messages.
+func stopRecorders(cmds []*exec.Cmd, stdins []*os.File, cameraNumbers []int) {
+	logging.InfoLogger.Println("Attempting to stop ffmpeg gracefully...")
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		wg.Add(1)
+		go func(i int, cmd *exec.Cmd) {
+			defer wg.Done()
+			// An ffmpeg blocked in an input read with no packets arriving
+			// on the UDP source may not react; it is then killed so the
+			// recorder always tears down promptly.
+			killed, err := StopFFmpeg(cmd, stdins[i], nil, stopGrace)
+			switch {
+			case killed:
+				logging.InfoLogger.Printf("ffmpeg did not stop gracefully for Camera %d; killed it", cameraNumbers[i])
+			case err != nil && isExpectedFFmpegStop(err):
+				logging.InfoLogger.Printf("ffmpeg stopped gracefully for Camera %d (signal exit): %v", cameraNumbers[i], err)
+			case err != nil:
+				logging.InfoLogger.Printf("ffmpeg exited with error for Camera %d: %v", cameraNumbers[i], err)
+			default:
+				logging.InfoLogger.Printf("ffmpeg stopped gracefully for Camera %d", cameraNumbers[i])
 			}
-		}
-		var wg sync.WaitGroup
-		for i, cmd := range currentRecordings {
-			wg.Add(1)
-			go func(i int, cmd *exec.Cmd) {
-				defer wg.Done()
-				// Give ffmpeg a brief window to react to the graceful stop
-				// (q on stdin / EOF / SIGINT). If it does not exit — typical
-				// when ffmpeg is blocked in an input read with no packets
-				// arriving on the UDP source — escalate to a hard kill so
-				// the recorder always tears down promptly.
-				done := make(chan error, 1)
-				go func() { done <- cmd.Wait() }()
-
-				var err error
-				select {
-				case err = <-done:
-				case <-time.After(2 * time.Second):
-					logging.InfoLogger.Printf("ffmpeg did not stop gracefully for Camera %d; forcing kill", i+1)
-					if killErr := forceKillCmd(cmd); killErr != nil {
-						logging.ErrorLogger.Printf("Failed to force-kill ffmpeg for Camera %d: %v", i+1, killErr)
-					}
-					err = <-done
-				}
+		}(i, cmd)
+	}
+	wg.Wait()
+}
 
-				if err != nil {
-					if isExpectedFFmpegStop(err) {
-						logging.InfoLogger.Printf("ffmpeg stopped gracefully for Camera %d (signal exit): %v", i+1, err)
-					} else {
-						logging.InfoLogger.Printf("ffmpeg exited with error for Camera %d: %v", i+1, err)
-					}
-				} else {
-					logging.InfoLogger.Printf("ffmpeg stopped gracefully for Camera %d", i+1)
-				}
-			}(i, cmd)
-		}
-		wg.Wait()
+// currentCameraNumbers returns the camera number of each running recording.
+func currentCameraNumbers() []int {
+	numbers := make([]int, len(currentRecordings))
+	for i := range numbers {
+		numbers[i] = recordingCameraNumber(i)
 	}
-	return false, nil
+	return numbers
 }
 
 func isExpectedFFmpegStop(err error) bool {
@@ -488,16 +1063,18 @@ func isExpectedFFmpegStop(err error) bool {
 }
 
 func TerminateRecordings() {
+	terminateLongRecording()
+	StopPreBuffers()
 	if config.NoVideo {
 		for i, fileName := range currentFileNames {
-			logging.InfoLogger.Printf("Simulating forced stop recording video for Camera %d: %s", i+1, fileName)
+			logging.InfoLogger.Printf("Simulating forced stop recording video for Camera %d: %s", recordingCameraNumber(i), fileName)
 		}
 	} else {
 		logging.InfoLogger.Println("Forcing stop ffmpeg if required...")
 		for i, cmd := range currentRecordings {
 			logging.InfoLogger.Printf("Attempting to stop ffmpeg %d gracefully...", i+1)
 			if err := RequestFFmpegStop(cmd, currentStdin[i]); err != nil {
-				logging.InfoLogger.Printf("Could not gracefully stop ffmpeg for Camera %d (this is normal if process exited): %v", i+1, err)
+				logging.InfoLogger.Printf("Could not gracefully stop ffmpeg for Camera %d (this is normal if process exited): %v", recordingCameraNumber(i), err)
 			}
 		}
 
@@ -509,9 +1086,9 @@ func TerminateRecordings() {
 			go func(i int, cmd *exec.Cmd) {
 				defer wg.Done()
 				if err := forceKillCmd(cmd); err != nil {
-					logging.InfoLogger.Printf("ffmpeg exited for Camera %d: %v", i+1, err)
+					logging.InfoLogger.Printf("ffmpeg exited for Camera %d: %v", recordingCameraNumber(i), err)
 				} else {
-					logging.InfoLogger.Printf("ffmpeg stopped gracefully for Camera %d", i+1)
+					logging.InfoLogger.Printf("ffmpeg stopped gracefully for Camera %d", recordingCameraNumber(i))
 				}
 			}(i, cmd)
 		}
@@ -551,6 +1128,10 @@ func ListCameras(window fyne.Window) {
 	if err := cmd.Run(); err != nil {
 		logging.ErrorLogger.Printf("Failed to list cameras: %v", err)
 		logging.ErrorLogger.Printf("Command output: %s", out.String())
+		if access := cameraAccessSection(window); access != nil {
+			showCameraAccess(window, fmt.Sprintf("Failed to list cameras: %v", err), access)
+			return
+		}
 		dialog.ShowError(fmt.Errorf("failed to list cameras: %v\nOutput: %s", err, out.String()), window)
 		return
 	}
@@ -605,7 +1186,12 @@ func ListCameras(window fyne.Window) {
 		logging.InfoLogger.Printf("ListCameras: Camera %d: %s", i+1, name)
 	}
 
+	access := cameraAccessSection(window)
 	if len(cameraNames) == 0 {
+		if access != nil {
+			showCameraAccess(window, "No cameras were found on this system.", access)
+			return
+		}
 		dialog.ShowInformation("No Cameras Found", "No cameras were found on this system.", window)
 		return
 	}
@@ -616,10 +1202,44 @@ func ListCameras(window fyne.Window) {
 	textArea.SetText(cameraList)
 	textArea.Wrapping = fyne.TextWrapWord
 
-	dialog := dialog.NewCustom("Available Cameras", "Close", container.NewVBox(
+	content := container.NewVBox(
 		widget.NewLabel("The following cameras were found on this system:"),
 		textArea,
-	), window)
-	dialog.Resize(fyne.NewSize(400, 300)) // Increased height by 25%
+	)
+	size := fyne.NewSize(400, 300) // Increased height by 25%
+	if access != nil {
+		content.Add(access)
+		size = fyne.NewSize(600, 500)
+	}
+	dialog := dialog.NewCustom("Available Cameras", "Close", content, window)
+	dialog.Resize(size)
 	dialog.Show()
 }
+
+// cameraAccessSection shows the camera access problems found on this machine
+// and the commands that fix them, or returns nil when there are none.
+func cameraAccessSection(window fyne.Window) fyne.CanvasObject {
+	issues := DiagnoseCameraAccess()
+	if len(issues) == 0 {
+		return nil
+	}
+	report := widget.NewMultiLineEntry()
+	report.SetText(FormatCameraIssues(issues))
+	report.SetMinRowsVisible(8)
+	report.Wrapping = fyne.TextWrapWord
+	copyFixes := widget.NewButton("Copy fix commands", func() {
+		window.Clipboard().SetContent(CameraFixCommands(issues))
+	})
+	return container.NewVBox(
+		widget.NewLabel("Camera access problems were found:"),
+		report,
+		copyFixes,
+	)
+}
+
+// showCameraAccess explains why no camera could be listed.
+func showCameraAccess(window fyne.Window, message string, access fyne.CanvasObject) {
+	d := dialog.NewCustom("Camera Access", "Close", container.NewVBox(widget.NewLabel(message), access), window)
+	d.Resize(fyne.NewSize(600, 450))
+	d.Show()
+}
@@ -0,0 +1,209 @@
+package recording
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/jobutil"
+	"github.com/owlcms/replays/internal/state"
+)
+
+func TestParseFFprobeFormat(t *testing.T) {
+	probe, err := parseFFprobeFormat("start_time=0.021000\nduration=8.533000\n")
+	if err != nil {
+		t.Fatalf("parseFFprobeFormat: %v", err)
+	}
+	if probe.durationMs != 8533 || probe.startMs != 21 {
+		t.Fatalf("unexpected probe: %+v", probe)
+	}
+
+	if _, err := parseFFprobeFormat("start_time=N/A\n"); err == nil {
+		t.Fatalf("expected error when duration is missing")
+	}
+}
+
+func TestVerifyTrimmedCut(t *testing.T) {
+	tests := []struct {
+		name          string
+		keepFromEndMs int64
+		probe         trimProbe
+		wantErr       bool
+	}{
+		{name: "keyframe snap", keepFromEndMs: 9000, probe: trimProbe{durationMs: 9800}},
+		{name: "shorter than requested", keepFromEndMs: 9000, probe: trimProbe{durationMs: 8200}},
+		{name: "whole file kept", keepFromEndMs: 0, probe: trimProbe{durationMs: 120000}},
+		{name: "zero duration", keepFromEndMs: 9000, probe: trimProbe{}, wantErr: true},
+		{name: "way too long", keepFromEndMs: 9000, probe: trimProbe{durationMs: 65000}, wantErr: true},
+		{name: "late first frame", keepFromEndMs: 9000, probe: trimProbe{durationMs: 9000, startMs: 4000}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := verifyTrimmedCut(tt.keepFromEndMs, tt.probe)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: verifyTrimmedCut() error = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNextTrimBackoff(t *testing.T) {
+	if got := nextTrimBackoff(trimPollInitialDelay); got != 2*trimPollInitialDelay {
+		t.Fatalf("nextTrimBackoff doubled to %s", got)
+	}
+	if got := nextTrimBackoff(trimPollMaxDelay); got != trimPollMaxDelay {
+		t.Fatalf("nextTrimBackoff exceeded cap: %s", got)
+	}
+}
+
+func TestWaitForTrimInput(t *testing.T) {
+	oldReadable := trimInputReadable
+	t.Cleanup(func() { trimInputReadable = oldReadable })
+
+	path := filepath.Join(t.TempDir(), "camera1.mkv")
+	if err := os.WriteFile(path, []byte("recording"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	probes := 0
+	trimInputReadable = func(string) error {
+		probes++
+		if probes < 2 {
+			return errors.New("moov atom not found")
+		}
+		return nil
+	}
+	if err := waitForTrimInput(1, path, 5*time.Second); err != nil {
+		t.Fatalf("waitForTrimInput on finalized file: %v", err)
+	}
+	if probes != 2 {
+		t.Fatalf("expected 2 probes, got %d", probes)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.mkv")
+	if err := waitForTrimInput(1, missing, 300*time.Millisecond); err == nil {
+		t.Fatalf("expected timeout for missing file")
+	}
+}
+
+func TestRecordingCameraNumberSkipsFailedCameras(t *testing.T) {
+	oldCameras := currentCameras
+	t.Cleanup(func() { currentCameras = oldCameras })
+
+	// Camera 2 failed to start: the second running recording is Camera 3.
+	currentCameras = []int{1, 3}
+	if got := recordingCameraNumber(1); got != 3 {
+		t.Fatalf("recordingCameraNumber(1) = %d, want 3", got)
+	}
+}
+
+func TestDeviceBusyErrorNamesOwner(t *testing.T) {
+	err := &DeviceBusyError{Device: "/dev/video0", Owners: []jobutil.PortProcess{{PID: 4321, Command: "obs"}}, Confirmed: true}
+	if got := err.Error(); got != "device busy: /dev/video0 used by obs (4321)" {
+		t.Fatalf("unexpected message: %q", got)
+	}
+	if err := CheckCameraDevice("mpegts", "udp://239.255.0.1:9001"); err != nil {
+		t.Fatalf("network sources must not be checked: %v", err)
+	}
+}
+
+func TestTrimProfileDownscalesOneCameraAndCopiesTheOther(t *testing.T) {
+	settings := config.MulticastSettings{
+		IP:          "239.255.0.1",
+		Camera1Port: 9001,
+		Camera2Port: 9002,
+		Trim: []config.TrimProfile{
+			{Camera: 1, Crf: 23, Preset: "veryfast", Scale: "1920:-2"},
+			{Camera: 2, Container: "mkv"},
+		},
+	}
+	cameras := settings.BuildCameraConfigs()
+
+	downscaled := strings.Join(buildTrimmingArgsWith(nil, 5000, "attempt.mkv", "replay.mp4", cameras[0]), " ")
+	for _, want := range []string{"-vf scale=1920:-2", "-c:v libx264 -crf 23 -preset veryfast"} {
+		if !strings.Contains(downscaled, want) {
+			t.Fatalf("trim of camera 1 = %s, want %s", downscaled, want)
+		}
+	}
+	if cameras[0].Trim.Extension() != ".mp4" {
+		t.Fatalf("camera 1 replays are %s, want .mp4", cameras[0].Trim.Extension())
+	}
+
+	hw := &HwEncoder{Name: "h264_nvenc", OutputParameters: "-c:v h264_nvenc"}
+	if args := strings.Join(buildTrimmingArgsWith(hw, 5000, "attempt.mkv", "replay.mp4", cameras[0]), " "); strings.Contains(args, "nvenc") {
+		t.Fatalf("trim profile settings replaced by the hardware encoder: %s", args)
+	}
+
+	copied := strings.Join(buildTrimmingArgsWith(nil, 5000, "attempt.mkv", "replay"+cameras[1].Trim.Extension(), cameras[1]), " ")
+	if !strings.HasSuffix(copied, "-c copy -avoid_negative_ts make_zero replay.mkv") {
+		t.Fatalf("trim of camera 2 = %s, want a copy into mkv", copied)
+	}
+}
+
+func TestTrimProfileCodecReencodesForEditing(t *testing.T) {
+	hevc := config.CameraConfiguration{Trim: &config.TrimProfile{Codec: "hevc", Container: "mov"}}
+	args := strings.Join(buildTrimmingArgsWith(nil, 5000, "attempt.mkv", "replay"+hevc.Trim.Extension(), hevc), " ")
+	for _, want := range []string{"-c:v libx265 -crf 22", "-tag:v hvc1", "-movflags +faststart replay.mov"} {
+		if !strings.Contains(args, want) {
+			t.Fatalf("hevc trim = %s, want %s", args, want)
+		}
+	}
+
+	prores := config.CameraConfiguration{Trim: &config.TrimProfile{Codec: "prores", Container: "mkv"}}
+	args = strings.Join(buildTrimmingArgsWith(nil, 5000, "attempt.mkv", "replay.mkv", prores), " ")
+	if !strings.Contains(args, "-c:v prores_ks -profile:v 3") || strings.Contains(args, "-crf") || strings.Contains(args, "movflags") {
+		t.Fatalf("prores trim = %s", args)
+	}
+
+	h264 := config.CameraConfiguration{Trim: &config.TrimProfile{Codec: "h264"}}
+	if h264.TrimRecodes() {
+		t.Fatalf("the default codec must keep the replays copied")
+	}
+}
+
+func TestReplaySizeLetterboxesCamerasOfAnotherSize(t *testing.T) {
+	config.ReplaySize = "1920x1080"
+	defer func() { config.ReplaySize = "" }()
+
+	hd := config.CameraConfiguration{Size: "1920x1080"}
+	if args := strings.Join(buildTrimmingArgsWith(nil, 5000, "attempt.mkv", "replay.mp4", hd), " "); !strings.Contains(args, "-c copy") {
+		t.Fatalf("camera already at the replay size is re-encoded: %s", args)
+	}
+
+	portrait := config.CameraConfiguration{Size: "1080x1920", Trim: &config.TrimProfile{Scale: "720:-2"}}
+	args := strings.Join(buildTrimmingArgsWith(nil, 5000, "attempt.mkv", "replay.mp4", portrait), " ")
+	want := "-vf scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2,setsar=1 -c:v libx264"
+	if !strings.Contains(args, want) {
+		t.Fatalf("trim of a portrait camera = %s, want %s", args, want)
+	}
+}
+
+func TestOverlayIsBurnedIntoTheReplay(t *testing.T) {
+	details := httpServer.StatusAttemptDetails{AthleteName: "DOE John", LiftType: "CLEANJERK", AttemptNumber: 2, Weight: 142}
+	camera := config.CameraConfiguration{Overlay: overlayText(details)}
+	args := strings.Join(buildTrimmingArgsWith(nil, 5000, "attempt.mkv", "replay.mp4", camera), " ")
+	if !strings.Contains(args, "-vf drawtext=expansion=none:text=DOE John - Clean&Jerk attempt 2 - 142 kg:") || !strings.Contains(args, "-c:v libx264") {
+		t.Fatalf("trim with an overlay = %s", args)
+	}
+}
+
+func TestClockSegmentsStartWithTheReplay(t *testing.T) {
+	start := time.Date(2026, 5, 2, 14, 0, 0, 0, time.UTC)
+	events := []state.ClockEvent{
+		{At: start.Add(-4 * time.Second), Running: true, RemainingMs: 60000},
+		{At: start.Add(6 * time.Second), RemainingMs: 50000},
+	}
+	segments := clockSegments(events, start, start.Add(10*time.Second))
+	want := []config.ClockSegment{
+		{From: 0, To: 6, Remaining: 56, Running: true},
+		{From: 6, To: 10, Remaining: 50},
+	}
+	if !reflect.DeepEqual(segments, want) {
+		t.Errorf("clockSegments = %+v, want %+v", segments, want)
+	}
+}
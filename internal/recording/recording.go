@@ -1,11 +1,28 @@
 package recording
 
+import "sync/atomic"
+
 // Recording tracks whether a recording is currently in progress
 var Recording bool
 
+// Trimming tracks whether the videos of the last attempt are being trimmed
+var Trimming bool
+
 const FfmpegBuild = "ffmpeg-7.1-full_build"
 
 // IsRecording returns the current recording state
 func IsRecording() bool {
 	return Recording
 }
+
+// IsBusy reports whether an attempt or a long recording is being recorded or
+// saved, or the cameras calibrated, during which the camera configuration
+// must not change.
+func IsBusy() bool {
+	return Recording || Trimming || IsLongRecording() || IsCalibrating()
+}
+
+// IsCalibrating reports whether the calibration is recording the cameras.
+func IsCalibrating() bool {
+	return atomic.LoadInt32(&calibrating) != 0
+}
@@ -0,0 +1,101 @@
+package recording
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// damagedSuffix is appended to recordings that could not be repaired. They
+// are kept for manual recovery; the cleanup of leftover .mkv files skips them.
+const damagedSuffix = ".damaged"
+
+// DamagedRecording is returned when the untrimmed recording cannot be read
+// and the repair pass could not salvage it. Kept is where it was moved.
+type DamagedRecording struct {
+	Kept string
+	Err  error
+}
+
+func (e *DamagedRecording) Error() string {
+	return fmt.Sprintf("recording is damaged and could not be repaired: %v", e.Err)
+}
+
+func (e *DamagedRecording) Unwrap() error {
+	return e.Err
+}
+
+// buildRepairArgs remuxes a truncated recording (power loss, killed ffmpeg)
+// into a new file. Errors are ignored, corrupt packets dropped and missing
+// timestamps regenerated so that everything up to the damage is kept.
+func buildRepairArgs(source, target string) []string {
+	return []string{
+		"-y",
+		"-err_detect", "ignore_err",
+		"-fflags", "+genpts+discardcorrupt",
+		"-i", source,
+		"-map", "0",
+		"-c", "copy",
+		target,
+	}
+}
+
+// runRepair runs the repair ffmpeg. Replaced in tests.
+var runRepair = func(cameraNumber int, source, target string) error {
+	logLevel := ""
+	if !config.GetLogFfmpeg() {
+		logLevel = "error"
+	}
+	cmd := CreateFfmpegCmd(buildRepairArgs(source, target), "repair", logLevel)
+	_, output := captureTrimOutput(cmd)
+	logging.InfoLogger.Printf("Repairing recording for Camera %d: %s", cameraNumber, cmd.String())
+	if err := cmd.Run(); err != nil {
+		if output != nil && strings.TrimSpace(output.String()) != "" {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(tailForReport(output.String())))
+		}
+		return err
+	}
+	return nil
+}
+
+// repairRecording salvages a recording whose container cannot be read. The
+// repaired copy replaces it when ffprobe can read it; otherwise the original
+// is renamed with damagedSuffix and a *DamagedRecording is returned.
+func repairRecording(cameraNumber int, path string) error {
+	repaired := strings.TrimSuffix(path, ".mkv") + "_repaired.mkv"
+	err := runRepair(cameraNumber, path, repaired)
+	if err == nil {
+		err = trimInputReadable(repaired)
+	}
+	if err == nil {
+		err = os.Rename(repaired, path)
+	}
+	if err == nil {
+		logging.InfoLogger.Printf("Camera %d: repaired truncated recording %s", cameraNumber, path)
+		return nil
+	}
+
+	os.Remove(repaired)
+	kept := path + damagedSuffix
+	if renameErr := os.Rename(path, kept); renameErr != nil {
+		logging.ErrorLogger.Printf("Camera %d: failed to set aside damaged recording %s: %v", cameraNumber, path, renameErr)
+		kept = path
+	} else {
+		kept = keepRecording(kept)
+	}
+	logging.ErrorLogger.Printf("Camera %d: recording could not be repaired, kept as %s: %v", cameraNumber, kept, err)
+	return &DamagedRecording{Kept: kept, Err: err}
+}
+
+// needsRepair reports whether a finished recording has data but a container
+// ffprobe cannot read, as left by a power loss.
+func needsRepair(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+	return trimInputReadable(path) != nil
+}
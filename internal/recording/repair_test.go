@@ -0,0 +1,50 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepairRecordingReplacesOrSetsAsideTruncatedFile(t *testing.T) {
+	oldReadable, oldRepair := trimInputReadable, runRepair
+	t.Cleanup(func() { trimInputReadable, runRepair = oldReadable, oldRepair })
+	trimInputReadable = func(path string) error {
+		if strings.Contains(path, "_repaired") {
+			return nil
+		}
+		return fmt.Errorf("moov atom not found")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Jane_SNATCH_attempt1_Camera1_1.mkv")
+	if err := os.WriteFile(path, []byte("truncated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !needsRepair(path) {
+		t.Fatalf("an unreadable recording needs repair")
+	}
+
+	runRepair = func(_ int, source, target string) error {
+		return os.WriteFile(target, []byte("remuxed"), 0644)
+	}
+	if err := repairRecording(1, path); err != nil {
+		t.Fatalf("repairRecording() = %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "remuxed" {
+		t.Fatalf("recording was not replaced by its repaired copy: %q", content)
+	}
+
+	runRepair = func(int, string, string) error { return fmt.Errorf("invalid data") }
+	err := repairRecording(1, path)
+	var damaged *DamagedRecording
+	if !errors.As(err, &damaged) || damaged.Kept != path+damagedSuffix {
+		t.Fatalf("repairRecording() = %v, want the recording set aside", err)
+	}
+	if _, err := os.Stat(damaged.Kept); err != nil {
+		t.Fatalf("damaged recording not kept: %v", err)
+	}
+}
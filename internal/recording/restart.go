@@ -0,0 +1,123 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+var (
+	// recordersMutex keeps a camera from being restarted while the attempt
+	// is being stopped.
+	recordersMutex sync.Mutex
+	// currentParts holds, for each running recording, the files written
+	// before the camera was restarted, oldest first.
+	currentParts [][]string
+)
+
+// partFileName is the file a camera restarted for the n-th time records into.
+func partFileName(fileName string, n int) string {
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s_part%d%s", strings.TrimSuffix(fileName, ext), n, ext)
+}
+
+// restartRecorder replaces the ffmpeg of the i-th recording of the attempt,
+// stalled or crashed, by a new one recording into a new part. It returns the
+// file of the new part.
+func restartRecorder(serial int64, i int, gapStart time.Time) (string, error) {
+	recordersMutex.Lock()
+	defer recordersMutex.Unlock()
+	if atomic.LoadInt64(&recordingSerial) != serial || !IsRecording() || i >= len(currentRecordings) {
+		return "", fmt.Errorf("the attempt is over")
+	}
+	cameraNumber := recordingCameraNumber(i)
+	stopRecorders([]*exec.Cmd{currentRecordings[i]}, []*os.File{currentStdin[i]}, []int{cameraNumber})
+
+	for len(currentParts) <= i {
+		currentParts = append(currentParts, nil)
+	}
+	parts := append(currentParts[i], currentFileNames[i])
+	fileName := partFileName(parts[0], len(parts)+1)
+	log := attemptLogOf(currentFileNames[i])
+	cmd, stdin, err := startCameraRecording(cameraNumber, buildRecordingArgs(fileName, *config.GetCameraConfig(cameraNumber)), log)
+	if err != nil {
+		return "", err
+	}
+	if log != nil {
+		registerAttemptLog(fileName, log)
+	}
+	currentParts[i] = parts
+	currentRecordings[i] = cmd
+	currentStdin[i] = stdin
+	currentFileNames[i] = fileName
+	logging.WarningLogger.Printf("GAP Camera %d: nothing recorded since %s, restarted into %s",
+		cameraNumber, gapStart.Format("15:04:05.000"), filepath.Base(fileName))
+	return fileName, nil
+}
+
+// joinRestartedParts joins the parts of every camera restarted during the
+// attempt, so that the replay is cut from all of its footage. Called once the
+// recorders are stopped.
+func joinRestartedParts() {
+	for i, parts := range currentParts {
+		if len(parts) == 0 || i >= len(currentFileNames) {
+			continue
+		}
+		cameraNumber := recordingCameraNumber(i)
+		files := append(append([]string(nil), parts...), currentFileNames[i])
+		joined := strings.TrimSuffix(parts[0], filepath.Ext(parts[0])) + "_joined" + filepath.Ext(parts[0])
+		if err := joinParts(files, joined); err != nil {
+			// The last part holds the end of the lift; the earlier ones
+			// are kept for the operator.
+			logging.ErrorLogger.Printf("Camera %d: %v, the replay is cut from the last part", cameraNumber, err)
+			for _, part := range parts {
+				releaseAttemptLog(part)
+				keepRecording(part)
+			}
+			continue
+		}
+		logging.InfoLogger.Printf("Camera %d: joined %d parts into %s", cameraNumber, len(files), filepath.Base(joined))
+		if log := attemptLogOf(currentFileNames[i]); log != nil {
+			registerAttemptLog(joined, log)
+		}
+		for _, file := range files {
+			releaseAttemptLog(file)
+			os.Remove(file)
+		}
+		currentFileNames[i] = joined
+	}
+	currentParts = nil
+}
+
+// joinParts concatenates the recordings in files into target, without
+// re-encoding.
+func joinParts(files []string, target string) error {
+	var list strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(file, "'", `'\''`))
+	}
+	listFile := strings.TrimSuffix(target, filepath.Ext(target)) + ".txt"
+	if err := os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(listFile)
+
+	cmd := CreateFfmpegCmd([]string{"-y", "-f", "concat", "-safe", "0", "-i", listFile, "-c", "copy", target}, "restart", "error")
+	var stderr bytes.Buffer
+	if cmd.Stderr == nil {
+		cmd.Stderr = &stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to join the parts of the recording: %w %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
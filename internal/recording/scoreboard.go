@@ -0,0 +1,45 @@
+package recording
+
+import (
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/state"
+)
+
+// scoreboardFilter returns the filter drawing the clock and decision lights
+// owlcms announced between start and end onto the replay covering that time,
+// "" when owlcms sent neither.
+func scoreboardFilter(start, end time.Time) string {
+	events, decision := state.Scoreboard(start, end)
+	segments := clockSegments(events, start, end)
+	if decision == nil {
+		return config.ScoreboardFilter(segments, 0, nil)
+	}
+	return config.ScoreboardFilter(segments, decision.At.Sub(start).Seconds(), decision.Lights)
+}
+
+// clockSegments turns the clock events into the stretches of the replay from
+// start to end during which the clock runs or stays still.
+func clockSegments(events []state.ClockEvent, start, end time.Time) []config.ClockSegment {
+	duration := end.Sub(start).Seconds()
+	var segments []config.ClockSegment
+	for i, event := range events {
+		at := event.At.Sub(start).Seconds()
+		segment := config.ClockSegment{From: at, To: duration, Remaining: float64(event.RemainingMs) / 1000, Running: event.Running}
+		if i+1 < len(events) {
+			segment.To = events[i+1].At.Sub(start).Seconds()
+		}
+		if segment.From < 0 {
+			// The clock was set before the replay starts.
+			if segment.Running {
+				segment.Remaining += segment.From
+			}
+			segment.From = 0
+		}
+		if segment.To > segment.From {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,117 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// slowMotionFps is the frame rate of interpolated slow-motion copies.
+const slowMotionFps = 60
+
+// slowMotionMu makes one slow-motion copy at a time, so that they do not take
+// the processor away from the recording of the next attempt.
+var slowMotionMu sync.Mutex
+
+// slowMotionName is the name of the copy of a replay at percent speed.
+func slowMotionName(replay string, percent int) string {
+	return fmt.Sprintf("%s_slow%d.mp4", strings.TrimSuffix(replay, filepath.Ext(replay)), percent)
+}
+
+// buildSlowMotionArgs builds the ffmpeg arguments that slow down the replay
+// read on stdin. The sound is dropped: slowed down it does not help.
+func buildSlowMotionArgs(percent int, interpolate bool, output string) []string {
+	filter := fmt.Sprintf("setpts=%.4f*PTS", 100/float64(percent))
+	if interpolate {
+		filter += fmt.Sprintf(",minterpolate=fps=%d:mi_mode=mci", slowMotionFps)
+	}
+	args := []string{"-y", "-i", "pipe:0",
+		"-filter:v", filter,
+		"-an",
+		"-c:v", "libx264",
+	}
+	args = append(args, config.X264Args(1)...)
+	return append(args,
+		"-crf", "20",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		output,
+	)
+}
+
+// makeSlowMotion makes the configured slow-motion copies of a saved replay
+// of a session, in the background.
+func makeSlowMotion(cameraNumber int, sessionDir, replay string) {
+	speeds, interpolate := config.GetSlowMotion()
+	if len(speeds) == 0 || config.NoVideo {
+		return
+	}
+	if _, err := storage.Current().Stat(storage.Join(sessionDir, replay)); err != nil {
+		// The trim failed.
+		return
+	}
+	if config.DeferRecodes {
+		// Queued after the re-encode of the replay, if any.
+		deferRecode(fmt.Sprintf("slow motion of %s", replay), func() error {
+			return slowDownAll(cameraNumber, sessionDir, replay, speeds, interpolate, runIdle)
+		})
+		return
+	}
+	go func() {
+		slowMotionMu.Lock()
+		defer slowMotionMu.Unlock()
+		slowDownAll(cameraNumber, sessionDir, replay, speeds, interpolate, (*exec.Cmd).Run)
+	}()
+}
+
+// slowDownAll makes and stores the copies of a replay at each of speeds,
+// running ffmpeg with run. It stops at the first errPreempted.
+func slowDownAll(cameraNumber int, sessionDir, replay string, speeds []int, interpolate bool, run func(*exec.Cmd) error) error {
+	workDir, err := sessionWorkDir(sessionDir)
+	if err != nil {
+		logging.ErrorLogger.Printf("Camera %d: cannot make slow motion of %s: %v", cameraNumber, replay, err)
+		return nil
+	}
+	for _, percent := range speeds {
+		output := filepath.Join(workDir, slowMotionName(replay, percent))
+		if err := slowDown(storage.Join(sessionDir, replay), percent, interpolate, output, run); errors.Is(err, errPreempted) {
+			return err
+		} else if err != nil {
+			logging.ErrorLogger.Printf("Camera %d: failed to make the %d%% slow motion of %s: %v", cameraNumber, percent, replay, err)
+			continue
+		}
+		if err := storeSessionFile(sessionDir, output); err != nil {
+			logging.ErrorLogger.Printf("Camera %d: failed to store the %d%% slow motion of %s: %v", cameraNumber, percent, replay, err)
+			continue
+		}
+		logging.InfoLogger.Printf("Camera %d: %d%% slow motion of %s ready", cameraNumber, percent, replay)
+	}
+	return nil
+}
+
+// slowDown writes the copy of a stored replay at percent speed. ffmpeg writes
+// to a temporary name, so that a partial or preempted copy is never listed.
+func slowDown(name string, percent int, interpolate bool, output string, run func(*exec.Cmd) error) error {
+	input, err := storage.Current().Open(name)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	temp := output + ".tmp"
+	defer os.Remove(temp)
+	cmd := CreateFfmpegCmd(buildSlowMotionArgs(percent, interpolate, temp), "slowmotion")
+	cmd.Stdin = input
+	if err := run(cmd); err != nil {
+		return err
+	}
+	return os.Rename(temp, output)
+}
@@ -0,0 +1,57 @@
+package recording
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+func TestSlowMotionIsWrittenUnderATemporaryName(t *testing.T) {
+	videoDir := t.TempDir()
+	oldVideoDir := config.GetVideoDir()
+	config.SetVideoDir(videoDir)
+	t.Cleanup(func() { config.SetVideoDir(oldVideoDir) })
+	replay := "2025-03-01_14h00m00s_John_Smith_SNATCH_attempt2_Camera1.mp4"
+	if err := os.MkdirAll(filepath.Join(videoDir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(videoDir, "A", replay), []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), slowMotionName(replay, 50))
+
+	// encode writes what ffmpeg would, checking that the copy is not visible
+	// under its name meanwhile, and returns err.
+	encode := func(err error) func(*exec.Cmd) error {
+		return func(cmd *exec.Cmd) error {
+			target := cmd.Args[len(cmd.Args)-1]
+			if target == output {
+				t.Fatalf("ffmpeg writes straight to %s", output)
+			}
+			if _, statErr := os.Stat(output); statErr == nil {
+				t.Fatalf("%s exists while it is encoded", output)
+			}
+			if writeErr := os.WriteFile(target, []byte("slow"), 0644); writeErr != nil {
+				t.Fatal(writeErr)
+			}
+			return err
+		}
+	}
+
+	if err := slowDown(storage.Join("A", replay), 50, false, output, encode(errPreempted)); err != errPreempted {
+		t.Fatalf("err = %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(output)); len(entries) != 0 {
+		t.Fatalf("a preempted copy was left behind: %v", entries)
+	}
+	if err := slowDown(storage.Join("A", replay), 50, false, output, encode(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(output); err != nil || string(data) != "slow" {
+		t.Fatalf("copy = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,218 @@
+package recording
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// ffmpeg only writes to the recording once the first frame is encoded (the
+// encoder, and so the muxer, is opened on the first frame), so the time the
+// file gets its first bytes is the time the camera actually started.
+const (
+	startLatencyPoll    = 20 * time.Millisecond
+	startLatencyTimeout = 30 * time.Second
+)
+
+// startWatch measures when the files of an attempt recording start growing.
+type startWatch struct {
+	received      time.Time
+	fileNames     []string
+	cameraNumbers []int
+	started       []bool
+}
+
+// cameraStart is the start latency of one camera.
+type cameraStart struct {
+	camera  int
+	latency time.Duration
+}
+
+func newStartWatch(received time.Time, fileNames []string, cameraNumbers []int) *startWatch {
+	return &startWatch{
+		received:      received,
+		fileNames:     fileNames,
+		cameraNumbers: cameraNumbers,
+		started:       make([]bool, len(fileNames)),
+	}
+}
+
+// check returns the cameras whose file received its first bytes since the
+// previous check.
+func (w *startWatch) check(now time.Time, size func(string) int64) []cameraStart {
+	var starts []cameraStart
+	for i, name := range w.fileNames {
+		if w.started[i] || size(name) == 0 {
+			continue
+		}
+		w.started[i] = true
+		starts = append(starts, cameraStart{camera: w.cameraNumbers[i], latency: now.Sub(w.received)})
+	}
+	return starts
+}
+
+// done reports whether every camera has started.
+func (w *startWatch) done() bool {
+	for _, started := range w.started {
+		if !started {
+			return false
+		}
+	}
+	return true
+}
+
+// measureStartLatency logs how long each camera took from the start message
+// to its first frame, publishes it on /status, and warns the operator about
+// cameras slower than the configured threshold, since the replay of such a
+// camera may miss the beginning of the lift.
+func measureStartLatency(serial int64, received time.Time, fileNames []string, cameraNumbers []int) {
+	threshold := config.GetStartLatencyWarning()
+	w := newStartWatch(received, fileNames, cameraNumbers)
+	ticker := time.NewTicker(startLatencyPoll)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if atomic.LoadInt64(&recordingSerial) != serial || !IsRecording() {
+			return
+		}
+		var slow []string
+		for _, start := range w.check(now, fileSize) {
+			if warning := reportStart(start, threshold, now); warning != "" {
+				slow = append(slow, warning)
+			}
+		}
+		if len(slow) > 0 {
+			message := fmt.Sprintf("Recording: %s - %s attempt %d (Warning: %s)",
+				currentAttempt.AthleteName,
+				currentAttempt.LiftType,
+				currentAttempt.AttemptNumber,
+				strings.Join(slow, ", "))
+			httpServer.SendStatusWithDetails(httpServer.Recording, message, currentAttempt)
+		}
+		if w.done() {
+			return
+		}
+		if now.Sub(received) >= startLatencyTimeout {
+			// Cameras that never write are reported by the stall watchdog.
+			return
+		}
+	}
+}
+
+// reportStart logs the start latency of a camera and publishes it on /status.
+// It returns the warning shown to the operator when the camera was slower than
+// threshold, "" otherwise.
+func reportStart(start cameraStart, threshold time.Duration, now time.Time) string {
+	isSlow := threshold > 0 && start.latency > threshold
+	httpServer.SetStartLatency(httpServer.StartLatency{
+		Camera:     start.camera,
+		LatencyMs:  start.latency.Milliseconds(),
+		Slow:       isSlow,
+		MeasuredAt: now.UnixNano() / int64(time.Millisecond),
+	})
+	if !isSlow {
+		logging.InfoLogger.Printf("Camera %d: %dms from the start message to its first frame", start.camera, start.latency.Milliseconds())
+		return ""
+	}
+	logging.WarningLogger.Printf("Camera %d took %dms from the start message to its first frame", start.camera, start.latency.Milliseconds())
+	return fmt.Sprintf("Camera %d took %.1fs to start", start.camera, start.latency.Seconds())
+}
+
+// dropSilentCameras waits for the first frame of the cameras of the attempt,
+// since ffmpeg may run without ever receiving one (no signal, wrong stream),
+// and drops the cameras that recorded nothing. The attempt is recording
+// meanwhile, so that it can be stopped. failures are the cameras that could
+// not be started.
+func dropSilentCameras(serial int64, received time.Time, timeout time.Duration, failures []string) {
+	recordersMutex.Lock()
+	fileNames := append([]string(nil), currentFileNames...)
+	cameraNumbers := append([]int(nil), currentCameras...)
+	recordersMutex.Unlock()
+	missing, slow := waitForFirstFrames(received, fileNames, cameraNumbers, timeout, fileSize)
+
+	recordersMutex.Lock()
+	if atomic.LoadInt64(&recordingSerial) != serial || !IsRecording() {
+		recordersMutex.Unlock()
+		return
+	}
+	if len(missing) > 0 {
+		var silentCmds []*exec.Cmd
+		var silentStdins []*os.File
+		var silentCameras []int
+		for _, i := range missing {
+			logging.ErrorLogger.Printf("Camera %d recorded no frame within %s, not recorded", cameraNumbers[i], timeout)
+			failures = append(failures, fmt.Sprintf("Camera %d recorded no frame within %s%s", cameraNumbers[i], timeout, ffmpegErrorDetail(fileNames[i], cameraNumbers[i])))
+			for j := range currentAttempt.Cameras {
+				if currentAttempt.Cameras[j].Camera == cameraNumbers[i] {
+					currentAttempt.Cameras[j].Status = httpServer.CameraFailed
+				}
+			}
+			silentCmds = append(silentCmds, currentRecordings[i])
+			silentStdins = append(silentStdins, currentStdin[i])
+			silentCameras = append(silentCameras, cameraNumbers[i])
+		}
+		stopRecorders(silentCmds, silentStdins, silentCameras)
+		for k := len(missing) - 1; k >= 0; k-- {
+			i := missing[k]
+			releaseAttemptLog(fileNames[i])
+			os.Remove(fileNames[i])
+			currentRecordings = append(currentRecordings[:i], currentRecordings[i+1:]...)
+			currentStdin = append(currentStdin[:i], currentStdin[i+1:]...)
+			currentFileNames = append(currentFileNames[:i], currentFileNames[i+1:]...)
+			currentCameras = append(currentCameras[:i], currentCameras[i+1:]...)
+		}
+		fileNames = append([]string(nil), currentFileNames...)
+		cameraNumbers = append([]int(nil), currentCameras...)
+	}
+	recordersMutex.Unlock()
+
+	if len(fileNames) == 0 {
+		Recording = false
+		config.EndAttemptLog()
+		httpServer.SendStatus(httpServer.Error, fmt.Sprintf("Error: No camera could be started (%s)", strings.Join(failures, "; ")))
+		return
+	}
+	if len(missing) > 0 || len(slow) > 0 {
+		message := fmt.Sprintf("Recording: %s - %s attempt %d (Warning: %s)",
+			currentAttempt.AthleteName,
+			currentAttempt.LiftType,
+			currentAttempt.AttemptNumber,
+			strings.Join(append(failures, slow...), "; "))
+		httpServer.SendStatusWithDetails(httpServer.Recording, message, currentAttempt)
+	}
+	go watchRecording(serial, fileNames, cameraNumbers)
+}
+
+// waitForFirstFrames waits until every camera has recorded its first frame, or
+// until timeout has passed since the cameras were started. It returns the
+// indexes of the cameras that recorded nothing, and the warnings about the
+// cameras that were slow to start.
+func waitForFirstFrames(received time.Time, fileNames []string, cameraNumbers []int, timeout time.Duration, size func(string) int64) (missing []int, slow []string) {
+	threshold := config.GetStartLatencyWarning()
+	w := newStartWatch(received, fileNames, cameraNumbers)
+	deadline := time.Now().Add(timeout)
+	for {
+		now := time.Now()
+		for _, start := range w.check(now, size) {
+			if warning := reportStart(start, threshold, now); warning != "" {
+				slow = append(slow, warning)
+			}
+		}
+		if w.done() || !now.Before(deadline) {
+			break
+		}
+		time.Sleep(startLatencyPoll)
+	}
+	for i, started := range w.started {
+		if !started {
+			missing = append(missing, i)
+		}
+	}
+	return missing, slow
+}
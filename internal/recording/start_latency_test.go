@@ -0,0 +1,57 @@
+package recording
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartLatencyIsMeasuredToTheFirstBytesOfEachCamera(t *testing.T) {
+	received := time.Unix(1000, 0)
+	w := newStartWatch(received, []string{"cam1.mkv", "cam2.mkv"}, []int{1, 2})
+	sizes := map[string]int64{}
+	size := func(name string) int64 { return sizes[name] }
+
+	if starts := w.check(received.Add(100*time.Millisecond), size); len(starts) != 0 {
+		t.Fatalf("cameras started before writing anything: %+v", starts)
+	}
+	sizes["cam1.mkv"] = 4096
+	starts := w.check(received.Add(400*time.Millisecond), size)
+	if len(starts) != 1 || starts[0].camera != 1 || starts[0].latency != 400*time.Millisecond {
+		t.Fatalf("check() = %+v, want Camera 1 after 400ms", starts)
+	}
+	if w.done() {
+		t.Fatalf("done before Camera 2 started")
+	}
+
+	sizes["cam1.mkv"] = 8192
+	sizes["cam2.mkv"] = 4096
+	starts = w.check(received.Add(2*time.Second), size)
+	if len(starts) != 1 || starts[0].camera != 2 || starts[0].latency != 2*time.Second {
+		t.Fatalf("check() = %+v, want only Camera 2 after 2s", starts)
+	}
+	if !w.done() {
+		t.Fatalf("not done after every camera started")
+	}
+}
+
+func TestCamerasWithoutAFirstFrameAreReportedMissing(t *testing.T) {
+	sizes := map[string]int64{"cam1.mkv": 4096, "cam3.mkv": 4096}
+	size := func(name string) int64 { return sizes[name] }
+
+	started := time.Now()
+	missing, _ := waitForFirstFrames(started, []string{"cam1.mkv", "cam2.mkv", "cam3.mkv"}, []int{1, 2, 3}, 100*time.Millisecond, size)
+	if len(missing) != 1 || missing[0] != 1 {
+		t.Fatalf("missing = %v, want only the index of Camera 2", missing)
+	}
+	if waited := time.Since(started); waited < 100*time.Millisecond {
+		t.Fatalf("gave up on Camera 2 after %s, before the timeout", waited)
+	}
+
+	started = time.Now()
+	if missing, _ := waitForFirstFrames(started, []string{"cam1.mkv", "cam3.mkv"}, []int{1, 3}, 10*time.Second, size); len(missing) != 0 {
+		t.Fatalf("missing = %v, want none", missing)
+	}
+	if waited := time.Since(started); waited > time.Second {
+		t.Fatalf("waited %s although every camera had started", waited)
+	}
+}
@@ -0,0 +1,40 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/storage"
+)
+
+// sessionWorkDir creates the folder of a session in storage and returns the
+// local directory where ffmpeg writes its files: the session folder itself
+// when the backend keeps local files, the same folder of the video directory
+// otherwise.
+func sessionWorkDir(sessionDir string) (string, error) {
+	store := storage.Current()
+	if err := store.MkdirAll(sessionDir); err != nil {
+		return "", err
+	}
+	if dir, ok := store.LocalPath(sessionDir); ok {
+		return dir, nil
+	}
+	dir := filepath.Join(config.GetVideoDir(), sessionDir)
+	return dir, os.MkdirAll(dir, os.ModePerm)
+}
+
+// storeSessionFile hands a finished file of sessionWorkDir to storage.
+func storeSessionFile(sessionDir, fileName string) error {
+	return storage.Current().Import(fileName, storage.Join(sessionDir, filepath.Base(fileName)))
+}
+
+// publishReplay stores a finished replay and makes it the replay of its
+// camera.
+func publishReplay(cameraNumber int, sessionDir, fileName string, durationMs int64) error {
+	if err := storeSessionFile(sessionDir, fileName); err != nil {
+		return err
+	}
+	return httpServer.PublishReplayState(cameraNumber, sessionDir, filepath.Base(fileName), durationMs)
+}
@@ -0,0 +1,137 @@
+package recording
+
+import (
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/config/ffmpeg"
+	"github.com/owlcms/replays/internal/logging"
+)
+
+var (
+	trimEncoderMutex sync.Mutex
+	trimEncoder      *HwEncoder // nil re-encodes trims with libx264
+	trimHevcEncoder  *HwEncoder // for trim sections with codec = "hevc"; nil uses libx265
+)
+
+// DetectTrimEncoder chooses the hardware encoders used by trims that re-encode,
+// following trimEncoder in config.toml. Probing takes a few seconds, so it is
+// meant to run in the background at startup; trims use libx264 until then.
+func DetectTrimEncoder() {
+	if config.TrimEncoder == "software" || config.TrimEncoder == "libx264" {
+		logging.InfoLogger.Printf("Trims that re-encode use libx264 (trimEncoder = %q)", config.TrimEncoder)
+		setTrimEncoder(nil, nil)
+		return
+	}
+	encoders := DetectEncoders()
+	ffmpegPath := currentFFmpegPath()
+	setTrimEncoder(pickTrimEncoder(encoders, config.TrimEncoder, ffmpegPath), pickHevcTrimEncoder(encoders, config.TrimEncoder, ffmpegPath))
+}
+
+// pickTrimEncoder returns the encoder trims should use among the verified
+// ones, or nil for libx264. Encoders only verified with another ffmpeg than
+// the one trims run with are left out. auto picks an H.264 encoder; naming an
+// HEVC one re-encodes the replays of the MJPEG cameras to HEVC.
+func pickTrimEncoder(encoders []HwEncoder, choice, ffmpegPath string) *HwEncoder {
+	for _, enc := range encoders {
+		if enc.FFmpegPath != "" && enc.FFmpegPath != ffmpegPath {
+			logging.InfoLogger.Printf("Encoder %s only works with %s, not used for trims", enc.Name, enc.FFmpegPath)
+			continue
+		}
+		if choice == enc.Name || (choice == "" || choice == "auto") && enc.Codec() == ffmpeg.Codecs[0] {
+			logging.InfoLogger.Printf("Trims that re-encode use %s (%s)", enc.Name, enc.Description)
+			return &enc
+		}
+	}
+	if choice != "" && choice != "auto" {
+		logging.WarningLogger.Printf("trimEncoder %s is not available on this machine, trims that re-encode use libx264", choice)
+	} else {
+		logging.InfoLogger.Printf("No hardware encoder found, trims that re-encode use libx264")
+	}
+	return nil
+}
+
+// pickHevcTrimEncoder returns the encoder of the trims whose trim section asks
+// for hevc: trimEncoder when it names an HEVC encoder, the first verified one
+// otherwise, or nil for libx265.
+func pickHevcTrimEncoder(encoders []HwEncoder, choice, ffmpegPath string) *HwEncoder {
+	if ffmpeg.EncoderCodec(choice) != "hevc" {
+		choice = "auto"
+	}
+	for _, enc := range encoders {
+		if enc.FFmpegPath != "" && enc.FFmpegPath != ffmpegPath || enc.Codec() != "hevc" {
+			continue
+		}
+		if choice == "auto" || choice == enc.Name {
+			logging.InfoLogger.Printf("Trims re-encoded to hevc use %s (%s)", enc.Name, enc.Description)
+			return &enc
+		}
+	}
+	return nil
+}
+
+func setTrimEncoder(enc, hevc *HwEncoder) {
+	trimEncoderMutex.Lock()
+	defer trimEncoderMutex.Unlock()
+	trimEncoder = enc
+	trimHevcEncoder = hevc
+}
+
+func currentTrimEncoder() *HwEncoder {
+	trimEncoderMutex.Lock()
+	defer trimEncoderMutex.Unlock()
+	return trimEncoder
+}
+
+func currentHevcTrimEncoder() *HwEncoder {
+	trimEncoderMutex.Lock()
+	defer trimEncoderMutex.Unlock()
+	return trimHevcEncoder
+}
+
+// trimEncoderFor returns the hardware encoder re-encoding the replays of
+// camera, nil for software or a stream copy.
+func trimEncoderFor(camera config.CameraConfiguration) *HwEncoder {
+	if hevcOnlyTrim(camera) {
+		return currentHevcTrimEncoder()
+	}
+	if camera.Recode && !camera.TrimRecodes() {
+		return currentTrimEncoder()
+	}
+	return nil
+}
+
+// hevcOnlyTrim reports whether the replays of camera are re-encoded only
+// because its trim section asks for hevc, which a hardware encoder can do;
+// crf, preset, crop, scale and the overlays need libx265.
+func hevcOnlyTrim(camera config.CameraConfiguration) bool {
+	p := camera.Trim
+	if camera.Proxy || p == nil || p.VideoCodec() != "hevc" || p.Crf > 0 || p.Preset != "" || p.Crop != "" || p.Scale != "" {
+		return false
+	}
+	camera.Trim = nil
+	return !camera.TrimRecodes()
+}
+
+// currentFFmpegPath is the ffmpeg that runs the trims.
+func currentFFmpegPath() string {
+	if path := config.GetFFmpegPath(); path != "" {
+		return path
+	}
+	return "ffmpeg"
+}
+
+// hwTrimArgs returns the options that go before the input and the video
+// encoding options of a trim re-encoded with enc.
+func hwTrimArgs(enc *HwEncoder) (input, output []string) {
+	input = cleanParams(enc.TestInit)
+	if enc.VideoFilter != "" {
+		output = append(output, "-vf", enc.VideoFilter)
+	}
+	output = append(output, cleanParams(enc.OutputParameters)...)
+	if enc.Codec() == "hevc" {
+		// hvc1 is the tag QuickTime and Apple editors need.
+		output = append(output, "-tag:v", "hvc1")
+	}
+	return input, output
+}
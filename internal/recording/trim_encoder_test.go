@@ -0,0 +1,98 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestRecodingTrimUsesTheHardwareEncoder(t *testing.T) {
+	vaapi := HwEncoder{
+		Name:             "h264_vaapi",
+		VideoFilter:      "format=nv12,hwupload",
+		OutputParameters: "-c:v h264_vaapi -profile:v main -b:v 8M -bf 0",
+		TestInit:         "-init_hw_device vaapi=va:/dev/dri/renderD128",
+		FFmpegPath:       "ffmpeg",
+	}
+	camera := config.CameraConfiguration{Recode: true}
+
+	args := strings.Join(buildTrimmingArgsWith(&vaapi, 5000, "attempt.mkv", "replay.mp4", camera), " ")
+	want := "-y -init_hw_device vaapi=va:/dev/dri/renderD128 -sseof -5.000 -i attempt.mkv -vf format=nv12,hwupload -c:v h264_vaapi -profile:v main -b:v 8M -bf 0 -avoid_negative_ts make_zero -movflags +faststart replay.mp4"
+	if args != want {
+		t.Fatalf("hardware trim args = %s, want %s", args, want)
+	}
+
+	software := strings.Join(buildTrimmingArgsWith(nil, 5000, "attempt.mkv", "replay.mp4", camera), " ")
+	if !strings.Contains(software, "-c:v libx264") {
+		t.Fatalf("trim without hardware encoder does not use libx264: %s", software)
+	}
+
+	camera.Recode = false
+	copied := strings.Join(buildTrimmingArgsWith(&vaapi, 5000, "attempt.mkv", "replay.mp4", camera), " ")
+	if strings.Contains(copied, "vaapi") || !strings.Contains(copied, "-c copy") {
+		t.Fatalf("copied trim uses the hardware encoder: %s", copied)
+	}
+}
+
+func TestTrimEncoderChoice(t *testing.T) {
+	encoders := []HwEncoder{
+		{Name: "h264_nvenc", FFmpegPath: "/usr/bin/ffmpeg"},
+		{Name: "h264_qsv", FFmpegPath: "ffmpeg"},
+		{Name: "h264_vaapi", FFmpegPath: "ffmpeg"},
+	}
+	if enc := pickTrimEncoder(encoders, "auto", "ffmpeg"); enc == nil || enc.Name != "h264_qsv" {
+		t.Fatalf("auto picked %v, want h264_qsv (nvenc only works with another ffmpeg)", enc)
+	}
+	if enc := pickTrimEncoder(encoders, "h264_vaapi", "ffmpeg"); enc == nil || enc.Name != "h264_vaapi" {
+		t.Fatalf("h264_vaapi picked %v", enc)
+	}
+	if enc := pickTrimEncoder(encoders, "h264_amf", "ffmpeg"); enc != nil {
+		t.Fatalf("unavailable encoder picked %v, want libx264", enc)
+	}
+	if enc := pickTrimEncoder(nil, "auto", "ffmpeg"); enc != nil {
+		t.Fatalf("picked %v without hardware encoders", enc)
+	}
+}
+
+func TestHevcTrimEncoderChoice(t *testing.T) {
+	encoders := []HwEncoder{
+		{Name: "h264_nvenc", FFmpegPath: "ffmpeg"},
+		{Name: "hevc_nvenc", FFmpegPath: "ffmpeg"},
+	}
+	if enc := pickTrimEncoder(encoders[1:], "auto", "ffmpeg"); enc != nil {
+		t.Fatalf("auto picked %v for H.264 trims", enc)
+	}
+	if enc := pickTrimEncoder(encoders, "hevc_nvenc", "ffmpeg"); enc == nil || enc.Name != "hevc_nvenc" {
+		t.Fatalf("hevc_nvenc picked %v", enc)
+	}
+	if enc := pickHevcTrimEncoder(encoders, "h264_nvenc", "ffmpeg"); enc == nil || enc.Name != "hevc_nvenc" {
+		t.Fatalf("hevc trims picked %v", enc)
+	}
+	if enc := pickHevcTrimEncoder(encoders[:1], "auto", "ffmpeg"); enc != nil {
+		t.Fatalf("hevc trims picked %v without an HEVC encoder", enc)
+	}
+}
+
+func TestHevcTrimSectionUsesTheHardwareEncoder(t *testing.T) {
+	nvenc := HwEncoder{Name: "hevc_nvenc", VideoFilter: "format=yuv420p", OutputParameters: "-c:v hevc_nvenc -b:v 4M -bf 0", FFmpegPath: "ffmpeg"}
+	camera := config.CameraConfiguration{Trim: &config.TrimProfile{Camera: 1, Codec: "hevc"}}
+	if !hevcOnlyTrim(camera) {
+		t.Fatal("a trim section with codec = hevc only is not re-encoded in hardware")
+	}
+
+	args := strings.Join(buildTrimmingArgsWith(&nvenc, 5000, "attempt.mkv", "replay.mp4", camera), " ")
+	want := "-y -sseof -5.000 -i attempt.mkv -vf format=yuv420p -c:v hevc_nvenc -b:v 4M -bf 0 -tag:v hvc1 -avoid_negative_ts make_zero -movflags +faststart replay.mp4"
+	if args != want {
+		t.Fatalf("hevc trim args = %s, want %s", args, want)
+	}
+
+	camera.Trim.Scale = "1920:-2"
+	if hevcOnlyTrim(camera) {
+		t.Fatal("a scaled hevc trim is re-encoded in hardware")
+	}
+	software := strings.Join(buildTrimmingArgsWith(&nvenc, 5000, "attempt.mkv", "replay.mp4", camera), " ")
+	if !strings.Contains(software, "-c:v libx265") || strings.Contains(software, "hevc_nvenc") {
+		t.Fatalf("scaled hevc trim does not use libx265: %s", software)
+	}
+}
@@ -0,0 +1,141 @@
+package recording
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+)
+
+// trimQueueSize bounds the trims waiting for a worker; more than the cameras
+// of a few attempts never wait at once.
+const trimQueueSize = 64
+
+var (
+	trimPoolOnce sync.Once
+	trimQueue    chan func()
+	trimWaiting  int64 // trims queued and not yet started
+)
+
+// trimWorkers is the number of cameras re-encoded at the same time.
+func trimWorkers() int {
+	if config.TrimWorkers > 0 {
+		return config.TrimWorkers
+	}
+	return defaultTrimWorkers(runtime.NumCPU())
+}
+
+// defaultTrimWorkers uses half the cores, so that a weak processor does not
+// re-encode every camera at once.
+func defaultTrimWorkers(cores int) int {
+	if workers := cores / 2; workers > 1 {
+		return workers
+	}
+	return 1
+}
+
+// trimReencodes tells if the trim of a camera may re-encode its replay, and
+// so waits for a trim worker. Stream copies, deferred re-encodes included,
+// take little processor and are not held.
+func trimReencodes(camera config.CameraConfiguration) bool {
+	if camera.Recode || camera.FrameAccurate {
+		return true
+	}
+	if config.DeferRecodes {
+		return false
+	}
+	return camera.TrimRecodes() || config.Overlay || config.ScoreOverlay || config.Timecode
+}
+
+// trimParallelism is the number of re-encodes sharing the processor during
+// the trim of an attempt.
+func trimParallelism() int {
+	if cameras := len(config.GetCameraConfigs()); cameras < trimWorkers() {
+		return cameras
+	}
+	return trimWorkers()
+}
+
+// queueTrim runs job on the next free trim worker.
+func queueTrim(job func()) {
+	trimPoolOnce.Do(func() {
+		trimQueue = make(chan func(), trimQueueSize)
+		for i := 0; i < trimWorkers(); i++ {
+			go func() {
+				for job := range trimQueue {
+					atomic.AddInt64(&trimWaiting, -1)
+					job()
+				}
+			}()
+		}
+	})
+	atomic.AddInt64(&trimWaiting, 1)
+	trimQueue <- job
+}
+
+// trimBatch follows the trims of one attempt and reports their states on the
+// status channel.
+type trimBatch struct {
+	mu      sync.Mutex
+	message string
+	details httpServer.StatusAttemptDetails
+	jobs    []httpServer.TrimJob
+	wg      sync.WaitGroup
+}
+
+func newTrimBatch(message string, details httpServer.StatusAttemptDetails, cameraNumbers []int) *trimBatch {
+	batch := &trimBatch{message: message, details: details}
+	for _, cameraNumber := range cameraNumbers {
+		batch.jobs = append(batch.jobs, httpServer.TrimJob{Camera: cameraNumber, Status: httpServer.TrimQueued})
+	}
+	return batch
+}
+
+// submit runs the trim of job i, queued for a trim worker when it recodes;
+// finalFileName is where the replay is expected when the trim succeeds.
+func (b *trimBatch) submit(i int, finalFileName *string, recodes bool, trim func(wg *sync.WaitGroup)) {
+	b.wg.Add(1)
+	job := func() {
+		b.set(i, httpServer.TrimRunning)
+		var done sync.WaitGroup
+		done.Add(1)
+		trim(&done)
+		done.Wait()
+		status := httpServer.TrimDone
+		if _, err := os.Stat(*finalFileName); err != nil && !config.NoVideo {
+			status = httpServer.TrimFailed
+		}
+		b.set(i, status)
+		b.wg.Done()
+	}
+	if !recodes {
+		go job()
+		return
+	}
+	queueTrim(job)
+}
+
+// wait returns when every trim of the attempt has finished.
+func (b *trimBatch) wait() {
+	b.wg.Wait()
+}
+
+func (b *trimBatch) set(i int, status string) {
+	b.mu.Lock()
+	b.jobs[i].Status = status
+	b.mu.Unlock()
+	b.report()
+}
+
+// report sends the states of the trims of the attempt.
+func (b *trimBatch) report() {
+	b.mu.Lock()
+	details := b.details
+	details.TrimJobs = append([]httpServer.TrimJob(nil), b.jobs...)
+	details.TrimQueued = int(atomic.LoadInt64(&trimWaiting))
+	b.mu.Unlock()
+	httpServer.SendStatusWithDetails(httpServer.Trimming, b.message, details)
+}
@@ -0,0 +1,118 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/httpServer"
+)
+
+func TestDefaultTrimWorkersUsesHalfTheCores(t *testing.T) {
+	for cores, want := range map[int]int{1: 1, 2: 1, 3: 1, 4: 2, 8: 4, 16: 8} {
+		if got := defaultTrimWorkers(cores); got != want {
+			t.Errorf("defaultTrimWorkers(%d) = %d, want %d", cores, got, want)
+		}
+	}
+}
+
+func TestTrimBatchRunsAtMostTrimWorkersAtOnce(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-httpServer.StatusChan:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	dir := t.TempDir()
+	cameras := []int{1, 2, 3, 4, 5, 6}
+	finalFileNames := make([]string, len(cameras))
+	batch := newTrimBatch("Trimming video", httpServer.StatusAttemptDetails{}, cameras)
+
+	var running, most int32
+	for i := range cameras {
+		i := i
+		batch.submit(i, &finalFileNames[i], true, func(wg *sync.WaitGroup) {
+			defer wg.Done()
+			now := atomic.AddInt32(&running, 1)
+			for {
+				seen := atomic.LoadInt32(&most)
+				if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			// The cameras at even indexes (1, 3 and 5) fail and leave no replay.
+			finalFileNames[i] = filepath.Join(dir, filepath.Base(t.Name())+string(rune('a'+i))+".mp4")
+			if i%2 == 1 {
+				os.WriteFile(finalFileNames[i], nil, 0644)
+			}
+		})
+	}
+	batch.wait()
+
+	if int(most) > trimWorkers() {
+		t.Fatalf("%d trims ran at once, want at most %d", most, trimWorkers())
+	}
+	for i, job := range batch.jobs {
+		want := httpServer.TrimDone
+		if i%2 == 0 {
+			want = httpServer.TrimFailed
+		}
+		if job.Camera != cameras[i] || job.Status != want {
+			t.Errorf("job %d = %+v, want camera %d %s", i, job, cameras[i], want)
+		}
+	}
+}
+
+func TestTrimBatchDoesNotHoldStreamCopies(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-httpServer.StatusChan:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	cameras := make([]int, trimWorkers()+1)
+	for i := range cameras {
+		cameras[i] = i + 1
+	}
+	finalFileNames := make([]string, len(cameras))
+	batch := newTrimBatch("Trimming video", httpServer.StatusAttemptDetails{}, cameras)
+
+	// Each copy waits for all of them to run: one more than the workers
+	// would never finish if they were queued.
+	var started sync.WaitGroup
+	started.Add(len(cameras))
+	for i := range cameras {
+		batch.submit(i, &finalFileNames[i], false, func(wg *sync.WaitGroup) {
+			defer wg.Done()
+			started.Done()
+			started.Wait()
+		})
+	}
+	finished := make(chan struct{})
+	go func() {
+		batch.wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream copies waited for the trim workers")
+	}
+}
@@ -0,0 +1,99 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/owlcms/replays/internal/logging"
+)
+
+// usbQuirk adjusts the modes detected for a USB capture dongle that does not
+// deliver what it advertises.
+type usbQuirk struct {
+	name string
+	// maxFps is the frame rate the dongle really delivers; it advertises
+	// more. 0 trusts what it advertises.
+	maxFps int
+	// mjpegAbove720p: the raw formats advertised above 1280x720 do not go
+	// through USB 2, only MJPEG does.
+	mjpegAbove720p bool
+}
+
+// usbQuirks are the known HDMI-to-USB dongles, by USB vendor:product ID in
+// lower case.
+var usbQuirks = map[string]usbQuirk{
+	// MacroSilicon MS2109, sold under many names.
+	"534d:2109": {name: "MS2109 HDMI capture", maxFps: 30, mjpegAbove720p: true},
+	"eba4:7588": {name: "MS2109 HDMI capture (rebadged)", maxFps: 30, mjpegAbove720p: true},
+	// MacroSilicon MS2106, the older USB 2 chip.
+	"534d:6021": {name: "MS2106 HDMI capture", maxFps: 30, mjpegAbove720p: true},
+}
+
+// sysfsVideo4Linux is where Linux describes the video devices.
+var sysfsVideo4Linux = "/sys/class/video4linux"
+
+// v4l2USBID returns the vendor:product ID of the USB device behind a
+// /dev/video node, "" when it is not a USB device.
+func v4l2USBID(device string) string {
+	// The device link points to the USB interface; the IDs are on its parent.
+	dir, err := filepath.EvalSymlinks(filepath.Join(sysfsVideo4Linux, filepath.Base(device), "device"))
+	if err != nil {
+		return ""
+	}
+	vendor, err := os.ReadFile(filepath.Join(filepath.Dir(dir), "idVendor"))
+	if err != nil {
+		return ""
+	}
+	product, err := os.ReadFile(filepath.Join(filepath.Dir(dir), "idProduct"))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(string(vendor)) + ":" + strings.TrimSpace(string(product)))
+}
+
+var dshowUSBIDPattern = regexp.MustCompile(`(?i)vid_([0-9a-f]{4})&pid_([0-9a-f]{4})`)
+
+// dshowUSBID returns the vendor:product ID found in the alternative name of
+// a DirectShow device, "" when it is not a USB device.
+func dshowUSBID(alternativeName string) string {
+	m := dshowUSBIDPattern.FindStringSubmatch(alternativeName)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(m[1] + ":" + m[2])
+}
+
+// applyUSBQuirks corrects the modes advertised by a known dongle. Modes left
+// empty by the correction are kept as advertised.
+func applyUSBQuirks(usbID, name string, modes []cameraMode) []cameraMode {
+	quirk, ok := usbQuirks[usbID]
+	if !ok {
+		return modes
+	}
+	var corrected []cameraMode
+	for _, mode := range modes {
+		if quirk.mjpegAbove720p && mode.pixFmt != "mjpeg" && mode.height > 720 {
+			continue
+		}
+		if quirk.maxFps > 0 && mode.fps > quirk.maxFps {
+			mode.fps = quirk.maxFps
+		}
+		duplicate := false
+		for _, kept := range corrected {
+			if kept == mode {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			corrected = append(corrected, mode)
+		}
+	}
+	if len(corrected) == 0 {
+		return modes
+	}
+	logging.InfoLogger.Printf("Camera %s is a %s (%s): %d advertised modes corrected to %d", name, quirk.name, usbID, len(modes), len(corrected))
+	return corrected
+}
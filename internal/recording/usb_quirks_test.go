@@ -0,0 +1,60 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyUSBQuirksCorrectsTheMS2109(t *testing.T) {
+	modes := []cameraMode{
+		{pixFmt: "mjpeg", width: 1920, height: 1080, fps: 60},
+		{pixFmt: "yuyv422", width: 1920, height: 1080, fps: 60},
+		{pixFmt: "yuyv422", width: 1280, height: 720, fps: 10},
+		{pixFmt: "mjpeg", width: 1920, height: 1080, fps: 30},
+	}
+	want := []cameraMode{
+		{pixFmt: "mjpeg", width: 1920, height: 1080, fps: 30},
+		{pixFmt: "yuyv422", width: 1280, height: 720, fps: 10},
+	}
+	if got := applyUSBQuirks("534d:2109", "USB Video", modes); !reflect.DeepEqual(got, want) {
+		t.Errorf("applyUSBQuirks = %+v, want %+v", got, want)
+	}
+	if got := applyUSBQuirks("046d:085e", "Logitech BRIO", modes); !reflect.DeepEqual(got, modes) {
+		t.Errorf("an unknown camera was changed: %+v", got)
+	}
+}
+
+func TestUSBIDs(t *testing.T) {
+	if id := dshowUSBID(`@device_pnp_\\?\usb#vid_534D&pid_2109&mi_00#6&1b2c3d4&0&0000#{65e8773d-8f56-11d0-a3b9-00a0c9223196}\global`); id != "534d:2109" {
+		t.Errorf("dshowUSBID = %q, want 534d:2109", id)
+	}
+	if id := dshowUSBID(`@device_sw_{860BB310-5D01-11D0-BD3B-00A0C911CE86}\{OBS}`); id != "" {
+		t.Errorf("dshowUSBID(virtual camera) = %q, want none", id)
+	}
+
+	root := t.TempDir()
+	usb := filepath.Join(root, "devices", "1-2")
+	iface := filepath.Join(usb, "1-2:1.0")
+	if err := os.MkdirAll(iface, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(usb, "idVendor"), []byte("534d\n"), 0644)
+	os.WriteFile(filepath.Join(usb, "idProduct"), []byte("2109\n"), 0644)
+	if err := os.MkdirAll(filepath.Join(root, "video4linux", "video0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(iface, filepath.Join(root, "video4linux", "video0", "device")); err != nil {
+		t.Skip(err)
+	}
+	saved := sysfsVideo4Linux
+	defer func() { sysfsVideo4Linux = saved }()
+	sysfsVideo4Linux = filepath.Join(root, "video4linux")
+	if id := v4l2USBID("/dev/video0"); id != "534d:2109" {
+		t.Errorf("v4l2USBID = %q, want 534d:2109", id)
+	}
+	if id := v4l2USBID("/dev/video2"); id != "" {
+		t.Errorf("v4l2USBID(missing) = %q, want none", id)
+	}
+}
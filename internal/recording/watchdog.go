@@ -0,0 +1,183 @@
+package recording
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+	"github.com/owlcms/replays/internal/httpServer"
+	"github.com/owlcms/replays/internal/logging"
+	"github.com/owlcms/replays/internal/state"
+)
+
+// watchInterval is how often the files of a running attempt are checked.
+const watchInterval = time.Second
+
+// recordingSerial identifies the running attempt recording, so that the
+// watchdog of one attempt leaves the next one alone.
+var recordingSerial int64
+
+// recordingWatch follows the files of one attempt recording.
+type recordingWatch struct {
+	started       time.Time
+	fileNames     []string
+	cameraNumbers []int
+	sizes         []int64
+	lastGrowth    []time.Time
+	stalled       []bool
+	restarts      []int
+}
+
+func newRecordingWatch(started time.Time, fileNames []string, cameraNumbers []int) *recordingWatch {
+	w := &recordingWatch{
+		started:       started,
+		fileNames:     fileNames,
+		cameraNumbers: cameraNumbers,
+		sizes:         make([]int64, len(fileNames)),
+		lastGrowth:    make([]time.Time, len(fileNames)),
+		stalled:       make([]bool, len(fileNames)),
+		restarts:      make([]int, len(fileNames)),
+	}
+	for i := range w.lastGrowth {
+		w.lastGrowth[i] = started
+	}
+	return w
+}
+
+// check records the current file sizes and returns the cameras that just
+// stalled (nothing written for stallTimeout) and those that recovered.
+func (w *recordingWatch) check(now time.Time, stallTimeout time.Duration, size func(string) int64) (stalled, recovered []int) {
+	for i, name := range w.fileNames {
+		if current := size(name); current > w.sizes[i] {
+			w.sizes[i] = current
+			w.lastGrowth[i] = now
+			if w.stalled[i] {
+				w.stalled[i] = false
+				recovered = append(recovered, w.cameraNumbers[i])
+			}
+			continue
+		}
+		if stallTimeout > 0 && !w.stalled[i] && now.Sub(w.lastGrowth[i]) >= stallTimeout {
+			w.stalled[i] = true
+			stalled = append(stalled, w.cameraNumbers[i])
+		}
+	}
+	return stalled, recovered
+}
+
+func fileSize(name string) int64 {
+	info, err := os.Stat(name)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// watchRecording warns the operator about cameras that stop recording and
+// saves the attempt when no decision arrives within the configured maximum
+// (owlcms stopped or the network went down).
+func watchRecording(serial int64, fileNames []string, cameraNumbers []int) {
+	maxDuration := config.GetMaxRecording()
+	stallTimeout := config.GetStallTimeout()
+	if maxDuration <= 0 && stallTimeout <= 0 {
+		return
+	}
+	w := newRecordingWatch(time.Now(), fileNames, cameraNumbers)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if atomic.LoadInt64(&recordingSerial) != serial || !IsRecording() {
+			return
+		}
+
+		stalled, recovered := w.check(now, stallTimeout, fileSize)
+		for _, cameraNumber := range stalled {
+			logging.WarningLogger.Printf("Camera %d has recorded nothing for %s", cameraNumber, stallTimeout)
+			w.restart(serial, cameraNumber, now)
+		}
+		for _, cameraNumber := range recovered {
+			logging.InfoLogger.Printf("Camera %d is recording again", cameraNumber)
+		}
+		if len(stalled) > 0 || len(recovered) > 0 {
+			httpServer.SendStatusWithDetails(httpServer.Recording, w.statusMessage(stallTimeout), w.attemptDetails())
+		}
+
+		if maxDuration > 0 && now.Sub(w.started) >= maxDuration {
+			if state.LastDecisionTime >= w.started.UnixNano()/int64(time.Millisecond) {
+				// The decision arrived; its handler is saving the attempt.
+				return
+			}
+			logging.WarningLogger.Printf("No referees decision after %s, saving the recording of %s", maxDuration, currentAttempt.AthleteName)
+			httpServer.SendStatus(httpServer.Error, fmt.Sprintf("Warning: no decision received after %s, saving the recording of %s", maxDuration, currentAttempt.AthleteName))
+			if err := StopRecordingAndTrim(now.UnixNano() / int64(time.Millisecond)); err != nil {
+				logging.ErrorLogger.Printf("Failed to save the recording after %s: %v", maxDuration, err)
+			}
+			return
+		}
+	}
+}
+
+// restart starts a new ffmpeg for a stalled camera, or one whose ffmpeg
+// crashed, as long as the camera has restarts left in this attempt.
+func (w *recordingWatch) restart(serial int64, cameraNumber int, now time.Time) {
+	for i, number := range w.cameraNumbers {
+		if number != cameraNumber || w.restarts[i] >= config.GetStallRestarts() {
+			continue
+		}
+		fileName, err := restartRecorder(serial, i, w.lastGrowth[i])
+		if err != nil {
+			logging.ErrorLogger.Printf("Failed to restart Camera %d: %v", cameraNumber, err)
+			return
+		}
+		w.restarts[i]++
+		w.fileNames[i] = fileName
+		w.sizes[i] = 0
+		w.lastGrowth[i] = now
+		w.stalled[i] = false
+	}
+}
+
+// attemptDetails is the attempt being recorded with the stalled cameras marked.
+func (w *recordingWatch) attemptDetails() httpServer.StatusAttemptDetails {
+	details := currentAttempt
+	details.Cameras = make([]httpServer.RecordingCamera, len(currentAttempt.Cameras))
+	copy(details.Cameras, currentAttempt.Cameras)
+	for i := range details.Cameras {
+		for j, cameraNumber := range w.cameraNumbers {
+			if cameraNumber == details.Cameras[i].Camera && w.stalled[j] {
+				details.Cameras[i].Status = httpServer.CameraStalled
+			}
+		}
+	}
+	return details
+}
+
+// statusMessage is the recording status with the cameras currently stalled.
+func (w *recordingWatch) statusMessage(stallTimeout time.Duration) string {
+	message := fmt.Sprintf("Recording: %s - %s attempt %d",
+		currentAttempt.AthleteName,
+		currentAttempt.LiftType,
+		currentAttempt.AttemptNumber)
+	var stalled []string
+	for i, isStalled := range w.stalled {
+		if isStalled {
+			stalled = append(stalled, fmt.Sprintf("Camera %d", w.cameraNumbers[i]))
+		}
+	}
+	if len(stalled) > 0 {
+		message += fmt.Sprintf(" (Warning: %s recorded nothing for %s)", strings.Join(stalled, ", "), stallTimeout)
+	}
+	var restarted []string
+	for i, restarts := range w.restarts {
+		if restarts > 0 {
+			restarted = append(restarted, fmt.Sprintf("Camera %d", w.cameraNumbers[i]))
+		}
+	}
+	if len(restarted) > 0 {
+		message += fmt.Sprintf(" (Warning: %s restarted, the replay has a gap)", strings.Join(restarted, ", "))
+	}
+	return message
+}
@@ -0,0 +1,100 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+func TestRecordingWatchReportsStalledAndRecoveredCameras(t *testing.T) {
+	start := time.Now()
+	sizes := map[string]int64{"cam1.mkv": 0, "cam2.mkv": 0}
+	size := func(name string) int64 { return sizes[name] }
+	w := newRecordingWatch(start, []string{"cam1.mkv", "cam2.mkv"}, []int{1, 2})
+
+	sizes["cam1.mkv"] = 1000
+	sizes["cam2.mkv"] = 1000
+	if stalled, _ := w.check(start.Add(time.Second), 5*time.Second, size); len(stalled) != 0 {
+		t.Fatalf("growing files reported as stalled: %v", stalled)
+	}
+
+	sizes["cam1.mkv"] = 5000
+	stalled, _ := w.check(start.Add(6*time.Second), 5*time.Second, size)
+	if len(stalled) != 1 || stalled[0] != 2 {
+		t.Fatalf("stalled = %v, want camera 2", stalled)
+	}
+	if stalled, _ := w.check(start.Add(7*time.Second), 5*time.Second, size); len(stalled) != 0 {
+		t.Fatalf("a stalled camera must be reported once, got %v", stalled)
+	}
+
+	sizes["cam2.mkv"] = 2000
+	_, recovered := w.check(start.Add(8*time.Second), 5*time.Second, size)
+	if len(recovered) != 1 || recovered[0] != 2 {
+		t.Fatalf("recovered = %v, want camera 2", recovered)
+	}
+}
+
+func TestStalledCameraRestartsIntoANewPart(t *testing.T) {
+	if got := partFileName("/tmp/Smith_SNATCH_attempt1_Camera2_1000.mkv", 2); got != "/tmp/Smith_SNATCH_attempt1_Camera2_1000_part2.mkv" {
+		t.Fatalf("partFileName = %s", got)
+	}
+
+	start := time.Now()
+	w := newRecordingWatch(start, []string{"cam1.mkv"}, []int{1})
+	w.check(start.Add(11*time.Second), 10*time.Second, func(string) int64 { return 0 })
+	// The attempt of serial -1 is over: the camera stays stalled and is not
+	// reported as restarted.
+	w.restart(-1, 1, start.Add(11*time.Second))
+	if w.restarts[0] != 0 || !w.stalled[0] || w.fileNames[0] != "cam1.mkv" {
+		t.Fatalf("after a failed restart: restarts %v stalled %v files %v", w.restarts, w.stalled, w.fileNames)
+	}
+	if message := w.statusMessage(10 * time.Second); strings.Contains(message, "restarted") || !strings.Contains(message, "Camera 1 recorded nothing") {
+		t.Fatalf("status message after a failed restart: %s", message)
+	}
+}
+
+func TestJoinPartsConcatenatesTheParts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	// The fake ffmpeg copies the concat list it is given into the target.
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\nwhile [ \"$1\" != -i ]; do shift; done\nlist=$2\nfor last; do :; done\ncp \"$list\" \"$last\"\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldPath := config.GetFFmpegPath()
+	config.SetFFmpegPath(ffmpeg)
+	t.Cleanup(func() { config.SetFFmpegPath(oldPath) })
+
+	target := filepath.Join(dir, "O'Neil_Camera1_1000.mkv")
+	parts := []string{filepath.Join(dir, "O'Neil_Camera1_1000_part1.mkv"), filepath.Join(dir, "O'Neil_Camera1_1000_part2.mkv")}
+	if err := joinParts(parts, target); err != nil {
+		t.Fatal(err)
+	}
+	list, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ""
+	for _, part := range parts {
+		want += "file '" + strings.ReplaceAll(part, "'", `'\''`) + "'\n"
+	}
+	if string(list) != want {
+		t.Fatalf("concat list =\n%s\nwant\n%s", list, want)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(target, ".mkv") + ".txt"); !os.IsNotExist(err) {
+		t.Fatalf("the concat list was left behind: %v", err)
+	}
+
+	config.SetFFmpegPath(filepath.Join(dir, "missing"))
+	if err := joinParts(parts, target); err == nil {
+		t.Fatal("joinParts succeeded without ffmpeg")
+	}
+}
@@ -0,0 +1,41 @@
+package state
+
+import "sync"
+
+// DiskLevel is how full the disk of the videos is.
+type DiskLevel int
+
+const (
+	DiskOK DiskLevel = iota
+	DiskLow
+	DiskCritical // attempts are not recorded
+)
+
+var (
+	diskMu    sync.Mutex
+	diskLevel DiskLevel
+	diskAlert string
+
+	// OnDiskSpaceChanged is called after the disk level or its alert changes,
+	// outside of any lock.
+	OnDiskSpaceChanged func()
+)
+
+// SetDiskSpace records the level of the disk and the alert shown for it, ""
+// when there is enough space.
+func SetDiskSpace(level DiskLevel, alert string) {
+	diskMu.Lock()
+	changed := level != diskLevel || alert != diskAlert
+	diskLevel, diskAlert = level, alert
+	diskMu.Unlock()
+	if changed && OnDiskSpaceChanged != nil {
+		OnDiskSpaceChanged()
+	}
+}
+
+// DiskSpace returns the level of the disk and its alert.
+func DiskSpace() (DiskLevel, string) {
+	diskMu.Lock()
+	defer diskMu.Unlock()
+	return diskLevel, diskAlert
+}
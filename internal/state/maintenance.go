@@ -0,0 +1,87 @@
+package state
+
+import (
+	"sync"
+	"time"
+)
+
+// Maintenance mode pauses the recording of attempts while the operator works
+// on the cameras (swapping one, moving a tripod). It always ends on its own so
+// that a forgotten toggle cannot lose the rest of a session.
+const (
+	DefaultMaintenanceDuration = 10 * time.Minute
+	MaxMaintenanceDuration     = 2 * time.Hour
+)
+
+var (
+	maintenanceMu    sync.Mutex
+	maintenanceUntil time.Time
+	maintenanceTimer *time.Timer
+
+	// OnMaintenanceChanged is called after maintenance starts, is extended or
+	// ends, outside of any lock.
+	OnMaintenanceChanged func()
+)
+
+// StartMaintenance starts (or extends) maintenance mode for duration, bounded
+// by MaxMaintenanceDuration, and returns when it ends.
+func StartMaintenance(duration time.Duration) time.Time {
+	if duration <= 0 {
+		duration = DefaultMaintenanceDuration
+	}
+	if duration > MaxMaintenanceDuration {
+		duration = MaxMaintenanceDuration
+	}
+	maintenanceMu.Lock()
+	if maintenanceTimer != nil {
+		maintenanceTimer.Stop()
+	}
+	until := time.Now().Add(duration).Truncate(time.Second)
+	maintenanceUntil = until
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		maintenanceMu.Lock()
+		if maintenanceTimer != timer {
+			maintenanceMu.Unlock()
+			return
+		}
+		maintenanceUntil = time.Time{}
+		maintenanceTimer = nil
+		maintenanceMu.Unlock()
+		notifyMaintenanceChanged()
+	})
+	maintenanceTimer = timer
+	maintenanceMu.Unlock()
+	notifyMaintenanceChanged()
+	return until
+}
+
+// StopMaintenance ends maintenance mode. It reports whether it was active.
+func StopMaintenance() bool {
+	maintenanceMu.Lock()
+	active := maintenanceTimer != nil
+	if active {
+		maintenanceTimer.Stop()
+		maintenanceTimer = nil
+		maintenanceUntil = time.Time{}
+	}
+	maintenanceMu.Unlock()
+	if active {
+		notifyMaintenanceChanged()
+	}
+	return active
+}
+
+// MaintenanceUntil returns when maintenance mode ends, and whether it is
+// active.
+func MaintenanceUntil() (time.Time, bool) {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	return maintenanceUntil, maintenanceTimer != nil
+}
+
+func notifyMaintenanceChanged() {
+	if OnMaintenanceChanged != nil {
+		OnMaintenanceChanged()
+	}
+}
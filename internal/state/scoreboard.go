@@ -0,0 +1,143 @@
+package state
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClockEvent is a change of the attempt clock announced by owlcms.
+type ClockEvent struct {
+	At          time.Time
+	Running     bool
+	RemainingMs int
+}
+
+// Decision is a referees decision announced by owlcms: one light per
+// referee, "good" or "bad".
+type Decision struct {
+	At     time.Time
+	Lights []string
+}
+
+// maxClockEvents bounds the clock history; an attempt needs a handful.
+const maxClockEvents = 64
+
+var (
+	scoreboardMu sync.Mutex
+	clockEvents  []ClockEvent
+	decisions    []Decision
+)
+
+// timerMessage is the payload of owlcms/fop/timer.
+type timerMessage struct {
+	EventType  string `json:"athleteTimerEventType"`
+	MillisLeft *int   `json:"athleteMillisRemaining"`
+}
+
+// decisionMessage is the payload of owlcms/fop/decision.
+type decisionMessage struct {
+	EventType string          `json:"decisionEventType"`
+	D1        json.RawMessage `json:"d1"`
+	D2        json.RawMessage `json:"d2"`
+	D3        json.RawMessage `json:"d3"`
+}
+
+// UpdateStateFromTimerMessage records the clock of an owlcms timer message
+// received at. It returns false for messages about something else.
+func UpdateStateFromTimerMessage(message string, at time.Time) bool {
+	var timer timerMessage
+	if err := json.Unmarshal([]byte(message), &timer); err != nil || timer.MillisLeft == nil {
+		return false
+	}
+	event := ClockEvent{At: at, RemainingMs: *timer.MillisLeft}
+	switch timer.EventType {
+	case "StartTime":
+		event.Running = true
+	case "StopTime", "SetTime":
+	default:
+		return false
+	}
+	scoreboardMu.Lock()
+	defer scoreboardMu.Unlock()
+	clockEvents = append(clockEvents, event)
+	if len(clockEvents) > maxClockEvents {
+		clockEvents = clockEvents[len(clockEvents)-maxClockEvents:]
+	}
+	return true
+}
+
+// UpdateStateFromDecisionMessage records the lights of an owlcms decision
+// message received at; only full decisions are kept.
+func UpdateStateFromDecisionMessage(message string, at time.Time) bool {
+	var msg decisionMessage
+	if err := json.Unmarshal([]byte(message), &msg); err != nil {
+		return false
+	}
+	if msg.EventType != "" && msg.EventType != "FULL_DECISION" {
+		return false
+	}
+	var lights []string
+	for _, raw := range []json.RawMessage{msg.D1, msg.D2, msg.D3} {
+		light := parseLight(raw)
+		if light == "" {
+			return false
+		}
+		lights = append(lights, light)
+	}
+	scoreboardMu.Lock()
+	defer scoreboardMu.Unlock()
+	decisions = append(decisions, Decision{At: at, Lights: lights})
+	if len(decisions) > maxClockEvents {
+		decisions = decisions[len(decisions)-maxClockEvents:]
+	}
+	return true
+}
+
+// parseLight reads a referee light sent as a boolean or as "good" or "bad".
+func parseLight(raw json.RawMessage) string {
+	var good bool
+	if err := json.Unmarshal(raw, &good); err == nil {
+		if good {
+			return "good"
+		}
+		return "bad"
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return ""
+	}
+	switch strings.ToLower(text) {
+	case "good", "true", "white":
+		return "good"
+	case "bad", "false", "red":
+		return "bad"
+	}
+	return ""
+}
+
+// Scoreboard returns what the scoreboard shows between from and to: the
+// clock events, starting with the one in force at from, and the last
+// decision given in between, nil if none.
+func Scoreboard(from, to time.Time) ([]ClockEvent, *Decision) {
+	scoreboardMu.Lock()
+	defer scoreboardMu.Unlock()
+	var events []ClockEvent
+	for _, event := range clockEvents {
+		switch {
+		case !event.At.After(from):
+			events = []ClockEvent{event}
+		case event.At.Before(to):
+			events = append(events, event)
+		}
+	}
+	var decision *Decision
+	for i := range decisions {
+		if decisions[i].At.After(from) && decisions[i].At.Before(to) {
+			d := decisions[i]
+			decision = &d
+		}
+	}
+	return events, decision
+}
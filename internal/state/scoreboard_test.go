@@ -0,0 +1,32 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScoreboardKeepsTheClockAndDecisionOfTheReplay(t *testing.T) {
+	defer func() { clockEvents, decisions = nil, nil }()
+	base := time.Date(2026, 5, 2, 14, 0, 0, 0, time.UTC)
+	UpdateStateFromTimerMessage(`{"athleteTimerEventType":"SetTime","athleteMillisRemaining":60000}`, base)
+	UpdateStateFromTimerMessage(`{"athleteTimerEventType":"StartTime","athleteMillisRemaining":60000}`, base.Add(10*time.Second))
+	UpdateStateFromTimerMessage(`{"athleteTimerEventType":"StopTime","athleteMillisRemaining":48000}`, base.Add(22*time.Second))
+	if UpdateStateFromTimerMessage(`{"athleteTimerEventType":"StartTime"}`, base.Add(23*time.Second)) {
+		t.Error("a timer message without the time left was kept")
+	}
+	if UpdateStateFromDecisionMessage(`{"decisionEventType":"RESET","d1":true,"d2":true,"d3":true}`, base.Add(24*time.Second)) {
+		t.Error("a reset was kept as a decision")
+	}
+	UpdateStateFromDecisionMessage(`{"decisionEventType":"FULL_DECISION","d1":true,"d2":"bad","d3":true}`, base.Add(25*time.Second))
+
+	events, decision := Scoreboard(base.Add(5*time.Second), base.Add(30*time.Second))
+	if len(events) != 3 || events[0].Running || !events[1].Running || events[2].RemainingMs != 48000 {
+		t.Errorf("events = %+v, want the set time in force, then the start and the stop", events)
+	}
+	if decision == nil || len(decision.Lights) != 3 || decision.Lights[1] != "bad" || decision.Lights[2] != "good" {
+		t.Errorf("decision = %+v, want good, bad, good", decision)
+	}
+	if _, decision := Scoreboard(base, base.Add(20*time.Second)); decision != nil {
+		t.Errorf("decision = %+v, want none before it was given", decision)
+	}
+}
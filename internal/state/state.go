@@ -12,15 +12,20 @@ var (
 	LastStartTime     int64
 	LastTimerStopTime int64
 	LastDecisionTime  int64
+	// StartReceivedAt is when the last start message arrived, by our clock.
+	StartReceivedAt time.Time
 
 	// New state variables
 	CurrentAthlete      string
 	CurrentLiftType     string
 	CurrentAttempt      int
+	CurrentWeight       int // requested weight in kg, 0 when owlcms did not send it
 	StopRequestCount    int
 	CurrentCameraNumber int
 	CurrentSession      string // Current competition session name
 	AvailablePlatforms  []string
+	CurrentPlatform     string
+	JurySize            int // jury members announced by owlcms, 0 until its config message
 )
 
 type StartMessage struct {
@@ -28,6 +33,7 @@ type StartMessage struct {
 	AttemptNumber int    `json:"attemptNumber"`
 	LiftType      string `json:"liftType"`
 	Session       string `json:"session"` // Add session field
+	Weight        int    `json:"requestedWeight"`
 }
 
 func UpdateStateFromStartMessage(message string) {
@@ -57,6 +63,7 @@ func UpdateStateFromStartMessage(message string) {
 	CurrentAthlete = startMsg.AthleteName
 	CurrentAttempt = startMsg.AttemptNumber
 	CurrentLiftType = startMsg.LiftType
+	CurrentWeight = startMsg.Weight
 	CurrentSession = startMsg.Session
 	CurrentSession = strings.ReplaceAll(CurrentSession, " ", "_")
 	LastStartTime = parseTime(timePart)
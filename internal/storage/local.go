@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps the videos in a directory of this machine.
+type Local struct {
+	Root string
+}
+
+// path returns the file of a name, which cannot leave the root.
+func (l Local) path(name string) string {
+	return filepath.Join(l.Root, filepath.FromSlash(Join(name)))
+}
+
+func (l Local) Open(name string) (http.File, error) {
+	return http.Dir(l.Root).Open("/" + Join(name))
+}
+
+func (l Local) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(l.path(name))
+}
+
+func (l Local) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(l.path(name))
+}
+
+func (l Local) MkdirAll(name string) error {
+	return os.MkdirAll(l.path(name), os.ModePerm)
+}
+
+func (l Local) WriteFile(name string, data []byte) error {
+	return writeAtomic(l.path(name), bytes.NewReader(data))
+}
+
+// writeAtomic writes target from r through a temporary file renamed at the
+// end, so that readers never see it half written.
+func writeAtomic(target string, r io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".storage-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// Import renames the file into the directory; files already written in place
+// are left alone.
+func (l Local) Import(localPath, name string) error {
+	target := l.path(name)
+	if filepath.Clean(localPath) == target {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(localPath, target)
+}
+
+func (l Local) Remove(name string) error {
+	return os.Remove(l.path(name))
+}
+
+func (l Local) LocalPath(name string) (string, bool) {
+	return l.path(name), true
+}
+
+func (l Local) String() string {
+	return l.Root
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalKeepsNamesInsideItsRoot(t *testing.T) {
+	root := t.TempDir()
+	store := Local{Root: root}
+
+	if err := store.MkdirAll("Session_A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteFile("../../Session_A/note.webm", []byte("audio")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "Session_A", "note.webm"))
+	if err != nil || string(data) != "audio" {
+		t.Fatalf("note written outside the session folder: %q, %v", data, err)
+	}
+
+	staged := filepath.Join(t.TempDir(), "replay.mp4")
+	if err := os.WriteFile(staged, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Import(staged, Join("Session_B", "replay.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	file, err := store.Open("Session_B/replay.mp4")
+	if err != nil {
+		t.Fatalf("imported replay cannot be opened: %v", err)
+	}
+	file.Close()
+	if path, ok := store.LocalPath("Session_B/replay.mp4"); !ok || path != filepath.Join(root, "Session_B", "replay.mp4") {
+		t.Fatalf("LocalPath() = %q, %v", path, ok)
+	}
+	if err := store.Import(filepath.Join(root, "Session_B", "replay.mp4"), "Session_B/replay.mp4"); err != nil {
+		t.Fatalf("importing a file already in place: %v", err)
+	}
+}
+
+func TestShareCopiesTheFinishedFiles(t *testing.T) {
+	store := Share{Local{Root: t.TempDir()}}
+	staged := filepath.Join(t.TempDir(), "replay.mp4")
+	if err := os.WriteFile(staged, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Import(staged, "Session_A/replay.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(store.Root, "Session_A", "replay.mp4"))
+	if err != nil || string(data) != "video" {
+		t.Fatalf("replay on the share: %q, %v", data, err)
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Fatalf("local copy left behind: %v", err)
+	}
+	if _, ok := store.LocalPath("Session_A/replay.mp4"); ok {
+		t.Fatal("ffmpeg must not work on the share")
+	}
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Share keeps the videos on a mounted SMB or NFS share. ffmpeg works in the
+// local video directory, since writing over the network could stall a
+// recording; the finished files are then copied to the share.
+type Share struct {
+	Local
+}
+
+// Import copies the file to the share and deletes the local one.
+func (s Share) Import(localPath, name string) error {
+	target := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	err = writeAtomic(target, file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(localPath)
+}
+
+// LocalPath is false: the files of the share are copied to the local video
+// directory before ffmpeg reads them.
+func (s Share) LocalPath(name string) (string, bool) {
+	return "", false
+}
+
+func (s Share) String() string {
+	return "share " + s.Root
+}
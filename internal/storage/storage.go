@@ -0,0 +1,122 @@
+// Package storage keeps the replay videos and the files that go with them
+// (voice notes, annotations, exports). ffmpeg records and trims local files;
+// finished files are handed to the storage backend, from which the web server
+// lists and serves them. The backend is chosen by the [storage] table of the
+// configuration: the local video directory (the default), a mounted SMB or
+// NFS share, or a bucket of an S3-compatible object storage. The last two
+// record and trim in the local video directory and only receive the finished
+// files.
+//
+// Names are slash-separated and relative to the root of the storage, such as
+// "Session_A/2025-03-01_14h00m00s_DOE_John_SNATCH_attempt1_Camera1.mp4".
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/owlcms/replays/internal/config"
+)
+
+// Backend stores the videos of the sessions.
+type Backend interface {
+	// Open opens a stored file for reading. A Backend is an http.FileSystem.
+	Open(name string) (http.File, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Stat(name string) (fs.FileInfo, error)
+	MkdirAll(name string) error
+	// WriteFile replaces a stored file; readers never see it half written.
+	WriteFile(name string, data []byte) error
+	// Import moves a finished local file into storage under name.
+	Import(localPath, name string) error
+	Remove(name string) error
+	// LocalPath returns where a stored file is on this machine, for the
+	// programs that need a real file (ffmpeg, ffprobe). ok is false when the
+	// backend keeps no local copy.
+	LocalPath(name string) (path string, ok bool)
+}
+
+var (
+	mu      sync.Mutex
+	current Backend
+)
+
+// New returns the backend of the [storage] settings, nil for the local video
+// directory.
+func New(settings config.StorageSettings) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(settings.Type)) {
+	case "", "local":
+		return nil, nil
+	case "share":
+		if settings.Path == "" {
+			return nil, fmt.Errorf("storage type \"share\" needs the path where the share is mounted")
+		}
+		info, err := os.Stat(settings.Path)
+		if err != nil {
+			return nil, fmt.Errorf("share %s is not available: %w", settings.Path, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("share %s is not a folder", settings.Path)
+		}
+		return Share{Local{Root: settings.Path}}, nil
+	case "s3":
+		s3, err := NewS3(settings)
+		if err != nil {
+			return nil, err
+		}
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("unknown storage type %q, expected \"local\", \"share\" or \"s3\"", settings.Type)
+	}
+}
+
+// Current returns the backend in use: the one set with Use, or else the
+// local video directory.
+func Current() Backend {
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		return current
+	}
+	return Local{Root: config.GetVideoDir()}
+}
+
+// Use makes backend the storage of the videos; nil returns to the local
+// video directory.
+func Use(backend Backend) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = backend
+}
+
+// FileSystem serves the current backend over HTTP, following later changes
+// of backend or video directory.
+var FileSystem http.FileSystem = currentFileSystem{}
+
+type currentFileSystem struct{}
+
+func (currentFileSystem) Open(name string) (http.File, error) {
+	return Current().Open(name)
+}
+
+// ReadFile returns the content of a stored file.
+func ReadFile(name string) ([]byte, error) {
+	file, err := Current().Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Join builds a storage name from its parts. The result never leaves the
+// root of the storage.
+func Join(elem ...string) string {
+	return strings.TrimPrefix(path.Join(append([]string{"/"}, elem...)...), "/")
+}